@@ -46,6 +46,15 @@ type AuthZServiceClient interface {
 	// 401	Not Authorized
 	// 500	Internal Error
 	Allocate(ctx context.Context, in *AllocateResourceRequest, opts ...grpc.CallOption) (*AllocateResourceResponse, error)
+	// RenewAllocation Resources swagger:route PUT /api/v1/{organization_id}/{namespace}/resources/{id}/renew/{principal_id} resources renewAllocationRequest
+	//
+	// Responses:
+	// 200: renewAllocationResponse
+	// 400	Bad Request
+	// 401	Not Authorized
+	// 404	Not Found
+	// 500	Internal Error
+	RenewAllocation(ctx context.Context, in *RenewAllocationRequest, opts ...grpc.CallOption) (*RenewAllocationResponse, error)
 	// Deallocate Resources swagger:route PUT /api/v1/{organization_id}/{namespace}/resources/{id}/deallocate/{principal_id} resources deallocateResourceRequest
 	//
 	// Responses:
@@ -91,6 +100,15 @@ func (c *authZServiceClient) Allocate(ctx context.Context, in *AllocateResourceR
 	return out, nil
 }
 
+func (c *authZServiceClient) RenewAllocation(ctx context.Context, in *RenewAllocationRequest, opts ...grpc.CallOption) (*RenewAllocationResponse, error) {
+	out := new(RenewAllocationResponse)
+	err := c.cc.Invoke(ctx, "/api.authz.services.AuthZService/RenewAllocation", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *authZServiceClient) Deallocate(ctx context.Context, in *DeallocateResourceRequest, opts ...grpc.CallOption) (*DeallocateResourceResponse, error) {
 	out := new(DeallocateResourceResponse)
 	err := c.cc.Invoke(ctx, "/api.authz.services.AuthZService/Deallocate", in, out, opts...)
@@ -128,6 +146,15 @@ type AuthZServiceServer interface {
 	// 401	Not Authorized
 	// 500	Internal Error
 	Allocate(context.Context, *AllocateResourceRequest) (*AllocateResourceResponse, error)
+	// RenewAllocation Resources swagger:route PUT /api/v1/{organization_id}/{namespace}/resources/{id}/renew/{principal_id} resources renewAllocationRequest
+	//
+	// Responses:
+	// 200: renewAllocationResponse
+	// 400	Bad Request
+	// 401	Not Authorized
+	// 404	Not Found
+	// 500	Internal Error
+	RenewAllocation(context.Context, *RenewAllocationRequest) (*RenewAllocationResponse, error)
 	// Deallocate Resources swagger:route PUT /api/v1/{organization_id}/{namespace}/resources/{id}/deallocate/{principal_id} resources deallocateResourceRequest
 	//
 	// Responses:
@@ -152,6 +179,9 @@ func (UnimplementedAuthZServiceServer) Check(context.Context, *CheckConstraintsR
 func (UnimplementedAuthZServiceServer) Allocate(context.Context, *AllocateResourceRequest) (*AllocateResourceResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Allocate not implemented")
 }
+func (UnimplementedAuthZServiceServer) RenewAllocation(context.Context, *RenewAllocationRequest) (*RenewAllocationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RenewAllocation not implemented")
+}
 func (UnimplementedAuthZServiceServer) Deallocate(context.Context, *DeallocateResourceRequest) (*DeallocateResourceResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Deallocate not implemented")
 }
@@ -222,6 +252,24 @@ func _AuthZService_Allocate_Handler(srv interface{}, ctx context.Context, dec fu
 	return interceptor(ctx, in, info, handler)
 }
 
+func _AuthZService_RenewAllocation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RenewAllocationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthZServiceServer).RenewAllocation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.authz.services.AuthZService/RenewAllocation",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthZServiceServer).RenewAllocation(ctx, req.(*RenewAllocationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _AuthZService_Deallocate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(DeallocateResourceRequest)
 	if err := dec(in); err != nil {
@@ -259,6 +307,10 @@ var AuthZService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Allocate",
 			Handler:    _AuthZService_Allocate_Handler,
 		},
+		{
+			MethodName: "RenewAllocation",
+			Handler:    _AuthZService_RenewAllocation_Handler,
+		},
 		{
 			MethodName: "Deallocate",
 			Handler:    _AuthZService_Deallocate_Handler,
@@ -0,0 +1,146 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v3.20.1
+// source: api/v1/services/decision_service.proto
+
+package services
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// DecisionsServiceClient is the client API for DecisionsService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type DecisionsServiceClient interface {
+	// Query Decisions swagger:route GET /api/v1/{organization_id}/{namespace}/decisions decisions queryDecisionRequest
+	//
+	// Responses:
+	// 200: queryDecisionResponse
+	// 400	Bad Request
+	// 401	Not Authorized
+	// 500	Internal Error
+	Query(ctx context.Context, in *QueryDecisionRequest, opts ...grpc.CallOption) (DecisionsService_QueryClient, error)
+}
+
+type decisionsServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDecisionsServiceClient(cc grpc.ClientConnInterface) DecisionsServiceClient {
+	return &decisionsServiceClient{cc}
+}
+
+func (c *decisionsServiceClient) Query(ctx context.Context, in *QueryDecisionRequest, opts ...grpc.CallOption) (DecisionsService_QueryClient, error) {
+	stream, err := c.cc.NewStream(ctx, &DecisionsService_ServiceDesc.Streams[0], "/api.authz.services.DecisionsService/Query", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &decisionsServiceQueryClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type DecisionsService_QueryClient interface {
+	Recv() (*QueryDecisionResponse, error)
+	grpc.ClientStream
+}
+
+type decisionsServiceQueryClient struct {
+	grpc.ClientStream
+}
+
+func (x *decisionsServiceQueryClient) Recv() (*QueryDecisionResponse, error) {
+	m := new(QueryDecisionResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// DecisionsServiceServer is the server API for DecisionsService service.
+// All implementations must embed UnimplementedDecisionsServiceServer
+// for forward compatibility
+type DecisionsServiceServer interface {
+	// Query Decisions swagger:route GET /api/v1/{organization_id}/{namespace}/decisions decisions queryDecisionRequest
+	//
+	// Responses:
+	// 200: queryDecisionResponse
+	// 400	Bad Request
+	// 401	Not Authorized
+	// 500	Internal Error
+	Query(*QueryDecisionRequest, DecisionsService_QueryServer) error
+	mustEmbedUnimplementedDecisionsServiceServer()
+}
+
+// UnimplementedDecisionsServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedDecisionsServiceServer struct {
+}
+
+func (UnimplementedDecisionsServiceServer) Query(*QueryDecisionRequest, DecisionsService_QueryServer) error {
+	return status.Errorf(codes.Unimplemented, "method Query not implemented")
+}
+func (UnimplementedDecisionsServiceServer) mustEmbedUnimplementedDecisionsServiceServer() {}
+
+// UnsafeDecisionsServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DecisionsServiceServer will
+// result in compilation errors.
+type UnsafeDecisionsServiceServer interface {
+	mustEmbedUnimplementedDecisionsServiceServer()
+}
+
+func RegisterDecisionsServiceServer(s grpc.ServiceRegistrar, srv DecisionsServiceServer) {
+	s.RegisterService(&DecisionsService_ServiceDesc, srv)
+}
+
+func _DecisionsService_Query_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(QueryDecisionRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DecisionsServiceServer).Query(m, &decisionsServiceQueryServer{stream})
+}
+
+type DecisionsService_QueryServer interface {
+	Send(*QueryDecisionResponse) error
+	grpc.ServerStream
+}
+
+type decisionsServiceQueryServer struct {
+	grpc.ServerStream
+}
+
+func (x *decisionsServiceQueryServer) Send(m *QueryDecisionResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// DecisionsService_ServiceDesc is the grpc.ServiceDesc for DecisionsService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var DecisionsService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "api.authz.services.DecisionsService",
+	HandlerType: (*DecisionsServiceServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Query",
+			Handler:       _DecisionsService_Query_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "api/v1/services/decision_service.proto",
+}
@@ -0,0 +1,247 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v3.20.1
+// source: api/v1/services/policy_service.proto
+
+package services
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// PoliciesServiceClient is the client API for PoliciesService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PoliciesServiceClient interface {
+	// Add Policy
+	//
+	// Responses:
+	// 200: addPolicyResponse
+	// 400	Bad Request
+	// 401	Not Authorized
+	// 500	Internal Error
+	Add(ctx context.Context, in *AddPolicyRequest, opts ...grpc.CallOption) (*AddPolicyResponse, error)
+	// Remove Policy
+	//
+	// Responses:
+	// 200: removePolicyResponse
+	// 400	Bad Request
+	// 401	Not Authorized
+	// 500	Internal Error
+	Remove(ctx context.Context, in *RemovePolicyRequest, opts ...grpc.CallOption) (*RemovePolicyResponse, error)
+	// Query Policies
+	//
+	// Responses:
+	// 200: queryPolicyResponse
+	// 400	Bad Request
+	// 401	Not Authorized
+	// 500	Internal Error
+	Query(ctx context.Context, in *QueryPolicyRequest, opts ...grpc.CallOption) (PoliciesService_QueryClient, error)
+}
+
+type policiesServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPoliciesServiceClient(cc grpc.ClientConnInterface) PoliciesServiceClient {
+	return &policiesServiceClient{cc}
+}
+
+func (c *policiesServiceClient) Add(ctx context.Context, in *AddPolicyRequest, opts ...grpc.CallOption) (*AddPolicyResponse, error) {
+	out := new(AddPolicyResponse)
+	err := c.cc.Invoke(ctx, "/api.authz.services.PoliciesService/Add", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *policiesServiceClient) Remove(ctx context.Context, in *RemovePolicyRequest, opts ...grpc.CallOption) (*RemovePolicyResponse, error) {
+	out := new(RemovePolicyResponse)
+	err := c.cc.Invoke(ctx, "/api.authz.services.PoliciesService/Remove", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *policiesServiceClient) Query(ctx context.Context, in *QueryPolicyRequest, opts ...grpc.CallOption) (PoliciesService_QueryClient, error) {
+	stream, err := c.cc.NewStream(ctx, &PoliciesService_ServiceDesc.Streams[0], "/api.authz.services.PoliciesService/Query", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &policiesServiceQueryClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type PoliciesService_QueryClient interface {
+	Recv() (*QueryPolicyResponse, error)
+	grpc.ClientStream
+}
+
+type policiesServiceQueryClient struct {
+	grpc.ClientStream
+}
+
+func (x *policiesServiceQueryClient) Recv() (*QueryPolicyResponse, error) {
+	m := new(QueryPolicyResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// PoliciesServiceServer is the server API for PoliciesService service.
+// All implementations must embed UnimplementedPoliciesServiceServer
+// for forward compatibility
+type PoliciesServiceServer interface {
+	// Add Policy
+	//
+	// Responses:
+	// 200: addPolicyResponse
+	// 400	Bad Request
+	// 401	Not Authorized
+	// 500	Internal Error
+	Add(context.Context, *AddPolicyRequest) (*AddPolicyResponse, error)
+	// Remove Policy
+	//
+	// Responses:
+	// 200: removePolicyResponse
+	// 400	Bad Request
+	// 401	Not Authorized
+	// 500	Internal Error
+	Remove(context.Context, *RemovePolicyRequest) (*RemovePolicyResponse, error)
+	// Query Policies
+	//
+	// Responses:
+	// 200: queryPolicyResponse
+	// 400	Bad Request
+	// 401	Not Authorized
+	// 500	Internal Error
+	Query(*QueryPolicyRequest, PoliciesService_QueryServer) error
+	mustEmbedUnimplementedPoliciesServiceServer()
+}
+
+// UnimplementedPoliciesServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedPoliciesServiceServer struct {
+}
+
+func (UnimplementedPoliciesServiceServer) Add(context.Context, *AddPolicyRequest) (*AddPolicyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Add not implemented")
+}
+func (UnimplementedPoliciesServiceServer) Remove(context.Context, *RemovePolicyRequest) (*RemovePolicyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Remove not implemented")
+}
+func (UnimplementedPoliciesServiceServer) Query(*QueryPolicyRequest, PoliciesService_QueryServer) error {
+	return status.Errorf(codes.Unimplemented, "method Query not implemented")
+}
+func (UnimplementedPoliciesServiceServer) mustEmbedUnimplementedPoliciesServiceServer() {}
+
+// UnsafePoliciesServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PoliciesServiceServer will
+// result in compilation errors.
+type UnsafePoliciesServiceServer interface {
+	mustEmbedUnimplementedPoliciesServiceServer()
+}
+
+func RegisterPoliciesServiceServer(s grpc.ServiceRegistrar, srv PoliciesServiceServer) {
+	s.RegisterService(&PoliciesService_ServiceDesc, srv)
+}
+
+func _PoliciesService_Add_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddPolicyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PoliciesServiceServer).Add(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.authz.services.PoliciesService/Add",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PoliciesServiceServer).Add(ctx, req.(*AddPolicyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PoliciesService_Remove_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemovePolicyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PoliciesServiceServer).Remove(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.authz.services.PoliciesService/Remove",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PoliciesServiceServer).Remove(ctx, req.(*RemovePolicyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PoliciesService_Query_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(QueryPolicyRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PoliciesServiceServer).Query(m, &policiesServiceQueryServer{stream})
+}
+
+type PoliciesService_QueryServer interface {
+	Send(*QueryPolicyResponse) error
+	grpc.ServerStream
+}
+
+type policiesServiceQueryServer struct {
+	grpc.ServerStream
+}
+
+func (x *policiesServiceQueryServer) Send(m *QueryPolicyResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// PoliciesService_ServiceDesc is the grpc.ServiceDesc for PoliciesService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var PoliciesService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "api.authz.services.PoliciesService",
+	HandlerType: (*PoliciesServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Add",
+			Handler:    _PoliciesService_Add_Handler,
+		},
+		{
+			MethodName: "Remove",
+			Handler:    _PoliciesService_Remove_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Query",
+			Handler:       _PoliciesService_Query_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "api/v1/services/policy_service.proto",
+}
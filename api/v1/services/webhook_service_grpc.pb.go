@@ -0,0 +1,374 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v3.20.1
+// source: api/v1/services/webhook_service.proto
+
+package services
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// WebhooksServiceClient is the client API for WebhooksService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type WebhooksServiceClient interface {
+	// Create Webhooks swagger:route POST /api/v1/{organization_id}/webhooks webhooks createWebhookRequest
+	//
+	// Responses:
+	// 200: createWebhookResponse
+	// 400	Bad Request
+	// 401	Not Authorized
+	// 500	Internal Error
+	Create(ctx context.Context, in *CreateWebhookRequest, opts ...grpc.CallOption) (*CreateWebhookResponse, error)
+	// Update Webhooks swagger:route PUT /api/v1/{organization_id}/webhooks/{id} webhooks updateWebhookRequest
+	//
+	// Responses:
+	// 200: updateWebhookResponse
+	// 400	Bad Request
+	// 401	Not Authorized
+	// 500	Internal Error
+	Update(ctx context.Context, in *UpdateWebhookRequest, opts ...grpc.CallOption) (*UpdateWebhookResponse, error)
+	// Query Webhooks swagger:route GET /api/v1/{organization_id}/webhooks webhooks queryWebhookRequest
+	//
+	// Responses:
+	// 200: queryWebhookResponse
+	// 400	Bad Request
+	// 401	Not Authorized
+	// 500	Internal Error
+	Query(ctx context.Context, in *QueryWebhookRequest, opts ...grpc.CallOption) (WebhooksService_QueryClient, error)
+	// Delete Webhooks swagger:route DELETE /api/v1/{organization_id}/webhooks/{id} webhooks deleteWebhookRequest
+	//
+	// Responses:
+	// 200: deleteWebhookResponse
+	// 400	Bad Request
+	// 401	Not Authorized
+	// 500	Internal Error
+	Delete(ctx context.Context, in *DeleteWebhookRequest, opts ...grpc.CallOption) (*DeleteWebhookResponse, error)
+	// Query Webhook Deliveries swagger:route GET /api/v1/{organization_id}/webhooks/{webhook_id}/deliveries webhooks queryWebhookDeliveryRequest
+	//
+	// Responses:
+	// 200: queryWebhookDeliveryResponse
+	// 400	Bad Request
+	// 401	Not Authorized
+	// 500	Internal Error
+	QueryDeliveries(ctx context.Context, in *QueryWebhookDeliveryRequest, opts ...grpc.CallOption) (WebhooksService_QueryDeliveriesClient, error)
+}
+
+type webhooksServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewWebhooksServiceClient(cc grpc.ClientConnInterface) WebhooksServiceClient {
+	return &webhooksServiceClient{cc}
+}
+
+func (c *webhooksServiceClient) Create(ctx context.Context, in *CreateWebhookRequest, opts ...grpc.CallOption) (*CreateWebhookResponse, error) {
+	out := new(CreateWebhookResponse)
+	err := c.cc.Invoke(ctx, "/api.authz.services.WebhooksService/Create", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *webhooksServiceClient) Update(ctx context.Context, in *UpdateWebhookRequest, opts ...grpc.CallOption) (*UpdateWebhookResponse, error) {
+	out := new(UpdateWebhookResponse)
+	err := c.cc.Invoke(ctx, "/api.authz.services.WebhooksService/Update", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *webhooksServiceClient) Query(ctx context.Context, in *QueryWebhookRequest, opts ...grpc.CallOption) (WebhooksService_QueryClient, error) {
+	stream, err := c.cc.NewStream(ctx, &WebhooksService_ServiceDesc.Streams[0], "/api.authz.services.WebhooksService/Query", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &webhooksServiceQueryClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type WebhooksService_QueryClient interface {
+	Recv() (*QueryWebhookResponse, error)
+	grpc.ClientStream
+}
+
+type webhooksServiceQueryClient struct {
+	grpc.ClientStream
+}
+
+func (x *webhooksServiceQueryClient) Recv() (*QueryWebhookResponse, error) {
+	m := new(QueryWebhookResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *webhooksServiceClient) Delete(ctx context.Context, in *DeleteWebhookRequest, opts ...grpc.CallOption) (*DeleteWebhookResponse, error) {
+	out := new(DeleteWebhookResponse)
+	err := c.cc.Invoke(ctx, "/api.authz.services.WebhooksService/Delete", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *webhooksServiceClient) QueryDeliveries(ctx context.Context, in *QueryWebhookDeliveryRequest, opts ...grpc.CallOption) (WebhooksService_QueryDeliveriesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &WebhooksService_ServiceDesc.Streams[1], "/api.authz.services.WebhooksService/QueryDeliveries", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &webhooksServiceQueryDeliveriesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type WebhooksService_QueryDeliveriesClient interface {
+	Recv() (*QueryWebhookDeliveryResponse, error)
+	grpc.ClientStream
+}
+
+type webhooksServiceQueryDeliveriesClient struct {
+	grpc.ClientStream
+}
+
+func (x *webhooksServiceQueryDeliveriesClient) Recv() (*QueryWebhookDeliveryResponse, error) {
+	m := new(QueryWebhookDeliveryResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// WebhooksServiceServer is the server API for WebhooksService service.
+// All implementations must embed UnimplementedWebhooksServiceServer
+// for forward compatibility
+type WebhooksServiceServer interface {
+	// Create Webhooks swagger:route POST /api/v1/{organization_id}/webhooks webhooks createWebhookRequest
+	//
+	// Responses:
+	// 200: createWebhookResponse
+	// 400	Bad Request
+	// 401	Not Authorized
+	// 500	Internal Error
+	Create(context.Context, *CreateWebhookRequest) (*CreateWebhookResponse, error)
+	// Update Webhooks swagger:route PUT /api/v1/{organization_id}/webhooks/{id} webhooks updateWebhookRequest
+	//
+	// Responses:
+	// 200: updateWebhookResponse
+	// 400	Bad Request
+	// 401	Not Authorized
+	// 500	Internal Error
+	Update(context.Context, *UpdateWebhookRequest) (*UpdateWebhookResponse, error)
+	// Query Webhooks swagger:route GET /api/v1/{organization_id}/webhooks webhooks queryWebhookRequest
+	//
+	// Responses:
+	// 200: queryWebhookResponse
+	// 400	Bad Request
+	// 401	Not Authorized
+	// 500	Internal Error
+	Query(*QueryWebhookRequest, WebhooksService_QueryServer) error
+	// Delete Webhooks swagger:route DELETE /api/v1/{organization_id}/webhooks/{id} webhooks deleteWebhookRequest
+	//
+	// Responses:
+	// 200: deleteWebhookResponse
+	// 400	Bad Request
+	// 401	Not Authorized
+	// 500	Internal Error
+	Delete(context.Context, *DeleteWebhookRequest) (*DeleteWebhookResponse, error)
+	// Query Webhook Deliveries swagger:route GET /api/v1/{organization_id}/webhooks/{webhook_id}/deliveries webhooks queryWebhookDeliveryRequest
+	//
+	// Responses:
+	// 200: queryWebhookDeliveryResponse
+	// 400	Bad Request
+	// 401	Not Authorized
+	// 500	Internal Error
+	QueryDeliveries(*QueryWebhookDeliveryRequest, WebhooksService_QueryDeliveriesServer) error
+	mustEmbedUnimplementedWebhooksServiceServer()
+}
+
+// UnimplementedWebhooksServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedWebhooksServiceServer struct {
+}
+
+func (UnimplementedWebhooksServiceServer) Create(context.Context, *CreateWebhookRequest) (*CreateWebhookResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Create not implemented")
+}
+func (UnimplementedWebhooksServiceServer) Update(context.Context, *UpdateWebhookRequest) (*UpdateWebhookResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Update not implemented")
+}
+func (UnimplementedWebhooksServiceServer) Query(*QueryWebhookRequest, WebhooksService_QueryServer) error {
+	return status.Errorf(codes.Unimplemented, "method Query not implemented")
+}
+func (UnimplementedWebhooksServiceServer) Delete(context.Context, *DeleteWebhookRequest) (*DeleteWebhookResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedWebhooksServiceServer) QueryDeliveries(*QueryWebhookDeliveryRequest, WebhooksService_QueryDeliveriesServer) error {
+	return status.Errorf(codes.Unimplemented, "method QueryDeliveries not implemented")
+}
+func (UnimplementedWebhooksServiceServer) mustEmbedUnimplementedWebhooksServiceServer() {}
+
+// UnsafeWebhooksServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to WebhooksServiceServer will
+// result in compilation errors.
+type UnsafeWebhooksServiceServer interface {
+	mustEmbedUnimplementedWebhooksServiceServer()
+}
+
+func RegisterWebhooksServiceServer(s grpc.ServiceRegistrar, srv WebhooksServiceServer) {
+	s.RegisterService(&WebhooksService_ServiceDesc, srv)
+}
+
+func _WebhooksService_Create_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateWebhookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WebhooksServiceServer).Create(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.authz.services.WebhooksService/Create",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WebhooksServiceServer).Create(ctx, req.(*CreateWebhookRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WebhooksService_Update_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateWebhookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WebhooksServiceServer).Update(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.authz.services.WebhooksService/Update",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WebhooksServiceServer).Update(ctx, req.(*UpdateWebhookRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WebhooksService_Query_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(QueryWebhookRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WebhooksServiceServer).Query(m, &webhooksServiceQueryServer{stream})
+}
+
+type WebhooksService_QueryServer interface {
+	Send(*QueryWebhookResponse) error
+	grpc.ServerStream
+}
+
+type webhooksServiceQueryServer struct {
+	grpc.ServerStream
+}
+
+func (x *webhooksServiceQueryServer) Send(m *QueryWebhookResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _WebhooksService_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteWebhookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WebhooksServiceServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.authz.services.WebhooksService/Delete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WebhooksServiceServer).Delete(ctx, req.(*DeleteWebhookRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WebhooksService_QueryDeliveries_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(QueryWebhookDeliveryRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WebhooksServiceServer).QueryDeliveries(m, &webhooksServiceQueryDeliveriesServer{stream})
+}
+
+type WebhooksService_QueryDeliveriesServer interface {
+	Send(*QueryWebhookDeliveryResponse) error
+	grpc.ServerStream
+}
+
+type webhooksServiceQueryDeliveriesServer struct {
+	grpc.ServerStream
+}
+
+func (x *webhooksServiceQueryDeliveriesServer) Send(m *QueryWebhookDeliveryResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// WebhooksService_ServiceDesc is the grpc.ServiceDesc for WebhooksService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var WebhooksService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "api.authz.services.WebhooksService",
+	HandlerType: (*WebhooksServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Create",
+			Handler:    _WebhooksService_Create_Handler,
+		},
+		{
+			MethodName: "Update",
+			Handler:    _WebhooksService_Update_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _WebhooksService_Delete_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Query",
+			Handler:       _WebhooksService_Query_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "QueryDeliveries",
+			Handler:       _WebhooksService_QueryDeliveries_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "api/v1/services/webhook_service.proto",
+}
@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/bhatti/PlexAuthZ/internal/bundle"
+	cfg "github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/factory"
+	"github.com/bhatti/PlexAuthZ/internal/metrics"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var bundleOrganizationID string
+var bundleOutput string
+
+var bundleCmd = &cobra.Command{
+	Use:   "bundle",
+	Short: "Exports signed bundle of an organization",
+	Long:  `Exports authorization model of an organization as a bundle signed with the private key of bundle config`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := bundleRun(); err != nil {
+			log.Fatalf("error: %v", err)
+		}
+	},
+}
+
+func init() {
+	bundleCmd.Flags().StringVar(
+		&bundleOrganizationID,
+		"org",
+		"",
+		"organization id")
+	bundleCmd.Flags().StringVar(
+		&bundleOutput,
+		"out",
+		"",
+		"output file, which defaults to bundle path of config")
+	rootCmd.AddCommand(bundleCmd)
+}
+
+func bundleRun() error {
+	if bundleOrganizationID == "" {
+		return fmt.Errorf("organization id is not defined")
+	}
+	output := bundleOutput
+	if output == "" {
+		output = config.Bundle.Path
+	}
+	if output == "" {
+		return fmt.Errorf("output file is not defined")
+	}
+	privateKey, err := bundle.LoadPrivateKey(config.Bundle.PrivateKeyFile)
+	if err != nil {
+		return err
+	}
+	authService, _, err := factory.CreateAuthAdminService(config, metrics.New(), cfg.RootClientType, "")
+	if err != nil {
+		return err
+	}
+	b, err := bundle.Export(context.Background(), authService, bundleOrganizationID)
+	if err != nil {
+		return err
+	}
+	data, err := bundle.Sign(b, privateKey)
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(output, data, 0600); err != nil {
+		return err
+	}
+	fmt.Printf("exported bundle of %s with revision %d to %s\n", bundleOrganizationID, b.Revision, output)
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	cfg "github.com/bhatti/PlexAuthZ/internal/domain"
 	"github.com/bhatti/PlexAuthZ/internal/factory"
 	"github.com/bhatti/PlexAuthZ/internal/metrics"
+	"github.com/bhatti/PlexAuthZ/internal/ratelimit"
 	"github.com/bhatti/PlexAuthZ/internal/server"
 	"github.com/bhatti/PlexAuthZ/internal/version"
 	"github.com/bhatti/PlexAuthZ/internal/web"
@@ -41,19 +42,25 @@ func init() {
 }
 
 func rootRun(_ *cobra.Command, _ []string) error {
-	// Start web server for health status
-	webServer := web.NewDefaultWebServer(config)
+	// registry and rate limiter are shared by gRPC and REST servers
+	metricsRegistry := metrics.New()
 
-	authService, _, err := factory.CreateAuthAdminService(config, metrics.New(), cfg.RootClientType, "")
+	// Start web server for health status
+	webServer := web.NewDefaultWebServer(config, metricsRegistry)
+	authService, _, err := factory.CreateAuthAdminService(config, metricsRegistry, cfg.RootClientType, "")
+	if err != nil {
+		return err
+	}
+	rateLimiter, err := ratelimit.CreateRateLimiter(config, authService, metricsRegistry)
 	if err != nil {
 		return err
 	}
-	err = controller.StartControllers(config, authService, webServer)
+	err = controller.StartControllers(config, authService, webServer, metricsRegistry, rateLimiter)
 	if err != nil {
 		return err
 	}
 
-	grpcServer, err := server.StartServers(config, authService)
+	grpcServer, err := server.StartServers(config, authService, metricsRegistry, rateLimiter)
 	if err != nil {
 		log.WithField("Error", err).Fatalf("could start for gRPC server")
 		return err
@@ -0,0 +1,117 @@
+package audit
+
+import (
+	"context"
+	"github.com/bhatti/PlexAuthZ/internal/repository"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+type versioned interface {
+	GetVersion() int64
+}
+
+// AuditedRepository decorates repository to record audit records of creates, updates and deletes,
+// where failures of audit records are logged without failing the changes.
+type AuditedRepository[T any] struct {
+	repository.Repository[T]
+	auditor    *Auditor
+	entityType string
+}
+
+// NewAuditedRepository constructor
+func NewAuditedRepository[T any](
+	delegate repository.Repository[T],
+	auditor *Auditor,
+	entityType string,
+) repository.Repository[T] {
+	return &AuditedRepository[T]{
+		Repository: delegate,
+		auditor:    auditor,
+		entityType: entityType,
+	}
+}
+
+// Create - creates a new object and records the change.
+func (r *AuditedRepository[T]) Create(
+	ctx context.Context,
+	organizationID string,
+	namespace string,
+	id string,
+	obj *T,
+	expiration time.Duration,
+) error {
+	if err := r.Repository.Create(ctx, organizationID, namespace, id, obj, expiration); err != nil {
+		return err
+	}
+	r.record(ctx, CreateAction, organizationID, namespace, id, getVersion(obj), nil, obj)
+	return nil
+}
+
+// Update - saves existing object and records the change with the previous object.
+func (r *AuditedRepository[T]) Update(
+	ctx context.Context,
+	organizationID string,
+	namespace string,
+	id string,
+	version int64,
+	obj *T,
+	expiration time.Duration,
+) error {
+	before, _ := r.Repository.GetByID(ctx, organizationID, namespace, id)
+	if err := r.Repository.Update(ctx, organizationID, namespace, id, version, obj, expiration); err != nil {
+		return err
+	}
+	r.record(ctx, UpdateAction, organizationID, namespace, id, getVersion(obj), before, obj)
+	return nil
+}
+
+// Delete - removes the object and records the change with the deleted object.
+func (r *AuditedRepository[T]) Delete(
+	ctx context.Context,
+	organizationID string,
+	namespace string,
+	id string,
+) error {
+	before, _ := r.Repository.GetByID(ctx, organizationID, namespace, id)
+	if err := r.Repository.Delete(ctx, organizationID, namespace, id); err != nil {
+		return err
+	}
+	r.record(ctx, DeleteAction, organizationID, namespace, id, getVersion(before), before, nil)
+	return nil
+}
+
+func (r *AuditedRepository[T]) record(
+	ctx context.Context,
+	action string,
+	organizationID string,
+	namespace string,
+	id string,
+	version int64,
+	before *T,
+	after *T,
+) {
+	if _, err := r.auditor.Record(
+		ctx, action, r.entityType, organizationID, namespace, id, version, before, after); err != nil {
+		r.auditor.metricsRegistry.Incr("audit_errors", "entity", r.entityType)
+		log.WithFields(log.Fields{
+			"Component":    "AuditedRepository",
+			"Organization": organizationID,
+			"Namespace":    namespace,
+			"Entity":       r.entityType,
+			"Id":           id,
+			"Action":       action,
+			"Error":        err,
+		}).Warnf("failed to record audit")
+	}
+}
+
+func getVersion[T any](obj *T) int64 {
+	if obj == nil {
+		return 0
+	}
+	if v, ok := any(obj).(versioned); ok {
+		return v.GetVersion()
+	}
+	return 0
+}
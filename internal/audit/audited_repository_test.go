@@ -0,0 +1,60 @@
+package audit
+
+import (
+	"context"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/metrics"
+	"github.com/bhatti/PlexAuthZ/internal/repository"
+	"github.com/bhatti/PlexAuthZ/internal/repository/redis"
+	"github.com/stretchr/testify/require"
+	"github.com/twinj/uuid"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"testing"
+	"time"
+)
+
+func Test_ShouldAuditChangesOfPrincipals(t *testing.T) {
+	// GIVEN audited principal repository
+	ctx := domain.WithSubject(context.TODO(), "admin-user")
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	store, err := redis.NewRedisStore(cfg)
+	require.NoError(t, err)
+	orgRepository, err := repository.NewOrganizationRepository(store)
+	require.NoError(t, err)
+	principalRepository, err := repository.NewPrincipalRepository(store)
+	require.NoError(t, err)
+	auditor, err := NewAuditor(domain.AuditConfig{}, store, orgRepository, metrics.New())
+	require.NoError(t, err)
+	repo := NewAuditedRepository[types.Principal](principalRepository, auditor, "Principal")
+	orgId := uuid.NewV4().String()
+	require.NoError(t, orgRepository.Create(ctx, orgId, "", orgId,
+		&types.Organization{Id: orgId, Name: "org", AuditRetention: durationpb.New(time.Hour)}, 0))
+	principal := &types.Principal{Id: uuid.NewV4().String(), Username: "alice", Version: 1}
+
+	// WHEN creating principal, adding group and deleting principal
+	require.NoError(t, repo.Create(ctx, orgId, "ns", principal.Id, principal, 0))
+	updated := &types.Principal{Id: principal.Id, Username: "alice", Version: 2,
+		GroupIds: []string{"g1"}}
+	require.NoError(t, repo.Update(ctx, orgId, "ns", principal.Id, 1, updated, 0))
+	require.NoError(t, repo.Delete(ctx, orgId, "ns", principal.Id))
+
+	// THEN changes should be recorded with caller and versions
+	records, err := auditor.Query(ctx, orgId, "ns", "Principal", principal.Id, "", "", nil, 0)
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	require.Equal(t, CreateAction, records[0].Action)
+	require.Equal(t, AddMembershipAction, records[1].Action)
+	require.Equal(t, int64(2), records[1].Version)
+	require.Equal(t, `{"group_ids":{"after":["g1"]}}`, records[1].Diff)
+	require.Equal(t, DeleteAction, records[2].Action)
+	for _, record := range records {
+		require.Equal(t, "admin-user", record.Caller)
+	}
+
+	// AND retention of organization should be used for audit records
+	require.Equal(t, time.Hour, auditor.retention(ctx, orgId))
+	require.Equal(t, 90*24*time.Hour, auditor.retention(ctx, uuid.NewV4().String()))
+	require.NoError(t, store.ClearTable("AuditRecord", "", orgId, ""))
+}
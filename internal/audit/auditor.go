@@ -0,0 +1,158 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/metrics"
+	"github.com/bhatti/PlexAuthZ/internal/repository"
+	"github.com/twinj/uuid"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"sort"
+	"time"
+)
+
+// Auditor records append-only audit records of administrative changes, which are stored
+// per organization and expire after retention of the organization or the configured retention.
+type Auditor struct {
+	config          domain.AuditConfig
+	repository      repository.Repository[types.AuditRecord]
+	orgRepository   repository.Repository[types.Organization]
+	metricsRegistry *metrics.Registry
+}
+
+// NewAuditor constructor
+func NewAuditor(
+	config domain.AuditConfig,
+	store repository.DataStore,
+	orgRepository repository.Repository[types.Organization],
+	metricsRegistry *metrics.Registry,
+) (*Auditor, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	repo, err := NewAuditRepository(store)
+	if err != nil {
+		return nil, err
+	}
+	return &Auditor{
+		config:          config,
+		repository:      repo,
+		orgRepository:   orgRepository,
+		metricsRegistry: metricsRegistry,
+	}, nil
+}
+
+// NewAuditRepository creates repository for persisting audit records
+func NewAuditRepository(store repository.DataStore) (repository.Repository[types.AuditRecord], error) {
+	return repository.NewBaseRepository[types.AuditRecord](store,
+		"AuditRecord",
+		"",
+		time.Duration(0),
+		func() *types.AuditRecord {
+			return &types.AuditRecord{}
+		})
+}
+
+// Record stores audit record of the change with diff of before and after objects and caller
+// from the authenticated subject of the context.
+func (a *Auditor) Record(
+	ctx context.Context,
+	action string,
+	entityType string,
+	organizationId string,
+	namespace string,
+	entityId string,
+	version int64,
+	before any,
+	after any,
+) (*types.AuditRecord, error) {
+	changes, err := Diff(before, after)
+	if err != nil {
+		return nil, err
+	}
+	if action == UpdateAction {
+		action = ClassifyUpdate(changes)
+	}
+	diff, err := json.Marshal(changes)
+	if err != nil {
+		return nil, err
+	}
+	record := &types.AuditRecord{
+		Id:             uuid.NewV4().String(),
+		OrganizationId: organizationId,
+		Namespace:      namespace,
+		EntityType:     entityType,
+		EntityId:       entityId,
+		Action:         action,
+		Version:        version,
+		Caller:         domain.Subject(ctx),
+		Diff:           string(diff),
+		Created:        timestamppb.Now(),
+	}
+	if err = a.repository.Create(
+		ctx,
+		organizationId,
+		"",
+		record.Id,
+		record,
+		a.retention(ctx, organizationId),
+	); err != nil {
+		return nil, err
+	}
+	a.metricsRegistry.Incr("audit_recorded", "action", action)
+	return record, nil
+}
+
+// Query finds audit records of organization matching namespace, entity type, entity id, caller,
+// action and predicates if defined, which are sorted by created date in ascending order. All
+// matching records are returned when limit is not positive.
+func (a *Auditor) Query(
+	ctx context.Context,
+	organizationId string,
+	namespace string,
+	entityType string,
+	entityId string,
+	caller string,
+	action string,
+	predicates map[string]string,
+	limit int64,
+) ([]*types.AuditRecord, error) {
+	matching := make(map[string]string)
+	for k, v := range predicates {
+		matching[k] = v
+	}
+	for k, v := range map[string]string{
+		"namespace":   namespace,
+		"entity_type": entityType,
+		"entity_id":   entityId,
+		"caller":      caller,
+		"action":      action,
+	} {
+		if v != "" {
+			matching[k] = v
+		}
+	}
+	res, _, err := a.repository.Query(ctx, organizationId, "", matching, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Created.AsTime().Before(res[j].Created.AsTime())
+	})
+	if limit > 0 && int64(len(res)) > limit {
+		res = res[0:limit]
+	}
+	return res, nil
+}
+
+func (a *Auditor) retention(ctx context.Context, organizationId string) time.Duration {
+	if a.orgRepository != nil {
+		if org, err := a.orgRepository.GetByID(ctx, organizationId, "", organizationId); err == nil &&
+			org.AuditRetention != nil && org.AuditRetention.AsDuration() > 0 {
+			return org.AuditRetention.AsDuration()
+		}
+	}
+	return a.config.Retention
+}
@@ -0,0 +1,126 @@
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+const (
+	// CreateAction for created entities.
+	CreateAction = "CREATE"
+
+	// UpdateAction for updated entities.
+	UpdateAction = "UPDATE"
+
+	// DeleteAction for deleted entities.
+	DeleteAction = "DELETE"
+
+	// AddMembershipAction for updates that only add ids such as roles of a principal.
+	AddMembershipAction = "ADD_MEMBERSHIP"
+
+	// RemoveMembershipAction for updates that only remove ids such as groups of a principal.
+	RemoveMembershipAction = "REMOVE_MEMBERSHIP"
+
+	// membershipSuffix of fields that hold ids of other entities such as group_ids.
+	membershipSuffix = "_ids"
+)
+
+// ignoredFields are changed on every update and are recorded separately.
+var ignoredFields = map[string]bool{"version": true, "created": true, "updated": true}
+
+// Change of a field with values before and after the change.
+type Change struct {
+	Before any `json:"before,omitempty"`
+	After  any `json:"after,omitempty"`
+}
+
+// Diff compares JSON fields of before and after objects, where either can be nil for created
+// or deleted entities, and returns changed fields.
+func Diff(before any, after any) (map[string]*Change, error) {
+	beforeFields, err := toFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := toFields(after)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]*Change)
+	for k, v := range beforeFields {
+		if ignoredFields[k] {
+			continue
+		}
+		if !reflect.DeepEqual(v, afterFields[k]) {
+			res[k] = &Change{Before: v, After: afterFields[k]}
+		}
+	}
+	for k, v := range afterFields {
+		if ignoredFields[k] {
+			continue
+		}
+		if _, ok := beforeFields[k]; !ok {
+			res[k] = &Change{After: v}
+		}
+	}
+	return res, nil
+}
+
+// ClassifyUpdate returns AddMembershipAction or RemoveMembershipAction when changes only add or
+// only remove ids of membership fields, otherwise UpdateAction.
+func ClassifyUpdate(changes map[string]*Change) string {
+	if len(changes) == 0 {
+		return UpdateAction
+	}
+	added := false
+	removed := false
+	for k, change := range changes {
+		if !strings.HasSuffix(k, membershipSuffix) {
+			return UpdateAction
+		}
+		beforeIds := toSet(change.Before)
+		afterIds := toSet(change.After)
+		for id := range afterIds {
+			if !beforeIds[id] {
+				added = true
+			}
+		}
+		for id := range beforeIds {
+			if !afterIds[id] {
+				removed = true
+			}
+		}
+	}
+	if added && !removed {
+		return AddMembershipAction
+	}
+	if removed && !added {
+		return RemoveMembershipAction
+	}
+	return UpdateAction
+}
+
+func toFields(obj any) (map[string]any, error) {
+	if obj == nil || (reflect.ValueOf(obj).Kind() == reflect.Ptr && reflect.ValueOf(obj).IsNil()) {
+		return map[string]any{}, nil
+	}
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]any)
+	if err = json.Unmarshal(b, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func toSet(val any) map[any]bool {
+	res := make(map[any]bool)
+	if arr, ok := val.([]any); ok {
+		for _, v := range arr {
+			res[v] = true
+		}
+	}
+	return res
+}
@@ -0,0 +1,53 @@
+package audit
+
+import (
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_ShouldDiffChangedFields(t *testing.T) {
+	// GIVEN role before and after change
+	before := &types.Role{Id: "r1", Version: 1, Name: "reader", PermissionIds: []string{"p1"}}
+	after := &types.Role{Id: "r1", Version: 2, Name: "writer", PermissionIds: []string{"p1"}}
+
+	// WHEN comparing roles
+	changes, err := Diff(before, after)
+
+	// THEN only name should be changed
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	require.Equal(t, "reader", changes["name"].Before)
+	require.Equal(t, "writer", changes["name"].After)
+	require.Equal(t, UpdateAction, ClassifyUpdate(changes))
+
+	// AND all fields should be added for new role
+	changes, err = Diff(nil, after)
+	require.NoError(t, err)
+	require.Nil(t, changes["id"].Before)
+	require.Equal(t, "r1", changes["id"].After)
+	require.Nil(t, changes["version"])
+}
+
+func Test_ShouldClassifyMembershipChanges(t *testing.T) {
+	// GIVEN principal with groups
+	before := &types.Principal{Id: "u1", GroupIds: []string{"g1"}}
+
+	// WHEN adding groups and roles
+	changes, err := Diff(before, &types.Principal{Id: "u1", GroupIds: []string{"g1", "g2"}, RoleIds: []string{"r1"}})
+	require.NoError(t, err)
+	// THEN it should be classified as adding membership
+	require.Equal(t, AddMembershipAction, ClassifyUpdate(changes))
+
+	// WHEN removing groups
+	changes, err = Diff(before, &types.Principal{Id: "u1"})
+	require.NoError(t, err)
+	// THEN it should be classified as removing membership
+	require.Equal(t, RemoveMembershipAction, ClassifyUpdate(changes))
+
+	// WHEN replacing groups
+	changes, err = Diff(before, &types.Principal{Id: "u1", GroupIds: []string{"g2"}})
+	require.NoError(t, err)
+	// THEN it should be classified as update
+	require.Equal(t, UpdateAction, ClassifyUpdate(changes))
+}
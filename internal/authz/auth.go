@@ -15,6 +15,9 @@ const (
 	// CasbinAuthorizerKind based on Casbin implementation.
 	CasbinAuthorizerKind AuthorizerKind = "CASBIN"
 
+	// SystemAuthorizerKind based on principals, roles and permissions of the system organization.
+	SystemAuthorizerKind AuthorizerKind = "SYSTEM"
+
 	// NullAuthorizerKind based on NULL implementation.
 	NullAuthorizerKind AuthorizerKind = "NULL"
 
@@ -196,6 +196,67 @@ func Test_PermissionsForDepositAccountForDefaultAuthorizer(t *testing.T) {
 	}
 }
 
+func Test_ConsistencyTokenForDefaultAuthorizer(t *testing.T) {
+	// GIVEN auth-authService, organization and principal with a role without permissions
+	ctx := context.TODO()
+	authService, cfg, err := newAuthService()
+	require.NoError(t, err)
+	org, err := domain.NewOrganizationBuilder().
+		WithId("test-org-" + uuid.NewV4().String()).
+		WithName("org-name").
+		WithNamespaces("finance").Build()
+	require.NoError(t, err)
+	org, err = authService.CreateOrganization(ctx, org)
+	require.NoError(t, err)
+	alice, err := domain.NewPrincipalBuilder().
+		WithOrganizationId(org.Id).
+		WithNamespaces(org.Namespaces...).
+		WithUsername("alice").Build()
+	require.NoError(t, err)
+	alice, err = authService.CreatePrincipal(ctx, alice)
+	require.NoError(t, err)
+	report, err := domain.NewResourceBuilder().
+		WithNamespace(org.Namespaces[0]).
+		WithName("Report").
+		WithAllowedActions("read").Build()
+	require.NoError(t, err)
+	report, err = authService.CreateResource(ctx, org.Id, report)
+	require.NoError(t, err)
+	reader := assertCreateRole(t, authService, org, "Reader")
+	require.NoError(t, authService.AddRolesToPrincipal(ctx, org.Id, org.Namespaces[0], alice.Id, reader.Id))
+	authorizer, err := CreateAuthorizer(DefaultAuthorizerKind, cfg, authService)
+	require.NoError(t, err)
+	req := &services.AuthRequest{
+		OrganizationId: org.Id,
+		Namespace:      org.Namespaces[0],
+		PrincipalId:    alice.Id,
+		Action:         "read",
+		Resource:       "Report",
+	}
+	_, err = authorizer.Authorize(ctx, req)
+	require.Error(t, err) // cached principal without permissions
+
+	// WHEN granting permission to the role
+	perm := assertCreatePermission(t, report, authService, org, "", "read")
+	require.NoError(t, authService.AddPermissionsToRole(ctx, org.Id, org.Namespaces[0], reader.Id, perm.Id))
+	token := domain.NewConsistencyToken()
+
+	// THEN authorization with token of the change should evaluate the updated principal
+	req.ConsistencyToken = token
+	res, err := authorizer.Authorize(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, types.Effect_PERMITTED, res.Effect)
+	evaluated, err := domain.ParseConsistencyToken(res.ConsistencyToken)
+	require.NoError(t, err)
+	revision, _ := domain.ParseConsistencyToken(token)
+	require.Greater(t, evaluated, revision)
+
+	// AND invalid token should fail
+	req.ConsistencyToken = "bad-token"
+	_, err = authorizer.Authorize(ctx, req)
+	require.Error(t, err)
+}
+
 func assertCreateRole(
 	t *testing.T,
 	authAdminService service.AuthAdminService,
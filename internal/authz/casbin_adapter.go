@@ -0,0 +1,245 @@
+package authz
+
+import (
+	"context"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/repository"
+	"github.com/casbin/casbin/model"
+	"github.com/casbin/casbin/persist"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const casbinPolicyRevision = "casbin_policy_revision"
+
+// CasbinAdapter stores casbin policy lines in the data store.
+type CasbinAdapter struct {
+	tenant           string
+	policyRepository repository.Repository[domain.CasbinPolicy]
+	hashRepository   repository.Repository[domain.HashIndex]
+}
+
+// NewCasbinAdapter constructor
+func NewCasbinAdapter(
+	store repository.DataStore,
+	tenant string,
+) (*CasbinAdapter, error) {
+	policyRepository, err := repository.NewCasbinPolicyRepository(store)
+	if err != nil {
+		return nil, err
+	}
+	hashRepository, err := repository.NewHashIndexRepository(store)
+	if err != nil {
+		return nil, err
+	}
+	return &CasbinAdapter{
+		tenant:           tenant,
+		policyRepository: policyRepository,
+		hashRepository:   hashRepository,
+	}, nil
+}
+
+// Policies returns policy lines, optionally filtered by policy type.
+func (a *CasbinAdapter) Policies(
+	ctx context.Context,
+	ptype string,
+) ([]*domain.CasbinPolicy, error) {
+	predicate := make(map[string]string)
+	if ptype != "" {
+		predicate["ptype"] = ptype
+	}
+	res, _, err := a.policyRepository.Query(ctx, a.tenant, "", predicate, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].String() < res[j].String()
+	})
+	return res, nil
+}
+
+// Revision returns timestamp of the latest change of policies.
+func (a *CasbinAdapter) Revision(ctx context.Context) int64 {
+	index, err := a.hashRepository.GetByID(ctx, a.tenant, "", casbinPolicyRevision)
+	if err != nil || index.Updated == nil {
+		return 0
+	}
+	return index.Updated.AsTime().UnixNano()
+}
+
+// Add stores the policy line.
+func (a *CasbinAdapter) Add(
+	ctx context.Context,
+	ptype string,
+	rule []string,
+) (*domain.CasbinPolicy, error) {
+	policy := domain.NewCasbinPolicy(ptype, rule)
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	if err := a.policyRepository.Create(
+		ctx, a.tenant, "", policy.Id, policy, time.Duration(0)); err != nil {
+		return nil, err
+	}
+	return policy, a.updateRevision(ctx, policy.Id)
+}
+
+// Remove deletes the policy line.
+func (a *CasbinAdapter) Remove(
+	ctx context.Context,
+	ptype string,
+	rule []string,
+) error {
+	policy := domain.NewCasbinPolicy(ptype, rule)
+	if _, err := a.policyRepository.GetByID(ctx, a.tenant, "", policy.Id); err != nil {
+		return err
+	}
+	if err := a.policyRepository.Delete(ctx, a.tenant, "", policy.Id); err != nil {
+		return err
+	}
+	return a.updateRevision(ctx, policy.Id)
+}
+
+// Bootstrap imports policy lines of the file when the data store doesn't have any policies.
+func (a *CasbinAdapter) Bootstrap(
+	ctx context.Context,
+	policyFile string,
+) error {
+	existing, err := a.Policies(ctx, "")
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 {
+		return nil
+	}
+	b, err := os.ReadFile(policyFile)
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tokens := strings.Split(line, ",")
+		for i := range tokens {
+			tokens[i] = strings.TrimSpace(tokens[i])
+		}
+		if _, err = a.Add(ctx, tokens[0], tokens[1:]); err != nil {
+			return err
+		}
+	}
+	log.WithFields(log.Fields{
+		"Component": "CasbinAdapter",
+		"File":      policyFile,
+		"Tenant":    a.tenant,
+	}).Infof("imported casbin policies")
+	return nil
+}
+
+// LoadPolicy loads all policy rules from the data store.
+func (a *CasbinAdapter) LoadPolicy(m model.Model) error {
+	policies, err := a.Policies(context.Background(), "")
+	if err != nil {
+		return err
+	}
+	for _, policy := range policies {
+		sec := policy.PType[:1]
+		if m[sec] == nil || m[sec][policy.PType] == nil {
+			log.WithFields(log.Fields{
+				"Component": "CasbinAdapter",
+				"Policy":    policy.String(),
+			}).Warnf("skipping policy that is not defined in the model")
+			continue
+		}
+		persist.LoadPolicyLine(policy.String(), m)
+	}
+	return nil
+}
+
+// SavePolicy replaces all policy rules in the data store.
+func (a *CasbinAdapter) SavePolicy(m model.Model) error {
+	ctx := context.Background()
+	existing, err := a.Policies(ctx, "")
+	if err != nil {
+		return err
+	}
+	for _, policy := range existing {
+		if err = a.policyRepository.Delete(ctx, a.tenant, "", policy.Id); err != nil {
+			return err
+		}
+	}
+	for _, sec := range []string{"p", "g"} {
+		for ptype, ast := range m[sec] {
+			for _, rule := range ast.Policy {
+				policy := domain.NewCasbinPolicy(ptype, rule)
+				if err = a.policyRepository.Create(
+					ctx, a.tenant, "", policy.Id, policy, time.Duration(0)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return a.updateRevision(ctx, "")
+}
+
+// AddPolicy adds a policy rule to the data store.
+func (a *CasbinAdapter) AddPolicy(_ string, ptype string, rule []string) error {
+	_, err := a.Add(context.Background(), ptype, rule)
+	return err
+}
+
+// RemovePolicy removes a policy rule from the data store.
+func (a *CasbinAdapter) RemovePolicy(_ string, ptype string, rule []string) error {
+	return a.Remove(context.Background(), ptype, rule)
+}
+
+// RemoveFilteredPolicy removes policy rules that match the filter from the data store.
+func (a *CasbinAdapter) RemoveFilteredPolicy(_ string, ptype string, fieldIndex int, fieldValues ...string) error {
+	ctx := context.Background()
+	policies, err := a.Policies(ctx, ptype)
+	if err != nil {
+		return err
+	}
+	removed := 0
+	for _, policy := range policies {
+		if !matchesCasbinFilter(policy.Rule, fieldIndex, fieldValues...) {
+			continue
+		}
+		if err = a.policyRepository.Delete(ctx, a.tenant, "", policy.Id); err != nil {
+			return err
+		}
+		removed++
+	}
+	if removed == 0 {
+		return nil
+	}
+	return a.updateRevision(ctx, "")
+}
+
+func (a *CasbinAdapter) updateRevision(ctx context.Context, id string) error {
+	return a.hashRepository.Update(
+		ctx,
+		a.tenant,
+		"", // no namespace
+		casbinPolicyRevision,
+		-1, // no version
+		domain.NewHashIndex(casbinPolicyRevision, []string{id}),
+		time.Duration(0),
+	)
+}
+
+func matchesCasbinFilter(rule []string, fieldIndex int, fieldValues ...string) bool {
+	for i, v := range fieldValues {
+		if v == "" {
+			continue
+		}
+		if fieldIndex+i >= len(rule) || rule[fieldIndex+i] != v {
+			return false
+		}
+	}
+	return true
+}
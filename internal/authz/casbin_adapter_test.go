@@ -0,0 +1,158 @@
+package authz
+
+import (
+	"context"
+	"github.com/bhatti/PlexAuthZ/api/v1/services"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/repository/redis"
+	"github.com/stretchr/testify/require"
+	"github.com/twinj/uuid"
+	"testing"
+	"time"
+)
+
+func Test_ShouldManageCasbinPoliciesInDataStore(t *testing.T) {
+	// GIVEN two authorizers sharing policies in the data store
+	ctx := context.TODO()
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	cfg.Dir = "../../config"
+	cfg.CasbinPolicy.Store = domain.DataStoreCasbinPolicyStore
+	cfg.CasbinPolicy.Tenant = "casbin-" + uuid.NewV4().String()
+	cfg.CasbinPolicy.ReloadInterval = 50 * time.Millisecond
+	store, err := redis.NewRedisStore(cfg)
+	require.NoError(t, err)
+	first, err := NewGrpcAuthWithDataStore(cfg, store)
+	require.NoError(t, err)
+	defer func() {
+		_ = first.(domain.Closeable).Close()
+	}()
+	second, err := CreateAuthorizer(CasbinAuthorizerKind, cfg, nil)
+	require.NoError(t, err)
+	defer func() {
+		_ = second.(domain.Closeable).Close()
+	}()
+	updateReq := &services.AuthRequest{PrincipalId: "client", Resource: "*", Action: "update"}
+
+	// WHEN authorizing with policies imported from policy file
+	_, err = first.Authorize(ctx, &services.AuthRequest{PrincipalId: "root", Resource: "*", Action: "update"})
+	// THEN it should succeed
+	require.NoError(t, err)
+	_, err = first.Authorize(ctx, updateReq)
+	require.Error(t, err)
+
+	// WHEN adding policy
+	manager := first.(PolicyManager)
+	policy, err := manager.AddPolicy(ctx, "p", []string{"client", "*", "update"})
+	require.NoError(t, err)
+	require.NotEmpty(t, policy.Id)
+	policies, err := manager.GetPolicies(ctx, "p")
+	require.NoError(t, err)
+	require.Equal(t, 8, len(policies))
+
+	// THEN both authorizers should permit the request
+	_, err = first.Authorize(ctx, updateReq)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		_, err := second.Authorize(ctx, updateReq)
+		return err == nil
+	}, 5*time.Second, 20*time.Millisecond)
+
+	// WHEN removing policy
+	require.NoError(t, second.(PolicyManager).RemovePolicy(ctx, "p", []string{"client", "*", "update"}))
+	// THEN both authorizers should deny the request
+	_, err = second.Authorize(ctx, updateReq)
+	require.Error(t, err)
+	require.Eventually(t, func() bool {
+		_, err := first.Authorize(ctx, updateReq)
+		return err != nil
+	}, 5*time.Second, 20*time.Millisecond)
+
+	// WHEN adding invalid policy or removing missing policy
+	_, err = manager.AddPolicy(ctx, "p", []string{"client", "a,b", "update"})
+	// THEN it should fail
+	require.Error(t, err)
+	require.Error(t, manager.RemovePolicy(ctx, "p", []string{"client", "*", "update"}))
+}
+
+func Test_ShouldShareCasbinAuthorizerWithDataStore(t *testing.T) {
+	// GIVEN config with casbin policies in the data store
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	cfg.Dir = "../../config"
+	cfg.CasbinPolicy.Store = domain.DataStoreCasbinPolicyStore
+	cfg.CasbinPolicy.Tenant = "casbin-" + uuid.NewV4().String()
+
+	// WHEN creating authorizers for the same config
+	first, err := CreateAuthorizer(CasbinAuthorizerKind, cfg, nil)
+	require.NoError(t, err)
+	second, err := CreateAuthorizer(CasbinAuthorizerKind, cfg, nil)
+	require.NoError(t, err)
+	// THEN the data store and watch of policies should be shared
+	require.Same(t, first, second)
+
+	// WHEN closing one of the authorizers
+	require.NoError(t, first.(domain.Closeable).Close())
+	// THEN the other should keep watching policies
+	_, err = second.Authorize(context.TODO(),
+		&services.AuthRequest{PrincipalId: "root", Resource: "*", Action: "update"})
+	require.NoError(t, err)
+	select {
+	case <-second.(*authorizer).done:
+		require.Fail(t, "authorizer should not be stopped")
+	default:
+	}
+
+	// WHEN closing each authorizer
+	require.NoError(t, second.(domain.Closeable).Close())
+	// THEN it should be stopped and a new authorizer should be created
+	<-second.(*authorizer).done
+	third, err := CreateAuthorizer(CasbinAuthorizerKind, cfg, nil)
+	require.NoError(t, err)
+	defer func() {
+		_ = third.(domain.Closeable).Close()
+	}()
+	require.NotSame(t, second, third)
+}
+
+func Test_ShouldCheckConstraintsForCasbinAuthorizer(t *testing.T) {
+	// GIVEN casbin authorizer
+	ctx := context.TODO()
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	cfg.Dir = "../../config"
+	authorizer, err := NewGrpcAuth(cfg)
+	require.NoError(t, err)
+
+	// WHEN checking constraints that match subject and context
+	res, err := authorizer.Check(ctx, &services.CheckConstraintsRequest{
+		PrincipalId: "root",
+		Constraints: `and (eq .Principal.Id "root") (eq .Region "Midwest")`,
+		Context:     map[string]string{"Region": "Midwest"},
+	})
+	// THEN it should match
+	require.NoError(t, err)
+	require.True(t, res.Matched)
+
+	// WHEN checking constraints that don't match context
+	_, err = authorizer.Check(ctx, &services.CheckConstraintsRequest{
+		PrincipalId: "root",
+		Constraints: `eq .Region "Midwest"`,
+		Context:     map[string]string{"Region": "West"},
+	})
+	// THEN it should fail
+	require.Error(t, err)
+
+	// WHEN checking constraints for unknown subject
+	_, err = authorizer.Check(ctx, &services.CheckConstraintsRequest{
+		PrincipalId: "unknown",
+		Constraints: `eq 1 1`,
+	})
+	// THEN it should fail
+	require.Error(t, err)
+
+	// WHEN checking without constraints
+	_, err = authorizer.Check(ctx, &services.CheckConstraintsRequest{PrincipalId: "root"})
+	// THEN it should fail
+	require.Error(t, err)
+}
@@ -0,0 +1,41 @@
+package authz
+
+import (
+	"context"
+	"fmt"
+	"github.com/bhatti/PlexAuthZ/api/v1/services"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type scopeContextKey struct{}
+
+// Scope returns scope of the admin client from context, which is nil for clients without scope.
+func Scope(ctx context.Context) *domain.ClientScope {
+	v := ctx.Value(scopeContextKey{})
+	if v == nil {
+		return nil
+	}
+	return v.(*domain.ClientScope)
+}
+
+// WithScope stores scope of the admin client in context.
+func WithScope(ctx context.Context, scope *domain.ClientScope) context.Context {
+	if scope == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, scopeContextKey{}, scope)
+}
+
+// CheckScope verifies that organization and namespace of the request are within scope of the admin client.
+func CheckScope(ctx context.Context, req *services.AuthRequest) error {
+	scope := Scope(ctx)
+	if scope.Permits(req.OrganizationId, req.Namespace) {
+		return nil
+	}
+	return status.New(
+		codes.PermissionDenied,
+		fmt.Sprintf("%s not permitted to access organization '%s' and namespace '%s' outside of scope %s",
+			req.PrincipalId, req.OrganizationId, req.Namespace, scope)).Err()
+}
@@ -0,0 +1,112 @@
+package authz
+
+import (
+	"context"
+	"github.com/bhatti/PlexAuthZ/api/v1/services"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/decisionlog"
+	"github.com/twinj/uuid"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"time"
+)
+
+// DecisionLogAuthorizer records decisions of the delegate authorizer in the decision log.
+type DecisionLogAuthorizer struct {
+	delegate Authorizer
+	logger   *decisionlog.Logger
+}
+
+// NewDecisionLogAuthorizer constructor
+func NewDecisionLogAuthorizer(
+	delegate Authorizer,
+	logger *decisionlog.Logger,
+) *DecisionLogAuthorizer {
+	return &DecisionLogAuthorizer{
+		delegate: delegate,
+		logger:   logger,
+	}
+}
+
+// Authorize checks access with delegate and records the decision.
+func (a *DecisionLogAuthorizer) Authorize(
+	ctx context.Context,
+	req *services.AuthRequest,
+) (*services.AuthResponse, error) {
+	started := time.Now()
+	res, err := a.delegate.Authorize(ctx, req)
+	LogAuthorizeDecision(ctx, a.logger, started, req, res, err)
+	return res, err
+}
+
+// Check inspects constraints with delegate and records the decision.
+func (a *DecisionLogAuthorizer) Check(
+	ctx context.Context,
+	req *services.CheckConstraintsRequest,
+) (*services.CheckConstraintsResponse, error) {
+	started := time.Now()
+	res, err := a.delegate.Check(ctx, req)
+	decision := newDecision(ctx, decisionlog.CheckDecisionKind, started, err)
+	decision.OrganizationId = req.OrganizationId
+	decision.Namespace = req.Namespace
+	decision.PrincipalId = req.PrincipalId
+	decision.Context = req.Context
+	if err == nil && res != nil && !res.Matched {
+		decision.Effect = types.Effect_DENIED
+	}
+	a.logger.Log(decision)
+	return res, err
+}
+
+// LogAuthorizeDecision records decision of the authorization request, which is used by
+// handlers that authorize without an Authorizer.
+func LogAuthorizeDecision(
+	ctx context.Context,
+	logger *decisionlog.Logger,
+	started time.Time,
+	req *services.AuthRequest,
+	res *services.AuthResponse,
+	err error,
+) {
+	decision := newDecision(ctx, decisionlog.AuthorizeDecisionKind, started, err)
+	decision.OrganizationId = req.OrganizationId
+	decision.Namespace = req.Namespace
+	decision.PrincipalId = req.PrincipalId
+	decision.Resource = req.Resource
+	decision.Action = req.Action
+	decision.Scope = req.Scope
+	decision.Context = req.Context
+	if res != nil {
+		decision.PermissionIds = res.PermissionIds
+		if err == nil {
+			decision.Effect = res.Effect
+			decision.Message = res.Message
+		}
+	}
+	logger.Log(decision)
+}
+
+// Logger returns decision logger.
+func (a *DecisionLogAuthorizer) Logger() *decisionlog.Logger {
+	return a.logger
+}
+
+func newDecision(
+	ctx context.Context,
+	kind string,
+	started time.Time,
+	err error,
+) *types.Decision {
+	decision := &types.Decision{
+		Id:            uuid.NewV4().String(),
+		Kind:          kind,
+		Effect:        types.Effect_PERMITTED,
+		LatencyMicros: time.Since(started).Microseconds(),
+		Caller:        Subject(ctx),
+		Created:       timestamppb.New(started),
+	}
+	if err != nil {
+		decision.Effect = types.Effect_DENIED
+		decision.Message = err.Error()
+	}
+	return decision
+}
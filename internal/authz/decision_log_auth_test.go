@@ -0,0 +1,60 @@
+package authz
+
+import (
+	"context"
+	"github.com/bhatti/PlexAuthZ/api/v1/services"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/decisionlog"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/metrics"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_ShouldRecordDecisionsOfAuthorizer(t *testing.T) {
+	// GIVEN decision loggers of authorizers that permit and reject all requests
+	ctx := WithSubject(context.TODO(), "envoy")
+	logger, err := decisionlog.NewLogger(
+		domain.DecisionLogConfig{}, metrics.New(), decisionlog.NewRecentSink(10))
+	require.NoError(t, err)
+	permitted := NewDecisionLogAuthorizer(NullAuthorizer{}, logger)
+	denied := NewDecisionLogAuthorizer(NoAuthorizer{}, logger)
+	req := &services.AuthRequest{
+		OrganizationId: "org",
+		Namespace:      "ns",
+		PrincipalId:    "alice",
+		Resource:       "file",
+		Action:         "read",
+		Context:        map[string]string{"ip": "10.0.0.1"},
+	}
+
+	// WHEN authorizing and checking requests
+	_, err = permitted.Authorize(ctx, req)
+	require.NoError(t, err)
+	_, err = denied.Authorize(ctx, req)
+	require.Error(t, err)
+	_, err = denied.Check(ctx, &services.CheckConstraintsRequest{
+		OrganizationId: "org", Namespace: "ns", PrincipalId: "alice", Constraints: "true"})
+	require.Error(t, err)
+	require.NoError(t, logger.Close())
+
+	// THEN all decisions should be recorded with caller and effect
+	res, _, err := logger.Query(context.TODO(), "org", "ns", "alice", "file", nil, "", 0)
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+	effects := make(map[types.Effect]int)
+	for _, decision := range res {
+		require.Equal(t, decisionlog.AuthorizeDecisionKind, decision.Kind)
+		require.Equal(t, "envoy", decision.Caller)
+		require.Equal(t, "read", decision.Action)
+		require.Equal(t, decisionlog.HashContext(req.Context), decision.ContextHash)
+		effects[decision.Effect]++
+	}
+	require.Equal(t, 1, effects[types.Effect_PERMITTED])
+	require.Equal(t, 1, effects[types.Effect_DENIED])
+	res, _, err = logger.Query(context.TODO(), "org", "ns", "", "", map[string]string{"kind": "CHECK"}, "", 0)
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	require.Equal(t, types.Effect_DENIED, res[0].Effect)
+	require.NotEmpty(t, res[0].Message)
+}
@@ -28,6 +28,10 @@ func (a *DefaultAuthorizer) Authorize(
 	ctx context.Context,
 	req *services.AuthRequest,
 ) (*services.AuthResponse, error) {
+	ctx, err := domain.WithConsistencyToken(ctx, req.ConsistencyToken)
+	if err != nil {
+		return nil, err
+	}
 	principal, err := a.authAdminService.GetPrincipalExt(
 		ctx,
 		req.OrganizationId,
@@ -42,6 +46,7 @@ func (a *DefaultAuthorizer) Authorize(
 	if err != nil {
 		return nil, err
 	}
+	res.ConsistencyToken = domain.ConsistencyToken(principal.Revision)
 	return res, nil
 }
 
@@ -54,6 +59,10 @@ func (a *DefaultAuthorizer) Check(
 		return nil, domain.NewValidationError(
 			fmt.Sprintf("constraints is not defined"))
 	}
+	ctx, err := domain.WithConsistencyToken(ctx, req.ConsistencyToken)
+	if err != nil {
+		return nil, err
+	}
 	principal, err := a.authAdminService.GetPrincipalExt(
 		ctx,
 		req.OrganizationId,
@@ -81,7 +90,8 @@ func (a *DefaultAuthorizer) Check(
 
 	}
 	return &services.CheckConstraintsResponse{
-		Matched: matched,
-		Output:  output,
+		Matched:          matched,
+		Output:           output,
+		ConsistencyToken: domain.ConsistencyToken(principal.Revision),
 	}, nil
 }
@@ -2,10 +2,28 @@ package authz
 
 import (
 	"fmt"
+	"github.com/bhatti/PlexAuthZ/internal/decisionlog"
 	"github.com/bhatti/PlexAuthZ/internal/domain"
 	"github.com/bhatti/PlexAuthZ/internal/service"
+	"github.com/bhatti/PlexAuthZ/internal/service/db"
+	"sync"
 )
 
+var sharedCasbin = struct {
+	authorizers map[*domain.Config]*authorizer
+	refs        map[*domain.Config]int
+	lock        sync.Mutex
+}{authorizers: make(map[*domain.Config]*authorizer), refs: make(map[*domain.Config]int)}
+
+// AdminAuthorizerKind returns kind of authorizer for admin APIs, i.e., the system organization
+// when enabled or casbin policies otherwise.
+func AdminAuthorizerKind(config *domain.Config) AuthorizerKind {
+	if config.SystemAuth.Enabled {
+		return SystemAuthorizerKind
+	}
+	return CasbinAuthorizerKind
+}
+
 // CreateAuthorizer factory
 func CreateAuthorizer(
 	kind AuthorizerKind,
@@ -13,9 +31,21 @@ func CreateAuthorizer(
 	authService service.AuthAdminService,
 ) (Authorizer, error) {
 	if kind == DefaultAuthorizerKind {
+		if config.DecisionLog.Enabled {
+			logger, err := decisionlog.Shared(config)
+			if err != nil {
+				return nil, err
+			}
+			return NewDecisionLogAuthorizer(NewDefaultAuthorizer(authService), logger), nil
+		}
 		return NewDefaultAuthorizer(authService), nil
 	} else if kind == CasbinAuthorizerKind {
+		if config.CasbinPolicy.Store == domain.DataStoreCasbinPolicyStore {
+			return sharedCasbinAuthorizer(config)
+		}
 		return NewGrpcAuth(config)
+	} else if kind == SystemAuthorizerKind {
+		return NewSystemAuthorizer(config, authService)
 	} else if kind == NullAuthorizerKind {
 		return NullAuthorizer{}, nil
 	} else if kind == NoneAuthorizerKind {
@@ -24,3 +54,47 @@ func CreateAuthorizer(
 		return nil, fmt.Errorf("unknown kind %s", kind)
 	}
 }
+
+// sharedCasbinAuthorizer returns casbin authorizer of the config that stores policies in the data store,
+// which is created once so that gRPC servers and REST controllers of the process share the data store
+// and the watch of policy changes. Closing the authorizer releases it, and the data store is closed
+// and the watch is stopped when it's released by each caller.
+func sharedCasbinAuthorizer(config *domain.Config) (Authorizer, error) {
+	sharedCasbin.lock.Lock()
+	defer sharedCasbin.lock.Unlock()
+	if a := sharedCasbin.authorizers[config]; a != nil {
+		sharedCasbin.refs[config]++
+		return a, nil
+	}
+	store, err := db.CreateDataStore(config)
+	if err != nil {
+		return nil, err
+	}
+	a, err := newDataStoreAuthorizer(config, store)
+	if err != nil {
+		if closeable, ok := store.(domain.Closeable); ok {
+			_ = closeable.Close()
+		}
+		return nil, err
+	}
+	a.release = func() error {
+		sharedCasbin.lock.Lock()
+		defer sharedCasbin.lock.Unlock()
+		if sharedCasbin.authorizers[config] != a {
+			return nil
+		}
+		if sharedCasbin.refs[config]--; sharedCasbin.refs[config] > 0 {
+			return nil
+		}
+		delete(sharedCasbin.authorizers, config)
+		delete(sharedCasbin.refs, config)
+		_ = a.stop()
+		if closeable, ok := store.(domain.Closeable); ok {
+			return closeable.Close()
+		}
+		return nil
+	}
+	sharedCasbin.authorizers[config] = a
+	sharedCasbin.refs[config] = 1
+	return a, nil
+}
@@ -0,0 +1,169 @@
+package authz
+
+import (
+	"context"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/service"
+)
+
+// PrincipalWithGroupClaims returns copy of the principal that includes groups of external claims,
+// which are matched by name in the namespace, along with their parent groups, roles and permissions.
+// Unknown group names are ignored and the cached principal is not modified.
+func PrincipalWithGroupClaims(
+	ctx context.Context,
+	config *domain.Config,
+	authAdminService service.AuthAdminService,
+	principal *domain.PrincipalExt,
+	namespace string,
+	groupNames ...string,
+) (*domain.PrincipalExt, error) {
+	xPrincipal := copyPrincipalExt(principal)
+	organizationID := principal.Delegate.OrganizationId
+	var roleIDs []string
+	for _, name := range groupNames {
+		if xPrincipal.GroupsByName[name] != nil {
+			continue
+		}
+		groups, _, err := authAdminService.GetGroups(
+			ctx, organizationID, namespace, map[string]string{"name": name}, "", 1)
+		if err != nil {
+			return nil, err
+		}
+		for _, group := range groups {
+			ids, err := addClaimedGroup(ctx, config, authAdminService, xPrincipal, namespace, group, 0)
+			if err != nil {
+				return nil, err
+			}
+			roleIDs = append(roleIDs, ids...)
+		}
+	}
+	for _, roleID := range roleIDs {
+		if err := addClaimedRole(ctx, config, authAdminService, xPrincipal, namespace, roleID, 0); err != nil {
+			return nil, err
+		}
+	}
+	return xPrincipal, nil
+}
+
+// NewClaimsPrincipalExt creates a transient principal for users that are not registered
+// so that they can be authorized by their group claims.
+func NewClaimsPrincipalExt(
+	ctx context.Context,
+	authAdminService service.AuthAdminService,
+	organizationID string,
+	namespace string,
+	username string,
+) (*domain.PrincipalExt, error) {
+	org, err := authAdminService.GetOrganization(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	xPrincipal := domain.NewPrincipalExt(&types.Principal{
+		Id:             username,
+		OrganizationId: organizationID,
+		Namespaces:     []string{namespace},
+		Username:       username,
+	})
+	xPrincipal.Organization = org
+	return xPrincipal, nil
+}
+
+func addClaimedGroup(
+	ctx context.Context,
+	config *domain.Config,
+	authAdminService service.AuthAdminService,
+	xPrincipal *domain.PrincipalExt,
+	namespace string,
+	group *types.Group,
+	level int,
+) (roleIDs []string, err error) {
+	if level > config.MaxGroupRoleLevels || xPrincipal.GroupsByName[group.Name] != nil {
+		return
+	}
+	xPrincipal.GroupsByName[group.Name] = group
+	roleIDs = append(roleIDs, group.RoleIds...)
+	for _, parentID := range group.ParentIds {
+		parent, err := authAdminService.GetGroup(
+			ctx, xPrincipal.Delegate.OrganizationId, namespace, parentID)
+		if err != nil {
+			return nil, err
+		}
+		ids, err := addClaimedGroup(ctx, config, authAdminService, xPrincipal, namespace, parent, level+1)
+		if err != nil {
+			return nil, err
+		}
+		roleIDs = append(roleIDs, ids...)
+	}
+	return
+}
+
+func addClaimedRole(
+	ctx context.Context,
+	config *domain.Config,
+	authAdminService service.AuthAdminService,
+	xPrincipal *domain.PrincipalExt,
+	namespace string,
+	roleID string,
+	level int,
+) error {
+	if level > config.MaxGroupRoleLevels {
+		return nil
+	}
+	organizationID := xPrincipal.Delegate.OrganizationId
+	role, err := authAdminService.GetRole(ctx, organizationID, namespace, roleID)
+	if err != nil {
+		return err
+	}
+	if xPrincipal.RolesByName[role.Name] != nil {
+		return nil
+	}
+	xPrincipal.RolesByName[role.Name] = role
+	for _, permissionID := range role.PermissionIds {
+		perm, err := authAdminService.GetPermission(ctx, organizationID, namespace, permissionID)
+		if err != nil {
+			return err
+		}
+		if xPrincipal.ResourcesById[perm.ResourceId] == nil {
+			resource, err := authAdminService.GetResource(ctx, organizationID, namespace, perm.ResourceId)
+			if err != nil {
+				return err
+			}
+			xPrincipal.ResourcesById[resource.Id] = resource
+		}
+		if err = xPrincipal.AddPermission(perm); err != nil {
+			return err
+		}
+	}
+	for _, parentID := range role.ParentIds {
+		if err = addClaimedRole(ctx, config, authAdminService, xPrincipal, namespace, parentID, level+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyPrincipalExt(principal *domain.PrincipalExt) *domain.PrincipalExt {
+	xPrincipal := domain.NewPrincipalExt(principal.Delegate)
+	xPrincipal.Organization = principal.Organization
+	for k, v := range principal.GroupsByName {
+		xPrincipal.GroupsByName[k] = v
+	}
+	for k, v := range principal.RolesByName {
+		xPrincipal.RolesByName[k] = v
+	}
+	for k, v := range principal.RelationsById {
+		xPrincipal.RelationsById[k] = v
+	}
+	for k, v := range principal.ResourcesById {
+		xPrincipal.ResourcesById[k] = v
+	}
+	for name, perms := range principal.PermissionsByResourceName {
+		permsForResource := make(map[string]*types.Permission)
+		for k, v := range perms {
+			permsForResource[k] = v
+		}
+		xPrincipal.PermissionsByResourceName[name] = permsForResource
+	}
+	return xPrincipal
+}
@@ -6,19 +6,49 @@ import (
 	"github.com/bhatti/PlexAuthZ/api/v1/services"
 	"github.com/bhatti/PlexAuthZ/api/v1/types"
 	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/repository"
 	"github.com/casbin/casbin"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
-type authorizer struct {
-	enforcer *casbin.Enforcer
+// PolicyManager interface for managing casbin policies of admin APIs.
+type PolicyManager interface {
+	// AddPolicy adds policy line and reloads the enforcer.
+	AddPolicy(
+		ctx context.Context,
+		ptype string,
+		rule []string,
+	) (*domain.CasbinPolicy, error)
+
+	// RemovePolicy removes policy line and reloads the enforcer.
+	RemovePolicy(
+		ctx context.Context,
+		ptype string,
+		rule []string,
+	) error
+
+	// GetPolicies returns policy lines, optionally filtered by policy type.
+	GetPolicies(
+		ctx context.Context,
+		ptype string,
+	) ([]*domain.CasbinPolicy, error)
 }
 
-type subjectContextKey struct{}
+type authorizer struct {
+	enforcer  *casbin.SyncedEnforcer
+	adapter   *CasbinAdapter
+	revision  int64
+	done      chan struct{}
+	closeOnce sync.Once
+	release   func() error
+}
 
 // NewGrpcAuth constructor
 func NewGrpcAuth(config *domain.Config) (Authorizer, error) {
@@ -31,17 +61,58 @@ func NewGrpcAuth(config *domain.Config) (Authorizer, error) {
 		return nil, err
 	}
 	return &authorizer{
-		enforcer: casbin.NewEnforcer(aclFile, policyFile),
+		enforcer: casbin.NewSyncedEnforcer(aclFile, policyFile),
 	}, nil
 }
 
+// NewGrpcAuthWithDataStore constructor for casbin authorizer that stores policies in the data store,
+// which is bootstrapped from the policy file and reloaded when policies are changed by other servers.
+func NewGrpcAuthWithDataStore(
+	config *domain.Config,
+	store repository.DataStore,
+) (Authorizer, error) {
+	return newDataStoreAuthorizer(config, store)
+}
+
+func newDataStoreAuthorizer(
+	config *domain.Config,
+	store repository.DataStore,
+) (*authorizer, error) {
+	aclFile, err := config.ACLModelFile()
+	if err != nil {
+		return nil, err
+	}
+	policyFile, err := config.ACLPolicyFile()
+	if err != nil {
+		return nil, err
+	}
+	adapter, err := NewCasbinAdapter(store, config.CasbinPolicy.Tenant)
+	if err != nil {
+		return nil, err
+	}
+	if err = adapter.Bootstrap(context.Background(), policyFile); err != nil {
+		return nil, err
+	}
+	a := &authorizer{
+		enforcer: casbin.NewSyncedEnforcer(aclFile, adapter),
+		adapter:  adapter,
+		done:     make(chan struct{}),
+	}
+	if err = a.reload(context.Background()); err != nil {
+		return nil, err
+	}
+	go a.watchPolicies(config.CasbinPolicy.ReloadInterval)
+	return a, nil
+}
+
 // Subject returns subject from context
 func Subject(ctx context.Context) string {
-	v := ctx.Value(subjectContextKey{})
-	if v == nil {
-		return ""
-	}
-	return v.(string)
+	return domain.Subject(ctx)
+}
+
+// WithSubject returns context with subject of the client such as subject of REST requests.
+func WithSubject(ctx context.Context, subject string) context.Context {
+	return domain.WithSubject(ctx, subject)
 }
 
 // Authenticate checks access
@@ -62,19 +133,27 @@ func Authenticate(ctx context.Context) (context.Context, error) {
 			"ctx":  ctx,
 			"peer": peer,
 		}).Warn("authenticator couldn't find peer authz info")
-		return context.WithValue(ctx, subjectContextKey{}, ""), nil
+		return WithSubject(ctx, ""), nil
 	}
 
-	tlsInfo := peer.AuthInfo.(credentials.TLSInfo)
-	subject := tlsInfo.State.VerifiedChains[0][0].Subject.CommonName
-	return context.WithValue(ctx, subjectContextKey{}, subject), nil
+	tlsInfo, ok := peer.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		// client certificate is optional when bearer tokens are accepted
+		return WithSubject(ctx, ""), nil
+	}
+	cert := tlsInfo.State.VerifiedChains[0][0]
+	ctx = WithScope(ctx, domain.ParseClientScopeURIs(cert.URIs))
+	return WithSubject(ctx, cert.Subject.CommonName), nil
 }
 
 // Authorize checks authorization permission
 func (a *authorizer) Authorize(
-	_ context.Context,
+	ctx context.Context,
 	req *services.AuthRequest,
 ) (*services.AuthResponse, error) {
+	if err := CheckScope(ctx, req); err != nil {
+		return nil, err
+	}
 	valid := a.enforcer.Enforce(req.PrincipalId, req.Resource, req.Action)
 	if logrus.IsLevelEnabled(logrus.DebugLevel) {
 		logrus.WithFields(logrus.Fields{
@@ -95,10 +174,155 @@ func (a *authorizer) Authorize(
 	}, nil
 }
 
-// Check enforces constraints but not implemented
+// Check enforces constraints for the subject of casbin policies, where the constraints
+// can access the subject as .Principal.Id, its roles as .Principal.Roles and the context.
 func (a *authorizer) Check(
 	_ context.Context,
-	_ *services.CheckConstraintsRequest,
+	req *services.CheckConstraintsRequest,
 ) (*services.CheckConstraintsResponse, error) {
-	return nil, domain.NewInternalError("check method is not implemented for GRPC", domain.InternalCode)
+	if req.Constraints == "" {
+		return nil, domain.NewValidationError(
+			fmt.Sprintf("constraints is not defined"))
+	}
+	principal := domain.NewPrincipalExt(&types.Principal{
+		Id:             req.PrincipalId,
+		OrganizationId: req.OrganizationId,
+		Username:       req.PrincipalId,
+	})
+	roles := a.rolesForSubject(req.PrincipalId)
+	for _, role := range roles {
+		principal.RolesByName[role] = &types.Role{Name: role}
+	}
+	if len(roles) == 0 && len(a.enforcer.GetFilteredPolicy(0, req.PrincipalId)) == 0 {
+		return nil, status.New(
+			codes.PermissionDenied,
+			fmt.Sprintf("%s doesn't have any policies", req.PrincipalId)).Err()
+	}
+	authReq := &services.AuthRequest{
+		OrganizationId: req.OrganizationId,
+		Namespace:      req.Namespace,
+		PrincipalId:    req.PrincipalId,
+		Context:        req.Context,
+	}
+	matched, output, err := principal.CheckConstraints(authReq, &types.Resource{}, req.Constraints)
+	if err != nil {
+		return nil, err
+	}
+	if !matched {
+		return nil, domain.NewAuthError(
+			fmt.Sprintf("constraints '%s' not matched with context %v", req.Constraints, req.Context))
+	}
+	return &services.CheckConstraintsResponse{
+		Matched: matched,
+		Output:  output,
+	}, nil
+}
+
+// AddPolicy adds policy line and reloads the enforcer.
+func (a *authorizer) AddPolicy(
+	ctx context.Context,
+	ptype string,
+	rule []string,
+) (*domain.CasbinPolicy, error) {
+	if a.adapter == nil {
+		return nil, domain.NewValidationError("casbin policies are not stored in the data store")
+	}
+	policy, err := a.adapter.Add(ctx, ptype, rule)
+	if err != nil {
+		return nil, err
+	}
+	return policy, a.reload(ctx)
+}
+
+// RemovePolicy removes policy line and reloads the enforcer.
+func (a *authorizer) RemovePolicy(
+	ctx context.Context,
+	ptype string,
+	rule []string,
+) error {
+	if a.adapter == nil {
+		return domain.NewValidationError("casbin policies are not stored in the data store")
+	}
+	if err := a.adapter.Remove(ctx, ptype, rule); err != nil {
+		return err
+	}
+	return a.reload(ctx)
+}
+
+// GetPolicies returns policy lines, optionally filtered by policy type.
+func (a *authorizer) GetPolicies(
+	ctx context.Context,
+	ptype string,
+) ([]*domain.CasbinPolicy, error) {
+	if a.adapter == nil {
+		return nil, domain.NewValidationError("casbin policies are not stored in the data store")
+	}
+	return a.adapter.Policies(ctx, ptype)
+}
+
+// Close stops watching changes of policies.
+func (a *authorizer) Close() error {
+	if a.release != nil {
+		return a.release()
+	}
+	return a.stop()
+}
+
+// stop stops watching policy changes.
+func (a *authorizer) stop() error {
+	if a.done != nil {
+		a.closeOnce.Do(func() {
+			close(a.done)
+		})
+	}
+	return nil
+}
+
+func (a *authorizer) rolesForSubject(subject string) []string {
+	if a.enforcer.GetModel()["g"] == nil || a.enforcer.GetModel()["g"]["g"] == nil {
+		return nil
+	}
+	roles, _ := a.enforcer.GetRolesForUser(subject)
+	return roles
+}
+
+// reload loads policies from the data store and records revision of the loaded policies.
+func (a *authorizer) reload(ctx context.Context) error {
+	if a.adapter == nil {
+		return nil
+	}
+	revision := a.adapter.Revision(ctx)
+	if err := a.enforcer.LoadPolicy(); err != nil {
+		return err
+	}
+	atomic.StoreInt64(&a.revision, revision)
+	return nil
+}
+
+// watchPolicies reloads the enforcer when policies are changed by other servers.
+func (a *authorizer) watchPolicies(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.done:
+			return
+		case <-ticker.C:
+			ctx := context.Background()
+			if a.adapter.Revision(ctx) == atomic.LoadInt64(&a.revision) {
+				continue
+			}
+			if err := a.reload(ctx); err != nil {
+				logrus.WithFields(logrus.Fields{
+					"Component": "CasbinAuthorizer",
+					"Error":     err,
+				}).Warnf("failed to reload casbin policies")
+			} else {
+				logrus.WithFields(logrus.Fields{
+					"Component": "CasbinAuthorizer",
+					"Revision":  atomic.LoadInt64(&a.revision),
+				}).Infof("reloaded casbin policies")
+			}
+		}
+	}
 }
@@ -0,0 +1,440 @@
+package authz
+
+import (
+	"context"
+	"fmt"
+	"github.com/bhatti/PlexAuthZ/api/v1/services"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/service"
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/sirupsen/logrus"
+	"github.com/twinj/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
+	"time"
+)
+
+const (
+	systemResource   = "*"
+	systemAdminRole  = "admin"
+	systemClientRole = "client"
+
+	// bootstrapLeaseExpiry is renewed before each step so that another server only takes over
+	// bootstrapping after the holder stops.
+	bootstrapLeaseExpiry = 30 * time.Second
+	// bootstrapPollInterval for waiting on other servers that bootstrap the system organization.
+	bootstrapPollInterval = 100 * time.Millisecond
+)
+
+// systemActions defines actions of admin APIs.
+var systemActions = []string{"create", "update", "query", "delete", "auth"}
+
+// systemRoles defines actions of roles of the system organization.
+var systemRoles = map[string][]string{
+	systemAdminRole:  {"*"},
+	systemClientRole: {"query", "auth"},
+}
+
+// SystemAuthorizer authorizes admin APIs with principals, roles and permissions of the reserved
+// system organization, which are managed with the same APIs as other organizations. The subject
+// of admin client is matched with username of the principal in the system organization and the
+// organization and namespace of the request are available to constraints as .OrganizationId
+// and .Namespace.
+type SystemAuthorizer struct {
+	config           domain.SystemAuthConfig
+	authAdminService service.AuthAdminService
+	leaseService     service.LeaseService
+	delegate         Authorizer
+	organizationId   string
+	holder           string
+	principalIds     *expirable.LRU[string, string]
+}
+
+// NewSystemAuthorizer constructor, which bootstraps the system organization on first start. The
+// bootstrap is completed by one server at a time and the steps are repeated by another server if
+// the first one stops before marking it as completed.
+func NewSystemAuthorizer(
+	config *domain.Config,
+	authAdminService service.AuthAdminService,
+) (*SystemAuthorizer, error) {
+	leaseService, ok := authAdminService.(service.LeaseService)
+	if !ok {
+		return nil, domain.NewValidationError("system authorizer requires leases of the data store")
+	}
+	a := &SystemAuthorizer{
+		config:           config.SystemAuth,
+		authAdminService: authAdminService,
+		leaseService:     leaseService,
+		delegate:         NewDefaultAuthorizer(authAdminService),
+		holder:           uuid.NewV4().String(),
+		principalIds: expirable.NewLRU[string, string](
+			config.MaxCacheSize, nil, time.Duration(config.CacheExpirationMillis)*time.Millisecond),
+	}
+	org, err := a.bootstrap(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	a.organizationId = org.Id
+	return a, nil
+}
+
+// OrganizationId returns id of the system organization.
+func (a *SystemAuthorizer) OrganizationId() string {
+	return a.organizationId
+}
+
+// Authorize checks permissions of the admin client in the system organization.
+func (a *SystemAuthorizer) Authorize(
+	ctx context.Context,
+	req *services.AuthRequest,
+) (*services.AuthResponse, error) {
+	if err := CheckScope(ctx, req); err != nil {
+		return nil, err
+	}
+	principalId, err := a.principalId(ctx, req.PrincipalId)
+	if err != nil {
+		return nil, err
+	}
+	res, err := a.delegate.Authorize(ctx, &services.AuthRequest{
+		OrganizationId: a.organizationId,
+		Namespace:      a.config.Namespace,
+		PrincipalId:    principalId,
+		Resource:       req.Resource,
+		Action:         req.Action,
+		Scope:          req.Scope,
+		Context:        a.requestContext(req.OrganizationId, req.Namespace, req.Context),
+	})
+	if logrus.IsLevelEnabled(logrus.DebugLevel) {
+		logrus.WithFields(logrus.Fields{
+			"subject": req.PrincipalId,
+			"object":  req.Resource,
+			"action":  req.Action,
+			"error":   err,
+		}).Debugf("system authorizer authorizing")
+	}
+	if err != nil || res.Effect != types.Effect_PERMITTED {
+		return nil, status.New(
+			codes.PermissionDenied,
+			fmt.Sprintf("%s not permitted to %s to %s",
+				req.PrincipalId, req.Action, req.Resource)).Err()
+	}
+	return res, nil
+}
+
+// Check enforces constraints for the principal of the admin client in the system organization.
+func (a *SystemAuthorizer) Check(
+	ctx context.Context,
+	req *services.CheckConstraintsRequest,
+) (*services.CheckConstraintsResponse, error) {
+	principalId, err := a.principalId(ctx, req.PrincipalId)
+	if err != nil {
+		return nil, err
+	}
+	return a.delegate.Check(ctx, &services.CheckConstraintsRequest{
+		OrganizationId: a.organizationId,
+		Namespace:      a.config.Namespace,
+		PrincipalId:    principalId,
+		Constraints:    req.Constraints,
+		Context:        a.requestContext(req.OrganizationId, req.Namespace, req.Context),
+	})
+}
+
+// principalId returns id of the principal of the subject in the system organization, which is cached
+// so that admin requests don't query principals by username. Roles and permissions of the principal
+// are checked by the delegate with its own cache of principals.
+func (a *SystemAuthorizer) principalId(
+	ctx context.Context,
+	subject string,
+) (string, error) {
+	if subject == "" {
+		return "", status.New(
+			codes.PermissionDenied,
+			"subject is not defined for admin request").Err()
+	}
+	if id, ok := a.principalIds.Get(subject); ok && id != "" {
+		return id, nil
+	}
+	principal, err := a.findPrincipal(ctx, a.organizationId, subject)
+	if err != nil {
+		return "", err
+	}
+	if principal == nil {
+		return "", status.New(
+			codes.PermissionDenied,
+			fmt.Sprintf("%s is not a principal of system organization", subject)).Err()
+	}
+	_ = a.principalIds.Add(subject, principal.Id)
+	return principal.Id, nil
+}
+
+func (a *SystemAuthorizer) findPrincipal(
+	ctx context.Context,
+	organizationId string,
+	username string,
+) (*types.Principal, error) {
+	principals, _, err := a.authAdminService.GetPrincipals(
+		ctx, organizationId, map[string]string{"username": username}, "", 1)
+	if err != nil || len(principals) == 0 {
+		return nil, err
+	}
+	return principals[0], nil
+}
+
+func (a *SystemAuthorizer) requestContext(
+	organizationId string,
+	namespace string,
+	reqContext map[string]string,
+) map[string]string {
+	res := make(map[string]string)
+	for k, v := range reqContext {
+		res[k] = v
+	}
+	res["OrganizationId"] = organizationId
+	res["Namespace"] = namespace
+	return res
+}
+
+// bootstrap creates the system organization with admin and client roles, which mirror root and
+// client of casbin policies. The server holding the bootstrap lease runs the steps, which find
+// entities created by an earlier attempt before creating them, and marks the bootstrap as completed
+// after the last step so that an interrupted bootstrap is repeated on next start. Other servers wait
+// until the bootstrap is completed or its lease expires.
+func (a *SystemAuthorizer) bootstrap(ctx context.Context) (*types.Organization, error) {
+	job := "system-bootstrap:" + a.config.OrganizationName
+	for {
+		completed, err := a.leaseService.JobCompleted(ctx, job)
+		if err != nil {
+			return nil, err
+		}
+		if completed {
+			org, err := a.findOrganization(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if org == nil {
+				return nil, domain.NewNotFoundError(
+					fmt.Sprintf("system organization %s is not found", a.config.OrganizationName))
+			}
+			return org, nil
+		}
+		acquired, err := a.leaseService.AcquireLease(ctx, job, a.holder, bootstrapLeaseExpiry)
+		if err != nil {
+			return nil, err
+		}
+		if acquired {
+			org, err := a.bootstrapSteps(ctx, job)
+			if err != nil {
+				return nil, err
+			}
+			if err = a.leaseService.CompleteJob(ctx, job, a.holder); err != nil {
+				return nil, err
+			}
+			logrus.WithFields(logrus.Fields{
+				"Component":    "SystemAuthorizer",
+				"Organization": org.Id,
+				"Name":         org.Name,
+			}).Infof("bootstrapped system organization")
+			return org, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(bootstrapPollInterval):
+		}
+	}
+}
+
+func (a *SystemAuthorizer) bootstrapSteps(ctx context.Context, job string) (*types.Organization, error) {
+	org, err := a.findOrganization(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if org == nil {
+		if org, err = domain.NewOrganizationBuilder().
+			WithName(a.config.OrganizationName).
+			WithNamespaces(a.config.Namespace).Build(); err != nil {
+			return nil, err
+		}
+		if org, err = a.authAdminService.CreateOrganization(ctx, org); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = a.renewLease(ctx, job); err != nil {
+		return nil, err
+	}
+	resource, err := a.bootstrapResource(ctx, org.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	roleIds := make(map[string]string)
+	for name, actions := range systemRoles {
+		if err = a.renewLease(ctx, job); err != nil {
+			return nil, err
+		}
+		if roleIds[name], err = a.bootstrapRole(ctx, org.Id, resource.Id, name, actions); err != nil {
+			return nil, err
+		}
+	}
+
+	for role, usernames := range map[string][]string{
+		systemAdminRole:  a.config.Admins,
+		systemClientRole: a.config.Clients,
+	} {
+		for _, username := range usernames {
+			if err = a.renewLease(ctx, job); err != nil {
+				return nil, err
+			}
+			if err = a.bootstrapPrincipal(ctx, org.Id, username, roleIds[role]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return org, nil
+}
+
+func (a *SystemAuthorizer) bootstrapResource(ctx context.Context, organizationId string) (*types.Resource, error) {
+	resources, _, err := a.authAdminService.QueryResources(
+		ctx, organizationId, a.config.Namespace, map[string]string{"name": systemResource}, "", 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(resources) > 0 {
+		return resources[0], nil
+	}
+	resource, err := domain.NewResourceBuilder().
+		WithNamespace(a.config.Namespace).
+		WithName(systemResource).
+		WithAllowedActions(systemActions...).Build()
+	if err != nil {
+		return nil, err
+	}
+	return a.authAdminService.CreateResource(ctx, organizationId, resource)
+}
+
+func (a *SystemAuthorizer) bootstrapRole(
+	ctx context.Context,
+	organizationId string,
+	resourceId string,
+	name string,
+	actions []string,
+) (string, error) {
+	roles, _, err := a.authAdminService.GetRoles(
+		ctx, organizationId, a.config.Namespace, map[string]string{"name": name}, "", 1)
+	if err != nil {
+		return "", err
+	}
+	var role *types.Role
+	if len(roles) > 0 {
+		role = roles[0]
+	} else {
+		if role, err = domain.NewRoleBuilder().
+			WithNamespace(a.config.Namespace).
+			WithName(name).Build(); err != nil {
+			return "", err
+		}
+		if role, err = a.authAdminService.CreateRole(ctx, organizationId, role); err != nil {
+			return "", err
+		}
+	}
+
+	perm, err := a.findPermission(ctx, organizationId, resourceId, actions)
+	if err != nil {
+		return "", err
+	}
+	if perm == nil {
+		if perm, err = domain.NewPermissionBuilder().
+			WithNamespace(a.config.Namespace).
+			WithActions(actions...).
+			WithResourceId(resourceId).
+			WithEffect(types.Effect_PERMITTED).
+			WithScope("*").Build(); err != nil {
+			return "", err
+		}
+		if perm, err = a.authAdminService.CreatePermission(ctx, organizationId, perm); err != nil {
+			return "", err
+		}
+	}
+	if err = a.authAdminService.AddPermissionsToRole(
+		ctx, organizationId, a.config.Namespace, role.Id, perm.Id); err != nil {
+		return "", err
+	}
+	return role.Id, nil
+}
+
+func (a *SystemAuthorizer) bootstrapPrincipal(
+	ctx context.Context,
+	organizationId string,
+	username string,
+	roleId string,
+) error {
+	principal, err := a.findPrincipal(ctx, organizationId, username)
+	if err != nil {
+		return err
+	}
+	if principal == nil {
+		if principal, err = domain.NewPrincipalBuilder().
+			WithOrganizationId(organizationId).
+			WithNamespaces(a.config.Namespace).
+			WithUsername(username).
+			WithName(username).Build(); err != nil {
+			return err
+		}
+		if principal, err = a.authAdminService.CreatePrincipal(ctx, principal); err != nil {
+			return err
+		}
+	}
+	return a.authAdminService.AddRolesToPrincipal(
+		ctx, organizationId, a.config.Namespace, principal.Id, roleId)
+}
+
+func (a *SystemAuthorizer) findOrganization(ctx context.Context) (*types.Organization, error) {
+	orgs, _, err := a.authAdminService.GetOrganizations(
+		ctx, map[string]string{"name": a.config.OrganizationName}, "", 1)
+	if err != nil || len(orgs) == 0 {
+		return nil, err
+	}
+	return orgs[0], nil
+}
+
+// findPermission returns permission of the system resource with the actions of a role, which was
+// created by an earlier attempt of the bootstrap.
+func (a *SystemAuthorizer) findPermission(
+	ctx context.Context,
+	organizationId string,
+	resourceId string,
+	actions []string,
+) (*types.Permission, error) {
+	offset := ""
+	for {
+		perms, next, err := a.authAdminService.GetPermissions(
+			ctx, organizationId, a.config.Namespace, map[string]string{"resource_id": resourceId}, offset, 100)
+		if err != nil {
+			return nil, err
+		}
+		for _, perm := range perms {
+			if strings.Join(perm.Actions, ",") == strings.Join(actions, ",") {
+				return perm, nil
+			}
+		}
+		if next == "" || len(perms) == 0 {
+			return nil, nil
+		}
+		offset = next
+	}
+}
+
+// renewLease renews the bootstrap lease before next step, which fails if another server took it over.
+func (a *SystemAuthorizer) renewLease(ctx context.Context, job string) error {
+	acquired, err := a.leaseService.AcquireLease(ctx, job, a.holder, bootstrapLeaseExpiry)
+	if err != nil {
+		return err
+	}
+	if !acquired {
+		return domain.NewConflictError(fmt.Sprintf("lease of %s is held by another server", job))
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package authz
+
+import (
+	"context"
+	"github.com/bhatti/PlexAuthZ/api/v1/services"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/stretchr/testify/require"
+	"github.com/twinj/uuid"
+	"sync"
+	"testing"
+)
+
+func Test_ShouldAuthorizeWithSystemOrganization(t *testing.T) {
+	// GIVEN auth-authService and system authorizer with a new system organization
+	ctx := context.TODO()
+	authService, cfg, err := newAuthService()
+	require.NoError(t, err)
+	cfg.SystemAuth.Enabled = true
+	cfg.SystemAuth.OrganizationName = "system-" + uuid.NewV4().String()
+	require.Equal(t, SystemAuthorizerKind, AdminAuthorizerKind(cfg))
+	authorizer, err := CreateAuthorizer(AdminAuthorizerKind(cfg), cfg, authService)
+	require.NoError(t, err)
+	systemOrgId := authorizer.(*SystemAuthorizer).OrganizationId()
+
+	// WHEN creating the authorizer again
+	other, err := NewSystemAuthorizer(cfg, authService)
+	// THEN it should reuse the bootstrapped system organization
+	require.NoError(t, err)
+	require.Equal(t, systemOrgId, other.OrganizationId())
+
+	// THEN root should be permitted for all actions and client only for query and auth
+	for _, action := range systemActions {
+		_, err = authorizer.Authorize(ctx, &services.AuthRequest{
+			PrincipalId: "root", OrganizationId: "org", Resource: "*", Action: action})
+		require.NoError(t, err)
+		_, err = authorizer.Authorize(ctx, &services.AuthRequest{
+			PrincipalId: "client", OrganizationId: "org", Resource: "*", Action: action})
+		if action == "query" || action == "auth" {
+			require.NoError(t, err)
+		} else {
+			require.Error(t, err)
+		}
+		_, err = authorizer.Authorize(ctx, &services.AuthRequest{
+			PrincipalId: "nobody", OrganizationId: "org", Resource: "*", Action: action})
+		require.Error(t, err)
+	}
+
+	// AND scoped root should not be permitted outside its organization
+	scoped := WithScope(ctx, domain.NewClientScope("org"))
+	_, err = authorizer.Authorize(scoped, &services.AuthRequest{
+		PrincipalId: "root", OrganizationId: "org", Resource: "*", Action: "update"})
+	require.NoError(t, err)
+	_, err = authorizer.Authorize(scoped, &services.AuthRequest{
+		PrincipalId: "root", OrganizationId: "other-org", Resource: "*", Action: "update"})
+	require.Error(t, err)
+
+	// WHEN adding an admin limited to an organization with the same APIs as other organizations
+	namespace := cfg.SystemAuth.Namespace
+	resources, _, err := authService.QueryResources(
+		ctx, systemOrgId, namespace, map[string]string{"name": "*"}, "", 0)
+	require.NoError(t, err)
+	require.Len(t, resources, 1)
+	perm, err := domain.NewPermissionBuilder().
+		WithNamespace(namespace).
+		WithActions("*").
+		WithResourceId(resources[0].Id).
+		WithEffect(types.Effect_PERMITTED).
+		WithScope("*").
+		WithConstraints(`eq .OrganizationId "acme"`).Build()
+	require.NoError(t, err)
+	perm, err = authService.CreatePermission(ctx, systemOrgId, perm)
+	require.NoError(t, err)
+	acmeAdmin, err := domain.NewPrincipalBuilder().
+		WithOrganizationId(systemOrgId).
+		WithNamespaces(namespace).
+		WithUsername("acme-admin").Build()
+	require.NoError(t, err)
+	acmeAdmin, err = authService.CreatePrincipal(ctx, acmeAdmin)
+	require.NoError(t, err)
+	require.NoError(t, authService.AddPermissionsToPrincipal(ctx, systemOrgId, namespace, acmeAdmin.Id, perm.Id))
+
+	// THEN the admin should only be permitted for its organization
+	_, err = authorizer.Authorize(ctx, &services.AuthRequest{
+		PrincipalId: "acme-admin", OrganizationId: "acme", Resource: "*", Action: "delete"})
+	require.NoError(t, err)
+	_, err = authorizer.Authorize(ctx, &services.AuthRequest{
+		PrincipalId: "acme-admin", OrganizationId: "other-org", Resource: "*", Action: "delete"})
+	require.Error(t, err)
+
+	// AND constraints should be checked for principals of system organization
+	res, err := authorizer.Check(ctx, &services.CheckConstraintsRequest{
+		PrincipalId:    "acme-admin",
+		OrganizationId: "acme",
+		Constraints:    `eq .OrganizationId "acme"`,
+	})
+	require.NoError(t, err)
+	require.True(t, res.Matched)
+	_, err = authorizer.Check(ctx, &services.CheckConstraintsRequest{
+		PrincipalId: "nobody",
+		Constraints: `eq .OrganizationId "acme"`,
+	})
+	require.Error(t, err)
+}
+
+func Test_ShouldBootstrapSystemOrganizationOnceWithConcurrentServers(t *testing.T) {
+	// GIVEN auth-service with a new system organization name
+	ctx := context.TODO()
+	authService, cfg, err := newAuthService()
+	require.NoError(t, err)
+	cfg.SystemAuth.Enabled = true
+	cfg.SystemAuth.OrganizationName = "system-" + uuid.NewV4().String()
+
+	// WHEN servers bootstrap the system organization concurrently
+	orgIds := make(chan string, 5)
+	errs := make(chan error, 5)
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			authorizer, err := NewSystemAuthorizer(cfg, authService)
+			if err != nil {
+				errs <- err
+				return
+			}
+			orgIds <- authorizer.OrganizationId()
+		}()
+	}
+	wg.Wait()
+	close(orgIds)
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	// THEN they should share one system organization with one role of each kind
+	orgs, _, err := authService.GetOrganizations(
+		ctx, map[string]string{"name": cfg.SystemAuth.OrganizationName}, "", 0)
+	require.NoError(t, err)
+	require.Len(t, orgs, 1)
+	for orgId := range orgIds {
+		require.Equal(t, orgs[0].Id, orgId)
+	}
+	roles, _, err := authService.GetRoles(ctx, orgs[0].Id, cfg.SystemAuth.Namespace, map[string]string{}, "", 0)
+	require.NoError(t, err)
+	require.Len(t, roles, 2)
+}
+
+func Test_ShouldCompleteInterruptedBootstrapOfSystemOrganization(t *testing.T) {
+	// GIVEN system organization created by a bootstrap that stopped after its first step
+	ctx := context.TODO()
+	authService, cfg, err := newAuthService()
+	require.NoError(t, err)
+	cfg.SystemAuth.Enabled = true
+	cfg.SystemAuth.OrganizationName = "system-" + uuid.NewV4().String()
+	org, err := authService.CreateOrganization(ctx, &types.Organization{
+		Name:       cfg.SystemAuth.OrganizationName,
+		Namespaces: []string{cfg.SystemAuth.Namespace},
+	})
+	require.NoError(t, err)
+
+	// WHEN creating the authorizer
+	authorizer, err := NewSystemAuthorizer(cfg, authService)
+	require.NoError(t, err)
+
+	// THEN it should complete the bootstrap with the existing organization
+	require.Equal(t, org.Id, authorizer.OrganizationId())
+	_, err = authorizer.Authorize(ctx, &services.AuthRequest{
+		PrincipalId: "root", OrganizationId: "org", Resource: "*", Action: "delete"})
+	require.NoError(t, err)
+
+	// WHEN removing roles of the bootstrapped admin and creating the authorizer again
+	principals, _, err := authService.GetPrincipals(ctx, org.Id, map[string]string{"username": "root"}, "", 1)
+	require.NoError(t, err)
+	require.Len(t, principals, 1)
+	require.NoError(t, authService.DeleteRolesToPrincipal(
+		ctx, org.Id, cfg.SystemAuth.Namespace, principals[0].Id, principals[0].RoleIds...))
+	_, err = NewSystemAuthorizer(cfg, authService)
+	require.NoError(t, err)
+
+	// THEN the completed bootstrap should not be repeated
+	principal, err := authService.GetPrincipal(ctx, org.Id, principals[0].Id)
+	require.NoError(t, err)
+	require.Len(t, principal.RoleIds, 0)
+}
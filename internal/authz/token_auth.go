@@ -0,0 +1,81 @@
+package authz
+
+import (
+	"context"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"strings"
+)
+
+// TokenClaims defines the user of JWT bearer token, which is mapped from its claims.
+type TokenClaims struct {
+	OrganizationId string
+	PrincipalId    string
+	Subject        string
+	Claims         map[string]any
+}
+
+type tokenClaimsContextKey struct{}
+
+// Claims returns claims of JWT bearer token from context
+func Claims(ctx context.Context) *TokenClaims {
+	v := ctx.Value(tokenClaimsContextKey{})
+	if v == nil {
+		return nil
+	}
+	return v.(*TokenClaims)
+}
+
+// NewAuthenticator creates authentication function for gRPC interceptors, which uses common name of
+// the client certificate and claims of JWT bearer token in the authorization metadata. The subject of
+// the certificate takes precedence over the token so that services can invoke APIs on behalf of users.
+func NewAuthenticator(config *domain.Config) (func(ctx context.Context) (context.Context, error), error) {
+	if !config.GrpcJWT.Enabled() {
+		return Authenticate, nil
+	}
+	verifier, err := domain.NewJWTVerifier(config.GrpcJWT)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context) (context.Context, error) {
+		ctx, err := Authenticate(ctx)
+		if err != nil {
+			return ctx, err
+		}
+		token := bearerToken(ctx)
+		if token == "" {
+			return ctx, nil
+		}
+		claims, err := verifier.Verify(token)
+		if err != nil {
+			return ctx, status.New(codes.Unauthenticated, err.Error()).Err()
+		}
+		tokenClaims := &TokenClaims{
+			OrganizationId: verifier.OrganizationId(claims),
+			PrincipalId:    verifier.PrincipalId(claims),
+			Subject:        verifier.Subject(claims),
+			Claims:         claims,
+		}
+		ctx = context.WithValue(ctx, tokenClaimsContextKey{}, tokenClaims)
+		if Subject(ctx) == "" {
+			ctx = WithScope(ctx, verifier.Scope(claims))
+			ctx = WithSubject(ctx, tokenClaims.Subject)
+		}
+		return ctx, nil
+	}, nil
+}
+
+func bearerToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	for _, authorization := range md.Get("authorization") {
+		if strings.HasPrefix(strings.ToLower(authorization), "bearer ") {
+			return authorization[len("bearer "):]
+		}
+	}
+	return ""
+}
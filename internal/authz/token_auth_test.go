@@ -0,0 +1,50 @@
+package authz
+
+import (
+	"context"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"net"
+	"testing"
+)
+
+func Test_ShouldAuthenticateWithJWTToken(t *testing.T) {
+	// GIVEN authenticator with claim mappings
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	cfg.GrpcJWT = domain.JWTAuthConfig{
+		SigningKey:        "secret",
+		SubjectClaim:      "role",
+		OrganizationClaim: "tenant",
+		PrincipalClaim:    "uid",
+	}
+	authenticate, err := NewAuthenticator(cfg)
+	require.NoError(t, err)
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.IPAddr{}})
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"role": "client", "tenant": "org-1", "uid": "user-1"}).SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	// WHEN authenticating with token
+	res, err := authenticate(metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+token)))
+	// THEN it should map claims to subject, organization and principal
+	require.NoError(t, err)
+	require.Equal(t, "client", Subject(res))
+	require.Equal(t, "org-1", Claims(res).OrganizationId)
+	require.Equal(t, "user-1", Claims(res).PrincipalId)
+
+	// WHEN authenticating without token
+	res, err = authenticate(ctx)
+	// THEN it should not have subject
+	require.NoError(t, err)
+	require.Equal(t, "", Subject(res))
+	require.Nil(t, Claims(res))
+
+	// WHEN authenticating with invalid token
+	_, err = authenticate(metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer bad")))
+	// THEN it should fail
+	require.Error(t, err)
+}
@@ -0,0 +1,135 @@
+package bundle
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"github.com/bhatti/PlexAuthZ/api/v1/services"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"os"
+	"time"
+)
+
+// FormatVersion of bundles that can be loaded.
+const FormatVersion = 1
+
+// Bundle of an organization's authorization model, which can be loaded by edge nodes without
+// reaching the datastore.
+type Bundle struct {
+	FormatVersion int                   `json:"format_version"`
+	Revision      int64                 `json:"revision"`
+	Created       time.Time             `json:"created"`
+	Organization  *types.Organization   `json:"organization"`
+	Principals    []*types.Principal    `json:"principals"`
+	Resources     []*types.Resource     `json:"resources"`
+	Permissions   []*types.Permission   `json:"permissions"`
+	Roles         []*types.Role         `json:"roles"`
+	Groups        []*types.Group        `json:"groups"`
+	Relationships []*types.Relationship `json:"relationships"`
+	Resolved      []*ResolvedPrincipal  `json:"resolved"`
+}
+
+// ResolvedPrincipal principal of a namespace with its groups, roles, resources, permissions and relations.
+type ResolvedPrincipal struct {
+	Namespace string                         `json:"namespace"`
+	Principal *services.GetPrincipalResponse `json:"principal"`
+}
+
+// SignedBundle envelope of the bundle with Ed25519 signature of its exact bytes.
+type SignedBundle struct {
+	Bundle    json.RawMessage `json:"bundle"`
+	Signature []byte          `json:"signature"`
+}
+
+// Sign serializes the bundle and signs it with the private key.
+func Sign(bundle *Bundle, privateKey ed25519.PrivateKey) ([]byte, error) {
+	payload, err := json.Marshal(bundle)
+	if err != nil {
+		return nil, domain.NewMarshalError(fmt.Sprintf("failed to marshal bundle due to %s", err))
+	}
+	return json.Marshal(&SignedBundle{
+		Bundle:    payload,
+		Signature: ed25519.Sign(privateKey, payload),
+	})
+}
+
+// Verify checks signature of the signed bundle with the public key and returns the bundle.
+func Verify(data []byte, publicKey ed25519.PublicKey) (*Bundle, error) {
+	signed := &SignedBundle{}
+	if err := json.Unmarshal(data, signed); err != nil {
+		return nil, domain.NewMarshalError(fmt.Sprintf("failed to unmarshal signed bundle due to %s", err))
+	}
+	if !ed25519.Verify(publicKey, signed.Bundle, signed.Signature) {
+		return nil, domain.NewAuthError("signature of bundle is not valid")
+	}
+	bundle := &Bundle{}
+	if err := json.Unmarshal(signed.Bundle, bundle); err != nil {
+		return nil, domain.NewMarshalError(fmt.Sprintf("failed to unmarshal bundle due to %s", err))
+	}
+	if bundle.FormatVersion != FormatVersion {
+		return nil, domain.NewValidationError(
+			fmt.Sprintf("bundle format version %d is not supported", bundle.FormatVersion))
+	}
+	if bundle.Organization == nil {
+		return nil, domain.NewValidationError("bundle does not define organization")
+	}
+	return bundle, nil
+}
+
+// LoadFile reads the signed bundle from the file and verifies it with the public key.
+func LoadFile(path string, publicKey ed25519.PublicKey) (*Bundle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Verify(data, publicKey)
+}
+
+// LoadPrivateKey reads PEM encoded PKCS8 Ed25519 private key.
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, domain.NewValidationError(fmt.Sprintf("private key %s is not Ed25519", path))
+	}
+	return privateKey, nil
+}
+
+// LoadPublicKey reads PEM encoded PKIX Ed25519 public key.
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, domain.NewValidationError(fmt.Sprintf("public key %s is not Ed25519", path))
+	}
+	return publicKey, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, domain.NewValidationError(fmt.Sprintf("failed to decode PEM of %s", path))
+	}
+	return block, nil
+}
@@ -0,0 +1,604 @@
+package bundle
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/service"
+	"github.com/bhatti/PlexAuthZ/internal/utils"
+	"strconv"
+	"time"
+)
+
+// AuthAdminServiceBundle read-only implementation of auth-service that serves the authorization model of
+// an organization from a verified bundle.
+type AuthAdminServiceBundle struct {
+	bundle      *Bundle
+	resolved    map[string]*domain.PrincipalExt
+	readOnlyErr error
+}
+
+var _ service.AuthAdminService = &AuthAdminServiceBundle{}
+
+// NewAuthAdminServiceBundle constructor, which loads the bundle and verifies its signature with the public key.
+func NewAuthAdminServiceBundle(config domain.BundleConfig) (*AuthAdminServiceBundle, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	publicKey, err := LoadPublicKey(config.PublicKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	bundle, err := LoadFile(config.Path, publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return NewAuthAdminServiceBundleFrom(bundle), nil
+}
+
+// NewAuthAdminServiceBundleFrom constructor for a verified bundle.
+func NewAuthAdminServiceBundleFrom(bundle *Bundle) *AuthAdminServiceBundle {
+	resolved := make(map[string]*domain.PrincipalExt)
+	for _, principal := range bundle.Resolved {
+		xPrincipal := domain.NewPrincipalExtFromResponse(principal.Principal)
+		xPrincipal.Revision = bundle.Revision
+		resolved[resolvedKey(principal.Namespace, principal.Principal.Id)] = xPrincipal
+	}
+	return &AuthAdminServiceBundle{
+		bundle:   bundle,
+		resolved: resolved,
+		readOnlyErr: domain.NewValidationError(
+			fmt.Sprintf("organization %s is served from read-only bundle", bundle.Organization.Id)),
+	}
+}
+
+// Revision of the bundle.
+func (s *AuthAdminServiceBundle) Revision() int64 {
+	return s.bundle.Revision
+}
+
+// GetOrganization - finds organization
+func (s *AuthAdminServiceBundle) GetOrganization(
+	_ context.Context,
+	id string) (*types.Organization, error) {
+	if id != s.bundle.Organization.Id {
+		return nil, domain.NewNotFoundError(fmt.Sprintf("organization %s is not found in bundle", id))
+	}
+	return s.bundle.Organization, nil
+}
+
+// GetOrganizations - queries organizations
+func (s *AuthAdminServiceBundle) GetOrganizations(
+	_ context.Context,
+	predicate map[string]string,
+	offset string,
+	limit int64) ([]*types.Organization, string, error) {
+	return query([]*types.Organization{s.bundle.Organization}, predicate, offset, limit)
+}
+
+// CreateOrganization - is not supported by bundle
+func (s *AuthAdminServiceBundle) CreateOrganization(
+	context.Context,
+	*types.Organization) (*types.Organization, error) {
+	return nil, s.readOnlyErr
+}
+
+// UpdateOrganization - is not supported by bundle
+func (s *AuthAdminServiceBundle) UpdateOrganization(
+	context.Context,
+	*types.Organization) error {
+	return s.readOnlyErr
+}
+
+// DeleteOrganization - is not supported by bundle
+func (s *AuthAdminServiceBundle) DeleteOrganization(
+	context.Context,
+	string) error {
+	return s.readOnlyErr
+}
+
+// GetPrincipalExt - returns principal resolved in the bundle
+func (s *AuthAdminServiceBundle) GetPrincipalExt(
+	_ context.Context,
+	organizationID string,
+	namespace string,
+	id string,
+) (*domain.PrincipalExt, error) {
+	if err := s.checkOrganization(organizationID); err != nil {
+		return nil, err
+	}
+	xPrincipal := s.resolved[resolvedKey(namespace, id)]
+	if xPrincipal == nil {
+		return nil, domain.NewNotFoundError(
+			fmt.Sprintf("principal %s is not found in bundle for namespace %s", id, namespace))
+	}
+	return xPrincipal, nil
+}
+
+// GetPrincipal - finds principal
+func (s *AuthAdminServiceBundle) GetPrincipal(
+	_ context.Context,
+	organizationID string,
+	id string,
+) (*types.Principal, error) {
+	if err := s.checkOrganization(organizationID); err != nil {
+		return nil, err
+	}
+	return find(s.bundle.Principals, "principal", id, func(principal *types.Principal) string {
+		return principal.Id
+	})
+}
+
+// GetPrincipals - queries principals
+func (s *AuthAdminServiceBundle) GetPrincipals(
+	_ context.Context,
+	organizationID string,
+	predicate map[string]string,
+	offset string,
+	limit int64) ([]*types.Principal, string, error) {
+	if err := s.checkOrganization(organizationID); err != nil {
+		return nil, "", err
+	}
+	return query(s.bundle.Principals, predicate, offset, limit)
+}
+
+// CreatePrincipal - is not supported by bundle
+func (s *AuthAdminServiceBundle) CreatePrincipal(
+	context.Context,
+	*types.Principal) (*types.Principal, error) {
+	return nil, s.readOnlyErr
+}
+
+// UpdatePrincipal - is not supported by bundle
+func (s *AuthAdminServiceBundle) UpdatePrincipal(
+	context.Context,
+	*types.Principal) error {
+	return s.readOnlyErr
+}
+
+// DeletePrincipal - is not supported by bundle
+func (s *AuthAdminServiceBundle) DeletePrincipal(
+	context.Context,
+	string,
+	string) error {
+	return s.readOnlyErr
+}
+
+// AddGroupsToPrincipal - is not supported by bundle
+func (s *AuthAdminServiceBundle) AddGroupsToPrincipal(
+	context.Context, string, string, string, ...string) error {
+	return s.readOnlyErr
+}
+
+// DeleteGroupsToPrincipal - is not supported by bundle
+func (s *AuthAdminServiceBundle) DeleteGroupsToPrincipal(
+	context.Context, string, string, string, ...string) error {
+	return s.readOnlyErr
+}
+
+// AddRolesToPrincipal - is not supported by bundle
+func (s *AuthAdminServiceBundle) AddRolesToPrincipal(
+	context.Context, string, string, string, ...string) error {
+	return s.readOnlyErr
+}
+
+// DeleteRolesToPrincipal - is not supported by bundle
+func (s *AuthAdminServiceBundle) DeleteRolesToPrincipal(
+	context.Context, string, string, string, ...string) error {
+	return s.readOnlyErr
+}
+
+// AddPermissionsToPrincipal - is not supported by bundle
+func (s *AuthAdminServiceBundle) AddPermissionsToPrincipal(
+	context.Context, string, string, string, ...string) error {
+	return s.readOnlyErr
+}
+
+// DeletePermissionsToPrincipal - is not supported by bundle
+func (s *AuthAdminServiceBundle) DeletePermissionsToPrincipal(
+	context.Context, string, string, string, ...string) error {
+	return s.readOnlyErr
+}
+
+// AddRelationshipsToPrincipal - is not supported by bundle
+func (s *AuthAdminServiceBundle) AddRelationshipsToPrincipal(
+	context.Context, string, string, string, ...string) error {
+	return s.readOnlyErr
+}
+
+// DeleteRelationshipsToPrincipal - is not supported by bundle
+func (s *AuthAdminServiceBundle) DeleteRelationshipsToPrincipal(
+	context.Context, string, string, string, ...string) error {
+	return s.readOnlyErr
+}
+
+// GetResource - finds resource
+func (s *AuthAdminServiceBundle) GetResource(
+	_ context.Context,
+	organizationID string,
+	namespace string,
+	id string,
+) (*types.Resource, error) {
+	if err := s.checkOrganization(organizationID); err != nil {
+		return nil, err
+	}
+	return find(inNamespace(s.bundle.Resources, namespace, func(resource *types.Resource) string {
+		return resource.Namespace
+	}), "resource", id, func(resource *types.Resource) string {
+		return resource.Id
+	})
+}
+
+// QueryResources - queries resources
+func (s *AuthAdminServiceBundle) QueryResources(
+	_ context.Context,
+	organizationID string,
+	namespace string,
+	predicate map[string]string,
+	offset string,
+	limit int64) ([]*types.Resource, string, error) {
+	if err := s.checkOrganization(organizationID); err != nil {
+		return nil, "", err
+	}
+	return query(inNamespace(s.bundle.Resources, namespace, func(resource *types.Resource) string {
+		return resource.Namespace
+	}), predicate, offset, limit)
+}
+
+// CreateResource - is not supported by bundle
+func (s *AuthAdminServiceBundle) CreateResource(
+	context.Context,
+	string,
+	*types.Resource) (*types.Resource, error) {
+	return nil, s.readOnlyErr
+}
+
+// UpdateResource - is not supported by bundle
+func (s *AuthAdminServiceBundle) UpdateResource(
+	context.Context,
+	string,
+	*types.Resource) error {
+	return s.readOnlyErr
+}
+
+// DeleteResource - is not supported by bundle
+func (s *AuthAdminServiceBundle) DeleteResource(
+	context.Context, string, string, string) error {
+	return s.readOnlyErr
+}
+
+// AllocateResourceInstance - is not supported by bundle
+func (s *AuthAdminServiceBundle) AllocateResourceInstance(
+	context.Context, string, string, string, string, string, time.Duration, map[string]string) (time.Time, error) {
+	return time.Time{}, s.readOnlyErr
+}
+
+// RenewAllocation - is not supported by bundle
+func (s *AuthAdminServiceBundle) RenewAllocation(
+	context.Context, string, string, string, string, time.Duration) (time.Time, error) {
+	return time.Time{}, s.readOnlyErr
+}
+
+// DeallocateResourceInstance - is not supported by bundle
+func (s *AuthAdminServiceBundle) DeallocateResourceInstance(
+	context.Context, string, string, string, string) error {
+	return s.readOnlyErr
+}
+
+// CountResourceInstances - is not supported by bundle as allocations are not exported
+func (s *AuthAdminServiceBundle) CountResourceInstances(
+	context.Context, string, string, string) (int32, int32, error) {
+	return 0, 0, s.readOnlyErr
+}
+
+// QueryResourceInstances - is not supported by bundle as allocations are not exported
+func (s *AuthAdminServiceBundle) QueryResourceInstances(
+	context.Context, string, string, string, map[string]string, string, int64,
+) ([]*types.ResourceInstance, string, error) {
+	return nil, "", s.readOnlyErr
+}
+
+// GetPermission - finds permission
+func (s *AuthAdminServiceBundle) GetPermission(
+	_ context.Context,
+	organizationID string,
+	namespace string,
+	id string,
+) (*types.Permission, error) {
+	if err := s.checkOrganization(organizationID); err != nil {
+		return nil, err
+	}
+	return find(inNamespace(s.bundle.Permissions, namespace, func(permission *types.Permission) string {
+		return permission.Namespace
+	}), "permission", id, func(permission *types.Permission) string {
+		return permission.Id
+	})
+}
+
+// GetPermissions - queries permissions
+func (s *AuthAdminServiceBundle) GetPermissions(
+	_ context.Context,
+	organizationID string,
+	namespace string,
+	predicate map[string]string,
+	offset string,
+	limit int64) ([]*types.Permission, string, error) {
+	if err := s.checkOrganization(organizationID); err != nil {
+		return nil, "", err
+	}
+	return query(inNamespace(s.bundle.Permissions, namespace, func(permission *types.Permission) string {
+		return permission.Namespace
+	}), predicate, offset, limit)
+}
+
+// CreatePermission - is not supported by bundle
+func (s *AuthAdminServiceBundle) CreatePermission(
+	context.Context,
+	string,
+	*types.Permission) (*types.Permission, error) {
+	return nil, s.readOnlyErr
+}
+
+// UpdatePermission - is not supported by bundle
+func (s *AuthAdminServiceBundle) UpdatePermission(
+	context.Context,
+	string,
+	*types.Permission) error {
+	return s.readOnlyErr
+}
+
+// DeletePermission - is not supported by bundle
+func (s *AuthAdminServiceBundle) DeletePermission(
+	context.Context, string, string, string) error {
+	return s.readOnlyErr
+}
+
+// GetRole - finds role
+func (s *AuthAdminServiceBundle) GetRole(
+	_ context.Context,
+	organizationID string,
+	namespace string,
+	id string,
+) (*types.Role, error) {
+	if err := s.checkOrganization(organizationID); err != nil {
+		return nil, err
+	}
+	return find(inNamespace(s.bundle.Roles, namespace, func(role *types.Role) string {
+		return role.Namespace
+	}), "role", id, func(role *types.Role) string {
+		return role.Id
+	})
+}
+
+// GetRoles - queries roles
+func (s *AuthAdminServiceBundle) GetRoles(
+	_ context.Context,
+	organizationID string,
+	namespace string,
+	predicate map[string]string,
+	offset string,
+	limit int64) ([]*types.Role, string, error) {
+	if err := s.checkOrganization(organizationID); err != nil {
+		return nil, "", err
+	}
+	return query(inNamespace(s.bundle.Roles, namespace, func(role *types.Role) string {
+		return role.Namespace
+	}), predicate, offset, limit)
+}
+
+// CreateRole - is not supported by bundle
+func (s *AuthAdminServiceBundle) CreateRole(
+	context.Context,
+	string,
+	*types.Role) (*types.Role, error) {
+	return nil, s.readOnlyErr
+}
+
+// UpdateRole - is not supported by bundle
+func (s *AuthAdminServiceBundle) UpdateRole(
+	context.Context,
+	string,
+	*types.Role) error {
+	return s.readOnlyErr
+}
+
+// DeleteRole - is not supported by bundle
+func (s *AuthAdminServiceBundle) DeleteRole(
+	context.Context, string, string, string) error {
+	return s.readOnlyErr
+}
+
+// AddPermissionsToRole - is not supported by bundle
+func (s *AuthAdminServiceBundle) AddPermissionsToRole(
+	context.Context, string, string, string, ...string) error {
+	return s.readOnlyErr
+}
+
+// DeletePermissionsToRole - is not supported by bundle
+func (s *AuthAdminServiceBundle) DeletePermissionsToRole(
+	context.Context, string, string, string, ...string) error {
+	return s.readOnlyErr
+}
+
+// GetGroup - finds group
+func (s *AuthAdminServiceBundle) GetGroup(
+	_ context.Context,
+	organizationID string,
+	namespace string,
+	id string,
+) (*types.Group, error) {
+	if err := s.checkOrganization(organizationID); err != nil {
+		return nil, err
+	}
+	return find(inNamespace(s.bundle.Groups, namespace, func(group *types.Group) string {
+		return group.Namespace
+	}), "group", id, func(group *types.Group) string {
+		return group.Id
+	})
+}
+
+// GetGroups - queries groups
+func (s *AuthAdminServiceBundle) GetGroups(
+	_ context.Context,
+	organizationID string,
+	namespace string,
+	predicate map[string]string,
+	offset string,
+	limit int64) ([]*types.Group, string, error) {
+	if err := s.checkOrganization(organizationID); err != nil {
+		return nil, "", err
+	}
+	return query(inNamespace(s.bundle.Groups, namespace, func(group *types.Group) string {
+		return group.Namespace
+	}), predicate, offset, limit)
+}
+
+// CreateGroup - is not supported by bundle
+func (s *AuthAdminServiceBundle) CreateGroup(
+	context.Context,
+	string,
+	*types.Group) (*types.Group, error) {
+	return nil, s.readOnlyErr
+}
+
+// UpdateGroup - is not supported by bundle
+func (s *AuthAdminServiceBundle) UpdateGroup(
+	context.Context,
+	string,
+	*types.Group) error {
+	return s.readOnlyErr
+}
+
+// DeleteGroup - is not supported by bundle
+func (s *AuthAdminServiceBundle) DeleteGroup(
+	context.Context, string, string, string) error {
+	return s.readOnlyErr
+}
+
+// AddRolesToGroup - is not supported by bundle
+func (s *AuthAdminServiceBundle) AddRolesToGroup(
+	context.Context, string, string, string, ...string) error {
+	return s.readOnlyErr
+}
+
+// DeleteRolesToGroup - is not supported by bundle
+func (s *AuthAdminServiceBundle) DeleteRolesToGroup(
+	context.Context, string, string, string, ...string) error {
+	return s.readOnlyErr
+}
+
+// GetRelationship - finds relationship
+func (s *AuthAdminServiceBundle) GetRelationship(
+	_ context.Context,
+	organizationID string,
+	namespace string,
+	id string,
+) (*types.Relationship, error) {
+	if err := s.checkOrganization(organizationID); err != nil {
+		return nil, err
+	}
+	return find(inNamespace(s.bundle.Relationships, namespace, func(relationship *types.Relationship) string {
+		return relationship.Namespace
+	}), "relationship", id, func(relationship *types.Relationship) string {
+		return relationship.Id
+	})
+}
+
+// GetRelationships - queries relationships
+func (s *AuthAdminServiceBundle) GetRelationships(
+	_ context.Context,
+	organizationID string,
+	namespace string,
+	predicate map[string]string,
+	offset string,
+	limit int64) ([]*types.Relationship, string, error) {
+	if err := s.checkOrganization(organizationID); err != nil {
+		return nil, "", err
+	}
+	return query(inNamespace(s.bundle.Relationships, namespace, func(relationship *types.Relationship) string {
+		return relationship.Namespace
+	}), predicate, offset, limit)
+}
+
+// CreateRelationship - is not supported by bundle
+func (s *AuthAdminServiceBundle) CreateRelationship(
+	context.Context,
+	string,
+	*types.Relationship) (*types.Relationship, error) {
+	return nil, s.readOnlyErr
+}
+
+// UpdateRelationship - is not supported by bundle
+func (s *AuthAdminServiceBundle) UpdateRelationship(
+	context.Context,
+	string,
+	*types.Relationship) error {
+	return s.readOnlyErr
+}
+
+// DeleteRelationship - is not supported by bundle
+func (s *AuthAdminServiceBundle) DeleteRelationship(
+	context.Context, string, string, string) error {
+	return s.readOnlyErr
+}
+
+func (s *AuthAdminServiceBundle) checkOrganization(organizationID string) error {
+	if organizationID == "" {
+		return domain.NewValidationError("organization_id is not defined")
+	}
+	if organizationID != s.bundle.Organization.Id {
+		return domain.NewNotFoundError(fmt.Sprintf("organization %s is not found in bundle", organizationID))
+	}
+	return nil
+}
+
+func resolvedKey(namespace string, principalID string) string {
+	return namespace + "/" + principalID
+}
+
+func inNamespace[T any](all []T, namespace string, namespaceOf func(T) string) (res []T) {
+	for _, next := range all {
+		if namespaceOf(next) == namespace {
+			res = append(res, next)
+		}
+	}
+	return
+}
+
+func find[T any](all []T, kind string, id string, idOf func(T) string) (T, error) {
+	for _, next := range all {
+		if idOf(next) == id {
+			return next, nil
+		}
+	}
+	var empty T
+	return empty, domain.NewNotFoundError(fmt.Sprintf("%s %s is not found in bundle", kind, id))
+}
+
+// query filters records with predicate similar to the repositories, where offset is the index of next record.
+func query[T any](
+	all []T,
+	predicate map[string]string,
+	offsetStr string,
+	limit int64,
+) (res []T, nextOffset string, err error) {
+	offset, _ := strconv.Atoi(offsetStr)
+	for i := offset; i < len(all); i++ {
+		if len(predicate) > 0 {
+			value, err := json.Marshal(all[i])
+			if err != nil {
+				return nil, "", err
+			}
+			if !utils.MatchPredicate(value, predicate) {
+				continue
+			}
+		}
+		res = append(res, all[i])
+		if limit > 0 && int64(len(res)) >= limit {
+			return res, strconv.Itoa(i + 1), nil
+		}
+	}
+	return res, "", nil
+}
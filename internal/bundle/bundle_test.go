@@ -0,0 +1,145 @@
+package bundle
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/bhatti/PlexAuthZ/api/v1/services"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/authz"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/metrics"
+	"github.com/bhatti/PlexAuthZ/internal/service/db"
+	"github.com/stretchr/testify/require"
+	"github.com/twinj/uuid"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_ShouldExportSignedBundleAndAuthorizeFromIt(t *testing.T) {
+	// GIVEN organization with a principal that can read reports through a role
+	ctx := context.Background()
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	authService, _, err := db.CreateDatabaseAuthService(cfg, metrics.New())
+	require.NoError(t, err)
+	org, err := authService.CreateOrganization(ctx, &types.Organization{
+		Name:       "bundle-" + uuid.NewV4().String(),
+		Namespaces: []string{"finance"},
+	})
+	require.NoError(t, err)
+	namespace := org.Namespaces[0]
+	report, err := authService.CreateResource(ctx, org.Id, &types.Resource{
+		Namespace: namespace, Name: "report", AllowedActions: []string{"read", "write"}})
+	require.NoError(t, err)
+	perm, err := authService.CreatePermission(ctx, org.Id, &types.Permission{
+		Namespace: namespace, ResourceId: report.Id, Actions: []string{"read"}})
+	require.NoError(t, err)
+	role, err := authService.CreateRole(ctx, org.Id, &types.Role{Namespace: namespace, Name: "reader"})
+	require.NoError(t, err)
+	require.NoError(t, authService.AddPermissionsToRole(ctx, org.Id, namespace, role.Id, perm.Id))
+	alice, err := authService.CreatePrincipal(ctx, &types.Principal{
+		OrganizationId: org.Id, Namespaces: org.Namespaces, Username: "alice"})
+	require.NoError(t, err)
+	require.NoError(t, authService.AddRolesToPrincipal(ctx, org.Id, namespace, alice.Id, role.Id))
+
+	// WHEN exporting and signing the bundle
+	config := writeKeys(t)
+	privateKey, err := LoadPrivateKey(config.PrivateKeyFile)
+	require.NoError(t, err)
+	b, err := Export(ctx, authService, org.Id)
+	require.NoError(t, err)
+	data, err := Sign(b, privateKey)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(config.Path, data, 0600))
+
+	// THEN bundle service should serve the principal and authorize from the bundle
+	bundleService, err := NewAuthAdminServiceBundle(config)
+	require.NoError(t, err)
+	require.Equal(t, b.Revision, bundleService.Revision())
+	principal, err := bundleService.GetPrincipal(ctx, org.Id, alice.Id)
+	require.NoError(t, err)
+	require.Equal(t, "alice", principal.Username)
+	roles, _, err := bundleService.GetRoles(ctx, org.Id, namespace, map[string]string{"name": "reader"}, "", 10)
+	require.NoError(t, err)
+	require.Len(t, roles, 1)
+	authorizer := authz.NewDefaultAuthorizer(bundleService)
+	req := &services.AuthRequest{
+		OrganizationId: org.Id,
+		Namespace:      namespace,
+		PrincipalId:    alice.Id,
+		Action:         "read",
+		Resource:       "report",
+	}
+	res, err := authorizer.Authorize(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, types.Effect_PERMITTED, res.Effect)
+	req.Action = "write"
+	_, err = authorizer.Authorize(ctx, req)
+	require.Error(t, err)
+
+	// AND bundle service should be read-only
+	_, err = bundleService.CreateRole(ctx, org.Id, &types.Role{Namespace: namespace, Name: "writer"})
+	require.Error(t, err)
+
+	// AND tampered bundle should be rejected
+	tampered := append([]byte{}, data...)
+	tampered[len(tampered)/2] ^= 1
+	_, err = Verify(tampered, privateKey.Public().(ed25519.PublicKey))
+	require.Error(t, err)
+}
+
+func Test_ShouldExportPrincipalsForTheirNamespaces(t *testing.T) {
+	// GIVEN organization with two namespaces and principals of one or both namespaces
+	ctx := context.Background()
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	authService, _, err := db.CreateDatabaseAuthService(cfg, metrics.New())
+	require.NoError(t, err)
+	org, err := authService.CreateOrganization(ctx, &types.Organization{
+		Name:       "bundle-" + uuid.NewV4().String(),
+		Namespaces: []string{"finance", "sales"},
+	})
+	require.NoError(t, err)
+	alice, err := authService.CreatePrincipal(ctx, &types.Principal{
+		OrganizationId: org.Id, Namespaces: []string{"finance"}, Username: "alice"})
+	require.NoError(t, err)
+	bob, err := authService.CreatePrincipal(ctx, &types.Principal{
+		OrganizationId: org.Id, Namespaces: org.Namespaces, Username: "bob"})
+	require.NoError(t, err)
+
+	// WHEN exporting the bundle
+	b, err := Export(ctx, authService, org.Id)
+	require.NoError(t, err)
+
+	// THEN principals should only be resolved for their namespaces
+	resolved := make(map[string][]string)
+	for _, next := range b.Resolved {
+		resolved[next.Principal.Id] = append(resolved[next.Principal.Id], next.Namespace)
+	}
+	require.Equal(t, []string{"finance"}, resolved[alice.Id])
+	require.Equal(t, []string{"finance", "sales"}, resolved[bob.Id])
+}
+
+func writeKeys(t *testing.T) domain.BundleConfig {
+	dir := t.TempDir()
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	privateDer, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+	publicDer, err := x509.MarshalPKIXPublicKey(publicKey)
+	require.NoError(t, err)
+	config := domain.BundleConfig{
+		Path:           filepath.Join(dir, "bundle.json"),
+		PrivateKeyFile: filepath.Join(dir, "bundle.key"),
+		PublicKeyFile:  filepath.Join(dir, "bundle.pub"),
+	}
+	require.NoError(t, os.WriteFile(config.PrivateKeyFile,
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDer}), 0600))
+	require.NoError(t, os.WriteFile(config.PublicKeyFile,
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDer}), 0600))
+	return config
+}
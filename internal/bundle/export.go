@@ -0,0 +1,104 @@
+package bundle
+
+import (
+	"context"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/service"
+	"github.com/bhatti/PlexAuthZ/internal/utils"
+	"time"
+)
+
+const exportPageSize = 500
+
+// Export builds bundle of the organization with its resources, permissions, roles, groups, principals and
+// relationships, where principals are also resolved for each of their namespaces.
+func Export(
+	ctx context.Context,
+	authAdminService service.AuthAdminService,
+	organizationId string,
+) (*Bundle, error) {
+	revision := domain.NewRevision()
+	org, err := authAdminService.GetOrganization(ctx, organizationId)
+	if err != nil {
+		return nil, err
+	}
+	bundle := &Bundle{
+		FormatVersion: FormatVersion,
+		Revision:      revision,
+		Created:       time.Now(),
+		Organization:  org,
+	}
+	if bundle.Principals, err = queryAll(func(offset string) ([]*types.Principal, string, error) {
+		return authAdminService.GetPrincipals(ctx, org.Id, nil, offset, exportPageSize)
+	}); err != nil {
+		return nil, err
+	}
+	for _, namespace := range org.Namespaces {
+		resources, err := queryAll(func(offset string) ([]*types.Resource, string, error) {
+			return authAdminService.QueryResources(ctx, org.Id, namespace, nil, offset, exportPageSize)
+		})
+		if err != nil {
+			return nil, err
+		}
+		bundle.Resources = append(bundle.Resources, resources...)
+		permissions, err := queryAll(func(offset string) ([]*types.Permission, string, error) {
+			return authAdminService.GetPermissions(ctx, org.Id, namespace, nil, offset, exportPageSize)
+		})
+		if err != nil {
+			return nil, err
+		}
+		bundle.Permissions = append(bundle.Permissions, permissions...)
+		roles, err := queryAll(func(offset string) ([]*types.Role, string, error) {
+			return authAdminService.GetRoles(ctx, org.Id, namespace, nil, offset, exportPageSize)
+		})
+		if err != nil {
+			return nil, err
+		}
+		bundle.Roles = append(bundle.Roles, roles...)
+		groups, err := queryAll(func(offset string) ([]*types.Group, string, error) {
+			return authAdminService.GetGroups(ctx, org.Id, namespace, nil, offset, exportPageSize)
+		})
+		if err != nil {
+			return nil, err
+		}
+		bundle.Groups = append(bundle.Groups, groups...)
+		relationships, err := queryAll(func(offset string) ([]*types.Relationship, string, error) {
+			return authAdminService.GetRelationships(ctx, org.Id, namespace, nil, offset, exportPageSize)
+		})
+		if err != nil {
+			return nil, err
+		}
+		bundle.Relationships = append(bundle.Relationships, relationships...)
+		for _, principal := range bundle.Principals {
+			if !utils.Includes(principal.Namespaces, namespace) {
+				continue
+			}
+			xPrincipal, err := authAdminService.GetPrincipalExt(ctx, org.Id, namespace, principal.Id)
+			if err != nil {
+				return nil, err
+			}
+			bundle.Resolved = append(bundle.Resolved, &ResolvedPrincipal{
+				Namespace: namespace,
+				Principal: xPrincipal.ToGetPrincipalResponse(),
+			})
+		}
+	}
+	return bundle, nil
+}
+
+// queryAll fetches pages until a partial page is returned.
+func queryAll[T any](query func(offset string) ([]T, string, error)) (all []T, err error) {
+	offset := ""
+	for {
+		page, nextOffset, err := query(offset)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if len(page) < exportPageSize || nextOffset == "" || nextOffset == offset {
+			return all, nil
+		}
+		offset = nextOffset
+	}
+}
@@ -16,14 +16,23 @@ import (
 type AuthAdapter struct {
 	authorizer       authz.Authorizer
 	authAdminService service.AuthAdminService
+	// DecisionCache optional cache of authorization decisions, see WithDecisionCache.
+	DecisionCache *DecisionCache
+	// LocalAuthorizer optional authorizer that evaluates requests locally, see WithLocalEvaluation.
+	LocalAuthorizer *LocalAuthorizer
 }
 
 // New constructor
 func New(
 	authorizer authz.Authorizer,
 	authAdminService service.AuthAdminService,
+	opts ...Option,
 ) *AuthAdapter {
-	return &AuthAdapter{authorizer: authorizer, authAdminService: authAdminService}
+	adapter := &AuthAdapter{authorizer: authorizer, authAdminService: authAdminService}
+	for _, opt := range opts {
+		opt(adapter)
+	}
+	return adapter
 }
 
 // CreateOrganization adapter
@@ -36,6 +45,7 @@ func (c *AuthAdapter) CreateOrganization(
 	return &OrganizationAdapter{
 		authorizer:       c.authorizer,
 		authAdminService: c.authAdminService,
+		decisionCache:    c.DecisionCache,
 		Organization:     org,
 	}, nil
 }
@@ -50,6 +60,7 @@ func (c *AuthAdapter) GetOrganization(
 	return &OrganizationAdapter{
 		authorizer:       c.authorizer,
 		authAdminService: c.authAdminService,
+		decisionCache:    c.DecisionCache,
 		Organization:     org,
 	}, nil
 }
@@ -58,6 +69,7 @@ func (c *AuthAdapter) GetOrganization(
 type OrganizationAdapter struct {
 	authorizer       authz.Authorizer
 	authAdminService service.AuthAdminService
+	decisionCache    *DecisionCache
 	Organization     *types.Organization
 }
 
@@ -76,6 +88,7 @@ func (c *OrganizationAdapter) Principals() *PrincipalAdapter {
 	return &PrincipalAdapter{
 		authAdminService: c.authAdminService,
 		authorizer:       c.authorizer,
+		decisionCache:    c.decisionCache,
 		Principal: &types.Principal{
 			OrganizationId: c.Organization.Id,
 			Namespaces:     c.Organization.Namespaces,
@@ -130,15 +143,16 @@ func (c *OrganizationAdapter) Roles(namespace string) *RoleAdapter {
 
 // AuthorizerAdapter for authorization request.
 type AuthorizerAdapter struct {
-	authorizer  authz.Authorizer
-	Principal   *types.Principal
-	namespace   string
-	action      string
-	resource    string
-	scope       string
-	context     map[string]string
-	constraints string
-	LastMessage string
+	authorizer    authz.Authorizer
+	decisionCache *DecisionCache
+	Principal     *types.Principal
+	namespace     string
+	action        string
+	resource      string
+	scope         string
+	context       map[string]string
+	constraints   string
+	LastMessage   string
 }
 
 // WithAction setter.
@@ -205,7 +219,7 @@ func (c *AuthorizerAdapter) Check() error {
 			Scope:          c.scope,
 			Context:        c.context,
 		}
-		res, err := c.authorizer.Authorize(context.Background(), req)
+		res, err := c.authorize(req)
 		if err != nil {
 			return err
 		}
@@ -218,10 +232,21 @@ func (c *AuthorizerAdapter) Check() error {
 	return nil
 }
 
+func (c *AuthorizerAdapter) authorize(req *services.AuthRequest) (*services.AuthResponse, error) {
+	authorize := func(req *services.AuthRequest) (*services.AuthResponse, error) {
+		return c.authorizer.Authorize(context.Background(), req)
+	}
+	if c.decisionCache == nil {
+		return authorize(req)
+	}
+	return c.decisionCache.Authorize(req, authorize)
+}
+
 // PrincipalAdapter for managing principals.
 type PrincipalAdapter struct {
 	authorizer       authz.Authorizer
 	authAdminService service.AuthAdminService
+	decisionCache    *DecisionCache
 	Principal        *types.Principal
 }
 
@@ -427,9 +452,10 @@ func (c *PrincipalAdapter) Authorizer(
 	namespace string,
 ) *AuthorizerAdapter {
 	return &AuthorizerAdapter{
-		authorizer: c.authorizer,
-		Principal:  c.Principal,
-		namespace:  namespace,
+		authorizer:    c.authorizer,
+		decisionCache: c.decisionCache,
+		Principal:     c.Principal,
+		namespace:     namespace,
 	}
 }
 
@@ -726,7 +752,7 @@ func (c *ResourceAdapter) Update() error {
 
 // Allocate adapter for allocating resource.
 func (c *ResourceAdapter) Allocate(principal *types.Principal) error {
-	return c.authAdminService.AllocateResourceInstance(
+	_, err := c.authAdminService.AllocateResourceInstance(
 		context.Background(),
 		c.orgID,
 		c.Resource.Namespace,
@@ -736,6 +762,19 @@ func (c *ResourceAdapter) Allocate(principal *types.Principal) error {
 		c.expiry,
 		c.context,
 	)
+	return err
+}
+
+// Renew adapter for renewing lease of allocated resource, which returns deadline of the lease.
+func (c *ResourceAdapter) Renew(principal *types.Principal) (time.Time, error) {
+	return c.authAdminService.RenewAllocation(
+		context.Background(),
+		c.orgID,
+		c.Resource.Namespace,
+		c.Resource.Id,
+		principal.Id,
+		c.expiry,
+	)
 }
 
 // Deallocate adapter for deallocating resource.
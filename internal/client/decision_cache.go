@@ -0,0 +1,191 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/bhatti/PlexAuthZ/api/v1/services"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultDecisionCacheTTL     = 10 * time.Second
+	defaultDecisionCacheMaxSize = 10000
+)
+
+// Option for configuring the auth adapter.
+type Option func(adapter *AuthAdapter)
+
+// WithDecisionCache enables the client-side cache of authorization decisions, where decisions are
+// kept up to the ttl and evicted in LRU order when max-size is reached.
+func WithDecisionCache(ttl time.Duration, maxSize int) Option {
+	return func(adapter *AuthAdapter) {
+		adapter.DecisionCache = NewDecisionCache(ttl, maxSize)
+	}
+}
+
+// DecisionCacheStats statistics of the decision cache.
+type DecisionCacheStats struct {
+	Hits          int64
+	Misses        int64
+	Invalidations int64
+	Size          int
+}
+
+// DecisionCache caches authorization decisions keyed by organization, namespace, principal, resource,
+// action, scope and hash of the context. Entries of an organization are invalidated when the server
+// signals a change to the organization, see Watch.
+type DecisionCache struct {
+	cache         *expirable.LRU[string, *cachedDecision]
+	hits          int64
+	misses        int64
+	invalidations int64
+	// generation of each organization is incremented on invalidation so that decisions evaluated
+	// concurrently with a change are not cached.
+	generations map[string]int64
+	lock        sync.RWMutex
+}
+
+type cachedDecision struct {
+	organizationId string
+	res            *services.AuthResponse
+	err            error
+}
+
+// NewDecisionCache constructor
+func NewDecisionCache(ttl time.Duration, maxSize int) *DecisionCache {
+	if ttl <= 0 {
+		ttl = defaultDecisionCacheTTL
+	}
+	if maxSize <= 0 {
+		maxSize = defaultDecisionCacheMaxSize
+	}
+	return &DecisionCache{
+		cache:       expirable.NewLRU[string, *cachedDecision](maxSize, nil, ttl),
+		generations: make(map[string]int64),
+	}
+}
+
+// Authorize returns cached decision of the request or evaluates it with the authorize function,
+// where permitted and denied decisions are cached but other errors are not.
+func (c *DecisionCache) Authorize(
+	req *services.AuthRequest,
+	authorize func(req *services.AuthRequest) (*services.AuthResponse, error),
+) (*services.AuthResponse, error) {
+	key := decisionKey(req)
+	if decision, ok := c.cache.Get(key); ok {
+		atomic.AddInt64(&c.hits, 1)
+		return decision.res, decision.err
+	}
+	atomic.AddInt64(&c.misses, 1)
+	generation := c.generation(req.OrganizationId)
+	res, err := authorize(req)
+	if err != nil {
+		if _, denied := err.(*domain.AuthError); !denied {
+			return nil, err
+		}
+	}
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if c.generations[req.OrganizationId] == generation {
+		c.cache.Add(key, &cachedDecision{organizationId: req.OrganizationId, res: res, err: err})
+	}
+	return res, err
+}
+
+// Invalidate removes cached decisions of the organization.
+func (c *DecisionCache) Invalidate(organizationId string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.generations[organizationId]++
+	atomic.AddInt64(&c.invalidations, 1)
+	for _, key := range c.cache.Keys() {
+		// expired entries that are not yet removed are returned without value
+		if decision, ok := c.cache.Peek(key); ok && decision != nil && decision.organizationId == organizationId {
+			c.cache.Remove(key)
+		}
+	}
+}
+
+// Purge removes all cached decisions.
+func (c *DecisionCache) Purge() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for org := range c.generations {
+		c.generations[org]++
+	}
+	atomic.AddInt64(&c.invalidations, 1)
+	c.cache.Purge()
+}
+
+// Stats returns hit and miss statistics of the cache.
+func (c *DecisionCache) Stats() DecisionCacheStats {
+	return DecisionCacheStats{
+		Hits:          atomic.LoadInt64(&c.hits),
+		Misses:        atomic.LoadInt64(&c.misses),
+		Invalidations: atomic.LoadInt64(&c.invalidations),
+		Size:          c.cache.Len(),
+	}
+}
+
+// Watch streams change events of the organization from the server and invalidates its cached decisions
+// until the context is cancelled. The watch is resumed from the last cursor after disconnects, and
+// decisions are invalidated as changes may have been missed in the meantime.
+func (c *DecisionCache) Watch(
+	ctx context.Context,
+	watchClient services.WatchServiceClient,
+	organizationId string,
+	reconnectInterval time.Duration,
+) {
+	watchChanges(ctx, watchClient, organizationId, reconnectInterval,
+		func(event *types.ChangeEvent) {
+			c.Invalidate(event.OrganizationId)
+		},
+		func() {
+			c.Invalidate(organizationId)
+		})
+}
+
+func (c *DecisionCache) generation(organizationId string) int64 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.generations[organizationId]
+}
+
+func decisionKey(req *services.AuthRequest) string {
+	return strings.Join([]string{
+		req.OrganizationId,
+		req.Namespace,
+		req.PrincipalId,
+		req.Resource,
+		req.Action,
+		req.Scope,
+		contextHash(req.Context),
+	}, "\x00")
+}
+
+func contextHash(context map[string]string) string {
+	if len(context) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(context))
+	for k := range context {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	for _, k := range keys {
+		h.Write([]byte(k))
+		h.Write([]byte{0})
+		h.Write([]byte(context[k]))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,176 @@
+package client
+
+import (
+	"context"
+	"github.com/bhatti/PlexAuthZ/api/v1/services"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_ShouldCacheDecisions(t *testing.T) {
+	// GIVEN adapter with decision cache and authorizer that permits reading
+	authorizer := &countingAuthorizer{}
+	authAdapter := New(authorizer, nil, WithDecisionCache(time.Minute, 100))
+	principal := &PrincipalAdapter{
+		authorizer:    authorizer,
+		decisionCache: authAdapter.DecisionCache,
+		Principal:     &types.Principal{OrganizationId: "org", Id: "alice"},
+	}
+
+	// WHEN checking same request repeatedly
+	for i := 0; i < 5; i++ {
+		require.NoError(t, principal.Authorizer("ns").WithAction("read").
+			WithResourceName("report").WithContext("ip", "1.1.1.1").Check())
+	}
+
+	// THEN authorizer should be invoked once
+	require.Equal(t, int64(1), atomic.LoadInt64(&authorizer.calls))
+	stats := authAdapter.DecisionCache.Stats()
+	require.Equal(t, int64(4), stats.Hits)
+	require.Equal(t, int64(1), stats.Misses)
+
+	// AND denied decisions should be cached with different context and action
+	require.Error(t, principal.Authorizer("ns").WithAction("write").WithResourceName("report").Check())
+	require.Error(t, principal.Authorizer("ns").WithAction("write").WithResourceName("report").Check())
+	require.NoError(t, principal.Authorizer("ns").WithAction("read").
+		WithResourceName("report").WithContext("ip", "2.2.2.2").Check())
+	require.Equal(t, int64(3), atomic.LoadInt64(&authorizer.calls))
+
+	// WHEN server signals a change of the organization
+	watchClient := &fakeWatchClient{events: make(chan *types.ChangeEvent, 1)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	authAdapter.DecisionCache.Watch(ctx, watchClient, "org", time.Second)
+	watchClient.events <- &types.ChangeEvent{Cursor: 1, OrganizationId: "org", EntityType: types.ChangeEntityType_ROLE}
+
+	// THEN cached decisions should be invalidated
+	require.Eventually(t, func() bool {
+		return authAdapter.DecisionCache.Stats().Size == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, principal.Authorizer("ns").WithAction("read").
+		WithResourceName("report").WithContext("ip", "1.1.1.1").Check())
+	require.Equal(t, int64(4), atomic.LoadInt64(&authorizer.calls))
+}
+
+func Test_ShouldInvalidateDecisionsAfterTTL(t *testing.T) {
+	// GIVEN decision cache with short ttl and a cached decision
+	cache := NewDecisionCache(50*time.Millisecond, 100)
+	req := &services.AuthRequest{OrganizationId: "org", PrincipalId: "alice", Action: "read", Resource: "report"}
+	_, err := cache.Authorize(req, func(*services.AuthRequest) (*services.AuthResponse, error) {
+		return &services.AuthResponse{Effect: types.Effect_PERMITTED}, nil
+	})
+	require.NoError(t, err)
+
+	// WHEN invalidating other organization while the decision expires
+	// THEN expired entries that are not yet removed should be skipped
+	require.Eventually(t, func() bool {
+		cache.Invalidate("other")
+		return cache.Stats().Size == 0
+	}, 5*time.Second, time.Microsecond)
+}
+
+func Test_ShouldResetRejectedWatchCursor(t *testing.T) {
+	// GIVEN decision cache with a cached decision and watch of server that rejects resumed cursors
+	authorizer := &countingAuthorizer{}
+	authAdapter := New(authorizer, nil, WithDecisionCache(time.Minute, 100))
+	principal := &PrincipalAdapter{
+		authorizer:    authorizer,
+		decisionCache: authAdapter.DecisionCache,
+		Principal:     &types.Principal{OrganizationId: "org", Id: "alice"},
+	}
+	watchClient := &fakeWatchClient{
+		events:       make(chan *types.ChangeEvent, 1),
+		cursors:      make(chan int64, 10),
+		rejectCursor: true,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	authAdapter.DecisionCache.Watch(ctx, watchClient, "org", 10*time.Millisecond)
+	require.Equal(t, int64(0), <-watchClient.cursors)
+	watchClient.events <- &types.ChangeEvent{Cursor: 5, OrganizationId: "org", EntityType: types.ChangeEntityType_ROLE}
+	require.Eventually(t, func() bool {
+		return authAdapter.DecisionCache.Stats().Invalidations == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, principal.Authorizer("ns").WithAction("read").WithResourceName("report").Check())
+	require.Equal(t, 1, authAdapter.DecisionCache.Stats().Size)
+
+	// WHEN the stream disconnects and the server rejects cursor of the last change
+	watchClient.events <- nil
+
+	// THEN cursor should be reset and cached decisions flushed
+	require.Equal(t, int64(5), <-watchClient.cursors)
+	require.Equal(t, int64(0), <-watchClient.cursors)
+	require.Eventually(t, func() bool {
+		return authAdapter.DecisionCache.Stats().Size == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+type countingAuthorizer struct {
+	calls int64
+}
+
+func (a *countingAuthorizer) Authorize(
+	_ context.Context,
+	req *services.AuthRequest,
+) (*services.AuthResponse, error) {
+	atomic.AddInt64(&a.calls, 1)
+	if req.Action != "read" {
+		return nil, domain.NewAuthError("denied")
+	}
+	return &services.AuthResponse{Effect: types.Effect_PERMITTED}, nil
+}
+
+func (a *countingAuthorizer) Check(
+	_ context.Context,
+	_ *services.CheckConstraintsRequest,
+) (*services.CheckConstraintsResponse, error) {
+	return &services.CheckConstraintsResponse{Matched: true}, nil
+}
+
+type fakeWatchClient struct {
+	events chan *types.ChangeEvent
+	// cursors records cursors of watch requests when defined
+	cursors chan int64
+	// rejectCursor rejects watches that resume from a cursor when defined
+	rejectCursor bool
+}
+
+func (c *fakeWatchClient) Watch(
+	ctx context.Context,
+	req *services.WatchRequest,
+	_ ...grpc.CallOption,
+) (services.WatchService_WatchClient, error) {
+	if c.cursors != nil {
+		c.cursors <- req.Cursor
+	}
+	if c.rejectCursor && req.Cursor > 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "cursor %d is not available", req.Cursor)
+	}
+	return &fakeWatchStream{ctx: ctx, events: c.events}, nil
+}
+
+type fakeWatchStream struct {
+	grpc.ClientStream
+	ctx    context.Context
+	events chan *types.ChangeEvent
+}
+
+func (s *fakeWatchStream) Recv() (*services.WatchResponse, error) {
+	select {
+	case <-s.ctx.Done():
+		return nil, s.ctx.Err()
+	case event := <-s.events:
+		if event == nil {
+			return nil, io.EOF
+		}
+		return &services.WatchResponse{Event: event}, nil
+	}
+}
@@ -0,0 +1,295 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bhatti/PlexAuthZ/api/v1/services"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/authz"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/service"
+	"github.com/hashicorp/golang-lru/v2"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultSnapshotRefreshInterval = 30 * time.Second
+	defaultMaxSnapshots            = 10000
+	defaultSnapshotMaxStaleness    = 5 * time.Minute
+	// snapshots that were not used for these many refresh intervals are removed instead of refreshed.
+	snapshotIdleRefreshes = 10
+)
+
+// WithLocalEvaluation evaluates Authorize and Check within the process using snapshots of fully resolved
+// principals, which are fetched once and refreshed in background every refresh interval. At most max-snapshots
+// principals are kept in LRU order, and requests of a principal are denied when its snapshot is older than
+// max-staleness and cannot be refreshed.
+func WithLocalEvaluation(refreshInterval time.Duration, maxSnapshots int, maxStaleness time.Duration) Option {
+	return func(adapter *AuthAdapter) {
+		adapter.LocalAuthorizer = NewLocalAuthorizer(adapter.authAdminService, refreshInterval, maxSnapshots, maxStaleness)
+		adapter.authorizer = adapter.LocalAuthorizer
+	}
+}
+
+// LocalAuthorizer evaluates authorization requests against snapshots of principals, which include their
+// groups, roles, resources, permissions and relations. Snapshots are refreshed in background or on change
+// notifications, and the last snapshot is used when the server cannot be reached until it's older than
+// max-staleness. Snapshots that are not used for a while are removed instead of refreshed.
+type LocalAuthorizer struct {
+	authz.Authorizer
+	authAdminService service.AuthAdminService
+	snapshots        *lru.Cache[snapshotKey, *snapshot]
+	refreshInterval  time.Duration
+	maxStaleness     time.Duration
+	done             chan bool
+	closeOnce        sync.Once
+}
+
+type snapshotKey struct {
+	organizationId string
+	namespace      string
+	principalId    string
+}
+
+type snapshot struct {
+	// used is unix nanos of the last authorization with the snapshot, which is accessed atomically.
+	used      int64
+	principal *domain.PrincipalExt
+	fetched   time.Time
+}
+
+// NewLocalAuthorizer constructor, which starts refreshing snapshots in background until Close.
+func NewLocalAuthorizer(
+	authAdminService service.AuthAdminService,
+	refreshInterval time.Duration,
+	maxSnapshots int,
+	maxStaleness time.Duration,
+) *LocalAuthorizer {
+	if refreshInterval <= 0 {
+		refreshInterval = defaultSnapshotRefreshInterval
+	}
+	if maxSnapshots <= 0 {
+		maxSnapshots = defaultMaxSnapshots
+	}
+	if maxStaleness <= 0 {
+		maxStaleness = defaultSnapshotMaxStaleness
+	}
+	snapshots, _ := lru.New[snapshotKey, *snapshot](maxSnapshots) // only fails for non-positive size
+	a := &LocalAuthorizer{
+		authAdminService: authAdminService,
+		snapshots:        snapshots,
+		refreshInterval:  refreshInterval,
+		maxStaleness:     maxStaleness,
+		done:             make(chan bool),
+	}
+	a.Authorizer = authz.NewDefaultAuthorizer(&snapshotPrincipalService{
+		AuthAdminService: authAdminService,
+		authorizer:       a,
+	})
+	go a.refreshPeriodically()
+	return a
+}
+
+// Refresh fetches snapshots of principals in the organization again, e.g., after reconnecting to the server.
+func (a *LocalAuthorizer) Refresh(ctx context.Context, organizationId string) {
+	a.refreshMatching(ctx, func(key snapshotKey, _ *domain.PrincipalExt) bool {
+		return organizationId == "" || key.organizationId == organizationId
+	})
+}
+
+// RefreshChanged fetches snapshots of principals affected by the change event again, i.e., the changed
+// principal or principals that include the changed group, role, permission, resource or relationship.
+func (a *LocalAuthorizer) RefreshChanged(ctx context.Context, event *types.ChangeEvent) {
+	a.refreshMatching(ctx, func(key snapshotKey, principal *domain.PrincipalExt) bool {
+		return key.organizationId == event.OrganizationId && affectedBy(principal, event)
+	})
+}
+
+// Watch refreshes snapshots affected by changes of the organization that are signaled by the server until
+// the context is cancelled, where all snapshots of the organization are refreshed after disconnects as
+// changes may have been missed.
+func (a *LocalAuthorizer) Watch(
+	ctx context.Context,
+	watchClient services.WatchServiceClient,
+	organizationId string,
+	reconnectInterval time.Duration,
+) {
+	watchChanges(ctx, watchClient, organizationId, reconnectInterval,
+		func(event *types.ChangeEvent) {
+			a.RefreshChanged(ctx, event)
+		},
+		func() {
+			a.Refresh(ctx, organizationId)
+		})
+}
+
+// Close stops refreshing snapshots.
+func (a *LocalAuthorizer) Close() {
+	a.closeOnce.Do(func() {
+		close(a.done)
+	})
+}
+
+// principal returns snapshot of the principal, which is fetched when it's not available, older than
+// max-staleness or older than the revision of consistency token in the context.
+func (a *LocalAuthorizer) principal(
+	ctx context.Context,
+	key snapshotKey,
+) (*domain.PrincipalExt, error) {
+	now := time.Now()
+	if last, ok := a.snapshots.Get(key); ok {
+		atomic.StoreInt64(&last.used, now.UnixNano())
+		if last.principal.Revision >= domain.MinRevision(ctx) && now.Sub(last.fetched) < a.maxStaleness {
+			return last.principal, nil
+		}
+	}
+	return a.refresh(ctx, key)
+}
+
+func (a *LocalAuthorizer) refresh(
+	ctx context.Context,
+	key snapshotKey,
+) (*domain.PrincipalExt, error) {
+	revision := domain.NewRevision()
+	fetched := time.Now()
+	principal, err := a.authAdminService.GetPrincipalExt(ctx, key.organizationId, key.namespace, key.principalId)
+	last, _ := a.snapshots.Peek(key)
+	if err != nil {
+		if isNotFound(err) {
+			// principal was deleted so last snapshot must not be used for authorization
+			a.snapshots.Remove(key)
+			return nil, err
+		}
+		if last == nil {
+			return nil, err
+		}
+		if time.Since(last.fetched) >= a.maxStaleness {
+			return nil, domain.NewAuthError(fmt.Sprintf(
+				"snapshot of principal %s is older than %s and cannot be refreshed due to %s",
+				key.principalId, a.maxStaleness, err))
+		}
+		log.WithFields(log.Fields{
+			"Component":    "LocalAuthorizer",
+			"Organization": key.organizationId,
+			"Principal":    key.principalId,
+			"Error":        err,
+		}).Warnf("failed to refresh principal, using last snapshot")
+		return last.principal, nil
+	}
+	if principal.Revision == 0 {
+		// remote services don't return revision of the principal
+		principal.Revision = revision
+	}
+	used := fetched.UnixNano()
+	if last != nil {
+		used = atomic.LoadInt64(&last.used)
+	}
+	a.snapshots.Add(key, &snapshot{principal: principal, fetched: fetched, used: used})
+	return principal, nil
+}
+
+// affectedBy returns true if the principal is changed or it includes the changed entity, where other
+// entities such as resource instances are not part of snapshots.
+func affectedBy(principal *domain.PrincipalExt, event *types.ChangeEvent) bool {
+	switch event.EntityType {
+	case types.ChangeEntityType_ORGANIZATION:
+		return true
+	case types.ChangeEntityType_PRINCIPAL:
+		return principal.Delegate.Id == event.EntityId
+	case types.ChangeEntityType_GROUP:
+		for _, group := range principal.GroupsByName {
+			if group.Id == event.EntityId {
+				return true
+			}
+		}
+	case types.ChangeEntityType_ROLE:
+		for _, role := range principal.RolesByName {
+			if role.Id == event.EntityId {
+				return true
+			}
+		}
+	case types.ChangeEntityType_PERMISSION:
+		for _, perms := range principal.PermissionsByResourceName {
+			if perms[event.EntityId] != nil {
+				return true
+			}
+		}
+	case types.ChangeEntityType_RESOURCE:
+		return principal.ResourcesById[event.EntityId] != nil
+	case types.ChangeEntityType_RELATIONSHIP:
+		return principal.RelationsById[event.EntityId] != nil
+	}
+	return false
+}
+
+// isNotFound returns true for not-found errors of the local services and gRPC status of remote services.
+func isNotFound(err error) bool {
+	var notFoundErr *domain.NotFoundError
+	return errors.As(err, &notFoundErr) || status.Code(err) == codes.NotFound
+}
+
+func (a *LocalAuthorizer) refreshMatching(
+	ctx context.Context,
+	matches func(key snapshotKey, principal *domain.PrincipalExt) bool,
+) {
+	for _, key := range a.snapshots.Keys() {
+		if last, ok := a.snapshots.Peek(key); ok && matches(key, last.principal) {
+			_, _ = a.refresh(ctx, key)
+		}
+	}
+}
+
+// refreshUsed refreshes snapshots that were used recently and removes the others.
+func (a *LocalAuthorizer) refreshUsed(ctx context.Context) {
+	idle := time.Now().Add(-snapshotIdleRefreshes * a.refreshInterval).UnixNano()
+	for _, key := range a.snapshots.Keys() {
+		last, ok := a.snapshots.Peek(key)
+		if !ok {
+			continue
+		}
+		if atomic.LoadInt64(&last.used) < idle {
+			a.snapshots.Remove(key)
+			continue
+		}
+		_, _ = a.refresh(ctx, key)
+	}
+}
+
+func (a *LocalAuthorizer) refreshPeriodically() {
+	ticker := time.NewTicker(a.refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.done:
+			return
+		case <-ticker.C:
+			a.refreshUsed(context.Background())
+		}
+	}
+}
+
+// snapshotPrincipalService serves principals from snapshots of the local authorizer.
+type snapshotPrincipalService struct {
+	service.AuthAdminService
+	authorizer *LocalAuthorizer
+}
+
+// GetPrincipalExt returns snapshot of the principal.
+func (s *snapshotPrincipalService) GetPrincipalExt(
+	ctx context.Context,
+	organizationId string,
+	namespace string,
+	principalId string,
+) (*domain.PrincipalExt, error) {
+	return s.authorizer.principal(ctx, snapshotKey{
+		organizationId: organizationId,
+		namespace:      namespace,
+		principalId:    principalId,
+	})
+}
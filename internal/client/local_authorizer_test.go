@@ -0,0 +1,216 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/metrics"
+	"github.com/bhatti/PlexAuthZ/internal/service"
+	"github.com/bhatti/PlexAuthZ/internal/service/db"
+	"github.com/stretchr/testify/require"
+	"github.com/twinj/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_ShouldEvaluateLocallyWithSnapshots(t *testing.T) {
+	// GIVEN adapter with local evaluation and organization with a principal that can read reports
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	dbService, _, err := db.CreateDatabaseAuthService(cfg, metrics.New())
+	require.NoError(t, err)
+	authService := &flakyPrincipalService{AuthAdminService: dbService}
+	authAdapter := New(nil, authService, WithLocalEvaluation(time.Hour, 0, 0))
+	defer authAdapter.LocalAuthorizer.Close()
+	orgAdapter, err := authAdapter.CreateOrganization(&types.Organization{
+		Name:       "local-" + uuid.NewV4().String(),
+		Namespaces: []string{"finance"},
+	})
+	require.NoError(t, err)
+	namespace := orgAdapter.Organization.Namespaces[0]
+	report, err := orgAdapter.Resources(namespace).WithName("report").WithActions("read", "write").Create()
+	require.NoError(t, err)
+	read, err := orgAdapter.Permissions(namespace).WithResource(report.Resource).WithActions("read").Create()
+	require.NoError(t, err)
+	reader, err := orgAdapter.Roles(namespace).WithName("reader").Create()
+	require.NoError(t, err)
+	require.NoError(t, reader.AddPermissions(read.Permission))
+	alice, err := orgAdapter.Principals().WithUsername("alice").Create()
+	require.NoError(t, err)
+	require.NoError(t, alice.AddRoles(reader.Role))
+
+	// WHEN checking access repeatedly
+	for i := 0; i < 5; i++ {
+		require.NoError(t, alice.Authorizer(namespace).WithAction("read").WithResource(report.Resource).Check())
+	}
+	require.Error(t, alice.Authorizer(namespace).WithAction("write").WithResource(report.Resource).Check())
+
+	// THEN principal should be fetched once
+	require.Equal(t, int64(1), atomic.LoadInt64(&authService.calls))
+
+	// WHEN granting write and refreshing snapshots after change notification
+	write, err := orgAdapter.Permissions(namespace).WithResource(report.Resource).WithActions("write").Create()
+	require.NoError(t, err)
+	require.NoError(t, reader.AddPermissions(write.Permission))
+	authAdapter.LocalAuthorizer.Refresh(context.Background(), orgAdapter.Organization.Id)
+
+	// THEN updated snapshot should be evaluated
+	require.NoError(t, alice.Authorizer(namespace).WithAction("write").WithResource(report.Resource).Check())
+
+	// AND last snapshot should be used when server cannot be reached
+	atomic.StoreInt32(&authService.down, 1)
+	authAdapter.LocalAuthorizer.Refresh(context.Background(), orgAdapter.Organization.Id)
+	require.NoError(t, alice.Authorizer(namespace).WithAction("read").WithResource(report.Resource).Check())
+
+	// WHEN principal is deleted and remote server reports it as not found
+	atomic.StoreInt32(&authService.down, 0)
+	require.NoError(t, alice.Delete())
+	atomic.StoreInt32(&authService.remote, 1)
+	authAdapter.LocalAuthorizer.Refresh(context.Background(), orgAdapter.Organization.Id)
+
+	// THEN last snapshot should be dropped and access should be denied
+	require.Error(t, alice.Authorizer(namespace).WithAction("read").WithResource(report.Resource).Check())
+}
+
+func Test_ShouldRefreshSnapshotsAffectedByChanges(t *testing.T) {
+	// GIVEN adapter with local evaluation of two snapshots and principals with and without a role
+	ctx := context.Background()
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	dbService, _, err := db.CreateDatabaseAuthService(cfg, metrics.New())
+	require.NoError(t, err)
+	authService := &flakyPrincipalService{AuthAdminService: dbService}
+	authAdapter := New(nil, authService, WithLocalEvaluation(time.Hour, 2, 0))
+	defer authAdapter.LocalAuthorizer.Close()
+	orgAdapter, err := authAdapter.CreateOrganization(&types.Organization{
+		Name:       "local-" + uuid.NewV4().String(),
+		Namespaces: []string{"finance"},
+	})
+	require.NoError(t, err)
+	orgId := orgAdapter.Organization.Id
+	namespace := orgAdapter.Organization.Namespaces[0]
+	report, err := orgAdapter.Resources(namespace).WithName("report").WithActions("read").Create()
+	require.NoError(t, err)
+	read, err := orgAdapter.Permissions(namespace).WithResource(report.Resource).WithActions("read").Create()
+	require.NoError(t, err)
+	reader, err := orgAdapter.Roles(namespace).WithName("reader").Create()
+	require.NoError(t, err)
+	require.NoError(t, reader.AddPermissions(read.Permission))
+	alice, err := orgAdapter.Principals().WithUsername("alice").Create()
+	require.NoError(t, err)
+	require.NoError(t, alice.AddRoles(reader.Role))
+	bob, err := orgAdapter.Principals().WithUsername("bob").Create()
+	require.NoError(t, err)
+	require.NoError(t, alice.Authorizer(namespace).WithAction("read").WithResource(report.Resource).Check())
+	require.Error(t, bob.Authorizer(namespace).WithAction("read").WithResource(report.Resource).Check())
+	require.Equal(t, int64(2), atomic.LoadInt64(&authService.calls))
+
+	// WHEN principal is changed
+	authAdapter.LocalAuthorizer.RefreshChanged(ctx, &types.ChangeEvent{
+		OrganizationId: orgId, EntityType: types.ChangeEntityType_PRINCIPAL, EntityId: bob.Principal.Id})
+	// THEN only its snapshot should be refreshed
+	require.Equal(t, int64(3), atomic.LoadInt64(&authService.calls))
+
+	// WHEN role is changed
+	authAdapter.LocalAuthorizer.RefreshChanged(ctx, &types.ChangeEvent{
+		OrganizationId: orgId, EntityType: types.ChangeEntityType_ROLE, EntityId: reader.Role.Id})
+	// THEN only snapshot of the principal with the role should be refreshed
+	require.Equal(t, int64(4), atomic.LoadInt64(&authService.calls))
+
+	// WHEN entities that are not part of snapshots or other organizations are changed
+	authAdapter.LocalAuthorizer.RefreshChanged(ctx, &types.ChangeEvent{
+		OrganizationId: orgId, EntityType: types.ChangeEntityType_RESOURCE_INSTANCE, EntityId: report.Resource.Id})
+	authAdapter.LocalAuthorizer.RefreshChanged(ctx, &types.ChangeEvent{
+		OrganizationId: "other", EntityType: types.ChangeEntityType_ORGANIZATION, EntityId: "other"})
+	// THEN no snapshots should be refreshed
+	require.Equal(t, int64(4), atomic.LoadInt64(&authService.calls))
+
+	// WHEN organization is changed
+	authAdapter.LocalAuthorizer.RefreshChanged(ctx, &types.ChangeEvent{
+		OrganizationId: orgId, EntityType: types.ChangeEntityType_ORGANIZATION, EntityId: orgId})
+	// THEN all snapshots of the organization should be refreshed
+	require.Equal(t, int64(6), atomic.LoadInt64(&authService.calls))
+
+	// WHEN authorizing more principals than max snapshots
+	carol, err := orgAdapter.Principals().WithUsername("carol").Create()
+	require.NoError(t, err)
+	require.Error(t, carol.Authorizer(namespace).WithAction("read").WithResource(report.Resource).Check())
+	// THEN least recently used snapshot should be evicted
+	require.Equal(t, 2, authAdapter.LocalAuthorizer.snapshots.Len())
+}
+
+func Test_ShouldDenyWithStaleSnapshotsAndRemoveIdleSnapshots(t *testing.T) {
+	// GIVEN adapter with local evaluation of snapshots refreshed frequently and a principal that can read reports
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	dbService, _, err := db.CreateDatabaseAuthService(cfg, metrics.New())
+	require.NoError(t, err)
+	authService := &flakyPrincipalService{AuthAdminService: dbService}
+	authAdapter := New(nil, authService, WithLocalEvaluation(20*time.Millisecond, 0, 200*time.Millisecond))
+	defer authAdapter.LocalAuthorizer.Close()
+	orgAdapter, err := authAdapter.CreateOrganization(&types.Organization{
+		Name:       "local-" + uuid.NewV4().String(),
+		Namespaces: []string{"finance"},
+	})
+	require.NoError(t, err)
+	namespace := orgAdapter.Organization.Namespaces[0]
+	report, err := orgAdapter.Resources(namespace).WithName("report").WithActions("read").Create()
+	require.NoError(t, err)
+	read, err := orgAdapter.Permissions(namespace).WithResource(report.Resource).WithActions("read").Create()
+	require.NoError(t, err)
+	alice, err := orgAdapter.Principals().WithUsername("alice").Create()
+	require.NoError(t, err)
+	require.NoError(t, alice.AddPermissions(read.Permission))
+	check := func() error {
+		return alice.Authorizer(namespace).WithAction("read").WithResource(report.Resource).Check()
+	}
+	require.NoError(t, check())
+
+	// WHEN server cannot be reached
+	atomic.StoreInt32(&authService.down, 1)
+	// THEN last snapshot should be used until it's older than max staleness
+	require.NoError(t, check())
+	require.Eventually(t, func() bool {
+		return check() != nil
+	}, 2*time.Second, 20*time.Millisecond)
+
+	// WHEN server is reachable again
+	atomic.StoreInt32(&authService.down, 0)
+	// THEN snapshot should be refreshed
+	require.NoError(t, check())
+
+	// AND snapshot should be removed when it's not used
+	require.Eventually(t, func() bool {
+		return authAdapter.LocalAuthorizer.snapshots.Len() == 0
+	}, 2*time.Second, 20*time.Millisecond)
+}
+
+type flakyPrincipalService struct {
+	service.AuthAdminService
+	calls  int64
+	down   int32
+	remote int32
+}
+
+func (s *flakyPrincipalService) GetPrincipalExt(
+	ctx context.Context,
+	organizationId string,
+	namespace string,
+	principalId string,
+) (*domain.PrincipalExt, error) {
+	atomic.AddInt64(&s.calls, 1)
+	if atomic.LoadInt32(&s.down) == 1 {
+		return nil, errors.New("server unavailable")
+	}
+	principal, err := s.AuthAdminService.GetPrincipalExt(ctx, organizationId, namespace, principalId)
+	var notFoundErr *domain.NotFoundError
+	if atomic.LoadInt32(&s.remote) == 1 && errors.As(err, &notFoundErr) {
+		// gRPC services return status errors
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return principal, err
+}
@@ -0,0 +1,67 @@
+package client
+
+import (
+	"context"
+	"github.com/bhatti/PlexAuthZ/api/v1/services"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+// watchChanges streams change events of the organization in background until the context is cancelled,
+// where the watch is resumed from the last cursor after disconnects and disconnected is invoked because
+// changes may have been missed in the meantime. A cursor rejected by the server, e.g., after it's evicted
+// from the changes retained by the server or the server restarted, is reset so that the watch starts from
+// the latest change.
+func watchChanges(
+	ctx context.Context,
+	watchClient services.WatchServiceClient,
+	organizationId string,
+	reconnectInterval time.Duration,
+	changed func(event *types.ChangeEvent),
+	disconnected func(),
+) {
+	go func() {
+		var cursor int64
+		for ctx.Err() == nil {
+			stream, err := watchClient.Watch(ctx, &services.WatchRequest{
+				OrganizationId: organizationId,
+				Cursor:         cursor,
+			})
+			if err == nil {
+				for {
+					var res *services.WatchResponse
+					if res, err = stream.Recv(); err != nil {
+						break
+					}
+					if res.Event == nil {
+						continue
+					}
+					cursor = res.Event.Cursor
+					changed(res.Event)
+				}
+			}
+			if status.Code(err) == codes.InvalidArgument {
+				// changes since the cursor are not available so callers must discard derived state
+				cursor = 0
+			}
+			disconnected()
+			if ctx.Err() != nil {
+				return
+			}
+			log.WithFields(log.Fields{
+				"Component":    "ClientWatch",
+				"Organization": organizationId,
+				"Cursor":       cursor,
+				"Error":        err,
+			}).Warnf("watch of changes disconnected")
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(reconnectInterval):
+			}
+		}
+	}()
+}
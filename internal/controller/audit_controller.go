@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"github.com/bhatti/PlexAuthZ/internal/audit"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/metrics"
+	"github.com/bhatti/PlexAuthZ/internal/service/db"
+	"github.com/bhatti/PlexAuthZ/internal/web"
+	"net/http"
+)
+
+// AuditController - exports audit records of administrative changes for compliance
+type AuditController struct {
+	config  *domain.Config
+	auditor *audit.Auditor
+}
+
+// NewAuditController instantiates controller for exporting audit records
+func NewAuditController(
+	config *domain.Config,
+	metricsRegistry *metrics.Registry,
+	webserver web.Server) (*AuditController, error) {
+	ctrl := &AuditController{
+		config: config,
+	}
+	if config.Audit.Enabled {
+		auditor, err := db.CreateAuditor(config, metricsRegistry)
+		if err != nil {
+			return nil, err
+		}
+		ctrl.auditor = auditor
+	}
+	webserver.GET("/api/v1/:organization_id/audit", ctrl.query)
+	return ctrl, nil
+}
+
+// query handler
+func (ctr *AuditController) query(c web.APIContext) (err error) {
+	if ctr.auditor == nil {
+		return domain.NewValidationError("audit is not enabled")
+	}
+	_, _, limit := toPredicates(c)
+	res, err := ctr.auditor.Query(
+		subjectContext(c),
+		c.Param("organization_id"),
+		c.QueryParam("namespace"),
+		c.QueryParam("entity_type"),
+		c.QueryParam("entity_id"),
+		c.QueryParam("caller"),
+		c.QueryParam("action"),
+		nil,
+		limit,
+	)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"encoding/json"
+	"github.com/bhatti/PlexAuthZ/api/v1/services"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/audit"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func Test_ShouldQueryAuditRecordsOfRESTChanges(t *testing.T) {
+	// GIVEN web server with audit of changes and api keys
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	cfg.Dir = "../../config"
+	cfg.HttpListenPort = "127.0.0.1:17783"
+	cfg.Audit.Enabled = true
+	cfg.HttpAuth.Enabled = true
+	cfg.HttpAuth.APIKeys = []domain.APIKeyConfig{{Key: "root-key", Subject: "root"}}
+	_, teardown := SetupWebServerForTesting(t, cfg, nil)
+	defer teardown()
+	client := &http.Client{}
+	baseURL := "http://" + cfg.HttpListenPort + "/api/v1/"
+	headers := map[string]string{"X-API-Key": "root-key"}
+
+	// WHEN creating organization and role
+	status, body := invokeTestAPI(t, client, http.MethodPost, baseURL+"organizations", headers,
+		[]byte(`{"name": "audit-org", "namespaces": ["admin"]}`))
+	require.Equal(t, http.StatusOK, status)
+	orgRes := &services.CreateOrganizationResponse{}
+	require.NoError(t, json.Unmarshal(body, orgRes))
+	status, _ = invokeTestAPI(t, client, http.MethodPost, baseURL+orgRes.Id+"/admin/roles", headers,
+		[]byte(`{"name": "reader"}`))
+	require.Equal(t, http.StatusOK, status)
+
+	// THEN role creation should be recorded with caller of the api key
+	status, body = invokeTestAPI(t, client, http.MethodGet, baseURL+orgRes.Id+"/audit?entity_type=Role", headers, nil)
+	require.Equal(t, http.StatusOK, status)
+	var records []*types.AuditRecord
+	require.NoError(t, json.Unmarshal(body, &records))
+	require.Len(t, records, 1)
+	require.Equal(t, audit.CreateAction, records[0].Action)
+	require.Equal(t, "root", records[0].Caller)
+	require.Contains(t, records[0].Diff, `"name":{"after":"reader"}`)
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/bhatti/PlexAuthZ/internal/domain"
 	"github.com/bhatti/PlexAuthZ/internal/service"
 	"github.com/bhatti/PlexAuthZ/internal/web"
+	"google.golang.org/protobuf/types/known/timestamppb"
 	"io"
 	"net/http"
 	"time"
@@ -37,6 +38,7 @@ func NewAuthController(
 	webserver.POST("/api/v1/:organization_id/:namespace/:principal_id/auth", ctrl.auth)
 	webserver.POST("/api/v1/:organization_id/:namespace/:principal_id/auth/constraints", ctrl.check)
 	webserver.PUT("/api/v1/:organization_id/:namespace/resources/:id/allocate/:principal_id", ctrl.allocate)
+	webserver.PUT("/api/v1/:organization_id/:namespace/resources/:id/renew/:principal_id", ctrl.renew)
 	webserver.PUT("/api/v1/:organization_id/:namespace/resources/:id/deallocate/:principal_id", ctrl.deallocate)
 	return ctrl, nil
 }
@@ -56,7 +58,7 @@ func (ctr *AuthController) auth(c web.APIContext) error {
 	req.PrincipalId = c.Param("principal_id")
 
 	res, err := ctr.authorizer.Authorize(
-		context.Background(),
+		subjectContext(c),
 		req)
 
 	if err != nil {
@@ -81,7 +83,7 @@ func (ctr *AuthController) check(c web.APIContext) error {
 	req.PrincipalId = c.Param("principal_id")
 
 	res, err := ctr.authorizer.Check(
-		context.Background(),
+		subjectContext(c),
 		req)
 
 	if err != nil {
@@ -91,6 +93,12 @@ func (ctr *AuthController) check(c web.APIContext) error {
 	return c.JSON(http.StatusOK, res)
 }
 
+// subjectContext returns context with the authenticated subject of the request.
+func subjectContext(c web.APIContext) context.Context {
+	subject, _ := c.Get(web.SubjectKey).(string)
+	return authz.WithSubject(context.Background(), subject)
+}
+
 // allocate handler
 func (ctr *AuthController) allocate(c web.APIContext) (err error) {
 	req := &services.AllocateResourceRequest{}
@@ -102,7 +110,7 @@ func (ctr *AuthController) allocate(c web.APIContext) (err error) {
 		expiry = req.Expiry.AsDuration()
 	}
 
-	err = ctr.authService.AllocateResourceInstance(
+	expiresAt, err := ctr.authService.AllocateResourceInstance(
 		context.Background(),
 		c.Param("organization_id"),
 		c.Param("namespace"),
@@ -115,7 +123,38 @@ func (ctr *AuthController) allocate(c web.APIContext) (err error) {
 	if err != nil {
 		return err
 	}
-	return c.JSON(http.StatusOK, &services.AllocateResourceResponse{})
+	return c.JSON(http.StatusOK, &services.AllocateResourceResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+		ExpiresAt:        timestamppb.New(expiresAt),
+	})
+}
+
+// renew handler
+func (ctr *AuthController) renew(c web.APIContext) (err error) {
+	req := &services.RenewAllocationRequest{}
+	if b, err := io.ReadAll(c.Request().Body); err == nil {
+		_ = json.Unmarshal(b, req)
+	}
+	var expiry time.Duration
+	if req.Expiry != nil {
+		expiry = req.Expiry.AsDuration()
+	}
+
+	expiresAt, err := ctr.authService.RenewAllocation(
+		context.Background(),
+		c.Param("organization_id"),
+		c.Param("namespace"),
+		c.Param("id"),
+		c.Param("principal_id"),
+		expiry,
+	)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, &services.RenewAllocationResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+		ExpiresAt:        timestamppb.New(expiresAt),
+	})
 }
 
 // deallocate handler
@@ -130,5 +169,7 @@ func (ctr *AuthController) deallocate(c web.APIContext) (err error) {
 	if err != nil {
 		return err
 	}
-	return c.JSON(http.StatusOK, &services.DeallocateResourceResponse{})
+	return c.JSON(http.StatusOK, &services.DeallocateResourceResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	})
 }
@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"context"
+	"github.com/bhatti/PlexAuthZ/api/v1/services"
+	"github.com/bhatti/PlexAuthZ/internal/authz"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/web"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strings"
+)
+
+const (
+	objectWildcard = "*"
+	updateAction   = "update"
+	queryAction    = "query"
+	deleteAction   = "delete"
+	authAction     = "auth"
+)
+
+// AuthorizationMiddleware authorizes the subject of authenticated requests with the same
+// actions as gRPC APIs, i.e., query for GET, delete for DELETE, auth for authorization
+// requests and update for other requests. The organization and namespace parameters of
+// the route must also be within scope of the subject.
+func AuthorizationMiddleware(authorizer authz.Authorizer) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			subject, _ := c.Get(web.SubjectKey).(string)
+			scope, _ := c.Get(web.ScopeKey).(*domain.ClientScope)
+			if _, err := authorizer.Authorize(
+				authz.WithScope(context.Background(), scope),
+				&services.AuthRequest{
+					PrincipalId:    subject,
+					OrganizationId: routeOrganization(c),
+					Namespace:      c.Param("namespace"),
+					Resource:       objectWildcard,
+					Action:         routeAction(c.Request().Method, c.Path()),
+				},
+			); err != nil {
+				return echo.NewHTTPError(http.StatusForbidden, err.Error())
+			}
+			return next(c)
+		}
+	}
+}
+
+func routeOrganization(c echo.Context) string {
+	if strings.HasPrefix(c.Path(), "/api/v1/organizations/") {
+		return c.Param("id")
+	}
+	return c.Param("organization_id")
+}
+
+func routeAction(method string, path string) string {
+	if strings.HasSuffix(path, "/auth") ||
+		strings.HasSuffix(path, "/auth/constraints") ||
+		strings.HasSuffix(path, "/kubernetes/authorize") {
+		return authAction
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		return queryAction
+	case http.MethodDelete:
+		return deleteAction
+	default:
+		return updateAction
+	}
+}
@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"github.com/bhatti/PlexAuthZ/api/v1/services"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/metrics"
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func Test_ShouldAuthorizeRESTAPIs(t *testing.T) {
+	// GIVEN web server with authentication of mtls, api keys and jwt tokens
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	cfg.Dir = "../../config"
+	cfg.HttpListenPort = "127.0.0.1:17780"
+	cfg.HttpAuth.Enabled = true
+	cfg.HttpAuth.MTLS = true
+	cfg.HttpAuth.APIKeys = []domain.APIKeyConfig{
+		{Key: "root-key", Subject: "root"},
+		{Key: "client-key", Subject: "client"},
+		{Key: "nobody-key", Subject: "nobody"},
+	}
+	cfg.HttpAuth.JWT.SigningKey = "secret"
+	_, teardown := SetupWebServerForTesting(t, cfg, nil)
+	defer teardown()
+	tlsConfig, err := cfg.SetupTLSServer(cfg.HttpListenPort, metrics.New())
+	require.NoError(t, err)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: tlsConfig.ClientCAs}}}
+	baseURL := "https://" + cfg.HttpListenPort + "/api/v1/"
+	orgsURL := baseURL + "organizations"
+	body := []byte(`{"name": "org-name", "namespaces": ["admin", "finance"]}`)
+	apiKey := func(key string) map[string]string {
+		return map[string]string{"X-API-Key": key}
+	}
+
+	// WHEN invoking APIs without api key
+	// THEN it should fail with unauthorized
+	status, _ := invokeTestAPI(t, client, http.MethodGet, orgsURL, nil, nil)
+	require.Equal(t, http.StatusUnauthorized, status)
+
+	// WHEN invoking APIs with nobody api key
+	// THEN it should fail with forbidden
+	status, _ = invokeTestAPI(t, client, http.MethodGet, orgsURL, apiKey("nobody-key"), nil)
+	require.Equal(t, http.StatusForbidden, status)
+	status, _ = invokeTestAPI(t, client, http.MethodPost, orgsURL, apiKey("nobody-key"), body)
+	require.Equal(t, http.StatusForbidden, status)
+
+	// WHEN invoking APIs with client api key
+	// THEN it should only allow queries
+	status, _ = invokeTestAPI(t, client, http.MethodGet, orgsURL, apiKey("client-key"), nil)
+	require.Equal(t, http.StatusOK, status)
+	status, _ = invokeTestAPI(t, client, http.MethodPost, orgsURL, apiKey("client-key"), body)
+	require.Equal(t, http.StatusForbidden, status)
+	status, _ = invokeTestAPI(t, client, http.MethodDelete, orgsURL+"/id", apiKey("client-key"), nil)
+	require.Equal(t, http.StatusForbidden, status)
+
+	// WHEN invoking APIs with root api key
+	// THEN it should allow updates
+	status, resBody := invokeTestAPI(t, client, http.MethodPost, orgsURL, apiKey("root-key"), body)
+	require.Equal(t, http.StatusOK, status)
+	orgRes := &services.CreateOrganizationResponse{}
+	require.NoError(t, json.Unmarshal(resBody, orgRes))
+
+	// WHEN invoking APIs with token scoped to the organization and its admin namespace
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": "root", "org_id": orgRes.Id, "namespaces": []string{"admin"}}).SignedString([]byte("secret"))
+	require.NoError(t, err)
+	scoped := map[string]string{"Authorization": "Bearer " + token}
+	// THEN it should only allow access within scope
+	status, _ = invokeTestAPI(t, client, http.MethodGet, orgsURL+"/"+orgRes.Id, scoped, nil)
+	require.Equal(t, http.StatusOK, status)
+	status, _ = invokeTestAPI(t, client, http.MethodGet, baseURL+orgRes.Id+"/admin/roles", scoped, nil)
+	require.Equal(t, http.StatusOK, status)
+	status, _ = invokeTestAPI(t, client, http.MethodGet, baseURL+orgRes.Id+"/finance/roles", scoped, nil)
+	require.Equal(t, http.StatusForbidden, status)
+	status, _ = invokeTestAPI(t, client, http.MethodGet, baseURL+"other-org/admin/roles", scoped, nil)
+	require.Equal(t, http.StatusForbidden, status)
+	status, _ = invokeTestAPI(t, client, http.MethodPost, orgsURL, scoped, body)
+	require.Equal(t, http.StatusForbidden, status)
+
+	// WHEN invoking APIs with root client certificate
+	certFile, err := cfg.ClientRootCertFile()
+	require.NoError(t, err)
+	keyFile, err := cfg.ClientRootKeyFile()
+	require.NoError(t, err)
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	require.NoError(t, err)
+	client = &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs: tlsConfig.ClientCAs, Certificates: []tls.Certificate{cert}}}}
+	// THEN it should allow updates
+	status, _ = invokeTestAPI(t, client, http.MethodPost, orgsURL, nil, body)
+	require.Equal(t, http.StatusOK, status)
+}
+
+func Test_ShouldMapRouteActions(t *testing.T) {
+	require.Equal(t, queryAction, routeAction(http.MethodGet, "/api/v1/organizations"))
+	require.Equal(t, updateAction, routeAction(http.MethodPost, "/api/v1/organizations"))
+	require.Equal(t, updateAction, routeAction(http.MethodPut,
+		"/api/v1/:organization_id/:namespace/resources/:id/allocate/:principal_id"))
+	require.Equal(t, deleteAction, routeAction(http.MethodDelete, "/api/v1/organizations/:id"))
+	require.Equal(t, authAction, routeAction(http.MethodPost, "/api/v1/:organization_id/:namespace/:principal_id/auth"))
+	require.Equal(t, authAction, routeAction(http.MethodPost, "/api/v1/kubernetes/authorize"))
+}
+
+func invokeTestAPI(
+	t *testing.T,
+	client *http.Client,
+	method string,
+	url string,
+	headers map[string]string,
+	body []byte) (int, []byte) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	res, err := client.Do(req)
+	require.NoError(t, err)
+	defer func() {
+		_ = res.Body.Close()
+	}()
+	resBody, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	return res.StatusCode, resBody
+}
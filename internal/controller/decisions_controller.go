@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"context"
+	"github.com/bhatti/PlexAuthZ/internal/decisionlog"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/web"
+	"net/http"
+)
+
+// DecisionsController - searches decision logs for investigations
+type DecisionsController struct {
+	config         *domain.Config
+	decisionLogger *decisionlog.Logger
+}
+
+// NewDecisionsController instantiates controller for searching decision logs
+func NewDecisionsController(
+	config *domain.Config,
+	webserver web.Server) (*DecisionsController, error) {
+	ctrl := &DecisionsController{
+		config: config,
+	}
+	if config.DecisionLog.Enabled {
+		logger, err := decisionlog.Shared(config)
+		if err != nil {
+			return nil, err
+		}
+		ctrl.decisionLogger = logger
+	}
+	webserver.GET("/api/v1/:organization_id/:namespace/decisions", ctrl.query)
+	return ctrl, nil
+}
+
+// query handler
+func (ctr *DecisionsController) query(c web.APIContext) (err error) {
+	if ctr.decisionLogger == nil {
+		return domain.NewValidationError("decision logs are not enabled")
+	}
+	predicates, offset, limit := toPredicates(c, "action", "caller", "kind", "scope", "context_hash")
+	res, nextOffset, err := ctr.decisionLogger.Query(
+		context.Background(),
+		c.Param("organization_id"),
+		c.Param("namespace"),
+		c.QueryParam("principal_id"),
+		c.QueryParam("resource"),
+		predicates,
+		offset,
+		limit,
+	)
+	if err != nil {
+		return err
+	}
+	c.Response().Header().Set(domain.NextOffsetHeader, nextOffset)
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"encoding/json"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/stretchr/testify/require"
+	"github.com/twinj/uuid"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func Test_ShouldQueryDecisionsOfRESTAuthorize(t *testing.T) {
+	// GIVEN web server with decision logs
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	cfg.Dir = "../../config"
+	cfg.HttpListenPort = "127.0.0.1:17782"
+	cfg.DecisionLog.Enabled = true
+	_, teardown := SetupWebServerForTesting(t, cfg, nil)
+	defer teardown()
+	client := &http.Client{}
+	baseURL := "http://" + cfg.HttpListenPort + "/api/v1/" + uuid.NewV4().String() + "/default/"
+
+	// WHEN authorizing unknown principal
+	status, _ := invokeTestAPI(t, client, http.MethodPost, baseURL+"alice/auth", nil,
+		[]byte(`{"resource": "file", "action": "read"}`))
+	// THEN it should fail
+	require.NotEqual(t, http.StatusOK, status)
+
+	// AND denied decision should be searchable by principal
+	var decisions []*types.Decision
+	require.Eventually(t, func() bool {
+		status, body := invokeTestAPI(t, client, http.MethodGet, baseURL+"decisions?principal_id=alice", nil, nil)
+		require.Equal(t, http.StatusOK, status)
+		require.NoError(t, json.Unmarshal(body, &decisions))
+		return len(decisions) == 1
+	}, 5*time.Second, 50*time.Millisecond)
+	require.Equal(t, types.Effect_DENIED, decisions[0].Effect)
+	require.Equal(t, "file", decisions[0].Resource)
+	require.NotEmpty(t, decisions[0].Message)
+}
@@ -1,7 +1,6 @@
 package controller
 
 import (
-	"context"
 	"encoding/json"
 	"github.com/bhatti/PlexAuthZ/api/v1/services"
 	"github.com/bhatti/PlexAuthZ/api/v1/types"
@@ -51,13 +50,14 @@ func (ctr *GroupsController) create(c web.APIContext) error {
 	}
 	group.Namespace = c.Param("namespace")
 	if group, err = ctr.authAdminService.CreateGroup(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		group); err != nil {
 		return err
 	}
 	return c.JSON(http.StatusOK, &services.CreateGroupResponse{
-		Id: group.Id,
+		Id:               group.Id,
+		ConsistencyToken: domain.NewConsistencyToken(),
 	})
 }
 
@@ -74,19 +74,21 @@ func (ctr *GroupsController) update(c web.APIContext) error {
 	group.Id = c.Param("id")
 	group.Namespace = c.Param("namespace")
 	if err = ctr.authAdminService.UpdateGroup(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		group); err != nil {
 		return err
 	}
-	return c.JSON(http.StatusOK, &services.UpdateGroupResponse{})
+	return c.JSON(http.StatusOK, &services.UpdateGroupResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	})
 }
 
 // query handler
 func (ctr *GroupsController) query(c web.APIContext) (err error) {
 	predicates, offset, limit := toPredicates(c, "id", "name")
 	res, nextOffset, err := ctr.authAdminService.GetGroups(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		c.Param("namespace"),
 		predicates,
@@ -103,14 +105,16 @@ func (ctr *GroupsController) query(c web.APIContext) (err error) {
 // delete handler
 func (ctr *GroupsController) delete(c web.APIContext) (err error) {
 	if err = ctr.authAdminService.DeleteGroup(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		c.Param("namespace"),
 		c.Param("id"),
 	); err != nil {
 		return err
 	}
-	return c.JSON(http.StatusOK, &services.DeleteGroupResponse{})
+	return c.JSON(http.StatusOK, &services.DeleteGroupResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	})
 }
 
 // addRoles handler
@@ -124,7 +128,7 @@ func (ctr *GroupsController) addRoles(c web.APIContext) (err error) {
 		return err
 	}
 	if err = ctr.authAdminService.AddRolesToGroup(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		c.Param("namespace"),
 		c.Param("id"),
@@ -132,7 +136,9 @@ func (ctr *GroupsController) addRoles(c web.APIContext) (err error) {
 	); err != nil {
 		return err
 	}
-	return c.JSON(http.StatusOK, &services.AddRolesToGroupResponse{})
+	return c.JSON(http.StatusOK, &services.AddRolesToGroupResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	})
 }
 
 // deleteRoles handler
@@ -146,7 +152,7 @@ func (ctr *GroupsController) deleteRoles(c web.APIContext) (err error) {
 		return err
 	}
 	if err = ctr.authAdminService.DeleteRolesToGroup(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		c.Param("namespace"),
 		c.Param("id"),
@@ -154,7 +160,9 @@ func (ctr *GroupsController) deleteRoles(c web.APIContext) (err error) {
 	); err != nil {
 		return err
 	}
-	return c.JSON(http.StatusOK, &services.DeleteRolesToGroupResponse{})
+	return c.JSON(http.StatusOK, &services.DeleteRolesToGroupResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	})
 }
 
 func toPredicates(c web.APIContext, keys ...string) (predicates map[string]string, offset string, limit int64) {
@@ -0,0 +1,394 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/bhatti/PlexAuthZ/api/v1/services"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/authz"
+	"github.com/bhatti/PlexAuthZ/internal/decisionlog"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/service"
+	"github.com/bhatti/PlexAuthZ/internal/web"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	subjectAccessReviewAPIVersion = "authorization.k8s.io/v1"
+	subjectAccessReviewKind       = "SubjectAccessReview"
+)
+
+// SubjectAccessReview defines request/response of Kubernetes authorization webhook.
+type SubjectAccessReview struct {
+	APIVersion string                    `json:"apiVersion"`
+	Kind       string                    `json:"kind"`
+	Metadata   map[string]any            `json:"metadata,omitempty"`
+	Spec       SubjectAccessReviewSpec   `json:"spec"`
+	Status     SubjectAccessReviewStatus `json:"status"`
+}
+
+// SubjectAccessReviewSpec defines attributes of the access review.
+type SubjectAccessReviewSpec struct {
+	ResourceAttributes    *ResourceAttributes    `json:"resourceAttributes,omitempty"`
+	NonResourceAttributes *NonResourceAttributes `json:"nonResourceAttributes,omitempty"`
+	User                  string                 `json:"user,omitempty"`
+	Groups                []string               `json:"groups,omitempty"`
+	Extra                 map[string][]string    `json:"extra,omitempty"`
+	UID                   string                 `json:"uid,omitempty"`
+}
+
+// ResourceAttributes defines attributes of Kubernetes resource request.
+type ResourceAttributes struct {
+	Namespace   string `json:"namespace,omitempty"`
+	Verb        string `json:"verb,omitempty"`
+	Group       string `json:"group,omitempty"`
+	Version     string `json:"version,omitempty"`
+	Resource    string `json:"resource,omitempty"`
+	Subresource string `json:"subresource,omitempty"`
+	Name        string `json:"name,omitempty"`
+}
+
+// NonResourceAttributes defines attributes of Kubernetes non-resource request.
+type NonResourceAttributes struct {
+	Path string `json:"path,omitempty"`
+	Verb string `json:"verb,omitempty"`
+}
+
+// SubjectAccessReviewStatus defines decision of the access review.
+type SubjectAccessReviewStatus struct {
+	Allowed         bool   `json:"allowed"`
+	Denied          bool   `json:"denied,omitempty"`
+	Reason          string `json:"reason,omitempty"`
+	EvaluationError string `json:"evaluationError,omitempty"`
+}
+
+type kubernetesAuthRule struct {
+	domain.KubernetesAuthRule
+	organization *template.Template
+	namespace    *template.Template
+	principal    *template.Template
+	resource     *template.Template
+	action       *template.Template
+	scope        *template.Template
+	context      map[string]*template.Template
+}
+
+// KubernetesController - Kubernetes authorization webhook controller
+type KubernetesController struct {
+	config         *domain.Config
+	authService    service.AuthAdminService
+	rules          []*kubernetesAuthRule
+	decisionLogger *decisionlog.Logger
+}
+
+// NewKubernetesController instantiates controller for Kubernetes SubjectAccessReview webhook
+func NewKubernetesController(
+	config *domain.Config,
+	authService service.AuthAdminService,
+	webserver web.Server) (*KubernetesController, error) {
+	ctrl := &KubernetesController{
+		config:      config,
+		authService: authService,
+	}
+	for i, rule := range config.KubernetesAuth.Rules {
+		compiled, err := compileKubernetesAuthRule(rule)
+		if err != nil {
+			return nil, domain.NewValidationError(
+				fmt.Sprintf("failed to compile kubernetes rule %d '%s' due to %s", i, rule.Name, err))
+		}
+		ctrl.rules = append(ctrl.rules, compiled)
+	}
+	if config.DecisionLog.Enabled {
+		logger, err := decisionlog.Shared(config)
+		if err != nil {
+			return nil, err
+		}
+		ctrl.decisionLogger = logger
+	}
+	webserver.POST("/api/v1/kubernetes/authorize", ctrl.authorize)
+	return ctrl, nil
+}
+
+// authorize handler
+func (ctr *KubernetesController) authorize(c web.APIContext) error {
+	b, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return err
+	}
+	review := &SubjectAccessReview{}
+	if err = json.Unmarshal(b, review); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if review.APIVersion == "" {
+		review.APIVersion = subjectAccessReviewAPIVersion
+	}
+	review.Kind = subjectAccessReviewKind
+	review.Status = ctr.review(subjectContext(c), &review.Spec)
+	return c.JSON(http.StatusOK, review)
+}
+
+func (ctr *KubernetesController) review(
+	ctx context.Context,
+	spec *SubjectAccessReviewSpec,
+) (status SubjectAccessReviewStatus) {
+	attrs := toKubernetesAttributes(spec)
+	rule := ctr.matchRule(attrs)
+	if rule == nil {
+		// no opinion so that other authorizers can decide
+		status.Reason = fmt.Sprintf("no rule matched %s %s", attrs["Verb"], kubernetesTarget(attrs))
+		return
+	}
+	req, err := rule.toAuthRequest(attrs)
+	if err != nil {
+		status.EvaluationError = err.Error()
+		return
+	}
+	started := time.Now()
+	res, err := ctr.authorizeRequest(ctx, req, spec)
+	if ctr.decisionLogger != nil {
+		authz.LogAuthorizeDecision(ctx, ctr.decisionLogger, started, req, res, err)
+	}
+	if _, denied := err.(*domain.AuthError); denied {
+		status.Denied = true
+		status.Reason = err.Error()
+	} else if err != nil {
+		// failures to evaluate the review have no opinion
+		status.EvaluationError = err.Error()
+	} else if res.Effect != types.Effect_PERMITTED {
+		status.Denied = true
+		status.Reason = res.Message
+	} else {
+		status.Allowed = true
+		status.Reason = fmt.Sprintf("permitted by PlexAuthZ rule %s", rule.Name)
+	}
+	if log.IsLevelEnabled(log.DebugLevel) {
+		log.WithFields(log.Fields{
+			"Component": "KubernetesController",
+			"Rule":      rule.Name,
+			"Request":   req,
+			"Status":    status,
+		}).Debugf("reviewed kubernetes access")
+	}
+	return
+}
+
+func (ctr *KubernetesController) authorizeRequest(
+	ctx context.Context,
+	req *services.AuthRequest,
+	spec *SubjectAccessReviewSpec,
+) (*services.AuthResponse, error) {
+	if req.PrincipalId == "" {
+		return nil, domain.NewAuthError("user is not defined")
+	}
+	principalID, err := ctr.findPrincipalID(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	var principal *domain.PrincipalExt
+	if principalID == "" {
+		if !ctr.config.KubernetesAuth.GroupClaims {
+			return nil, domain.NewAuthError(
+				fmt.Sprintf("principal %s not found", req.PrincipalId))
+		}
+		principal, err = authz.NewClaimsPrincipalExt(
+			ctx, ctr.authService, req.OrganizationId, req.Namespace, req.PrincipalId)
+	} else {
+		req.PrincipalId = principalID
+		principal, err = ctr.authService.GetPrincipalExt(
+			ctx, req.OrganizationId, req.Namespace, req.PrincipalId)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ctr.config.KubernetesAuth.GroupClaims && len(spec.Groups) > 0 {
+		principal, err = authz.PrincipalWithGroupClaims(
+			ctx, ctr.config, ctr.authService, principal, req.Namespace, spec.Groups...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return principal.CheckPermission(req)
+}
+
+// findPrincipalID returns id of the principal or empty string if it's not registered.
+func (ctr *KubernetesController) findPrincipalID(
+	ctx context.Context,
+	req *services.AuthRequest,
+) (string, error) {
+	if ctr.config.KubernetesAuth.PrincipalLookup == "id" {
+		principal, err := ctr.authService.GetPrincipal(ctx, req.OrganizationId, req.PrincipalId)
+		if err != nil {
+			if domain.ErrorToHTTPStatus(err) == http.StatusNotFound {
+				return "", nil
+			}
+			return "", err
+		}
+		return principal.Id, nil
+	}
+	principals, _, err := ctr.authService.GetPrincipals(
+		ctx, req.OrganizationId, map[string]string{"username": req.PrincipalId}, "", 1)
+	if err != nil {
+		return "", err
+	}
+	if len(principals) == 0 {
+		return "", nil
+	}
+	return principals[0].Id, nil
+}
+
+func (ctr *KubernetesController) matchRule(attrs map[string]any) *kubernetesAuthRule {
+	path := attrs["Path"].(string)
+	for _, rule := range ctr.rules {
+		if !matchesKubernetesValue(rule.Verbs, attrs["Verb"].(string)) {
+			continue
+		}
+		if path != "" {
+			if len(rule.NonResourcePaths) == 0 || !matchesKubernetesPath(rule.NonResourcePaths, path) {
+				continue
+			}
+		} else {
+			if len(rule.NonResourcePaths) > 0 ||
+				!matchesKubernetesValue(rule.APIGroups, attrs["APIGroup"].(string)) ||
+				!matchesKubernetesValue(rule.Resources, kubernetesResource(attrs)) ||
+				!matchesKubernetesValue(rule.Namespaces, attrs["ResourceNamespace"].(string)) {
+				continue
+			}
+		}
+		return rule
+	}
+	return nil
+}
+
+func toKubernetesAttributes(spec *SubjectAccessReviewSpec) map[string]any {
+	attrs := map[string]any{
+		"User":              spec.User,
+		"UID":               spec.UID,
+		"Groups":            spec.Groups,
+		"Extra":             spec.Extra,
+		"Verb":              "",
+		"APIGroup":          "",
+		"Version":           "",
+		"Resource":          "",
+		"Subresource":       "",
+		"Name":              "",
+		"ResourceNamespace": "",
+		"Path":              "",
+	}
+	if spec.Extra == nil {
+		attrs["Extra"] = map[string][]string{}
+	}
+	if res := spec.ResourceAttributes; res != nil {
+		attrs["Verb"] = res.Verb
+		attrs["APIGroup"] = res.Group
+		attrs["Version"] = res.Version
+		attrs["Resource"] = res.Resource
+		attrs["Subresource"] = res.Subresource
+		attrs["Name"] = res.Name
+		attrs["ResourceNamespace"] = res.Namespace
+	} else if nonRes := spec.NonResourceAttributes; nonRes != nil {
+		attrs["Verb"] = nonRes.Verb
+		attrs["Path"] = nonRes.Path
+	}
+	return attrs
+}
+
+// kubernetesResource returns resource along with subresource, e.g., pods/log.
+func kubernetesResource(attrs map[string]any) string {
+	if sub := attrs["Subresource"].(string); sub != "" {
+		return attrs["Resource"].(string) + "/" + sub
+	}
+	return attrs["Resource"].(string)
+}
+
+func kubernetesTarget(attrs map[string]any) string {
+	if path := attrs["Path"].(string); path != "" {
+		return path
+	}
+	return kubernetesResource(attrs)
+}
+
+func matchesKubernetesValue(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, next := range values {
+		if next == "*" || next == value {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesKubernetesPath(prefixes []string, path string) bool {
+	for _, prefix := range prefixes {
+		if prefix == "*" || strings.HasPrefix(path, strings.TrimSuffix(prefix, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+func compileKubernetesAuthRule(rule domain.KubernetesAuthRule) (compiled *kubernetesAuthRule, err error) {
+	compiled = &kubernetesAuthRule{
+		KubernetesAuthRule: rule,
+		context:            make(map[string]*template.Template),
+	}
+	if compiled.organization, err = domain.ParseMappingTemplate(rule.Organization); err != nil {
+		return nil, err
+	}
+	if compiled.namespace, err = domain.ParseMappingTemplate(rule.Namespace); err != nil {
+		return nil, err
+	}
+	if compiled.principal, err = domain.ParseMappingTemplate(rule.Principal); err != nil {
+		return nil, err
+	}
+	if compiled.resource, err = domain.ParseMappingTemplate(rule.Resource); err != nil {
+		return nil, err
+	}
+	if compiled.action, err = domain.ParseMappingTemplate(rule.Action); err != nil {
+		return nil, err
+	}
+	if compiled.scope, err = domain.ParseMappingTemplate(rule.Scope); err != nil {
+		return nil, err
+	}
+	for k, v := range rule.Context {
+		if compiled.context[k], err = domain.ParseMappingTemplate(v); err != nil {
+			return nil, err
+		}
+	}
+	return compiled, nil
+}
+
+func (r *kubernetesAuthRule) toAuthRequest(attrs map[string]any) (req *services.AuthRequest, err error) {
+	req = &services.AuthRequest{Context: make(map[string]string)}
+	if req.OrganizationId, err = domain.ExecuteMappingTemplate(r.organization, attrs); err != nil {
+		return nil, err
+	}
+	if req.Namespace, err = domain.ExecuteMappingTemplate(r.namespace, attrs); err != nil {
+		return nil, err
+	}
+	if req.PrincipalId, err = domain.ExecuteMappingTemplate(r.principal, attrs); err != nil {
+		return nil, err
+	}
+	if req.Resource, err = domain.ExecuteMappingTemplate(r.resource, attrs); err != nil {
+		return nil, err
+	}
+	if req.Action, err = domain.ExecuteMappingTemplate(r.action, attrs); err != nil {
+		return nil, err
+	}
+	if req.Scope, err = domain.ExecuteMappingTemplate(r.scope, attrs); err != nil {
+		return nil, err
+	}
+	for k, t := range r.context {
+		if req.Context[k], err = domain.ExecuteMappingTemplate(t, attrs); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
@@ -0,0 +1,224 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/web"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func Test_ShouldReviewKubernetesAccess(t *testing.T) {
+	to, ctrl, err := newTestKubernetesController()
+	require.NoError(t, err)
+
+	// WHEN reviewing access for registered user with permission
+	status := reviewKubernetesAccess(t, ctrl, SubjectAccessReviewSpec{
+		User: "john",
+		ResourceAttributes: &ResourceAttributes{
+			Namespace: "default", Verb: "read", Resource: "paper"},
+	})
+	// THEN it should be allowed
+	require.True(t, status.Allowed)
+	require.False(t, status.Denied)
+
+	// WHEN reviewing access for registered user without permission
+	status = reviewKubernetesAccess(t, ctrl, SubjectAccessReviewSpec{
+		User: "john",
+		ResourceAttributes: &ResourceAttributes{
+			Namespace: "default", Verb: "list", Resource: "pods"},
+	})
+	// THEN it should be denied
+	require.False(t, status.Allowed)
+	require.True(t, status.Denied)
+	require.NotEmpty(t, status.Reason)
+
+	// WHEN reviewing access for registered user with group claim
+	status = reviewKubernetesAccess(t, ctrl, SubjectAccessReviewSpec{
+		User:   "john",
+		Groups: []string{"system:authenticated", "k8s-viewers"},
+		ResourceAttributes: &ResourceAttributes{
+			Namespace: "default", Verb: "list", Resource: "pods"},
+	})
+	// THEN it should be allowed
+	require.True(t, status.Allowed)
+
+	// WHEN reviewing access for unregistered user with group claim
+	status = reviewKubernetesAccess(t, ctrl, SubjectAccessReviewSpec{
+		User:   "system:serviceaccount:default:viewer",
+		Groups: []string{"k8s-viewers"},
+		ResourceAttributes: &ResourceAttributes{
+			Namespace: "default", Verb: "list", Resource: "pods"},
+	})
+	// THEN it should be allowed
+	require.True(t, status.Allowed)
+
+	// WHEN reviewing access for unregistered user without group claim
+	status = reviewKubernetesAccess(t, ctrl, SubjectAccessReviewSpec{
+		User: "jane",
+		ResourceAttributes: &ResourceAttributes{
+			Namespace: "default", Verb: "list", Resource: "pods"},
+	})
+	// THEN it should be denied
+	require.True(t, status.Denied)
+
+	// WHEN reviewing access that doesn't match any rule
+	status = reviewKubernetesAccess(t, ctrl, SubjectAccessReviewSpec{
+		User:   "john",
+		Groups: []string{"k8s-viewers"},
+		ResourceAttributes: &ResourceAttributes{
+			Namespace: "kube-system", Verb: "list", Resource: "pods"},
+	})
+	// THEN it should not have an opinion
+	require.False(t, status.Allowed)
+	require.False(t, status.Denied)
+
+	// WHEN reviewing access for non-resource path
+	status = reviewKubernetesAccess(t, ctrl, SubjectAccessReviewSpec{
+		User:                  "john",
+		Groups:                []string{"k8s-viewers"},
+		NonResourceAttributes: &NonResourceAttributes{Path: "/healthz/ready", Verb: "get"},
+	})
+	// THEN it should be allowed
+	require.True(t, status.Allowed)
+
+	// WHEN reviewing access that cannot be evaluated
+	status = reviewKubernetesAccess(t, ctrl, SubjectAccessReviewSpec{
+		User: "john",
+		ResourceAttributes: &ResourceAttributes{
+			Namespace: "missing", Verb: "list", Resource: "pods"},
+	})
+	// THEN it should return evaluation error without an opinion
+	require.False(t, status.Allowed)
+	require.False(t, status.Denied)
+	require.NotEmpty(t, status.EvaluationError)
+	require.NotNil(t, to)
+}
+
+func Test_ShouldFailKubernetesControllerWithInvalidRules(t *testing.T) {
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	cfg.KubernetesAuth.Rules = []domain.KubernetesAuthRule{{Organization: "{{.User", Namespace: "ns"}}
+	_, err = NewKubernetesController(cfg, nil, web.NewStubWebServer())
+	require.Error(t, err)
+	cfg.KubernetesAuth.Rules = []domain.KubernetesAuthRule{{Organization: "org"}}
+	require.Error(t, cfg.Validate())
+	cfg.KubernetesAuth.Rules = nil
+	cfg.KubernetesAuth.PrincipalLookup = "email"
+	require.Error(t, cfg.Validate())
+}
+
+func reviewKubernetesAccess(
+	t *testing.T,
+	ctrl *KubernetesController,
+	spec SubjectAccessReviewSpec) SubjectAccessReviewStatus {
+	reqB, err := json.Marshal(&SubjectAccessReview{
+		APIVersion: "authorization.k8s.io/v1",
+		Kind:       "SubjectAccessReview",
+		Spec:       spec,
+	})
+	require.NoError(t, err)
+	reader := io.NopCloser(bytes.NewReader(reqB))
+	u, err := url.Parse("https://localhost:8080/api/v1/kubernetes/authorize")
+	require.NoError(t, err)
+	ctx := web.NewStubContext(&http.Request{Body: reader, URL: u})
+	require.NoError(t, ctrl.authorize(ctx))
+	res := ctx.Result.(*SubjectAccessReview)
+	require.Equal(t, "SubjectAccessReview", res.Kind)
+	return res.Status
+}
+
+func newTestKubernetesController() (*testObjects, *KubernetesController, error) {
+	to, err := newTestObjects()
+	if err != nil {
+		return nil, nil, err
+	}
+	namespace := to.org.Namespaces[0]
+	// group claims of kubernetes users are mapped to groups with the same name
+	resource, err := to.authService.CreateResource(to.ctx, to.org.Id, &types.Resource{
+		Namespace:      namespace,
+		Name:           "pods",
+		AllowedActions: []string{"get", "list", "watch"},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	healthz, err := to.authService.CreateResource(to.ctx, to.org.Id, &types.Resource{
+		Namespace:      namespace,
+		Name:           "/healthz*",
+		AllowedActions: []string{"get"},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	perm, err := to.authService.CreatePermission(to.ctx, to.org.Id, &types.Permission{
+		Namespace:  namespace,
+		Actions:    []string{"get", "list"},
+		ResourceId: resource.Id,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	healthzPerm, err := to.authService.CreatePermission(to.ctx, to.org.Id, &types.Permission{
+		Namespace:  namespace,
+		Actions:    []string{"get"},
+		ResourceId: healthz.Id,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	role, err := to.authService.CreateRole(to.ctx, to.org.Id, &types.Role{
+		Namespace:     namespace,
+		Name:          "k8s-viewer",
+		PermissionIds: []string{perm.Id, healthzPerm.Id},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	group, err := to.authService.CreateGroup(to.ctx, to.org.Id, &types.Group{
+		Namespace: namespace,
+		Name:      "k8s-viewers",
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = to.authService.AddRolesToGroup(to.ctx, to.org.Id, namespace, group.Id, role.Id); err != nil {
+		return nil, nil, err
+	}
+	to.config.KubernetesAuth = domain.KubernetesAuthConfig{
+		GroupClaims: true,
+		Rules: []domain.KubernetesAuthRule{
+			{
+				Name:         "default",
+				Namespaces:   []string{"default"},
+				Organization: to.org.Id,
+				Namespace:    namespace,
+			},
+			{
+				Name:             "health",
+				NonResourcePaths: []string{"/healthz*"},
+				Organization:     to.org.Id,
+				Namespace:        namespace,
+			},
+			{
+				Name:         "missing",
+				Namespaces:   []string{"missing"},
+				Organization: "missing-org",
+				Namespace:    namespace,
+			},
+		},
+	}
+	if err = to.config.Validate(); err != nil {
+		return nil, nil, err
+	}
+	ctrl, err := NewKubernetesController(to.config, to.authService, web.NewStubWebServer())
+	if err != nil {
+		return nil, nil, err
+	}
+	return to, ctrl, nil
+}
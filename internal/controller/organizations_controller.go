@@ -1,7 +1,6 @@
 package controller
 
 import (
-	"context"
 	"encoding/json"
 	"github.com/bhatti/PlexAuthZ/api/v1/services"
 	"github.com/bhatti/PlexAuthZ/api/v1/types"
@@ -47,12 +46,13 @@ func (ctr *OrganizationsController) create(c web.APIContext) error {
 		return err
 	}
 	if organization, err = ctr.authAdminService.CreateOrganization(
-		context.Background(),
+		subjectContext(c),
 		organization); err != nil {
 		return err
 	}
 	return c.JSON(http.StatusOK, &services.CreateOrganizationResponse{
-		Id: organization.Id,
+		Id:               organization.Id,
+		ConsistencyToken: domain.NewConsistencyToken(),
 	})
 }
 
@@ -68,17 +68,19 @@ func (ctr *OrganizationsController) update(c web.APIContext) error {
 	}
 	organization.Id = c.Param("id")
 	if err = ctr.authAdminService.UpdateOrganization(
-		context.Background(),
+		subjectContext(c),
 		organization); err != nil {
 		return err
 	}
-	return c.JSON(http.StatusOK, &services.UpdateOrganizationResponse{})
+	return c.JSON(http.StatusOK, &services.UpdateOrganizationResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	})
 }
 
 // get handler
 func (ctr *OrganizationsController) get(c web.APIContext) (err error) {
 	res, err := ctr.authAdminService.GetOrganization(
-		context.Background(),
+		subjectContext(c),
 		c.Param("id"),
 	)
 	if err != nil {
@@ -91,7 +93,7 @@ func (ctr *OrganizationsController) get(c web.APIContext) (err error) {
 func (ctr *OrganizationsController) query(c web.APIContext) (err error) {
 	predicates, offset, limit := toPredicates(c, "id", "name")
 	res, nextOffset, err := ctr.authAdminService.GetOrganizations(
-		context.Background(),
+		subjectContext(c),
 		predicates,
 		offset,
 		limit,
@@ -106,11 +108,13 @@ func (ctr *OrganizationsController) query(c web.APIContext) (err error) {
 // delete handler
 func (ctr *OrganizationsController) delete(c web.APIContext) (err error) {
 	err = ctr.authAdminService.DeleteOrganization(
-		context.Background(),
+		subjectContext(c),
 		c.Param("id"),
 	)
 	if err != nil {
 		return err
 	}
-	return c.JSON(http.StatusOK, &services.DeleteOrganizationResponse{})
+	return c.JSON(http.StatusOK, &services.DeleteOrganizationResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	})
 }
@@ -1,7 +1,6 @@
 package controller
 
 import (
-	"context"
 	"encoding/json"
 	"github.com/bhatti/PlexAuthZ/api/v1/services"
 	"github.com/bhatti/PlexAuthZ/api/v1/types"
@@ -47,13 +46,14 @@ func (ctr *PermissionsController) create(c web.APIContext) error {
 	}
 	permission.Namespace = c.Param("namespace")
 	if permission, err = ctr.authAdminService.CreatePermission(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		permission); err != nil {
 		return err
 	}
 	return c.JSON(http.StatusOK, &services.CreatePermissionResponse{
-		Id: permission.Id,
+		Id:               permission.Id,
+		ConsistencyToken: domain.NewConsistencyToken(),
 	})
 }
 
@@ -70,19 +70,21 @@ func (ctr *PermissionsController) update(c web.APIContext) error {
 	permission.Id = c.Param("id")
 	permission.Namespace = c.Param("namespace")
 	if err = ctr.authAdminService.UpdatePermission(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		permission); err != nil {
 		return err
 	}
-	return c.JSON(http.StatusOK, &services.UpdatePermissionResponse{})
+	return c.JSON(http.StatusOK, &services.UpdatePermissionResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	})
 }
 
 // query handler
 func (ctr *PermissionsController) query(c web.APIContext) (err error) {
 	predicates, offset, limit := toPredicates(c, "id", "namespace", "scope", "resource_id")
 	res, nextOffset, err := ctr.authAdminService.GetPermissions(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		c.Param("namespace"),
 		predicates,
@@ -99,12 +101,14 @@ func (ctr *PermissionsController) query(c web.APIContext) (err error) {
 // delete handler
 func (ctr *PermissionsController) delete(c web.APIContext) (err error) {
 	if err = ctr.authAdminService.DeletePermission(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		c.Param("namespace"),
 		c.Param("id"),
 	); err != nil {
 		return err
 	}
-	return c.JSON(http.StatusOK, &services.DeletePermissionResponse{})
+	return c.JSON(http.StatusOK, &services.DeletePermissionResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	})
 }
@@ -1,7 +1,6 @@
 package controller
 
 import (
-	"context"
 	"encoding/json"
 	"github.com/bhatti/PlexAuthZ/api/v1/services"
 	"github.com/bhatti/PlexAuthZ/api/v1/types"
@@ -56,12 +55,13 @@ func (ctr *PrincipalsController) create(c web.APIContext) error {
 	}
 	principal.OrganizationId = c.Param("organization_id")
 	if principal, err = ctr.authAdminService.CreatePrincipal(
-		context.Background(),
+		subjectContext(c),
 		principal); err != nil {
 		return err
 	}
 	return c.JSON(http.StatusOK, &services.CreatePrincipalResponse{
-		Id: principal.Id,
+		Id:               principal.Id,
+		ConsistencyToken: domain.NewConsistencyToken(),
 	})
 }
 
@@ -78,17 +78,19 @@ func (ctr *PrincipalsController) update(c web.APIContext) error {
 	principal.OrganizationId = c.Param("organization_id")
 	principal.Id = c.Param("id")
 	if err = ctr.authAdminService.UpdatePrincipal(
-		context.Background(),
+		subjectContext(c),
 		principal); err != nil {
 		return err
 	}
-	return c.JSON(http.StatusOK, &services.UpdatePrincipalResponse{})
+	return c.JSON(http.StatusOK, &services.UpdatePrincipalResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	})
 }
 
 // get handler
 func (ctr *PrincipalsController) get(c web.APIContext) (err error) {
 	principal, err := ctr.authAdminService.GetPrincipalExt(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		c.Param("namespace"),
 		c.Param("id"),
@@ -104,7 +106,7 @@ func (ctr *PrincipalsController) get(c web.APIContext) (err error) {
 func (ctr *PrincipalsController) query(c web.APIContext) (err error) {
 	predicates, offset, limit := toPredicates(c, "id", "name")
 	res, nextOffset, err := ctr.authAdminService.GetPrincipals(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		predicates,
 		offset,
@@ -120,13 +122,15 @@ func (ctr *PrincipalsController) query(c web.APIContext) (err error) {
 // delete handler
 func (ctr *PrincipalsController) delete(c web.APIContext) (err error) {
 	if err = ctr.authAdminService.DeletePrincipal(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		c.Param("id"),
 	); err != nil {
 		return err
 	}
-	return c.JSON(http.StatusOK, &services.DeletePrincipalResponse{})
+	return c.JSON(http.StatusOK, &services.DeletePrincipalResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	})
 }
 
 // addGroups handler
@@ -140,7 +144,7 @@ func (ctr *PrincipalsController) addGroups(c web.APIContext) (err error) {
 		return err
 	}
 	err = ctr.authAdminService.AddGroupsToPrincipal(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		c.Param("namespace"),
 		c.Param("id"),
@@ -149,7 +153,9 @@ func (ctr *PrincipalsController) addGroups(c web.APIContext) (err error) {
 	if err != nil {
 		return err
 	}
-	return c.JSON(http.StatusOK, &services.AddGroupsToPrincipalResponse{})
+	return c.JSON(http.StatusOK, &services.AddGroupsToPrincipalResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	})
 }
 
 // deleteGroups handler
@@ -163,7 +169,7 @@ func (ctr *PrincipalsController) deleteGroups(c web.APIContext) (err error) {
 		return err
 	}
 	if err = ctr.authAdminService.DeleteGroupsToPrincipal(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		c.Param("namespace"),
 		c.Param("id"),
@@ -171,7 +177,9 @@ func (ctr *PrincipalsController) deleteGroups(c web.APIContext) (err error) {
 	); err != nil {
 		return err
 	}
-	return c.JSON(http.StatusOK, &services.DeleteGroupsToPrincipalResponse{})
+	return c.JSON(http.StatusOK, &services.DeleteGroupsToPrincipalResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	})
 }
 
 // addRoles handler
@@ -185,7 +193,7 @@ func (ctr *PrincipalsController) addRoles(c web.APIContext) (err error) {
 		return err
 	}
 	if err = ctr.authAdminService.AddRolesToPrincipal(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		c.Param("namespace"),
 		c.Param("id"),
@@ -193,7 +201,9 @@ func (ctr *PrincipalsController) addRoles(c web.APIContext) (err error) {
 	); err != nil {
 		return err
 	}
-	return c.JSON(http.StatusOK, &services.AddRolesToPrincipalResponse{})
+	return c.JSON(http.StatusOK, &services.AddRolesToPrincipalResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	})
 }
 
 // deleteRoles handler
@@ -207,7 +217,7 @@ func (ctr *PrincipalsController) deleteRoles(c web.APIContext) (err error) {
 		return err
 	}
 	if err = ctr.authAdminService.DeleteRolesToPrincipal(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		c.Param("namespace"),
 		c.Param("id"),
@@ -215,7 +225,9 @@ func (ctr *PrincipalsController) deleteRoles(c web.APIContext) (err error) {
 	); err != nil {
 		return err
 	}
-	return c.JSON(http.StatusOK, &services.DeleteRolesToPrincipalResponse{})
+	return c.JSON(http.StatusOK, &services.DeleteRolesToPrincipalResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	})
 }
 
 // addPermissions handler
@@ -229,7 +241,7 @@ func (ctr *PrincipalsController) addPermissions(c web.APIContext) (err error) {
 		return err
 	}
 	if err = ctr.authAdminService.AddPermissionsToPrincipal(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		c.Param("namespace"),
 		c.Param("id"),
@@ -237,7 +249,9 @@ func (ctr *PrincipalsController) addPermissions(c web.APIContext) (err error) {
 	); err != nil {
 		return err
 	}
-	return c.JSON(http.StatusOK, &services.AddPermissionsToPrincipalResponse{})
+	return c.JSON(http.StatusOK, &services.AddPermissionsToPrincipalResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	})
 }
 
 // deletePermissions handler
@@ -251,7 +265,7 @@ func (ctr *PrincipalsController) deletePermissions(c web.APIContext) (err error)
 		return err
 	}
 	if err = ctr.authAdminService.DeletePermissionsToPrincipal(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		c.Param("namespace"),
 		c.Param("id"),
@@ -259,7 +273,9 @@ func (ctr *PrincipalsController) deletePermissions(c web.APIContext) (err error)
 	); err != nil {
 		return err
 	}
-	return c.JSON(http.StatusOK, &services.DeletePermissionsToPrincipalResponse{})
+	return c.JSON(http.StatusOK, &services.DeletePermissionsToPrincipalResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	})
 }
 
 // addRelationships handler
@@ -273,7 +289,7 @@ func (ctr *PrincipalsController) addRelationships(c web.APIContext) (err error)
 		return err
 	}
 	if err = ctr.authAdminService.AddRelationshipsToPrincipal(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		c.Param("namespace"),
 		c.Param("id"),
@@ -281,7 +297,9 @@ func (ctr *PrincipalsController) addRelationships(c web.APIContext) (err error)
 	); err != nil {
 		return err
 	}
-	return c.JSON(http.StatusOK, &services.AddRelationshipsToPrincipalResponse{})
+	return c.JSON(http.StatusOK, &services.AddRelationshipsToPrincipalResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	})
 }
 
 // deleteRelationships handler
@@ -295,7 +313,7 @@ func (ctr *PrincipalsController) deleteRelationships(c web.APIContext) (err erro
 		return err
 	}
 	if err = ctr.authAdminService.DeleteRelationshipsToPrincipal(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		c.Param("namespace"),
 		c.Param("id"),
@@ -303,5 +321,7 @@ func (ctr *PrincipalsController) deleteRelationships(c web.APIContext) (err erro
 	); err != nil {
 		return err
 	}
-	return c.JSON(http.StatusOK, &services.DeleteRelationshipsToPrincipalResponse{})
+	return c.JSON(http.StatusOK, &services.DeleteRelationshipsToPrincipalResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	})
 }
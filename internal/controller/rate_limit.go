@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/ratelimit"
+	"github.com/bhatti/PlexAuthZ/internal/web"
+	"github.com/labstack/echo/v4"
+	"io"
+	"math"
+	"net/http"
+)
+
+// RateLimitMiddleware rejects requests with 429 and Retry-After header when the organization, principal
+// or client of the request exceeds its rate limit, where the principal is read from the route or the body
+// of authorization requests and the client is the authenticated subject or remote address.
+func RateLimitMiddleware(rateLimiter *ratelimit.RateLimiter) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			client, _ := c.Get(web.SubjectKey).(string)
+			if client == "" {
+				client = c.RealIP()
+			}
+			err := rateLimiter.Allow(
+				context.Background(),
+				routeOrganization(c),
+				routePrincipal(c),
+				client,
+			)
+			var rateLimitErr *domain.RateLimitError
+			if errors.As(err, &rateLimitErr) {
+				retryAfter := math.Max(1, math.Ceil(rateLimitErr.RetryAfter.Seconds()))
+				c.Response().Header().Set("Retry-After", fmt.Sprintf("%d", int64(retryAfter)))
+				return echo.NewHTTPError(http.StatusTooManyRequests, err.Error())
+			} else if err != nil {
+				return err
+			}
+			return next(c)
+		}
+	}
+}
+
+func routePrincipal(c echo.Context) string {
+	if principalId := c.Param("principal_id"); principalId != "" {
+		return principalId
+	}
+	if routeAction(c.Request().Method, c.Path()) != authAction || c.Request().Body == nil {
+		return ""
+	}
+	b, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return ""
+	}
+	c.Request().Body = io.NopCloser(bytes.NewReader(b))
+	req := struct {
+		PrincipalId string `json:"principal_id"`
+	}{}
+	_ = json.Unmarshal(b, &req)
+	return req.PrincipalId
+}
@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func Test_ShouldRateLimitRESTAPIs(t *testing.T) {
+	// GIVEN web server with rate limit of single request
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	cfg.Dir = "../../config"
+	cfg.HttpListenPort = "127.0.0.1:17781"
+	cfg.RateLimit.Enabled = true
+	cfg.RateLimit.RequestsPerSecond = 0.001
+	cfg.RateLimit.Burst = 1
+	_, teardown := SetupWebServerForTesting(t, cfg, nil)
+	defer teardown()
+	client := &http.Client{}
+	orgsURL := "http://" + cfg.HttpListenPort + "/api/v1/organizations"
+
+	// WHEN invoking APIs within the limit
+	status, _ := invokeTestAPI(t, client, http.MethodGet, orgsURL, nil, nil)
+	// THEN it should succeed
+	require.Equal(t, http.StatusOK, status)
+
+	// WHEN exceeding the limit
+	req, err := http.NewRequest(http.MethodGet, orgsURL, nil)
+	require.NoError(t, err)
+	res, err := client.Do(req)
+	require.NoError(t, err)
+	_ = res.Body.Close()
+	// THEN it should fail with too many requests and retry-after
+	require.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+	require.NotEmpty(t, res.Header.Get("Retry-After"))
+}
@@ -1,7 +1,6 @@
 package controller
 
 import (
-	"context"
 	"encoding/json"
 	"github.com/bhatti/PlexAuthZ/api/v1/services"
 	"github.com/bhatti/PlexAuthZ/api/v1/types"
@@ -48,14 +47,15 @@ func (ctr *RelationshipsController) create(c web.APIContext) error {
 	}
 	relation.Namespace = c.Param("namespace")
 	relation, err = ctr.authAdminService.CreateRelationship(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		relation)
 	if err != nil {
 		return err
 	}
 	return c.JSON(http.StatusOK, &services.CreateRelationshipResponse{
-		Id: relation.Id,
+		Id:               relation.Id,
+		ConsistencyToken: domain.NewConsistencyToken(),
 	})
 }
 
@@ -73,19 +73,21 @@ func (ctr *RelationshipsController) update(c web.APIContext) error {
 	relation.Id = c.Param("id")
 	relation.Namespace = c.Param("namespace")
 	if err = ctr.authAdminService.UpdateRelationship(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		relation); err != nil {
 		return err
 	}
-	return c.JSON(http.StatusOK, &services.UpdateRelationshipResponse{})
+	return c.JSON(http.StatusOK, &services.UpdateRelationshipResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	})
 }
 
 // query handler
 func (ctr *RelationshipsController) query(c web.APIContext) (err error) {
 	predicates, offset, limit := toPredicates(c, "id", "relation")
 	res, nextOffset, err := ctr.authAdminService.GetRelationships(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		c.Param("namespace"),
 		predicates,
@@ -102,7 +104,7 @@ func (ctr *RelationshipsController) query(c web.APIContext) (err error) {
 // delete handler
 func (ctr *RelationshipsController) delete(c web.APIContext) (err error) {
 	err = ctr.authAdminService.DeleteRelationship(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		c.Param("namespace"),
 		c.Param("id"),
@@ -110,5 +112,7 @@ func (ctr *RelationshipsController) delete(c web.APIContext) (err error) {
 	if err != nil {
 		return err
 	}
-	return c.JSON(http.StatusOK, &services.DeleteRelationshipResponse{})
+	return c.JSON(http.StatusOK, &services.DeleteRelationshipResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	})
 }
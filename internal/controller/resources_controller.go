@@ -1,7 +1,6 @@
 package controller
 
 import (
-	"context"
 	"encoding/json"
 	"github.com/bhatti/PlexAuthZ/api/v1/services"
 	"github.com/bhatti/PlexAuthZ/api/v1/types"
@@ -50,14 +49,15 @@ func (ctr *ResourcesController) create(c web.APIContext) error {
 	}
 	resource.Namespace = c.Param("namespace")
 	resource, err = ctr.authAdminService.CreateResource(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		resource)
 	if err != nil {
 		return err
 	}
 	return c.JSON(http.StatusOK, &services.CreateResourceResponse{
-		Id: resource.Id,
+		Id:               resource.Id,
+		ConsistencyToken: domain.NewConsistencyToken(),
 	})
 }
 
@@ -75,19 +75,21 @@ func (ctr *ResourcesController) update(c web.APIContext) error {
 	resource.Id = c.Param("id")
 	resource.Namespace = c.Param("namespace")
 	if err = ctr.authAdminService.UpdateResource(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		resource); err != nil {
 		return err
 	}
-	return c.JSON(http.StatusOK, &services.UpdateResourceResponse{})
+	return c.JSON(http.StatusOK, &services.UpdateResourceResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	})
 }
 
 // query handler
 func (ctr *ResourcesController) query(c web.APIContext) (err error) {
 	predicates, offset, limit := toPredicates(c, "id", "name")
 	res, nextOffset, err := ctr.authAdminService.QueryResources(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		c.Param("namespace"),
 		predicates,
@@ -104,7 +106,7 @@ func (ctr *ResourcesController) query(c web.APIContext) (err error) {
 // delete handler
 func (ctr *ResourcesController) delete(c web.APIContext) (err error) {
 	err = ctr.authAdminService.DeleteResource(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		c.Param("namespace"),
 		c.Param("id"),
@@ -112,14 +114,16 @@ func (ctr *ResourcesController) delete(c web.APIContext) (err error) {
 	if err != nil {
 		return err
 	}
-	return c.JSON(http.StatusOK, &services.DeleteResourceResponse{})
+	return c.JSON(http.StatusOK, &services.DeleteResourceResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	})
 }
 
 // queryAllocatedInstances handler
 func (ctr *ResourcesController) queryAllocatedInstances(c web.APIContext) (err error) {
 	predicates, offset, limit := toPredicates(c, "name") // no id as it will search for instance-id
 	instances, nextOffset, err := ctr.authAdminService.QueryResourceInstances(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		c.Param("namespace"),
 		c.Param("id"),
@@ -137,7 +141,7 @@ func (ctr *ResourcesController) queryAllocatedInstances(c web.APIContext) (err e
 // allocatedInstancesCount handler
 func (ctr *ResourcesController) allocatedInstancesCount(c web.APIContext) (err error) {
 	capacity, allocated, err := ctr.authAdminService.CountResourceInstances(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		c.Param("namespace"),
 		c.Param("id"),
@@ -1,7 +1,6 @@
 package controller
 
 import (
-	"context"
 	"encoding/json"
 	"github.com/bhatti/PlexAuthZ/api/v1/services"
 	"github.com/bhatti/PlexAuthZ/api/v1/types"
@@ -50,14 +49,15 @@ func (ctr *RolesController) create(c web.APIContext) error {
 	}
 	role.Namespace = c.Param("namespace")
 	role, err = ctr.authAdminService.CreateRole(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		role)
 	if err != nil {
 		return err
 	}
 	return c.JSON(http.StatusOK, &services.CreateRoleResponse{
-		Id: role.Id,
+		Id:               role.Id,
+		ConsistencyToken: domain.NewConsistencyToken(),
 	})
 }
 
@@ -75,19 +75,21 @@ func (ctr *RolesController) update(c web.APIContext) error {
 	role.Id = c.Param("id")
 	role.Namespace = c.Param("namespace")
 	if err = ctr.authAdminService.UpdateRole(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		role); err != nil {
 		return err
 	}
-	return c.JSON(http.StatusOK, &services.UpdateRoleResponse{})
+	return c.JSON(http.StatusOK, &services.UpdateRoleResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	})
 }
 
 // query handler
 func (ctr *RolesController) query(c web.APIContext) (err error) {
 	predicates, offset, limit := toPredicates(c, "id", "name")
 	res, nextOffset, err := ctr.authAdminService.GetRoles(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		c.Param("namespace"),
 		predicates,
@@ -104,7 +106,7 @@ func (ctr *RolesController) query(c web.APIContext) (err error) {
 // delete handler
 func (ctr *RolesController) delete(c web.APIContext) (err error) {
 	err = ctr.authAdminService.DeleteRole(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		c.Param("namespace"),
 		c.Param("id"),
@@ -112,7 +114,9 @@ func (ctr *RolesController) delete(c web.APIContext) (err error) {
 	if err != nil {
 		return err
 	}
-	return c.JSON(http.StatusOK, &services.DeleteRoleResponse{})
+	return c.JSON(http.StatusOK, &services.DeleteRoleResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	})
 }
 
 // addPermissions handler
@@ -126,7 +130,7 @@ func (ctr *RolesController) addPermissions(c web.APIContext) (err error) {
 		return err
 	}
 	if err = ctr.authAdminService.AddPermissionsToRole(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		c.Param("namespace"),
 		c.Param("id"),
@@ -134,7 +138,9 @@ func (ctr *RolesController) addPermissions(c web.APIContext) (err error) {
 	); err != nil {
 		return err
 	}
-	return c.JSON(http.StatusOK, &services.AddPermissionsToRoleResponse{})
+	return c.JSON(http.StatusOK, &services.AddPermissionsToRoleResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	})
 }
 
 // deletePermissions handler
@@ -148,7 +154,7 @@ func (ctr *RolesController) deletePermissions(c web.APIContext) (err error) {
 		return err
 	}
 	if err = ctr.authAdminService.DeletePermissionsToRole(
-		context.Background(),
+		subjectContext(c),
 		c.Param("organization_id"),
 		c.Param("namespace"),
 		c.Param("id"),
@@ -156,5 +162,7 @@ func (ctr *RolesController) deletePermissions(c web.APIContext) (err error) {
 	); err != nil {
 		return err
 	}
-	return c.JSON(http.StatusOK, &services.DeletePermissionsToRoleResponse{})
+	return c.JSON(http.StatusOK, &services.DeletePermissionsToRoleResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	})
 }
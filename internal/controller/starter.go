@@ -1,7 +1,10 @@
 package controller
 
 import (
+	"github.com/bhatti/PlexAuthZ/internal/authz"
 	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/metrics"
+	"github.com/bhatti/PlexAuthZ/internal/ratelimit"
 	"github.com/bhatti/PlexAuthZ/internal/service"
 	"github.com/bhatti/PlexAuthZ/internal/web"
 )
@@ -11,7 +14,25 @@ func StartControllers(
 	config *domain.Config,
 	authService service.AuthAdminService,
 	webServer web.Server,
+	metricsRegistry *metrics.Registry,
+	rateLimiter *ratelimit.RateLimiter,
 ) error {
+	if config.HttpAuth.Enabled {
+		authenticators, err := web.NewAuthenticators(config)
+		if err != nil {
+			return err
+		}
+		authorizer, err := authz.CreateAuthorizer(authz.AdminAuthorizerKind(config), config, authService)
+		if err != nil {
+			return err
+		}
+		webServer.AddMiddleware(web.AuthenticationMiddleware(authenticators...))
+		webServer.AddMiddleware(AuthorizationMiddleware(authorizer))
+	}
+	if rateLimiter != nil {
+		webServer.AddMiddleware(RateLimitMiddleware(rateLimiter))
+	}
+
 	// Start controllers
 	if _, err := NewAuthController(
 		config,
@@ -54,5 +75,39 @@ func StartControllers(
 		config,
 		authService,
 		webServer)
+
+	if _, err := NewDecisionsController(
+		config,
+		webServer); err != nil {
+		return err
+	}
+
+	if _, err := NewAuditController(
+		config,
+		metricsRegistry,
+		webServer); err != nil {
+		return err
+	}
+
+	if _, err := NewWatchController(
+		config,
+		metricsRegistry,
+		webServer); err != nil {
+		return err
+	}
+
+	if _, err := NewWebhooksController(
+		config,
+		metricsRegistry,
+		webServer); err != nil {
+		return err
+	}
+
+	if _, err := NewKubernetesController(
+		config,
+		authService,
+		webServer); err != nil {
+		return err
+	}
 	return nil
 }
@@ -2,6 +2,7 @@ package controller
 
 import (
 	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/metrics"
 	"github.com/bhatti/PlexAuthZ/internal/web"
 	"github.com/stretchr/testify/require"
 	"testing"
@@ -11,7 +12,7 @@ func Test_ShouldSucceedWithControllersRegistration(t *testing.T) {
 	webServer := web.NewStubWebServer()
 	to, _, err := newTestAuthController()
 	require.NoError(t, err)
-	require.NoError(t, StartControllers(to.config, to.authService, webServer))
+	require.NoError(t, StartControllers(to.config, to.authService, webServer, metrics.New(), nil))
 }
 
 func Test_ShouldSucceedWithSetupWebServerForTesting(t *testing.T) {
@@ -5,6 +5,7 @@ import (
 	"github.com/bhatti/PlexAuthZ/api/v1/types"
 	"github.com/bhatti/PlexAuthZ/internal/domain"
 	"github.com/bhatti/PlexAuthZ/internal/metrics"
+	"github.com/bhatti/PlexAuthZ/internal/ratelimit"
 	"github.com/bhatti/PlexAuthZ/internal/service"
 	"github.com/bhatti/PlexAuthZ/internal/service/db"
 	"github.com/bhatti/PlexAuthZ/internal/web"
@@ -22,13 +23,16 @@ func SetupWebServerForTesting(
 	teardown func()) {
 	t.Helper()
 
-	webServer := web.NewDefaultWebServer(cfg)
+	metricsRegistry := metrics.New()
+	webServer := web.NewDefaultWebServer(cfg, metricsRegistry)
 
 	client = web.NewHTTPClient(cfg)
-	serverAuthService, _, err := db.CreateDatabaseAuthService(cfg, metrics.New())
+	serverAuthService, _, err := db.CreateDatabaseAuthService(cfg, metricsRegistry)
+	require.NoError(t, err)
+	rateLimiter, err := ratelimit.CreateRateLimiter(cfg, serverAuthService, metricsRegistry)
 	require.NoError(t, err)
 
-	err = StartControllers(cfg, serverAuthService, webServer)
+	err = StartControllers(cfg, serverAuthService, webServer, metricsRegistry, rateLimiter)
 	require.NoError(t, err)
 
 	if fn != nil {
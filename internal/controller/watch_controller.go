@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/events"
+	"github.com/bhatti/PlexAuthZ/internal/metrics"
+	"github.com/bhatti/PlexAuthZ/internal/web"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// keepAliveInterval of comments sent to idle watches so that proxies don't close them.
+const keepAliveInterval = 15 * time.Second
+
+// WatchController - streams change events of organizations as server-sent events
+type WatchController struct {
+	config *domain.Config
+	broker *events.Broker
+}
+
+// NewWatchController instantiates controller for streaming change events
+func NewWatchController(
+	config *domain.Config,
+	metricsRegistry *metrics.Registry,
+	webserver web.Server) (*WatchController, error) {
+	broker, err := events.Shared(config, metricsRegistry)
+	if err != nil {
+		return nil, err
+	}
+	ctrl := &WatchController{
+		config: config,
+		broker: broker,
+	}
+	webserver.GET("/api/v1/:organization_id/watch", ctrl.watch)
+	return ctrl, nil
+}
+
+// watch handler, which resumes from cursor query parameter or Last-Event-ID header.
+func (ctr *WatchController) watch(c web.APIContext) (err error) {
+	cursorParam := c.QueryParam("cursor")
+	if cursorParam == "" {
+		cursorParam = c.Request().Header.Get("Last-Event-ID")
+	}
+	var cursor int64
+	if cursorParam != "" {
+		if cursor, err = strconv.ParseInt(cursorParam, 10, 64); err != nil {
+			return domain.NewValidationError(fmt.Sprintf("invalid cursor %s", cursorParam))
+		}
+	}
+	var entityTypes []types.ChangeEntityType
+	for _, name := range c.QueryParams()["entity_type"] {
+		entityType, ok := types.ChangeEntityType_value[name]
+		if !ok {
+			return domain.NewValidationError(fmt.Sprintf("invalid entity type %s", name))
+		}
+		entityTypes = append(entityTypes, types.ChangeEntityType(entityType))
+	}
+	sub, replay, err := ctr.broker.Subscribe(
+		c.Param("organization_id"),
+		c.QueryParam("namespace"),
+		entityTypes,
+		cursor)
+	if err != nil {
+		return err
+	}
+	defer ctr.broker.Unsubscribe(sub)
+
+	res := c.Response()
+	res.Header().Set("Content-Type", "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+	for _, event := range replay {
+		if err = writeEvent(res, event); err != nil {
+			return nil
+		}
+		cursor = event.Cursor
+	}
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case <-keepAlive.C:
+			if _, err = res.Write([]byte(": keep-alive\n\n")); err != nil {
+				return nil
+			}
+			res.Flush()
+		case event, ok := <-sub.Events():
+			if !ok {
+				_, _ = fmt.Fprintf(res, "event: error\ndata: watch fell behind changes, resume from cursor %d\n\n", cursor)
+				res.Flush()
+				return nil
+			}
+			if event.Cursor <= cursor {
+				continue
+			}
+			if err = writeEvent(res, event); err != nil {
+				return nil
+			}
+			cursor = event.Cursor
+		}
+	}
+}
+
+func writeEvent(res http.ResponseWriter, event *types.ChangeEvent) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if _, err = fmt.Fprintf(res, "id: %d\nevent: %s\ndata: %s\n\n", event.Cursor, event.Operation, b); err != nil {
+		return err
+	}
+	res.(http.Flusher).Flush()
+	return nil
+}
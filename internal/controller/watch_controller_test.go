@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/bhatti/PlexAuthZ/api/v1/services"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func Test_ShouldStreamChangesAsServerSentEvents(t *testing.T) {
+	// GIVEN web server and organization
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	cfg.Dir = "../../config"
+	cfg.HttpListenPort = "127.0.0.1:17784"
+	_, teardown := SetupWebServerForTesting(t, cfg, nil)
+	defer teardown()
+	client := &http.Client{}
+	baseURL := "http://" + cfg.HttpListenPort + "/api/v1/"
+	status, body := invokeTestAPI(t, client, http.MethodPost, baseURL+"organizations", nil,
+		[]byte(`{"name": "watch-org", "namespaces": ["admin"]}`))
+	require.Equal(t, http.StatusOK, status)
+	orgRes := &services.CreateOrganizationResponse{}
+	require.NoError(t, json.Unmarshal(body, orgRes))
+	watchURL := baseURL + orgRes.Id + "/watch?entity_type=ROLE"
+
+	// WHEN watching roles and creating a role
+	res, err := client.Get(watchURL)
+	require.NoError(t, err)
+	defer func() {
+		_ = res.Body.Close()
+	}()
+	require.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+	status, body = invokeTestAPI(t, client, http.MethodPost, baseURL+orgRes.Id+"/admin/roles", nil,
+		[]byte(`{"name": "reader"}`))
+	require.Equal(t, http.StatusOK, status)
+	roleRes := &services.CreateRoleResponse{}
+	require.NoError(t, json.Unmarshal(body, roleRes))
+
+	// THEN it should receive the change as server-sent event
+	id, name, event := readServerSentEvent(t, bufio.NewReader(res.Body))
+	require.Equal(t, "CREATED", name)
+	require.Equal(t, roleRes.Id, event.EntityId)
+	require.Equal(t, types.ChangeEntityType_ROLE, event.EntityType)
+	require.Equal(t, strconv.FormatInt(event.Cursor, 10), id)
+
+	// AND watch should be resumed with Last-Event-ID header
+	req, err := http.NewRequest(http.MethodGet, watchURL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Last-Event-ID", strconv.FormatInt(event.Cursor-1, 10))
+	resumed, err := client.Do(req)
+	require.NoError(t, err)
+	defer func() {
+		_ = resumed.Body.Close()
+	}()
+	_, _, replayed := readServerSentEvent(t, bufio.NewReader(resumed.Body))
+	require.Equal(t, event.Cursor, replayed.Cursor)
+}
+
+func readServerSentEvent(t *testing.T, reader *bufio.Reader) (id string, name string, event *types.ChangeEvent) {
+	for {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			event = &types.ChangeEvent{}
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), event))
+		case line == "" && event != nil:
+			return
+		}
+	}
+}
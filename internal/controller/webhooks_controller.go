@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"encoding/json"
+	"github.com/bhatti/PlexAuthZ/api/v1/services"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/metrics"
+	"github.com/bhatti/PlexAuthZ/internal/web"
+	"github.com/bhatti/PlexAuthZ/internal/webhook"
+	"io"
+	"net/http"
+)
+
+// WebhooksController - manages webhooks of change events and their deliveries
+type WebhooksController struct {
+	config  *domain.Config
+	manager *webhook.Manager
+}
+
+// NewWebhooksController instantiates controller for managing webhooks
+func NewWebhooksController(
+	config *domain.Config,
+	metricsRegistry *metrics.Registry,
+	webserver web.Server) (*WebhooksController, error) {
+	ctrl := &WebhooksController{
+		config: config,
+	}
+	if config.Webhook.Enabled {
+		manager, err := webhook.Shared(config, metricsRegistry)
+		if err != nil {
+			return nil, err
+		}
+		ctrl.manager = manager
+	}
+	webserver.POST("/api/v1/:organization_id/webhooks", ctrl.create)
+	webserver.PUT("/api/v1/:organization_id/webhooks/:id", ctrl.update)
+	webserver.GET("/api/v1/:organization_id/webhooks", ctrl.query)
+	webserver.DELETE("/api/v1/:organization_id/webhooks/:id", ctrl.delete)
+	webserver.GET("/api/v1/:organization_id/webhooks/:webhook_id/deliveries", ctrl.queryDeliveries)
+	return ctrl, nil
+}
+
+// create handler
+func (ctr *WebhooksController) create(c web.APIContext) error {
+	if ctr.manager == nil {
+		return domain.NewValidationError("webhooks are not enabled")
+	}
+	b, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return err
+	}
+	req := &services.CreateWebhookRequest{}
+	if err = json.Unmarshal(b, req); err != nil {
+		return err
+	}
+	saved, err := ctr.manager.CreateWebhook(
+		subjectContext(c),
+		&types.Webhook{
+			OrganizationId: c.Param("organization_id"),
+			Url:            req.Url,
+			EntityTypes:    req.EntityTypes,
+			Secret:         req.Secret,
+		})
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, &services.CreateWebhookResponse{
+		Id: saved.Id,
+	})
+}
+
+// update handler
+func (ctr *WebhooksController) update(c web.APIContext) error {
+	if ctr.manager == nil {
+		return domain.NewValidationError("webhooks are not enabled")
+	}
+	b, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return err
+	}
+	req := &services.UpdateWebhookRequest{}
+	if err = json.Unmarshal(b, req); err != nil {
+		return err
+	}
+	if err = ctr.manager.UpdateWebhook(
+		subjectContext(c),
+		&types.Webhook{
+			Id:             c.Param("id"),
+			Version:        req.Version,
+			OrganizationId: c.Param("organization_id"),
+			Url:            req.Url,
+			EntityTypes:    req.EntityTypes,
+			Secret:         req.Secret,
+		}); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, &services.UpdateWebhookResponse{})
+}
+
+// query handler
+func (ctr *WebhooksController) query(c web.APIContext) (err error) {
+	if ctr.manager == nil {
+		return domain.NewValidationError("webhooks are not enabled")
+	}
+	predicates, offset, limit := toPredicates(c, "id", "url")
+	res, nextOffset, err := ctr.manager.QueryWebhooks(
+		subjectContext(c),
+		c.Param("organization_id"),
+		predicates,
+		offset,
+		limit,
+	)
+	if err != nil {
+		return err
+	}
+	c.Response().Header().Set(domain.NextOffsetHeader, nextOffset)
+	return c.JSON(http.StatusOK, res)
+}
+
+// delete handler
+func (ctr *WebhooksController) delete(c web.APIContext) (err error) {
+	if ctr.manager == nil {
+		return domain.NewValidationError("webhooks are not enabled")
+	}
+	if err = ctr.manager.DeleteWebhook(
+		subjectContext(c),
+		c.Param("organization_id"),
+		c.Param("id"),
+	); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, &services.DeleteWebhookResponse{})
+}
+
+// queryDeliveries handler, which filters deliveries by status such as DEAD_LETTER.
+func (ctr *WebhooksController) queryDeliveries(c web.APIContext) (err error) {
+	if ctr.manager == nil {
+		return domain.NewValidationError("webhooks are not enabled")
+	}
+	_, offset, limit := toPredicates(c)
+	res, nextOffset, err := ctr.manager.QueryDeliveries(
+		subjectContext(c),
+		c.Param("organization_id"),
+		c.Param("webhook_id"),
+		c.QueryParam("status"),
+		offset,
+		limit,
+	)
+	if err != nil {
+		return err
+	}
+	c.Response().Header().Set(domain.NextOffsetHeader, nextOffset)
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"encoding/json"
+	"github.com/bhatti/PlexAuthZ/api/v1/services"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_ShouldRecordDeadLettersOfRESTWebhooks(t *testing.T) {
+	// GIVEN web server with webhooks and a receiver that always fails
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	cfg.Dir = "../../config"
+	cfg.HttpListenPort = "127.0.0.1:17785"
+	cfg.Webhook.Enabled = true
+	cfg.Webhook.MaxAttempts = 2
+	cfg.Webhook.InitialBackoff = time.Millisecond
+	cfg.Webhook.AllowPrivateNetworks = true
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+	_, teardown := SetupWebServerForTesting(t, cfg, nil)
+	defer teardown()
+	client := &http.Client{}
+	baseURL := "http://" + cfg.HttpListenPort + "/api/v1/"
+	status, body := invokeTestAPI(t, client, http.MethodPost, baseURL+"organizations", nil,
+		[]byte(`{"name": "webhook-org", "namespaces": ["admin"]}`))
+	require.Equal(t, http.StatusOK, status)
+	orgRes := &services.CreateOrganizationResponse{}
+	require.NoError(t, json.Unmarshal(body, orgRes))
+
+	// WHEN creating webhook for principal changes and a principal
+	status, body = invokeTestAPI(t, client, http.MethodPost, baseURL+orgRes.Id+"/webhooks", nil,
+		[]byte(`{"url": "`+receiver.URL+`", "entity_types": [1], "secret": "webhook-secret"}`))
+	require.Equal(t, http.StatusOK, status)
+	webhookRes := &services.CreateWebhookResponse{}
+	require.NoError(t, json.Unmarshal(body, webhookRes))
+	status, _ = invokeTestAPI(t, client, http.MethodPost, baseURL+orgRes.Id+"/principals", nil,
+		[]byte(`{"username": "alice", "namespaces": ["admin"]}`))
+	require.Equal(t, http.StatusOK, status)
+
+	// THEN failed delivery should be recorded as dead letter
+	var deliveries []*types.WebhookDelivery
+	require.Eventually(t, func() bool {
+		status, body = invokeTestAPI(t, client, http.MethodGet,
+			baseURL+orgRes.Id+"/webhooks/"+webhookRes.Id+"/deliveries?status=DEAD_LETTER", nil, nil)
+		return status == http.StatusOK && json.Unmarshal(body, &deliveries) == nil && len(deliveries) == 1
+	}, 5*time.Second, 20*time.Millisecond)
+	require.Equal(t, int32(2), deliveries[0].Attempts)
+	require.Equal(t, int32(http.StatusServiceUnavailable), deliveries[0].ResponseCode)
+	require.Equal(t, types.ChangeEntityType_PRINCIPAL, deliveries[0].Event.EntityType)
+
+	// WHEN deleting webhook
+	status, _ = invokeTestAPI(t, client, http.MethodDelete, baseURL+orgRes.Id+"/webhooks/"+webhookRes.Id, nil, nil)
+	// THEN it should not fail
+	require.Equal(t, http.StatusOK, status)
+}
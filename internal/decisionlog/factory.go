@@ -0,0 +1,69 @@
+package decisionlog
+
+import (
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/metrics"
+	"github.com/bhatti/PlexAuthZ/internal/service/db"
+	"os"
+	"sync"
+)
+
+var shared = struct {
+	loggers map[*domain.Config]*Logger
+	lock    sync.Mutex
+}{loggers: make(map[*domain.Config]*Logger)}
+
+// Shared returns decision logger of the config, which is created once so that gRPC servers
+// and REST controllers of the process write to the same sinks.
+func Shared(config *domain.Config) (*Logger, error) {
+	shared.lock.Lock()
+	defer shared.lock.Unlock()
+	if logger := shared.loggers[config]; logger != nil {
+		return logger, nil
+	}
+	logger, err := CreateLogger(config, metrics.New())
+	if err != nil {
+		return nil, err
+	}
+	shared.loggers[config] = logger
+	return logger, nil
+}
+
+// CreateLogger factory for decision logger with the configured sinks, where recent decisions are
+// kept in memory for queries unless they are stored in the data store.
+func CreateLogger(
+	config *domain.Config,
+	metricsRegistry *metrics.Registry,
+) (*Logger, error) {
+	if err := config.DecisionLog.Validate(); err != nil {
+		return nil, err
+	}
+	var sinks []Sink
+	for _, kind := range config.DecisionLog.Sinks {
+		switch kind {
+		case domain.DataStoreDecisionLogSink:
+			store, err := db.CreateDataStore(config)
+			if err != nil {
+				return nil, err
+			}
+			sink, err := NewDataStoreSink(store, config.DecisionLog.Retention)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, sink)
+		case domain.FileDecisionLogSink:
+			sink, err := NewFileSink(
+				config.DecisionLog.File, int64(config.DecisionLog.MaxSizeMB)*1024*1024, config.DecisionLog.MaxBackups)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, sink)
+		case domain.StdoutDecisionLogSink:
+			sinks = append(sinks, NewWriterSink(os.Stdout))
+		}
+	}
+	if !config.DecisionLog.HasSink(domain.DataStoreDecisionLogSink) {
+		sinks = append(sinks, NewRecentSink(config.DecisionLog.RecentSize))
+	}
+	return NewLogger(config.DecisionLog, metricsRegistry, sinks...)
+}
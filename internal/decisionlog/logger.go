@@ -0,0 +1,227 @@
+package decisionlog
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/metrics"
+	log "github.com/sirupsen/logrus"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// AuthorizeDecisionKind for decisions of Authorize requests.
+	AuthorizeDecisionKind = "AUTHORIZE"
+
+	// CheckDecisionKind for decisions of Check requests.
+	CheckDecisionKind = "CHECK"
+
+	// redacted value of context keys that must not be recorded.
+	redacted = "REDACTED"
+
+	defaultQueryLimit = 100
+)
+
+// Sink interface for destinations of decision logs.
+type Sink interface {
+	// Write records the decision.
+	Write(ctx context.Context, decision *types.Decision) error
+
+	// Close flushes and releases resources of the sink.
+	Close() error
+}
+
+// Querier interface for sinks that can search recorded decisions.
+type Querier interface {
+	// Query finds a page of decisions of organization and namespace matching predicates starting at
+	// the offset, where next offset is empty after the last page.
+	Query(
+		ctx context.Context,
+		organizationId string,
+		namespace string,
+		predicates map[string]string,
+		offset string,
+		limit int64,
+	) (res []*types.Decision, nextOffset string, err error)
+}
+
+// Logger records decisions to sinks with a buffered pipeline so that requests are never
+// blocked by sinks, and decisions are dropped when the buffer is full.
+type Logger struct {
+	config          domain.DecisionLogConfig
+	sinks           []Sink
+	querier         Querier
+	pending         chan *types.Decision
+	done            chan struct{}
+	metricsRegistry *metrics.Registry
+	redactKeys      map[string]bool
+	closed          bool
+	lock            sync.RWMutex
+}
+
+// NewLogger constructor, where decisions are queried from the first sink that implements Querier.
+func NewLogger(
+	config domain.DecisionLogConfig,
+	metricsRegistry *metrics.Registry,
+	sinks ...Sink,
+) (*Logger, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	l := &Logger{
+		config:          config,
+		sinks:           sinks,
+		pending:         make(chan *types.Decision, config.BufferSize),
+		done:            make(chan struct{}),
+		metricsRegistry: metricsRegistry,
+		redactKeys:      make(map[string]bool),
+	}
+	for _, key := range config.RedactKeys {
+		l.redactKeys[strings.ToLower(key)] = true
+	}
+	for _, sink := range sinks {
+		if querier, ok := sink.(Querier); ok {
+			l.querier = querier
+			break
+		}
+	}
+	go l.run()
+	return l, nil
+}
+
+// Log hashes and redacts context of the decision and queues it for sinks if it's sampled.
+func (l *Logger) Log(decision *types.Decision) {
+	if decision.Effect == types.Effect_PERMITTED && l.config.SampleRate < 1 &&
+		rand.Float64() >= l.config.SampleRate {
+		l.metricsRegistry.Incr("decision_log_skipped", "org", decision.OrganizationId)
+		return
+	}
+	decision.ContextHash = HashContext(decision.Context)
+	decision.Context = l.redact(decision.Context)
+
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	if l.closed {
+		return
+	}
+	select {
+	case l.pending <- decision:
+	default:
+		l.metricsRegistry.Incr("decision_log_dropped", "org", decision.OrganizationId)
+	}
+}
+
+// Query finds a page of recent decisions of organization and namespace for principal and resource if
+// defined, where decisions of the page are sorted by created date in descending order.
+func (l *Logger) Query(
+	ctx context.Context,
+	organizationId string,
+	namespace string,
+	principalId string,
+	resource string,
+	predicates map[string]string,
+	offset string,
+	limit int64,
+) ([]*types.Decision, string, error) {
+	if l.querier == nil {
+		return nil, "", domain.NewValidationError("decision logs cannot be queried from the configured sinks")
+	}
+	matching := make(map[string]string)
+	for k, v := range predicates {
+		matching[k] = v
+	}
+	if principalId != "" {
+		matching["principal_id"] = principalId
+	}
+	if resource != "" {
+		matching["resource"] = resource
+	}
+	if limit <= 0 {
+		limit = defaultQueryLimit
+	}
+	res, nextOffset, err := l.querier.Query(ctx, organizationId, namespace, matching, offset, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Created.AsTime().After(res[j].Created.AsTime())
+	})
+	return res, nextOffset, nil
+}
+
+// Close writes pending decisions and closes sinks.
+func (l *Logger) Close() error {
+	l.lock.Lock()
+	if l.closed {
+		l.lock.Unlock()
+		return nil
+	}
+	l.closed = true
+	close(l.pending)
+	l.lock.Unlock()
+	<-l.done
+	var err error
+	for _, sink := range l.sinks {
+		if closeErr := sink.Close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+func (l *Logger) run() {
+	defer close(l.done)
+	for decision := range l.pending {
+		for _, sink := range l.sinks {
+			if err := sink.Write(context.Background(), decision); err != nil {
+				l.metricsRegistry.Incr("decision_log_errors", "org", decision.OrganizationId)
+				log.WithFields(log.Fields{
+					"Component": "DecisionLogger",
+					"Decision":  decision.Id,
+					"Error":     err,
+				}).Warnf("failed to write decision log")
+			}
+		}
+		l.metricsRegistry.Incr("decision_log_recorded", "org", decision.OrganizationId)
+	}
+}
+
+func (l *Logger) redact(context map[string]string) map[string]string {
+	if !l.config.IncludeContext || len(context) == 0 {
+		return nil
+	}
+	res := make(map[string]string, len(context))
+	for k, v := range context {
+		if l.redactKeys[strings.ToLower(k)] {
+			res[k] = redacted
+		} else {
+			res[k] = v
+		}
+	}
+	return res
+}
+
+// HashContext returns sha256 of sorted context so that decisions with same context can be correlated.
+func HashContext(context map[string]string) string {
+	if len(context) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(context))
+	for k := range context {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	for _, k := range keys {
+		h.Write([]byte(k))
+		h.Write([]byte{0})
+		h.Write([]byte(context[k]))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
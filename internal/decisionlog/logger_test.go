@@ -0,0 +1,181 @@
+package decisionlog
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/metrics"
+	"github.com/bhatti/PlexAuthZ/internal/repository/redis"
+	"github.com/stretchr/testify/require"
+	"github.com/twinj/uuid"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_ShouldRecordAndQueryRecentDecisions(t *testing.T) {
+	// GIVEN decision logger with redaction and memory sink
+	ctx := context.TODO()
+	file := filepath.Join(t.TempDir(), "decisions.log")
+	fileSink, err := NewFileSink(file, 1024*1024, 2)
+	require.NoError(t, err)
+	logger, err := NewLogger(domain.DecisionLogConfig{
+		IncludeContext: true,
+		RedactKeys:     []string{"Password"},
+	}, metrics.New(), fileSink, NewRecentSink(2))
+	require.NoError(t, err)
+
+	// WHEN logging decisions
+	started := time.Now()
+	for i, principal := range []string{"alice", "bob", "alice"} {
+		logger.Log(&types.Decision{
+			Id:             uuid.NewV4().String(),
+			OrganizationId: "org",
+			Namespace:      "ns",
+			PrincipalId:    principal,
+			Resource:       "file",
+			Action:         "read",
+			Context:        map[string]string{"ip": "10.0.0.1", "password": "secret"},
+			Created:        timestamppb.New(started.Add(time.Duration(i) * time.Second)),
+		})
+	}
+	require.NoError(t, logger.Close())
+
+	// THEN recent decisions should be searchable by principal in descending order
+	res, _, err := logger.Query(ctx, "org", "ns", "alice", "", nil, "", 0)
+	require.NoError(t, err)
+	require.Len(t, res, 1) // oldest decision is evicted from recent decisions
+	require.Equal(t, "alice", res[0].PrincipalId)
+	res, _, err = logger.Query(ctx, "org", "ns", "", "file", map[string]string{"action": "read"}, "", 0)
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+	require.True(t, res[0].Created.AsTime().After(res[1].Created.AsTime()))
+	res, _, err = logger.Query(ctx, "other-org", "ns", "alice", "", nil, "", 0)
+	require.NoError(t, err)
+	require.Len(t, res, 0)
+
+	// AND context should be hashed and redacted
+	res, next, err := logger.Query(ctx, "org", "ns", "", "", nil, "", 1)
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	require.Equal(t, "1", next)
+	require.Equal(t, HashContext(map[string]string{"ip": "10.0.0.1", "password": "secret"}), res[0].ContextHash)
+	require.Equal(t, "REDACTED", res[0].Context["password"])
+	require.Equal(t, "10.0.0.1", res[0].Context["ip"])
+
+	// AND next page should return older decision
+	res, next, err = logger.Query(ctx, "org", "ns", "", "", nil, next, 1)
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	require.Equal(t, "", next)
+	require.Equal(t, "bob", res[0].PrincipalId)
+
+	// AND all decisions should be written to the file as json lines
+	f, err := os.Open(file)
+	require.NoError(t, err)
+	defer func() {
+		_ = f.Close()
+	}()
+	lines := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		decision := &types.Decision{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), decision))
+		require.Equal(t, "REDACTED", decision.Context["password"])
+		lines++
+	}
+	require.Equal(t, 3, lines)
+}
+
+func Test_ShouldSampleOnlyPermittedDecisions(t *testing.T) {
+	// GIVEN decision logger with low sample rate
+	recent := NewRecentSink(100)
+	logger, err := NewLogger(domain.DecisionLogConfig{SampleRate: 0.000001}, metrics.New(), recent)
+	require.NoError(t, err)
+
+	// WHEN logging permitted and denied decisions
+	for i := 0; i < 10; i++ {
+		logger.Log(&types.Decision{OrganizationId: "org", Effect: types.Effect_PERMITTED, Created: timestamppb.Now()})
+		logger.Log(&types.Decision{OrganizationId: "org", Effect: types.Effect_DENIED, Created: timestamppb.Now()})
+	}
+	require.NoError(t, logger.Close())
+
+	// THEN denied decisions should always be recorded without context unless included
+	res, _, err := logger.Query(context.TODO(), "org", "", "", "", nil, "", 0)
+	require.NoError(t, err)
+	require.Len(t, res, 10)
+	for _, decision := range res {
+		require.Equal(t, types.Effect_DENIED, decision.Effect)
+		require.Nil(t, decision.Context)
+	}
+}
+
+func Test_ShouldRotateDecisionLogFiles(t *testing.T) {
+	// GIVEN file sink with small size
+	file := filepath.Join(t.TempDir(), "decisions.log")
+	sink, err := NewFileSink(file, 200, 2)
+	require.NoError(t, err)
+
+	// WHEN writing decisions more than size of the file
+	for i := 0; i < 10; i++ {
+		require.NoError(t, sink.Write(context.TODO(), &types.Decision{
+			Id: uuid.NewV4().String(), OrganizationId: "org", PrincipalId: "alice"}))
+	}
+	require.NoError(t, sink.Close())
+
+	// THEN files should be rotated up to maximum backups
+	for _, name := range []string{file, file + ".1", file + ".2"} {
+		info, err := os.Stat(name)
+		require.NoError(t, err)
+		require.True(t, info.Size() <= 200)
+	}
+	_, err = os.Stat(file + ".3")
+	require.True(t, os.IsNotExist(err))
+}
+
+func Test_ShouldStoreAndQueryDecisionsInDataStore(t *testing.T) {
+	// GIVEN data store sink
+	ctx := context.TODO()
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	store, err := redis.NewRedisStore(cfg)
+	require.NoError(t, err)
+	sink, err := NewDataStoreSink(store, time.Minute)
+	require.NoError(t, err)
+	org := uuid.NewV4().String()
+
+	// WHEN storing decisions
+	for i := 0; i < 30; i++ {
+		principal := "alice"
+		if i%3 == 0 {
+			principal = "bob"
+		}
+		require.NoError(t, sink.Write(ctx, &types.Decision{
+			Id: uuid.NewV4().String(), OrganizationId: org, Namespace: "ns", PrincipalId: principal,
+			Resource: "file", Action: "read", Created: timestamppb.Now()}))
+	}
+
+	// THEN it should find pages of decisions by principal
+	all := make(map[string]bool)
+	next := ""
+	for pages := 0; ; pages++ {
+		res, nextOffset, err := sink.Query(ctx, org, "ns", map[string]string{"principal_id": "bob"}, next, 4)
+		require.NoError(t, err)
+		require.True(t, len(res) <= 4)
+		for _, decision := range res {
+			require.Equal(t, "bob", decision.PrincipalId)
+			all[decision.Id] = true
+		}
+		if nextOffset == "" {
+			break
+		}
+		next = nextOffset
+		require.True(t, pages < 10)
+	}
+	require.Len(t, all, 10)
+	require.NoError(t, store.ClearTable("Decision", "", org, "ns"))
+}
@@ -0,0 +1,241 @@
+package decisionlog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/repository"
+	"github.com/bhatti/PlexAuthZ/internal/utils"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// WriterSink writes decisions as JSON lines such as to stdout.
+type WriterSink struct {
+	writer io.Writer
+	lock   sync.Mutex
+}
+
+// NewWriterSink constructor
+func NewWriterSink(writer io.Writer) *WriterSink {
+	return &WriterSink{writer: writer}
+}
+
+// Write appends decision as JSON line.
+func (s *WriterSink) Write(_ context.Context, decision *types.Decision) error {
+	b, err := json.Marshal(decision)
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, err = s.writer.Write(append(b, '\n'))
+	return err
+}
+
+// Close is no-op as the writer is owned by the caller.
+func (s *WriterSink) Close() error {
+	return nil
+}
+
+// FileSink appends decisions as JSON lines to a file, which is renamed to <file>.1 when it exceeds
+// maximum size and older files are shifted up to the maximum backups.
+type FileSink struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+	lock       sync.Mutex
+}
+
+// NewFileSink constructor
+func NewFileSink(path string, maxSize int64, maxBackups int) (*FileSink, error) {
+	s := &FileSink{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Write appends decision as JSON line and rotates the file if needed.
+func (s *FileSink) Write(_ context.Context, decision *types.Decision) error {
+	b, err := json.Marshal(decision)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.size > 0 && s.size+int64(len(b)) > s.maxSize {
+		if err = s.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := s.file.Write(b)
+	s.size += int64(n)
+	return err
+}
+
+// Close closes the file.
+func (s *FileSink) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.file.Close()
+}
+
+func (s *FileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	s.file = file
+	s.size = info.Size()
+	return nil
+}
+
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	_ = os.Remove(s.backup(s.maxBackups))
+	for i := s.maxBackups - 1; i > 0; i-- {
+		_ = os.Rename(s.backup(i), s.backup(i+1))
+	}
+	if err := os.Rename(s.path, s.backup(1)); err != nil {
+		return err
+	}
+	return s.open()
+}
+
+func (s *FileSink) backup(i int) string {
+	return fmt.Sprintf("%s.%d", s.path, i)
+}
+
+// RecentSink keeps most recent decisions in memory for queries.
+type RecentSink struct {
+	decisions []*types.Decision
+	next      int
+	lock      sync.RWMutex
+}
+
+// NewRecentSink constructor
+func NewRecentSink(size int) *RecentSink {
+	return &RecentSink{decisions: make([]*types.Decision, 0, size)}
+}
+
+// Write adds decision and replaces the oldest decision when full.
+func (s *RecentSink) Write(_ context.Context, decision *types.Decision) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.decisions) < cap(s.decisions) {
+		s.decisions = append(s.decisions, decision)
+		return nil
+	}
+	s.decisions[s.next] = decision
+	s.next = (s.next + 1) % len(s.decisions)
+	return nil
+}
+
+// Query finds a page of decisions of organization and namespace matching predicates, which are sorted
+// by created date in descending order and the offset is the index of the next matching decision.
+func (s *RecentSink) Query(
+	_ context.Context,
+	organizationId string,
+	namespace string,
+	predicates map[string]string,
+	offset string,
+	limit int64,
+) (res []*types.Decision, nextOffset string, err error) {
+	start := 0
+	if offset != "" {
+		if start, err = strconv.Atoi(offset); err != nil || start < 0 {
+			return nil, "", domain.NewValidationError(fmt.Sprintf("invalid offset %s", offset))
+		}
+	}
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	for _, decision := range s.decisions {
+		if decision.OrganizationId != organizationId || decision.Namespace != namespace {
+			continue
+		}
+		b, err := json.Marshal(decision)
+		if err != nil {
+			return nil, "", err
+		}
+		if utils.MatchPredicate(b, predicates) {
+			res = append(res, decision)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Created.AsTime().After(res[j].Created.AsTime())
+	})
+	if start >= len(res) {
+		return nil, "", nil
+	}
+	res = res[start:]
+	if limit > 0 && int64(len(res)) > limit {
+		res = res[0:limit]
+		nextOffset = strconv.Itoa(start + int(limit))
+	}
+	return
+}
+
+// Close is no-op.
+func (s *RecentSink) Close() error {
+	return nil
+}
+
+// DataStoreSink stores decisions in the data store, which expire after retention.
+type DataStoreSink struct {
+	repository repository.Repository[types.Decision]
+}
+
+// NewDataStoreSink constructor
+func NewDataStoreSink(store repository.DataStore, retention time.Duration) (*DataStoreSink, error) {
+	repo, err := repository.NewDecisionRepository(store, retention)
+	if err != nil {
+		return nil, err
+	}
+	return &DataStoreSink{repository: repo}, nil
+}
+
+// Write stores decision.
+func (s *DataStoreSink) Write(ctx context.Context, decision *types.Decision) error {
+	return s.repository.Create(
+		ctx,
+		decision.OrganizationId,
+		decision.Namespace,
+		decision.Id,
+		decision,
+		time.Duration(0))
+}
+
+// Query finds a page of decisions of organization and namespace matching predicates, which are
+// filtered and limited by the data store.
+func (s *DataStoreSink) Query(
+	ctx context.Context,
+	organizationId string,
+	namespace string,
+	predicates map[string]string,
+	offset string,
+	limit int64,
+) ([]*types.Decision, string, error) {
+	return s.repository.Query(ctx, organizationId, namespace, predicates, offset, limit)
+}
+
+// Close is no-op.
+func (s *DataStoreSink) Close() error {
+	return nil
+}
@@ -4,6 +4,8 @@ import (
 	"github.com/bhatti/PlexAuthZ/api/v1/types"
 	"github.com/bhatti/PlexAuthZ/internal/utils"
 	"github.com/twinj/uuid"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"time"
 )
 
 // OrganizationBuilder that owns roles, groups, relations, and principals for a given namespace.
@@ -16,6 +18,10 @@ type OrganizationBuilder struct {
 	Namespaces []string
 	// url for organization.
 	Url string
+	// RateLimit overrides configured rate limit for organization.
+	RateLimit *types.RateLimit
+	// AuditRetention overrides configured retention of audit records for organization.
+	AuditRetention time.Duration
 }
 
 // NewOrganizationBuilder constructor
@@ -47,6 +53,18 @@ func (b *OrganizationBuilder) WithUrl(url string) *OrganizationBuilder {
 	return b
 }
 
+// WithRateLimit setter
+func (b *OrganizationBuilder) WithRateLimit(requestsPerSecond float64, burst int32) *OrganizationBuilder {
+	b.RateLimit = &types.RateLimit{RequestsPerSecond: requestsPerSecond, Burst: burst}
+	return b
+}
+
+// WithAuditRetention setter
+func (b *OrganizationBuilder) WithAuditRetention(retention time.Duration) *OrganizationBuilder {
+	b.AuditRetention = retention
+	return b
+}
+
 // Build helper
 func (b *OrganizationBuilder) Build() (*types.Organization, error) {
 	org := &types.Organization{
@@ -54,6 +72,10 @@ func (b *OrganizationBuilder) Build() (*types.Organization, error) {
 		Name:       b.Name,
 		Namespaces: b.Namespaces,
 		Url:        b.Url,
+		RateLimit:  b.RateLimit,
+	}
+	if b.AuditRetention > 0 {
+		org.AuditRetention = durationpb.New(b.AuditRetention)
 	}
 	if err := NewOrganizationExt(org).Validate(); err != nil {
 		return nil, err
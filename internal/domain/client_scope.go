@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"fmt"
+	"github.com/bhatti/PlexAuthZ/internal/utils"
+	"net/url"
+	"strings"
+)
+
+// ClientScopeURIScheme scheme of URI SAN in client certificates that binds the client to an
+// organization and its namespaces, e.g., plexauthz://org-id?namespace=admin&namespace=finance.
+const ClientScopeURIScheme = "plexauthz"
+
+// ClientScope binds an admin client to an organization and its namespaces.
+type ClientScope struct {
+	OrganizationId string
+	// Namespaces of the organization, where empty namespaces permit all namespaces.
+	Namespaces []string
+}
+
+// NewClientScope constructor
+func NewClientScope(organizationId string, namespaces ...string) *ClientScope {
+	return &ClientScope{
+		OrganizationId: organizationId,
+		Namespaces:     namespaces,
+	}
+}
+
+// ParseClientScopeURIs returns scope of the first URI with ClientScopeURIScheme.
+func ParseClientScopeURIs(uris []*url.URL) *ClientScope {
+	for _, uri := range uris {
+		if uri == nil || uri.Scheme != ClientScopeURIScheme || uri.Host == "" {
+			continue
+		}
+		return NewClientScope(uri.Host, uri.Query()["namespace"]...)
+	}
+	return nil
+}
+
+// ClientScopeURI returns URI SAN for the organization and namespaces.
+func ClientScopeURI(organizationId string, namespaces ...string) *url.URL {
+	return &url.URL{
+		Scheme:   ClientScopeURIScheme,
+		Host:     organizationId,
+		RawQuery: url.Values{"namespace": namespaces}.Encode(),
+	}
+}
+
+// Permits returns true if the organization and namespace are within the scope, where nil scope
+// permits everything and empty namespace is used for objects of the organization such as principals.
+func (s *ClientScope) Permits(organizationId string, namespace string) bool {
+	if s == nil {
+		return true
+	}
+	if organizationId != s.OrganizationId {
+		return false
+	}
+	return namespace == "" || len(s.Namespaces) == 0 || utils.Includes(s.Namespaces, namespace)
+}
+
+// String defines description of scope
+func (s *ClientScope) String() string {
+	if s == nil {
+		return "*"
+	}
+	if len(s.Namespaces) == 0 {
+		return s.OrganizationId
+	}
+	return fmt.Sprintf("%s/%s", s.OrganizationId, strings.Join(s.Namespaces, ","))
+}
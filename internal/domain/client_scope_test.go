@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"github.com/stretchr/testify/require"
+	"net/url"
+	"testing"
+)
+
+func Test_ShouldParseClientScopeURIs(t *testing.T) {
+	// GIVEN URI SANs of client certificate
+	other, err := url.Parse("spiffe://cluster/ns/default")
+	require.NoError(t, err)
+	uri := ClientScopeURI("org-1", "admin", "finance")
+
+	// WHEN parsing scope
+	scope := ParseClientScopeURIs([]*url.URL{other, uri})
+	// THEN it should return organization and namespaces
+	require.Equal(t, "org-1", scope.OrganizationId)
+	require.Equal(t, []string{"admin", "finance"}, scope.Namespaces)
+	require.Equal(t, "org-1/admin,finance", scope.String())
+
+	// WHEN parsing URIs without scope
+	// THEN it should return nil
+	require.Nil(t, ParseClientScopeURIs([]*url.URL{other}))
+}
+
+func Test_ShouldPermitWithinClientScope(t *testing.T) {
+	// GIVEN scopes with and without namespaces
+	var global *ClientScope
+	org := NewClientScope("org-1")
+	ns := NewClientScope("org-1", "admin")
+
+	// WHEN checking organization and namespace
+	// THEN it should only permit them within scope
+	require.True(t, global.Permits("", ""))
+	require.True(t, global.Permits("org-2", "finance"))
+	require.True(t, org.Permits("org-1", "finance"))
+	require.False(t, org.Permits("org-2", "finance"))
+	require.False(t, org.Permits("", ""))
+	require.True(t, ns.Permits("org-1", "admin"))
+	require.True(t, ns.Permits("org-1", ""))
+	require.False(t, ns.Permits("org-1", "finance"))
+}
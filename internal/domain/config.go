@@ -3,10 +3,12 @@ package domain
 import (
 	"crypto/tls"
 	"fmt"
+	"github.com/bhatti/PlexAuthZ/internal/metrics"
 	"github.com/bhatti/PlexAuthZ/internal/version"
 	"github.com/mitchellh/go-homedir"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
+	"math"
 	"os"
 	"path/filepath"
 	"strings"
@@ -25,6 +27,23 @@ const (
 
 	// MemoryPersistenceProvider uses in-memory
 	MemoryPersistenceProvider PersistenceProvider = "MEMORY"
+
+	// SQLPersistenceProvider uses relational database based on SQLDialect
+	SQLPersistenceProvider PersistenceProvider = "SQL"
+
+	// BoltPersistenceProvider uses embedded bbolt file
+	BoltPersistenceProvider PersistenceProvider = "BOLT"
+)
+
+// SQLDialect defines enum for relational databases.
+type SQLDialect string
+
+const (
+	// SQLiteDialect uses embedded SQLite database
+	SQLiteDialect SQLDialect = "SQLITE"
+
+	// PostgresDialect uses PostgreSQL database
+	PostgresDialect SQLDialect = "POSTGRES"
 )
 
 // AuthServiceProvider defines enum for auth service implementation
@@ -39,6 +58,9 @@ const (
 
 	// HttpAuthServiceProvider uses HTTP client based on PersistenceProvider
 	HttpAuthServiceProvider AuthServiceProvider = "HTTP"
+
+	// BundleAuthServiceProvider uses read-only signed bundle of an organization
+	BundleAuthServiceProvider AuthServiceProvider = "BUNDLE"
 )
 
 // DynamoDBConfig config
@@ -52,32 +74,358 @@ type DynamoDBConfig struct {
 	Endpoint            string `yaml:"endpoint" mapstructure:"endpoint" env:"DDB_ENDPOINT"`
 }
 
+// SQLConfig config of relational database, where DataSource is the file of SQLite or connection
+// string of PostgreSQL and expired records are deleted every CleanupInterval.
+type SQLConfig struct {
+	Dialect         SQLDialect    `yaml:"dialect" mapstructure:"dialect"`
+	DataSource      string        `yaml:"data_source" mapstructure:"data_source" env:"SQL_DATA_SOURCE"`
+	MaxOpenConns    int           `yaml:"max_open_conns" mapstructure:"max_open_conns"`
+	CleanupInterval time.Duration `yaml:"cleanup_interval" mapstructure:"cleanup_interval"`
+}
+
+// BoltConfig config of embedded bbolt database, where expired records are deleted every CleanupInterval.
+type BoltConfig struct {
+	Path            string        `yaml:"path" mapstructure:"path" env:"BOLT_PATH"`
+	CleanupInterval time.Duration `yaml:"cleanup_interval" mapstructure:"cleanup_interval"`
+}
+
 // RedisConfig redis config
 type RedisConfig struct {
 	Host     string `yaml:"host" mapstructure:"host"`
 	Port     int    `yaml:"port" mapstructure:"port"`
 	Password string `yaml:"password" mapstructure:"password"`
 	PoolSize int    `yaml:"pool_size" mapstructure:"pool_size"`
+	// IndexedFields are JSON fields of records such as username, name or resource_id that are
+	// indexed with sorted sets so that queries with equality or range predicates don't scan tables.
+	IndexedFields []string `yaml:"indexed_fields" mapstructure:"indexed_fields"`
+	// CleanupInterval for deleting expired records in background.
+	CleanupInterval time.Duration `yaml:"cleanup_interval" mapstructure:"cleanup_interval"`
+}
+
+// EnvoyAuthRule maps attributes of an Envoy ext_authz check request to an authorization request.
+// The Organization, Namespace, Principal, Resource, Action, Scope and Context values are
+// Go templates evaluated with .Method, .Path, .Host, .Query, .Headers, .Params (named groups
+// of PathRegex), .Claims (JWT claims), .MTLSPrincipal and .Subject.
+type EnvoyAuthRule struct {
+	Name         string            `yaml:"name" mapstructure:"name"`
+	Methods      []string          `yaml:"methods" mapstructure:"methods"`
+	PathPrefix   string            `yaml:"path_prefix" mapstructure:"path_prefix"`
+	PathRegex    string            `yaml:"path_regex" mapstructure:"path_regex"`
+	Organization string            `yaml:"organization" mapstructure:"organization"`
+	Namespace    string            `yaml:"namespace" mapstructure:"namespace"`
+	Principal    string            `yaml:"principal" mapstructure:"principal"`
+	Resource     string            `yaml:"resource" mapstructure:"resource"`
+	Action       string            `yaml:"action" mapstructure:"action"`
+	Scope        string            `yaml:"scope" mapstructure:"scope"`
+	Context      map[string]string `yaml:"context" mapstructure:"context"`
+}
+
+// EnvoyAuthConfig config for Envoy ext_authz server
+type EnvoyAuthConfig struct {
+	// JwtMetadataKey key of payload_in_metadata in the envoy.filters.http.jwt_authn metadata.
+	JwtMetadataKey string `yaml:"jwt_metadata_key" mapstructure:"jwt_metadata_key"`
+	// PrincipalClaim claim used for .Subject of JWT tokens.
+	PrincipalClaim string          `yaml:"principal_claim" mapstructure:"principal_claim"`
+	Rules          []EnvoyAuthRule `yaml:"rules" mapstructure:"rules"`
+}
+
+// KubernetesAuthRule maps attributes of a Kubernetes SubjectAccessReview to an authorization
+// request. A rule matches when the review matches all of the defined Verbs, APIGroups, Resources,
+// Namespaces or NonResourcePaths (prefixes), where "*" matches any value. The Organization,
+// Namespace, Principal, Resource, Action, Scope and Context values are Go templates evaluated
+// with .User, .UID, .Groups, .Extra, .Verb, .APIGroup, .Version, .Resource, .Subresource,
+// .Name, .ResourceNamespace and .Path (for non-resource requests).
+type KubernetesAuthRule struct {
+	Name             string            `yaml:"name" mapstructure:"name"`
+	Verbs            []string          `yaml:"verbs" mapstructure:"verbs"`
+	APIGroups        []string          `yaml:"api_groups" mapstructure:"api_groups"`
+	Resources        []string          `yaml:"resources" mapstructure:"resources"`
+	Namespaces       []string          `yaml:"namespaces" mapstructure:"namespaces"`
+	NonResourcePaths []string          `yaml:"non_resource_paths" mapstructure:"non_resource_paths"`
+	Organization     string            `yaml:"organization" mapstructure:"organization"`
+	Namespace        string            `yaml:"namespace" mapstructure:"namespace"`
+	Principal        string            `yaml:"principal" mapstructure:"principal"`
+	Resource         string            `yaml:"resource" mapstructure:"resource"`
+	Action           string            `yaml:"action" mapstructure:"action"`
+	Scope            string            `yaml:"scope" mapstructure:"scope"`
+	Context          map[string]string `yaml:"context" mapstructure:"context"`
+}
+
+// KubernetesAuthConfig config for Kubernetes authorization webhook
+type KubernetesAuthConfig struct {
+	// PrincipalLookup defines how the mapped principal is found, i.e., by username or id.
+	PrincipalLookup string `yaml:"principal_lookup" mapstructure:"principal_lookup"`
+	// GroupClaims uses groups of the review, matched by name, in addition to stored groups. It
+	// also allows users that are not registered as principals to be authorized by their groups.
+	GroupClaims bool                 `yaml:"group_claims" mapstructure:"group_claims"`
+	Rules       []KubernetesAuthRule `yaml:"rules" mapstructure:"rules"`
+}
+
+// CasbinPolicyStore defines enum for storage of casbin policies for admin APIs.
+type CasbinPolicyStore string
+
+const (
+	// FileCasbinPolicyStore uses static policy.csv file
+	FileCasbinPolicyStore CasbinPolicyStore = "FILE"
+
+	// DataStoreCasbinPolicyStore uses configured data store, which is bootstrapped from policy.csv file
+	DataStoreCasbinPolicyStore CasbinPolicyStore = "DATASTORE"
+)
+
+// CasbinPolicyConfig config for casbin policies of admin APIs
+type CasbinPolicyConfig struct {
+	Store CasbinPolicyStore `yaml:"store" mapstructure:"store"`
+	// Tenant partition of the data store for policies.
+	Tenant string `yaml:"tenant" mapstructure:"tenant"`
+	// ReloadInterval for checking changes of policies by other servers.
+	ReloadInterval time.Duration `yaml:"reload_interval" mapstructure:"reload_interval"`
+}
+
+// RateLimitKey defines enum for keys of rate limit buckets.
+type RateLimitKey string
+
+const (
+	// OrganizationRateLimitKey shares bucket of organization by all callers
+	OrganizationRateLimitKey RateLimitKey = "ORGANIZATION"
+
+	// PrincipalRateLimitKey uses bucket for each principal of organization, or the client for admin requests
+	PrincipalRateLimitKey RateLimitKey = "PRINCIPAL"
+
+	// ClientRateLimitKey uses bucket for each authenticated client or remote address of organization
+	ClientRateLimitKey RateLimitKey = "CLIENT"
+)
+
+// RateLimitStore defines enum for storage of rate limit buckets.
+type RateLimitStore string
+
+const (
+	// MemoryRateLimitStore keeps buckets in memory of each server
+	MemoryRateLimitStore RateLimitStore = "MEMORY"
+
+	// RedisRateLimitStore keeps buckets in Redis so that they are shared by servers
+	RedisRateLimitStore RateLimitStore = "REDIS"
+)
+
+// RateLimitConfig config for token-bucket rate limits of gRPC and REST requests, which are
+// overridden by rate_limit of the organization.
+type RateLimitConfig struct {
+	Enabled           bool           `yaml:"enabled" mapstructure:"enabled"`
+	RequestsPerSecond float64        `yaml:"requests_per_second" mapstructure:"requests_per_second"`
+	Burst             int32          `yaml:"burst" mapstructure:"burst"`
+	Key               RateLimitKey   `yaml:"key" mapstructure:"key"`
+	Store             RateLimitStore `yaml:"store" mapstructure:"store"`
+}
+
+// DecisionLogSink defines enum for destinations of decision logs.
+type DecisionLogSink string
+
+const (
+	// FileDecisionLogSink appends decisions as JSON lines to a file, which is rotated by size
+	FileDecisionLogSink DecisionLogSink = "FILE"
+
+	// StdoutDecisionLogSink writes decisions as JSON lines to stdout
+	StdoutDecisionLogSink DecisionLogSink = "STDOUT"
+
+	// DataStoreDecisionLogSink stores decisions in the configured data store so that they can be queried
+	DataStoreDecisionLogSink DecisionLogSink = "DATASTORE"
+)
+
+// DecisionLogConfig config for recording decisions of Authorize and Check requests.
+type DecisionLogConfig struct {
+	Enabled bool              `yaml:"enabled" mapstructure:"enabled"`
+	Sinks   []DecisionLogSink `yaml:"sinks" mapstructure:"sinks"`
+	// BufferSize of pending decisions, after which decisions are dropped instead of blocking requests.
+	BufferSize int `yaml:"buffer_size" mapstructure:"buffer_size"`
+	// SampleRate fraction of permitted decisions that are recorded, where denied decisions are always recorded.
+	SampleRate float64 `yaml:"sample_rate" mapstructure:"sample_rate"`
+	// IncludeContext records context values in addition to the hash of context after redacting RedactKeys.
+	IncludeContext bool     `yaml:"include_context" mapstructure:"include_context"`
+	RedactKeys     []string `yaml:"redact_keys" mapstructure:"redact_keys"`
+	// File and its rotation settings for the FILE sink.
+	File       string `yaml:"file" mapstructure:"file"`
+	MaxSizeMB  int    `yaml:"max_size_mb" mapstructure:"max_size_mb"`
+	MaxBackups int    `yaml:"max_backups" mapstructure:"max_backups"`
+	// Retention of decisions in the data store.
+	Retention time.Duration `yaml:"retention" mapstructure:"retention"`
+	// RecentSize of decisions kept in memory for queries when the DATASTORE sink is not used.
+	RecentSize int `yaml:"recent_size" mapstructure:"recent_size"`
+}
+
+// HasSink returns true if sink is configured.
+func (c *DecisionLogConfig) HasSink(sink DecisionLogSink) bool {
+	for _, next := range c.Sinks {
+		if next == sink {
+			return true
+		}
+	}
+	return false
+}
+
+// AuditConfig config for append-only audit records of administrative changes with the caller
+// and before/after diff of changed entities.
+type AuditConfig struct {
+	Enabled bool `yaml:"enabled" mapstructure:"enabled"`
+	// Retention of audit records, which can be overridden by audit retention of organization.
+	Retention time.Duration `yaml:"retention" mapstructure:"retention"`
+}
+
+// WatchConfig config for streaming change events, where recent events are kept in history so that
+// watches can be resumed from a cursor.
+type WatchConfig struct {
+	HistorySize int `yaml:"history_size" mapstructure:"history_size"`
+	// SubscriberBufferSize of pending events for each watch, which is closed when the buffer is full
+	// so that slow watchers resume from their cursor instead of blocking changes.
+	SubscriberBufferSize int `yaml:"subscriber_buffer_size" mapstructure:"subscriber_buffer_size"`
+}
+
+// CacheInvalidationBus defines enum for buses of cache invalidations.
+type CacheInvalidationBus string
+
+const (
+	// LocalCacheInvalidationBus evicts caches of services in the process
+	LocalCacheInvalidationBus CacheInvalidationBus = "LOCAL"
+
+	// RedisCacheInvalidationBus publishes invalidations with Redis pub/sub so that caches of all replicas are evicted
+	RedisCacheInvalidationBus CacheInvalidationBus = "REDIS"
+)
+
+// CacheInvalidationConfig config for evicting cached principals and organizations after changes.
+type CacheInvalidationConfig struct {
+	Bus     CacheInvalidationBus `yaml:"bus" mapstructure:"bus"`
+	Channel string               `yaml:"channel" mapstructure:"channel"`
+	// ReconnectInterval after failure of the subscription, where all caches are evicted after reconnecting
+	// because invalidations may have been missed.
+	ReconnectInterval time.Duration `yaml:"reconnect_interval" mapstructure:"reconnect_interval"`
+}
+
+// BundleConfig config for signed bundles of organizations, where PrivateKeyFile is used for exporting
+// bundles and PublicKeyFile for verifying them when auth service provider is BUNDLE.
+type BundleConfig struct {
+	Path           string `yaml:"path" mapstructure:"path"`
+	PrivateKeyFile string `yaml:"private_key_file" mapstructure:"private_key_file"`
+	PublicKeyFile  string `yaml:"public_key_file" mapstructure:"public_key_file"`
+}
+
+// WebhookConfig config for delivering change events to webhooks of organizations, where failed
+// deliveries are retried with exponential backoff and recorded as dead letters after MaxAttempts.
+type WebhookConfig struct {
+	Enabled        bool          `yaml:"enabled" mapstructure:"enabled"`
+	Workers        int           `yaml:"workers" mapstructure:"workers"`
+	QueueSize      int           `yaml:"queue_size" mapstructure:"queue_size"`
+	MaxAttempts    int           `yaml:"max_attempts" mapstructure:"max_attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff" mapstructure:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff" mapstructure:"max_backoff"`
+	Timeout        time.Duration `yaml:"timeout" mapstructure:"timeout"`
+	// DeliveryRetention of delivery records in the data store.
+	DeliveryRetention time.Duration `yaml:"delivery_retention" mapstructure:"delivery_retention"`
+	// CacheTTL of webhooks of an organization, which are also reloaded after changes on this server.
+	CacheTTL time.Duration `yaml:"cache_ttl" mapstructure:"cache_ttl"`
+	// AllowPrivateNetworks permits urls of loopback, link-local and private addresses, which should
+	// only be enabled when all organizations are trusted.
+	AllowPrivateNetworks bool `yaml:"allow_private_networks" mapstructure:"allow_private_networks"`
+}
+
+// SystemAuthConfig config for authorizing admin APIs with principals, roles and permissions of the
+// reserved system organization instead of casbin policies.
+type SystemAuthConfig struct {
+	Enabled bool `yaml:"enabled" mapstructure:"enabled"`
+	// OrganizationName name of the system organization, which is bootstrapped on first start.
+	OrganizationName string `yaml:"organization_name" mapstructure:"organization_name"`
+	Namespace        string `yaml:"namespace" mapstructure:"namespace"`
+	// Admins and Clients are usernames of bootstrapped principals for subjects with full
+	// access and query/auth access like root and client of casbin policies.
+	Admins  []string `yaml:"admins" mapstructure:"admins"`
+	Clients []string `yaml:"clients" mapstructure:"clients"`
+}
+
+// JWTAuthConfig config for validating JWT bearer tokens, which are signed with either the
+// symmetric SigningKey (HMAC), the private key of PublicKeyFile (RSA or ECDSA) or one of
+// the keys in JWKSFile (JSON Web Key Set) matching kid header of the token.
+type JWTAuthConfig struct {
+	SigningKey    string `yaml:"signing_key" mapstructure:"signing_key"`
+	PublicKeyFile string `yaml:"public_key_file" mapstructure:"public_key_file"`
+	JWKSFile      string `yaml:"jwks_file" mapstructure:"jwks_file"`
+	// Issuer and Audience are verified when defined.
+	Issuer   string `yaml:"issuer" mapstructure:"issuer"`
+	Audience string `yaml:"audience" mapstructure:"audience"`
+	// SubjectClaim claim used as subject of casbin policies.
+	SubjectClaim string `yaml:"subject_claim" mapstructure:"subject_claim"`
+	// OrganizationClaim and PrincipalClaim claims used as organization-id and principal-id
+	// of the user when authorizing on behalf of the user.
+	OrganizationClaim string `yaml:"organization_claim" mapstructure:"organization_claim"`
+	PrincipalClaim    string `yaml:"principal_claim" mapstructure:"principal_claim"`
+	// NamespacesClaim claim with namespaces, which along with OrganizationClaim limits scope of the
+	// admin client to the organization and its namespaces.
+	NamespacesClaim string `yaml:"namespaces_claim" mapstructure:"namespaces_claim"`
+}
+
+// APIKeyConfig maps a static API key to subject of casbin policies, which is optionally
+// limited to the organization and its namespaces.
+type APIKeyConfig struct {
+	Key            string   `yaml:"key" mapstructure:"key"`
+	Subject        string   `yaml:"subject" mapstructure:"subject"`
+	OrganizationId string   `yaml:"organization_id" mapstructure:"organization_id"`
+	Namespaces     []string `yaml:"namespaces" mapstructure:"namespaces"`
+}
+
+// Scope returns scope of the API key or nil if it's not limited to an organization.
+func (c APIKeyConfig) Scope() *ClientScope {
+	if c.OrganizationId == "" {
+		return nil
+	}
+	return NewClientScope(c.OrganizationId, c.Namespaces...)
+}
+
+// HttpAuthConfig config for authentication of REST APIs, where the subject of mTLS client
+// certificate, API key or JWT bearer token is authorized with casbin policies like gRPC APIs.
+type HttpAuthConfig struct {
+	Enabled bool `yaml:"enabled" mapstructure:"enabled"`
+	// MTLS serves HTTPS and uses common name of the verified client certificate as subject.
+	MTLS         bool           `yaml:"mtls" mapstructure:"mtls"`
+	APIKeyHeader string         `yaml:"api_key_header" mapstructure:"api_key_header"`
+	APIKeys      []APIKeyConfig `yaml:"api_keys" mapstructure:"api_keys"`
+	JWT          JWTAuthConfig  `yaml:"jwt" mapstructure:"jwt"`
+	// ClientAPIKey and ClientToken are sent by the HTTP client of auth service.
+	ClientAPIKey string `yaml:"client_api_key" mapstructure:"client_api_key"`
+	ClientToken  string `yaml:"client_token" mapstructure:"client_token"`
 }
 
 // Config -- Default Config
 type Config struct {
-	Redis                      RedisConfig         `yaml:"redis" env:"REDIS"`
-	DynamoDB                   DynamoDBConfig      `yaml:"ddb" env:"DYNAMODB"`
-	GrpcSasl                   bool                `yaml:"grpc_sasl"`
-	GrpcListenPort             string              `yaml:"grpc_listen_port" env:"GRPC_PORT"`
-	HttpListenPort             string              `yaml:"http_listen_port" env:"HTTP_PORT"`
-	ResourceInstanceExpiration time.Duration       `yaml:"resource_instance_expiration"`
-	HttpClientTimeout          time.Duration       `yaml:"http_client_timeout"`
-	Debug                      bool                `yaml:"debug"`
-	Dir                        string              `yaml:"dir" env:"CONFIG_DIR"`
-	PersistenceProvider        PersistenceProvider `yaml:"persistence_provider" env:"PERSISTENCE_PROVIDER"`
-	AuthServiceProvider        AuthServiceProvider `yaml:"auth_service_provider" env:"AUTH_SERVICE_PROVIDER"`
-	MaxCacheSize               int                 `yaml:"max_cache_size"`
-	CacheExpirationMillis      int                 `yaml:"cache_expiration_millis"`
-	MaxGroupRoleLevels         int                 `yaml:"max_group_role_levels"`
-	ProxyURL                   string              `yaml:"proxy_url"`
-	Version                    *version.Info       `yaml:"-"`
+	Redis                      RedisConfig             `yaml:"redis" env:"REDIS"`
+	DynamoDB                   DynamoDBConfig          `yaml:"ddb" env:"DYNAMODB"`
+	SQL                        SQLConfig               `yaml:"sql" mapstructure:"sql"`
+	Bolt                       BoltConfig              `yaml:"bolt" mapstructure:"bolt"`
+	EnvoyAuth                  EnvoyAuthConfig         `yaml:"envoy_auth" mapstructure:"envoy_auth"`
+	KubernetesAuth             KubernetesAuthConfig    `yaml:"kubernetes_auth" mapstructure:"kubernetes_auth"`
+	CasbinPolicy               CasbinPolicyConfig      `yaml:"casbin_policy" mapstructure:"casbin_policy"`
+	SystemAuth                 SystemAuthConfig        `yaml:"system_auth" mapstructure:"system_auth"`
+	RateLimit                  RateLimitConfig         `yaml:"rate_limit" mapstructure:"rate_limit"`
+	DecisionLog                DecisionLogConfig       `yaml:"decision_log" mapstructure:"decision_log"`
+	Audit                      AuditConfig             `yaml:"audit" mapstructure:"audit"`
+	Watch                      WatchConfig             `yaml:"watch" mapstructure:"watch"`
+	Webhook                    WebhookConfig           `yaml:"webhook" mapstructure:"webhook"`
+	CacheInvalidation          CacheInvalidationConfig `yaml:"cache_invalidation" mapstructure:"cache_invalidation"`
+	Bundle                     BundleConfig            `yaml:"bundle" mapstructure:"bundle"`
+	HttpAuth                   HttpAuthConfig          `yaml:"http_auth" mapstructure:"http_auth"`
+	GrpcJWT                    JWTAuthConfig           `yaml:"grpc_jwt" mapstructure:"grpc_jwt"`
+	GrpcSasl                   bool                    `yaml:"grpc_sasl"`
+	GrpcListenPort             string                  `yaml:"grpc_listen_port" env:"GRPC_PORT"`
+	HttpListenPort             string                  `yaml:"http_listen_port" env:"HTTP_PORT"`
+	ResourceInstanceExpiration time.Duration           `yaml:"resource_instance_expiration"`
+	ResourceLeaseSweepInterval time.Duration           `yaml:"resource_lease_sweep_interval"`
+	ReleasedInstanceExpiration time.Duration           `yaml:"released_instance_expiration"`
+	HttpClientTimeout          time.Duration           `yaml:"http_client_timeout"`
+	TLSReloadInterval          time.Duration           `yaml:"tls_reload_interval" mapstructure:"tls_reload_interval"`
+	Debug                      bool                    `yaml:"debug"`
+	Dir                        string                  `yaml:"dir" env:"CONFIG_DIR"`
+	PersistenceProvider        PersistenceProvider     `yaml:"persistence_provider" env:"PERSISTENCE_PROVIDER"`
+	AuthServiceProvider        AuthServiceProvider     `yaml:"auth_service_provider" env:"AUTH_SERVICE_PROVIDER"`
+	MaxCacheSize               int                     `yaml:"max_cache_size"`
+	CacheExpirationMillis      int                     `yaml:"cache_expiration_millis"`
+	MaxGroupRoleLevels         int                     `yaml:"max_group_role_levels"`
+	ProxyURL                   string                  `yaml:"proxy_url"`
+	Version                    *version.Info           `yaml:"-"`
 }
 
 // NewConfig -- initializes the Default Configuration
@@ -152,9 +500,10 @@ func (c *Config) TLSClient() (tlsC TLSConfig, err error) {
 		return
 	}
 	tlsC = TLSConfig{
-		CertFile: certFile,
-		KeyFile:  keyFile,
-		CAFile:   caFile,
+		CertFile:       certFile,
+		KeyFile:        keyFile,
+		CAFile:         caFile,
+		ReloadInterval: c.TLSReloadInterval,
 	}
 	return
 }
@@ -179,9 +528,10 @@ func (c *Config) TLSRootClient() (tlsC TLSConfig, err error) {
 		return
 	}
 	tlsC = TLSConfig{
-		CertFile: certFile,
-		KeyFile:  keyFile,
-		CAFile:   caFile,
+		CertFile:       certFile,
+		KeyFile:        keyFile,
+		CAFile:         caFile,
+		ReloadInterval: c.TLSReloadInterval,
 	}
 	return
 }
@@ -206,22 +556,24 @@ func (c *Config) TLSNobodyClient() (tlsC TLSConfig, err error) {
 		return
 	}
 	tlsC = TLSConfig{
-		CertFile: certFile,
-		KeyFile:  keyFile,
-		CAFile:   caFile,
+		CertFile:       certFile,
+		KeyFile:        keyFile,
+		CAFile:         caFile,
+		ReloadInterval: c.TLSReloadInterval,
 	}
 	return
 }
 
-func (c *Config) SetupTLSClient() (tlsConfig *tls.Config, err error) {
+func (c *Config) SetupTLSClient(metricsRegistry *metrics.Registry) (tlsConfig *tls.Config, err error) {
 	tlsC, err := c.TLSClient()
 	if err != nil {
 		return nil, err
 	}
+	tlsC.MetricsRegistry = metricsRegistry
 	return tlsC.SetupTLS()
 }
 
-func (c *Config) SetupTLSServer(addr string) (tlsConfig *tls.Config, err error) {
+func (c *Config) SetupTLSServer(addr string, metricsRegistry *metrics.Registry) (tlsConfig *tls.Config, err error) {
 	certFile, err := c.ServerCertFile()
 	if err != nil {
 		return nil, err
@@ -235,11 +587,13 @@ func (c *Config) SetupTLSServer(addr string) (tlsConfig *tls.Config, err error)
 		return nil, err
 	}
 	tlsC := TLSConfig{
-		CertFile:      certFile,
-		KeyFile:       keyFile,
-		CAFile:        caFile,
-		ServerAddress: addr,
-		Server:        true,
+		CertFile:        certFile,
+		KeyFile:         keyFile,
+		CAFile:          caFile,
+		ServerAddress:   addr,
+		Server:          true,
+		ReloadInterval:  c.TLSReloadInterval,
+		MetricsRegistry: metricsRegistry,
 	}
 	return tlsC.SetupTLS()
 }
@@ -309,6 +663,12 @@ func (c *Config) Validate() error {
 	if c.ResourceInstanceExpiration.Seconds() <= 0 {
 		c.ResourceInstanceExpiration = 15 * time.Minute
 	}
+	if c.ResourceLeaseSweepInterval <= 0 {
+		c.ResourceLeaseSweepInterval = time.Minute
+	}
+	if c.ReleasedInstanceExpiration <= 0 {
+		c.ReleasedInstanceExpiration = 24 * time.Hour
+	}
 	if c.MaxCacheSize <= 0 {
 		c.MaxCacheSize = 10000
 	}
@@ -331,6 +691,314 @@ func (c *Config) Validate() error {
 	if c.HttpClientTimeout.Seconds() < 0 {
 		c.HttpClientTimeout = time.Second * 5
 	}
+	if c.TLSReloadInterval <= 0 {
+		c.TLSReloadInterval = DefaultCertReloadInterval
+	}
+	if err := c.EnvoyAuth.Validate(); err != nil {
+		return err
+	}
+	if err := c.KubernetesAuth.Validate(); err != nil {
+		return err
+	}
+	if err := c.CasbinPolicy.Validate(); err != nil {
+		return err
+	}
+	if err := c.SystemAuth.Validate(); err != nil {
+		return err
+	}
+	if err := c.RateLimit.Validate(); err != nil {
+		return err
+	}
+	if err := c.DecisionLog.Validate(); err != nil {
+		return err
+	}
+	if err := c.Audit.Validate(); err != nil {
+		return err
+	}
+	if err := c.Watch.Validate(); err != nil {
+		return err
+	}
+	if err := c.Webhook.Validate(); err != nil {
+		return err
+	}
+	if err := c.CacheInvalidation.Validate(); err != nil {
+		return err
+	}
+	if c.AuthServiceProvider == BundleAuthServiceProvider {
+		if err := c.Bundle.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := c.HttpAuth.Validate(); err != nil {
+		return err
+	}
+	if err := c.GrpcJWT.Validate(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Validate - validates
+func (c *HttpAuthConfig) Validate() error {
+	if c.APIKeyHeader == "" {
+		c.APIKeyHeader = "X-API-Key"
+	}
+	for i, apiKey := range c.APIKeys {
+		if apiKey.Key == "" || apiKey.Subject == "" {
+			return NewValidationError(
+				fmt.Sprintf("key or subject is not defined for api key %d", i))
+		}
+	}
+	return c.JWT.Validate()
+}
+
+// Validate - validates
+func (c *JWTAuthConfig) Validate() error {
+	if c.SubjectClaim == "" {
+		c.SubjectClaim = "sub"
+	}
+	if c.OrganizationClaim == "" {
+		c.OrganizationClaim = "org_id"
+	}
+	if c.PrincipalClaim == "" {
+		c.PrincipalClaim = "sub"
+	}
+	if c.NamespacesClaim == "" {
+		c.NamespacesClaim = "namespaces"
+	}
+	return nil
+}
+
+// Enabled returns true if signing key, public key or key set is defined.
+func (c *JWTAuthConfig) Enabled() bool {
+	return c.SigningKey != "" || c.PublicKeyFile != "" || c.JWKSFile != ""
+}
+
+// Validate - validates
+func (c *CasbinPolicyConfig) Validate() error {
+	if c.Store == "" {
+		c.Store = FileCasbinPolicyStore
+	}
+	if c.Store != FileCasbinPolicyStore && c.Store != DataStoreCasbinPolicyStore {
+		return NewValidationError(fmt.Sprintf("invalid casbin policy store %s", c.Store))
+	}
+	if c.Tenant == "" {
+		c.Tenant = "casbin"
+	}
+	if c.ReloadInterval <= 0 {
+		c.ReloadInterval = 10 * time.Second
+	}
+	return nil
+}
+
+// Validate - validates
+func (c *RateLimitConfig) Validate() error {
+	if c.RequestsPerSecond <= 0 {
+		c.RequestsPerSecond = 100
+	}
+	if c.Burst <= 0 {
+		c.Burst = int32(math.Ceil(c.RequestsPerSecond))
+	}
+	if c.Key == "" {
+		c.Key = OrganizationRateLimitKey
+	}
+	if c.Key != OrganizationRateLimitKey && c.Key != PrincipalRateLimitKey && c.Key != ClientRateLimitKey {
+		return NewValidationError(fmt.Sprintf("invalid rate limit key %s", c.Key))
+	}
+	if c.Store == "" {
+		c.Store = MemoryRateLimitStore
+	}
+	if c.Store != MemoryRateLimitStore && c.Store != RedisRateLimitStore {
+		return NewValidationError(fmt.Sprintf("invalid rate limit store %s", c.Store))
+	}
+	return nil
+}
+
+// Validate - validates
+func (c *DecisionLogConfig) Validate() error {
+	if len(c.Sinks) == 0 {
+		c.Sinks = []DecisionLogSink{DataStoreDecisionLogSink}
+	}
+	for _, sink := range c.Sinks {
+		if sink != FileDecisionLogSink && sink != StdoutDecisionLogSink && sink != DataStoreDecisionLogSink {
+			return NewValidationError(fmt.Sprintf("invalid decision log sink %s", sink))
+		}
+	}
+	if c.BufferSize <= 0 {
+		c.BufferSize = 1000
+	}
+	if c.SampleRate <= 0 || c.SampleRate > 1 {
+		c.SampleRate = 1
+	}
+	if c.File == "" {
+		c.File = "decisions.log"
+	}
+	if c.MaxSizeMB <= 0 {
+		c.MaxSizeMB = 100
+	}
+	if c.MaxBackups <= 0 {
+		c.MaxBackups = 5
+	}
+	if c.Retention <= 0 {
+		c.Retention = 24 * time.Hour
+	}
+	if c.RecentSize <= 0 {
+		c.RecentSize = 1000
+	}
+	return nil
+}
+
+// Validate - validates
+func (c *AuditConfig) Validate() error {
+	if c.Retention <= 0 {
+		c.Retention = 90 * 24 * time.Hour
+	}
+	return nil
+}
+
+// Validate - validates
+func (c *WatchConfig) Validate() error {
+	if c.HistorySize <= 0 {
+		c.HistorySize = 1000
+	}
+	if c.SubscriberBufferSize <= 0 {
+		c.SubscriberBufferSize = 100
+	}
+	return nil
+}
+
+// Validate - validates
+func (c *WebhookConfig) Validate() error {
+	if c.Workers <= 0 {
+		c.Workers = 4
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = 1000
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 5
+	}
+	if c.InitialBackoff <= 0 {
+		c.InitialBackoff = time.Second
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = time.Minute
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 10 * time.Second
+	}
+	if c.DeliveryRetention <= 0 {
+		c.DeliveryRetention = 7 * 24 * time.Hour
+	}
+	if c.CacheTTL <= 0 {
+		c.CacheTTL = 30 * time.Second
+	}
+	return nil
+}
+
+// Validate - validates
+func (c *CacheInvalidationConfig) Validate() error {
+	if c.Bus == "" {
+		c.Bus = LocalCacheInvalidationBus
+	}
+	if c.Bus != LocalCacheInvalidationBus && c.Bus != RedisCacheInvalidationBus {
+		return NewValidationError(fmt.Sprintf("invalid cache invalidation bus %s", c.Bus))
+	}
+	if c.Channel == "" {
+		c.Channel = "plexauthz:cache_invalidations"
+	}
+	if c.ReconnectInterval <= 0 {
+		c.ReconnectInterval = time.Second
+	}
+	return nil
+}
+
+// Validate - validates that bundle and public key are defined for loading the bundle
+func (c *BundleConfig) Validate() error {
+	if c.Path == "" {
+		return NewValidationError("bundle path is not defined")
+	}
+	if c.PublicKeyFile == "" {
+		return NewValidationError("bundle public key file is not defined")
+	}
+	return nil
+}
+
+// Validate - validates
+func (c *SystemAuthConfig) Validate() error {
+	if c.OrganizationName == "" {
+		c.OrganizationName = "plexauthz-system"
+	}
+	if c.Namespace == "" {
+		c.Namespace = "admin"
+	}
+	if len(c.Admins) == 0 {
+		c.Admins = []string{"root"}
+	}
+	if len(c.Clients) == 0 {
+		c.Clients = []string{"client"}
+	}
+	return nil
+}
+
+// Validate - validates
+func (c *EnvoyAuthConfig) Validate() error {
+	if c.JwtMetadataKey == "" {
+		c.JwtMetadataKey = "jwt_payload"
+	}
+	if c.PrincipalClaim == "" {
+		c.PrincipalClaim = "sub"
+	}
+	for i := range c.Rules {
+		if c.Rules[i].Principal == "" {
+			c.Rules[i].Principal = "{{.Subject}}"
+		}
+		if c.Rules[i].Resource == "" {
+			c.Rules[i].Resource = "{{.Path}}"
+		}
+		if c.Rules[i].Action == "" {
+			c.Rules[i].Action = "{{lower .Method}}"
+		}
+		if c.Rules[i].Organization == "" {
+			return NewValidationError(
+				fmt.Sprintf("organization is not defined for envoy rule %d", i))
+		}
+		if c.Rules[i].Namespace == "" {
+			return NewValidationError(
+				fmt.Sprintf("namespace is not defined for envoy rule %d", i))
+		}
+	}
+	return nil
+}
+
+// Validate - validates
+func (c *KubernetesAuthConfig) Validate() error {
+	if c.PrincipalLookup == "" {
+		c.PrincipalLookup = "username"
+	}
+	if c.PrincipalLookup != "username" && c.PrincipalLookup != "id" {
+		return NewValidationError(
+			fmt.Sprintf("invalid principal_lookup %s for kubernetes auth", c.PrincipalLookup))
+	}
+	for i := range c.Rules {
+		if c.Rules[i].Principal == "" {
+			c.Rules[i].Principal = "{{.User}}"
+		}
+		if c.Rules[i].Resource == "" {
+			c.Rules[i].Resource = "{{if .Path}}{{.Path}}{{else}}{{.Resource}}{{end}}"
+		}
+		if c.Rules[i].Action == "" {
+			c.Rules[i].Action = "{{.Verb}}"
+		}
+		if c.Rules[i].Organization == "" {
+			return NewValidationError(
+				fmt.Sprintf("organization is not defined for kubernetes rule %d", i))
+		}
+		if c.Rules[i].Namespace == "" {
+			return NewValidationError(
+				fmt.Sprintf("namespace is not defined for kubernetes rule %d", i))
+		}
+	}
 	return nil
 }
 
@@ -342,6 +1010,47 @@ func (c *RedisConfig) Validate() error {
 	if c.Port == 0 {
 		c.Port = 6379
 	}
+	if c.CleanupInterval <= 0 {
+		c.CleanupInterval = time.Minute
+	}
+	return nil
+}
+
+// Validate - validates
+func (c *SQLConfig) Validate() error {
+	if c.Dialect == "" {
+		c.Dialect = SQLiteDialect
+	}
+	if c.Dialect != SQLiteDialect && c.Dialect != PostgresDialect {
+		return NewValidationError(fmt.Sprintf("invalid sql dialect %s", c.Dialect))
+	}
+	if c.DataSource == "" {
+		if c.Dialect == PostgresDialect {
+			return NewValidationError("sql data source is not defined")
+		}
+		c.DataSource = "plexauthz.db"
+	}
+	if c.MaxOpenConns <= 0 {
+		if c.Dialect == SQLiteDialect {
+			c.MaxOpenConns = 1
+		} else {
+			c.MaxOpenConns = 10
+		}
+	}
+	if c.CleanupInterval <= 0 {
+		c.CleanupInterval = time.Minute
+	}
+	return nil
+}
+
+// Validate - validates
+func (c *BoltConfig) Validate() error {
+	if c.Path == "" {
+		c.Path = "plexauthz.bolt"
+	}
+	if c.CleanupInterval <= 0 {
+		c.CleanupInterval = time.Minute
+	}
 	return nil
 }
 
@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const consistencyTokenPrefix = "r1:"
+
+var lastRevision int64
+
+type minRevisionContextKey struct{}
+
+// NewRevision returns timestamp of a change in nanoseconds, which increases monotonically in the process.
+// Revisions are taken from the local clock, so they are only ordered across servers whose clocks are
+// synchronized, and a single writer is assumed when clocks may drift.
+func NewRevision() int64 {
+	for {
+		last := atomic.LoadInt64(&lastRevision)
+		next := time.Now().UnixNano()
+		if next <= last {
+			next = last + 1
+		}
+		if atomic.CompareAndSwapInt64(&lastRevision, last, next) {
+			return next
+		}
+	}
+}
+
+// NewConsistencyToken returns opaque token of a new revision, which is returned after writes so that
+// authorization requests can ask for data at least as fresh as the write.
+func NewConsistencyToken() string {
+	return ConsistencyToken(NewRevision())
+}
+
+// ConsistencyToken returns opaque token of the revision.
+func ConsistencyToken(revision int64) string {
+	if revision <= 0 {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(consistencyTokenPrefix + strconv.FormatInt(revision, 10)))
+}
+
+// ParseConsistencyToken returns revision of the token, which is zero for empty token.
+func ParseConsistencyToken(token string) (int64, error) {
+	if token == "" {
+		return 0, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || !strings.HasPrefix(string(b), consistencyTokenPrefix) {
+		return 0, NewValidationError(fmt.Sprintf("invalid consistency token %s", token))
+	}
+	revision, err := strconv.ParseInt(strings.TrimPrefix(string(b), consistencyTokenPrefix), 10, 64)
+	if err != nil || revision <= 0 {
+		return 0, NewValidationError(fmt.Sprintf("invalid consistency token %s", token))
+	}
+	return revision, nil
+}
+
+// MinRevision returns revision from context that cached data must be at least as fresh as.
+func MinRevision(ctx context.Context) int64 {
+	v, _ := ctx.Value(minRevisionContextKey{}).(int64)
+	return v
+}
+
+// WithConsistencyToken returns context with revision of the token so that stale cached data is reloaded.
+// A token ahead of the local clock, e.g., from a server whose clock is ahead, reloads cached data on every
+// request until the local clock passes it, so the guarantee is only as good as synchronization of clocks.
+func WithConsistencyToken(ctx context.Context, token string) (context.Context, error) {
+	revision, err := ParseConsistencyToken(token)
+	if err != nil || revision == 0 {
+		return ctx, err
+	}
+	return context.WithValue(ctx, minRevisionContextKey{}, revision), nil
+}
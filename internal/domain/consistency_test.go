@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Test_ShouldParseConsistencyTokens(t *testing.T) {
+	// GIVEN tokens of two changes
+	first := NewRevision()
+	token := NewConsistencyToken()
+
+	// WHEN parsing token
+	revision, err := ParseConsistencyToken(token)
+
+	// THEN it should return newer revision
+	require.NoError(t, err)
+	require.Greater(t, revision, first)
+
+	// AND context should carry revision of the token
+	ctx, err := WithConsistencyToken(context.Background(), token)
+	require.NoError(t, err)
+	require.Equal(t, revision, MinRevision(ctx))
+
+	// AND empty token should not require any revision
+	ctx, err = WithConsistencyToken(context.Background(), "")
+	require.NoError(t, err)
+	require.Equal(t, int64(0), MinRevision(ctx))
+
+	// AND invalid tokens should fail
+	_, err = ParseConsistencyToken("blah")
+	require.Error(t, err)
+	_, err = ParseConsistencyToken(ConsistencyToken(first)[1:])
+	require.Error(t, err)
+
+	// AND tokens ahead of the local clock should require reloading cached data
+	future := time.Now().Add(time.Hour).UnixNano()
+	ctx, err = WithConsistencyToken(context.Background(), ConsistencyToken(future))
+	require.NoError(t, err)
+	require.Equal(t, future, MinRevision(ctx))
+	require.Less(t, NewRevision(), MinRevision(ctx))
+}
@@ -3,6 +3,7 @@ package domain
 import (
 	"errors"
 	"fmt"
+	"time"
 )
 
 const (
@@ -14,6 +15,9 @@ const (
 	// DuplicateCode error
 	DuplicateCode string = "EC100409"
 
+	// ConflictCode error
+	ConflictCode string = "EC100419"
+
 	// ValidationCode error
 	ValidationCode string = "EC100400"
 
@@ -40,6 +44,9 @@ const (
 
 	// ConflictingPermissionsCode error
 	ConflictingPermissionsCode string = "EC100452"
+
+	// RateLimitCode error
+	RateLimitCode string = "EC100429"
 )
 
 // ValidationError error
@@ -127,6 +134,27 @@ func (e *DuplicateError) String() string {
 	return fmt.Sprintf("DuplicateError: %s", e.Message)
 }
 
+// ConflictError error when record was changed concurrently since it was read
+type ConflictError struct {
+	Message string
+}
+
+// NewConflictError constructor
+func NewConflictError(msg string) *ConflictError {
+	return &ConflictError{
+		Message: msg + " [" + ConflictCode + "]",
+	}
+}
+
+func (e *ConflictError) Error() string {
+	return e.Message
+}
+
+// String getter
+func (e *ConflictError) String() string {
+	return fmt.Sprintf("ConflictError: %s", e.Message)
+}
+
 // NotFoundError error
 type NotFoundError struct {
 	Message string
@@ -197,15 +225,41 @@ func (e *InternalError) String() string {
 	return fmt.Sprintf("InternalError: %s", e.Message)
 }
 
+// RateLimitError error
+type RateLimitError struct {
+	Message string
+	// RetryAfter duration after which the request may be permitted.
+	RetryAfter time.Duration
+}
+
+// NewRateLimitError constructor
+func NewRateLimitError(msg string, retryAfter time.Duration) *RateLimitError {
+	return &RateLimitError{
+		Message:    msg + " [" + RateLimitCode + "]",
+		RetryAfter: retryAfter,
+	}
+}
+
+func (e *RateLimitError) Error() string {
+	return e.Message
+}
+
+// String getter
+func (e *RateLimitError) String() string {
+	return fmt.Sprintf("RateLimitError: %s", e.Message)
+}
+
 // ErrorToHTTPStatus helper
 func ErrorToHTTPStatus(err error) int {
 	var validationErr *ValidationError
 	var marshalError *MarshalError
 	var notFoundErr *NotFoundError
 	var duplicateErr *DuplicateError
+	var conflictErr *ConflictError
 	var authFoundErr *AuthError
 	var databaseErr *DatabaseError
 	var internalErr *InternalError
+	var rateLimitErr *RateLimitError
 	if errors.As(err, &validationErr) {
 		return 400
 	} else if errors.As(err, &marshalError) {
@@ -214,12 +268,16 @@ func ErrorToHTTPStatus(err error) int {
 		return 404
 	} else if errors.As(err, &duplicateErr) {
 		return 409
+	} else if errors.As(err, &conflictErr) {
+		return 409
 	} else if errors.As(err, &authFoundErr) {
 		return 401
 	} else if errors.As(err, &databaseErr) {
 		return 500
 	} else if errors.As(err, &internalErr) {
 		return 500
+	} else if errors.As(err, &rateLimitErr) {
+		return 429
 	} else if err != nil {
 		return 500
 	}
@@ -51,6 +51,15 @@ func Test_ShouldBuildDuplicateError(t *testing.T) {
 	require.Equal(t, 409, ErrorToHTTPStatus(err))
 }
 
+func Test_ShouldBuildConflictError(t *testing.T) {
+	// GIVEN a mismatch error
+	err := NewConflictError("test error")
+	// THEN it should match message
+	require.Error(t, err)
+	require.Equal(t, "test error [EC100419]", err.Error())
+	require.Equal(t, 409, ErrorToHTTPStatus(err))
+}
+
 func Test_ShouldBuildDatabaseError(t *testing.T) {
 	// GIVEN a mismatch error
 	err := NewDatabaseError("test error")
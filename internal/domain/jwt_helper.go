@@ -0,0 +1,235 @@
+package domain
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/golang-jwt/jwt"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// JSONWebKey defines a key of JSON Web Key Set (RFC 7517) for RSA, EC or symmetric keys.
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// JSONWebKeySet defines JSON Web Key Set.
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JWTVerifier verifies JWT bearer tokens with keys of JWTAuthConfig.
+type JWTVerifier struct {
+	config    JWTAuthConfig
+	publicKey any
+	keySet    map[string]any
+}
+
+// NewJWTVerifier constructor
+func NewJWTVerifier(config JWTAuthConfig) (*JWTVerifier, error) {
+	if !config.Enabled() {
+		return nil, NewValidationError("signing key, public key file or jwks file is not defined for jwt")
+	}
+	verifier := &JWTVerifier{config: config}
+	if config.PublicKeyFile != "" {
+		b, err := os.ReadFile(config.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if verifier.publicKey, err = jwt.ParseRSAPublicKeyFromPEM(b); err != nil {
+			if verifier.publicKey, err = jwt.ParseECPublicKeyFromPEM(b); err != nil {
+				return nil, NewValidationError(
+					fmt.Sprintf("failed to parse public key %s", config.PublicKeyFile))
+			}
+		}
+	}
+	if config.JWKSFile != "" {
+		b, err := os.ReadFile(config.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		if verifier.keySet, err = ParseJSONWebKeySet(b); err != nil {
+			return nil, err
+		}
+	}
+	return verifier, nil
+}
+
+// ParseJSONWebKeySet parses keys of JSON Web Key Set by their kid.
+func ParseJSONWebKeySet(b []byte) (map[string]any, error) {
+	keySet := JSONWebKeySet{}
+	if err := json.Unmarshal(b, &keySet); err != nil {
+		return nil, NewValidationError(fmt.Sprintf("failed to parse jwks %s", err))
+	}
+	keys := make(map[string]any)
+	for _, jwk := range keySet.Keys {
+		key, err := jwk.PublicKey()
+		if err != nil {
+			return nil, err
+		}
+		keys[jwk.Kid] = key
+	}
+	return keys, nil
+}
+
+// PublicKey returns RSA or ECDSA public key or symmetric key.
+func (k JSONWebKey) PublicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBase64URL(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBase64URL(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, NewValidationError(fmt.Sprintf("unsupported curve %s of jwk %s", k.Crv, k.Kid))
+		}
+		x, err := decodeBase64URL(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBase64URL(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "oct":
+		return decodeBase64URL(k.K)
+	default:
+		return nil, NewValidationError(fmt.Sprintf("unsupported key type %s of jwk %s", k.Kty, k.Kid))
+	}
+}
+
+// Verify validates signature, expiration, issuer and audience of the token and returns its claims.
+func (v *JWTVerifier) Verify(token string) (map[string]any, error) {
+	token = strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(token, "Bearer "), "bearer "))
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(token, claims, v.key); err != nil {
+		return nil, NewAuthError(fmt.Sprintf("invalid jwt token: %s", err))
+	}
+	if v.config.Issuer != "" && !claims.VerifyIssuer(v.config.Issuer, true) {
+		return nil, NewAuthError(fmt.Sprintf("invalid issuer of jwt token: %v", claims["iss"]))
+	}
+	if v.config.Audience != "" && !claims.VerifyAudience(v.config.Audience, true) {
+		return nil, NewAuthError(fmt.Sprintf("invalid audience of jwt token: %v", claims["aud"]))
+	}
+	return claims, nil
+}
+
+// Subject returns value of the subject claim.
+func (v *JWTVerifier) Subject(claims map[string]any) string {
+	return claimValue(claims, v.config.SubjectClaim)
+}
+
+// OrganizationId returns value of the organization claim.
+func (v *JWTVerifier) OrganizationId(claims map[string]any) string {
+	return claimValue(claims, v.config.OrganizationClaim)
+}
+
+// PrincipalId returns value of the principal claim.
+func (v *JWTVerifier) PrincipalId(claims map[string]any) string {
+	return claimValue(claims, v.config.PrincipalClaim)
+}
+
+// Scope returns scope of the organization and namespaces claims or nil if the organization is not defined.
+func (v *JWTVerifier) Scope(claims map[string]any) *ClientScope {
+	organizationId := v.OrganizationId(claims)
+	if organizationId == "" {
+		return nil
+	}
+	var namespaces []string
+	switch val := claims[v.config.NamespacesClaim].(type) {
+	case string:
+		for _, ns := range strings.Split(val, ",") {
+			if ns = strings.TrimSpace(ns); ns != "" {
+				namespaces = append(namespaces, ns)
+			}
+		}
+	case []any:
+		for _, ns := range val {
+			namespaces = append(namespaces, fmt.Sprintf("%v", ns))
+		}
+	}
+	return NewClientScope(organizationId, namespaces...)
+}
+
+func (v *JWTVerifier) key(token *jwt.Token) (any, error) {
+	if v.keySet != nil {
+		kid, _ := token.Header["kid"].(string)
+		if key := v.keySet[kid]; key != nil && matchesSigningKey(token.Method, key) {
+			return key, nil
+		}
+		if v.config.SigningKey == "" && v.publicKey == nil {
+			return nil, fmt.Errorf("key %s is not found in jwks", kid)
+		}
+	}
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if v.config.SigningKey != "" {
+			return []byte(v.config.SigningKey), nil
+		}
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+		if v.publicKey != nil {
+			return v.publicKey, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+}
+
+func matchesSigningKey(method jwt.SigningMethod, key any) bool {
+	switch key.(type) {
+	case []byte:
+		_, ok := method.(*jwt.SigningMethodHMAC)
+		return ok
+	case *rsa.PublicKey:
+		switch method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+			return true
+		}
+	case *ecdsa.PublicKey:
+		_, ok := method.(*jwt.SigningMethodECDSA)
+		return ok
+	}
+	return false
+}
+
+func claimValue(claims map[string]any, name string) string {
+	if name == "" || claims[name] == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", claims[name])
+}
+
+func decodeBase64URL(str string) ([]byte, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(str, "="))
+	if err != nil {
+		return nil, NewValidationError(fmt.Sprintf("failed to decode jwk value %s", err))
+	}
+	return b, nil
+}
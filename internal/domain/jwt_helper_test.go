@@ -0,0 +1,145 @@
+package domain
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_ShouldVerifyHMACSignedJWT(t *testing.T) {
+	// GIVEN verifier with symmetric key
+	config := JWTAuthConfig{SigningKey: "secret", Issuer: "plexauthz", Audience: "admin"}
+	require.NoError(t, config.Validate())
+	verifier, err := NewJWTVerifier(config)
+	require.NoError(t, err)
+
+	// WHEN verifying token with valid claims
+	token := signJWT(t, jwt.SigningMethodHS256, []byte("secret"), jwt.MapClaims{
+		"sub": "root", "iss": "plexauthz", "aud": "admin", "exp": time.Now().Add(time.Minute).Unix()})
+	claims, err := verifier.Verify("Bearer " + token)
+	// THEN it should return subject
+	require.NoError(t, err)
+	require.Equal(t, "root", verifier.Subject(claims))
+
+	// WHEN verifying token with invalid key, issuer, audience or expiration
+	for _, token := range []string{
+		signJWT(t, jwt.SigningMethodHS256, []byte("bad"), jwt.MapClaims{
+			"sub": "root", "iss": "plexauthz", "aud": "admin"}),
+		signJWT(t, jwt.SigningMethodHS256, []byte("secret"), jwt.MapClaims{
+			"sub": "root", "iss": "other", "aud": "admin"}),
+		signJWT(t, jwt.SigningMethodHS256, []byte("secret"), jwt.MapClaims{
+			"sub": "root", "iss": "plexauthz", "aud": "other"}),
+		signJWT(t, jwt.SigningMethodHS256, []byte("secret"), jwt.MapClaims{
+			"sub": "root", "iss": "plexauthz", "aud": "admin", "exp": time.Now().Add(-time.Minute).Unix()}),
+	} {
+		_, err = verifier.Verify(token)
+		// THEN it should fail
+		require.Error(t, err)
+	}
+}
+
+func Test_ShouldVerifyRSASignedJWT(t *testing.T) {
+	// GIVEN verifier with public key file
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	b, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	require.NoError(t, err)
+	publicKeyFile := filepath.Join(t.TempDir(), "public.pem")
+	require.NoError(t, os.WriteFile(publicKeyFile,
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: b}), 0600))
+	config := JWTAuthConfig{PublicKeyFile: publicKeyFile, SubjectClaim: "email"}
+	verifier, err := NewJWTVerifier(config)
+	require.NoError(t, err)
+
+	// WHEN verifying token signed with private key
+	claims, err := verifier.Verify(signJWT(t, jwt.SigningMethodRS256, privateKey, jwt.MapClaims{"email": "a@b.c"}))
+	// THEN it should return subject claim
+	require.NoError(t, err)
+	require.Equal(t, "a@b.c", verifier.Subject(claims))
+
+	// WHEN verifying HMAC token without signing key
+	_, err = verifier.Verify(signJWT(t, jwt.SigningMethodHS256, []byte("secret"), jwt.MapClaims{"email": "a@b.c"}))
+	// THEN it should fail
+	require.Error(t, err)
+
+	// WHEN creating verifier without keys
+	_, err = NewJWTVerifier(JWTAuthConfig{})
+	// THEN it should fail
+	require.Error(t, err)
+}
+
+func Test_ShouldVerifyJWTWithJWKS(t *testing.T) {
+	// GIVEN verifier with jwks file of RSA, EC and symmetric keys
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	keySet := JSONWebKeySet{Keys: []JSONWebKey{
+		{Kty: "RSA", Kid: "rsa", N: encodeBase64URL(rsaKey.N.Bytes()),
+			E: encodeBase64URL(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{Kty: "EC", Kid: "ec", Crv: "P-256", X: encodeBase64URL(ecKey.X.Bytes()), Y: encodeBase64URL(ecKey.Y.Bytes())},
+		{Kty: "oct", Kid: "hmac", K: encodeBase64URL([]byte("secret"))},
+	}}
+	b, err := json.Marshal(keySet)
+	require.NoError(t, err)
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(jwksFile, b, 0600))
+	config := JWTAuthConfig{JWKSFile: jwksFile}
+	require.NoError(t, config.Validate())
+	verifier, err := NewJWTVerifier(config)
+	require.NoError(t, err)
+	claims := jwt.MapClaims{"sub": "john", "org_id": "org"}
+
+	// WHEN verifying tokens signed with keys of jwks
+	for kid, token := range map[string]*jwt.Token{
+		"rsa":  jwt.NewWithClaims(jwt.SigningMethodRS256, claims),
+		"ec":   jwt.NewWithClaims(jwt.SigningMethodES256, claims),
+		"hmac": jwt.NewWithClaims(jwt.SigningMethodHS256, claims),
+	} {
+		token.Header["kid"] = kid
+		var key any = rsaKey
+		if kid == "ec" {
+			key = ecKey
+		} else if kid == "hmac" {
+			key = []byte("secret")
+		}
+		signed, err := token.SignedString(key)
+		require.NoError(t, err)
+		res, err := verifier.Verify(signed)
+		// THEN it should map claims
+		require.NoError(t, err)
+		require.Equal(t, "john", verifier.PrincipalId(res))
+		require.Equal(t, "org", verifier.OrganizationId(res))
+	}
+
+	// WHEN verifying token with unknown kid
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "unknown"
+	signed, err := token.SignedString(rsaKey)
+	require.NoError(t, err)
+	_, err = verifier.Verify(signed)
+	// THEN it should fail
+	require.Error(t, err)
+}
+
+func encodeBase64URL(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func signJWT(t *testing.T, method jwt.SigningMethod, key any, claims jwt.MapClaims) string {
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	require.NoError(t, err)
+	return token
+}
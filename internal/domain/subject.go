@@ -0,0 +1,17 @@
+package domain
+
+import "context"
+
+type subjectContextKey struct{}
+
+// Subject returns authenticated subject of the client from context, which is recorded by
+// the data layer such as for audits of changes.
+func Subject(ctx context.Context) string {
+	v, _ := ctx.Value(subjectContextKey{}).(string)
+	return v
+}
+
+// WithSubject returns context with authenticated subject of the client.
+func WithSubject(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, subjectContextKey{}, subject)
+}
@@ -12,6 +12,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	texttemplate "text/template"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -59,6 +60,31 @@ func ParseTemplate(
 	return []byte(strResponse), nil
 }
 
+// ParseMappingTemplate parses GO template for mapping attributes of external requests such as
+// Envoy or Kubernetes to the authorization request.
+func ParseMappingTemplate(templateStr string) (*texttemplate.Template, error) {
+	return texttemplate.New("").
+		Option("missingkey=zero").
+		Funcs(texttemplate.FuncMap{
+			"lower": strings.ToLower,
+			"upper": strings.ToUpper,
+			"trimPrefix": func(prefix string, s string) string {
+				return strings.TrimPrefix(s, prefix)
+			},
+		}).
+		Parse(templateStr)
+}
+
+// ExecuteMappingTemplate executes template parsed by ParseMappingTemplate
+func ExecuteMappingTemplate(t *texttemplate.Template, attrs any) (string, error) {
+	var out bytes.Buffer
+	if err := t.Execute(&out, attrs); err != nil {
+		return "", NewInternalError(
+			fmt.Sprintf("failed to execute mapping template due to %s", err), TemplateCode)
+	}
+	return strings.ReplaceAll(strings.TrimSpace(out.String()), "<no value>", ""), nil
+}
+
 // TemplateFuncs returns template functions
 func TemplateFuncs(
 	principal *PrincipalExt,
@@ -4,7 +4,8 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
-	"os"
+	"github.com/bhatti/PlexAuthZ/internal/metrics"
+	"time"
 )
 
 type TLSConfig struct {
@@ -13,35 +14,48 @@ type TLSConfig struct {
 	CAFile        string
 	ServerAddress string
 	Server        bool
+	// ReloadInterval for checking changes of certificate, key and CA files.
+	ReloadInterval time.Duration
+	// MetricsRegistry records expiry and reloads of certificates.
+	MetricsRegistry *metrics.Registry
 }
 
+// SetupTLS builds TLS config that reloads certificates and CA pool when their files are changed,
+// where servers use GetCertificate and GetConfigForClient for client CAs and clients use
+// GetClientCertificate and VerifyConnection for verifying server certificates with the current CA pool.
 func (c TLSConfig) SetupTLS() (tlsConfig *tls.Config, err error) {
+	reloader, err := NewCertReloader(c, c.ReloadInterval)
+	if err != nil {
+		return nil, err
+	}
 	tlsConfig = &tls.Config{}
 
 	if c.CertFile != "" && c.KeyFile != "" {
-		tlsConfig.Certificates = make([]tls.Certificate, 1)
-		tlsConfig.Certificates[0], err = tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
-		if err != nil {
-			return nil, err
+		if c.Server {
+			tlsConfig.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+				return reloader.Certificate()
+			}
+		} else {
+			tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+				return reloader.Certificate()
+			}
 		}
 	}
 
 	if c.CAFile != "" {
-		b, err := os.ReadFile(c.CAFile)
-		if err != nil {
-			return nil, err
-		}
-
-		ca := x509.NewCertPool()
-		if !ca.AppendCertsFromPEM(b) {
-			return nil, fmt.Errorf("failed to parse root certificate %q", c.CAFile)
-		}
-
 		if c.Server {
-			tlsConfig.ClientCAs = ca
+			tlsConfig.ClientCAs = reloader.caPool
 			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+			tlsConfig.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+				return c.serverConfigForClient(tlsConfig, reloader)
+			}
 		} else {
-			tlsConfig.RootCAs = ca
+			tlsConfig.RootCAs = reloader.caPool
+			// server certificates are verified with the reloaded CA pool by VerifyConnection
+			tlsConfig.InsecureSkipVerify = true
+			tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+				return c.verifyServerCertificate(state, reloader)
+			}
 		}
 
 		tlsConfig.ServerName = c.ServerAddress
@@ -49,3 +63,44 @@ func (c TLSConfig) SetupTLS() (tlsConfig *tls.Config, err error) {
 
 	return
 }
+
+// serverConfigForClient copies the server config, which may have been changed after the setup,
+// with the current CA pool for verifying client certificates.
+func (c TLSConfig) serverConfigForClient(tlsConfig *tls.Config, reloader *CertReloader) (*tls.Config, error) {
+	caPool, err := reloader.CertPool()
+	if err != nil {
+		return nil, err
+	}
+	config := tlsConfig.Clone()
+	config.GetConfigForClient = nil
+	config.ClientCAs = caPool
+	if len(config.NextProtos) == 0 {
+		// protocols added by gRPC and HTTP servers to their copies of the config
+		config.NextProtos = []string{"h2", "http/1.1"}
+	}
+	return config, nil
+}
+
+func (c TLSConfig) verifyServerCertificate(state tls.ConnectionState, reloader *CertReloader) error {
+	caPool, err := reloader.CertPool()
+	if err != nil {
+		return err
+	}
+	if len(state.PeerCertificates) == 0 {
+		return fmt.Errorf("server certificate is not defined")
+	}
+	serverName := state.ServerName
+	if serverName == "" {
+		serverName = c.ServerAddress
+	}
+	opts := x509.VerifyOptions{
+		Roots:         caPool,
+		DNSName:       serverName,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range state.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err = state.PeerCertificates[0].Verify(opts)
+	return err
+}
@@ -0,0 +1,196 @@
+package domain
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"github.com/bhatti/PlexAuthZ/internal/metrics"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultCertReloadInterval defines how often certificate files are checked for changes.
+const DefaultCertReloadInterval = 5 * time.Second
+
+// CertReloader keeps certificate, key and CA files of TLSConfig in memory and reloads them when
+// their modification time changes, which is checked at most once per interval during TLS handshakes.
+// When reloaded files are invalid, e.g., unparseable, mismatched with the key or expired, the
+// handshakes fail until valid files are installed rather than using stale certificates. Expiry of
+// loaded certificates and reloads are recorded in the metrics registry of the config.
+type CertReloader struct {
+	config    TLSConfig
+	metrics   *metrics.Registry
+	interval  time.Duration
+	lock      sync.RWMutex
+	cert      *tls.Certificate
+	caPool    *x509.CertPool
+	modTimes  map[string]time.Time
+	lastCheck time.Time
+	err       error
+}
+
+// NewCertReloader constructor, which fails if the files cannot be loaded.
+func NewCertReloader(config TLSConfig, interval time.Duration) (*CertReloader, error) {
+	if interval <= 0 {
+		interval = DefaultCertReloadInterval
+	}
+	metricsRegistry := config.MetricsRegistry
+	if metricsRegistry == nil {
+		metricsRegistry = metrics.New()
+	}
+	r := &CertReloader{
+		config:   config,
+		metrics:  metricsRegistry,
+		interval: interval,
+		modTimes: make(map[string]time.Time),
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Certificate returns current certificate after reloading changed files.
+func (r *CertReloader) Certificate() (*tls.Certificate, error) {
+	r.checkChanges()
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.cert, nil
+}
+
+// CertPool returns current CA pool after reloading changed files.
+func (r *CertReloader) CertPool() (*x509.CertPool, error) {
+	r.checkChanges()
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.caPool, nil
+}
+
+// Reload loads certificate, key and CA files.
+func (r *CertReloader) Reload() error {
+	modTimes := make(map[string]time.Time)
+	for _, file := range r.files() {
+		if info, err := os.Stat(file); err == nil {
+			modTimes[file] = info.ModTime()
+		}
+	}
+	cert, caPool, err := r.load()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.modTimes = modTimes
+	r.lastCheck = time.Now()
+	r.err = err
+	if err != nil {
+		r.metrics.Incr("tls_certificate_reload_failures", "file", r.config.CertFile)
+		log.WithFields(log.Fields{
+			"Component": "CertReloader",
+			"CertFile":  r.config.CertFile,
+			"CAFile":    r.config.CAFile,
+			"Error":     err,
+		}).Errorf("failed to reload certificates, rejecting TLS handshakes")
+		return err
+	}
+	r.metrics.Incr("tls_certificate_reloads", "file", r.config.CertFile)
+	r.cert = cert
+	r.caPool = caPool
+	return nil
+}
+
+func (r *CertReloader) checkChanges() {
+	r.lock.RLock()
+	due := time.Since(r.lastCheck) >= r.interval
+	r.lock.RUnlock()
+	if !due {
+		return
+	}
+	changed := false
+	r.lock.Lock()
+	r.lastCheck = time.Now()
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil || !info.ModTime().Equal(r.modTimes[file]) {
+			changed = true
+			break
+		}
+	}
+	r.lock.Unlock()
+	if changed {
+		_ = r.Reload()
+	}
+}
+
+func (r *CertReloader) files() (files []string) {
+	for _, file := range []string{r.config.CertFile, r.config.KeyFile, r.config.CAFile} {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return
+}
+
+func (r *CertReloader) load() (cert *tls.Certificate, caPool *x509.CertPool, err error) {
+	now := time.Now()
+	if r.config.CertFile != "" && r.config.KeyFile != "" {
+		pair, err := tls.LoadX509KeyPair(r.config.CertFile, r.config.KeyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		if pair.Leaf, err = x509.ParseCertificate(pair.Certificate[0]); err != nil {
+			return nil, nil, err
+		}
+		if err = verifyValidity(pair.Leaf, r.config.CertFile, now); err != nil {
+			return nil, nil, err
+		}
+		r.metrics.Set("tls_certificate_expiry_timestamp_seconds",
+			float64(pair.Leaf.NotAfter.Unix()), "file", r.config.CertFile)
+		cert = &pair
+	}
+	if r.config.CAFile != "" {
+		b, err := os.ReadFile(r.config.CAFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		caPool = x509.NewCertPool()
+		var expiry time.Time
+		for block, rest := pem.Decode(b); block != nil; block, rest = pem.Decode(rest) {
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			ca, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, nil, err
+			}
+			if err = verifyValidity(ca, r.config.CAFile, now); err != nil {
+				return nil, nil, err
+			}
+			caPool.AddCert(ca)
+			if expiry.IsZero() || ca.NotAfter.Before(expiry) {
+				expiry = ca.NotAfter
+			}
+		}
+		if expiry.IsZero() {
+			return nil, nil, fmt.Errorf("failed to parse root certificate %q", r.config.CAFile)
+		}
+		r.metrics.Set("tls_certificate_expiry_timestamp_seconds",
+			float64(expiry.Unix()), "file", r.config.CAFile)
+	}
+	return cert, caPool, nil
+}
+
+func verifyValidity(cert *x509.Certificate, file string, now time.Time) error {
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return fmt.Errorf("certificate %q of %s is not valid between %s and %s",
+			file, cert.Subject.CommonName, cert.NotBefore, cert.NotAfter)
+	}
+	return nil
+}
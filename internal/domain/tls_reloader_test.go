@@ -0,0 +1,199 @@
+package domain
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/bhatti/PlexAuthZ/internal/metrics"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_ShouldReloadRotatedCertificates(t *testing.T) {
+	// GIVEN server and client certificates signed by a CA
+	dir := t.TempDir()
+	ca, caKey := newTestCertificate(t, "ca", nil, nil, time.Hour)
+	writeTestCertificate(t, dir, "ca", ca, nil)
+	server, serverKey := newTestCertificate(t, "127.0.0.1", ca, caKey, time.Hour)
+	writeTestCertificate(t, dir, "server", server, serverKey)
+	client, clientKey := newTestCertificate(t, "client", ca, caKey, time.Hour)
+	writeTestCertificate(t, dir, "client", client, clientKey)
+
+	metricsRegistry := metrics.New()
+	serverConfig, err := TLSConfig{
+		CertFile:        filepath.Join(dir, "server.pem"),
+		KeyFile:         filepath.Join(dir, "server-key.pem"),
+		CAFile:          filepath.Join(dir, "ca.pem"),
+		Server:          true,
+		ReloadInterval:  time.Millisecond,
+		MetricsRegistry: metricsRegistry,
+	}.SetupTLS()
+	require.NoError(t, err)
+	clientConfig, err := TLSConfig{
+		CertFile:       filepath.Join(dir, "client.pem"),
+		KeyFile:        filepath.Join(dir, "client-key.pem"),
+		CAFile:         filepath.Join(dir, "ca.pem"),
+		ReloadInterval: time.Millisecond,
+	}.SetupTLS()
+	require.NoError(t, err)
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+	require.NoError(t, err)
+	defer func() {
+		_ = listener.Close()
+	}()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.(*tls.Conn).Handshake()
+			_ = conn.Close()
+		}
+	}()
+
+	// WHEN connecting with the initial certificates
+	peer, err := testHandshake(listener.Addr().String(), clientConfig)
+	// THEN it should succeed
+	require.NoError(t, err)
+	require.Equal(t, server.SerialNumber, peer.SerialNumber)
+	require.Equal(t, float64(1), metricsRegistry.Summary()["tls_certificate_reloads_total"])
+	// AND expiry of the loaded certificate should be recorded
+	certRegistry := metrics.New()
+	_, err = NewCertReloader(TLSConfig{
+		CertFile:        filepath.Join(dir, "server.pem"),
+		KeyFile:         filepath.Join(dir, "server-key.pem"),
+		MetricsRegistry: certRegistry,
+	}, 0)
+	require.NoError(t, err)
+	require.Equal(t, float64(server.NotAfter.Unix()),
+		certRegistry.Summary()["tls_certificate_expiry_timestamp_seconds"])
+
+	// WHEN rotating all certificates with a new CA
+	ca, caKey = newTestCertificate(t, "new-ca", nil, nil, 2*time.Hour)
+	writeTestCertificate(t, dir, "ca", ca, nil)
+	rotated, rotatedKey := newTestCertificate(t, "127.0.0.1", ca, caKey, 2*time.Hour)
+	writeTestCertificate(t, dir, "server", rotated, rotatedKey)
+	client, clientKey = newTestCertificate(t, "client", ca, caKey, 2*time.Hour)
+	writeTestCertificate(t, dir, "client", client, clientKey)
+	time.Sleep(5 * time.Millisecond)
+
+	// THEN connections should use the rotated certificates without restarting
+	peer, err = testHandshake(listener.Addr().String(), clientConfig)
+	require.NoError(t, err)
+	require.Equal(t, rotated.SerialNumber, peer.SerialNumber)
+	require.GreaterOrEqual(t, metricsRegistry.Summary()["tls_certificate_reloads_total"], float64(2))
+
+	// WHEN replacing server certificate with an expired certificate
+	expired, expiredKey := newTestCertificate(t, "127.0.0.1", ca, caKey, -time.Minute)
+	writeTestCertificate(t, dir, "server", expired, expiredKey)
+	time.Sleep(5 * time.Millisecond)
+
+	// THEN connections should fail rather than using the stale certificate
+	_, err = testHandshake(listener.Addr().String(), clientConfig)
+	require.Error(t, err)
+	require.GreaterOrEqual(t, metricsRegistry.Summary()["tls_certificate_reload_failures_total"], float64(1))
+
+	// WHEN replacing server certificate with a certificate of an unknown CA
+	otherCA, otherCAKey := newTestCertificate(t, "other-ca", nil, nil, time.Hour)
+	other, otherKey := newTestCertificate(t, "127.0.0.1", otherCA, otherCAKey, time.Hour)
+	writeTestCertificate(t, dir, "server", other, otherKey)
+	time.Sleep(5 * time.Millisecond)
+
+	// THEN client should reject the server certificate
+	_, err = testHandshake(listener.Addr().String(), clientConfig)
+	require.Error(t, err)
+}
+
+func Test_ShouldFailReloaderWithInvalidCertificates(t *testing.T) {
+	// GIVEN an expired certificate
+	dir := t.TempDir()
+	ca, caKey := newTestCertificate(t, "ca", nil, nil, time.Hour)
+	writeTestCertificate(t, dir, "ca", ca, nil)
+	expired, expiredKey := newTestCertificate(t, "127.0.0.1", ca, caKey, -time.Minute)
+	writeTestCertificate(t, dir, "server", expired, expiredKey)
+
+	// WHEN setting up TLS
+	_, err := TLSConfig{
+		CertFile: filepath.Join(dir, "server.pem"),
+		KeyFile:  filepath.Join(dir, "server-key.pem"),
+		CAFile:   filepath.Join(dir, "ca.pem"),
+		Server:   true,
+	}.SetupTLS()
+	// THEN it should fail
+	require.Error(t, err)
+
+	// AND it should fail with invalid CA file
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ca.pem"), []byte("invalid"), 0600))
+	_, err = TLSConfig{CAFile: filepath.Join(dir, "ca.pem")}.SetupTLS()
+	require.Error(t, err)
+}
+
+func testHandshake(addr string, config *tls.Config) (*x509.Certificate, error) {
+	conn, err := tls.Dial("tcp", addr, config)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	return conn.ConnectionState().PeerCertificates[0], nil
+}
+
+func newTestCertificate(
+	t *testing.T,
+	cn string,
+	parent *x509.Certificate,
+	parentKey *ecdsa.PrivateKey,
+	validity time.Duration,
+) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(validity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+		template.IPAddresses = nil
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+func writeTestCertificate(t *testing.T, dir string, name string, cert *x509.Certificate, key *ecdsa.PrivateKey) {
+	// modification time is randomized as files may be rewritten within granularity of timestamps
+	modTime := time.Now().Add(time.Duration(time.Now().UnixNano() % int64(time.Hour)))
+	certFile := filepath.Join(dir, name+".pem")
+	require.NoError(t, os.WriteFile(certFile,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0600))
+	require.NoError(t, os.Chtimes(certFile, modTime, modTime))
+	if key != nil {
+		keyDer, err := x509.MarshalECPrivateKey(key)
+		require.NoError(t, err)
+		keyFile := filepath.Join(dir, name+"-key.pem")
+		require.NoError(t, os.WriteFile(keyFile,
+			pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+		require.NoError(t, os.Chtimes(keyFile, modTime, modTime))
+	}
+}
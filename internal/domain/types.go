@@ -13,6 +13,7 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"time"
 	"unsafe"
 )
 
@@ -54,6 +55,86 @@ func (x *HashIndex) Validate() error {
 	return nil
 }
 
+// LeaderLease records the server that runs a background job, e.g., sweeping expired resource instances,
+// so that the job runs on one server at a time. Another server takes over the job after the lease expires.
+type LeaderLease struct {
+	Job       string                 `json:"job,omitempty"`
+	Holder    string                 `json:"holder,omitempty"`
+	Version   int64                  `json:"version,omitempty"`
+	ExpiresAt *timestamppb.Timestamp `json:"expires_at,omitempty"`
+	// Completed is set when the job runs once such as bootstrapping, which is not expired.
+	Completed bool `json:"completed,omitempty"`
+}
+
+// NewLeaderLease constructor
+func NewLeaderLease(job string, holder string, version int64, expiry time.Duration) *LeaderLease {
+	return &LeaderLease{
+		Job:       job,
+		Holder:    holder,
+		Version:   version,
+		ExpiresAt: timestamppb.New(time.Now().Add(expiry)),
+	}
+}
+
+// Expired returns true if deadline of the lease has passed.
+func (x *LeaderLease) Expired(now time.Time) bool {
+	return x.ExpiresAt == nil || !x.ExpiresAt.AsTime().After(now)
+}
+
+// CasbinPolicy defines a policy line of casbin model for admin APIs
+type CasbinPolicy struct {
+	Id      string                 `json:"id,omitempty"`
+	PType   string                 `json:"ptype,omitempty"`
+	Rule    []string               `json:"rule,omitempty"`
+	Created *timestamppb.Timestamp `json:"created,omitempty"`
+}
+
+// NewCasbinPolicy constructor
+func NewCasbinPolicy(ptype string, rule []string) *CasbinPolicy {
+	if ptype == "" {
+		ptype = "p"
+	}
+	x := &CasbinPolicy{
+		PType:   ptype,
+		Rule:    rule,
+		Created: timestamppb.Now(),
+	}
+	x.Id = x.Hash()
+	return x
+}
+
+// Validate helper
+func (x *CasbinPolicy) Validate() error {
+	if x.PType == "" {
+		return NewValidationError(fmt.Sprintf("policy type is not defined"))
+	}
+	if len(x.Rule) == 0 {
+		return NewValidationError(fmt.Sprintf("policy rule is not defined"))
+	}
+	for _, v := range x.Rule {
+		if strings.TrimSpace(v) == "" || strings.Contains(v, ",") {
+			return NewValidationError(fmt.Sprintf("invalid policy rule value '%s'", v))
+		}
+	}
+	return nil
+}
+
+// Hash calculates hash of policy type and rule
+func (x *CasbinPolicy) Hash() string {
+	h := sha256.New()
+	h.Write([]byte(x.PType))
+	for _, v := range x.Rule {
+		h.Write([]byte(","))
+		h.Write([]byte(strings.TrimSpace(v)))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// String converts policy to casbin CSV line
+func (x *CasbinPolicy) String() string {
+	return x.PType + ", " + strings.Join(x.Rule, ", ")
+}
+
 // OrganizationExt that owns roles, groups, relations, and principals for a given namespace.
 type OrganizationExt struct {
 	Delegate *types.Organization
@@ -359,6 +440,8 @@ type PrincipalExt struct {
 	RelationsById             map[string]*types.Relationship
 	ResourcesById             map[string]*types.Resource
 	PermissionsByResourceName map[string]map[string]*types.Permission
+	// Revision when the principal was loaded from the data store.
+	Revision int64
 }
 
 // NewPrincipalExt constructor
@@ -639,6 +722,32 @@ func (x *PrincipalExt) LatestGroupRoleDate() (latestGroupRoleDate *timestamppb.T
 	return
 }
 
+// HasGroup returns true if principal is member of the group directly or through parent groups.
+func (x *PrincipalExt) HasGroup(id string) bool {
+	if utils.Includes(x.Delegate.GroupIds, id) {
+		return true
+	}
+	for _, group := range x.GroupsByName {
+		if group.Id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// HasRole returns true if principal holds the role directly, through groups or parent roles.
+func (x *PrincipalExt) HasRole(id string) bool {
+	if utils.Includes(x.Delegate.RoleIds, id) {
+		return true
+	}
+	for _, role := range x.RolesByName {
+		if role.Id == id {
+			return true
+		}
+	}
+	return false
+}
+
 // ToGetPrincipalResponse helper
 func (x *PrincipalExt) ToGetPrincipalResponse() *services.GetPrincipalResponse {
 	return &services.GetPrincipalResponse{
@@ -764,6 +873,9 @@ func (x *PrincipalExt) CheckPermission(
 		}
 	}
 	// Checking matched permissions
+	if res != nil {
+		res.PermissionIds = permissionIds
+	}
 	if len(effects) == 0 {
 		return res, NewAuthError(fmt.Sprintf("no permissions[%d/%v/%v] matched for %s %s",
 			len(permissionIds), actionMatched, constraintsFailed, req.Resource, req.Action))
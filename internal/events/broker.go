@@ -0,0 +1,203 @@
+package events
+
+import (
+	"fmt"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/metrics"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"sync"
+)
+
+// Broker publishes change events to watches of organizations, where recent events are kept in
+// history so that watches can be resumed from the cursor of the last received event.
+type Broker struct {
+	config          domain.WatchConfig
+	metricsRegistry *metrics.Registry
+	history         []*types.ChangeEvent
+	next            int
+	cursor          int64
+	subscriptions   map[*Subscription]bool
+	lock            sync.Mutex
+}
+
+// Subscription of a watch with filters of namespace and entity types.
+type Subscription struct {
+	organizationId string
+	namespace      string
+	entityTypes    map[types.ChangeEntityType]bool
+	events         chan *types.ChangeEvent
+	lagging        bool
+}
+
+// NewBroker constructor
+func NewBroker(config domain.WatchConfig, metricsRegistry *metrics.Registry) (*Broker, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &Broker{
+		config:          config,
+		metricsRegistry: metricsRegistry,
+		history:         make([]*types.ChangeEvent, 0, config.HistorySize),
+		subscriptions:   make(map[*Subscription]bool),
+	}, nil
+}
+
+// Publish assigns next cursor to the change and sends it to matching watches, where watches
+// with full buffer are closed instead of blocking the change.
+func (b *Broker) Publish(
+	organizationId string,
+	namespace string,
+	entityType types.ChangeEntityType,
+	entityId string,
+	version int64,
+	operation types.ChangeOperation,
+) *types.ChangeEvent {
+	if b == nil {
+		return nil
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.cursor++
+	event := &types.ChangeEvent{
+		Cursor:         b.cursor,
+		OrganizationId: organizationId,
+		Namespace:      namespace,
+		EntityType:     entityType,
+		EntityId:       entityId,
+		Version:        version,
+		Operation:      operation,
+		Created:        timestamppb.Now(),
+	}
+	if len(b.history) < cap(b.history) {
+		b.history = append(b.history, event)
+	} else {
+		b.history[b.next] = event
+		b.next = (b.next + 1) % len(b.history)
+	}
+	for sub := range b.subscriptions {
+		if !sub.matches(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			sub.lagging = true
+			b.remove(sub)
+			b.metricsRegistry.Incr("watch_lagging", "org", organizationId)
+		}
+	}
+	b.metricsRegistry.Incr("watch_published", "org", organizationId)
+	return event
+}
+
+// Subscribe starts watch of organization and returns events after the cursor from history, which
+// fails if the cursor is older than the history or newer than the last change such as after restart
+// so that watchers reload their state. Only new events are sent when cursor is zero.
+func (b *Broker) Subscribe(
+	organizationId string,
+	namespace string,
+	entityTypes []types.ChangeEntityType,
+	cursor int64,
+) (*Subscription, []*types.ChangeEvent, error) {
+	if organizationId == "" {
+		return nil, nil, domain.NewValidationError("organization_id is not defined")
+	}
+	sub := &Subscription{
+		organizationId: organizationId,
+		namespace:      namespace,
+		entityTypes:    make(map[types.ChangeEntityType]bool),
+		events:         make(chan *types.ChangeEvent, b.config.SubscriberBufferSize),
+	}
+	for _, entityType := range entityTypes {
+		sub.entityTypes[entityType] = true
+	}
+	return b.subscribe(sub, cursor)
+}
+
+// SubscribeAll starts watch of all organizations such as for delivering webhooks, which is
+// resumed from the cursor like Subscribe.
+func (b *Broker) SubscribeAll(
+	cursor int64,
+	bufferSize int,
+) (*Subscription, []*types.ChangeEvent, error) {
+	return b.subscribe(&Subscription{
+		entityTypes: make(map[types.ChangeEntityType]bool),
+		events:      make(chan *types.ChangeEvent, bufferSize),
+	}, cursor)
+}
+
+func (b *Broker) subscribe(
+	sub *Subscription,
+	cursor int64,
+) (*Subscription, []*types.ChangeEvent, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if cursor > b.cursor {
+		// cursor of a previous process, which cannot be resumed
+		return nil, nil, domain.NewValidationError(
+			fmt.Sprintf("cursor %d is newer than the last change %d", cursor, b.cursor))
+	}
+	var replay []*types.ChangeEvent
+	if cursor > 0 && cursor < b.cursor {
+		ordered := b.ordered()
+		if len(ordered) == 0 || ordered[0].Cursor > cursor+1 {
+			return nil, nil, domain.NewValidationError(
+				fmt.Sprintf("cursor %d is older than the history of changes", cursor))
+		}
+		for _, event := range ordered {
+			if event.Cursor > cursor && sub.matches(event) {
+				replay = append(replay, event)
+			}
+		}
+	}
+	b.subscriptions[sub] = true
+	return sub, replay, nil
+}
+
+// Unsubscribe stops the watch.
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.remove(sub)
+}
+
+// Cursor returns cursor of the last published event.
+func (b *Broker) Cursor() int64 {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.cursor
+}
+
+// Events returns channel of the watch, which is closed when the watch is unsubscribed or lagging.
+func (s *Subscription) Events() <-chan *types.ChangeEvent {
+	return s.events
+}
+
+// Lagging returns true if the watch was closed because it didn't receive events fast enough.
+func (s *Subscription) Lagging() bool {
+	return s.lagging
+}
+
+func (s *Subscription) matches(event *types.ChangeEvent) bool {
+	if s.organizationId != "" && event.OrganizationId != s.organizationId {
+		return false
+	}
+	if s.namespace != "" && event.Namespace != "" && event.Namespace != s.namespace {
+		return false
+	}
+	return len(s.entityTypes) == 0 || s.entityTypes[event.EntityType]
+}
+
+func (b *Broker) remove(sub *Subscription) {
+	if b.subscriptions[sub] {
+		delete(b.subscriptions, sub)
+		close(sub.events)
+	}
+}
+
+func (b *Broker) ordered() []*types.ChangeEvent {
+	res := make([]*types.ChangeEvent, 0, len(b.history))
+	res = append(res, b.history[b.next:]...)
+	return append(res, b.history[0:b.next]...)
+}
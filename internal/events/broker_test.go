@@ -0,0 +1,89 @@
+package events
+
+import (
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/metrics"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_ShouldPublishChangesToMatchingWatches(t *testing.T) {
+	// GIVEN broker with watches of roles and all changes
+	broker, err := NewBroker(domain.WatchConfig{}, metrics.New())
+	require.NoError(t, err)
+	roles, _, err := broker.Subscribe("org", "ns", []types.ChangeEntityType{types.ChangeEntityType_ROLE}, 0)
+	require.NoError(t, err)
+	all, _, err := broker.Subscribe("org", "", nil, 0)
+	require.NoError(t, err)
+
+	// WHEN publishing changes
+	broker.Publish("org", "ns", types.ChangeEntityType_ROLE, "r1", 1, types.ChangeOperation_CREATED)
+	broker.Publish("org", "other", types.ChangeEntityType_ROLE, "r2", 1, types.ChangeOperation_CREATED)
+	broker.Publish("org", "", types.ChangeEntityType_PRINCIPAL, "p1", 2, types.ChangeOperation_UPDATED)
+	broker.Publish("other-org", "ns", types.ChangeEntityType_ROLE, "r3", 1, types.ChangeOperation_DELETED)
+	broker.Unsubscribe(roles)
+	broker.Unsubscribe(all)
+
+	// THEN watches should receive matching changes with increasing cursors
+	var received []*types.ChangeEvent
+	for event := range roles.Events() {
+		received = append(received, event)
+	}
+	require.Len(t, received, 1)
+	require.Equal(t, "r1", received[0].EntityId)
+	require.Equal(t, int64(1), received[0].Cursor)
+	received = nil
+	for event := range all.Events() {
+		received = append(received, event)
+	}
+	require.Len(t, received, 3)
+	require.Equal(t, types.ChangeOperation_UPDATED, received[2].Operation)
+	require.Equal(t, int64(3), received[2].Cursor)
+}
+
+func Test_ShouldResumeWatchFromCursor(t *testing.T) {
+	// GIVEN broker with small history
+	broker, err := NewBroker(domain.WatchConfig{HistorySize: 3}, metrics.New())
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		broker.Publish("org", "ns", types.ChangeEntityType_GROUP, "g1", int64(i+1), types.ChangeOperation_UPDATED)
+	}
+
+	// WHEN resuming from cursor within history
+	sub, replay, err := broker.Subscribe("org", "", nil, 3)
+	// THEN it should replay changes after the cursor
+	require.NoError(t, err)
+	require.Len(t, replay, 2)
+	require.Equal(t, int64(4), replay[0].Cursor)
+	require.Equal(t, int64(5), replay[1].Cursor)
+	broker.Unsubscribe(sub)
+
+	// WHEN resuming from cursor older than history or newer than last change
+	_, _, err = broker.Subscribe("org", "", nil, 1)
+	// THEN it should fail
+	require.Error(t, err)
+	_, _, err = broker.Subscribe("org", "", nil, 10)
+	require.Error(t, err)
+	require.Equal(t, int64(5), broker.Cursor())
+}
+
+func Test_ShouldCloseLaggingWatches(t *testing.T) {
+	// GIVEN broker with small buffer of watches
+	broker, err := NewBroker(domain.WatchConfig{SubscriberBufferSize: 1}, metrics.New())
+	require.NoError(t, err)
+	sub, _, err := broker.Subscribe("org", "", nil, 0)
+	require.NoError(t, err)
+
+	// WHEN publishing more changes than buffer without receiving
+	broker.Publish("org", "", types.ChangeEntityType_PRINCIPAL, "p1", 1, types.ChangeOperation_CREATED)
+	broker.Publish("org", "", types.ChangeEntityType_PRINCIPAL, "p1", 2, types.ChangeOperation_UPDATED)
+
+	// THEN watch should be closed after buffered changes
+	event, ok := <-sub.Events()
+	require.True(t, ok)
+	require.Equal(t, int64(1), event.Cursor)
+	_, ok = <-sub.Events()
+	require.False(t, ok)
+	require.True(t, sub.Lagging())
+}
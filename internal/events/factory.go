@@ -0,0 +1,29 @@
+package events
+
+import (
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/metrics"
+	"sync"
+)
+
+var shared = struct {
+	brokers map[*domain.Config]*Broker
+	lock    sync.Mutex
+}{brokers: make(map[*domain.Config]*Broker)}
+
+// Shared returns broker of the config, which is created once so that changes of the database
+// services are published to watches of gRPC servers and REST controllers in the process. Metrics of the
+// broker are recorded in the registry of the process.
+func Shared(config *domain.Config, metricsRegistry *metrics.Registry) (*Broker, error) {
+	shared.lock.Lock()
+	defer shared.lock.Unlock()
+	if broker := shared.brokers[config]; broker != nil {
+		return broker, nil
+	}
+	broker, err := NewBroker(config.Watch, metricsRegistry)
+	if err != nil {
+		return nil, err
+	}
+	shared.brokers[config] = broker
+	return broker, nil
+}
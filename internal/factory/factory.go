@@ -2,6 +2,7 @@ package factory
 
 import (
 	"bytes"
+	"github.com/bhatti/PlexAuthZ/internal/bundle"
 	"github.com/bhatti/PlexAuthZ/internal/domain"
 	"github.com/bhatti/PlexAuthZ/internal/metrics"
 	"github.com/bhatti/PlexAuthZ/internal/server"
@@ -52,6 +53,12 @@ func CreateAuthAdminService(
 		}
 		authService := grpc.NewAuthAdminServiceGrpc(clients)
 		return authService, cc, nil
+	} else if cfg.AuthServiceProvider == domain.BundleAuthServiceProvider {
+		authService, err := bundle.NewAuthAdminServiceBundle(cfg.Bundle)
+		if err != nil {
+			return nil, nil, err
+		}
+		return authService, io.NopCloser(bytes.NewReader([]byte{})), nil
 	} else {
 		return db.CreateDatabaseAuthService(cfg, registry)
 	}
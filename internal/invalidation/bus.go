@@ -0,0 +1,102 @@
+package invalidation
+
+import (
+	"context"
+	"sync"
+)
+
+// Kind of entity whose cached data is invalidated.
+type Kind string
+
+const (
+	// PrincipalKind evicts the cached principal
+	PrincipalKind Kind = "PRINCIPAL"
+
+	// GroupKind evicts cached principals that are members of the group
+	GroupKind Kind = "GROUP"
+
+	// RoleKind evicts cached principals that hold the role directly or through groups
+	RoleKind Kind = "ROLE"
+
+	// OrganizationKind evicts the cached organization and all of its cached principals
+	OrganizationKind Kind = "ORGANIZATION"
+
+	// AllKind evicts all cached data such as after missing invalidations of other replicas
+	AllKind Kind = "ALL"
+)
+
+// Invalidation of cached data after a change.
+type Invalidation struct {
+	// Origin of the bus that published the invalidation.
+	Origin         string `json:"origin"`
+	OrganizationId string `json:"organization_id"`
+	Kind           Kind   `json:"kind"`
+	Id             string `json:"id"`
+}
+
+// Handler evicts cached data of the invalidation.
+type Handler func(*Invalidation)
+
+// Bus publishes invalidations to handlers of caches, where handlers in the process are invoked before
+// Publish returns so that changes are visible to subsequent requests of the same replica.
+type Bus interface {
+	// Publish sends invalidation to all subscribers.
+	Publish(ctx context.Context, invalidation *Invalidation) error
+	// Subscribe adds handler of invalidations and returns id of the subscription.
+	Subscribe(handler Handler) int64
+	// Unsubscribe removes handler of the subscription.
+	Unsubscribe(id int64)
+	// Close stops receiving invalidations.
+	Close() error
+}
+
+// LocalBus sends invalidations to handlers in the process.
+type LocalBus struct {
+	handlers map[int64]Handler
+	lastID   int64
+	lock     sync.RWMutex
+}
+
+// NewLocalBus constructor
+func NewLocalBus() *LocalBus {
+	return &LocalBus{handlers: make(map[int64]Handler)}
+}
+
+// Publish invokes all handlers.
+func (b *LocalBus) Publish(_ context.Context, invalidation *Invalidation) error {
+	b.dispatch(invalidation)
+	return nil
+}
+
+// Subscribe adds handler of invalidations and returns id of the subscription.
+func (b *LocalBus) Subscribe(handler Handler) int64 {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.lastID++
+	b.handlers[b.lastID] = handler
+	return b.lastID
+}
+
+// Unsubscribe removes handler of the subscription.
+func (b *LocalBus) Unsubscribe(id int64) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	delete(b.handlers, id)
+}
+
+// Close no-op
+func (b *LocalBus) Close() error {
+	return nil
+}
+
+func (b *LocalBus) dispatch(invalidation *Invalidation) {
+	b.lock.RLock()
+	handlers := make([]Handler, 0, len(b.handlers))
+	for _, handler := range b.handlers {
+		handlers = append(handlers, handler)
+	}
+	b.lock.RUnlock()
+	for _, handler := range handlers {
+		handler(invalidation)
+	}
+}
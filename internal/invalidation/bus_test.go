@@ -0,0 +1,72 @@
+package invalidation
+
+import (
+	"context"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/metrics"
+	redisstore "github.com/bhatti/PlexAuthZ/internal/repository/redis"
+	"github.com/stretchr/testify/require"
+	"github.com/twinj/uuid"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_ShouldPublishToLocalHandlers(t *testing.T) {
+	// GIVEN local bus with a handler
+	bus := NewLocalBus()
+	var received []*Invalidation
+	id := bus.Subscribe(func(inv *Invalidation) {
+		received = append(received, inv)
+	})
+
+	// WHEN publishing invalidation
+	err := bus.Publish(context.Background(), &Invalidation{OrganizationId: "org", Kind: RoleKind, Id: "r1"})
+
+	// THEN handler should be invoked before publish returns
+	require.NoError(t, err)
+	require.Len(t, received, 1)
+	require.Equal(t, RoleKind, received[0].Kind)
+
+	// AND handler should not be invoked after unsubscribing
+	bus.Unsubscribe(id)
+	require.NoError(t, bus.Publish(context.Background(), &Invalidation{Kind: AllKind}))
+	require.Len(t, received, 1)
+}
+
+func Test_ShouldPublishToOtherReplicasWithRedis(t *testing.T) {
+	// GIVEN two redis buses on the same channel
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	channel := "test_invalidations_" + uuid.NewV4().String()
+	var lock sync.Mutex
+	received := make(map[string][]*Invalidation)
+	buses := make([]*RedisBus, 2)
+	for i, name := range []string{"first", "second"} {
+		name := name
+		buses[i] = NewRedisBus(redisstore.NewPool(cfg), channel, time.Second, metrics.New())
+		buses[i].Subscribe(func(inv *Invalidation) {
+			lock.Lock()
+			defer lock.Unlock()
+			received[name] = append(received[name], inv)
+		})
+	}
+
+	// WHEN publishing invalidation of a group with first bus
+	err = buses[0].Publish(context.Background(), &Invalidation{OrganizationId: "org", Kind: GroupKind, Id: "g1"})
+	require.NoError(t, err)
+
+	// THEN second bus should receive it
+	require.Eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(received["second"]) == 1 && received["second"][0].Id == "g1"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// AND first bus should receive it once without its own message
+	require.NoError(t, buses[1].Close())
+	require.NoError(t, buses[0].Close())
+	lock.Lock()
+	defer lock.Unlock()
+	require.Len(t, received["first"], 1)
+}
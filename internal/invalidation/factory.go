@@ -0,0 +1,39 @@
+package invalidation
+
+import (
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/metrics"
+	redisstore "github.com/bhatti/PlexAuthZ/internal/repository/redis"
+	"sync"
+)
+
+var shared = struct {
+	buses map[*domain.Config]Bus
+	lock  sync.Mutex
+}{buses: make(map[*domain.Config]Bus)}
+
+// Shared returns invalidation bus of the config, which is created once so that caches of database
+// services of gRPC servers and REST controllers in the process are evicted together. Metrics of the
+// bus are recorded in the registry of the process.
+func Shared(config *domain.Config, metricsRegistry *metrics.Registry) (Bus, error) {
+	shared.lock.Lock()
+	defer shared.lock.Unlock()
+	if bus := shared.buses[config]; bus != nil {
+		return bus, nil
+	}
+	if err := config.CacheInvalidation.Validate(); err != nil {
+		return nil, err
+	}
+	var bus Bus
+	if config.CacheInvalidation.Bus == domain.RedisCacheInvalidationBus {
+		bus = NewRedisBus(
+			redisstore.NewPool(config),
+			config.CacheInvalidation.Channel,
+			config.CacheInvalidation.ReconnectInterval,
+			metricsRegistry)
+	} else {
+		bus = NewLocalBus()
+	}
+	shared.buses[config] = bus
+	return bus, nil
+}
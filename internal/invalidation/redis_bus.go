@@ -0,0 +1,161 @@
+package invalidation
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/bhatti/PlexAuthZ/internal/metrics"
+	"github.com/gomodule/redigo/redis"
+	log "github.com/sirupsen/logrus"
+	"github.com/twinj/uuid"
+	"sync"
+	"time"
+)
+
+// RedisBus publishes invalidations with Redis pub/sub so that every replica evicts its caches, where
+// handlers of the publishing replica are invoked directly and its own messages are ignored.
+type RedisBus struct {
+	*LocalBus
+	pool              *redis.Pool
+	channel           string
+	origin            string
+	reconnectInterval time.Duration
+	metricsRegistry   *metrics.Registry
+	conn              *redis.PubSubConn
+	closed            bool
+	lock              sync.Mutex
+	wg                sync.WaitGroup
+}
+
+// NewRedisBus constructor, which subscribes to the channel in background.
+func NewRedisBus(
+	pool *redis.Pool,
+	channel string,
+	reconnectInterval time.Duration,
+	metricsRegistry *metrics.Registry,
+) *RedisBus {
+	b := &RedisBus{
+		LocalBus:          NewLocalBus(),
+		pool:              pool,
+		channel:           channel,
+		origin:            uuid.NewV4().String(),
+		reconnectInterval: reconnectInterval,
+		metricsRegistry:   metricsRegistry,
+	}
+	ready := make(chan bool, 1)
+	b.wg.Add(1)
+	go b.receive(ready)
+	// wait briefly for the subscription so that invalidations published right after start are received
+	select {
+	case <-ready:
+	case <-time.After(reconnectInterval):
+	}
+	return b
+}
+
+// Publish invokes handlers of the process and sends invalidation to other replicas.
+func (b *RedisBus) Publish(_ context.Context, invalidation *Invalidation) error {
+	b.dispatch(invalidation)
+	message := *invalidation
+	message.Origin = b.origin
+	payload, err := json.Marshal(&message)
+	if err != nil {
+		return err
+	}
+	conn := b.pool.Get()
+	defer func() {
+		_ = conn.Close()
+	}()
+	if _, err = conn.Do("PUBLISH", b.channel, payload); err != nil {
+		b.metricsRegistry.Incr("cache_invalidation_errors", "org", invalidation.OrganizationId)
+		return err
+	}
+	b.metricsRegistry.Incr("cache_invalidation_published", "org", invalidation.OrganizationId)
+	return nil
+}
+
+// Close stops the subscription.
+func (b *RedisBus) Close() error {
+	b.lock.Lock()
+	b.closed = true
+	if b.conn != nil {
+		_ = b.conn.Close()
+	}
+	b.lock.Unlock()
+	b.wg.Wait()
+	return nil
+}
+
+func (b *RedisBus) receive(ready chan bool) {
+	defer b.wg.Done()
+	for reconnect := false; ; reconnect = true {
+		conn, err := b.subscribe()
+		if conn == nil && err == nil {
+			return // closed
+		}
+		if err != nil {
+			log.WithFields(log.Fields{
+				"Component": "RedisInvalidationBus",
+				"Channel":   b.channel,
+				"Error":     err,
+			}).Warnf("failed to subscribe to cache invalidations")
+			time.Sleep(b.reconnectInterval)
+			continue
+		}
+		if reconnect {
+			// invalidations may have been missed while disconnected
+			b.dispatch(&Invalidation{Kind: AllKind})
+		}
+		select {
+		case ready <- true:
+		default:
+		}
+		b.listen(conn)
+	}
+}
+
+func (b *RedisBus) subscribe() (*redis.PubSubConn, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.closed {
+		return nil, nil
+	}
+	// dedicated connection, which is closed to interrupt receiving messages
+	raw, err := b.pool.Dial()
+	if err != nil {
+		return nil, err
+	}
+	conn := &redis.PubSubConn{Conn: raw}
+	if err = conn.Subscribe(b.channel); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	// wait for confirmation so that invalidations published afterward are received
+	if err, ok := conn.Receive().(error); ok {
+		_ = conn.Close()
+		return nil, err
+	}
+	b.conn = conn
+	return conn, nil
+}
+
+func (b *RedisBus) listen(conn *redis.PubSubConn) {
+	defer func() {
+		_ = conn.Close()
+	}()
+	for {
+		switch msg := conn.Receive().(type) {
+		case redis.Message:
+			invalidation := &Invalidation{}
+			if err := json.Unmarshal(msg.Data, invalidation); err != nil {
+				continue
+			}
+			if invalidation.Origin == b.origin {
+				continue
+			}
+			b.metricsRegistry.Incr("cache_invalidation_received", "org", invalidation.OrganizationId)
+			b.dispatch(invalidation)
+		case error:
+			return
+		}
+	}
+}
@@ -175,10 +175,14 @@ func (r *Registry) Summary() map[string]float64 {
 					res[*metric.Name] = *m.Histogram.SampleSum
 					res[strings.ReplaceAll(*metric.Name, "duration_seconds", "counts")] = float64(*m.Histogram.SampleCount)
 				}
-			} else if *metric.Type == dto.MetricType_COUNTER { // dto.MetricType_GAUGE
+			} else if *metric.Type == dto.MetricType_COUNTER {
 				if m.Counter.Value != nil {
 					res[*metric.Name] = *m.Counter.Value
 				}
+			} else if *metric.Type == dto.MetricType_GAUGE {
+				if m.Gauge.Value != nil {
+					res[*metric.Name] = *m.Gauge.Value
+				}
 			}
 		}
 	}
@@ -12,4 +12,6 @@ func Test_ShouldCreateMetricsRegistry(t *testing.T) {
 	registry.Set("id3", 3, "level", "2")
 	summary := registry.Summary()
 	require.True(t, len(summary) > 0)
+	require.Equal(t, float64(1), summary["id1_total"])
+	require.Equal(t, float64(3), summary["id3"])
 }
@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// maxBuckets defines number of buckets kept in memory, after which least recently used buckets are removed.
+	maxBuckets = 10000
+	// bucketExpiration defines idle duration after which buckets are removed, which restart full afterward.
+	bucketExpiration = time.Hour
+)
+
+// Limit defines refill rate and capacity of token bucket.
+type Limit struct {
+	RequestsPerSecond float64
+	Burst             int32
+}
+
+// Limiter interface for token buckets.
+type Limiter interface {
+	// Allow takes a token from the bucket of key and returns false with the duration after
+	// which a token will be available if the bucket is empty.
+	Allow(
+		ctx context.Context,
+		key string,
+		limit Limit,
+	) (allowed bool, retryAfter time.Duration, err error)
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// MemoryLimiter keeps token buckets of recently used keys in memory of the server.
+type MemoryLimiter struct {
+	buckets *expirable.LRU[string, *bucket]
+	lock    sync.Mutex
+}
+
+// NewMemoryLimiter constructor
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		buckets: expirable.NewLRU[string, *bucket](maxBuckets, nil, bucketExpiration),
+	}
+}
+
+// Allow takes a token from the bucket of key.
+func (l *MemoryLimiter) Allow(
+	_ context.Context,
+	key string,
+	limit Limit,
+) (bool, time.Duration, error) {
+	now := time.Now()
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	b, _ := l.buckets.Get(key)
+	if b == nil {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+	}
+	b.tokens = refill(b.tokens, now.Sub(b.updated), limit)
+	b.updated = now
+	// adding the bucket again renews its expiration
+	_ = l.buckets.Add(key, b)
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0, nil
+	}
+	return false, retryAfter(b.tokens, limit), nil
+}
+
+func refill(tokens float64, elapsed time.Duration, limit Limit) float64 {
+	return math.Min(float64(limit.Burst), tokens+elapsed.Seconds()*limit.RequestsPerSecond)
+}
+
+func retryAfter(tokens float64, limit Limit) time.Duration {
+	return time.Duration(math.Ceil((1 - tokens) / limit.RequestsPerSecond * float64(time.Second)))
+}
@@ -0,0 +1,119 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/metrics"
+	redisstore "github.com/bhatti/PlexAuthZ/internal/repository/redis"
+	"github.com/bhatti/PlexAuthZ/internal/service"
+	log "github.com/sirupsen/logrus"
+	"math"
+)
+
+// RateLimiter limits requests of each organization, and optionally of each principal or client
+// within the organization, with token buckets. The configured limits are overridden by the
+// rate_limit of the organization.
+type RateLimiter struct {
+	config          domain.RateLimitConfig
+	limiter         Limiter
+	orgService      service.OrganizationService
+	metricsRegistry *metrics.Registry
+}
+
+// NewRateLimiter constructor
+func NewRateLimiter(
+	config *domain.Config,
+	orgService service.OrganizationService,
+	metricsRegistry *metrics.Registry,
+) (*RateLimiter, error) {
+	if err := config.RateLimit.Validate(); err != nil {
+		return nil, err
+	}
+	var limiter Limiter
+	if config.RateLimit.Store == domain.RedisRateLimitStore {
+		limiter = NewRedisLimiter(redisstore.NewPool(config))
+	} else {
+		limiter = NewMemoryLimiter()
+	}
+	return &RateLimiter{
+		config:          config.RateLimit,
+		limiter:         limiter,
+		orgService:      orgService,
+		metricsRegistry: metricsRegistry,
+	}, nil
+}
+
+// CreateRateLimiter returns rate limiter of the config or nil if rate limits are disabled. The limiter
+// is created once by the starter and shared by gRPC and REST servers so that requests of both APIs take
+// tokens from the same buckets.
+func CreateRateLimiter(
+	config *domain.Config,
+	orgService service.OrganizationService,
+	metricsRegistry *metrics.Registry,
+) (*RateLimiter, error) {
+	if !config.RateLimit.Enabled {
+		return nil, nil
+	}
+	return NewRateLimiter(config, orgService, metricsRegistry)
+}
+
+// Allow returns RateLimitError if the bucket of organization and principal or client is empty, where
+// principal is used for authorization requests and client is the authenticated subject or remote address.
+func (r *RateLimiter) Allow(
+	ctx context.Context,
+	organizationId string,
+	principalId string,
+	client string,
+) error {
+	key := r.key(organizationId, principalId, client)
+	allowed, retryAfter, err := r.limiter.Allow(ctx, key, r.limit(ctx, organizationId))
+	if err != nil {
+		// requests are not rejected when buckets are unavailable
+		r.metricsRegistry.Incr("rate_limit_errors", "org", organizationId)
+		log.WithFields(log.Fields{
+			"Component": "RateLimiter",
+			"Key":       key,
+			"Error":     err,
+		}).Warnf("failed to check rate limit")
+		return nil
+	}
+	if !allowed {
+		r.metricsRegistry.Incr("rate_limit_rejected", "org", organizationId)
+		return domain.NewRateLimitError(
+			fmt.Sprintf("rate limit exceeded for %s, retry after %s", key, retryAfter), retryAfter)
+	}
+	r.metricsRegistry.Incr("rate_limit_allowed", "org", organizationId)
+	return nil
+}
+
+func (r *RateLimiter) key(organizationId string, principalId string, client string) string {
+	key := "org:" + organizationId
+	switch r.config.Key {
+	case domain.PrincipalRateLimitKey:
+		if principalId != "" {
+			return key + ":principal:" + principalId
+		}
+		return key + ":client:" + client
+	case domain.ClientRateLimitKey:
+		return key + ":client:" + client
+	}
+	return key
+}
+
+func (r *RateLimiter) limit(ctx context.Context, organizationId string) Limit {
+	limit := Limit{RequestsPerSecond: r.config.RequestsPerSecond, Burst: r.config.Burst}
+	if organizationId == "" {
+		return limit
+	}
+	org, err := r.orgService.GetOrganization(ctx, organizationId)
+	if err != nil || org.RateLimit == nil || org.RateLimit.RequestsPerSecond <= 0 {
+		return limit
+	}
+	limit.RequestsPerSecond = org.RateLimit.RequestsPerSecond
+	limit.Burst = org.RateLimit.Burst
+	if limit.Burst <= 0 {
+		limit.Burst = int32(math.Ceil(limit.RequestsPerSecond))
+	}
+	return limit
+}
@@ -0,0 +1,146 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/metrics"
+	redisstore "github.com/bhatti/PlexAuthZ/internal/repository/redis"
+	"github.com/bhatti/PlexAuthZ/internal/service/db"
+	"github.com/stretchr/testify/require"
+	"github.com/twinj/uuid"
+	"testing"
+	"time"
+)
+
+func Test_ShouldLimitWithMemoryBuckets(t *testing.T) {
+	// GIVEN memory limiter with burst of 2
+	limiter := NewMemoryLimiter()
+	limit := Limit{RequestsPerSecond: 10, Burst: 2}
+	// WHEN taking tokens more than burst
+	// THEN it should reject after burst with retry duration
+	verifyLimiter(t, limiter, "memory-"+uuid.NewV4().String(), limit)
+}
+
+func Test_ShouldBoundMemoryBuckets(t *testing.T) {
+	// GIVEN memory limiter with an empty bucket
+	ctx := context.TODO()
+	limiter := NewMemoryLimiter()
+	limit := Limit{RequestsPerSecond: 0.001, Burst: 1}
+	allowed, _, err := limiter.Allow(ctx, "first", limit)
+	require.NoError(t, err)
+	require.True(t, allowed)
+	allowed, _, err = limiter.Allow(ctx, "first", limit)
+	require.NoError(t, err)
+	require.False(t, allowed)
+
+	// WHEN taking tokens of more keys than the buckets kept in memory
+	for i := 0; i < maxBuckets+10; i++ {
+		_, _, err = limiter.Allow(ctx, fmt.Sprintf("key-%d", i), limit)
+		require.NoError(t, err)
+	}
+
+	// THEN least recently used buckets should be removed
+	require.Equal(t, maxBuckets, limiter.buckets.Len())
+	_, ok := limiter.buckets.Peek("first")
+	require.False(t, ok)
+}
+
+func Test_ShouldLimitWithRedisBuckets(t *testing.T) {
+	// GIVEN redis limiter with burst of 2
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	limiter := NewRedisLimiter(redisstore.NewPool(cfg))
+	limit := Limit{RequestsPerSecond: 10, Burst: 2}
+	// WHEN taking tokens more than burst
+	// THEN it should reject after burst with retry duration
+	verifyLimiter(t, limiter, "redis-"+uuid.NewV4().String(), limit)
+}
+
+func Test_ShouldLimitOrganizationsAndPrincipals(t *testing.T) {
+	// GIVEN rate limiter keyed by principal with organization override
+	ctx := context.TODO()
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	cfg.RateLimit.Enabled = true
+	cfg.RateLimit.RequestsPerSecond = 0.001
+	cfg.RateLimit.Burst = 1
+	cfg.RateLimit.Key = domain.PrincipalRateLimitKey
+	authService, _, err := db.CreateDatabaseAuthService(cfg, metrics.New())
+	require.NoError(t, err)
+	org, err := domain.NewOrganizationBuilder().
+		WithName("rate-limit-"+uuid.NewV4().String()).
+		WithNamespaces("default").
+		WithRateLimit(0.001, 3).Build()
+	require.NoError(t, err)
+	org, err = authService.CreateOrganization(ctx, org)
+	require.NoError(t, err)
+	registry := metrics.New()
+	rateLimiter, err := NewRateLimiter(cfg, authService, registry)
+	require.NoError(t, err)
+
+	// WHEN invoking for organization without override
+	// THEN it should use configured burst
+	require.NoError(t, rateLimiter.Allow(ctx, "unknown-org", "p1", "c1"))
+	err = rateLimiter.Allow(ctx, "unknown-org", "p1", "c1")
+	var rateLimitErr *domain.RateLimitError
+	require.True(t, errors.As(err, &rateLimitErr))
+	require.True(t, rateLimitErr.RetryAfter > 0)
+
+	// WHEN invoking for organization with override
+	// THEN it should use burst of organization for each principal
+	for i := 0; i < 3; i++ {
+		require.NoError(t, rateLimiter.Allow(ctx, org.Id, "p1", "c1"))
+	}
+	require.Error(t, rateLimiter.Allow(ctx, org.Id, "p1", "c1"))
+	require.NoError(t, rateLimiter.Allow(ctx, org.Id, "p2", "c1"))
+	// AND client should be used when principal is not known
+	require.NoError(t, rateLimiter.Allow(ctx, org.Id, "", "c1"))
+
+	// THEN metrics should count allowed and rejected requests
+	summary := registry.Summary()
+	require.NotZero(t, summary["rate_limit_allowed_total"])
+	require.NotZero(t, summary["rate_limit_rejected_total"])
+}
+
+func verifyLimiter(t *testing.T, limiter Limiter, key string, limit Limit) {
+	ctx := context.TODO()
+	for i := 0; i < int(limit.Burst); i++ {
+		allowed, _, err := limiter.Allow(ctx, key, limit)
+		require.NoError(t, err)
+		require.True(t, allowed)
+	}
+	allowed, retryAfter, err := limiter.Allow(ctx, key, limit)
+	require.NoError(t, err)
+	require.False(t, allowed)
+	require.True(t, retryAfter > 0 && retryAfter <= 100*time.Millisecond)
+
+	// WHEN waiting for refill
+	time.Sleep(retryAfter + 10*time.Millisecond)
+	// THEN it should allow again
+	allowed, _, err = limiter.Allow(ctx, key, limit)
+	require.NoError(t, err)
+	require.True(t, allowed)
+}
+
+func Test_ShouldCreateRateLimiterOnlyWhenEnabled(t *testing.T) {
+	// GIVEN config with rate limits disabled
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	cfg.RateLimit.Enabled = false
+	authService, _, err := db.CreateDatabaseAuthService(cfg, metrics.New())
+	require.NoError(t, err)
+	// WHEN creating rate limiter
+	rateLimiter, err := CreateRateLimiter(cfg, authService, metrics.New())
+	// THEN it should not be created
+	require.NoError(t, err)
+	require.Nil(t, rateLimiter)
+
+	// WHEN rate limits are enabled
+	cfg.RateLimit.Enabled = true
+	rateLimiter, err = CreateRateLimiter(cfg, authService, metrics.New())
+	// THEN it should be created
+	require.NoError(t, err)
+	require.NotNil(t, rateLimiter)
+}
@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/gomodule/redigo/redis"
+	"math"
+	"time"
+)
+
+// tokenBucketScript refills and takes a token from the bucket atomically and returns 1 if allowed
+// or 0 with milliseconds until a token is available.
+var tokenBucketScript = redis.NewScript(1, `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(bucket[1])
+local updated = tonumber(bucket[2])
+if tokens == nil or updated == nil then
+  tokens = burst
+  updated = now
+end
+tokens = math.min(burst, tokens + math.max(0, now - updated) / 1000 * rate)
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  retry = math.ceil((1 - tokens) / rate * 1000)
+end
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'updated', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return {allowed, retry}
+`)
+
+// RedisLimiter keeps token buckets in Redis so that limits are shared by all servers.
+type RedisLimiter struct {
+	pool   *redis.Pool
+	prefix string
+}
+
+// NewRedisLimiter constructor
+func NewRedisLimiter(pool *redis.Pool) *RedisLimiter {
+	return &RedisLimiter{
+		pool:   pool,
+		prefix: "rate_limit:",
+	}
+}
+
+// Allow takes a token from the bucket of key.
+func (l *RedisLimiter) Allow(
+	_ context.Context,
+	key string,
+	limit Limit,
+) (bool, time.Duration, error) {
+	conn := l.pool.Get()
+	defer func() {
+		_ = conn.Close()
+	}()
+	res, err := redis.Int64s(tokenBucketScript.Do(
+		conn,
+		l.prefix+key,
+		limit.RequestsPerSecond,
+		limit.Burst,
+		time.Now().UnixMilli()))
+	if err != nil {
+		return false, 0, err
+	}
+	if len(res) == 2 && res[0] == 1 {
+		return true, 0, nil
+	}
+	retry := int64(0)
+	if len(res) == 2 {
+		retry = res[1]
+	}
+	return false, time.Duration(math.Max(float64(retry), 1)) * time.Millisecond, nil
+}
@@ -168,6 +168,47 @@ func (r *BaseRepository[T]) Update(
 		expiration)
 }
 
+// Allocate saves the item unless it is new and number of items has reached capacity.
+func (r *BaseRepository[T]) Allocate(
+	_ context.Context,
+	organizationID string,
+	namespace string,
+	id string,
+	version int64,
+	capacity int64,
+	obj *T,
+	expiration time.Duration,
+) (err error) {
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return domain.NewMarshalError(
+			fmt.Sprintf("failed to marshal object [%s %s %s %s] due to %s",
+				r.baseTableName, organizationID, namespace, id, err))
+	}
+	log.WithFields(log.Fields{
+		"Component":    "DDBBaseRepository",
+		"Organization": organizationID,
+		"Namespace":    namespace,
+		"Id":           id,
+		"Table":        r.baseTableName,
+		"Capacity":     capacity,
+	}).
+		Debugf("allocating object")
+	if expiration.Seconds() <= 0 {
+		expiration = r.expiration
+	}
+	return r.store.Allocate(
+		r.baseTableName,
+		r.baseTableSuffix,
+		organizationID,
+		namespace,
+		id,
+		version,
+		capacity,
+		b,
+		expiration)
+}
+
 // Query - queries data
 func (r *BaseRepository[T]) Query(
 	_ context.Context,
@@ -202,6 +243,40 @@ func (r *BaseRepository[T]) Query(
 	return res, nextOffsetToken, nil
 }
 
+// Expiring - finds objects that expire at or before the given time with the expiry index of the store
+func (r *BaseRepository[T]) Expiring(
+	_ context.Context,
+	organizationID string,
+	namespace string,
+	before time.Time,
+	lastOffsetToken string,
+	limit int64) (res []*T, nextOffsetToken string, err error) {
+	matched, nextOffsetToken, err := r.store.Expiring(
+		r.baseTableName,
+		r.baseTableSuffix,
+		organizationID,
+		namespace,
+		before,
+		lastOffsetToken,
+		limit)
+	if err != nil {
+		return res, "", domain.NewDatabaseError(
+			fmt.Sprintf("failed to find expiring objects [%s %s %s] due to %s",
+				r.baseTableName, organizationID, namespace, err))
+	}
+	for _, b := range matched {
+		obj := r.builder()
+		err = json.Unmarshal(b, obj)
+		if err != nil {
+			return res, "", domain.NewMarshalError(
+				fmt.Sprintf("failed to unmarshal object [%s %s %s] after finding expiring objects due to %s",
+					r.baseTableName, organizationID, namespace, err))
+		}
+		res = append(res, obj)
+	}
+	return res, nextOffsetToken, nil
+}
+
 func (r *BaseRepository[T]) Delete(
 	_ context.Context,
 	organizationID string,
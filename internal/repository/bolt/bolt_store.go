@@ -0,0 +1,529 @@
+package bolt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/utils"
+	"github.com/sirupsen/logrus"
+	"go.etcd.io/bbolt"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// headerSize of version and expiry stored before the value of each record.
+const headerSize = 16
+
+// Store persists records in an embedded bbolt file with a bucket for each table, which contains a bucket
+// for each tenant and namespace, where updates with version are applied only if stored version matches.
+type Store struct {
+	config   *domain.BoltConfig
+	db       *bbolt.DB
+	database *database
+	once     sync.Once
+}
+
+// database is a bbolt file opened once by stores of the process because bbolt locks the file exclusively,
+// and it's closed when it's released by each store.
+type database struct {
+	path string
+	db   *bbolt.DB
+	refs int
+	done chan bool
+	wg   sync.WaitGroup
+}
+
+var shared = struct {
+	databases map[string]*database
+	lock      sync.Mutex
+}{databases: make(map[string]*database)}
+
+// NewBoltStore constructor for bbolt store, which deletes expired records in background. Stores of the
+// same path share the database.
+func NewBoltStore(
+	config *domain.Config,
+) (*Store, error) {
+	if err := config.Bolt.Validate(); err != nil {
+		return nil, err
+	}
+	path, err := filepath.Abs(config.Bolt.Path)
+	if err != nil {
+		return nil, err
+	}
+	shared.lock.Lock()
+	defer shared.lock.Unlock()
+	if d := shared.databases[path]; d != nil {
+		d.refs++
+		return &Store{config: &config.Bolt, db: d.db, database: d}, nil
+	}
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	logrus.WithFields(
+		logrus.Fields{
+			"Component": "BoltStore",
+			"Path":      path,
+		}).Debugf("opened bolt database")
+	d := &database{path: path, db: db, refs: 1, done: make(chan bool)}
+	shared.databases[path] = d
+	store := &Store{config: &config.Bolt, db: db, database: d}
+	d.wg.Add(1)
+	go store.cleanup()
+	return store, nil
+}
+
+// Close releases the database, which stops deleting expired records and closes the database when
+// it's released by each store of the path.
+func (r *Store) Close() (err error) {
+	r.once.Do(func() {
+		shared.lock.Lock()
+		defer shared.lock.Unlock()
+		if r.database.refs--; r.database.refs > 0 {
+			return
+		}
+		delete(shared.databases, r.database.path)
+		close(r.database.done)
+		r.database.wg.Wait()
+		err = r.db.Close()
+	})
+	return
+}
+
+// CreateTable creates bucket for table if needed.
+func (r *Store) CreateTable(
+	baseTableName string,
+	_ string, // suffix is added to bucket of tenant
+) (err error) {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(baseTableName))
+		return err
+	})
+}
+
+// Size returns number of live records for tenant in table.
+func (r *Store) Size(
+	baseTableName string,
+	baseTableSuffix string,
+	tenant string,
+	namespace string,
+) (size int64, err error) {
+	now := time.Now().Unix()
+	err = r.db.View(func(tx *bbolt.Tx) error {
+		bucket := toBucket(tx, baseTableName, baseTableSuffix, tenant, namespace)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(_, v []byte) error {
+			if _, _, live := decode(v, now); live {
+				size++
+			}
+			return nil
+		})
+	})
+	return
+}
+
+// Get finds records by ids.
+func (r *Store) Get(
+	baseTableName string,
+	baseTableSuffix string,
+	tenant string,
+	namespace string,
+	ids ...string,
+) (res map[string][]byte, err error) {
+	res = make(map[string][]byte)
+	now := time.Now().Unix()
+	err = r.db.View(func(tx *bbolt.Tx) error {
+		bucket := toBucket(tx, baseTableName, baseTableSuffix, tenant, namespace)
+		for _, id := range ids {
+			var value []byte
+			live := false
+			if bucket != nil {
+				value, _, live = decode(bucket.Get([]byte(id)), now)
+			}
+			if !live {
+				return domain.NewNotFoundError(
+					fmt.Sprintf("failed to get object for id %s in %s", id, baseTableName))
+			}
+			res[id] = value
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Query searches records by predicates in the order of ids, where the id of the last record is
+// returned as next key when the limit is reached.
+func (r *Store) Query(
+	baseTableName string,
+	baseTableSuffix string,
+	tenant string,
+	namespace string,
+	predicate map[string]string,
+	lastEvaluatedKeyStr string,
+	limit int64,
+) (res map[string][]byte, nextKeyStr string, err error) {
+	res = make(map[string][]byte)
+	now := time.Now().Unix()
+	err = r.db.View(func(tx *bbolt.Tx) error {
+		bucket := toBucket(tx, baseTableName, baseTableSuffix, tenant, namespace)
+		if bucket == nil {
+			return nil
+		}
+		cursor := bucket.Cursor()
+		k, v := cursor.First()
+		if lastEvaluatedKeyStr != "" {
+			k, v = cursor.Seek([]byte(lastEvaluatedKeyStr))
+			if k != nil && bytes.Equal(k, []byte(lastEvaluatedKeyStr)) {
+				k, v = cursor.Next()
+			}
+		}
+		for ; k != nil; k, v = cursor.Next() {
+			value, _, live := decode(v, now)
+			if !live || !utils.MatchPredicate(value, predicate) {
+				continue
+			}
+			res[string(k)] = value
+			if limit > 0 && int64(len(res)) >= limit {
+				nextKeyStr = string(k)
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return
+}
+
+// Expiring finds live records that expire at or before the given time in the order of ids, where the bucket
+// of tenant is scanned because expiry is only stored with each record. The id of the last record is returned
+// as next key when the limit is reached.
+func (r *Store) Expiring(
+	baseTableName string,
+	baseTableSuffix string,
+	tenant string,
+	namespace string,
+	before time.Time,
+	lastEvaluatedKeyStr string,
+	limit int64,
+) (res map[string][]byte, nextKeyStr string, err error) {
+	res = make(map[string][]byte)
+	now := time.Now().Unix()
+	err = r.db.View(func(tx *bbolt.Tx) error {
+		bucket := toBucket(tx, baseTableName, baseTableSuffix, tenant, namespace)
+		if bucket == nil {
+			return nil
+		}
+		cursor := bucket.Cursor()
+		k, v := cursor.First()
+		if lastEvaluatedKeyStr != "" {
+			k, v = cursor.Seek([]byte(lastEvaluatedKeyStr))
+			if k != nil && bytes.Equal(k, []byte(lastEvaluatedKeyStr)) {
+				k, v = cursor.Next()
+			}
+		}
+		for ; k != nil; k, v = cursor.Next() {
+			value, header, live := decode(v, now)
+			if !live || header[1] == 0 || header[1] > before.Unix() {
+				continue
+			}
+			res[string(k)] = value
+			if limit > 0 && int64(len(res)) >= limit {
+				nextKeyStr = string(k)
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return
+}
+
+// Create adds a new record, which fails if a live record with same id already exists.
+func (r *Store) Create(
+	baseTableName string,
+	baseTableSuffix string,
+	tenant string,
+	namespace string,
+	id string,
+	value []byte,
+	expiration time.Duration) (err error) {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := createBucket(tx, baseTableName, baseTableSuffix, tenant, namespace)
+		if err != nil {
+			return err
+		}
+		if _, _, live := decode(bucket.Get([]byte(id)), time.Now().Unix()); live {
+			return domain.NewDuplicateError(
+				fmt.Sprintf("object with id %s already exists in %s", id, baseTableName))
+		}
+		return bucket.Put([]byte(id), encode(1, toExpiresAt(expiration), value))
+	})
+}
+
+// Update changes existing record if its version matches, or writes the record regardless of the
+// version when version is negative.
+func (r *Store) Update(
+	baseTableName string,
+	baseTableSuffix string,
+	tenant string,
+	namespace string,
+	id string,
+	version int64,
+	value []byte,
+	expiration time.Duration) (err error) {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := createBucket(tx, baseTableName, baseTableSuffix, tenant, namespace)
+		if err != nil {
+			return err
+		}
+		existing := bucket.Get([]byte(id))
+		_, header, live := decode(existing, time.Now().Unix())
+		if !live && version >= 0 {
+			return domain.NewNotFoundError(
+				fmt.Sprintf("failed to get object for id %s in %s", id, baseTableName))
+		}
+		storedVersion, expiresAt := int64(0), int64(0)
+		if live {
+			storedVersion, expiresAt = header[0], header[1]
+		}
+		if version >= 0 && storedVersion != version {
+			return domain.NewConflictError(
+				fmt.Sprintf("version %d of object %s in %s is stale", version, id, baseTableName))
+		}
+		if expiration.Seconds() > 0 {
+			expiresAt = toExpiresAt(expiration)
+		}
+		return bucket.Put([]byte(id), encode(storedVersion+1, expiresAt, value))
+	})
+}
+
+// Allocate changes live record or adds record if number of live records is less than capacity,
+// which is atomic because bbolt allows a single writable transaction.
+func (r *Store) Allocate(
+	baseTableName string,
+	baseTableSuffix string,
+	tenant string,
+	namespace string,
+	id string,
+	version int64,
+	capacity int64,
+	value []byte,
+	expiration time.Duration) (err error) {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := createBucket(tx, baseTableName, baseTableSuffix, tenant, namespace)
+		if err != nil {
+			return err
+		}
+		now := time.Now().Unix()
+		_, header, live := decode(bucket.Get([]byte(id)), now)
+		storedVersion := int64(0)
+		if live {
+			storedVersion = header[0]
+		}
+		if version >= 0 && storedVersion != version {
+			return domain.NewConflictError(
+				fmt.Sprintf("version %d of object %s in %s is stale", version, id, baseTableName))
+		}
+		if live {
+			expiresAt := header[1]
+			if expiration.Seconds() > 0 {
+				expiresAt = toExpiresAt(expiration)
+			}
+			return bucket.Put([]byte(id), encode(header[0]+1, expiresAt, value))
+		}
+		size := int64(0)
+		if err = bucket.ForEach(func(_, v []byte) error {
+			if _, _, live := decode(v, now); live {
+				size++
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		if size >= capacity {
+			return domain.NewValidationError(
+				fmt.Sprintf("usage %d exceeds capacity %d", size, capacity))
+		}
+		return bucket.Put([]byte(id), encode(1, toExpiresAt(expiration), value))
+	})
+}
+
+// Delete removes existing record.
+func (r *Store) Delete(
+	baseTableName string,
+	baseTableSuffix string,
+	tenant string,
+	namespace string,
+	id string,
+) (err error) {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		bucket := toBucket(tx, baseTableName, baseTableSuffix, tenant, namespace)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.Delete([]byte(id))
+	})
+}
+
+// ClearTable removes bucket of tenant in table.
+func (r *Store) ClearTable(
+	baseTableName string,
+	baseTableSuffix string,
+	tenant string,
+	namespace string,
+) (err error) {
+	logrus.WithFields(logrus.Fields{
+		"Component": "BoltStore",
+		"Table":     baseTableName,
+		"Tenant":    toBucketName(baseTableName, baseTableSuffix, tenant, namespace),
+	}).
+		Debugf("deleting all objects in table")
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		table := tx.Bucket([]byte(baseTableName))
+		if table == nil {
+			return nil
+		}
+		err := table.DeleteBucket([]byte(toBucketName(baseTableName, baseTableSuffix, tenant, namespace)))
+		if err == bbolt.ErrBucketNotFound {
+			return nil
+		}
+		return err
+	})
+}
+
+// DeleteExpired removes expired records from all tables.
+func (r *Store) DeleteExpired() (deleted int64, err error) {
+	now := time.Now().Unix()
+	err = r.db.Update(func(tx *bbolt.Tx) error {
+		return tx.ForEach(func(_ []byte, table *bbolt.Bucket) error {
+			return table.ForEachBucket(func(name []byte) error {
+				bucket := table.Bucket(name)
+				var expired [][]byte
+				if err := bucket.ForEach(func(k, v []byte) error {
+					if _, _, live := decode(v, now); !live {
+						expired = append(expired, k)
+					}
+					return nil
+				}); err != nil {
+					return err
+				}
+				// keys are deleted after iteration as bbolt doesn't allow changing bucket while iterating
+				for _, k := range expired {
+					if err := bucket.Delete(k); err != nil {
+						return err
+					}
+				}
+				deleted += int64(len(expired))
+				return nil
+			})
+		})
+	})
+	return
+}
+
+func (r *Store) cleanup() {
+	defer r.database.wg.Done()
+	ticker := time.NewTicker(r.config.CleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.database.done:
+			return
+		case <-ticker.C:
+			if deleted, err := r.DeleteExpired(); err != nil {
+				logrus.WithFields(logrus.Fields{
+					"Component": "BoltStore",
+					"Error":     err,
+				}).Warnf("failed to delete expired objects")
+			} else if deleted > 0 {
+				logrus.WithFields(logrus.Fields{
+					"Component": "BoltStore",
+					"Deleted":   deleted,
+				}).Debugf("deleted expired objects")
+			}
+		}
+	}
+}
+
+func toBucket(
+	tx *bbolt.Tx,
+	baseTableName string,
+	baseTableSuffix string,
+	organizationID string,
+	namespace string,
+) *bbolt.Bucket {
+	table := tx.Bucket([]byte(baseTableName))
+	if table == nil {
+		return nil
+	}
+	return table.Bucket([]byte(toBucketName(baseTableName, baseTableSuffix, organizationID, namespace)))
+}
+
+func createBucket(
+	tx *bbolt.Tx,
+	baseTableName string,
+	baseTableSuffix string,
+	organizationID string,
+	namespace string,
+) (*bbolt.Bucket, error) {
+	table, err := tx.CreateBucketIfNotExists([]byte(baseTableName))
+	if err != nil {
+		return nil, err
+	}
+	return table.CreateBucketIfNotExists(
+		[]byte(toBucketName(baseTableName, baseTableSuffix, organizationID, namespace)))
+}
+
+func toBucketName(
+	baseTableName string,
+	baseTableSuffix string,
+	organizationID string,
+	namespace string,
+) string {
+	if baseTableName == "Organization" {
+		// organizations are shared across tenants as in redis store
+		return baseTableName
+	}
+	return fmt.Sprintf("%s__%s__%s", organizationID, namespace, baseTableSuffix)
+}
+
+// encode prefixes value with version and expiry.
+func encode(version int64, expiresAt int64, value []byte) []byte {
+	b := make([]byte, headerSize+len(value))
+	binary.BigEndian.PutUint64(b[0:8], uint64(version))
+	binary.BigEndian.PutUint64(b[8:16], uint64(expiresAt))
+	copy(b[headerSize:], value)
+	return b
+}
+
+// decode returns copy of value with version and expiry, which is live if record exists and is not expired.
+func decode(b []byte, now int64) (value []byte, header [2]int64, live bool) {
+	if len(b) < headerSize {
+		return nil, header, false
+	}
+	header[0] = int64(binary.BigEndian.Uint64(b[0:8]))
+	header[1] = int64(binary.BigEndian.Uint64(b[8:16]))
+	if header[1] > 0 && header[1] <= now {
+		return nil, header, false
+	}
+	// bbolt values are only valid during transaction
+	return append([]byte{}, b[headerSize:]...), header, true
+}
+
+func toExpiresAt(expiration time.Duration) int64 {
+	if expiration.Seconds() <= 0 {
+		return 0
+	}
+	return time.Now().Add(expiration).Unix()
+}
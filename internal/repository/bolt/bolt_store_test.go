@@ -0,0 +1,88 @@
+package bolt
+
+import (
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/repository"
+	"github.com/bhatti/PlexAuthZ/internal/repository/storetest"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func Test_ShouldPassDataStoreSuite(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) repository.DataStore {
+		return newTestStore(t)
+	})
+}
+
+func Test_ShouldKeepDataAfterReopening(t *testing.T) {
+	// GIVEN config and bolt-store with a record
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	cfg.Bolt.Path = filepath.Join(t.TempDir(), "plexauthz.bolt")
+	store, err := NewBoltStore(cfg)
+	require.NoError(t, err)
+	require.NoError(t, store.Create("table1", "", "123", "test-reopen", "id1", []byte("data1"), 0))
+	require.NoError(t, store.Close())
+
+	// WHEN reopening the store
+	store, err = NewBoltStore(cfg)
+	require.NoError(t, err)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	// THEN record and its version should be kept
+	saved, err := store.Get("table1", "", "123", "test-reopen", "id1")
+	require.NoError(t, err)
+	require.Equal(t, "data1", string(saved["id1"]))
+	require.NoError(t, store.Update("table1", "", "123", "test-reopen", "id1", 1, []byte("data2"), 0))
+}
+
+func Test_ShouldShareDatabaseOfSamePath(t *testing.T) {
+	// GIVEN two stores opened on the same path
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	cfg.Bolt.Path = filepath.Join(t.TempDir(), "plexauthz.bolt")
+	first, err := NewBoltStore(cfg)
+	require.NoError(t, err)
+	second, err := NewBoltStore(cfg)
+	require.NoError(t, err)
+
+	// WHEN creating record with first store
+	require.NoError(t, first.Create("table1", "", "123", "test-shared", "id1", []byte("data1"), 0))
+	// THEN it should be visible to second store
+	saved, err := second.Get("table1", "", "123", "test-shared", "id1")
+	require.NoError(t, err)
+	require.Equal(t, "data1", string(saved["id1"]))
+
+	// WHEN closing first store
+	require.NoError(t, first.Close())
+	require.NoError(t, first.Close())
+	// THEN second store should keep using the database
+	require.NoError(t, second.Update("table1", "", "123", "test-shared", "id1", 1, []byte("data2"), 0))
+
+	// WHEN closing each store
+	require.NoError(t, second.Close())
+	// THEN the database should be reopened by a new store
+	third, err := NewBoltStore(cfg)
+	require.NoError(t, err)
+	defer func() {
+		_ = third.Close()
+	}()
+	saved, err = third.Get("table1", "", "123", "test-shared", "id1")
+	require.NoError(t, err)
+	require.Equal(t, "data2", string(saved["id1"]))
+}
+
+func newTestStore(t *testing.T) *Store {
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	cfg.Bolt.Path = filepath.Join(t.TempDir(), "plexauthz.bolt")
+	store, err := NewBoltStore(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = store.Close()
+	})
+	return store
+}
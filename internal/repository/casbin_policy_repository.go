@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"time"
+)
+
+// NewCasbinPolicyRepository creates repository for persisting casbin policies of admin APIs
+func NewCasbinPolicyRepository(
+	store DataStore,
+) (Repository[domain.CasbinPolicy], error) {
+	return NewBaseRepository[domain.CasbinPolicy](store,
+		"CasbinPolicy",
+		"",
+		time.Duration(0),
+		func() *domain.CasbinPolicy {
+			return &domain.CasbinPolicy{}
+		})
+}
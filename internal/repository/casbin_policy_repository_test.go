@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/repository/redis"
+	"github.com/stretchr/testify/require"
+	"github.com/twinj/uuid"
+	"testing"
+	"time"
+)
+
+func Test_ShouldSaveAndQueryCasbinPolicy(t *testing.T) {
+	// GIVEN config, redis-service and policy repository
+	ctx := context.TODO()
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	store, err := redis.NewRedisStore(cfg)
+	require.NoError(t, err)
+	repository, err := NewCasbinPolicyRepository(store)
+	require.NoError(t, err)
+	tenant := uuid.NewV4().String()
+	policy := domain.NewCasbinPolicy("", []string{"root", "*", "query"})
+	require.NoError(t, policy.Validate())
+	require.Equal(t, "p, root, *, query", policy.String())
+
+	// WHEN saving policy
+	err = repository.Create(ctx, tenant, "", policy.Id, policy, time.Duration(0))
+	// THEN it should be found by id and type
+	require.NoError(t, err)
+	saved, err := repository.GetByID(ctx, tenant, "", policy.Id)
+	require.NoError(t, err)
+	require.Equal(t, policy.Rule, saved.Rule)
+	res, _, err := repository.Query(ctx, tenant, "", map[string]string{"ptype": "p"}, "", 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(res))
+
+	// WHEN deleting policy
+	err = repository.Delete(ctx, tenant, "", policy.Id)
+	// THEN it should not be found
+	require.NoError(t, err)
+	_, err = repository.GetByID(ctx, tenant, "", policy.Id)
+	require.Error(t, err)
+}
@@ -38,6 +38,18 @@ type DataStore interface {
 		limit int64,
 	) (res map[string][]byte, nextKeyStr string, err error)
 
+	// Expiring finds live records that expire at or before the given time, where the next key resumes
+	// the search and is empty when no more records are left.
+	Expiring(
+		baseTableName string,
+		baseTableSuffix string,
+		tenant string,
+		namespace string,
+		before time.Time,
+		lastEvaluatedKeyStr string,
+		limit int64,
+	) (res map[string][]byte, nextKeyStr string, err error)
+
 	// Create adds a new record.
 	Create(
 		baseTableName string,
@@ -59,6 +71,21 @@ type DataStore interface {
 		value []byte,
 		expiration time.Duration) (err error)
 
+	// Allocate writes a record if it already exists or if number of live records for tenant is
+	// less than capacity, where capacity is checked and the record is written atomically. The version
+	// is compared with version of the live record, where 0 requires a new record and negative version
+	// skips the check.
+	Allocate(
+		baseTableName string,
+		baseTableSuffix string,
+		tenant string,
+		namespace string,
+		id string,
+		version int64,
+		capacity int64,
+		value []byte,
+		expiration time.Duration) (err error)
+
 	// Delete removes existing record.
 	Delete(
 		baseTableName string,
@@ -15,6 +15,10 @@ import (
 	"time"
 )
 
+// allocationAttempts is number of times an allocation is retried when another allocation of the
+// same tenant is committed concurrently.
+const allocationAttempts = 10
+
 // Store cache service
 type Store struct {
 	config *domain.DynamoDBConfig
@@ -235,6 +239,72 @@ func (r *Store) Query(
 	return
 }
 
+// Expiring finds items that expire at or before the given time, where items of tenant are filtered by
+// expiry in the table because it has no index of expiry.
+func (r *Store) Expiring(
+	baseTableName string,
+	baseTableSuffix string,
+	baseTenant string,
+	namespace string,
+	before time.Time,
+	lastEvaluatedKeyStr string,
+	limit int64,
+) (res map[string][]byte, nextKeyStr string, err error) {
+	if limit == 0 {
+		limit = 500
+	}
+	res = make(map[string][]byte)
+	var lastEvaluatedKey map[string]*dynamodb.AttributeValue
+	if lastEvaluatedKeyStr != "" {
+		decoded, err := base64.StdEncoding.DecodeString(lastEvaluatedKeyStr)
+		if err == nil {
+			err = json.Unmarshal(decoded, &lastEvaluatedKey)
+		}
+		if err != nil {
+			return nil, "", domain.NewValidationError(
+				fmt.Sprintf("invalid offset %s for %s", lastEvaluatedKeyStr, baseTableName))
+		}
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(baseTableName),
+		KeyConditionExpression: aws.String("#tenant = :tenant"),
+		FilterExpression:       aws.String("expire_at > :now AND expire_at <= :before"),
+		ExpressionAttributeNames: map[string]*string{
+			"#tenant": aws.String(r.config.TenantPartitionName),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":tenant": {S: aws.String(toTenant(baseTableSuffix, baseTenant, namespace))},
+			":now":    {N: aws.String(fmt.Sprintf("%d", time.Now().Unix()))},
+			":before": {N: aws.String(fmt.Sprintf("%d", before.Unix()))},
+		},
+		ExclusiveStartKey: lastEvaluatedKey,
+		Limit:             aws.Int64(limit),
+	}
+
+	result, err := r.ddbSvc.Query(input)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for _, item := range result.Items {
+		id, val := r.getItem(item)
+		if val != nil {
+			res[id] = val
+		}
+	}
+
+	if result.LastEvaluatedKey != nil {
+		jsonKey, err := json.Marshal(result.LastEvaluatedKey)
+		if err != nil {
+			return nil, "", err
+		}
+		nextKeyStr = base64.StdEncoding.EncodeToString(jsonKey)
+	}
+
+	return
+}
+
 // Create adds a new item.
 func (r *Store) Create(
 	baseTableName string,
@@ -267,7 +337,10 @@ func (r *Store) Create(
 	if expiration.Seconds() > 0 {
 		input.Item["expire_at"] = &dynamodb.AttributeValue{N: aws.String(fmt.Sprintf("%d", expireTime))}
 	}
-	_, err = r.ddbSvc.PutItem(input) // check for ConditionalCheckFailedException
+	if _, err = r.ddbSvc.PutItem(input); isConditionalCheckFailed(err) {
+		return domain.NewDuplicateError(
+			fmt.Sprintf("object with id %s already exists in %s", id, baseTableName))
+	}
 	return
 }
 
@@ -334,11 +407,136 @@ func (r *Store) Update(
 			expressionAttributeValues["expire_at"] = &dynamodb.AttributeValue{N: aws.String(fmt.Sprintf("%d", expireTime))}
 		}
 
-		_, err = r.ddbSvc.UpdateItem(input)
+		if _, err = r.ddbSvc.UpdateItem(input); isConditionalCheckFailed(err) {
+			return domain.NewConflictError(
+				fmt.Sprintf("version %d of object %s in %s is stale", version, id, baseTableName))
+		}
 	}
 	return
 }
 
+// Allocate changes live item or adds item if number of live items is less than capacity. New items are
+// written in a transaction with a guard item of the tenant, whose version is incremented by each new
+// allocation so that allocations that counted items concurrently fail and are retried.
+func (r *Store) Allocate(
+	baseTableName string,
+	baseTableSuffix string,
+	baseTenant string,
+	namespace string,
+	id string,
+	version int64,
+	capacity int64,
+	value []byte,
+	expiration time.Duration) (err error) {
+	tenant := toTenant(baseTableSuffix, baseTenant, namespace)
+	idName := map[string]*string{"#id": aws.String(r.config.IDName)}
+	stale := domain.NewConflictError(
+		fmt.Sprintf("version %d of object %s in %s is stale", version, id, baseTableName))
+	key := map[string]*dynamodb.AttributeValue{
+		r.config.TenantPartitionName: {S: aws.String(tenant)},
+		r.config.IDName:              {S: aws.String(id)},
+	}
+	guardKey := map[string]*dynamodb.AttributeValue{
+		r.config.TenantPartitionName: {S: aws.String(tenant + ":allocations")},
+		r.config.IDName:              {S: aws.String("guard")},
+	}
+	expireTime := aws.String(fmt.Sprintf("%d", time.Now().Add(expiration).Unix()))
+	for attempt := 0; attempt < allocationAttempts; attempt++ {
+		now := aws.String(fmt.Sprintf("%d", time.Now().Unix()))
+		guard, err := r.ddbSvc.GetItem(&dynamodb.GetItemInput{
+			TableName:      aws.String(baseTableName),
+			Key:            guardKey,
+			ConsistentRead: aws.Bool(true),
+		})
+		if err != nil {
+			return err
+		}
+		if version != 0 {
+			// existing allocation is renewed without checking capacity if its version matches
+			updateExpression := "SET val = :val, ver = ver + :one"
+			condition := "attribute_exists(#id) AND (attribute_not_exists(expire_at) OR expire_at > :now)"
+			expressionAttributeValues := map[string]*dynamodb.AttributeValue{
+				":val": {S: aws.String(string(value))},
+				":one": {N: aws.String("1")},
+				":now": {N: now},
+			}
+			if version > 0 {
+				condition += " AND ver = :expectedVersion"
+				expressionAttributeValues[":expectedVersion"] = &dynamodb.AttributeValue{
+					N: aws.String(fmt.Sprintf("%d", version))}
+			}
+			if expiration.Seconds() > 0 {
+				updateExpression += ", expire_at = :expire_at"
+				expressionAttributeValues[":expire_at"] = &dynamodb.AttributeValue{N: expireTime}
+			}
+			_, err = r.ddbSvc.UpdateItem(&dynamodb.UpdateItemInput{
+				TableName:                 aws.String(baseTableName),
+				Key:                       key,
+				ConditionExpression:       aws.String(condition),
+				UpdateExpression:          aws.String(updateExpression),
+				ExpressionAttributeNames:  idName,
+				ExpressionAttributeValues: expressionAttributeValues,
+			})
+			if !isConditionalCheckFailed(err) {
+				return err
+			}
+			if version > 0 {
+				return stale
+			}
+		}
+		size, err := r.countLive(baseTableName, tenant, now)
+		if err != nil {
+			return err
+		}
+		if size >= capacity {
+			return domain.NewValidationError(
+				fmt.Sprintf("usage %d exceeds capacity %d", size, capacity))
+		}
+		guardCondition := "attribute_not_exists(ver)"
+		guardValues := map[string]*dynamodb.AttributeValue{":one": {N: aws.String("1")}}
+		if ver := guard.Item["ver"]; ver != nil && ver.N != nil {
+			guardCondition = "ver = :ver"
+			guardValues[":ver"] = ver
+		}
+		item := map[string]*dynamodb.AttributeValue{
+			r.config.TenantPartitionName: {S: aws.String(tenant)},
+			r.config.IDName:              {S: aws.String(id)},
+			"val":                        {S: aws.String(string(value))},
+			"ver":                        {N: aws.String("1")},
+		}
+		if expiration.Seconds() > 0 {
+			item["expire_at"] = &dynamodb.AttributeValue{N: expireTime}
+		}
+		_, err = r.ddbSvc.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+			TransactItems: []*dynamodb.TransactWriteItem{
+				{
+					Update: &dynamodb.Update{
+						TableName:                 aws.String(baseTableName),
+						Key:                       guardKey,
+						ConditionExpression:       aws.String(guardCondition),
+						UpdateExpression:          aws.String("ADD ver :one"),
+						ExpressionAttributeValues: guardValues,
+					},
+				},
+				{
+					Put: &dynamodb.Put{
+						TableName:                 aws.String(baseTableName),
+						Item:                      item,
+						ConditionExpression:       aws.String("attribute_not_exists(#id) OR expire_at <= :now"),
+						ExpressionAttributeNames:  idName,
+						ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":now": {N: now}},
+					},
+				},
+			},
+		})
+		if !isTransactionCanceled(err) {
+			return err
+		}
+	}
+	return domain.NewConflictError(
+		fmt.Sprintf("failed to allocate object %s in %s due to concurrent allocations", id, baseTableName))
+}
+
 // Delete removes existing item in DDB table.
 func (r *Store) Delete(
 	baseTableName string,
@@ -436,6 +634,53 @@ func (r *Store) tableExists(
 	return true, nil
 }
 
+func isConditionalCheckFailed(err error) bool {
+	if err, ok := err.(awserr.Error); ok {
+		return err.Code() == dynamodb.ErrCodeConditionalCheckFailedException
+	}
+	return false
+}
+
+func isTransactionCanceled(err error) bool {
+	if err, ok := err.(awserr.Error); ok {
+		return err.Code() == dynamodb.ErrCodeTransactionCanceledException
+	}
+	return false
+}
+
+// countLive returns number of items for tenant that are not expired.
+func (r *Store) countLive(
+	tableName string,
+	tenant string,
+	now *string,
+) (size int64, err error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(tableName),
+		Select:                 aws.String("COUNT"),
+		ConsistentRead:         aws.Bool(true),
+		KeyConditionExpression: aws.String("#tenant = :tenant"),
+		FilterExpression:       aws.String("attribute_not_exists(expire_at) OR expire_at > :now"),
+		ExpressionAttributeNames: map[string]*string{
+			"#tenant": aws.String(r.config.TenantPartitionName),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":tenant": {S: aws.String(tenant)},
+			":now":    {N: now},
+		},
+	}
+	for {
+		result, err := r.ddbSvc.Query(input)
+		if err != nil {
+			return 0, err
+		}
+		size += *result.Count
+		if result.LastEvaluatedKey == nil {
+			return size, nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
 func toTenant(
 	baseTableSuffix string,
 	organizationID string,
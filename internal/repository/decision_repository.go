@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"time"
+)
+
+// NewDecisionRepository creates repository for persisting authorization decisions
+func NewDecisionRepository(
+	store DataStore,
+	retention time.Duration,
+) (Repository[types.Decision], error) {
+	return NewBaseRepository[types.Decision](store,
+		"Decision",
+		"",
+		retention,
+		func() *types.Decision {
+			return &types.Decision{}
+		})
+}
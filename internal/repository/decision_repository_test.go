@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/repository/redis"
+	"github.com/stretchr/testify/require"
+	"github.com/twinj/uuid"
+	"testing"
+	"time"
+)
+
+func Test_ShouldSaveAndQueryDecision(t *testing.T) {
+	// GIVEN config, redis-service and decision repository
+	ctx := context.TODO()
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	store, err := redis.NewRedisStore(cfg)
+	require.NoError(t, err)
+	repository, err := NewDecisionRepository(store, time.Minute)
+	require.NoError(t, err)
+	org := uuid.NewV4().String()
+	namespace := "decision-namespace"
+
+	// WHEN saving decisions
+	for i, principal := range []string{"alice", "bob", "alice"} {
+		decision := &types.Decision{
+			Id:             uuid.NewV4().String(),
+			OrganizationId: org,
+			Namespace:      namespace,
+			PrincipalId:    principal,
+			Resource:       "file",
+			Action:         "read",
+			LatencyMicros:  int64(i),
+		}
+		err = repository.Create(ctx, org, namespace, decision.Id, decision, time.Duration(0))
+		require.NoError(t, err)
+	}
+
+	// THEN it should query decisions by principal
+	res, _, err := repository.Query(ctx, org, namespace, map[string]string{"principal_id": "alice"}, "", 0)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(res))
+	res, _, err = repository.Query(ctx, org, namespace, map[string]string{"principal_id": "bob"}, "", 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(res))
+	require.Equal(t, "read", res[0].Action)
+}
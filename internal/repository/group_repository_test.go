@@ -135,6 +135,7 @@ func Test_ShouldSaveAndQueryGroup(t *testing.T) {
 func buildTestGroup(i int) types.Group {
 	return types.Group{
 		Id:      fmt.Sprintf("id_%d", i),
+		Version: 1,
 		Name:    fmt.Sprintf("name_%d", i),
 		RoleIds: []string{"1", "2"},
 	}
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"time"
+)
+
+// NewLeaderLeaseRepository creates repository for leases of background jobs
+func NewLeaderLeaseRepository(
+	store DataStore,
+) (Repository[domain.LeaderLease], error) {
+	return NewBaseRepository[domain.LeaderLease](store,
+		"LeaderLease",
+		"",
+		time.Duration(0),
+		func() *domain.LeaderLease {
+			return &domain.LeaderLease{}
+		})
+}
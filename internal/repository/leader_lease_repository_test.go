@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/repository/redis"
+	"github.com/stretchr/testify/require"
+	"github.com/twinj/uuid"
+	"testing"
+	"time"
+)
+
+func Test_ShouldAcquireLeaderLeaseOnce(t *testing.T) {
+	// GIVEN config, redis-service and lease repository
+	ctx := context.TODO()
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	store, err := redis.NewRedisStore(cfg)
+	require.NoError(t, err)
+	repository, err := NewLeaderLeaseRepository(store)
+	require.NoError(t, err)
+	job := uuid.NewV4().String()
+
+	// WHEN acquiring lease by two holders
+	err = repository.Create(ctx, "", "", job, domain.NewLeaderLease(job, "a", 1, time.Minute), time.Minute)
+	require.NoError(t, err)
+	err = repository.Create(ctx, "", "", job, domain.NewLeaderLease(job, "b", 1, time.Minute), time.Minute)
+	// THEN only first holder should acquire it
+	require.Error(t, err)
+
+	// WHEN renewing lease with its version
+	saved, err := repository.GetByID(ctx, "", "", job)
+	require.NoError(t, err)
+	require.Equal(t, "a", saved.Holder)
+	require.False(t, saved.Expired(time.Now()))
+	err = repository.Update(ctx, "", "", job, saved.Version,
+		domain.NewLeaderLease(job, "a", saved.Version+1, time.Minute), time.Minute)
+	// THEN it should be renewed
+	require.NoError(t, err)
+	// AND stale version should be rejected
+	err = repository.Update(ctx, "", "", job, saved.Version,
+		domain.NewLeaderLease(job, "b", saved.Version+1, time.Minute), time.Minute)
+	require.Error(t, err)
+}
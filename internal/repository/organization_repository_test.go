@@ -21,6 +21,7 @@ func Test_ShouldSaveAndGetOrganization(t *testing.T) {
 	repository, err := NewOrganizationRepository(store)
 	require.NoError(t, err)
 	org := buildTestOrg(1)
+	_ = repository.Delete(ctx, org.Id, "", org.Id)
 	err = repository.Create(ctx, org.Id, "", org.Id, &org, time.Duration(0))
 	require.NoError(t, err)
 
@@ -54,6 +55,7 @@ func Test_ShouldSaveAndDeleteOrganization(t *testing.T) {
 
 	namespace := "org-del-namespace"
 
+	_ = repository.Delete(ctx, org.Id, namespace, org.Id)
 	err = repository.Create(ctx, org.Id, namespace, org.Id, &org, time.Duration(0))
 	require.NoError(t, err)
 
@@ -78,6 +80,7 @@ func Test_ShouldSaveAndQueryOrganization(t *testing.T) {
 	require.NoError(t, err)
 	for i := 0; i < 200; i++ {
 		org := buildTestOrg(i)
+		_ = repository.Delete(ctx, org.Id, "", org.Id)
 		err = repository.Create(ctx, org.Id, "", org.Id, &org, time.Duration(0))
 		require.NoError(t, err)
 	}
@@ -119,6 +122,7 @@ func Test_ShouldSaveAndQueryOrganization(t *testing.T) {
 func buildTestOrg(i int) types.Organization {
 	return types.Organization{
 		Id:         fmt.Sprintf("id_%d", i),
+		Version:    1,
 		Url:        fmt.Sprintf("url_%d", i),
 		Namespaces: []string{"1", "2"},
 	}
@@ -134,6 +134,7 @@ func Test_ShouldSaveAndQueryPermission(t *testing.T) {
 func buildTestPermission(i int) types.Permission {
 	return types.Permission{
 		Id:          fmt.Sprintf("id_%d", i),
+		Version:     1,
 		Namespace:   fmt.Sprintf("ns_%d", i),
 		Scope:       fmt.Sprintf("scope_%d", i),
 		Actions:     []string{"read", "write"},
@@ -136,6 +136,7 @@ func Test_ShouldSaveAndQueryPrincipal(t *testing.T) {
 func buildTestPrincipal(i int) types.Principal {
 	return types.Principal{
 		Id:             fmt.Sprintf("id_%d", i),
+		Version:        1,
 		Username:       fmt.Sprintf("name_%d", i),
 		OrganizationId: fmt.Sprintf("org_%d", i),
 		Name:           fmt.Sprintf("john doe_%d", i),
@@ -0,0 +1,493 @@
+package redis
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/utils"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/sirupsen/logrus"
+)
+
+// idOffsetPrefix is added to the id of last record returned by scans.
+const idOffsetPrefix = "id:"
+
+// lexOffsetPrefix is added to the encoded member of last string value returned by indexed queries.
+const lexOffsetPrefix = "lex:"
+
+// numericOffsetPrefix is added to the score and encoded id of last numeric value returned by indexed queries.
+const numericOffsetPrefix = "num:"
+
+// getBatchSize is number of records fetched with a single HMGET by indexed queries.
+const getBatchSize = 100
+
+// reindexLua is shared by scripts that change items, where KEYS[3] is the hash of index entries
+// for each item and KEYS[6..] are sorted sets of indexed fields with score and member of each set
+// passed in ARGV starting at argStart. Entries of previous value are removed before adding new entries.
+const reindexLua = `
+local function reindex(id, argStart)
+	local old = redis.call('HGET', KEYS[3], id)
+	if old then
+		for _, entry in ipairs(cjson.decode(old)) do
+			redis.call('ZREM', entry[1], entry[2])
+		end
+	end
+	local entries = {}
+	for i = 6, #KEYS do
+		local member = ARGV[argStart + 2 * (i - 6) + 1]
+		redis.call('ZADD', KEYS[i], ARGV[argStart + 2 * (i - 6)], member)
+		entries[#entries + 1] = {KEYS[i], member}
+	end
+	if #entries > 0 then
+		redis.call('HSET', KEYS[3], id, cjson.encode(entries))
+	elseif old then
+		redis.call('HDEL', KEYS[3], id)
+	end
+end
+`
+
+// indexScript adds index entries of existing item unless its value was changed after it was read.
+var indexScript = redis.NewScript(-1, reindexLua+`
+if redis.call('HGET', KEYS[1], ARGV[1]) ~= ARGV[2] then
+	return 0
+end
+reindex(ARGV[1], 3)
+return 1
+`)
+
+// indexEntry is member of sorted set that indexes a field, where string values are stored with
+// lexicographical order and numeric values are stored with their score.
+type indexEntry struct {
+	key    string
+	score  float64
+	member string
+}
+
+// toIndexEntries returns entries of configured fields that are defined for the item.
+func (r *Store) toIndexEntries(
+	tableName string,
+	id string,
+	value []byte,
+) (entries []indexEntry) {
+	if len(r.indexedFields) == 0 {
+		return
+	}
+	m := make(map[string]interface{})
+	if err := json.Unmarshal(value, &m); err != nil {
+		return
+	}
+	for _, field := range r.indexedFields {
+		switch v := m[field].(type) {
+		case float64:
+			entries = append(entries, indexEntry{key: toNumericIndexName(tableName, field), score: v, member: id})
+		case string, bool:
+			entries = append(entries, indexEntry{key: toIndexName(tableName, field), member: fmt.Sprintf("%v\x00%s", v, id)})
+		}
+	}
+	return
+}
+
+// toScriptArgs returns number of keys followed by keys and arguments of scripts that maintain indexes.
+func toScriptArgs(
+	tableName string,
+	entries []indexEntry,
+	args ...interface{},
+) []interface{} {
+	res := []interface{}{5 + len(entries), tableName, toVersionTableName(tableName),
+		toIndexEntriesName(tableName), toExpiryTableName(tableName), expiringTablesKey}
+	for _, entry := range entries {
+		res = append(res, entry.key)
+	}
+	res = append(res, args...)
+	for _, entry := range entries {
+		res = append(res, entry.score, entry.member)
+	}
+	return res
+}
+
+// toIndexPredicate returns indexed field of predicate that can be used to find matching items,
+// where equality is preferred over ranges and inequality cannot be used.
+func (r *Store) toIndexPredicate(
+	predicate map[string]string,
+) (field string, op string, value string, ok bool) {
+	keys := make([]string, 0, len(predicate))
+	for k := range predicate {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		f, o := utils.ParsePredicateKey(k)
+		if o == "!=" || !utils.Includes(r.indexedFields, f) {
+			continue
+		}
+		if !ok || (o == "==" && op != "==") {
+			field, op, value, ok = f, o, predicate[k], true
+		}
+	}
+	return
+}
+
+// indexBounds are ranges of the sorted sets of a field that match an indexed predicate.
+type indexBounds struct {
+	lexMin   string
+	lexMax   string
+	scoreMin string
+	scoreMax string
+}
+
+// toIndexBounds returns ranges of string and numeric values that may match the predicate, where values are
+// compared both as strings and numbers because the type of the field is not known.
+func toIndexBounds(
+	op string,
+	value string,
+) indexBounds {
+	lexMin, lexMax := "["+value+"\x00", "("+value+"\x01"
+	number := strconv.FormatFloat(utils.ToFloat64(value), 'g', -1, 64)
+	scoreMin, scoreMax := number, number
+	switch op {
+	case ">=":
+		lexMax, scoreMax = "+", "+inf"
+	case ">":
+		lexMin, lexMax, scoreMin, scoreMax = "["+value+"\x01", "+", "("+number, "+inf"
+	case "<=":
+		lexMin, scoreMin = "-", "-inf"
+	case "<":
+		lexMin, lexMax, scoreMin, scoreMax = "-", "("+value+"\x00", "-inf", "("+number
+	}
+	return indexBounds{lexMin: lexMin, lexMax: lexMax, scoreMin: scoreMin, scoreMax: scoreMax}
+}
+
+// indexCursor is the position of an indexed query, where string values are read before numeric values.
+// Numeric values are read from the score of the last item and items with that score are skipped until
+// the id of the last item.
+type indexCursor struct {
+	numeric   bool
+	lexMin    string
+	scoreMin  string
+	lastScore float64
+	lastID    string
+	skip      int64
+}
+
+// queryIndex finds items matching predicates using sorted sets of the indexed field, where string values are
+// read in lexicographical order followed by numeric values in the order of scores. Each batch is read with
+// LIMIT and the next offset carries the last member that was read, so paging does not depend on the number
+// of items before the offset.
+func (r *Store) queryIndex(
+	conn redis.Conn,
+	tableName string,
+	field string,
+	op string,
+	value string,
+	predicate map[string]string,
+	offsetStr string,
+	limit int64,
+) (res map[string][]byte, nextOffset string, err error) {
+	res = make(map[string][]byte)
+	lexKey, numericKey := toIndexName(tableName, field), toNumericIndexName(tableName, field)
+	bounds := toIndexBounds(op, value)
+	cursor, err := parseIndexOffset(conn, tableName, lexKey, bounds, offsetStr)
+	if err != nil {
+		return nil, "", err
+	}
+	expired, err := expiredIDs(conn, tableName)
+	if err != nil {
+		return nil, "", err
+	}
+	for !cursor.numeric {
+		members, err := redis.Strings(conn.Do("ZRANGEBYLEX", lexKey, cursor.lexMin, bounds.lexMax,
+			"LIMIT", cursor.skip, getBatchSize))
+		if err != nil {
+			return nil, "", err
+		}
+		ids := make([]string, len(members))
+		for i, member := range members {
+			ids[i] = member[strings.LastIndexByte(member, 0)+1:]
+		}
+		last, err := addIndexed(conn, tableName, ids, expired, predicate, limit, res)
+		if err != nil {
+			return nil, "", err
+		}
+		if last >= 0 {
+			more := last+1 < len(members) || len(members) == getBatchSize
+			if !more {
+				count, err := redis.Int(conn.Do("ZCOUNT", numericKey, bounds.scoreMin, bounds.scoreMax))
+				if err != nil {
+					return nil, "", err
+				}
+				more = count > 0
+			}
+			if more {
+				nextOffset = lexOffsetPrefix + base64.RawURLEncoding.EncodeToString([]byte(members[last]))
+			}
+			return res, nextOffset, nil
+		}
+		if len(members) < getBatchSize {
+			cursor = indexCursor{numeric: true, scoreMin: bounds.scoreMin}
+			break
+		}
+		cursor.lexMin, cursor.skip = "("+members[len(members)-1], 0
+	}
+	for {
+		arr, err := redis.Strings(conn.Do("ZRANGEBYSCORE", numericKey, cursor.scoreMin, bounds.scoreMax,
+			"WITHSCORES", "LIMIT", cursor.skip, getBatchSize))
+		if err != nil {
+			return nil, "", err
+		}
+		ids := make([]string, 0, len(arr)/2)
+		scores := make([]string, 0, len(arr)/2)
+		lastScore := cursor.lastScore
+		for i := 0; i+1 < len(arr); i += 2 {
+			if lastScore, err = strconv.ParseFloat(arr[i+1], 64); err != nil {
+				return nil, "", err
+			}
+			// items before the offset with the same score are skipped
+			if cursor.lastID != "" && lastScore == cursor.lastScore && arr[i] <= cursor.lastID {
+				continue
+			}
+			ids = append(ids, arr[i])
+			scores = append(scores, arr[i+1])
+		}
+		last, err := addIndexed(conn, tableName, ids, expired, predicate, limit, res)
+		if err != nil {
+			return nil, "", err
+		}
+		if last >= 0 {
+			if last+1 < len(ids) || len(arr)/2 == getBatchSize {
+				nextOffset = numericOffsetPrefix + scores[last] + ":" +
+					base64.RawURLEncoding.EncodeToString([]byte(ids[last]))
+			}
+			return res, nextOffset, nil
+		}
+		if len(arr)/2 < getBatchSize {
+			return res, "", nil
+		}
+		if cursor.lastID != "" && lastScore == cursor.lastScore {
+			// all items of the batch have the same score as the offset
+			cursor.skip += getBatchSize
+		} else {
+			cursor.scoreMin, cursor.lastScore, cursor.lastID, cursor.skip =
+				arr[len(arr)-1], lastScore, arr[len(arr)-2], 0
+		}
+	}
+}
+
+// addIndexed adds values of ids that are neither deleted nor expired and match predicates, and returns
+// position of the id that reached the limit or -1 if the limit was not reached.
+func addIndexed(
+	conn redis.Conn,
+	tableName string,
+	ids []string,
+	expired map[string]bool,
+	predicate map[string]string,
+	limit int64,
+	res map[string][]byte,
+) (int, error) {
+	if len(ids) == 0 {
+		return -1, nil
+	}
+	arr, err := toArray(conn.Do("HMGET", insert(tableName, ids)...))
+	if err != nil {
+		return -1, err
+	}
+	for i, a := range arr {
+		// index entries of deleted items are ignored
+		if a == nil || expired[ids[i]] {
+			continue
+		}
+		val, err := redis.Bytes(a, nil)
+		if err != nil {
+			return -1, err
+		}
+		if !utils.MatchPredicate(val, predicate) {
+			continue
+		}
+		res[ids[i]] = val
+		if limit > 0 && int64(len(res)) >= limit {
+			return i, nil
+		}
+	}
+	return -1, nil
+}
+
+// parseIndexOffset returns cursor of an indexed query for the offset, where a numeric offset skips given
+// number of index entries from the start.
+func parseIndexOffset(
+	conn redis.Conn,
+	tableName string,
+	lexKey string,
+	bounds indexBounds,
+	offsetStr string,
+) (cursor indexCursor, err error) {
+	cursor = indexCursor{lexMin: bounds.lexMin, scoreMin: bounds.scoreMin}
+	invalid := func() (indexCursor, error) {
+		return indexCursor{}, domain.NewValidationError(
+			fmt.Sprintf("invalid offset %s for %s", offsetStr, tableName))
+	}
+	switch {
+	case offsetStr == "":
+		return
+	case strings.HasPrefix(offsetStr, lexOffsetPrefix):
+		member, err := base64.RawURLEncoding.DecodeString(offsetStr[len(lexOffsetPrefix):])
+		if err != nil {
+			return invalid()
+		}
+		cursor.lexMin = "(" + string(member)
+	case strings.HasPrefix(offsetStr, numericOffsetPrefix):
+		parts := strings.SplitN(offsetStr[len(numericOffsetPrefix):], ":", 2)
+		if len(parts) != 2 {
+			return invalid()
+		}
+		id, err := base64.RawURLEncoding.DecodeString(parts[1])
+		if err != nil || len(id) == 0 {
+			return invalid()
+		}
+		if cursor.lastScore, err = strconv.ParseFloat(parts[0], 64); err != nil {
+			return invalid()
+		}
+		cursor.numeric, cursor.scoreMin, cursor.lastID = true, parts[0], string(id)
+	default:
+		skip, err := strconv.ParseInt(offsetStr, 10, 64)
+		if err != nil || skip < 0 {
+			return invalid()
+		}
+		count, err := redis.Int64(conn.Do("ZLEXCOUNT", lexKey, bounds.lexMin, bounds.lexMax))
+		if err != nil {
+			return indexCursor{}, err
+		}
+		if skip < count {
+			cursor.skip = skip
+		} else {
+			cursor.numeric, cursor.skip = true, skip-count
+		}
+	}
+	return
+}
+
+// useIndex returns true if the table can be queried with indexes for the offset, where offsets of scans
+// continue the scan. Tables with items written before the configured fields were indexed are scanned
+// while their index entries are added in background.
+func (r *Store) useIndex(
+	conn redis.Conn,
+	tableName string,
+	offsetStr string,
+) (bool, error) {
+	if strings.HasPrefix(offsetStr, lexOffsetPrefix) || strings.HasPrefix(offsetStr, numericOffsetPrefix) {
+		return true, nil
+	}
+	if strings.Contains(offsetStr, ":") {
+		return false, nil
+	}
+	indexed, err := redis.String(conn.Do("GET", toIndexedMarkerName(tableName)))
+	if err != nil && err != redis.ErrNil {
+		return false, err
+	}
+	if indexed == strings.Join(r.indexedFields, ",") {
+		return true, nil
+	}
+	r.reindex(tableName)
+	return false, nil
+}
+
+// reindex adds index entries of the table in background unless the table is already being indexed.
+func (r *Store) reindex(tableName string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	select {
+	case <-r.done:
+		return
+	default:
+	}
+	if _, loaded := r.indexing.LoadOrStore(tableName, true); loaded {
+		return
+	}
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer r.indexing.Delete(tableName)
+		conn := r.pool.Get()
+		defer func() {
+			_ = conn.Close()
+		}()
+		if err := r.ensureIndexed(conn, tableName); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Component": "RedisStore",
+				"Table":     tableName,
+				"Error":     err,
+			}).Warnf("failed to index existing objects")
+		}
+	}()
+}
+
+// ensureIndexed adds index entries of items that were written before the configured fields were indexed.
+func (r *Store) ensureIndexed(
+	conn redis.Conn,
+	tableName string,
+) error {
+	signature := strings.Join(r.indexedFields, ",")
+	cursor := "0"
+	for {
+		select {
+		case <-r.done:
+			return nil
+		default:
+		}
+		arr, err := toArray(conn.Do("HSCAN", tableName, cursor, "COUNT", scanCount))
+		if err != nil {
+			return err
+		}
+		if cursor, err = redis.String(arr[0], nil); err != nil {
+			return err
+		}
+		items, err := redis.ByteSlices(arr[1], nil)
+		if err != nil {
+			return err
+		}
+		for i := 0; i+1 < len(items); i += 2 {
+			id := string(items[i])
+			if _, err = indexScript.Do(conn,
+				toScriptArgs(tableName, r.toIndexEntries(tableName, id, items[i+1]), id, items[i+1])...); err != nil {
+				return err
+			}
+		}
+		if cursor == "0" {
+			break
+		}
+	}
+	_, err := conn.Do("SET", toIndexedMarkerName(tableName), signature)
+	return err
+}
+
+// toIndexKeys returns keys of indexes for the table.
+func (r *Store) toIndexKeys(tableName string) (keys []interface{}) {
+	keys = append(keys, toIndexEntriesName(tableName), toIndexedMarkerName(tableName))
+	for _, field := range r.indexedFields {
+		keys = append(keys, toIndexName(tableName, field), toNumericIndexName(tableName, field))
+	}
+	return
+}
+
+// toIndexName returns sorted set of string values of the field with id of each item.
+func toIndexName(tableName string, field string) string {
+	return tableName + ":index:" + field
+}
+
+// toNumericIndexName returns sorted set of ids of items with numeric values of the field as scores.
+func toNumericIndexName(tableName string, field string) string {
+	return tableName + ":index:" + field + ":num"
+}
+
+// toIndexEntriesName returns hash of index entries for each item.
+func toIndexEntriesName(tableName string) string {
+	return tableName + ":indexes"
+}
+
+// toIndexedMarkerName returns key that stores fields that have been indexed for all items.
+func toIndexedMarkerName(tableName string) string {
+	return tableName + ":indexed"
+}
@@ -1,30 +1,220 @@
 package redis
 
 import (
+	"encoding/base64"
 	"fmt"
 	"github.com/bhatti/PlexAuthZ/internal/domain"
 	"github.com/bhatti/PlexAuthZ/internal/utils"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gomodule/redigo/redis"
 	"github.com/sirupsen/logrus"
 )
 
+// scanCount is the hint for number of items returned by each HSCAN.
+const scanCount = 500
+
+// expiryLua is shared by scripts that change items, where KEYS[4] is the sorted set of expiry times
+// of items in milliseconds and KEYS[5] is the set of tables with expiring items.
+const expiryLua = `
+local function live(id, now)
+	if redis.call('HEXISTS', KEYS[1], id) == 0 then
+		return false
+	end
+	local expiresAt = redis.call('ZSCORE', KEYS[4], id)
+	return not expiresAt or tonumber(expiresAt) > now
+end
+local function expire(id, expiresAt)
+	if expiresAt > 0 then
+		redis.call('ZADD', KEYS[4], expiresAt, id)
+		redis.call('SADD', KEYS[5], KEYS[1])
+	else
+		redis.call('ZREM', KEYS[4], id)
+	end
+end
+`
+
+// createScript adds item with version 1 and its index entries unless a live item already exists.
+var createScript = redis.NewScript(-1, reindexLua+expiryLua+`
+if live(ARGV[1], tonumber(ARGV[3])) then
+	return 0
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+redis.call('HSET', KEYS[2], ARGV[1], 1)
+expire(ARGV[1], tonumber(ARGV[4]))
+reindex(ARGV[1], 5)
+return 1
+`)
+
+// updateScript changes item and its index entries and increments its version if the stored version
+// matches expected version, where negative version skips the check. The expiry of the item is kept
+// unless a new expiry is given. It returns -1 if item doesn't exist and 0 if version doesn't match.
+var updateScript = redis.NewScript(-1, reindexLua+expiryLua+`
+local version, expiresAt = tonumber(ARGV[3]), tonumber(ARGV[5])
+local exists = live(ARGV[1], tonumber(ARGV[4]))
+if version >= 0 then
+	if not exists then
+		return -1
+	end
+	local current = redis.call('HGET', KEYS[2], ARGV[1])
+	if current and tonumber(current) ~= version then
+		return 0
+	end
+	redis.call('HSET', KEYS[2], ARGV[1], version + 1)
+elseif exists then
+	redis.call('HINCRBY', KEYS[2], ARGV[1], 1)
+else
+	redis.call('HSET', KEYS[2], ARGV[1], 1)
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+if expiresAt > 0 or not exists then
+	expire(ARGV[1], expiresAt)
+end
+reindex(ARGV[1], 6)
+return 1
+`)
+
+// allocateScript changes live item or adds item if number of live items is less than capacity, where
+// version of the live item or 0 for a new item must match expected version unless it's negative. It
+// returns 1 and number of live items if the item was written, 0 and number of live items if capacity
+// is exhausted, or -1 if version doesn't match.
+var allocateScript = redis.NewScript(-1, reindexLua+expiryLua+`
+local now, expiresAt, version = tonumber(ARGV[3]), tonumber(ARGV[4]), tonumber(ARGV[6])
+local size = redis.call('HLEN', KEYS[1]) - redis.call('ZCOUNT', KEYS[4], '-inf', now)
+local exists = live(ARGV[1], now)
+if version >= 0 then
+	local current = 0
+	if exists then
+		current = tonumber(redis.call('HGET', KEYS[2], ARGV[1]) or version)
+	end
+	if current ~= version then
+		return {-1, size}
+	end
+end
+if exists then
+	redis.call('HINCRBY', KEYS[2], ARGV[1], 1)
+	if expiresAt > 0 then
+		expire(ARGV[1], expiresAt)
+	end
+else
+	if size >= tonumber(ARGV[5]) then
+		return {0, size}
+	end
+	redis.call('HSET', KEYS[2], ARGV[1], 1)
+	expire(ARGV[1], expiresAt)
+	size = size + 1
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+reindex(ARGV[1], 7)
+return {1, size}
+`)
+
+// deleteScript removes item with its version, expiry and index entries.
+var deleteScript = redis.NewScript(-1, reindexLua+`
+redis.call('HDEL', KEYS[1], ARGV[1])
+redis.call('HDEL', KEYS[2], ARGV[1])
+redis.call('ZREM', KEYS[4], ARGV[1])
+reindex(ARGV[1], 2)
+return 1
+`)
+
+// deleteExpiredScript removes item if it is still expired and removes the table from the set of
+// tables with expiring items when it has no more expiring items.
+var deleteExpiredScript = redis.NewScript(-1, reindexLua+`
+local expiresAt = redis.call('ZSCORE', KEYS[4], ARGV[1])
+if not expiresAt or tonumber(expiresAt) > tonumber(ARGV[2]) then
+	return 0
+end
+redis.call('HDEL', KEYS[1], ARGV[1])
+redis.call('HDEL', KEYS[2], ARGV[1])
+redis.call('ZREM', KEYS[4], ARGV[1])
+reindex(ARGV[1], 3)
+if redis.call('ZCARD', KEYS[4]) == 0 then
+	redis.call('SREM', KEYS[5], KEYS[1])
+end
+return 1
+`)
+
+// getScript returns values of items that are not expired.
+var getScript = redis.NewScript(2, `
+local now = tonumber(ARGV[1])
+local res = {}
+for i = 2, #ARGV do
+	local expiresAt = redis.call('ZSCORE', KEYS[2], ARGV[i])
+	if not expiresAt or tonumber(expiresAt) > now then
+		res[i - 1] = redis.call('HGET', KEYS[1], ARGV[i])
+	else
+		res[i - 1] = false
+	end
+end
+return res
+`)
+
+// sizeScript returns number of items that are not expired.
+var sizeScript = redis.NewScript(2, `
+return redis.call('HLEN', KEYS[1]) - redis.call('ZCOUNT', KEYS[2], '-inf', ARGV[1])
+`)
+
+// expiringTablesKey is the set of tables that have items with expiry.
+const expiringTablesKey = "ExpiringTables"
+
 // Store cache service
 type Store struct {
-	pool *redis.Pool
+	config        *domain.RedisConfig
+	pool          *redis.Pool
+	indexedFields []string
+	indexing      sync.Map
+	done          chan bool
+	wg            sync.WaitGroup
+	lock          sync.Mutex
 }
 
-// NewRedisStore constructor for Redis store.
+// NewRedisStore constructor for Redis store, which deletes expired records in background.
 func NewRedisStore(
 	config *domain.Config,
 ) (*Store, error) {
 	if err := config.Validate(); err != nil {
 		return nil, err
 	}
+	pool := NewPool(config)
+	logrus.WithFields(
+		logrus.Fields{
+			"Component": "RedisStore",
+			"Host":      config.Redis.Host,
+			"Port":      config.Redis.Port,
+		}).Debugf("connected to Redis")
+	indexedFields := append([]string{}, config.Redis.IndexedFields...)
+	sort.Strings(indexedFields)
+	store := &Store{
+		config:        &config.Redis,
+		pool:          pool,
+		indexedFields: indexedFields,
+		done:          make(chan bool),
+	}
+	store.wg.Add(1)
+	go store.cleanup()
+	return store, nil
+}
+
+// Close stops deleting expired records and closes connections.
+func (r *Store) Close() error {
+	r.lock.Lock()
+	close(r.done)
+	r.lock.Unlock()
+	r.wg.Wait()
+	return r.pool.Close()
+}
+
+// NewPool creates pool of Redis connections for the configured host.
+func NewPool(
+	config *domain.Config,
+) *redis.Pool {
 	hostPort := fmt.Sprintf("%s:%d", config.Redis.Host, config.Redis.Port)
-	pool := &redis.Pool{
+	return &redis.Pool{
 		MaxIdle:   config.Redis.PoolSize,
 		MaxActive: config.Redis.PoolSize,
 		Dial: func() (redis.Conn, error) {
@@ -45,15 +235,6 @@ func NewRedisStore(
 			return err
 		},
 	}
-	logrus.WithFields(
-		logrus.Fields{
-			"Component": "RedisStore",
-			"Host":      config.Redis.Host,
-			"Port":      config.Redis.Port,
-		}).Debugf("connected to Redis")
-	return &Store{
-		pool: pool,
-	}, nil
 }
 
 // CreateTable no-op function.
@@ -65,7 +246,7 @@ func (r *Store) CreateTable(
 	return nil
 }
 
-// Size returns number of rows for tenant in table.
+// Size returns number of live rows for tenant in table.
 func (r *Store) Size(
 	baseTableName string,
 	baseTableSuffix string,
@@ -78,14 +259,14 @@ func (r *Store) Size(
 	}()
 
 	tableName := toTableName(baseTableName, baseTableSuffix, tenant, namespace)
-	i, err := conn.Do("HLEN", tableName)
+	i, err := sizeScript.Do(conn, tableName, toExpiryTableName(tableName), time.Now().UnixMilli())
 	if err != nil {
 		return 0, err
 	}
 	return utils.ToInt64(i), nil
 }
 
-// Get finds record by ids.
+// Get finds live records by ids.
 func (r *Store) Get(
 	baseTableName string,
 	baseTableSuffix string,
@@ -100,11 +281,19 @@ func (r *Store) Get(
 
 	tableName := toTableName(baseTableName, baseTableSuffix, tenant, namespace)
 	res = make(map[string][]byte)
-	arr, err := toArray(conn.Do("HMGET", insert(tableName, ids)...))
+	args := []interface{}{tableName, toExpiryTableName(tableName), time.Now().UnixMilli()}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	arr, err := toArray(getScript.Do(conn, args...))
 	if err != nil {
 		return nil, err
 	}
 	for i, a := range arr {
+		if a == nil {
+			return nil, domain.NewNotFoundError(
+				fmt.Sprintf("failed to get object for id %s in %s", ids[i], baseTableName))
+		}
 		res[ids[i]], err = redis.Bytes(a, err)
 		if err != nil {
 			return nil, err
@@ -113,7 +302,10 @@ func (r *Store) Get(
 	return
 }
 
-// Query queries records for given predicates.
+// Query queries records for given predicates, where records are found with sorted sets of indexed
+// fields if predicates include an indexed field, or otherwise by scanning the table with HSCAN. The
+// next offset resumes the scan or indexed query and is empty when no more records are left. Records
+// may be returned more than once if the table is resized during a scan.
 func (r *Store) Query(
 	baseTableName string,
 	baseTableSuffix string,
@@ -122,40 +314,162 @@ func (r *Store) Query(
 	predicate map[string]string,
 	offsetStr string,
 	limit int64,
+) (res map[string][]byte, nextOffset string, err error) {
+	conn := r.pool.Get()
+	defer func() {
+		_ = conn.Close()
+	}()
+	tableName := toTableName(baseTableName, baseTableSuffix, tenant, namespace)
+	if field, op, value, ok := r.toIndexPredicate(predicate); ok {
+		if indexed, err := r.useIndex(conn, tableName, offsetStr); err != nil {
+			return nil, "", err
+		} else if indexed {
+			return r.queryIndex(conn, tableName, field, op, value, predicate, offsetStr, limit)
+		}
+	}
+	return r.scan(conn, tableName, predicate, offsetStr, limit)
+}
+
+// scan finds records for given predicates with HSCAN, where items returned for each cursor are sorted by
+// id and offset is the cursor of HSCAN followed by id of the last item consumed for that cursor. Items are
+// resumed after that id, so items added or deleted for the cursor do not shift the page. HSCAN may return
+// items more than once if the table is resized between pages. A numeric offset skips given number of items
+// from the start of the table.
+func (r *Store) scan(
+	conn redis.Conn,
+	tableName string,
+	predicate map[string]string,
+	offsetStr string,
+	limit int64,
 ) (res map[string][]byte, nextOffset string, err error) {
 	res = make(map[string][]byte)
+	cursor, skip, lastID, err := parseScanOffset(tableName, offsetStr)
+	if err != nil {
+		return nil, "", err
+	}
+	expired, err := expiredIDs(conn, tableName)
+	if err != nil {
+		return nil, "", err
+	}
+	for {
+		arr, err := toArray(conn.Do("HSCAN", tableName, cursor, "COUNT", scanCount))
+		if err != nil {
+			return nil, "", err
+		}
+		next, err := redis.String(arr[0], nil)
+		if err != nil {
+			return nil, "", err
+		}
+		items, err := redis.ByteSlices(arr[1], nil)
+		if err != nil {
+			return nil, "", err
+		}
+		size := len(items) / 2
+		order := make([]int, size)
+		for i := range order {
+			order[i] = i
+		}
+		sort.Slice(order, func(i, j int) bool {
+			return string(items[order[i]*2]) < string(items[order[j]*2])
+		})
+		for k := skip; k < size; k++ {
+			id, value := string(items[order[k]*2]), items[order[k]*2+1]
+			if (lastID != "" && id <= lastID) || expired[id] || !utils.MatchPredicate(value, predicate) {
+				continue
+			}
+			res[id] = value
+			if limit > 0 && int64(len(res)) >= limit {
+				if k+1 < size {
+					return res, cursor + ":" + idOffsetPrefix + id, nil
+				} else if next != "0" {
+					return res, next + ":0", nil
+				}
+				return res, "", nil
+			}
+		}
+		skip, lastID = max(0, skip-size), ""
+		if next == "0" {
+			return res, "", nil
+		}
+		cursor = next
+	}
+}
+
+// Expiring finds live records that expire at or before the given time with the sorted set of expiry
+// times of the table, where records are read in the order of expiry and the next offset carries the
+// expiry and id of the last record, so paging does not depend on records released before the offset.
+func (r *Store) Expiring(
+	baseTableName string,
+	baseTableSuffix string,
+	tenant string,
+	namespace string,
+	before time.Time,
+	offsetStr string,
+	limit int64,
+) (res map[string][]byte, nextOffset string, err error) {
 	conn := r.pool.Get()
 	defer func() {
 		_ = conn.Close()
 	}()
 	tableName := toTableName(baseTableName, baseTableSuffix, tenant, namespace)
-	arr, err := toArray(conn.Do("HGETALL", tableName))
-	offset, _ := strconv.Atoi(offsetStr)
+	lastScore, lastID, err := parseExpiringOffset(tableName, offsetStr)
 	if err != nil {
 		return nil, "", err
 	}
-	for i := offset * 2; i < len(arr); i += 2 {
-		name, err := redis.String(arr[i], nil)
+	res = make(map[string][]byte)
+	scoreMin, skip := "("+strconv.FormatInt(time.Now().UnixMilli(), 10), 0
+	if lastID != "" && lastScore > time.Now().UnixMilli() {
+		scoreMin = strconv.FormatInt(lastScore, 10)
+	}
+	for {
+		arr, err := redis.Strings(conn.Do("ZRANGEBYSCORE", toExpiryTableName(tableName), scoreMin,
+			before.UnixMilli(), "WITHSCORES", "LIMIT", skip, getBatchSize))
 		if err != nil {
 			return nil, "", err
 		}
-		value, err := redis.Bytes(arr[i+1], nil)
+		ids := make([]string, 0, len(arr)/2)
+		scores := make([]string, 0, len(arr)/2)
+		allSkipped := true
+		for i := 0; i+1 < len(arr); i += 2 {
+			score, err := strconv.ParseInt(arr[i+1], 10, 64)
+			if err != nil {
+				return nil, "", err
+			}
+			// items before the offset with the same expiry are skipped
+			if lastID != "" && score == lastScore && arr[i] <= lastID {
+				continue
+			}
+			allSkipped = false
+			ids = append(ids, arr[i])
+			scores = append(scores, arr[i+1])
+		}
+		last, err := addIndexed(conn, tableName, ids, nil, nil, limit, res)
 		if err != nil {
 			return nil, "", err
 		}
-		if !utils.MatchPredicate(value, predicate) {
-			continue
+		if last >= 0 {
+			if last+1 < len(ids) || len(arr)/2 == getBatchSize {
+				nextOffset = scores[last] + ":" + base64.RawURLEncoding.EncodeToString([]byte(ids[last]))
+			}
+			return res, nextOffset, nil
 		}
-		res[name] = value
-		if limit > 0 && len(res) >= int(limit) {
-			break
+		if len(arr)/2 < getBatchSize {
+			return res, "", nil
+		}
+		if allSkipped {
+			// all items of the batch have the same expiry as the offset
+			skip += getBatchSize
+		} else {
+			scoreMin, skip = arr[len(arr)-1], 0
+			if lastScore, err = strconv.ParseInt(scoreMin, 10, 64); err != nil {
+				return nil, "", err
+			}
+			lastID = arr[len(arr)-2]
 		}
 	}
-	nextOffset = fmt.Sprintf("%d", max(limit, len(res)))
-	return
 }
 
-// Create updates item in Redis table -- no difference between Create and Update.
+// Create adds item in Redis table with version 1, which fails if the item already exists.
 func (r *Store) Create(
 	baseTableName string,
 	baseTableSuffix string,
@@ -164,17 +478,35 @@ func (r *Store) Create(
 	id string,
 	value []byte,
 	expiration time.Duration) (err error) {
-	return r.Update(baseTableName, baseTableSuffix, tenant, namespace, id, 0, value, expiration)
+	conn := r.pool.Get()
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	tableName := toTableName(baseTableName, baseTableSuffix, tenant, namespace)
+	now := time.Now().UnixMilli()
+	created, err := redis.Int(createScript.Do(conn, toScriptArgs(tableName,
+		r.toIndexEntries(tableName, id, value), id, value, now, toExpiresAt(now, expiration))...))
+	if err != nil {
+		return err
+	}
+	if created == 0 {
+		return domain.NewDuplicateError(
+			fmt.Sprintf("object with id %s already exists in %s", id, baseTableName))
+	}
+	return nil
 }
 
-// Update updates item in Redis table.
+// Update changes item in Redis table if its stored version matches, or writes the item regardless
+// of the version when version is negative. Items written before versions were stored are updated
+// without checking the version.
 func (r *Store) Update(
 	baseTableName string,
 	baseTableSuffix string,
 	tenant string,
 	namespace string,
 	id string,
-	_ int64, // no version support
+	version int64,
 	value []byte,
 	expiration time.Duration) (err error) {
 	conn := r.pool.Get()
@@ -183,14 +515,57 @@ func (r *Store) Update(
 	}()
 
 	tableName := toTableName(baseTableName, baseTableSuffix, tenant, namespace)
-	_, err = conn.Do("HSET", tableName, id, value)
-	if err == nil && expiration.Seconds() > 0 {
-		_, err = conn.Do("EXPIRE", tableName, expiration.Seconds())
+	now := time.Now().UnixMilli()
+	updated, err := redis.Int(updateScript.Do(conn, toScriptArgs(tableName,
+		r.toIndexEntries(tableName, id, value), id, value, version, now, toExpiresAt(now, expiration))...))
+	if err != nil {
+		return err
 	}
-	return
+	if updated == -1 {
+		return domain.NewNotFoundError(
+			fmt.Sprintf("failed to get object for id %s in %s", id, baseTableName))
+	} else if updated == 0 {
+		return domain.NewConflictError(
+			fmt.Sprintf("version %d of object %s in %s is stale", version, id, baseTableName))
+	}
+	return nil
 }
 
-// Delete removes cache entry
+// Allocate changes live item in Redis table or adds item if number of live items is less than capacity.
+func (r *Store) Allocate(
+	baseTableName string,
+	baseTableSuffix string,
+	tenant string,
+	namespace string,
+	id string,
+	version int64,
+	capacity int64,
+	value []byte,
+	expiration time.Duration) (err error) {
+	conn := r.pool.Get()
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	tableName := toTableName(baseTableName, baseTableSuffix, tenant, namespace)
+	now := time.Now().UnixMilli()
+	res, err := redis.Int64s(allocateScript.Do(conn, toScriptArgs(tableName,
+		r.toIndexEntries(tableName, id, value), id, value, now, toExpiresAt(now, expiration), capacity, version)...))
+	if err != nil {
+		return err
+	}
+	if res[0] < 0 {
+		return domain.NewConflictError(
+			fmt.Sprintf("version %d of object %s in %s is stale", version, id, baseTableName))
+	}
+	if res[0] == 0 {
+		return domain.NewValidationError(
+			fmt.Sprintf("usage %d exceeds capacity %d", res[1], capacity))
+	}
+	return nil
+}
+
+// Delete removes cache entry with its version and index entries
 func (r *Store) Delete(
 	baseTableName string,
 	baseTableSuffix string,
@@ -203,11 +578,11 @@ func (r *Store) Delete(
 	}()
 
 	tableName := toTableName(baseTableName, baseTableSuffix, tenant, namespace)
-	_, err = conn.Do("HDEL", tableName, id)
+	_, err = deleteScript.Do(conn, toScriptArgs(tableName, nil, id)...)
 	return
 }
 
-// ClearTable removes all entries in table
+// ClearTable removes all entries in table with their versions and indexes
 func (r *Store) ClearTable(
 	baseTableName string,
 	baseTableSuffix string,
@@ -219,29 +594,149 @@ func (r *Store) ClearTable(
 		_ = conn.Close()
 	}()
 	tableName := toTableName(baseTableName, baseTableSuffix, tenant, namespace)
-	arr, err := toArray(conn.Do("HGETALL", tableName))
+	size, err := redis.Int64(conn.Do("HLEN", tableName))
 	if err != nil {
 		return err
 	}
-	for i := 0; i < len(arr); i += 2 {
-		name, err := redis.String(arr[i], nil)
-		if err != nil {
-			return err
-		}
-		_, err = conn.Do("HDEL", tableName, name)
-		if err != nil {
-			return err
-		}
+	keys := append([]interface{}{tableName, toVersionTableName(tableName), toExpiryTableName(tableName)},
+		r.toIndexKeys(tableName)...)
+	if _, err = conn.Do("UNLINK", keys...); err != nil {
+		return err
+	}
+	if _, err = conn.Do("SREM", expiringTablesKey, tableName); err != nil {
+		return err
 	}
 	logrus.WithFields(logrus.Fields{
 		"Component": "RedisStore",
 		"Table":     tableName,
-		"Deleted":   len(arr),
+		"Deleted":   size,
 	}).
 		Debugf("deleting all objects in table")
 	return
 }
 
+// DeleteExpired removes expired records from all tables.
+func (r *Store) DeleteExpired() (deleted int64, err error) {
+	conn := r.pool.Get()
+	defer func() {
+		_ = conn.Close()
+	}()
+	cursor := "0"
+	for {
+		arr, err := toArray(conn.Do("SSCAN", expiringTablesKey, cursor, "COUNT", scanCount))
+		if err != nil {
+			return deleted, err
+		}
+		if cursor, err = redis.String(arr[0], nil); err != nil {
+			return deleted, err
+		}
+		tableNames, err := redis.Strings(arr[1], nil)
+		if err != nil {
+			return deleted, err
+		}
+		for _, tableName := range tableNames {
+			now := time.Now().UnixMilli()
+			ids, err := redis.Strings(conn.Do("ZRANGEBYSCORE", toExpiryTableName(tableName), "-inf", now))
+			if err != nil {
+				return deleted, err
+			}
+			for _, id := range ids {
+				n, err := redis.Int64(deleteExpiredScript.Do(conn, toScriptArgs(tableName, nil, id, now)...))
+				if err != nil {
+					return deleted, err
+				}
+				deleted += n
+			}
+		}
+		if cursor == "0" {
+			return deleted, nil
+		}
+	}
+}
+
+func (r *Store) cleanup() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(r.config.CleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			if deleted, err := r.DeleteExpired(); err != nil {
+				logrus.WithFields(logrus.Fields{
+					"Component": "RedisStore",
+					"Error":     err,
+				}).Warnf("failed to delete expired objects")
+			} else if deleted > 0 {
+				logrus.WithFields(logrus.Fields{
+					"Component": "RedisStore",
+					"Deleted":   deleted,
+				}).Debugf("deleted expired objects")
+			}
+		}
+	}
+}
+
+// expiredIDs returns ids of records in table that are expired but not yet deleted.
+func expiredIDs(conn redis.Conn, tableName string) (map[string]bool, error) {
+	ids, err := redis.Strings(conn.Do("ZRANGEBYSCORE", toExpiryTableName(tableName), "-inf", time.Now().UnixMilli()))
+	if err != nil {
+		return nil, err
+	}
+	expired := make(map[string]bool)
+	for _, id := range ids {
+		expired[id] = true
+	}
+	return expired, nil
+}
+
+// parseScanOffset returns cursor of HSCAN with number of items to skip or id of the last item consumed
+// for the offset.
+func parseScanOffset(tableName string, offsetStr string) (cursor string, skip int, lastID string, err error) {
+	cursor = "0"
+	if offsetStr == "" {
+		return
+	}
+	parts := strings.SplitN(offsetStr, ":", 2)
+	if len(parts) == 2 {
+		cursor = parts[0]
+		_, err = strconv.ParseUint(cursor, 10, 64)
+	}
+	last := parts[len(parts)-1]
+	if len(parts) == 2 && strings.HasPrefix(last, idOffsetPrefix) {
+		if lastID = last[len(idOffsetPrefix):]; lastID == "" {
+			skip = -1
+		}
+	} else if err == nil {
+		skip, err = strconv.Atoi(last)
+	}
+	if err != nil || skip < 0 {
+		return "", 0, "", domain.NewValidationError(
+			fmt.Sprintf("invalid offset %s for %s", offsetStr, tableName))
+	}
+	return
+}
+
+// parseExpiringOffset returns expiry and id of the last record returned by Expiring.
+func parseExpiringOffset(tableName string, offsetStr string) (lastScore int64, lastID string, err error) {
+	if offsetStr == "" {
+		return 0, "", nil
+	}
+	parts := strings.SplitN(offsetStr, ":", 2)
+	var id []byte
+	if len(parts) == 2 {
+		if lastScore, err = strconv.ParseInt(parts[0], 10, 64); err == nil {
+			id, err = base64.RawURLEncoding.DecodeString(parts[1])
+		}
+	}
+	if len(parts) != 2 || err != nil || len(id) == 0 {
+		return 0, "", domain.NewValidationError(
+			fmt.Sprintf("invalid offset %s for %s", offsetStr, tableName))
+	}
+	return lastScore, string(id), nil
+}
+
 func toArray(i interface{}, err error) ([]interface{}, error) {
 	if err != nil {
 		return nil, err
@@ -276,10 +771,34 @@ func toTableName(
 	return fmt.Sprintf("%s__%s__%s__%s", baseTableName, organizationID, namespace, baseTableSuffix)
 }
 
-func max(i int64, j int) int {
-	ii := int(i)
-	if ii > j {
-		return ii
+// toVersionTableName returns hash of versions for items of the table.
+func toVersionTableName(tableName string) string {
+	return tableName + ":versions"
+}
+
+// toExpiryTableName returns sorted set of expiry times for items of the table.
+func toExpiryTableName(tableName string) string {
+	return tableName + ":expires"
+}
+
+// toExpiresAt returns expiry time in milliseconds or 0 if item doesn't expire.
+func toExpiresAt(now int64, expiration time.Duration) int64 {
+	if expiration.Milliseconds() <= 0 {
+		return 0
+	}
+	return now + expiration.Milliseconds()
+}
+
+func max(i int, j int) int {
+	if i > j {
+		return i
+	}
+	return j
+}
+
+func min(i int, j int) int {
+	if i < j {
+		return i
 	}
 	return j
 }
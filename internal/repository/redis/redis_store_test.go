@@ -3,94 +3,381 @@ package redis
 import (
 	"fmt"
 	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/repository"
+	"github.com/bhatti/PlexAuthZ/internal/repository/storetest"
+	"github.com/gomodule/redigo/redis"
 	"github.com/stretchr/testify/require"
+	"github.com/twinj/uuid"
 	"testing"
+	"time"
 )
 
-func Test_ShouldSaveAndGetData(t *testing.T) {
-	// GIVEN config and redis-service
+func Test_ShouldPassDataStoreSuite(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) repository.DataStore {
+		cfg, err := domain.NewConfig("")
+		require.NoError(t, err)
+		store, err := NewRedisStore(cfg)
+		require.NoError(t, err)
+		return store
+	})
+}
+
+func Test_ShouldQueryPagesWithScanCursors(t *testing.T) {
+	// GIVEN config and redis-service with more records than a single scan
 	cfg, err := domain.NewConfig("")
 	require.NoError(t, err)
 	store, err := NewRedisStore(cfg)
 	require.NoError(t, err)
+	namespace := "test-query-pages"
 	baseTable := "table1"
-	namespace := "test-save-get"
-	tenant := "123"
-	err = store.Create(baseTable, "", tenant, namespace, "id1", []byte("data1"), 0)
+	tenant := uuid.NewV4().String()
+	for i := 0; i < 1200; i++ {
+		data := []byte(fmt.Sprintf(`{"name":"name_%04d","kind":"k%d"}`, i, i%2))
+		require.NoError(t, store.Create(baseTable, "", tenant, namespace, fmt.Sprintf("id_%d", i), data, 0))
+	}
+	defer func() {
+		require.NoError(t, store.ClearTable(baseTable, "", tenant, namespace))
+	}()
+
+	// WHEN querying pages
+	all := make(map[string][]byte)
+	next := ""
+	for pages := 0; ; pages++ {
+		res, nextOffset, err := store.Query(baseTable, "", tenant, namespace,
+			map[string]string{"kind": "k1"}, next, 70)
+		require.NoError(t, err)
+		require.True(t, len(res) <= 70)
+		for k, v := range res {
+			all[k] = v
+		}
+		if nextOffset == "" {
+			break
+		}
+		require.NotEqual(t, next, nextOffset)
+		next = nextOffset
+		require.True(t, pages < 20)
+	}
+	// THEN all matching records should be returned
+	require.Equal(t, 600, len(all))
+
+	// AND numeric offsets should skip records from the start
+	res, _, err := store.Query(baseTable, "", tenant, namespace, nil, "1190", 20)
 	require.NoError(t, err)
+	require.Equal(t, 10, len(res))
 
-	saved, err := store.Get(baseTable, "", tenant, namespace, "id1")
+	// AND invalid offset should fail
+	_, _, err = store.Query(baseTable, "", tenant, namespace, nil, "abc", 20)
+	require.Error(t, err)
+}
+
+func Test_ShouldQueryWithIndexedFields(t *testing.T) {
+	// GIVEN records written before fields were indexed
+	cfg, err := domain.NewConfig("")
 	require.NoError(t, err)
-	require.Equal(t, "data1", string(saved["id1"]))
+	unindexed, err := NewRedisStore(cfg)
+	require.NoError(t, err)
+	namespace := "test-query-index"
+	baseTable := "table1"
+	tenant := uuid.NewV4().String()
+	toData := func(i int) []byte {
+		return []byte(fmt.Sprintf(`{"name":"name_%03d","kind":"k%d","size":%d,"active":%v}`, i, i%2, i, i%3 == 0))
+	}
+	for i := 0; i < 100; i++ {
+		require.NoError(t, unindexed.Create(baseTable, "", tenant, namespace, fmt.Sprintf("id_%03d", i), toData(i), 0))
+	}
 
-	err = store.Update(baseTable, "", tenant, namespace, "id1", 1, []byte("data2"), 0)
+	// AND a store with indexed fields
+	cfg.Redis.IndexedFields = []string{"size", "name", "active"}
+	store, err := NewRedisStore(cfg)
 	require.NoError(t, err)
+	for i := 100; i < 200; i++ {
+		require.NoError(t, store.Create(baseTable, "", tenant, namespace, fmt.Sprintf("id_%03d", i), toData(i), 0))
+	}
+	defer func() {
+		require.NoError(t, store.ClearTable(baseTable, "", tenant, namespace))
+	}()
 
-	saved, err = store.Get(baseTable, "", tenant, namespace, "id1")
+	// WHEN querying with equality of indexed field
+	res, _, err := store.Query(baseTable, "", tenant, namespace, map[string]string{"name": "name_010"}, "", 0)
+	// THEN records written before indexing should be found
+	require.NoError(t, err)
+	require.Equal(t, 1, len(res))
+	require.Equal(t, string(toData(10)), string(res["id_010"]))
+	// AND records written before indexing should be indexed in background
+	conn := store.pool.Get()
+	defer func() {
+		_ = conn.Close()
+	}()
+	tableName := toTableName(baseTable, "", tenant, namespace)
+	require.Eventually(t, func() bool {
+		indexed, err := store.useIndex(conn, tableName, "")
+		return err == nil && indexed
+	}, 5*time.Second, 10*time.Millisecond)
+	indexed, err := redis.Int(conn.Do("ZCARD", toIndexName(tableName, "name")))
 	require.NoError(t, err)
-	require.Equal(t, "data2", string(saved["id1"]))
+	require.Equal(t, 200, indexed)
 
-	count, err := store.Size(baseTable, "", tenant, namespace)
+	// AND ranges of strings, numbers and booleans should match as without index
+	for _, tc := range []struct {
+		predicate string
+		value     string
+		expected  int
+	}{
+		{"name:>=", "name_050", 150},
+		{"name:>", "name_050", 149},
+		{"name:<=", "name_050", 51},
+		{"name:<", "name_050", 50},
+		{"size:>=", "50", 150},
+		{"size:>", "50", 149},
+		{"size:<=", "50", 51},
+		{"size:<", "50", 50},
+		{"size:==", "150", 1},
+		{"active", "true", 67},
+	} {
+		res, _, err = store.Query(baseTable, "", tenant, namespace, map[string]string{tc.predicate: tc.value}, "", 0)
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, len(res), tc.predicate)
+	}
+
+	// AND other predicates should be applied to indexed records
+	res, _, err = store.Query(baseTable, "", tenant, namespace,
+		map[string]string{"size:>=": "100", "kind": "k1", "name:!=": "name_101"}, "", 0)
 	require.NoError(t, err)
-	require.Equal(t, int64(1), count)
+	require.Equal(t, 49, len(res))
+
+	// WHEN querying pages of indexed records
+	all := make(map[string][]byte)
+	next := ""
+	for pages := 0; ; pages++ {
+		res, next, err = store.Query(baseTable, "", tenant, namespace, map[string]string{"size:<": "100"}, next, 30)
+		require.NoError(t, err)
+		for k, v := range res {
+			all[k] = v
+		}
+		if next == "" {
+			break
+		}
+		require.True(t, pages < 5)
+	}
+	// THEN each record should be returned
+	require.Equal(t, 100, len(all))
 
-	err = store.ClearTable(baseTable, "", tenant, namespace)
+	// WHEN updating and deleting indexed records
+	require.NoError(t, store.Update(baseTable, "", tenant, namespace, "id_010", 1, toData(500), 0))
+	require.NoError(t, store.Delete(baseTable, "", tenant, namespace, "id_011"))
+	// THEN indexes should be updated
+	res, _, err = store.Query(baseTable, "", tenant, namespace, map[string]string{"name": "name_010"}, "", 0)
 	require.NoError(t, err)
+	require.Equal(t, 0, len(res))
+	res, _, err = store.Query(baseTable, "", tenant, namespace, map[string]string{"size": "500"}, "", 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(res))
+	res, _, err = store.Query(baseTable, "", tenant, namespace, map[string]string{"name:<": "name_020"}, "", 0)
+	require.NoError(t, err)
+	require.Equal(t, 18, len(res))
+	indexed, err = redis.Int(conn.Do("ZCARD", toIndexName(tableName, "name")))
+	require.NoError(t, err)
+	require.Equal(t, 199, indexed)
 }
 
-func Test_ShouldSaveAndDeleteData(t *testing.T) {
-	// GIVEN config and redis-service
+func Test_ShouldPageWhileDeletingQueriedRecords(t *testing.T) {
+	// GIVEN config and redis-service with indexed fields
 	cfg, err := domain.NewConfig("")
 	require.NoError(t, err)
+	cfg.Redis.IndexedFields = []string{"size", "name"}
 	store, err := NewRedisStore(cfg)
 	require.NoError(t, err)
-
-	data := []byte("data1")
-	namespace := "test-save-del"
+	defer func() {
+		_ = store.Close()
+	}()
+	namespace := "test-query-paging"
 	baseTable := "table1"
-	tenant := "123"
-	err = store.Create(baseTable, "", tenant, namespace, "id1", data, 0)
-	require.NoError(t, err)
+	tenant := uuid.NewV4().String()
+	// AND records with same values of indexed fields
+	for i := 0; i < 250; i++ {
+		data := []byte(fmt.Sprintf(`{"name":"name_%d","size":%d,"kind":"k"}`, i%2, i%2))
+		require.NoError(t, store.Create(baseTable, "", tenant, namespace, fmt.Sprintf("id_%03d", i), data, 0))
+	}
+	defer func() {
+		require.NoError(t, store.ClearTable(baseTable, "", tenant, namespace))
+	}()
+	conn := store.pool.Get()
+	defer func() {
+		_ = conn.Close()
+	}()
+	tableName := toTableName(baseTable, "", tenant, namespace)
+	require.Eventually(t, func() bool {
+		indexed, err := store.useIndex(conn, tableName, "")
+		return err == nil && indexed
+	}, 5*time.Second, 10*time.Millisecond)
 
-	_, err = store.Get(baseTable, "", tenant, namespace, "id1")
-	require.NoError(t, err)
+	for _, predicate := range []map[string]string{
+		{"kind": "k"},
+		{"name:>=": "name_0"},
+		{"size:>=": "0"},
+	} {
+		// WHEN querying pages while deleting and recreating each returned record
+		all := make(map[string]bool)
+		next := ""
+		for pages := 0; ; pages++ {
+			res, nextOffset, err := store.Query(baseTable, "", tenant, namespace, predicate, next, 40)
+			require.NoError(t, err)
+			for k, v := range res {
+				// THEN records should not be returned more than once
+				require.False(t, all[k], k)
+				all[k] = true
+				require.NoError(t, store.Delete(baseTable, "", tenant, namespace, k))
+				require.NoError(t, store.Create(baseTable, "", tenant, namespace, k, v, 0))
+			}
+			if nextOffset == "" {
+				break
+			}
+			next = nextOffset
+			require.True(t, pages < 10)
+		}
+		// AND each record should be returned
+		require.Equal(t, 250, len(all), predicate)
+	}
 
-	err = store.Delete(baseTable, "", tenant, namespace, "id1")
+	// AND numeric offsets of indexed queries should skip index entries from the start
+	res, _, err := store.Query(baseTable, "", tenant, namespace, map[string]string{"size:>=": "0"}, "240", 0)
 	require.NoError(t, err)
-
-	_, err = store.Get(baseTable, "", tenant, namespace, "id1")
-	require.Error(t, err)
-
-	err = store.ClearTable(baseTable, "", tenant, namespace)
+	require.Equal(t, 10, len(res))
+	res, _, err = store.Query(baseTable, "", tenant, namespace, map[string]string{"name:>=": "name_0"}, "240", 0)
 	require.NoError(t, err)
+	require.Equal(t, 10, len(res))
+
+	// AND invalid offsets of indexed queries should fail
+	for _, offset := range []string{"lex:*", "num:abc:aWQ", "num:1", "-1"} {
+		_, _, err = store.Query(baseTable, "", tenant, namespace, map[string]string{"size": "1"}, offset, 0)
+		require.Error(t, err, offset)
+	}
 }
 
-func Test_ShouldSaveAndQueryData(t *testing.T) {
-	// GIVEN config and redis-service
+func Test_ShouldExpireEachRecord(t *testing.T) {
+	// GIVEN config and redis-service with expiring and permanent records
 	cfg, err := domain.NewConfig("")
 	require.NoError(t, err)
+	cfg.Redis.IndexedFields = []string{"name"}
 	store, err := NewRedisStore(cfg)
 	require.NoError(t, err)
-	namespace := "test-query-data"
+	defer func() {
+		_ = store.Close()
+	}()
+	namespace := "test-expire"
 	baseTable := "table1"
-	tenant := "123"
-	for i := 0; i < 200; i++ {
-		id := fmt.Sprintf("id_%d", i)
-		data := []byte(fmt.Sprintf("data_%d", i))
-		err = store.Create(baseTable, "", tenant, namespace, id, data, 0)
-		require.NoError(t, err)
+	tenant := uuid.NewV4().String()
+	toData := func(i int) []byte {
+		return []byte(fmt.Sprintf(`{"name":"name_%d"}`, i))
 	}
+	require.NoError(t, store.Create(baseTable, "", tenant, namespace, "id1", toData(1), 300*time.Millisecond))
+	require.NoError(t, store.Create(baseTable, "", tenant, namespace, "id2", toData(2), 0))
+	require.NoError(t, store.Create(baseTable, "", tenant, namespace, "id3", toData(3), time.Minute))
+	defer func() {
+		require.NoError(t, store.ClearTable(baseTable, "", tenant, namespace))
+	}()
+
+	// WHEN writing another record with expiry after the first record expires
+	time.Sleep(400 * time.Millisecond)
+	require.NoError(t, store.Update(baseTable, "", tenant, namespace, "id3", 1, toData(3), time.Minute))
+
+	// THEN only the expired record should not be found
+	_, err = store.Get(baseTable, "", tenant, namespace, "id1")
+	var notFoundErr *domain.NotFoundError
+	require.ErrorAs(t, err, &notFoundErr)
+	saved, err := store.Get(baseTable, "", tenant, namespace, "id2", "id3")
+	require.NoError(t, err)
+	require.Equal(t, 2, len(saved))
+	size, err := store.Size(baseTable, "", tenant, namespace)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), size)
 	res, _, err := store.Query(baseTable, "", tenant, namespace, nil, "", 0)
 	require.NoError(t, err)
-	require.Equal(t, 200, len(res))
-	res, _, err = store.Query(baseTable, "", tenant, namespace, map[string]string{}, "", 0)
+	require.Equal(t, 2, len(res))
+	res, _, err = store.Query(baseTable, "", tenant, namespace, map[string]string{"name": "name_1"}, "", 0)
 	require.NoError(t, err)
-	require.Equal(t, "data_0", string(res["id_0"]))
+	require.Equal(t, 0, len(res))
 
-	err = store.ClearTable(baseTable, "", tenant, namespace)
+	// AND expired record cannot be updated but can be created again
+	err = store.Update(baseTable, "", tenant, namespace, "id1", 1, toData(1), 0)
+	require.ErrorAs(t, err, &notFoundErr)
+	require.NoError(t, store.Create(baseTable, "", tenant, namespace, "id1", toData(1), 300*time.Millisecond))
+
+	// WHEN deleting expired records
+	time.Sleep(400 * time.Millisecond)
+	deleted, err := store.DeleteExpired()
 	require.NoError(t, err)
 
-	res, _, err = store.Query(baseTable, "", tenant, namespace, nil, "", 0)
+	// THEN expired record and its index entries should be removed
+	require.True(t, deleted >= 1)
+	conn := store.pool.Get()
+	defer func() {
+		_ = conn.Close()
+	}()
+	tableName := toTableName(baseTable, "", tenant, namespace)
+	exists, err := redis.Bool(conn.Do("HEXISTS", tableName, "id1"))
 	require.NoError(t, err)
-	require.Equal(t, 0, len(res))
+	require.False(t, exists)
+	indexed, err := redis.Int(conn.Do("ZCARD", toIndexName(tableName, "name")))
+	require.NoError(t, err)
+	require.Equal(t, 2, indexed)
+	size, err = store.Size(baseTable, "", tenant, namespace)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), size)
+}
+
+func Test_ShouldPageExpiringRecordsWithSameExpiry(t *testing.T) {
+	// GIVEN config and redis-service with more records of the same expiry than a batch
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	store, err := NewRedisStore(cfg)
+	require.NoError(t, err)
+	defer func() {
+		_ = store.Close()
+	}()
+	namespace := "test-expiring"
+	baseTable := "table1"
+	tenant := uuid.NewV4().String()
+	for i := 0; i < 250; i++ {
+		require.NoError(t, store.Create(baseTable, "", tenant, namespace, fmt.Sprintf("id_%03d", i), []byte("data"), time.Hour))
+	}
+	defer func() {
+		require.NoError(t, store.ClearTable(baseTable, "", tenant, namespace))
+	}()
+	conn := store.pool.Get()
+	defer func() {
+		_ = conn.Close()
+	}()
+	tableName := toTableName(baseTable, "", tenant, namespace)
+	expiresAt := time.Now().Add(time.Minute).UnixMilli()
+	for i := 0; i < 250; i++ {
+		_, err = conn.Do("ZADD", toExpiryTableName(tableName), expiresAt, fmt.Sprintf("id_%03d", i))
+		require.NoError(t, err)
+	}
+
+	// WHEN finding pages of expiring records
+	all := make(map[string]bool)
+	next := ""
+	for pages := 0; ; pages++ {
+		res, nextOffset, err := store.Expiring(baseTable, "", tenant, namespace, time.Now().Add(time.Hour), next, 70)
+		require.NoError(t, err)
+		for k := range res {
+			// THEN records should not be returned more than once
+			require.False(t, all[k], k)
+			all[k] = true
+		}
+		if nextOffset == "" {
+			break
+		}
+		next = nextOffset
+		require.True(t, pages < 10)
+	}
+	// AND each record should be returned
+	require.Equal(t, 250, len(all))
+
+	// AND invalid offsets should fail
+	for _, offset := range []string{"abc", "1:", "abc:aWQ", "1:*"} {
+		_, _, err = store.Expiring(baseTable, "", tenant, namespace, time.Now().Add(time.Hour), offset, 0)
+		require.Error(t, err, offset)
+	}
 }
@@ -131,6 +131,7 @@ func Test_ShouldSaveAndQueryRelationship(t *testing.T) {
 func buildTestRelationship(i int) types.Relationship {
 	return types.Relationship{
 		Id:          fmt.Sprintf("id_%d", i),
+		Version:     1,
 		Relation:    fmt.Sprintf("rel_%d", i),
 		PrincipalId: fmt.Sprintf("user_%d", i),
 		ResourceId:  fmt.Sprintf("res_%d", i),
@@ -44,6 +44,19 @@ type Repository[T any] interface {
 		expiration time.Duration,
 	) error
 
+	// Allocate - saves the object unless it is new and capacity of objects is exhausted, where version
+	// of existing object must match and 0 requires a new object.
+	Allocate(
+		ctx context.Context,
+		organizationID string,
+		namespace string,
+		id string,
+		version int64,
+		capacity int64,
+		obj *T,
+		expiration time.Duration,
+	) error
+
 	// Query - queries objects
 	Query(
 		ctx context.Context,
@@ -54,6 +67,16 @@ type Repository[T any] interface {
 		limit int64,
 	) (res []*T, nextOffset string, err error)
 
+	// Expiring - finds objects that expire at or before the given time
+	Expiring(
+		ctx context.Context,
+		organizationID string,
+		namespace string,
+		before time.Time,
+		offset string,
+		limit int64,
+	) (res []*T, nextOffset string, err error)
+
 	// Delete - remove the object
 	Delete(
 		ctx context.Context,
@@ -73,4 +96,6 @@ type Repository[T any] interface {
 // ResourceInstanceRepositoryFactory helper
 type ResourceInstanceRepositoryFactory interface {
 	CreateResourceInstanceRepository(resourceID string) (Repository[types.ResourceInstance], error)
+	// CreateReleasedResourceInstanceRepository for instances whose leases expired
+	CreateReleasedResourceInstanceRepository(resourceID string) (Repository[types.ResourceInstance], error)
 }
@@ -6,18 +6,22 @@ import (
 )
 
 type resourceInstanceRepository struct {
-	store      DataStore
-	expiration time.Duration
+	store              DataStore
+	expiration         time.Duration
+	releasedExpiration time.Duration
 }
 
-// NewResourceInstanceRepository creates repository for persisting resources instance
+// NewResourceInstanceRepository creates repository for persisting resources instance, where
+// released instances are kept for releasedExpiration.
 func NewResourceInstanceRepository(
 	store DataStore,
 	expiration time.Duration,
+	releasedExpiration time.Duration,
 ) (ResourceInstanceRepositoryFactory, error) {
 	return &resourceInstanceRepository{
-		store:      store,
-		expiration: expiration,
+		store:              store,
+		expiration:         expiration,
+		releasedExpiration: releasedExpiration,
 	}, nil
 }
 
@@ -34,3 +38,16 @@ func (r *resourceInstanceRepository) CreateResourceInstanceRepository(
 		})
 
 }
+
+func (r *resourceInstanceRepository) CreateReleasedResourceInstanceRepository(
+	resourceID string,
+) (Repository[types.ResourceInstance], error) {
+	return NewBaseRepository[types.ResourceInstance](
+		r.store,
+		"ReleasedResourceInstance",
+		resourceID,
+		r.releasedExpiration,
+		func() *types.ResourceInstance {
+			return &types.ResourceInstance{}
+		})
+}
@@ -19,7 +19,7 @@ func Test_ShouldSaveAndGetResourceInstance(t *testing.T) {
 	require.NoError(t, err)
 	store, err := redis.NewRedisStore(cfg)
 	require.NoError(t, err)
-	resourceRepository, err := NewResourceInstanceRepository(store, time.Second*1)
+	resourceRepository, err := NewResourceInstanceRepository(store, time.Second*1, time.Hour)
 	require.NoError(t, err)
 	instanceRepository, err := resourceRepository.CreateResourceInstanceRepository("r1")
 	require.NoError(t, err)
@@ -36,7 +36,7 @@ func Test_ShouldSaveAndGetResourceInstance(t *testing.T) {
 	require.Equal(t, instance.State, saved.State)
 
 	saved.Version = 2
-	err = instanceRepository.Update(ctx, testOrgId, namespace, instance.Id, saved.Version, saved, time.Duration(0))
+	err = instanceRepository.Update(ctx, testOrgId, namespace, instance.Id, 1, saved, time.Duration(0))
 	require.NoError(t, err)
 
 	saved, err = instanceRepository.GetByID(ctx, testOrgId, namespace, instance.Id)
@@ -55,7 +55,7 @@ func Test_ShouldSaveAndDeleteResourceInstance(t *testing.T) {
 	require.NoError(t, err)
 	store, err := redis.NewRedisStore(cfg)
 	require.NoError(t, err)
-	resourceRepository, err := NewResourceInstanceRepository(store, time.Second*1)
+	resourceRepository, err := NewResourceInstanceRepository(store, time.Second*1, time.Hour)
 	require.NoError(t, err)
 	instanceRepository, err := resourceRepository.CreateResourceInstanceRepository("r2")
 	require.NoError(t, err)
@@ -88,7 +88,7 @@ func Test_ShouldSaveAndQueryResourceInstance(t *testing.T) {
 	require.NoError(t, err)
 	testOrgId := uuid.NewV4().String()
 	namespace := "instance-query-namespace"
-	resourceRepository, err := NewResourceInstanceRepository(store, time.Second*1)
+	resourceRepository, err := NewResourceInstanceRepository(store, time.Second*1, time.Hour)
 	require.NoError(t, err)
 	instanceRepository, err := resourceRepository.CreateResourceInstanceRepository("r3")
 	require.NoError(t, err)
@@ -137,6 +137,7 @@ func Test_ShouldSaveAndQueryResourceInstance(t *testing.T) {
 func buildTestResourceInstance(i int) types.ResourceInstance {
 	return types.ResourceInstance{
 		Id:          fmt.Sprintf("id_%d", i),
+		Version:     1,
 		PrincipalId: fmt.Sprintf("user_%d", i),
 		State:       types.ResourceState_ALLOCATED,
 	}
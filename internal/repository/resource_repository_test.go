@@ -128,6 +128,7 @@ func Test_ShouldSaveAndQueryResource(t *testing.T) {
 func buildTestResource(i int) types.Resource {
 	return types.Resource{
 		Id:             fmt.Sprintf("id_%d", i),
+		Version:        1,
 		Name:           fmt.Sprintf("/file/%d", i),
 		Capacity:       int32(i + 1),
 		Attributes:     make(map[string]string),
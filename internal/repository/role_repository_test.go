@@ -116,6 +116,7 @@ func Test_ShouldSaveAndQueryRole(t *testing.T) {
 func buildTestRole(i int) types.Role {
 	return types.Role{
 		Id:            fmt.Sprintf("id_%d", i),
+		Version:       1,
 		Name:          fmt.Sprintf("/file/%d", i),
 		PermissionIds: []string{"1", "2"},
 		ParentIds:     []string{"1", "2"},
@@ -0,0 +1,706 @@
+package sql
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/utils"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	// registers postgres driver
+	_ "github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	// registers sqlite driver
+	_ "modernc.org/sqlite"
+)
+
+var identifierRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Store persists records in a table per base table with columns for tenant, namespace, id, version,
+// expiry and value, where updates with version are applied only if stored version matches.
+type Store struct {
+	config *domain.SQLConfig
+	db     *sql.DB
+	tables map[string]bool
+	lock   sync.RWMutex
+	done   chan bool
+	wg     sync.WaitGroup
+}
+
+// NewSQLStore constructor for SQL store, which deletes expired records in background.
+func NewSQLStore(
+	config *domain.Config,
+) (*Store, error) {
+	if err := config.SQL.Validate(); err != nil {
+		return nil, err
+	}
+	driver := "sqlite"
+	dataSource := config.SQL.DataSource
+	if config.SQL.Dialect == domain.PostgresDialect {
+		driver = "postgres"
+	} else if !strings.Contains(dataSource, "_pragma") {
+		separator := "?"
+		if strings.Contains(dataSource, "?") {
+			separator = "&"
+		}
+		dataSource += separator + "_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	}
+	db, err := sql.Open(driver, dataSource)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(config.SQL.MaxOpenConns)
+	if err = db.Ping(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	logrus.WithFields(
+		logrus.Fields{
+			"Component": "SQLStore",
+			"Dialect":   config.SQL.Dialect,
+		}).Debugf("connected to SQL database")
+	store := &Store{
+		config: &config.SQL,
+		db:     db,
+		tables: make(map[string]bool),
+		done:   make(chan bool),
+	}
+	store.wg.Add(1)
+	go store.cleanup()
+	return store, nil
+}
+
+// Close stops deleting expired records and closes the database.
+func (r *Store) Close() error {
+	close(r.done)
+	r.wg.Wait()
+	return r.db.Close()
+}
+
+// CreateTable creates table and index of expiry if needed.
+func (r *Store) CreateTable(
+	baseTableName string,
+	_ string, // suffix is stored in tenant column
+) (err error) {
+	table, err := toTableName(baseTableName)
+	if err != nil {
+		return err
+	}
+	r.lock.RLock()
+	exists := r.tables[table]
+	r.lock.RUnlock()
+	if exists {
+		return nil
+	}
+	if _, err = r.db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		tenant TEXT NOT NULL,
+		namespace TEXT NOT NULL,
+		id TEXT NOT NULL,
+		version BIGINT NOT NULL,
+		expires_at BIGINT NOT NULL DEFAULT 0,
+		value TEXT NOT NULL,
+		PRIMARY KEY (tenant, namespace, id))`, table)); err != nil {
+		return err
+	}
+	if _, err = r.db.Exec(fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (expires_at)`,
+		quote(strings.Trim(table, `"`)+"_expires_at"), table)); err != nil {
+		return err
+	}
+	if _, err = r.db.Exec(fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (tenant, namespace, expires_at)`,
+		quote(strings.Trim(table, `"`)+"_tenant_expires_at"), table)); err != nil {
+		return err
+	}
+	r.lock.Lock()
+	r.tables[table] = true
+	r.lock.Unlock()
+	return nil
+}
+
+// Size returns number of live records for tenant in table.
+func (r *Store) Size(
+	baseTableName string,
+	baseTableSuffix string,
+	tenant string,
+	namespace string,
+) (size int64, err error) {
+	table, err := r.table(baseTableName)
+	if err != nil {
+		return 0, err
+	}
+	err = r.db.QueryRow(r.rebind(fmt.Sprintf(
+		`SELECT COUNT(*) FROM %s WHERE tenant = ? AND namespace = ? AND (expires_at = 0 OR expires_at > ?)`, table)),
+		toTenant(baseTableName, baseTableSuffix, tenant), namespace, time.Now().Unix()).Scan(&size)
+	return
+}
+
+// Get finds records by ids.
+func (r *Store) Get(
+	baseTableName string,
+	baseTableSuffix string,
+	tenant string,
+	namespace string,
+	ids ...string,
+) (res map[string][]byte, err error) {
+	table, err := r.table(baseTableName)
+	if err != nil {
+		return nil, err
+	}
+	res = make(map[string][]byte)
+	if len(ids) == 0 {
+		return
+	}
+	args := []interface{}{toTenant(baseTableName, baseTableSuffix, tenant), namespace, time.Now().Unix()}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	rows, err := r.db.Query(r.rebind(fmt.Sprintf(
+		`SELECT id, value FROM %s WHERE tenant = ? AND namespace = ? AND (expires_at = 0 OR expires_at > ?) AND id IN (%s)`,
+		table, strings.TrimSuffix(strings.Repeat("?,", len(ids)), ","))), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		var id string
+		var value string
+		if err = rows.Scan(&id, &value); err != nil {
+			return nil, err
+		}
+		res[id] = []byte(value)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if res[id] == nil {
+			return nil, domain.NewNotFoundError(
+				fmt.Sprintf("failed to get object for id %s in %s", id, baseTableName))
+		}
+	}
+	return
+}
+
+// Query searches records by predicates in the order of ids, where predicates on strings are evaluated
+// with JSON operators of the database and all predicates are matched again for the same semantics as
+// other stores. Records are read in batches of the limit, and more batches are read when records are
+// filtered out by predicates that are only matched after reading. The id of the last record is returned
+// as next key when the limit is reached.
+func (r *Store) Query(
+	baseTableName string,
+	baseTableSuffix string,
+	tenant string,
+	namespace string,
+	predicate map[string]string,
+	lastEvaluatedKeyStr string,
+	limit int64,
+) (res map[string][]byte, nextKeyStr string, err error) {
+	table, err := r.table(baseTableName)
+	if err != nil {
+		return nil, "", err
+	}
+	res = make(map[string][]byte)
+	query := fmt.Sprintf(
+		`SELECT id, value FROM %s WHERE tenant = ? AND namespace = ? AND (expires_at = 0 OR expires_at > ?) AND id > ?`,
+		table)
+	conditions, conditionArgs := r.toConditions(predicate)
+	for _, condition := range conditions {
+		query += " AND " + condition
+	}
+	query += " ORDER BY id"
+	if limit > 0 {
+		query += " LIMIT ?"
+	}
+	after := lastEvaluatedKeyStr
+	for {
+		args := []interface{}{toTenant(baseTableName, baseTableSuffix, tenant), namespace, time.Now().Unix(), after}
+		args = append(args, conditionArgs...)
+		if limit > 0 {
+			args = append(args, limit)
+		}
+		var read int64
+		if read, after, nextKeyStr, err = r.queryBatch(query, args, predicate, limit, res); err != nil {
+			return nil, "", err
+		}
+		if nextKeyStr != "" || limit <= 0 || read < limit {
+			return res, nextKeyStr, nil
+		}
+	}
+}
+
+// queryBatch adds matching records of the batch to the result and returns number of read records,
+// id of the last read record and the next key if the limit is reached.
+func (r *Store) queryBatch(
+	query string,
+	args []interface{},
+	predicate map[string]string,
+	limit int64,
+	res map[string][]byte,
+) (read int64, lastID string, nextKeyStr string, err error) {
+	rows, err := r.db.Query(r.rebind(query), args...)
+	if err != nil {
+		return 0, "", "", err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		var id string
+		var value string
+		if err = rows.Scan(&id, &value); err != nil {
+			return 0, "", "", err
+		}
+		read++
+		lastID = id
+		if !utils.MatchPredicate([]byte(value), predicate) {
+			continue
+		}
+		res[id] = []byte(value)
+		if limit > 0 && int64(len(res)) >= limit {
+			return read, lastID, id, nil
+		}
+	}
+	return read, lastID, "", rows.Err()
+}
+
+// Expiring finds live records that expire at or before the given time in the order of their expiry with
+// the index of expiry, where the expiry and id of the last record are returned as next key when the
+// limit is reached.
+func (r *Store) Expiring(
+	baseTableName string,
+	baseTableSuffix string,
+	tenant string,
+	namespace string,
+	before time.Time,
+	lastEvaluatedKeyStr string,
+	limit int64,
+) (res map[string][]byte, nextKeyStr string, err error) {
+	table, err := r.table(baseTableName)
+	if err != nil {
+		return nil, "", err
+	}
+	lastExpiresAt, lastID, err := parseExpiringKey(baseTableName, lastEvaluatedKeyStr)
+	if err != nil {
+		return nil, "", err
+	}
+	query := fmt.Sprintf(
+		`SELECT id, value, expires_at FROM %s WHERE tenant = ? AND namespace = ? AND expires_at > ? AND expires_at <= ?
+		AND (expires_at > ? OR (expires_at = ? AND id > ?)) ORDER BY expires_at, id`, table)
+	args := []interface{}{toTenant(baseTableName, baseTableSuffix, tenant), namespace, time.Now().Unix(),
+		before.Unix(), lastExpiresAt, lastExpiresAt, lastID}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	rows, err := r.db.Query(r.rebind(query), args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	res = make(map[string][]byte)
+	for rows.Next() {
+		var id string
+		var value string
+		var expiresAt int64
+		if err = rows.Scan(&id, &value, &expiresAt); err != nil {
+			return nil, "", err
+		}
+		res[id] = []byte(value)
+		if limit > 0 && int64(len(res)) >= limit {
+			nextKeyStr = fmt.Sprintf("%d:%s", expiresAt, id)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, "", err
+	}
+	return
+}
+
+// Create adds a new record, which fails if a live record with same id already exists.
+func (r *Store) Create(
+	baseTableName string,
+	baseTableSuffix string,
+	tenant string,
+	namespace string,
+	id string,
+	value []byte,
+	expiration time.Duration) (err error) {
+	table, err := r.table(baseTableName)
+	if err != nil {
+		return err
+	}
+	now := time.Now().Unix()
+	res, err := r.db.Exec(r.rebind(fmt.Sprintf(
+		`INSERT INTO %s (tenant, namespace, id, version, expires_at, value) VALUES (?, ?, ?, 1, ?, ?)
+		ON CONFLICT (tenant, namespace, id) DO UPDATE SET version = 1, expires_at = excluded.expires_at, value = excluded.value
+		WHERE %s.expires_at > 0 AND %s.expires_at <= ?`, table, table, table)),
+		toTenant(baseTableName, baseTableSuffix, tenant), namespace, id, toExpiresAt(expiration), string(value), now)
+	if err != nil {
+		return err
+	}
+	if affected, err := res.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return domain.NewDuplicateError(
+			fmt.Sprintf("object with id %s already exists in %s", id, baseTableName))
+	}
+	return nil
+}
+
+// Update changes existing record if its version matches, or writes the record regardless of the
+// version when version is negative.
+func (r *Store) Update(
+	baseTableName string,
+	baseTableSuffix string,
+	tenant string,
+	namespace string,
+	id string,
+	version int64,
+	value []byte,
+	expiration time.Duration) (err error) {
+	table, err := r.table(baseTableName)
+	if err != nil {
+		return err
+	}
+	expiresAt := "expires_at"
+	args := []interface{}{string(value)}
+	if expiration.Seconds() > 0 {
+		expiresAt = "?"
+		args = append(args, toExpiresAt(expiration))
+	}
+	if version < 0 {
+		_, err = r.db.Exec(r.rebind(fmt.Sprintf(
+			`INSERT INTO %s (tenant, namespace, id, version, expires_at, value) VALUES (?, ?, ?, 1, ?, ?)
+			ON CONFLICT (tenant, namespace, id) DO UPDATE SET version = %s.version + 1, value = excluded.value, expires_at = %s`,
+			table, table, strings.Replace(expiresAt, "expires_at", table+".expires_at", 1))),
+			append([]interface{}{toTenant(baseTableName, baseTableSuffix, tenant), namespace, id, toExpiresAt(expiration), string(value)},
+				args[1:]...)...)
+		return err
+	}
+	args = append(args, toTenant(baseTableName, baseTableSuffix, tenant), namespace, id, version, time.Now().Unix())
+	res, err := r.db.Exec(r.rebind(fmt.Sprintf(
+		`UPDATE %s SET version = version + 1, value = ?, expires_at = %s
+		WHERE tenant = ? AND namespace = ? AND id = ? AND version = ? AND (expires_at = 0 OR expires_at > ?)`,
+		table, expiresAt)), args...)
+	if err != nil {
+		return err
+	}
+	if affected, err := res.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		if _, err = r.Get(baseTableName, baseTableSuffix, tenant, namespace, id); err != nil {
+			return err
+		}
+		return domain.NewConflictError(
+			fmt.Sprintf("version %d of object %s in %s is stale", version, id, baseTableName))
+	}
+	return nil
+}
+
+// Allocate changes live record or adds record if number of live records is less than capacity. The
+// record is written before counting so that SQLite holds the write lock, and PostgreSQL serializes
+// allocations of the tenant with an advisory lock of the transaction.
+func (r *Store) Allocate(
+	baseTableName string,
+	baseTableSuffix string,
+	tenant string,
+	namespace string,
+	id string,
+	version int64,
+	capacity int64,
+	value []byte,
+	expiration time.Duration) (err error) {
+	table, err := r.table(baseTableName)
+	if err != nil {
+		return err
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	tenantKey := toTenant(baseTableName, baseTableSuffix, tenant)
+	if r.config.Dialect == domain.PostgresDialect {
+		if _, err = tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`,
+			strings.Join([]string{table, tenantKey, namespace}, "/")); err != nil {
+			return err
+		}
+	}
+	now := time.Now().Unix()
+	expiresAt := "expires_at"
+	args := []interface{}{string(value)}
+	if expiration.Seconds() > 0 {
+		expiresAt = "?"
+		args = append(args, toExpiresAt(expiration))
+	}
+	stale := domain.NewConflictError(
+		fmt.Sprintf("version %d of object %s in %s is stale", version, id, baseTableName))
+	if version == 0 {
+		// live record is touched without changing it so that SQLite holds the write lock while counting
+		res, err := tx.Exec(r.rebind(fmt.Sprintf(
+			`UPDATE %s SET version = version
+			WHERE tenant = ? AND namespace = ? AND id = ? AND (expires_at = 0 OR expires_at > ?)`, table)),
+			tenantKey, namespace, id, now)
+		if err != nil {
+			return err
+		}
+		if affected, err := res.RowsAffected(); err != nil {
+			return err
+		} else if affected > 0 {
+			return stale
+		}
+	} else {
+		// live record is changed if its version matches or version is negative
+		condition := ""
+		args = append(args, tenantKey, namespace, id, now)
+		if version > 0 {
+			condition = " AND version = ?"
+			args = append(args, version)
+		}
+		res, err := tx.Exec(r.rebind(fmt.Sprintf(
+			`UPDATE %s SET version = version + 1, value = ?, expires_at = %s
+			WHERE tenant = ? AND namespace = ? AND id = ? AND (expires_at = 0 OR expires_at > ?)%s`,
+			table, expiresAt, condition)), args...)
+		if err != nil {
+			return err
+		}
+		if affected, err := res.RowsAffected(); err != nil || affected > 0 {
+			return err
+		}
+		if version > 0 {
+			return stale
+		}
+	}
+	var size int64
+	if err = tx.QueryRow(r.rebind(fmt.Sprintf(
+		`SELECT COUNT(*) FROM %s WHERE tenant = ? AND namespace = ? AND (expires_at = 0 OR expires_at > ?)`, table)),
+		tenantKey, namespace, now).Scan(&size); err != nil {
+		return err
+	}
+	if size >= capacity {
+		return domain.NewValidationError(
+			fmt.Sprintf("usage %d exceeds capacity %d", size, capacity))
+	}
+	// new record replaces expired record but not a live record of a concurrent allocation
+	res, err := tx.Exec(r.rebind(fmt.Sprintf(
+		`INSERT INTO %s (tenant, namespace, id, version, expires_at, value) VALUES (?, ?, ?, 1, ?, ?)
+		ON CONFLICT (tenant, namespace, id) DO UPDATE SET version = 1, expires_at = excluded.expires_at, value = excluded.value
+		WHERE %s.expires_at > 0 AND %s.expires_at <= ?`, table, table, table)),
+		tenantKey, namespace, id, toExpiresAt(expiration), string(value), now)
+	if err != nil {
+		return err
+	}
+	if affected, err := res.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return stale
+	}
+	return nil
+}
+
+// Delete removes existing record.
+func (r *Store) Delete(
+	baseTableName string,
+	baseTableSuffix string,
+	tenant string,
+	namespace string,
+	id string,
+) (err error) {
+	table, err := r.table(baseTableName)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(r.rebind(fmt.Sprintf(
+		`DELETE FROM %s WHERE tenant = ? AND namespace = ? AND id = ?`, table)),
+		toTenant(baseTableName, baseTableSuffix, tenant), namespace, id)
+	return
+}
+
+// ClearTable removes all records for tenant in table.
+func (r *Store) ClearTable(
+	baseTableName string,
+	baseTableSuffix string,
+	tenant string,
+	namespace string,
+) (err error) {
+	table, err := r.table(baseTableName)
+	if err != nil {
+		return err
+	}
+	res, err := r.db.Exec(r.rebind(fmt.Sprintf(
+		`DELETE FROM %s WHERE tenant = ? AND namespace = ?`, table)),
+		toTenant(baseTableName, baseTableSuffix, tenant), namespace)
+	if err != nil {
+		return err
+	}
+	deleted, _ := res.RowsAffected()
+	logrus.WithFields(logrus.Fields{
+		"Component": "SQLStore",
+		"Table":     table,
+		"Deleted":   deleted,
+	}).
+		Debugf("deleting all objects in table")
+	return
+}
+
+// DeleteExpired removes expired records from all tables.
+func (r *Store) DeleteExpired() (deleted int64, err error) {
+	r.lock.RLock()
+	tables := make([]string, 0, len(r.tables))
+	for table := range r.tables {
+		tables = append(tables, table)
+	}
+	r.lock.RUnlock()
+	sort.Strings(tables)
+	for _, table := range tables {
+		res, err := r.db.Exec(r.rebind(fmt.Sprintf(
+			`DELETE FROM %s WHERE expires_at > 0 AND expires_at <= ?`, table)), time.Now().Unix())
+		if err != nil {
+			return deleted, err
+		}
+		affected, _ := res.RowsAffected()
+		deleted += affected
+	}
+	return
+}
+
+func (r *Store) cleanup() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(r.config.CleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			if deleted, err := r.DeleteExpired(); err != nil {
+				logrus.WithFields(logrus.Fields{
+					"Component": "SQLStore",
+					"Error":     err,
+				}).Warnf("failed to delete expired objects")
+			} else if deleted > 0 {
+				logrus.WithFields(logrus.Fields{
+					"Component": "SQLStore",
+					"Deleted":   deleted,
+				}).Debugf("deleted expired objects")
+			}
+		}
+	}
+}
+
+func (r *Store) table(baseTableName string) (string, error) {
+	if err := r.CreateTable(baseTableName, ""); err != nil {
+		return "", err
+	}
+	return toTableName(baseTableName)
+}
+
+// toConditions converts predicates on strings into conditions with JSON operators, where predicates
+// on numbers and booleans are only matched after reading because their text differs across databases.
+func (r *Store) toConditions(predicate map[string]string) (conditions []string, args []interface{}) {
+	keys := make([]string, 0, len(predicate))
+	for k := range predicate {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := predicate[k]
+		field, op := utils.ParsePredicateKey(k)
+		if !identifierRegex.MatchString(field) || v == "true" || v == "false" {
+			continue
+		}
+		if _, err := strconv.ParseFloat(v, 64); err == nil {
+			continue
+		}
+		if op == "==" {
+			op = "="
+		}
+		if r.config.Dialect == domain.PostgresDialect {
+			conditions = append(conditions, fmt.Sprintf(`(value::jsonb ->> '%s') COLLATE "C" %s ?`, field, op))
+		} else {
+			conditions = append(conditions, fmt.Sprintf(`CASE WHEN json_valid(value) THEN json_extract(value, '$.%s') END %s ?`, field, op))
+		}
+		args = append(args, v)
+	}
+	return
+}
+
+// rebind converts placeholders into numbered parameters of PostgreSQL.
+func (r *Store) rebind(query string) string {
+	if r.config.Dialect != domain.PostgresDialect {
+		return query
+	}
+	var sb strings.Builder
+	n := 0
+	for _, ch := range query {
+		if ch == '?' {
+			n++
+			sb.WriteString("$" + strconv.Itoa(n))
+		} else {
+			sb.WriteRune(ch)
+		}
+	}
+	return sb.String()
+}
+
+// parseExpiringKey returns expiry and id of the last record returned by Expiring.
+func parseExpiringKey(baseTableName string, lastEvaluatedKeyStr string) (expiresAt int64, id string, err error) {
+	if lastEvaluatedKeyStr == "" {
+		return 0, "", nil
+	}
+	parts := strings.SplitN(lastEvaluatedKeyStr, ":", 2)
+	if len(parts) == 2 {
+		expiresAt, err = strconv.ParseInt(parts[0], 10, 64)
+	}
+	if len(parts) != 2 || err != nil {
+		return 0, "", domain.NewValidationError(
+			fmt.Sprintf("invalid offset %s for %s", lastEvaluatedKeyStr, baseTableName))
+	}
+	return expiresAt, parts[1], nil
+}
+
+func toTableName(baseTableName string) (string, error) {
+	if !identifierRegex.MatchString(baseTableName) {
+		return "", domain.NewValidationError(fmt.Sprintf("invalid table name %s", baseTableName))
+	}
+	return quote(baseTableName), nil
+}
+
+func quote(name string) string {
+	return `"` + name + `"`
+}
+
+func toTenant(
+	baseTableName string,
+	baseTableSuffix string,
+	organizationID string,
+) string {
+	if baseTableName == "Organization" {
+		// organizations are shared across tenants as in redis store
+		return ""
+	} else if baseTableSuffix == "" {
+		return organizationID
+	}
+	return fmt.Sprintf("%s__%s", organizationID, baseTableSuffix)
+}
+
+func toExpiresAt(expiration time.Duration) int64 {
+	if expiration.Seconds() <= 0 {
+		return 0
+	}
+	return time.Now().Add(expiration).Unix()
+}
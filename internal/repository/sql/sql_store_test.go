@@ -0,0 +1,66 @@
+package sql
+
+import (
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/repository"
+	"github.com/bhatti/PlexAuthZ/internal/repository/storetest"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test_ShouldPassDataStoreSuite runs the suite with SQLite store and with PostgreSQL store when the data
+// source of a test database is defined in PLEXAUTHZ_TEST_POSTGRES environment variable.
+func Test_ShouldPassDataStoreSuite(t *testing.T) {
+	t.Run("sqlite", func(t *testing.T) {
+		storetest.Run(t, func(t *testing.T) repository.DataStore {
+			return newTestStore(t, domain.SQLiteDialect, filepath.Join(t.TempDir(), "plexauthz.db"))
+		})
+	})
+	t.Run("postgres", func(t *testing.T) {
+		dataSource := os.Getenv("PLEXAUTHZ_TEST_POSTGRES")
+		if dataSource == "" {
+			t.Skip("PLEXAUTHZ_TEST_POSTGRES is not defined")
+		}
+		storetest.Run(t, func(t *testing.T) repository.DataStore {
+			return newTestStore(t, domain.PostgresDialect, dataSource)
+		})
+	})
+}
+
+func Test_ShouldBuildPostgresStatements(t *testing.T) {
+	// GIVEN store of PostgreSQL dialect
+	store := &Store{config: &domain.SQLConfig{Dialect: domain.PostgresDialect}}
+
+	// WHEN rebinding placeholders
+	// THEN they should be numbered parameters
+	require.Equal(t, "SELECT 1 WHERE a = $1 AND b IN ($2,$3)", store.rebind("SELECT 1 WHERE a = ? AND b IN (?,?)"))
+
+	// WHEN converting predicates
+	conditions, args := store.toConditions(map[string]string{"name:<": "x", "kind": "k", "size:>=": "1", "bad-field": "v"})
+
+	// THEN strings should be compared with JSON operators in byte order and numbers only after reading
+	require.Equal(t, []string{
+		`(value::jsonb ->> 'kind') COLLATE "C" = ?`,
+		`(value::jsonb ->> 'name') COLLATE "C" < ?`,
+	}, conditions)
+	require.Equal(t, []interface{}{"k", "x"}, args)
+}
+
+func newTestStore(t *testing.T, dialect domain.SQLDialect, dataSource string) *Store {
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	cfg.SQL.Dialect = dialect
+	cfg.SQL.DataSource = dataSource
+	store, err := NewSQLStore(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		// tables of the test are dropped as PostgreSQL database is shared by test runs
+		for table := range store.tables {
+			_, _ = store.db.Exec("DROP TABLE IF EXISTS " + table)
+		}
+		_ = store.Close()
+	})
+	return store
+}
@@ -0,0 +1,364 @@
+package storetest
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/repository"
+	"github.com/stretchr/testify/require"
+	"github.com/twinj/uuid"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// expiredDeleter is implemented by data stores that delete expired records in background.
+type expiredDeleter interface {
+	DeleteExpired() (deleted int64, err error)
+}
+
+// Run runs tests of the DataStore contract against stores created by newStore, which is shared by
+// all implementations so that they have the same semantics. Each test uses its own table and tenant
+// so that stores of shared databases such as Redis and PostgreSQL don't see records of other runs.
+func Run(t *testing.T, newStore func(t *testing.T) repository.DataStore) {
+	for name, test := range map[string]func(t *testing.T, store repository.DataStore){
+		"SaveAndGetData":                       testSaveAndGetData,
+		"SaveAndDeleteData":                    testSaveAndDeleteData,
+		"SaveAndQueryData":                     testSaveAndQueryData,
+		"QueryMoreBatchesWhenFiltered":         testQueryMoreBatchesWhenFiltered,
+		"RejectDuplicateAndStaleWrites":        testRejectDuplicateAndStaleWrites,
+		"AllowSingleConcurrentUpdateOfVersion": testAllowSingleConcurrentUpdateOfVersion,
+		"ExpireData":                           testExpireData,
+		"FindExpiringData":                     testFindExpiringData,
+		"NotExceedCapacityWithAllocations":     testNotExceedCapacityWithAllocations,
+	} {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			test(t, newStore(t))
+		})
+	}
+}
+
+// NewTable returns unique name of table so that test runs don't share records.
+func NewTable() string {
+	return "table_" + strings.ReplaceAll(uuid.NewV4().String(), "-", "")
+}
+
+func testSaveAndGetData(t *testing.T, store repository.DataStore) {
+	// GIVEN a record
+	baseTable, tenant, namespace := newTable(t, store)
+	require.NoError(t, store.Create(baseTable, "", tenant, namespace, "id1", []byte("data1"), 0))
+
+	// WHEN getting and updating the record
+	saved, err := store.Get(baseTable, "", tenant, namespace, "id1")
+	require.NoError(t, err)
+	require.Equal(t, "data1", string(saved["id1"]))
+	require.NoError(t, store.Update(baseTable, "", tenant, namespace, "id1", 1, []byte("data2"), 0))
+
+	// THEN latest record should be returned
+	saved, err = store.Get(baseTable, "", tenant, namespace, "id1")
+	require.NoError(t, err)
+	require.Equal(t, "data2", string(saved["id1"]))
+	count, err := store.Size(baseTable, "", tenant, namespace)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), count)
+
+	// AND suffix should keep records separate
+	count, err = store.Size(baseTable, "suffix", tenant, namespace)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), count)
+}
+
+func testSaveAndDeleteData(t *testing.T, store repository.DataStore) {
+	// GIVEN a record
+	baseTable, tenant, namespace := newTable(t, store)
+	require.NoError(t, store.Create(baseTable, "", tenant, namespace, "id1", []byte("data1"), 0))
+	_, err := store.Get(baseTable, "", tenant, namespace, "id1")
+	require.NoError(t, err)
+
+	// WHEN deleting the record
+	require.NoError(t, store.Delete(baseTable, "", tenant, namespace, "id1"))
+
+	// THEN it should not be found
+	_, err = store.Get(baseTable, "", tenant, namespace, "id1")
+	var notFoundErr *domain.NotFoundError
+	require.ErrorAs(t, err, &notFoundErr)
+}
+
+func testSaveAndQueryData(t *testing.T, store repository.DataStore) {
+	// GIVEN records with strings and numbers
+	baseTable, tenant, namespace := newTable(t, store)
+	for i := 0; i < 200; i++ {
+		data := []byte(fmt.Sprintf(`{"name":"name_%03d","kind":"k%d","size":%d}`, i, i%2, i))
+		require.NoError(t, store.Create(baseTable, "", tenant, namespace, fmt.Sprintf("id_%03d", i), data, 0))
+	}
+	res, _, err := store.Query(baseTable, "", tenant, namespace, nil, "", 0)
+	require.NoError(t, err)
+	require.Equal(t, 200, len(res))
+
+	// WHEN querying with predicates on strings and numbers
+	res, _, err = store.Query(baseTable, "", tenant, namespace,
+		map[string]string{"kind": "k1", "size:>=": "150", "name:<": "name_160"}, "", 0)
+	// THEN only matching records should be returned
+	require.NoError(t, err)
+	require.Equal(t, 5, len(res))
+	require.NotNil(t, res["id_151"])
+
+	// WHEN querying pages
+	all := make(map[string][]byte)
+	next := ""
+	for pages := 0; ; pages++ {
+		res, next, err = store.Query(baseTable, "", tenant, namespace,
+			map[string]string{"kind": "k0"}, next, 30)
+		require.NoError(t, err)
+		require.True(t, len(res) <= 30)
+		for k, v := range res {
+			all[k] = v
+		}
+		if next == "" {
+			break
+		}
+		require.True(t, pages < 10)
+	}
+	// THEN all matching records should be returned once
+	require.Equal(t, 100, len(all))
+
+	// WHEN clearing the table
+	require.NoError(t, store.ClearTable(baseTable, "", tenant, namespace))
+	// THEN no records should be returned
+	res, _, err = store.Query(baseTable, "", tenant, namespace, nil, "", 0)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(res))
+}
+
+func testQueryMoreBatchesWhenFiltered(t *testing.T, store repository.DataStore) {
+	// GIVEN records where only the last ones match predicate
+	baseTable, tenant, namespace := newTable(t, store)
+	for i := 0; i < 100; i++ {
+		data := []byte(fmt.Sprintf(`{"size":%d}`, i))
+		require.NoError(t, store.Create(baseTable, "", tenant, namespace, fmt.Sprintf("id_%03d", i), data, 0))
+	}
+
+	// WHEN querying pages with a limit smaller than the filtered records
+	all := make(map[string][]byte)
+	next := ""
+	for pages := 0; ; pages++ {
+		res, nextOffset, err := store.Query(baseTable, "", tenant, namespace,
+			map[string]string{"size:>=": "90"}, next, 5)
+		require.NoError(t, err)
+		require.True(t, len(res) <= 5)
+		for k, v := range res {
+			all[k] = v
+		}
+		if nextOffset == "" {
+			break
+		}
+		next = nextOffset
+		require.True(t, pages < 5)
+	}
+
+	// THEN pages should be filled with matching records
+	require.Equal(t, 10, len(all))
+	require.NotNil(t, all["id_090"])
+	require.NotNil(t, all["id_099"])
+}
+
+func testRejectDuplicateAndStaleWrites(t *testing.T, store repository.DataStore) {
+	// GIVEN a record
+	baseTable, tenant, namespace := newTable(t, store)
+	require.NoError(t, store.Create(baseTable, "", tenant, namespace, "id1", []byte("data1"), 0))
+
+	// WHEN creating the record again
+	err := store.Create(baseTable, "", tenant, namespace, "id1", []byte("data2"), 0)
+	// THEN it should fail
+	var duplicateErr *domain.DuplicateError
+	require.ErrorAs(t, err, &duplicateErr)
+
+	// WHEN updating with current and then stale version
+	require.NoError(t, store.Update(baseTable, "", tenant, namespace, "id1", 1, []byte("data2"), 0))
+	err = store.Update(baseTable, "", tenant, namespace, "id1", 1, []byte("data3"), 0)
+	// THEN stale update should fail with conflict without changing the record
+	var conflictErr *domain.ConflictError
+	require.ErrorAs(t, err, &conflictErr)
+	saved, err := store.Get(baseTable, "", tenant, namespace, "id1")
+	require.NoError(t, err)
+	require.Equal(t, "data2", string(saved["id1"]))
+
+	// AND update of missing record should not be found
+	err = store.Update(baseTable, "", tenant, namespace, "id2", 1, []byte("data1"), 0)
+	var notFoundErr *domain.NotFoundError
+	require.ErrorAs(t, err, &notFoundErr)
+
+	// AND update without version should write the record
+	require.NoError(t, store.Update(baseTable, "", tenant, namespace, "id2", -1, []byte("data1"), 0))
+	require.NoError(t, store.Update(baseTable, "", tenant, namespace, "id2", -1, []byte("data2"), 0))
+	saved, err = store.Get(baseTable, "", tenant, namespace, "id2")
+	require.NoError(t, err)
+	require.Equal(t, "data2", string(saved["id2"]))
+}
+
+func testAllowSingleConcurrentUpdateOfVersion(t *testing.T, store repository.DataStore) {
+	// GIVEN a record
+	baseTable, tenant, namespace := newTable(t, store)
+	require.NoError(t, store.Create(baseTable, "", tenant, namespace, "id1", []byte("data0"), 0))
+
+	// WHEN updating the same version concurrently
+	var wg sync.WaitGroup
+	var succeeded int32
+	var conflicted int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := store.Update(baseTable, "", tenant, namespace, "id1", 1, []byte(fmt.Sprintf("data%d", i)), 0)
+			var conflictErr *domain.ConflictError
+			if err == nil {
+				atomic.AddInt32(&succeeded, 1)
+			} else if errors.As(err, &conflictErr) {
+				atomic.AddInt32(&conflicted, 1)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// THEN only one update should succeed
+	require.Equal(t, int32(1), succeeded)
+	require.Equal(t, int32(9), conflicted)
+}
+
+func testExpireData(t *testing.T, store repository.DataStore) {
+	// GIVEN an expiring and a permanent record
+	baseTable, tenant, namespace := newTable(t, store)
+	require.NoError(t, store.Create(baseTable, "", tenant, namespace, "id1", []byte("data1"), time.Second))
+	require.NoError(t, store.Create(baseTable, "", tenant, namespace, "id2", []byte("data2"), 0))
+
+	// WHEN the record expires
+	time.Sleep(2100 * time.Millisecond)
+
+	// THEN it should not be found
+	_, err := store.Get(baseTable, "", tenant, namespace, "id1")
+	var notFoundErr *domain.NotFoundError
+	require.ErrorAs(t, err, &notFoundErr)
+	count, err := store.Size(baseTable, "", tenant, namespace)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), count)
+	res, _, err := store.Query(baseTable, "", tenant, namespace, nil, "", 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(res))
+
+	// AND it cannot be updated but can be created again
+	err = store.Update(baseTable, "", tenant, namespace, "id1", 1, []byte("data3"), 0)
+	require.ErrorAs(t, err, &notFoundErr)
+	require.NoError(t, store.Create(baseTable, "", tenant, namespace, "id1", []byte("data3"), 0))
+
+	// AND expired records should be deleted
+	deleter, ok := store.(expiredDeleter)
+	if !ok {
+		return
+	}
+	require.NoError(t, store.Create(baseTable, "", tenant, namespace, "id3", []byte("data3"), time.Second))
+	time.Sleep(2100 * time.Millisecond)
+	deleted, err := deleter.DeleteExpired()
+	require.NoError(t, err)
+	require.True(t, deleted >= 1)
+	count, err = store.Size(baseTable, "", tenant, namespace)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), count)
+}
+
+func testFindExpiringData(t *testing.T, store repository.DataStore) {
+	// GIVEN records expiring soon, records expiring later and permanent records
+	baseTable, tenant, namespace := newTable(t, store)
+	for i := 0; i < 150; i++ {
+		require.NoError(t, store.Create(baseTable, "", tenant, namespace,
+			fmt.Sprintf("soon_%03d", i), []byte("data"), time.Minute+time.Duration(i%3)*time.Second))
+	}
+	for i := 0; i < 10; i++ {
+		require.NoError(t, store.Create(baseTable, "", tenant, namespace,
+			fmt.Sprintf("later_%03d", i), []byte("data"), time.Hour))
+		require.NoError(t, store.Create(baseTable, "", tenant, namespace,
+			fmt.Sprintf("permanent_%03d", i), []byte("data"), 0))
+	}
+
+	// WHEN finding pages of records expiring soon while deleting records of each page
+	all := make(map[string][]byte)
+	next := ""
+	for pages := 0; ; pages++ {
+		res, nextOffset, err := store.Expiring(baseTable, "", tenant, namespace,
+			time.Now().Add(10*time.Minute), next, 40)
+		require.NoError(t, err)
+		require.True(t, len(res) <= 40)
+		for k, v := range res {
+			all[k] = v
+			require.NoError(t, store.Delete(baseTable, "", tenant, namespace, k))
+		}
+		if next = nextOffset; next == "" {
+			break
+		}
+		require.True(t, pages < 10)
+	}
+
+	// THEN only records expiring soon should be returned once
+	require.Equal(t, 150, len(all))
+	for k := range all {
+		require.True(t, strings.HasPrefix(k, "soon_"))
+	}
+	size, err := store.Size(baseTable, "", tenant, namespace)
+	require.NoError(t, err)
+	require.Equal(t, int64(20), size)
+}
+
+func testNotExceedCapacityWithAllocations(t *testing.T, store repository.DataStore) {
+	baseTable, tenant, namespace := newTable(t, store)
+
+	// WHEN allocating more records than capacity concurrently
+	var allocated int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if store.Allocate(baseTable, "", tenant, namespace,
+				fmt.Sprintf("id%d", i), 0, 7, []byte("data"), time.Minute) == nil {
+				atomic.AddInt32(&allocated, 1)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// THEN capacity should not be exceeded
+	require.Equal(t, int32(7), allocated)
+	size, err := store.Size(baseTable, "", tenant, namespace)
+	require.NoError(t, err)
+	require.Equal(t, int64(7), size)
+
+	// AND existing allocation can be renewed at capacity
+	res, _, err := store.Query(baseTable, "", tenant, namespace, nil, "", 1)
+	require.NoError(t, err)
+	for id := range res {
+		require.NoError(t, store.Allocate(baseTable, "", tenant, namespace, id, -1, 7, []byte("data2"), time.Minute))
+	}
+	// AND allocation with stale version should be rejected
+	for id := range res {
+		var conflictErr *domain.ConflictError
+		err = store.Allocate(baseTable, "", tenant, namespace, id, 1, 7, []byte("data3"), time.Minute)
+		require.ErrorAs(t, err, &conflictErr)
+		err = store.Allocate(baseTable, "", tenant, namespace, id, 0, 7, []byte("data3"), time.Minute)
+		require.ErrorAs(t, err, &conflictErr)
+		require.NoError(t, store.Allocate(baseTable, "", tenant, namespace, id, 2, 7, []byte("data3"), time.Minute))
+	}
+	err = store.Allocate(baseTable, "", tenant, namespace, "new", 0, 7, []byte("data"), time.Minute)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "exceeds capacity")
+}
+
+// newTable returns unique table, tenant and namespace of the test, which are cleared after the test.
+func newTable(t *testing.T, store repository.DataStore) (baseTable string, tenant string, namespace string) {
+	baseTable, tenant, namespace = NewTable(), uuid.NewV4().String(), "test"
+	t.Cleanup(func() {
+		_ = store.ClearTable(baseTable, "", tenant, namespace)
+	})
+	return
+}
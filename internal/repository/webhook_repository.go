@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"time"
+)
+
+// NewWebhookRepository creates repository for persisting webhooks
+func NewWebhookRepository(
+	store DataStore,
+) (Repository[types.Webhook], error) {
+	return NewBaseRepository[types.Webhook](store,
+		"Webhook",
+		"",
+		time.Duration(0),
+		func() *types.Webhook {
+			return &types.Webhook{}
+		})
+}
+
+// NewWebhookDeliveryRepository creates repository for persisting deliveries of webhooks
+func NewWebhookDeliveryRepository(
+	store DataStore,
+	retention time.Duration,
+) (Repository[types.WebhookDelivery], error) {
+	return NewBaseRepository[types.WebhookDelivery](store,
+		"WebhookDelivery",
+		"",
+		retention,
+		func() *types.WebhookDelivery {
+			return &types.WebhookDelivery{}
+		})
+}
+
+// NewWebhookClaimRepository creates repository for claims of pending deliveries of webhooks, which
+// are keyed by id of the delivery and removed after it's delivered or dead lettered so that pending
+// deliveries are found without scanning all deliveries.
+func NewWebhookClaimRepository(
+	store DataStore,
+	retention time.Duration,
+) (Repository[domain.LeaderLease], error) {
+	return NewBaseRepository[domain.LeaderLease](store,
+		"WebhookDeliveryClaim",
+		"",
+		retention,
+		func() *domain.LeaderLease {
+			return &domain.LeaderLease{}
+		})
+}
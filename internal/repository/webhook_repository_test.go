@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/repository/redis"
+	"github.com/stretchr/testify/require"
+	"github.com/twinj/uuid"
+	"testing"
+	"time"
+)
+
+func Test_ShouldSaveAndQueryWebhookDeliveries(t *testing.T) {
+	// GIVEN config, redis-service and webhook repositories
+	ctx := context.TODO()
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	store, err := redis.NewRedisStore(cfg)
+	require.NoError(t, err)
+	webhookRepository, err := NewWebhookRepository(store)
+	require.NoError(t, err)
+	deliveryRepository, err := NewWebhookDeliveryRepository(store, time.Minute)
+	require.NoError(t, err)
+	org := uuid.NewV4().String()
+	webhook := &types.Webhook{Id: uuid.NewV4().String(), OrganizationId: org, Url: "http://localhost/hook"}
+
+	// WHEN saving webhook and deliveries
+	err = webhookRepository.Create(ctx, org, "", webhook.Id, webhook, time.Duration(0))
+	require.NoError(t, err)
+	for _, status := range []types.WebhookDeliveryStatus{
+		types.WebhookDeliveryStatus_DELIVERED, types.WebhookDeliveryStatus_DEAD_LETTER} {
+		delivery := &types.WebhookDelivery{
+			Id:             uuid.NewV4().String(),
+			WebhookId:      webhook.Id,
+			OrganizationId: org,
+			Status:         status,
+		}
+		err = deliveryRepository.Create(ctx, org, "", delivery.Id, delivery, time.Duration(0))
+		require.NoError(t, err)
+	}
+
+	// THEN it should find webhook and its dead letters
+	saved, err := webhookRepository.GetByID(ctx, org, "", webhook.Id)
+	require.NoError(t, err)
+	require.Equal(t, webhook.Url, saved.Url)
+	res, _, err := deliveryRepository.Query(ctx, org, "", map[string]string{
+		"webhook_id": webhook.Id, "status": "2"}, "", 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(res))
+	require.Equal(t, types.WebhookDeliveryStatus_DEAD_LETTER, res[0].Status)
+}
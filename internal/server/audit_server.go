@@ -0,0 +1,67 @@
+package server
+
+import (
+	api "github.com/bhatti/PlexAuthZ/api/v1/services"
+	"github.com/bhatti/PlexAuthZ/internal/audit"
+	"github.com/bhatti/PlexAuthZ/internal/authz"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+)
+
+type auditServer struct {
+	api.AuditServiceServer
+	authorizer authz.Authorizer
+	auditor    *audit.Auditor
+}
+
+// NewAuditServer constructor for exporting audit records, which requires auditor
+// when audit is enabled.
+func NewAuditServer(
+	authorizer authz.Authorizer,
+	auditor *audit.Auditor,
+) (api.AuditServiceServer, error) {
+	return &auditServer{
+		authorizer: authorizer,
+		auditor:    auditor,
+	}, nil
+}
+
+// Query Audit Records
+func (s *auditServer) Query(
+	req *api.QueryAuditRequest,
+	sender api.AuditService_QueryServer,
+) error {
+	if _, err := s.authorizer.Authorize(
+		sender.Context(),
+		&api.AuthRequest{
+			PrincipalId:    authz.Subject(sender.Context()),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         queryAction,
+		},
+	); err != nil {
+		return err
+	}
+	if s.auditor == nil {
+		return domain.NewValidationError("audit is not enabled")
+	}
+	res, err := s.auditor.Query(
+		sender.Context(),
+		req.OrganizationId,
+		req.Namespace,
+		req.EntityType,
+		req.EntityId,
+		req.Caller,
+		req.Action,
+		req.Predicates,
+		req.Limit)
+	if err != nil {
+		return err
+	}
+	for _, record := range res {
+		if err = sender.Send(&api.QueryAuditResponse{Record: record}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"github.com/bhatti/PlexAuthZ/api/v1/services"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/audit"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"testing"
+)
+
+func Test_ShouldQueryAuditRecordsOfRoleChanges(t *testing.T) {
+	// GIVEN server with audit of changes
+	err := os.Setenv("CONFIG_DIR", "../../config")
+	require.NoError(t, err)
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	cfg.GrpcSasl = true
+	cfg.Audit.Enabled = true
+	ctx := context.Background()
+	clients, teardown := SetupGrpcServerForTesting(t, cfg, domain.RootClientType, nil)
+	defer teardown()
+	orgRes, err := clients.OrganizationsClient.Create(ctx, &services.CreateOrganizationRequest{
+		Name:       "audit-org",
+		Namespaces: []string{"admin"},
+	})
+	require.NoError(t, err)
+
+	// WHEN creating, updating and deleting role
+	roleRes, err := clients.RolesClient.Create(ctx, &services.CreateRoleRequest{
+		Name:           "role-name",
+		Namespace:      "admin",
+		OrganizationId: orgRes.Id,
+	})
+	require.NoError(t, err)
+	_, err = clients.RolesClient.Update(ctx, &services.UpdateRoleRequest{
+		Id:             roleRes.Id,
+		Name:           "new-name",
+		OrganizationId: orgRes.Id,
+		Namespace:      "admin",
+	})
+	require.NoError(t, err)
+	_, err = clients.RolesClient.Delete(ctx, &services.DeleteRoleRequest{
+		Id:             roleRes.Id,
+		OrganizationId: orgRes.Id,
+		Namespace:      "admin",
+	})
+	require.NoError(t, err)
+
+	// THEN changes should be recorded in order with caller and diff
+	records := queryAudit(t, clients, &services.QueryAuditRequest{
+		OrganizationId: orgRes.Id, EntityType: "Role", EntityId: roleRes.Id})
+	require.Len(t, records, 3)
+	require.Equal(t, audit.CreateAction, records[0].Action)
+	require.Equal(t, audit.UpdateAction, records[1].Action)
+	require.Equal(t, audit.DeleteAction, records[2].Action)
+	require.Contains(t, records[1].Diff, `"name":{"before":"role-name","after":"new-name"}`)
+	for _, record := range records {
+		require.Equal(t, "root", record.Caller)
+		require.Equal(t, "admin", record.Namespace)
+	}
+
+	// AND organization creation should be recorded
+	records = queryAudit(t, clients, &services.QueryAuditRequest{
+		OrganizationId: orgRes.Id, Action: audit.CreateAction})
+	require.Len(t, records, 2)
+	require.Equal(t, "Organization", records[0].EntityType)
+}
+
+func queryAudit(
+	t *testing.T,
+	clients Clients,
+	req *services.QueryAuditRequest,
+) (res []*types.AuditRecord) {
+	query, err := clients.AuditClient.Query(context.Background(), req)
+	require.NoError(t, err)
+	for {
+		next, err := query.Recv()
+		if err == io.EOF {
+			return
+		}
+		require.NoError(t, err)
+		res = append(res, next.Record)
+	}
+}
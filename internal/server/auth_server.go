@@ -2,26 +2,37 @@ package server
 
 import (
 	"context"
+	"fmt"
 	api "github.com/bhatti/PlexAuthZ/api/v1/services"
 	"github.com/bhatti/PlexAuthZ/internal/authz"
+	"github.com/bhatti/PlexAuthZ/internal/decisionlog"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
 	"github.com/bhatti/PlexAuthZ/internal/service"
+	"google.golang.org/protobuf/types/known/timestamppb"
 	"time"
 )
 
 type authServer struct {
 	api.AuthZServiceServer
-	authAdminService service.AuthAdminService
-	authorizer       authz.Authorizer
+	authAdminService  service.AuthAdminService
+	authorizer        authz.Authorizer
+	defaultAuthorizer authz.Authorizer
+	decisionLogger    *decisionlog.Logger
 }
 
-// NewAuthServer constructor.
+// NewAuthServer constructor, where decisions of Authorize are recorded if decision logger is defined.
+// Users of JWT bearer tokens are authorized with policies of their organization rather than the admin
+// authorizer of clients.
 func NewAuthServer(
 	authAdminService service.AuthAdminService,
 	authorizer authz.Authorizer,
+	decisionLogger *decisionlog.Logger,
 ) (api.AuthZServiceServer, error) {
 	return &authServer{
-		authAdminService: authAdminService,
-		authorizer:       authorizer,
+		authAdminService:  authAdminService,
+		authorizer:        authorizer,
+		defaultAuthorizer: authz.NewDefaultAuthorizer(authAdminService),
+		decisionLogger:    decisionLogger,
 	}, nil
 }
 
@@ -30,7 +41,20 @@ func (s *authServer) Authorize(
 	ctx context.Context,
 	req *api.AuthRequest,
 ) (*api.AuthResponse, error) {
-	return s.authorizer.Authorize(ctx, req)
+	tokenUser, err := authorizeOnBehalfOfUser(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	authorizer := s.authorizer
+	if tokenUser {
+		authorizer = s.defaultAuthorizer
+	}
+	started := time.Now()
+	res, err := authorizer.Authorize(ctx, req)
+	if s.decisionLogger != nil {
+		authz.LogAuthorizeDecision(ctx, s.decisionLogger, started, req, res, err)
+	}
+	return res, err
 }
 
 // Allocate Resources
@@ -41,9 +65,11 @@ func (s *authServer) Allocate(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      updateAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         updateAction,
 		},
 	); err != nil {
 		return nil, err
@@ -52,7 +78,7 @@ func (s *authServer) Allocate(
 	if req.Expiry != nil {
 		expiry = req.Expiry.AsDuration()
 	}
-	err := s.authAdminService.AllocateResourceInstance(
+	expiresAt, err := s.authAdminService.AllocateResourceInstance(
 		ctx,
 		req.OrganizationId,
 		req.Namespace,
@@ -65,7 +91,48 @@ func (s *authServer) Allocate(
 	if err != nil {
 		return nil, err
 	}
-	return &api.AllocateResourceResponse{}, nil
+	return &api.AllocateResourceResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+		ExpiresAt:        timestamppb.New(expiresAt),
+	}, nil
+}
+
+// RenewAllocation extends lease of allocated resource, which is used as heartbeat by its principal
+func (s *authServer) RenewAllocation(
+	ctx context.Context,
+	req *api.RenewAllocationRequest,
+) (*api.RenewAllocationResponse, error) {
+	if _, err := s.authorizer.Authorize(
+		ctx,
+		&api.AuthRequest{
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         updateAction,
+		},
+	); err != nil {
+		return nil, err
+	}
+	var expiry time.Duration
+	if req.Expiry != nil {
+		expiry = req.Expiry.AsDuration()
+	}
+	expiresAt, err := s.authAdminService.RenewAllocation(
+		ctx,
+		req.OrganizationId,
+		req.Namespace,
+		req.ResourceId,
+		req.PrincipalId,
+		expiry,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &api.RenewAllocationResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+		ExpiresAt:        timestamppb.New(expiresAt),
+	}, nil
 }
 
 // Deallocate Resources
@@ -76,9 +143,11 @@ func (s *authServer) Deallocate(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      updateAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         updateAction,
 		},
 	); err != nil {
 		return nil, err
@@ -94,5 +163,39 @@ func (s *authServer) Deallocate(
 	if err != nil {
 		return nil, err
 	}
-	return &api.DeallocateResourceResponse{}, nil
+	return &api.DeallocateResourceResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	}, nil
+}
+
+// authorizeOnBehalfOfUser uses organization and principal of the JWT bearer token when they are
+// not defined in the request and prevents users of tokens from authorizing other principals or
+// principals of other organizations. It returns true if the caller is the user of the token rather
+// than a client with certificate.
+func authorizeOnBehalfOfUser(
+	ctx context.Context,
+	req *api.AuthRequest,
+) (bool, error) {
+	claims := authz.Claims(ctx)
+	if claims == nil {
+		return false, nil
+	}
+	if req.OrganizationId == "" {
+		req.OrganizationId = claims.OrganizationId
+	}
+	if req.PrincipalId == "" {
+		req.PrincipalId = claims.PrincipalId
+	}
+	if authz.Subject(ctx) != claims.Subject {
+		return false, nil
+	}
+	if req.OrganizationId != claims.OrganizationId {
+		return false, domain.NewAuthError(
+			fmt.Sprintf("%s is not permitted to authorize in organization %s", claims.Subject, req.OrganizationId))
+	}
+	if req.PrincipalId != claims.PrincipalId {
+		return false, domain.NewAuthError(
+			fmt.Sprintf("%s is not permitted to authorize principal %s", claims.Subject, req.PrincipalId))
+	}
+	return true, nil
 }
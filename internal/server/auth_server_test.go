@@ -3,13 +3,18 @@ package server
 import (
 	"context"
 	"github.com/bhatti/PlexAuthZ/api/v1/services"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
 	"github.com/bhatti/PlexAuthZ/internal/authz"
 	"github.com/bhatti/PlexAuthZ/internal/domain"
 	"github.com/bhatti/PlexAuthZ/internal/metrics"
 	"github.com/bhatti/PlexAuthZ/internal/service/db"
+	"github.com/golang-jwt/jwt"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/durationpb"
 	"os"
 	"testing"
+	"time"
 )
 
 func Test_ShouldAuthorize(t *testing.T) {
@@ -19,7 +24,7 @@ func Test_ShouldAuthorize(t *testing.T) {
 	authService, _, err := db.CreateDatabaseAuthService(cfg, metrics.New())
 	require.NoError(t, err)
 
-	authorizer, err := NewAuthServer(authService, authz.NullAuthorizer{})
+	authorizer, err := NewAuthServer(authService, authz.NullAuthorizer{}, nil)
 	require.NoError(t, err)
 	_, err = authorizer.Authorize(context.Background(), &services.AuthRequest{
 		OrganizationId: "org",
@@ -32,6 +37,119 @@ func Test_ShouldAuthorize(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func Test_ShouldAuthenticateWithJWTTokens(t *testing.T) {
+	// GIVEN server that accepts client certificates and jwt tokens
+	err := os.Setenv("CONFIG_DIR", "../../config")
+	require.NoError(t, err)
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	cfg.GrpcSasl = true
+	cfg.GrpcJWT = domain.JWTAuthConfig{SigningKey: "secret", Issuer: "plexauthz"}
+	require.NoError(t, cfg.GrpcJWT.Validate())
+	rootClients, teardown := SetupGrpcServerForTesting(t, cfg, domain.RootClientType, nil)
+	defer teardown()
+	caFile, err := cfg.CAFile()
+	require.NoError(t, err)
+	cc, clients, err := NewClients(caFile, "", "", cfg.GrpcListenPort)
+	require.NoError(t, err)
+	defer func() {
+		_ = cc.Close()
+	}()
+	createOrg := &services.CreateOrganizationRequest{
+		Name:       "org-name",
+		Namespaces: []string{"admin"},
+	}
+	tokenContext := func(claims jwt.MapClaims, key string) context.Context {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(key))
+		require.NoError(t, err)
+		return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+	}
+
+	// WHEN invoking APIs with client certificate
+	_, err = rootClients.OrganizationsClient.Create(context.Background(), createOrg)
+	// THEN it should succeed
+	require.NoError(t, err)
+
+	// WHEN invoking APIs without certificate or token
+	_, err = clients.OrganizationsClient.Create(context.Background(), createOrg)
+	// THEN it should fail
+	require.Error(t, err)
+
+	// WHEN invoking APIs with invalid or unpermitted token
+	_, err = clients.OrganizationsClient.Create(
+		tokenContext(jwt.MapClaims{"sub": "root", "iss": "plexauthz"}, "bad"), createOrg)
+	// THEN it should fail
+	require.Error(t, err)
+	_, err = clients.OrganizationsClient.Create(
+		tokenContext(jwt.MapClaims{"sub": "nobody", "iss": "plexauthz"}, "secret"), createOrg)
+	require.Error(t, err)
+
+	// WHEN invoking APIs with permitted token
+	_, err = clients.OrganizationsClient.Create(
+		tokenContext(jwt.MapClaims{"sub": "root", "iss": "plexauthz"}, "secret"), createOrg)
+	// THEN it should succeed
+	require.NoError(t, err)
+
+	// WHEN invoking APIs with token scoped to organization
+	rootCtx := tokenContext(jwt.MapClaims{"sub": "root", "org_id": "org", "iss": "plexauthz"}, "secret")
+	_, err = clients.OrganizationsClient.Create(rootCtx, createOrg)
+	// THEN it should not create organizations
+	require.Error(t, err)
+
+	// GIVEN a principal that is not an admin with permission to read reports of its organization
+	ctx := context.Background()
+	orgRes, err := rootClients.OrganizationsClient.Create(ctx, createOrg)
+	require.NoError(t, err)
+	principalRes, err := rootClients.PrincipalsClient.Create(ctx, &services.CreatePrincipalRequest{
+		Username:       "alice",
+		OrganizationId: orgRes.Id,
+		Namespaces:     []string{"admin"},
+	})
+	require.NoError(t, err)
+	resourceRes, err := rootClients.ResourcesClient.Create(ctx, &services.CreateResourceRequest{
+		OrganizationId: orgRes.Id,
+		Namespace:      "admin",
+		Name:           "report",
+		AllowedActions: []string{"read", "write"},
+	})
+	require.NoError(t, err)
+	permissionRes, err := rootClients.PermissionsClient.Create(ctx, &services.CreatePermissionRequest{
+		OrganizationId: orgRes.Id,
+		Namespace:      "admin",
+		ResourceId:     resourceRes.Id,
+		Actions:        []string{"read"},
+	})
+	require.NoError(t, err)
+	_, err = rootClients.PrincipalsClient.AddPermissions(ctx, &services.AddPermissionsToPrincipalRequest{
+		Namespace:      "admin",
+		OrganizationId: orgRes.Id,
+		PermissionIds:  []string{permissionRes.Id},
+		PrincipalId:    principalRes.Id,
+	})
+	require.NoError(t, err)
+	userCtx := tokenContext(jwt.MapClaims{"sub": principalRes.Id, "org_id": orgRes.Id, "iss": "plexauthz"}, "secret")
+
+	// WHEN authorizing on behalf of the user of token
+	res, err := clients.AuthClient.Authorize(userCtx, &services.AuthRequest{
+		Namespace: "admin", Resource: "report", Action: "read"})
+	// THEN it should use principal of the token and policies of its organization
+	require.NoError(t, err)
+	require.Equal(t, types.Effect_PERMITTED, res.Effect)
+	// AND it should not permit actions without permission
+	res, err = clients.AuthClient.Authorize(userCtx, &services.AuthRequest{
+		Namespace: "admin", Resource: "report", Action: "write"})
+	require.True(t, err != nil || res.Effect != types.Effect_PERMITTED)
+	// AND it should not authorize other principals
+	_, err = clients.AuthClient.Authorize(userCtx, &services.AuthRequest{
+		PrincipalId: "client", Namespace: "admin", Resource: "report", Action: "read"})
+	require.Error(t, err)
+	// AND it should not authorize principal of the token in other organizations
+	_, err = clients.AuthClient.Authorize(userCtx, &services.AuthRequest{
+		OrganizationId: "other-org", Namespace: "admin", Resource: "report", Action: "read"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "not permitted to authorize in organization other-org")
+}
+
 func Test_ShouldAllocateResource(t *testing.T) {
 	// GIVEN auth-client
 	err := os.Setenv("CONFIG_DIR", "../../config")
@@ -88,13 +206,24 @@ func testShouldAllocateResources(t *testing.T, clients Clients) {
 	})
 	require.NoError(t, err)
 
-	_, err = clients.AuthClient.Allocate(ctx, &services.AllocateResourceRequest{
+	allocateRes, err := clients.AuthClient.Allocate(ctx, &services.AllocateResourceRequest{
+		OrganizationId: orgRes.Id,
+		Namespace:      "admin",
+		ResourceId:     resourceRes.Id,
+		PrincipalId:    principalRes.Id,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, allocateRes.ExpiresAt)
+
+	renewRes, err := clients.AuthClient.RenewAllocation(ctx, &services.RenewAllocationRequest{
 		OrganizationId: orgRes.Id,
 		Namespace:      "admin",
 		ResourceId:     resourceRes.Id,
 		PrincipalId:    principalRes.Id,
+		Expiry:         durationpb.New(time.Hour),
 	})
 	require.NoError(t, err)
+	require.True(t, renewRes.ExpiresAt.AsTime().After(allocateRes.ExpiresAt.AsTime()))
 
 	countRes, err := clients.ResourcesClient.CountResourceInstances(ctx, &services.CountResourceInstancesRequest{
 		OrganizationId: orgRes.Id,
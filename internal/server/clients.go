@@ -18,6 +18,11 @@ type Clients struct {
 	RelationshipsClient services.RelationshipsServiceClient
 	ResourcesClient     services.ResourcesServiceClient
 	RolesClient         services.RolesServiceClient
+	PoliciesClient      services.PoliciesServiceClient
+	DecisionsClient     services.DecisionsServiceClient
+	AuditClient         services.AuditServiceClient
+	WatchClient         services.WatchServiceClient
+	WebhooksClient      services.WebhooksServiceClient
 	ClientType          domain.ClientType
 }
 
@@ -52,6 +57,11 @@ func NewClients(caFile string, certFile string, keyFile string, addr string) (
 	clients.RelationshipsClient = services.NewRelationshipsServiceClient(conn)
 	clients.ResourcesClient = services.NewResourcesServiceClient(conn)
 	clients.RolesClient = services.NewRolesServiceClient(conn)
+	clients.PoliciesClient = services.NewPoliciesServiceClient(conn)
+	clients.DecisionsClient = services.NewDecisionsServiceClient(conn)
+	clients.AuditClient = services.NewAuditServiceClient(conn)
+	clients.WatchClient = services.NewWatchServiceClient(conn)
+	clients.WebhooksClient = services.NewWebhooksServiceClient(conn)
 	return
 }
 
@@ -0,0 +1,66 @@
+package server
+
+import (
+	api "github.com/bhatti/PlexAuthZ/api/v1/services"
+	"github.com/bhatti/PlexAuthZ/internal/authz"
+	"github.com/bhatti/PlexAuthZ/internal/decisionlog"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+)
+
+type decisionsServer struct {
+	api.DecisionsServiceServer
+	authorizer     authz.Authorizer
+	decisionLogger *decisionlog.Logger
+}
+
+// NewDecisionsServer constructor for searching decision logs, which requires decision logger
+// when decision logs are enabled.
+func NewDecisionsServer(
+	authorizer authz.Authorizer,
+	decisionLogger *decisionlog.Logger,
+) (api.DecisionsServiceServer, error) {
+	return &decisionsServer{
+		authorizer:     authorizer,
+		decisionLogger: decisionLogger,
+	}, nil
+}
+
+// Query Decisions
+func (s *decisionsServer) Query(
+	req *api.QueryDecisionRequest,
+	sender api.DecisionsService_QueryServer,
+) error {
+	if _, err := s.authorizer.Authorize(
+		sender.Context(),
+		&api.AuthRequest{
+			PrincipalId:    authz.Subject(sender.Context()),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         queryAction,
+		},
+	); err != nil {
+		return err
+	}
+	if s.decisionLogger == nil {
+		return domain.NewValidationError("decision logs are not enabled")
+	}
+	res, nextOffset, err := s.decisionLogger.Query(
+		sender.Context(),
+		req.OrganizationId,
+		req.Namespace,
+		req.PrincipalId,
+		req.Resource,
+		req.Predicates,
+		req.Offset,
+		req.Limit)
+	if err != nil {
+		return err
+	}
+	for _, decision := range res {
+		if err = sender.Send(&api.QueryDecisionResponse{Decision: decision, NextOffset: nextOffset}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
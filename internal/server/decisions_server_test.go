@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"github.com/bhatti/PlexAuthZ/api/v1/services"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/stretchr/testify/require"
+	"github.com/twinj/uuid"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func Test_ShouldQueryDecisionsOfAuthorize(t *testing.T) {
+	// GIVEN server with decision logs
+	err := os.Setenv("CONFIG_DIR", "../../config")
+	require.NoError(t, err)
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	cfg.GrpcSasl = true
+	cfg.DecisionLog.Enabled = true
+	ctx := context.Background()
+	clients, teardown := SetupGrpcServerForTesting(t, cfg, domain.RootClientType, nil)
+	defer teardown()
+	orgId := uuid.NewV4().String()
+
+	// WHEN authorizing requests
+	_, err = clients.AuthClient.Authorize(ctx, &services.AuthRequest{
+		OrganizationId: orgId, PrincipalId: "root", Resource: "*", Action: "auth"})
+	require.NoError(t, err)
+	_, err = clients.AuthClient.Authorize(ctx, &services.AuthRequest{
+		OrganizationId: orgId, PrincipalId: "nobody", Resource: "*", Action: "auth"})
+	require.Error(t, err)
+
+	// THEN decisions should be searchable by principal
+	var decisions []*types.Decision
+	require.Eventually(t, func() bool {
+		decisions = queryDecisions(t, clients, &services.QueryDecisionRequest{OrganizationId: orgId})
+		return len(decisions) == 2
+	}, 5*time.Second, 50*time.Millisecond)
+	decisions = queryDecisions(t, clients, &services.QueryDecisionRequest{
+		OrganizationId: orgId, PrincipalId: "nobody"})
+	require.Len(t, decisions, 1)
+	require.Equal(t, types.Effect_DENIED, decisions[0].Effect)
+	require.Equal(t, "root", decisions[0].Caller)
+	require.Equal(t, "auth", decisions[0].Action)
+}
+
+func queryDecisions(
+	t *testing.T,
+	clients Clients,
+	req *services.QueryDecisionRequest,
+) (res []*types.Decision) {
+	query, err := clients.DecisionsClient.Query(context.Background(), req)
+	require.NoError(t, err)
+	for {
+		next, err := query.Recv()
+		if err == io.EOF {
+			return
+		}
+		require.NoError(t, err)
+		res = append(res, next.Decision)
+	}
+}
@@ -0,0 +1,378 @@
+package server
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	api "github.com/bhatti/PlexAuthZ/api/v1/services"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/authz"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/service"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	log "github.com/sirupsen/logrus"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"net/url"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+const (
+	// EnvoyDecisionHeader header set on responses to Envoy with the decision.
+	EnvoyDecisionHeader = "x-plexauthz-decision"
+	// EnvoyPrincipalHeader header set on responses to Envoy with the principal-id.
+	EnvoyPrincipalHeader = "x-plexauthz-principal"
+	// EnvoyOrganizationHeader header set on responses to Envoy with the organization-id.
+	EnvoyOrganizationHeader = "x-plexauthz-organization"
+
+	jwtAuthnFilter = "envoy.filters.http.jwt_authn"
+)
+
+type envoyAuthRule struct {
+	domain.EnvoyAuthRule
+	pathRegex    *regexp.Regexp
+	organization *template.Template
+	namespace    *template.Template
+	principal    *template.Template
+	resource     *template.Template
+	action       *template.Template
+	scope        *template.Template
+	context      map[string]*template.Template
+}
+
+type envoyAuthServer struct {
+	authv3.UnimplementedAuthorizationServer
+	config          domain.EnvoyAuthConfig
+	authorizer      authz.Authorizer
+	adminAuthorizer authz.Authorizer
+	rules           []*envoyAuthRule
+}
+
+// NewEnvoyAuthServer constructor for Envoy ext_authz server that enforces decisions of
+// the default authorizer based on rules for mapping attributes of HTTP requests, where
+// the admin authorizer checks that the caller is permitted to authorize in the mapped organization.
+func NewEnvoyAuthServer(
+	config *domain.Config,
+	authAdminService service.AuthAdminService,
+	adminAuthorizer authz.Authorizer,
+) (authv3.AuthorizationServer, error) {
+	authorizer, err := authz.CreateAuthorizer(authz.DefaultAuthorizerKind, config, authAdminService)
+	if err != nil {
+		return nil, err
+	}
+	srv := &envoyAuthServer{
+		config:          config.EnvoyAuth,
+		authorizer:      authorizer,
+		adminAuthorizer: adminAuthorizer,
+	}
+	for i, rule := range config.EnvoyAuth.Rules {
+		compiled, err := compileEnvoyAuthRule(rule)
+		if err != nil {
+			return nil, domain.NewValidationError(
+				fmt.Sprintf("failed to compile envoy rule %d '%s' due to %s", i, rule.Name, err))
+		}
+		srv.rules = append(srv.rules, compiled)
+	}
+	return srv, nil
+}
+
+// Check authorizes HTTP request forwarded by Envoy.
+func (s *envoyAuthServer) Check(
+	ctx context.Context,
+	req *authv3.CheckRequest,
+) (*authv3.CheckResponse, error) {
+	attrs := s.toTemplateAttributes(req)
+	rule := s.matchRule(attrs)
+	if rule == nil {
+		return envoyDenied(codes.PermissionDenied, typev3.StatusCode_Forbidden,
+			fmt.Sprintf("no rule matched %s %s", attrs["Method"], attrs["Path"]), nil), nil
+	}
+	authReq, err := rule.toAuthRequest(attrs)
+	if err != nil {
+		return envoyDenied(codes.PermissionDenied, typev3.StatusCode_Forbidden, err.Error(), nil), nil
+	}
+	if err = s.authorizeCaller(ctx, authReq); err != nil {
+		// denied response instead of error so that failure_mode_allow of Envoy doesn't permit the request
+		return envoyDenied(codes.PermissionDenied, typev3.StatusCode_Forbidden, err.Error(), authReq), nil
+	}
+	if authReq.PrincipalId == "" {
+		return envoyDenied(codes.Unauthenticated, typev3.StatusCode_Unauthorized,
+			fmt.Sprintf("principal not found for rule %s", rule.Name), authReq), nil
+	}
+	res, err := s.authorizer.Authorize(ctx, authReq)
+	if err != nil {
+		return envoyDenied(codes.PermissionDenied, typev3.StatusCode_Forbidden, err.Error(), authReq), nil
+	}
+	if res.Effect != types.Effect_PERMITTED {
+		return envoyDenied(codes.PermissionDenied, typev3.StatusCode_Forbidden, res.Message, authReq), nil
+	}
+	if log.IsLevelEnabled(log.DebugLevel) {
+		log.WithFields(log.Fields{
+			"Component": "EnvoyAuthServer",
+			"Rule":      rule.Name,
+			"Request":   authReq,
+		}).Debugf("permitted envoy request")
+	}
+	return &authv3.CheckResponse{
+		Status: &rpcstatus.Status{Code: int32(codes.OK)},
+		HttpResponse: &authv3.CheckResponse_OkResponse{
+			OkResponse: &authv3.OkHttpResponse{
+				Headers: envoyHeaders(types.Effect_PERMITTED, authReq),
+			},
+		},
+	}, nil
+}
+
+// authorizeCaller checks that the client calling ext_authz such as Envoy is permitted to authorize
+// requests in the organization and namespace of the matched rule.
+func (s *envoyAuthServer) authorizeCaller(ctx context.Context, authReq *api.AuthRequest) error {
+	callerReq := &api.AuthRequest{
+		PrincipalId:    authz.Subject(ctx),
+		OrganizationId: authReq.OrganizationId,
+		Namespace:      authReq.Namespace,
+		Resource:       objectWildcard,
+		Action:         authAction,
+	}
+	if err := authz.CheckScope(ctx, callerReq); err != nil {
+		return err
+	}
+	_, err := s.adminAuthorizer.Authorize(ctx, callerReq)
+	return err
+}
+
+func (s *envoyAuthServer) matchRule(attrs map[string]any) *envoyAuthRule {
+	method := attrs["Method"].(string)
+	path := attrs["Path"].(string)
+	for _, rule := range s.rules {
+		if len(rule.Methods) > 0 && !includesFold(rule.Methods, method) {
+			continue
+		}
+		if rule.PathPrefix != "" && !strings.HasPrefix(path, rule.PathPrefix) {
+			continue
+		}
+		if rule.pathRegex != nil {
+			matches := rule.pathRegex.FindStringSubmatch(path)
+			if matches == nil {
+				continue
+			}
+			params := make(map[string]string)
+			for i, name := range rule.pathRegex.SubexpNames() {
+				if i > 0 && name != "" {
+					params[name] = matches[i]
+				}
+			}
+			attrs["Params"] = params
+		}
+		return rule
+	}
+	return nil
+}
+
+func (s *envoyAuthServer) toTemplateAttributes(req *authv3.CheckRequest) map[string]any {
+	attrs := map[string]any{
+		"Method":        "",
+		"Path":          "",
+		"Host":          "",
+		"Query":         map[string]string{},
+		"Headers":       map[string]string{},
+		"Params":        map[string]string{},
+		"Claims":        map[string]any{},
+		"MTLSPrincipal": "",
+		"Subject":       "",
+	}
+	httpReq := req.GetAttributes().GetRequest().GetHttp()
+	if httpReq != nil {
+		attrs["Method"] = strings.ToUpper(httpReq.Method)
+		attrs["Host"] = httpReq.Host
+		path := httpReq.Path
+		query := make(map[string]string)
+		if i := strings.Index(path, "?"); i >= 0 {
+			if values, err := url.ParseQuery(path[i+1:]); err == nil {
+				for k := range values {
+					query[k] = values.Get(k)
+				}
+			}
+			path = path[:i]
+		}
+		attrs["Path"] = path
+		attrs["Query"] = query
+		headers := make(map[string]string)
+		for k, v := range httpReq.Headers {
+			headers[strings.ToLower(k)] = v
+		}
+		attrs["Headers"] = headers
+	}
+
+	claims := s.jwtClaims(req)
+	attrs["Claims"] = claims
+	mtlsPrincipal := peerPrincipal(req.GetAttributes().GetSource())
+	attrs["MTLSPrincipal"] = mtlsPrincipal
+	if sub, ok := claims[s.config.PrincipalClaim]; ok && sub != nil {
+		attrs["Subject"] = fmt.Sprintf("%v", sub)
+	} else {
+		attrs["Subject"] = mtlsPrincipal
+	}
+	return attrs
+}
+
+// jwtClaims returns claims of JWT token verified by Envoy jwt_authn filter from its dynamic metadata.
+// Request headers are never used for claims because they can be forged by the downstream caller.
+func (s *envoyAuthServer) jwtClaims(req *authv3.CheckRequest) map[string]any {
+	claims := make(map[string]any)
+	if md := req.GetAttributes().GetMetadataContext().GetFilterMetadata()[jwtAuthnFilter]; md != nil {
+		if payload := md.GetFields()[s.config.JwtMetadataKey].GetStructValue(); payload != nil {
+			for k, v := range payload.AsMap() {
+				claims[k] = v
+			}
+		}
+	}
+	return claims
+}
+
+// peerPrincipal returns identity of the downstream peer from mTLS connection.
+func peerPrincipal(peer *authv3.AttributeContext_Peer) string {
+	if peer == nil {
+		return ""
+	}
+	if peer.Principal != "" {
+		return peer.Principal
+	}
+	if peer.Certificate == "" {
+		return ""
+	}
+	certPEM, err := url.QueryUnescape(peer.Certificate)
+	if err != nil {
+		return ""
+	}
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return ""
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return ""
+	}
+	return cert.Subject.CommonName
+}
+
+func compileEnvoyAuthRule(rule domain.EnvoyAuthRule) (compiled *envoyAuthRule, err error) {
+	compiled = &envoyAuthRule{
+		EnvoyAuthRule: rule,
+		context:       make(map[string]*template.Template),
+	}
+	if rule.PathRegex != "" {
+		if compiled.pathRegex, err = regexp.Compile(rule.PathRegex); err != nil {
+			return nil, err
+		}
+	}
+	if compiled.organization, err = domain.ParseMappingTemplate(rule.Organization); err != nil {
+		return nil, err
+	}
+	if compiled.namespace, err = domain.ParseMappingTemplate(rule.Namespace); err != nil {
+		return nil, err
+	}
+	if compiled.principal, err = domain.ParseMappingTemplate(rule.Principal); err != nil {
+		return nil, err
+	}
+	if compiled.resource, err = domain.ParseMappingTemplate(rule.Resource); err != nil {
+		return nil, err
+	}
+	if compiled.action, err = domain.ParseMappingTemplate(rule.Action); err != nil {
+		return nil, err
+	}
+	if compiled.scope, err = domain.ParseMappingTemplate(rule.Scope); err != nil {
+		return nil, err
+	}
+	for k, v := range rule.Context {
+		if compiled.context[k], err = domain.ParseMappingTemplate(v); err != nil {
+			return nil, err
+		}
+	}
+	return compiled, nil
+}
+
+func (r *envoyAuthRule) toAuthRequest(attrs map[string]any) (req *api.AuthRequest, err error) {
+	req = &api.AuthRequest{Context: make(map[string]string)}
+	if req.OrganizationId, err = domain.ExecuteMappingTemplate(r.organization, attrs); err != nil {
+		return nil, err
+	}
+	if req.Namespace, err = domain.ExecuteMappingTemplate(r.namespace, attrs); err != nil {
+		return nil, err
+	}
+	if req.PrincipalId, err = domain.ExecuteMappingTemplate(r.principal, attrs); err != nil {
+		return nil, err
+	}
+	if req.Resource, err = domain.ExecuteMappingTemplate(r.resource, attrs); err != nil {
+		return nil, err
+	}
+	if req.Action, err = domain.ExecuteMappingTemplate(r.action, attrs); err != nil {
+		return nil, err
+	}
+	if req.Scope, err = domain.ExecuteMappingTemplate(r.scope, attrs); err != nil {
+		return nil, err
+	}
+	for k, t := range r.context {
+		if req.Context[k], err = domain.ExecuteMappingTemplate(t, attrs); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+func envoyDenied(
+	code codes.Code,
+	httpCode typev3.StatusCode,
+	message string,
+	req *api.AuthRequest) *authv3.CheckResponse {
+	if log.IsLevelEnabled(log.DebugLevel) {
+		log.WithFields(log.Fields{
+			"Component": "EnvoyAuthServer",
+			"Request":   req,
+			"Message":   message,
+		}).Debugf("denied envoy request")
+	}
+	return &authv3.CheckResponse{
+		Status: &rpcstatus.Status{Code: int32(code), Message: message},
+		HttpResponse: &authv3.CheckResponse_DeniedResponse{
+			DeniedResponse: &authv3.DeniedHttpResponse{
+				Status:  &typev3.HttpStatus{Code: httpCode},
+				Headers: envoyHeaders(types.Effect_DENIED, req),
+				Body:    message,
+			},
+		},
+	}
+}
+
+func envoyHeaders(effect types.Effect, req *api.AuthRequest) (headers []*corev3.HeaderValueOption) {
+	headers = append(headers, &corev3.HeaderValueOption{
+		Header: &corev3.HeaderValue{Key: EnvoyDecisionHeader, Value: effect.String()},
+	})
+	if req == nil {
+		return
+	}
+	if req.PrincipalId != "" {
+		headers = append(headers, &corev3.HeaderValueOption{
+			Header: &corev3.HeaderValue{Key: EnvoyPrincipalHeader, Value: req.PrincipalId},
+		})
+	}
+	if req.OrganizationId != "" {
+		headers = append(headers, &corev3.HeaderValueOption{
+			Header: &corev3.HeaderValue{Key: EnvoyOrganizationHeader, Value: req.OrganizationId},
+		})
+	}
+	return
+}
+
+func includesFold(arr []string, s string) bool {
+	for _, next := range arr {
+		if strings.EqualFold(next, s) {
+			return true
+		}
+	}
+	return false
+}
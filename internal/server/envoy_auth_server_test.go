@@ -0,0 +1,209 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/authz"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/metrics"
+	"github.com/bhatti/PlexAuthZ/internal/service/db"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/structpb"
+	"testing"
+)
+
+func Test_ShouldAuthorizeEnvoyRequests(t *testing.T) {
+	// GIVEN auth-service and organization
+	ctx := context.Background()
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	authService, _, err := db.CreateDatabaseAuthService(cfg, metrics.New())
+	require.NoError(t, err)
+	org, err := authService.CreateOrganization(ctx, &types.Organization{
+		Name:       "envoy-org",
+		Namespaces: []string{"docs"},
+	})
+	require.NoError(t, err)
+	// AND principal with permission to read documents
+	principal, err := authService.CreatePrincipal(ctx, &types.Principal{
+		OrganizationId: org.Id,
+		Namespaces:     org.Namespaces,
+		Username:       "alice",
+	})
+	require.NoError(t, err)
+	resource, err := authService.CreateResource(ctx, org.Id, &types.Resource{
+		Namespace:      "docs",
+		Name:           "Document",
+		AllowedActions: []string{"get", "delete"},
+	})
+	require.NoError(t, err)
+	perm, err := authService.CreatePermission(ctx, org.Id, &types.Permission{
+		Namespace:  "docs",
+		Actions:    []string{"get"},
+		ResourceId: resource.Id,
+	})
+	require.NoError(t, err)
+	require.NoError(t, authService.AddPermissionsToPrincipal(ctx, org.Id, "docs", principal.Id, perm.Id))
+
+	// AND rules for mapping requests with JWT and mTLS principals
+	cfg.EnvoyAuth.Rules = []domain.EnvoyAuthRule{
+		{
+			Name:         "documents",
+			PathRegex:    `^/(?P<org>[^/]+)/docs/[^/]+$`,
+			Organization: "{{.Params.org}}",
+			Namespace:    "docs",
+			Resource:     "Document",
+			Context:      map[string]string{"Host": "{{.Host}}"},
+		},
+		{
+			Name:         "internal",
+			PathPrefix:   "/internal/",
+			Methods:      []string{"GET"},
+			Organization: org.Id,
+			Namespace:    "docs",
+			Principal:    "{{.MTLSPrincipal}}",
+			Resource:     "Document",
+		},
+	}
+	require.NoError(t, cfg.Validate())
+	srv, err := NewEnvoyAuthServer(cfg, authService, authz.NullAuthorizer{})
+	require.NoError(t, err)
+	claims := map[string]any{"sub": principal.Id}
+
+	// WHEN checking permitted action with JWT payload
+	res, err := srv.Check(ctx, withJwtMetadata(newEnvoyCheckRequest("GET", "/"+org.Id+"/docs/1?v=1",
+		nil, ""), claims))
+	// THEN it should be allowed with headers
+	require.NoError(t, err)
+	require.Equal(t, int32(codes.OK), res.Status.Code)
+	ok := res.GetOkResponse()
+	require.NotNil(t, ok)
+	require.Equal(t, EnvoyDecisionHeader, ok.Headers[0].Header.Key)
+	require.Equal(t, types.Effect_PERMITTED.String(), ok.Headers[0].Header.Value)
+	require.Equal(t, principal.Id, ok.Headers[1].Header.Value)
+
+	// WHEN checking action without permission
+	res, err = srv.Check(ctx, withJwtMetadata(newEnvoyCheckRequest("DELETE", "/"+org.Id+"/docs/1",
+		nil, ""), claims))
+	// THEN it should be denied
+	require.NoError(t, err)
+	require.Equal(t, int32(codes.PermissionDenied), res.Status.Code)
+	require.Equal(t, typev3.StatusCode_Forbidden, res.GetDeniedResponse().Status.Code)
+
+	// WHEN checking with forged payload header without jwt_authn metadata
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"` + principal.Id + `"}`))
+	res, err = srv.Check(ctx, newEnvoyCheckRequest("GET", "/"+org.Id+"/docs/1",
+		map[string]string{"x-jwt-payload": forged}, ""))
+	// THEN claims from the header should be ignored and request should be denied
+	require.NoError(t, err)
+	require.Equal(t, int32(codes.Unauthenticated), res.Status.Code)
+	require.Nil(t, res.GetOkResponse())
+
+	// WHEN checking without principal
+	res, err = srv.Check(ctx, newEnvoyCheckRequest("GET", "/"+org.Id+"/docs/1", nil, ""))
+	// THEN it should be unauthenticated
+	require.NoError(t, err)
+	require.Equal(t, int32(codes.Unauthenticated), res.Status.Code)
+
+	// WHEN checking with mTLS principal
+	res, err = srv.Check(ctx, newEnvoyCheckRequest("GET", "/internal/docs", nil, principal.Id))
+	// THEN it should be allowed
+	require.NoError(t, err)
+	require.Equal(t, int32(codes.OK), res.Status.Code)
+
+	// WHEN checking request that doesn't match any rule
+	res, err = srv.Check(ctx, newEnvoyCheckRequest("POST", "/internal/docs", nil, principal.Id))
+	// THEN it should be denied
+	require.NoError(t, err)
+	require.Equal(t, int32(codes.PermissionDenied), res.Status.Code)
+}
+
+func Test_ShouldDenyEnvoyRequestsOfUnauthorizedCallers(t *testing.T) {
+	// GIVEN rule for mapping requests of mTLS principals
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	authService, _, err := db.CreateDatabaseAuthService(cfg, metrics.New())
+	require.NoError(t, err)
+	cfg.EnvoyAuth.Rules = []domain.EnvoyAuthRule{
+		{
+			Name:         "internal",
+			PathPrefix:   "/internal/",
+			Organization: "envoy-org",
+			Namespace:    "docs",
+			Principal:    "{{.MTLSPrincipal}}",
+			Resource:     "Document",
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	// WHEN checking with caller that is not permitted by the admin authorizer
+	srv, err := NewEnvoyAuthServer(cfg, authService, authz.NoAuthorizer{})
+	require.NoError(t, err)
+	res, err := srv.Check(authz.WithSubject(context.Background(), "rogue"),
+		newEnvoyCheckRequest("GET", "/internal/docs", nil, "alice"))
+	// THEN it should be denied
+	require.NoError(t, err)
+	require.Equal(t, int32(codes.PermissionDenied), res.Status.Code)
+	require.Nil(t, res.GetOkResponse())
+
+	// WHEN checking with caller whose scope is another organization
+	srv, err = NewEnvoyAuthServer(cfg, authService, authz.NullAuthorizer{})
+	require.NoError(t, err)
+	ctx := authz.WithScope(authz.WithSubject(context.Background(), "envoy"),
+		domain.NewClientScope("other-org"))
+	res, err = srv.Check(ctx, newEnvoyCheckRequest("GET", "/internal/docs", nil, "alice"))
+	// THEN it should be denied
+	require.NoError(t, err)
+	require.Equal(t, int32(codes.PermissionDenied), res.Status.Code)
+	require.Contains(t, res.Status.Message, "outside of scope")
+}
+
+func Test_ShouldFailEnvoyServerWithInvalidRules(t *testing.T) {
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	cfg.EnvoyAuth.Rules = []domain.EnvoyAuthRule{{PathRegex: "(", Organization: "org", Namespace: "ns"}}
+	_, err = NewEnvoyAuthServer(cfg, nil, authz.NullAuthorizer{})
+	require.Error(t, err)
+	cfg.EnvoyAuth.Rules = []domain.EnvoyAuthRule{{Organization: "org"}}
+	require.Error(t, cfg.Validate())
+}
+
+func withJwtMetadata(req *authv3.CheckRequest, claims map[string]any) *authv3.CheckRequest {
+	payload, err := structpb.NewStruct(claims)
+	if err != nil {
+		panic(err)
+	}
+	req.Attributes.MetadataContext = &corev3.Metadata{
+		FilterMetadata: map[string]*structpb.Struct{
+			jwtAuthnFilter: {Fields: map[string]*structpb.Value{
+				"jwt_payload": structpb.NewStructValue(payload),
+			}},
+		},
+	}
+	return req
+}
+
+func newEnvoyCheckRequest(
+	method string,
+	path string,
+	headers map[string]string,
+	mtlsPrincipal string) *authv3.CheckRequest {
+	return &authv3.CheckRequest{
+		Attributes: &authv3.AttributeContext{
+			Source: &authv3.AttributeContext_Peer{Principal: mtlsPrincipal},
+			Request: &authv3.AttributeContext_Request{
+				Http: &authv3.AttributeContext_HttpRequest{
+					Method:  method,
+					Path:    path,
+					Host:    "localhost",
+					Headers: headers,
+				},
+			},
+		},
+	}
+}
@@ -5,6 +5,7 @@ import (
 	api "github.com/bhatti/PlexAuthZ/api/v1/services"
 	"github.com/bhatti/PlexAuthZ/api/v1/types"
 	"github.com/bhatti/PlexAuthZ/internal/authz"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
 	"github.com/bhatti/PlexAuthZ/internal/service"
 )
 
@@ -33,9 +34,11 @@ func (s *groupsServer) Create(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      updateAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         updateAction,
 		},
 	); err != nil {
 		return nil, err
@@ -50,7 +53,8 @@ func (s *groupsServer) Create(
 		return nil, err
 	}
 	return &api.CreateGroupResponse{
-		Id: group.Id,
+		Id:               group.Id,
+		ConsistencyToken: domain.NewConsistencyToken(),
 	}, nil
 }
 
@@ -62,15 +66,18 @@ func (s *groupsServer) Update(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      updateAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         updateAction,
 		},
 	); err != nil {
 		return nil, err
 	}
 	group := &types.Group{
 		Id:        req.Id,
+		Version:   req.Version,
 		Namespace: req.Namespace,
 		Name:      req.Name,
 		ParentIds: req.ParentIds,
@@ -79,7 +86,9 @@ func (s *groupsServer) Update(
 	if err != nil {
 		return nil, err
 	}
-	return &api.UpdateGroupResponse{}, nil
+	return &api.UpdateGroupResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	}, nil
 }
 
 // Query Group
@@ -96,9 +105,11 @@ func (s *groupsServer) Query(
 	if _, err := s.authorizer.Authorize(
 		sender.Context(),
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(sender.Context()),
-			Resource:    objectWildcard,
-			Action:      queryAction,
+			PrincipalId:    authz.Subject(sender.Context()),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         queryAction,
 		},
 	); err != nil {
 		return err
@@ -140,9 +151,11 @@ func (s *groupsServer) Delete(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      deleteAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         deleteAction,
 		},
 	); err != nil {
 		return nil, err
@@ -151,7 +164,9 @@ func (s *groupsServer) Delete(
 	if err != nil {
 		return nil, err
 	}
-	return &api.DeleteGroupResponse{}, nil
+	return &api.DeleteGroupResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	}, nil
 }
 
 // AddRoles Group
@@ -162,9 +177,11 @@ func (s *groupsServer) AddRoles(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      updateAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         updateAction,
 		},
 	); err != nil {
 		return nil, err
@@ -177,7 +194,9 @@ func (s *groupsServer) AddRoles(
 		req.RoleIds...); err != nil {
 		return nil, err
 	}
-	return &api.AddRolesToGroupResponse{}, nil
+	return &api.AddRolesToGroupResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	}, nil
 }
 
 // DeleteRoles Group
@@ -188,9 +207,11 @@ func (s *groupsServer) DeleteRoles(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      updateAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         updateAction,
 		},
 	); err != nil {
 		return nil, err
@@ -203,5 +224,7 @@ func (s *groupsServer) DeleteRoles(
 		req.RoleIds...); err != nil {
 		return nil, err
 	}
-	return &api.DeleteRolesToGroupResponse{}, nil
+	return &api.DeleteRolesToGroupResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	}, nil
 }
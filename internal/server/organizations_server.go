@@ -5,6 +5,7 @@ import (
 	api "github.com/bhatti/PlexAuthZ/api/v1/services"
 	"github.com/bhatti/PlexAuthZ/api/v1/types"
 	"github.com/bhatti/PlexAuthZ/internal/authz"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
 	"github.com/bhatti/PlexAuthZ/internal/service"
 	log "github.com/sirupsen/logrus"
 )
@@ -42,10 +43,12 @@ func (s *organizationsServer) Create(
 		return nil, err
 	}
 	organization := &types.Organization{
-		Name:       req.Name,
-		Url:        req.Url,
-		Namespaces: req.Namespaces,
-		ParentIds:  req.ParentIds,
+		Name:           req.Name,
+		Url:            req.Url,
+		Namespaces:     req.Namespaces,
+		ParentIds:      req.ParentIds,
+		RateLimit:      req.RateLimit,
+		AuditRetention: req.AuditRetention,
 	}
 	log.WithFields(log.Fields{
 		"Component": "OrganizationsServer",
@@ -57,7 +60,8 @@ func (s *organizationsServer) Create(
 		return nil, err
 	}
 	return &api.CreateOrganizationResponse{
-		Id: savedOrganization.Id,
+		Id:               savedOrganization.Id,
+		ConsistencyToken: domain.NewConsistencyToken(),
 	}, nil
 }
 
@@ -75,19 +79,23 @@ func (s *organizationsServer) Update(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      updateAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.Id,
+			Resource:       objectWildcard,
+			Action:         updateAction,
 		},
 	); err != nil {
 		return nil, err
 	}
 	organization := &types.Organization{
-		Id:         req.Id,
-		Name:       req.Name,
-		Url:        req.Url,
-		Namespaces: req.Namespaces,
-		ParentIds:  req.ParentIds,
+		Id:             req.Id,
+		Version:        req.Version,
+		Name:           req.Name,
+		Url:            req.Url,
+		Namespaces:     req.Namespaces,
+		ParentIds:      req.ParentIds,
+		RateLimit:      req.RateLimit,
+		AuditRetention: req.AuditRetention,
 	}
 	log.WithFields(log.Fields{
 		"Component": "OrganizationsServer",
@@ -98,7 +106,9 @@ func (s *organizationsServer) Update(
 	if err != nil {
 		return nil, err
 	}
-	return &api.UpdateOrganizationResponse{}, nil
+	return &api.UpdateOrganizationResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	}, nil
 }
 
 // Get Organization swagger:route GET /api/organizations/{id} organizations getOrganizationRequest
@@ -108,9 +118,10 @@ func (s *organizationsServer) Get(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      queryAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.Id,
+			Resource:       objectWildcard,
+			Action:         queryAction,
 		},
 	); err != nil {
 		return nil, err
@@ -124,14 +135,16 @@ func (s *organizationsServer) Get(
 	}
 
 	return &api.GetOrganizationResponse{
-		Id:         organization.Id,
-		Version:    organization.Version,
-		Name:       organization.Name,
-		Url:        organization.Url,
-		Namespaces: organization.Namespaces,
-		ParentIds:  organization.ParentIds,
-		Created:    organization.Created,
-		Updated:    organization.Updated,
+		Id:             organization.Id,
+		Version:        organization.Version,
+		Name:           organization.Name,
+		Url:            organization.Url,
+		Namespaces:     organization.Namespaces,
+		ParentIds:      organization.ParentIds,
+		RateLimit:      organization.RateLimit,
+		AuditRetention: organization.AuditRetention,
+		Created:        organization.Created,
+		Updated:        organization.Updated,
 	}, nil
 }
 
@@ -140,12 +153,19 @@ func (s *organizationsServer) Query(
 	req *api.QueryOrganizationRequest,
 	sender api.OrganizationsService_QueryServer,
 ) error {
+	// scoped clients can only list the organization of their scope
+	scope := authz.Scope(sender.Context())
+	organizationId := ""
+	if scope != nil {
+		organizationId = scope.OrganizationId
+	}
 	if _, err := s.authorizer.Authorize(
 		sender.Context(),
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(sender.Context()),
-			Resource:    objectWildcard,
-			Action:      queryAction,
+			PrincipalId:    authz.Subject(sender.Context()),
+			OrganizationId: organizationId,
+			Resource:       objectWildcard,
+			Action:         queryAction,
 		},
 	); err != nil {
 		return err
@@ -160,17 +180,22 @@ func (s *organizationsServer) Query(
 	}
 
 	for _, organization := range res {
+		if !scope.Permits(organization.Id, "") {
+			continue
+		}
 		err = sender.Send(
 			&api.QueryOrganizationResponse{
-				Id:         organization.Id,
-				Version:    organization.Version,
-				Name:       organization.Name,
-				Url:        organization.Url,
-				Namespaces: organization.Namespaces,
-				ParentIds:  organization.ParentIds,
-				Created:    organization.Created,
-				Updated:    organization.Updated,
-				NextOffset: nextOffset,
+				Id:             organization.Id,
+				Version:        organization.Version,
+				Name:           organization.Name,
+				Url:            organization.Url,
+				Namespaces:     organization.Namespaces,
+				ParentIds:      organization.ParentIds,
+				RateLimit:      organization.RateLimit,
+				AuditRetention: organization.AuditRetention,
+				Created:        organization.Created,
+				Updated:        organization.Updated,
+				NextOffset:     nextOffset,
 			})
 		if err != nil {
 			return err
@@ -187,9 +212,10 @@ func (s *organizationsServer) Delete(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      deleteAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.Id,
+			Resource:       objectWildcard,
+			Action:         deleteAction,
 		},
 	); err != nil {
 		return nil, err
@@ -203,5 +229,7 @@ func (s *organizationsServer) Delete(
 	if err != nil {
 		return nil, err
 	}
-	return &api.DeleteOrganizationResponse{}, nil
+	return &api.DeleteOrganizationResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	}, nil
 }
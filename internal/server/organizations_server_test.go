@@ -2,11 +2,29 @@ package server
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"github.com/bhatti/PlexAuthZ/api/v1/services"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
 	"github.com/bhatti/PlexAuthZ/internal/domain"
 	"github.com/stretchr/testify/require"
+	"github.com/twinj/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"io"
+	"math/big"
+	"net/url"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func Test_ShouldCreateAndGetAndDeleteOrganization(t *testing.T) {
@@ -86,3 +104,239 @@ func testShouldCRUDOrganizations(t *testing.T, clients Clients) {
 	})
 	require.Error(t, err)
 }
+
+func Test_ShouldLimitScopedClientToOrganization(t *testing.T) {
+	// GIVEN root client and a root client scoped to an organization and its admin namespace
+	err := os.Setenv("CONFIG_DIR", "../../config")
+	require.NoError(t, err)
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	cfg.GrpcSasl = true
+	ctx := context.Background()
+	rootClients, teardown := SetupGrpcServerForTesting(t, cfg, domain.RootClientType, nil)
+	defer teardown()
+	org, err := rootClients.OrganizationsClient.Create(ctx, &services.CreateOrganizationRequest{
+		Name:       "scoped-org",
+		Namespaces: []string{"admin", "finance"},
+	})
+	require.NoError(t, err)
+	otherOrg, err := rootClients.OrganizationsClient.Create(ctx, &services.CreateOrganizationRequest{
+		Name:       "other-org",
+		Namespaces: []string{"admin"},
+	})
+	require.NoError(t, err)
+	caFile, err := cfg.CAFile()
+	require.NoError(t, err)
+	certFile, keyFile := newScopedClientCert(t, cfg, "root", domain.ClientScopeURI(org.Id, "admin"))
+	cc, clients, err := NewClients(caFile, certFile, keyFile, cfg.GrpcListenPort)
+	require.NoError(t, err)
+	defer func() {
+		_ = cc.Close()
+	}()
+
+	// WHEN accessing organization and namespace within scope
+	_, err = clients.OrganizationsClient.Get(ctx, &services.GetOrganizationRequest{Id: org.Id})
+	// THEN it should succeed
+	require.NoError(t, err)
+	_, err = clients.GroupsClient.Create(ctx, &services.CreateGroupRequest{
+		OrganizationId: org.Id, Namespace: "admin", Name: "group"})
+	require.NoError(t, err)
+	_, err = clients.PrincipalsClient.Create(ctx, &services.CreatePrincipalRequest{
+		OrganizationId: org.Id, Namespaces: []string{"admin"}, Username: "scoped-user"})
+	require.NoError(t, err)
+	groups, err := clients.GroupsClient.Query(ctx, &services.QueryGroupRequest{
+		OrganizationId: org.Id, Namespace: "admin"})
+	require.NoError(t, err)
+	group, err := groups.Recv()
+	require.NoError(t, err)
+	require.Equal(t, "group", group.Name)
+	principals, err := clients.PrincipalsClient.Query(ctx, &services.QueryPrincipalRequest{
+		OrganizationId: org.Id})
+	require.NoError(t, err)
+	_, err = principals.Recv()
+	require.NoError(t, err)
+	resources, err := clients.ResourcesClient.Query(ctx, &services.QueryResourceRequest{
+		OrganizationId: org.Id, Namespace: "admin"})
+	require.NoError(t, err)
+	_, err = resources.Recv()
+	require.Equal(t, io.EOF, err)
+	// AND organizations should be limited to the scope
+	orgs, err := clients.OrganizationsClient.Query(ctx, &services.QueryOrganizationRequest{})
+	require.NoError(t, err)
+	count := 0
+	for {
+		next, err := orgs.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		require.Equal(t, org.Id, next.Id)
+		count++
+	}
+	require.Equal(t, 1, count)
+
+	// WHEN accessing namespace or organization outside of scope
+	_, err = clients.GroupsClient.Create(ctx, &services.CreateGroupRequest{
+		OrganizationId: org.Id, Namespace: "finance", Name: "group"})
+	// THEN it should fail
+	require.Error(t, err)
+	_, err = clients.OrganizationsClient.Get(ctx, &services.GetOrganizationRequest{Id: otherOrg.Id})
+	require.Error(t, err)
+	_, err = clients.GroupsClient.Create(ctx, &services.CreateGroupRequest{
+		OrganizationId: otherOrg.Id, Namespace: "admin", Name: "group"})
+	require.Error(t, err)
+	groups, err = clients.GroupsClient.Query(ctx, &services.QueryGroupRequest{
+		OrganizationId: otherOrg.Id, Namespace: "admin"})
+	require.NoError(t, err)
+	_, err = groups.Recv()
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	groups, err = clients.GroupsClient.Query(ctx, &services.QueryGroupRequest{
+		OrganizationId: org.Id, Namespace: "finance"})
+	require.NoError(t, err)
+	_, err = groups.Recv()
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = clients.OrganizationsClient.Create(ctx, &services.CreateOrganizationRequest{
+		Name: "new-org", Namespaces: []string{"admin"}})
+	require.Error(t, err)
+}
+
+func Test_ShouldAuthorizeAdminClientsWithSystemOrganization(t *testing.T) {
+	// GIVEN server that authorizes admin clients with principals of the system organization
+	err := os.Setenv("CONFIG_DIR", "../../config")
+	require.NoError(t, err)
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	cfg.GrpcSasl = true
+	cfg.SystemAuth.Enabled = true
+	cfg.SystemAuth.OrganizationName = "system-" + uuid.NewV4().String()
+	ctx := context.Background()
+	rootClients, teardown := SetupGrpcServerForTesting(t, cfg, domain.RootClientType, nil)
+	defer teardown()
+	caFile, err := cfg.CAFile()
+	require.NoError(t, err)
+	tlsC, err := cfg.TLSClient()
+	require.NoError(t, err)
+	cc, clients, err := NewClients(caFile, tlsC.CertFile, tlsC.KeyFile, cfg.GrpcListenPort)
+	require.NoError(t, err)
+	defer func() {
+		_ = cc.Close()
+	}()
+
+	// WHEN root creates an organization
+	org, err := rootClients.OrganizationsClient.Create(ctx, &services.CreateOrganizationRequest{
+		Name:       "system-authorized-org",
+		Namespaces: []string{"admin"},
+	})
+	// THEN it should succeed
+	require.NoError(t, err)
+
+	// AND client should only be able to query it
+	_, err = clients.OrganizationsClient.Get(ctx, &services.GetOrganizationRequest{Id: org.Id})
+	require.NoError(t, err)
+	_, err = clients.OrganizationsClient.Create(ctx, &services.CreateOrganizationRequest{
+		Name: "new-org", Namespaces: []string{"admin"}})
+	require.Error(t, err)
+
+	// WHEN root grants admin role of the system organization to client with the same APIs
+	systemOrgs, err := rootClients.OrganizationsClient.Query(ctx, &services.QueryOrganizationRequest{
+		Predicates: map[string]string{"name": cfg.SystemAuth.OrganizationName}})
+	require.NoError(t, err)
+	systemOrg, err := systemOrgs.Recv()
+	require.NoError(t, err)
+	principals, err := rootClients.PrincipalsClient.Query(ctx, &services.QueryPrincipalRequest{
+		OrganizationId: systemOrg.Id, Predicates: map[string]string{"username": "client"}})
+	require.NoError(t, err)
+	principal, err := principals.Recv()
+	require.NoError(t, err)
+	roles, err := rootClients.RolesClient.Query(ctx, &services.QueryRoleRequest{
+		OrganizationId: systemOrg.Id, Namespace: cfg.SystemAuth.Namespace,
+		Predicates: map[string]string{"name": "admin"}})
+	require.NoError(t, err)
+	role, err := roles.Recv()
+	require.NoError(t, err)
+	_, err = rootClients.PrincipalsClient.AddRoles(ctx, &services.AddRolesToPrincipalRequest{
+		OrganizationId: systemOrg.Id, Namespace: cfg.SystemAuth.Namespace,
+		PrincipalId: principal.Id, RoleIds: []string{role.Id}})
+	require.NoError(t, err)
+
+	// THEN client should be able to create organizations
+	_, err = clients.OrganizationsClient.Create(ctx, &services.CreateOrganizationRequest{
+		Name: "new-org", Namespaces: []string{"admin"}})
+	require.NoError(t, err)
+}
+
+func newScopedClientCert(t *testing.T, cfg *domain.Config, cn string, uris ...*url.URL) (string, string) {
+	caCertFile, err := cfg.CAFile()
+	require.NoError(t, err)
+	caKeyFile, err := configFileForTesting(cfg, "ca-key.pem")
+	require.NoError(t, err)
+	ca, err := tls.LoadX509KeyPair(caCertFile, caKeyFile)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(ca.Certificate[0])
+	require.NoError(t, err)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		URIs:         uris,
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, ca.PrivateKey)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "scoped-client.pem")
+	keyFile := filepath.Join(dir, "scoped-client-key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return certFile, keyFile
+}
+
+func configFileForTesting(cfg *domain.Config, name string) (string, error) {
+	caFile, err := cfg.CAFile()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(caFile), name), nil
+}
+
+func Test_ShouldRateLimitOrganizations(t *testing.T) {
+	// GIVEN server with rate limits
+	err := os.Setenv("CONFIG_DIR", "../../config")
+	require.NoError(t, err)
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	cfg.GrpcSasl = true
+	cfg.RateLimit.Enabled = true
+	ctx := context.Background()
+	clients, teardown := SetupGrpcServerForTesting(t, cfg, domain.RootClientType, nil)
+	defer teardown()
+
+	// AND organization with a lower rate limit
+	org, err := clients.OrganizationsClient.Create(ctx, &services.CreateOrganizationRequest{
+		Name:       "rate-limited-org",
+		Namespaces: []string{"default"},
+		RateLimit:  &types.RateLimit{RequestsPerSecond: 0.001, Burst: 1},
+	})
+	require.NoError(t, err)
+
+	// WHEN getting organization within its limit
+	res, err := clients.OrganizationsClient.Get(ctx, &services.GetOrganizationRequest{Id: org.Id})
+	// THEN it should succeed
+	require.NoError(t, err)
+	require.Equal(t, int32(1), res.RateLimit.Burst)
+
+	// WHEN exceeding the limit
+	var header metadata.MD
+	_, err = clients.OrganizationsClient.Get(
+		ctx, &services.GetOrganizationRequest{Id: org.Id}, grpc.Header(&header))
+	// THEN it should fail with resource exhausted and retry-after
+	require.Error(t, err)
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	require.NotEmpty(t, header.Get(RetryAfterHeader))
+}
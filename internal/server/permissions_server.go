@@ -5,6 +5,7 @@ import (
 	api "github.com/bhatti/PlexAuthZ/api/v1/services"
 	"github.com/bhatti/PlexAuthZ/api/v1/types"
 	"github.com/bhatti/PlexAuthZ/internal/authz"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
 	"github.com/bhatti/PlexAuthZ/internal/service"
 )
 
@@ -33,9 +34,11 @@ func (s *permissionsServer) Create(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      updateAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         updateAction,
 		},
 	); err != nil {
 		return nil, err
@@ -53,7 +56,8 @@ func (s *permissionsServer) Create(
 		return nil, err
 	}
 	return &api.CreatePermissionResponse{
-		Id: permission.Id,
+		Id:               permission.Id,
+		ConsistencyToken: domain.NewConsistencyToken(),
 	}, nil
 }
 
@@ -65,15 +69,18 @@ func (s *permissionsServer) Update(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      updateAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         updateAction,
 		},
 	); err != nil {
 		return nil, err
 	}
 	permission := &types.Permission{
 		Id:          req.Id,
+		Version:     req.Version,
 		Namespace:   req.Namespace,
 		Scope:       req.Scope,
 		Actions:     req.Actions,
@@ -84,7 +91,9 @@ func (s *permissionsServer) Update(
 	if err := s.authAdminService.UpdatePermission(ctx, req.OrganizationId, permission); err != nil {
 		return nil, err
 	}
-	return &api.UpdatePermissionResponse{}, nil
+	return &api.UpdatePermissionResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	}, nil
 }
 
 // Query Permission swagger:route GET /api/{organization_id}/{namespace}/permissions/{id} permissions queryPermissionRequest
@@ -101,9 +110,11 @@ func (s *permissionsServer) Query(
 	if _, err := s.authorizer.Authorize(
 		sender.Context(),
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(sender.Context()),
-			Resource:    objectWildcard,
-			Action:      deleteAction,
+			PrincipalId:    authz.Subject(sender.Context()),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         queryAction,
 		},
 	); err != nil {
 		return err
@@ -148,9 +159,11 @@ func (s *permissionsServer) Delete(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      deleteAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         deleteAction,
 		},
 	); err != nil {
 		return nil, err
@@ -159,5 +172,7 @@ func (s *permissionsServer) Delete(
 	if err != nil {
 		return nil, err
 	}
-	return &api.DeletePermissionResponse{}, nil
+	return &api.DeletePermissionResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	}, nil
 }
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	api "github.com/bhatti/PlexAuthZ/api/v1/services"
+	"github.com/bhatti/PlexAuthZ/internal/authz"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+)
+
+type policiesServer struct {
+	api.PoliciesServiceServer
+	authorizer    authz.Authorizer
+	policyManager authz.PolicyManager
+}
+
+// NewPoliciesServer constructor for managing casbin policies of admin APIs, which requires
+// authorizer that stores policies in the data store.
+func NewPoliciesServer(
+	authorizer authz.Authorizer,
+) (api.PoliciesServiceServer, error) {
+	policyManager, _ := authorizer.(authz.PolicyManager)
+	return &policiesServer{
+		authorizer:    authorizer,
+		policyManager: policyManager,
+	}, nil
+}
+
+// Add Policy
+func (s *policiesServer) Add(
+	ctx context.Context,
+	req *api.AddPolicyRequest,
+) (*api.AddPolicyResponse, error) {
+	if _, err := s.authorizer.Authorize(
+		ctx,
+		&api.AuthRequest{
+			PrincipalId: authz.Subject(ctx),
+			Resource:    objectWildcard,
+			Action:      updateAction,
+		},
+	); err != nil {
+		return nil, err
+	}
+	if s.policyManager == nil {
+		return nil, domain.NewValidationError("casbin policies are not stored in the data store")
+	}
+	policy, err := s.policyManager.AddPolicy(ctx, req.Ptype, req.Rule)
+	if err != nil {
+		return nil, err
+	}
+	return &api.AddPolicyResponse{
+		Id: policy.Id,
+	}, nil
+}
+
+// Remove Policy
+func (s *policiesServer) Remove(
+	ctx context.Context,
+	req *api.RemovePolicyRequest,
+) (*api.RemovePolicyResponse, error) {
+	if _, err := s.authorizer.Authorize(
+		ctx,
+		&api.AuthRequest{
+			PrincipalId: authz.Subject(ctx),
+			Resource:    objectWildcard,
+			Action:      deleteAction,
+		},
+	); err != nil {
+		return nil, err
+	}
+	if s.policyManager == nil {
+		return nil, domain.NewValidationError("casbin policies are not stored in the data store")
+	}
+	if err := s.policyManager.RemovePolicy(ctx, req.Ptype, req.Rule); err != nil {
+		return nil, err
+	}
+	return &api.RemovePolicyResponse{}, nil
+}
+
+// Query Policies
+func (s *policiesServer) Query(
+	req *api.QueryPolicyRequest,
+	sender api.PoliciesService_QueryServer,
+) error {
+	if _, err := s.authorizer.Authorize(
+		sender.Context(),
+		&api.AuthRequest{
+			PrincipalId: authz.Subject(sender.Context()),
+			Resource:    objectWildcard,
+			Action:      queryAction,
+		},
+	); err != nil {
+		return err
+	}
+	if s.policyManager == nil {
+		return domain.NewValidationError("casbin policies are not stored in the data store")
+	}
+	res, err := s.policyManager.GetPolicies(sender.Context(), req.Ptype)
+	if err != nil {
+		return err
+	}
+	for _, policy := range res {
+		err = sender.Send(
+			&api.QueryPolicyResponse{
+				Id:      policy.Id,
+				Ptype:   policy.PType,
+				Rule:    policy.Rule,
+				Created: policy.Created,
+			})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"github.com/bhatti/PlexAuthZ/api/v1/services"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/stretchr/testify/require"
+	"github.com/twinj/uuid"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func Test_ShouldAddAndRemovePolicies(t *testing.T) {
+	// GIVEN servers sharing casbin policies in the data store
+	err := os.Setenv("CONFIG_DIR", "../../config")
+	require.NoError(t, err)
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	cfg.GrpcSasl = true
+	cfg.CasbinPolicy.Store = domain.DataStoreCasbinPolicyStore
+	cfg.CasbinPolicy.Tenant = "casbin-" + uuid.NewV4().String()
+	cfg.CasbinPolicy.ReloadInterval = 50 * time.Millisecond
+	ctx := context.Background()
+	rootClients, rootTeardown := SetupGrpcServerForTesting(t, cfg, domain.RootClientType, nil)
+	defer rootTeardown()
+	clients, teardown := SetupGrpcServerForTesting(t, cfg, domain.DefaultClientType, nil)
+	defer teardown()
+	createOrg := &services.CreateOrganizationRequest{
+		Name:       "org-name",
+		Namespaces: []string{"admin"},
+	}
+
+	// WHEN client creates organization without policy
+	_, err = clients.OrganizationsClient.Create(ctx, createOrg)
+	// THEN it should fail
+	require.Error(t, err)
+
+	// WHEN client adds policy for itself
+	_, err = clients.PoliciesClient.Add(ctx, &services.AddPolicyRequest{
+		Rule: []string{"client", "*", "update"},
+	})
+	// THEN it should fail
+	require.Error(t, err)
+
+	// WHEN root adds policy for client
+	addRes, err := rootClients.PoliciesClient.Add(ctx, &services.AddPolicyRequest{
+		Rule: []string{"client", "*", "update"},
+	})
+	// THEN it should succeed
+	require.NoError(t, err)
+	require.NotEmpty(t, addRes.Id)
+	res, err := rootClients.PoliciesClient.Query(ctx, &services.QueryPolicyRequest{Ptype: "p"})
+	require.NoError(t, err)
+	ids := make(map[string]bool)
+	for {
+		policy, err := res.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		ids[policy.Id] = true
+	}
+	require.True(t, ids[addRes.Id])
+
+	// AND client should be able to create organization after reload
+	require.Eventually(t, func() bool {
+		_, err := clients.OrganizationsClient.Create(ctx, createOrg)
+		return err == nil
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// WHEN root removes policy for client
+	_, err = rootClients.PoliciesClient.Remove(ctx, &services.RemovePolicyRequest{
+		Rule: []string{"client", "*", "update"},
+	})
+	// THEN client should not be able to create organization after reload
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		_, err := clients.OrganizationsClient.Create(ctx, createOrg)
+		return err != nil
+	}, 5*time.Second, 50*time.Millisecond)
+}
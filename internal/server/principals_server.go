@@ -5,6 +5,7 @@ import (
 	api "github.com/bhatti/PlexAuthZ/api/v1/services"
 	"github.com/bhatti/PlexAuthZ/api/v1/types"
 	"github.com/bhatti/PlexAuthZ/internal/authz"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
 	"github.com/bhatti/PlexAuthZ/internal/service"
 )
 
@@ -33,9 +34,10 @@ func (s *principalsServer) Create(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      updateAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.OrganizationId,
+			Resource:       objectWildcard,
+			Action:         updateAction,
 		},
 	); err != nil {
 		return nil, err
@@ -52,7 +54,8 @@ func (s *principalsServer) Create(
 		return nil, err
 	}
 	return &api.CreatePrincipalResponse{
-		Id: principal.Id,
+		Id:               principal.Id,
+		ConsistencyToken: domain.NewConsistencyToken(),
 	}, nil
 }
 
@@ -64,15 +67,17 @@ func (s *principalsServer) Update(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      updateAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.OrganizationId,
+			Resource:       objectWildcard,
+			Action:         updateAction,
 		},
 	); err != nil {
 		return nil, err
 	}
 	principal := &types.Principal{
 		Id:             req.Id,
+		Version:        req.Version,
 		OrganizationId: req.OrganizationId,
 		Namespaces:     req.Namespaces,
 		Username:       req.Username,
@@ -82,7 +87,9 @@ func (s *principalsServer) Update(
 	if err := s.authAdminService.UpdatePrincipal(ctx, principal); err != nil {
 		return nil, err
 	}
-	return &api.UpdatePrincipalResponse{}, nil
+	return &api.UpdatePrincipalResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	}, nil
 }
 
 // Get Principal
@@ -93,9 +100,11 @@ func (s *principalsServer) Get(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      updateAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         updateAction,
 		},
 	); err != nil {
 		return nil, err
@@ -139,9 +148,10 @@ func (s *principalsServer) Query(
 	if _, err := s.authorizer.Authorize(
 		sender.Context(),
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(sender.Context()),
-			Resource:    objectWildcard,
-			Action:      updateAction,
+			PrincipalId:    authz.Subject(sender.Context()),
+			OrganizationId: req.OrganizationId,
+			Resource:       objectWildcard,
+			Action:         queryAction,
 		},
 	); err != nil {
 		return err
@@ -188,9 +198,10 @@ func (s *principalsServer) Delete(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      deleteAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.OrganizationId,
+			Resource:       objectWildcard,
+			Action:         deleteAction,
 		},
 	); err != nil {
 		return nil, err
@@ -199,7 +210,9 @@ func (s *principalsServer) Delete(
 	if err != nil {
 		return nil, err
 	}
-	return &api.DeletePrincipalResponse{}, nil
+	return &api.DeletePrincipalResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	}, nil
 }
 
 // AddGroups Principal
@@ -210,9 +223,11 @@ func (s *principalsServer) AddGroups(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      updateAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         updateAction,
 		},
 	); err != nil {
 		return nil, err
@@ -226,7 +241,9 @@ func (s *principalsServer) AddGroups(
 	if err != nil {
 		return nil, err
 	}
-	return &api.AddGroupsToPrincipalResponse{}, nil
+	return &api.AddGroupsToPrincipalResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	}, nil
 }
 
 // DeleteGroups Principal
@@ -237,9 +254,11 @@ func (s *principalsServer) DeleteGroups(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      updateAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         updateAction,
 		},
 	); err != nil {
 		return nil, err
@@ -253,7 +272,9 @@ func (s *principalsServer) DeleteGroups(
 	if err != nil {
 		return nil, err
 	}
-	return &api.DeleteGroupsToPrincipalResponse{}, nil
+	return &api.DeleteGroupsToPrincipalResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	}, nil
 }
 
 // AddRoles Principal
@@ -264,9 +285,11 @@ func (s *principalsServer) AddRoles(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      updateAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         updateAction,
 		},
 	); err != nil {
 		return nil, err
@@ -280,7 +303,9 @@ func (s *principalsServer) AddRoles(
 	if err != nil {
 		return nil, err
 	}
-	return &api.AddRolesToPrincipalResponse{}, nil
+	return &api.AddRolesToPrincipalResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	}, nil
 }
 
 // DeleteRoles Principal
@@ -291,9 +316,11 @@ func (s *principalsServer) DeleteRoles(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      updateAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         updateAction,
 		},
 	); err != nil {
 		return nil, err
@@ -307,7 +334,9 @@ func (s *principalsServer) DeleteRoles(
 	if err != nil {
 		return nil, err
 	}
-	return &api.DeleteRolesToPrincipalResponse{}, nil
+	return &api.DeleteRolesToPrincipalResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	}, nil
 }
 
 // AddPermissions Principal
@@ -318,9 +347,11 @@ func (s *principalsServer) AddPermissions(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      updateAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         updateAction,
 		},
 	); err != nil {
 		return nil, err
@@ -334,7 +365,9 @@ func (s *principalsServer) AddPermissions(
 	if err != nil {
 		return nil, err
 	}
-	return &api.AddPermissionsToPrincipalResponse{}, nil
+	return &api.AddPermissionsToPrincipalResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	}, nil
 }
 
 // DeletePermissions Principal
@@ -345,9 +378,11 @@ func (s *principalsServer) DeletePermissions(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      updateAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         updateAction,
 		},
 	); err != nil {
 		return nil, err
@@ -361,7 +396,9 @@ func (s *principalsServer) DeletePermissions(
 	if err != nil {
 		return nil, err
 	}
-	return &api.DeletePermissionsToPrincipalResponse{}, nil
+	return &api.DeletePermissionsToPrincipalResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	}, nil
 }
 
 // AddRelationships Principal
@@ -372,9 +409,11 @@ func (s *principalsServer) AddRelationships(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      updateAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         updateAction,
 		},
 	); err != nil {
 		return nil, err
@@ -388,7 +427,9 @@ func (s *principalsServer) AddRelationships(
 	if err != nil {
 		return nil, err
 	}
-	return &api.AddRelationshipsToPrincipalResponse{}, nil
+	return &api.AddRelationshipsToPrincipalResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	}, nil
 }
 
 // DeleteRelationships Principal
@@ -399,9 +440,11 @@ func (s *principalsServer) DeleteRelationships(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      updateAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         updateAction,
 		},
 	); err != nil {
 		return nil, err
@@ -415,5 +458,7 @@ func (s *principalsServer) DeleteRelationships(
 	if err != nil {
 		return nil, err
 	}
-	return &api.DeleteRelationshipsToPrincipalResponse{}, nil
+	return &api.DeleteRelationshipsToPrincipalResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	}, nil
 }
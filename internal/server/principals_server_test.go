@@ -5,6 +5,8 @@ import (
 	"github.com/bhatti/PlexAuthZ/api/v1/services"
 	"github.com/bhatti/PlexAuthZ/internal/domain"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"os"
 	"testing"
 )
@@ -116,6 +118,33 @@ func testShouldCRUDPrincipals(t *testing.T, clients Clients) {
 	require.Equal(t, "user1", saved.Username)
 	require.Equal(t, "jane", saved.Name)
 
+	// updating with stale version should be aborted
+	_, err = clients.PrincipalsClient.Update(ctx, &services.UpdatePrincipalRequest{
+		Id:             principal.Id,
+		Version:        principal.Version,
+		Username:       "user2",
+		OrganizationId: orgRes.Id,
+		Namespaces:     []string{"admin", "finance"},
+	})
+	require.Equal(t, codes.Aborted, status.Code(err))
+
+	// creating duplicate principal should fail with already exists
+	_, err = clients.PrincipalsClient.Create(ctx, &services.CreatePrincipalRequest{
+		Username:       "user1",
+		OrganizationId: orgRes.Id,
+		Namespaces:     []string{"admin"},
+	})
+	require.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	// streaming unknown principal should fail with not found
+	missingRes, err := clients.PrincipalsClient.Query(ctx, &services.QueryPrincipalRequest{
+		OrganizationId: orgRes.Id,
+		Predicates:     map[string]string{"id": "missing-principal"},
+	})
+	require.NoError(t, err)
+	_, err = missingRes.Recv()
+	require.Equal(t, codes.NotFound, status.Code(err))
+
 	role1, err := clients.RolesClient.Create(ctx, &services.CreateRoleRequest{
 		Name:           "role1",
 		Namespace:      "admin",
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	api "github.com/bhatti/PlexAuthZ/api/v1/services"
+	"github.com/bhatti/PlexAuthZ/internal/authz"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/ratelimit"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"math"
+	"net"
+)
+
+// RetryAfterHeader header with seconds after which rate limited requests may be retried.
+const RetryAfterHeader = "retry-after"
+
+// RateLimitUnaryInterceptor rejects requests with RESOURCE_EXHAUSTED when the organization,
+// principal or client of the request exceeds its rate limit.
+func RateLimitUnaryInterceptor(rateLimiter *ratelimit.RateLimiter) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		_ *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		if err := checkRateLimit(ctx, rateLimiter, req, func(md metadata.MD) error {
+			return grpc.SetHeader(ctx, md)
+		}); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// RateLimitStreamInterceptor rejects messages of streams with RESOURCE_EXHAUSTED when the
+// organization, principal or client of the message exceeds its rate limit.
+func RateLimitStreamInterceptor(rateLimiter *ratelimit.RateLimiter) grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		_ *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		return handler(srv, &rateLimitedStream{ServerStream: ss, rateLimiter: rateLimiter})
+	}
+}
+
+type rateLimitedStream struct {
+	grpc.ServerStream
+	rateLimiter *ratelimit.RateLimiter
+}
+
+// RecvMsg checks rate limit of received message.
+func (s *rateLimitedStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return checkRateLimit(s.Context(), s.rateLimiter, m, s.SetHeader)
+}
+
+func checkRateLimit(
+	ctx context.Context,
+	rateLimiter *ratelimit.RateLimiter,
+	req any,
+	setHeader func(md metadata.MD) error,
+) error {
+	organizationId, principalId := rateLimitRequestIds(req)
+	err := rateLimiter.Allow(ctx, organizationId, principalId, rateLimitClient(ctx))
+	var rateLimitErr *domain.RateLimitError
+	if err == nil || !errors.As(err, &rateLimitErr) {
+		return err
+	}
+	retryAfter := math.Max(1, math.Ceil(rateLimitErr.RetryAfter.Seconds()))
+	_ = setHeader(metadata.Pairs(RetryAfterHeader, fmt.Sprintf("%d", int64(retryAfter))))
+	st := status.New(codes.ResourceExhausted, err.Error())
+	if detailed, detailsErr := st.WithDetails(
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(rateLimitErr.RetryAfter)}); detailsErr == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+func rateLimitRequestIds(req any) (organizationId string, principalId string) {
+	switch r := req.(type) {
+	case *api.GetOrganizationRequest:
+		organizationId = r.Id
+	case *api.UpdateOrganizationRequest:
+		organizationId = r.Id
+	case *api.DeleteOrganizationRequest:
+		organizationId = r.Id
+	case interface{ GetOrganizationId() string }:
+		organizationId = r.GetOrganizationId()
+	}
+	if r, ok := req.(interface{ GetPrincipalId() string }); ok {
+		principalId = r.GetPrincipalId()
+	}
+	return
+}
+
+// rateLimitClient returns authenticated subject or remote host of the client.
+func rateLimitClient(ctx context.Context) string {
+	if subject := authz.Subject(ctx); subject != "" {
+		return subject
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			return host
+		}
+		return p.Addr.String()
+	}
+	return ""
+}
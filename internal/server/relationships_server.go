@@ -5,6 +5,7 @@ import (
 	api "github.com/bhatti/PlexAuthZ/api/v1/services"
 	"github.com/bhatti/PlexAuthZ/api/v1/types"
 	"github.com/bhatti/PlexAuthZ/internal/authz"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
 	"github.com/bhatti/PlexAuthZ/internal/service"
 )
 
@@ -33,9 +34,11 @@ func (s *relationshipsServer) Create(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      updateAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         updateAction,
 		},
 	); err != nil {
 		return nil, err
@@ -52,7 +55,8 @@ func (s *relationshipsServer) Create(
 		return nil, err
 	}
 	return &api.CreateRelationshipResponse{
-		Id: relationship.Id,
+		Id:               relationship.Id,
+		ConsistencyToken: domain.NewConsistencyToken(),
 	}, nil
 }
 
@@ -64,15 +68,18 @@ func (s *relationshipsServer) Update(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      updateAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         updateAction,
 		},
 	); err != nil {
 		return nil, err
 	}
 	relationship := &types.Relationship{
 		Id:          req.Id,
+		Version:     req.Version,
 		Namespace:   req.Namespace,
 		Relation:    req.Relation,
 		PrincipalId: req.PrincipalId,
@@ -82,7 +89,9 @@ func (s *relationshipsServer) Update(
 	if err := s.authAdminService.UpdateRelationship(ctx, req.OrganizationId, relationship); err != nil {
 		return nil, err
 	}
-	return &api.UpdateRelationshipResponse{}, nil
+	return &api.UpdateRelationshipResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	}, nil
 }
 
 // Query Relationship swagger:route GET /api/{organization_id}/{namespace}/relationships/{id} relationships queryRelationshipRequest
@@ -99,9 +108,11 @@ func (s *relationshipsServer) Query(
 	if _, err := s.authorizer.Authorize(
 		sender.Context(),
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(sender.Context()),
-			Resource:    objectWildcard,
-			Action:      deleteAction,
+			PrincipalId:    authz.Subject(sender.Context()),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         queryAction,
 		},
 	); err != nil {
 		return err
@@ -144,9 +155,11 @@ func (s *relationshipsServer) Delete(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      deleteAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         deleteAction,
 		},
 	); err != nil {
 		return nil, err
@@ -155,5 +168,7 @@ func (s *relationshipsServer) Delete(
 	if err != nil {
 		return nil, err
 	}
-	return &api.DeleteRelationshipResponse{}, nil
+	return &api.DeleteRelationshipResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	}, nil
 }
@@ -5,6 +5,7 @@ import (
 	api "github.com/bhatti/PlexAuthZ/api/v1/services"
 	"github.com/bhatti/PlexAuthZ/api/v1/types"
 	"github.com/bhatti/PlexAuthZ/internal/authz"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
 	"github.com/bhatti/PlexAuthZ/internal/service"
 )
 
@@ -33,9 +34,11 @@ func (s *resourcesServer) Create(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      updateAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         updateAction,
 		},
 	); err != nil {
 		return nil, err
@@ -52,7 +55,8 @@ func (s *resourcesServer) Create(
 		return nil, err
 	}
 	return &api.CreateResourceResponse{
-		Id: resource.Id,
+		Id:               resource.Id,
+		ConsistencyToken: domain.NewConsistencyToken(),
 	}, nil
 }
 
@@ -64,15 +68,18 @@ func (s *resourcesServer) Update(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      updateAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         updateAction,
 		},
 	); err != nil {
 		return nil, err
 	}
 	resource := &types.Resource{
 		Id:             req.Id,
+		Version:        req.Version,
 		Namespace:      req.Namespace,
 		Name:           req.Name,
 		Capacity:       req.Capacity,
@@ -82,7 +89,9 @@ func (s *resourcesServer) Update(
 	if err := s.authAdminService.UpdateResource(ctx, req.OrganizationId, resource); err != nil {
 		return nil, err
 	}
-	return &api.UpdateResourceResponse{}, nil
+	return &api.UpdateResourceResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	}, nil
 }
 
 // Query Resource swagger:route GET /api/{organization_id}/{namespace}/resources/{id} resources queryResourceRequest
@@ -99,9 +108,11 @@ func (s *resourcesServer) Query(
 	if _, err := s.authorizer.Authorize(
 		sender.Context(),
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(sender.Context()),
-			Resource:    objectWildcard,
-			Action:      updateAction,
+			PrincipalId:    authz.Subject(sender.Context()),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         queryAction,
 		},
 	); err != nil {
 		return err
@@ -146,9 +157,11 @@ func (s *resourcesServer) Delete(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      deleteAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         deleteAction,
 		},
 	); err != nil {
 		return nil, err
@@ -162,7 +175,9 @@ func (s *resourcesServer) Delete(
 	if err != nil {
 		return nil, err
 	}
-	return &api.DeleteResourceResponse{}, nil
+	return &api.DeleteResourceResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	}, nil
 }
 
 // CountResourceInstances Resources
@@ -173,9 +188,11 @@ func (s *resourcesServer) CountResourceInstances(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      updateAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         updateAction,
 		},
 	); err != nil {
 		return nil, err
@@ -203,9 +220,11 @@ func (s *resourcesServer) QueryResourceInstances(
 	if _, err := s.authorizer.Authorize(
 		sender.Context(),
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(sender.Context()),
-			Resource:    objectWildcard,
-			Action:      updateAction,
+			PrincipalId:    authz.Subject(sender.Context()),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         queryAction,
 		},
 	); err != nil {
 		return err
@@ -233,6 +252,7 @@ func (s *resourcesServer) QueryResourceInstances(
 			NextOffset:  nextToken,
 			Created:     instance.Created,
 			Updated:     instance.Updated,
+			ExpiresAt:   instance.ExpiresAt,
 		})
 		if err != nil {
 			return err
@@ -5,6 +5,7 @@ import (
 	api "github.com/bhatti/PlexAuthZ/api/v1/services"
 	"github.com/bhatti/PlexAuthZ/api/v1/types"
 	"github.com/bhatti/PlexAuthZ/internal/authz"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
 	"github.com/bhatti/PlexAuthZ/internal/service"
 )
 
@@ -33,9 +34,11 @@ func (s *rolesServer) Create(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      updateAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         updateAction,
 		},
 	); err != nil {
 		return nil, err
@@ -51,7 +54,8 @@ func (s *rolesServer) Create(
 		return nil, err
 	}
 	return &api.CreateRoleResponse{
-		Id: role.Id,
+		Id:               role.Id,
+		ConsistencyToken: domain.NewConsistencyToken(),
 	}, nil
 }
 
@@ -63,9 +67,11 @@ func (s *rolesServer) Update(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      updateAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         updateAction,
 		},
 	); err != nil {
 		return nil, err
@@ -73,6 +79,7 @@ func (s *rolesServer) Update(
 
 	role := &types.Role{
 		Id:        req.Id,
+		Version:   req.Version,
 		Namespace: req.Namespace,
 		Name:      req.Name,
 		ParentIds: req.ParentIds,
@@ -80,7 +87,9 @@ func (s *rolesServer) Update(
 	if err := s.authAdminService.UpdateRole(ctx, req.OrganizationId, role); err != nil {
 		return nil, err
 	}
-	return &api.UpdateRoleResponse{}, nil
+	return &api.UpdateRoleResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	}, nil
 }
 
 // Query Role swagger:route GET /api/{organization_id}/{namespace}/roles/{id} roles queryRoleRequest
@@ -97,9 +106,11 @@ func (s *rolesServer) Query(
 	if _, err := s.authorizer.Authorize(
 		sender.Context(),
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(sender.Context()),
-			Resource:    objectWildcard,
-			Action:      updateAction,
+			PrincipalId:    authz.Subject(sender.Context()),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         queryAction,
 		},
 	); err != nil {
 		return err
@@ -143,9 +154,11 @@ func (s *rolesServer) Delete(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      deleteAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         deleteAction,
 		},
 	); err != nil {
 		return nil, err
@@ -155,7 +168,9 @@ func (s *rolesServer) Delete(
 	if err != nil {
 		return nil, err
 	}
-	return &api.DeleteRoleResponse{}, nil
+	return &api.DeleteRoleResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	}, nil
 }
 
 // AddPermissions Role
@@ -166,9 +181,11 @@ func (s *rolesServer) AddPermissions(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      updateAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         updateAction,
 		},
 	); err != nil {
 		return nil, err
@@ -183,7 +200,9 @@ func (s *rolesServer) AddPermissions(
 	if err != nil {
 		return nil, err
 	}
-	return &api.AddPermissionsToRoleResponse{}, nil
+	return &api.AddPermissionsToRoleResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	}, nil
 }
 
 // DeletePermissions Role
@@ -195,9 +214,11 @@ func (s *rolesServer) DeletePermissions(
 	if _, err := s.authorizer.Authorize(
 		ctx,
 		&api.AuthRequest{
-			PrincipalId: authz.Subject(ctx),
-			Resource:    objectWildcard,
-			Action:      updateAction,
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         updateAction,
 		},
 	); err != nil {
 		return nil, err
@@ -212,5 +233,7 @@ func (s *rolesServer) DeletePermissions(
 	if err != nil {
 		return nil, err
 	}
-	return &api.DeletePermissionsToRoleResponse{}, nil
+	return &api.DeletePermissionsToRoleResponse{
+		ConsistencyToken: domain.NewConsistencyToken(),
+	}, nil
 }
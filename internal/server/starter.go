@@ -1,11 +1,20 @@
 package server
 
 import (
+	"crypto/tls"
 	"fmt"
 	api "github.com/bhatti/PlexAuthZ/api/v1/services"
+	"github.com/bhatti/PlexAuthZ/internal/audit"
 	"github.com/bhatti/PlexAuthZ/internal/authz"
+	"github.com/bhatti/PlexAuthZ/internal/decisionlog"
 	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/events"
+	"github.com/bhatti/PlexAuthZ/internal/metrics"
+	"github.com/bhatti/PlexAuthZ/internal/ratelimit"
 	"github.com/bhatti/PlexAuthZ/internal/service"
+	"github.com/bhatti/PlexAuthZ/internal/service/db"
+	"github.com/bhatti/PlexAuthZ/internal/webhook"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	grpc_auth "github.com/grpc-ecosystem/go-grpc-middleware/auth"
 	grpc_zap "github.com/grpc-ecosystem/go-grpc-middleware/logging/zap"
@@ -39,6 +48,7 @@ type GrpcAdapter struct {
 	id         string
 	grpcServer *grpc.Server
 	listener   net.Listener
+	authorizer authz.Authorizer
 }
 
 // Addr for managing address of gRPC server.
@@ -56,6 +66,9 @@ func (a *GrpcAdapter) Close() (err error) {
 	if a.listener != nil {
 		err = a.listener.Close()
 	}
+	if closeable, ok := a.authorizer.(domain.Closeable); ok {
+		_ = closeable.Close()
+	}
 	a.grpcServer = nil
 	a.listener = nil
 	return
@@ -82,17 +95,83 @@ func (a *GrpcAdapter) listen(listenPort string) (err error) {
 }
 
 func (a *GrpcAdapter) registerServers(
+	config *domain.Config,
 	authorizer authz.Authorizer,
-	authService service.AuthAdminService) error {
+	authService service.AuthAdminService,
+	metricsRegistry *metrics.Registry) error {
+	var decisionLogger *decisionlog.Logger
+	if config.DecisionLog.Enabled {
+		logger, err := decisionlog.Shared(config)
+		if err != nil {
+			return err
+		}
+		decisionLogger = logger
+	}
+
 	if srv, err := NewAuthServer(
 		authService,
 		authorizer,
+		decisionLogger,
 	); err == nil {
 		api.RegisterAuthZServiceServer(a.grpcServer, srv)
 	} else {
 		return err
 	}
 
+	if srv, err := NewDecisionsServer(
+		authorizer,
+		decisionLogger,
+	); err == nil {
+		api.RegisterDecisionsServiceServer(a.grpcServer, srv)
+	} else {
+		return err
+	}
+
+	var auditor *audit.Auditor
+	if config.Audit.Enabled {
+		var err error
+		if auditor, err = db.CreateAuditor(config, metricsRegistry); err != nil {
+			return err
+		}
+	}
+
+	if srv, err := NewAuditServer(
+		authorizer,
+		auditor,
+	); err == nil {
+		api.RegisterAuditServiceServer(a.grpcServer, srv)
+	} else {
+		return err
+	}
+
+	broker, err := events.Shared(config, metricsRegistry)
+	if err != nil {
+		return err
+	}
+	if srv, err := NewWatchServer(
+		authorizer,
+		broker,
+	); err == nil {
+		api.RegisterWatchServiceServer(a.grpcServer, srv)
+	} else {
+		return err
+	}
+
+	var webhookManager *webhook.Manager
+	if config.Webhook.Enabled {
+		if webhookManager, err = webhook.Shared(config, metricsRegistry); err != nil {
+			return err
+		}
+	}
+	if srv, err := NewWebhooksServer(
+		authorizer,
+		webhookManager,
+	); err == nil {
+		api.RegisterWebhooksServiceServer(a.grpcServer, srv)
+	} else {
+		return err
+	}
+
 	if srv, err := NewGroupsServer(
 		authService,
 		authorizer,
@@ -155,6 +234,24 @@ func (a *GrpcAdapter) registerServers(
 	} else {
 		return err
 	}
+
+	if srv, err := NewPoliciesServer(
+		authorizer,
+	); err == nil {
+		api.RegisterPoliciesServiceServer(a.grpcServer, srv)
+	} else {
+		return err
+	}
+
+	if srv, err := NewEnvoyAuthServer(
+		config,
+		authService,
+		authorizer,
+	); err == nil {
+		authv3.RegisterAuthorizationServer(a.grpcServer, srv)
+	} else {
+		return err
+	}
 	return nil
 }
 
@@ -162,13 +259,15 @@ func (a *GrpcAdapter) registerServers(
 func StartServers(
 	config *domain.Config,
 	authService service.AuthAdminService,
+	metricsRegistry *metrics.Registry,
+	rateLimiter *ratelimit.RateLimiter,
 	grpcOpts ...grpc.ServerOption) (adapter *GrpcAdapter, err error) {
 	adapter = &GrpcAdapter{}
 	err = adapter.listen(config.GrpcListenPort)
 	if err != nil {
 		return nil, err
 	}
-	err = adapter.startServer(config, authService, grpcOpts)
+	err = adapter.startServer(config, authService, metricsRegistry, rateLimiter, grpcOpts)
 	if err != nil {
 		return nil, err
 	}
@@ -178,19 +277,26 @@ func StartServers(
 func (a *GrpcAdapter) startServer(
 	config *domain.Config,
 	authService service.AuthAdminService,
+	metricsRegistry *metrics.Registry,
+	rateLimiter *ratelimit.RateLimiter,
 	grpcOpts []grpc.ServerOption,
 ) (err error) {
 	var authorizer authz.Authorizer
 	if config.GrpcSasl {
-		serverTLSConfig, err := config.SetupTLSServer(a.Addr().String())
+		serverTLSConfig, err := config.SetupTLSServer(a.Addr().String(), metricsRegistry)
 		if err != nil {
 			log.WithField("Error", err).Fatalf("Could setup TLS for gRPC")
 			return err
 		}
+		if config.GrpcJWT.Enabled() {
+			// client certificates are optional as requests can also use jwt tokens
+			serverTLSConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		}
 		serverCreds := credentials.NewTLS(serverTLSConfig)
 		grpcOpts = append(grpcOpts, grpc.Creds(serverCreds))
-
-		authorizer, err = authz.CreateAuthorizer(authz.CasbinAuthorizerKind, config, authService)
+	}
+	if config.GrpcSasl || config.GrpcJWT.Enabled() {
+		authorizer, err = authz.CreateAuthorizer(authz.AdminAuthorizerKind(config), config, authService)
 		if err != nil {
 			return err
 		}
@@ -224,30 +330,53 @@ func (a *GrpcAdapter) startServer(
 		return err
 	}
 
-	if config.GrpcSasl {
+	var streamInterceptors []grpc.StreamServerInterceptor
+	var unaryInterceptors []grpc.UnaryServerInterceptor
+	if config.GrpcSasl || config.GrpcJWT.Enabled() {
+		authenticate, err := authz.NewAuthenticator(config)
+		if err != nil {
+			return err
+		}
+		streamInterceptors = append(streamInterceptors,
+			grpc_ctxtags.StreamServerInterceptor(),
+			grpc_zap.StreamServerInterceptor(
+				logger, zapOpts...,
+			),
+			grpc_auth.StreamServerInterceptor(
+				authenticate,
+			),
+		)
+		unaryInterceptors = append(unaryInterceptors,
+			grpc_ctxtags.UnaryServerInterceptor(),
+			grpc_zap.UnaryServerInterceptor(
+				logger, zapOpts...,
+			),
+			grpc_auth.UnaryServerInterceptor(
+				authenticate,
+			),
+		)
+	}
+	if rateLimiter != nil {
+		// rate limits are checked after authentication so that clients are keyed by their subjects
+		streamInterceptors = append(streamInterceptors, RateLimitStreamInterceptor(rateLimiter))
+		unaryInterceptors = append(unaryInterceptors, RateLimitUnaryInterceptor(rateLimiter))
+	}
+	if len(unaryInterceptors) > 0 {
 		grpcOpts = append(grpcOpts,
 			grpc.StreamInterceptor(
-				grpc_middleware.ChainStreamServer(
-					grpc_ctxtags.StreamServerInterceptor(),
-					grpc_zap.StreamServerInterceptor(
-						logger, zapOpts...,
-					),
-					grpc_auth.StreamServerInterceptor(
-						authz.Authenticate,
-					),
-				)), grpc.UnaryInterceptor(
-				grpc_middleware.ChainUnaryServer(
-					grpc_ctxtags.UnaryServerInterceptor(),
-					grpc_zap.UnaryServerInterceptor(
-						logger, zapOpts...,
-					),
-					grpc_auth.UnaryServerInterceptor(
-						authz.Authenticate,
-					),
-				)),
+				grpc_middleware.ChainStreamServer(streamInterceptors...),
+			),
+			grpc.UnaryInterceptor(
+				grpc_middleware.ChainUnaryServer(unaryInterceptors...),
+			),
 			grpc.StatsHandler(&ocgrpc.ServerHandler{}),
 		)
 	}
+	// domain errors are mapped innermost so that other interceptors observe the status code
+	grpcOpts = append(grpcOpts,
+		grpc.ChainUnaryInterceptor(StatusUnaryInterceptor()),
+		grpc.ChainStreamInterceptor(StatusStreamInterceptor()),
+	)
 
 	if config.Debug {
 		logger, err := zap.NewDevelopment()
@@ -280,8 +409,9 @@ func (a *GrpcAdapter) startServer(
 		}
 	}
 	a.grpcServer = grpc.NewServer(grpcOpts...)
+	a.authorizer = authorizer
 
-	if err = a.registerServers(authorizer, authService); err != nil {
+	if err = a.registerServers(config, authorizer, authService, metricsRegistry); err != nil {
 		return err
 	}
 
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// StatusUnaryInterceptor maps domain errors of unary calls to gRPC status codes, e.g., ABORTED when
+// a record was changed concurrently so that clients can read the record again and retry the request.
+func StatusUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		_ *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		res, err := handler(ctx, req)
+		if err != nil {
+			return nil, toStatusError(err)
+		}
+		return res, nil
+	}
+}
+
+// StatusStreamInterceptor maps domain errors of streaming calls to gRPC status codes.
+func StatusStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		_ *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if err := handler(srv, ss); err != nil {
+			return toStatusError(err)
+		}
+		return nil
+	}
+}
+
+// toStatusError returns gRPC status for conflict, duplicate and not-found errors, and the error
+// as is otherwise.
+func toStatusError(err error) error {
+	var conflictErr *domain.ConflictError
+	var duplicateErr *domain.DuplicateError
+	var notFoundErr *domain.NotFoundError
+	if errors.As(err, &conflictErr) {
+		return status.New(codes.Aborted, err.Error()).Err()
+	} else if errors.As(err, &duplicateErr) {
+		return status.New(codes.AlreadyExists, err.Error()).Err()
+	} else if errors.As(err, &notFoundErr) {
+		return status.New(codes.NotFound, err.Error()).Err()
+	}
+	return err
+}
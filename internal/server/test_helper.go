@@ -3,6 +3,7 @@ package server
 import (
 	"github.com/bhatti/PlexAuthZ/internal/domain"
 	"github.com/bhatti/PlexAuthZ/internal/metrics"
+	"github.com/bhatti/PlexAuthZ/internal/ratelimit"
 	"github.com/bhatti/PlexAuthZ/internal/service/db"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
@@ -48,11 +49,14 @@ func SetupGrpcServerForTesting(
 		fn(cfg)
 	}
 
-	authService, _, err := db.CreateDatabaseAuthService(cfg, metrics.New())
+	metricsRegistry := metrics.New()
+	authService, _, err := db.CreateDatabaseAuthService(cfg, metricsRegistry)
+	require.NoError(t, err)
+	rateLimiter, err := ratelimit.CreateRateLimiter(cfg, authService, metricsRegistry)
 	require.NoError(t, err)
 
 	opts := make([]grpc.ServerOption, 0)
-	err = adapter.startServer(cfg, authService, opts)
+	err = adapter.startServer(cfg, authService, metricsRegistry, rateLimiter, opts)
 	require.NoError(t, err)
 
 	go func() {
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"fmt"
+	api "github.com/bhatti/PlexAuthZ/api/v1/services"
+	"github.com/bhatti/PlexAuthZ/internal/authz"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/events"
+	"google.golang.org/grpc/metadata"
+	"strconv"
+)
+
+// WatchCursorHeader header of watch streams with cursor of the last change when the watch is started.
+const WatchCursorHeader = "x-plexauthz-cursor"
+
+type watchServer struct {
+	api.WatchServiceServer
+	authorizer authz.Authorizer
+	broker     *events.Broker
+}
+
+// NewWatchServer constructor for streaming change events of organizations.
+func NewWatchServer(
+	authorizer authz.Authorizer,
+	broker *events.Broker,
+) (api.WatchServiceServer, error) {
+	return &watchServer{
+		authorizer: authorizer,
+		broker:     broker,
+	}, nil
+}
+
+// Watch Changes
+func (s *watchServer) Watch(
+	req *api.WatchRequest,
+	sender api.WatchService_WatchServer,
+) error {
+	if _, err := s.authorizer.Authorize(
+		sender.Context(),
+		&api.AuthRequest{
+			PrincipalId:    authz.Subject(sender.Context()),
+			OrganizationId: req.OrganizationId,
+			Namespace:      req.Namespace,
+			Resource:       objectWildcard,
+			Action:         queryAction,
+		},
+	); err != nil {
+		return err
+	}
+	sub, replay, err := s.broker.Subscribe(req.OrganizationId, req.Namespace, req.EntityTypes, req.Cursor)
+	if err != nil {
+		return err
+	}
+	defer s.broker.Unsubscribe(sub)
+	// headers notify clients that the watch is started along with cursor of the last change
+	if err = sender.SendHeader(metadata.Pairs(
+		WatchCursorHeader, strconv.FormatInt(s.broker.Cursor(), 10))); err != nil {
+		return err
+	}
+	cursor := req.Cursor
+	for _, event := range replay {
+		if err = sender.Send(&api.WatchResponse{Event: event}); err != nil {
+			return err
+		}
+		cursor = event.Cursor
+	}
+	for {
+		select {
+		case <-sender.Context().Done():
+			return nil
+		case event, ok := <-sub.Events():
+			if !ok {
+				return domain.NewValidationError(
+					fmt.Sprintf("watch fell behind changes, resume from cursor %d", cursor))
+			}
+			if event.Cursor <= cursor {
+				continue
+			}
+			if err = sender.Send(&api.WatchResponse{Event: event}); err != nil {
+				return err
+			}
+			cursor = event.Cursor
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"github.com/bhatti/PlexAuthZ/api/v1/services"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+func Test_ShouldWatchChangesOfRoles(t *testing.T) {
+	// GIVEN server and organization
+	err := os.Setenv("CONFIG_DIR", "../../config")
+	require.NoError(t, err)
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	cfg.GrpcSasl = true
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clients, teardown := SetupGrpcServerForTesting(t, cfg, domain.RootClientType, nil)
+	defer teardown()
+	orgRes, err := clients.OrganizationsClient.Create(ctx, &services.CreateOrganizationRequest{
+		Name:       "watch-org",
+		Namespaces: []string{"admin"},
+	})
+	require.NoError(t, err)
+	watch, err := clients.WatchClient.Watch(ctx, &services.WatchRequest{
+		OrganizationId: orgRes.Id,
+		EntityTypes:    []types.ChangeEntityType{types.ChangeEntityType_ROLE},
+	})
+	require.NoError(t, err)
+	// wait for subscription of the watch with the header of the stream
+	header, err := watch.Header()
+	require.NoError(t, err)
+	require.Len(t, header.Get(WatchCursorHeader), 1)
+
+	// WHEN creating and updating role
+	roleRes, err := clients.RolesClient.Create(ctx, &services.CreateRoleRequest{
+		Name:           "role-name",
+		Namespace:      "admin",
+		OrganizationId: orgRes.Id,
+	})
+	require.NoError(t, err)
+	_, err = clients.RolesClient.Update(ctx, &services.UpdateRoleRequest{
+		Id:             roleRes.Id,
+		Name:           "new-name",
+		OrganizationId: orgRes.Id,
+		Namespace:      "admin",
+	})
+	require.NoError(t, err)
+
+	// THEN watch should receive the changes
+	created, err := watch.Recv()
+	require.NoError(t, err)
+	require.Equal(t, types.ChangeOperation_CREATED, created.Event.Operation)
+	require.Equal(t, roleRes.Id, created.Event.EntityId)
+	require.Equal(t, "admin", created.Event.Namespace)
+	updated, err := watch.Recv()
+	require.NoError(t, err)
+	require.Equal(t, types.ChangeOperation_UPDATED, updated.Event.Operation)
+	require.True(t, updated.Event.Version > created.Event.Version)
+
+	// AND watch should be resumed from cursor of the first change
+	resumed, err := clients.WatchClient.Watch(ctx, &services.WatchRequest{
+		OrganizationId: orgRes.Id,
+		Namespace:      "admin",
+		Cursor:         created.Event.Cursor,
+	})
+	require.NoError(t, err)
+	next, err := resumed.Recv()
+	require.NoError(t, err)
+	require.Equal(t, updated.Event.Cursor, next.Event.Cursor)
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"context"
+	api "github.com/bhatti/PlexAuthZ/api/v1/services"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/authz"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/webhook"
+)
+
+type webhooksServer struct {
+	api.WebhooksServiceServer
+	authorizer authz.Authorizer
+	manager    *webhook.Manager
+}
+
+// NewWebhooksServer constructor for managing webhooks, which requires manager
+// when webhooks are enabled.
+func NewWebhooksServer(
+	authorizer authz.Authorizer,
+	manager *webhook.Manager,
+) (api.WebhooksServiceServer, error) {
+	return &webhooksServer{
+		authorizer: authorizer,
+		manager:    manager,
+	}, nil
+}
+
+// Create Webhook swagger:route POST /api/v1/{organization_id}/webhooks webhooks createWebhookRequest
+//
+// Responses:
+// 200: createWebhookResponse
+// 400	Bad Request
+// 401	Not Authorized
+// 500	Internal Error
+func (s *webhooksServer) Create(
+	ctx context.Context,
+	req *api.CreateWebhookRequest,
+) (*api.CreateWebhookResponse, error) {
+	if err := s.authorize(ctx, req.OrganizationId, updateAction); err != nil {
+		return nil, err
+	}
+	saved, err := s.manager.CreateWebhook(ctx, &types.Webhook{
+		OrganizationId: req.OrganizationId,
+		Url:            req.Url,
+		EntityTypes:    req.EntityTypes,
+		Secret:         req.Secret,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &api.CreateWebhookResponse{
+		Id: saved.Id,
+	}, nil
+}
+
+// Update Webhook swagger:route PUT /api/v1/{organization_id}/webhooks/{id} webhooks updateWebhookRequest
+//
+// Responses:
+// 200: updateWebhookResponse
+// 400	Bad Request
+// 401	Not Authorized
+// 500	Internal Error
+func (s *webhooksServer) Update(
+	ctx context.Context,
+	req *api.UpdateWebhookRequest,
+) (*api.UpdateWebhookResponse, error) {
+	if err := s.authorize(ctx, req.OrganizationId, updateAction); err != nil {
+		return nil, err
+	}
+	if err := s.manager.UpdateWebhook(ctx, &types.Webhook{
+		Id:             req.Id,
+		Version:        req.Version,
+		OrganizationId: req.OrganizationId,
+		Url:            req.Url,
+		EntityTypes:    req.EntityTypes,
+		Secret:         req.Secret,
+	}); err != nil {
+		return nil, err
+	}
+	return &api.UpdateWebhookResponse{}, nil
+}
+
+// Query Webhook swagger:route GET /api/v1/{organization_id}/webhooks webhooks queryWebhookRequest
+//
+// Responses:
+// 200: queryWebhookResponse
+// 400	Bad Request
+// 401	Not Authorized
+// 500	Internal Error
+func (s *webhooksServer) Query(
+	req *api.QueryWebhookRequest,
+	sender api.WebhooksService_QueryServer,
+) error {
+	if err := s.authorize(sender.Context(), req.OrganizationId, queryAction); err != nil {
+		return err
+	}
+	res, nextOffset, err := s.manager.QueryWebhooks(
+		sender.Context(),
+		req.OrganizationId,
+		req.Predicates,
+		req.Offset,
+		req.Limit)
+	if err != nil {
+		return err
+	}
+	for _, hook := range res {
+		if err = sender.Send(&api.QueryWebhookResponse{
+			Webhook:    hook,
+			NextOffset: nextOffset,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete Webhook swagger:route DELETE /api/v1/{organization_id}/webhooks/{id} webhooks deleteWebhookRequest
+//
+// Responses:
+// 200: deleteWebhookResponse
+// 400	Bad Request
+// 401	Not Authorized
+// 500	Internal Error
+func (s *webhooksServer) Delete(
+	ctx context.Context,
+	req *api.DeleteWebhookRequest,
+) (*api.DeleteWebhookResponse, error) {
+	if err := s.authorize(ctx, req.OrganizationId, updateAction); err != nil {
+		return nil, err
+	}
+	if err := s.manager.DeleteWebhook(ctx, req.OrganizationId, req.Id); err != nil {
+		return nil, err
+	}
+	return &api.DeleteWebhookResponse{}, nil
+}
+
+// QueryDeliveries of Webhook swagger:route GET /api/v1/{organization_id}/webhooks/{webhook_id}/deliveries webhooks queryWebhookDeliveryRequest
+//
+// Responses:
+// 200: queryWebhookDeliveryResponse
+// 400	Bad Request
+// 401	Not Authorized
+// 500	Internal Error
+func (s *webhooksServer) QueryDeliveries(
+	req *api.QueryWebhookDeliveryRequest,
+	sender api.WebhooksService_QueryDeliveriesServer,
+) error {
+	if err := s.authorize(sender.Context(), req.OrganizationId, queryAction); err != nil {
+		return err
+	}
+	res, nextOffset, err := s.manager.QueryDeliveries(
+		sender.Context(),
+		req.OrganizationId,
+		req.WebhookId,
+		req.Status,
+		req.Offset,
+		req.Limit)
+	if err != nil {
+		return err
+	}
+	for _, delivery := range res {
+		if err = sender.Send(&api.QueryWebhookDeliveryResponse{Delivery: delivery, NextOffset: nextOffset}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *webhooksServer) authorize(ctx context.Context, organizationId string, action string) error {
+	if _, err := s.authorizer.Authorize(
+		ctx,
+		&api.AuthRequest{
+			PrincipalId:    authz.Subject(ctx),
+			OrganizationId: organizationId,
+			Resource:       objectWildcard,
+			Action:         action,
+		},
+	); err != nil {
+		return err
+	}
+	if s.manager == nil {
+		return domain.NewValidationError("webhooks are not enabled")
+	}
+	return nil
+}
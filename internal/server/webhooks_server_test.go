@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"github.com/bhatti/PlexAuthZ/api/v1/services"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/webhook"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func Test_ShouldDeliverRoleChangesToWebhooks(t *testing.T) {
+	// GIVEN server with webhooks and a receiver of signed deliveries
+	err := os.Setenv("CONFIG_DIR", "../../config")
+	require.NoError(t, err)
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	cfg.GrpcSasl = true
+	cfg.Webhook.Enabled = true
+	cfg.Webhook.AllowPrivateNetworks = true
+	ctx := context.Background()
+	received := make(chan string, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !webhook.Verify("webhook-secret", body, r.Header.Get(webhook.SignatureHeader)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		received <- r.Header.Get(webhook.EventHeader)
+	}))
+	defer receiver.Close()
+	clients, teardown := SetupGrpcServerForTesting(t, cfg, domain.RootClientType, nil)
+	defer teardown()
+	orgRes, err := clients.OrganizationsClient.Create(ctx, &services.CreateOrganizationRequest{
+		Name:       "webhook-org",
+		Namespaces: []string{"admin"},
+	})
+	require.NoError(t, err)
+	webhookRes, err := clients.WebhooksClient.Create(ctx, &services.CreateWebhookRequest{
+		OrganizationId: orgRes.Id,
+		Url:            receiver.URL,
+		EntityTypes:    []types.ChangeEntityType{types.ChangeEntityType_ROLE},
+		Secret:         "webhook-secret",
+	})
+	require.NoError(t, err)
+
+	// WHEN creating role
+	_, err = clients.RolesClient.Create(ctx, &services.CreateRoleRequest{
+		Name:           "role-name",
+		Namespace:      "admin",
+		OrganizationId: orgRes.Id,
+	})
+	require.NoError(t, err)
+
+	// THEN change should be delivered and visible in deliveries of the webhook
+	select {
+	case event := <-received:
+		require.Equal(t, "ROLE.CREATED", event)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for delivery")
+	}
+	require.Eventually(t, func() bool {
+		res, err := clients.WebhooksClient.QueryDeliveries(ctx, &services.QueryWebhookDeliveryRequest{
+			OrganizationId: orgRes.Id,
+			WebhookId:      webhookRes.Id,
+			Status:         "DELIVERED",
+		})
+		if err != nil {
+			return false
+		}
+		count := 0
+		for {
+			delivery, err := res.Recv()
+			if err != nil {
+				return err == io.EOF && count == 1
+			}
+			require.Equal(t, types.ChangeEntityType_ROLE, delivery.Delivery.Event.EntityType)
+			count++
+		}
+	}, 5*time.Second, 20*time.Millisecond)
+
+	// AND webhooks should be queried without secrets
+	res, err := clients.WebhooksClient.Query(ctx, &services.QueryWebhookRequest{OrganizationId: orgRes.Id})
+	require.NoError(t, err)
+	hook, err := res.Recv()
+	require.NoError(t, err)
+	require.Equal(t, webhookRes.Id, hook.Webhook.Id)
+	require.Equal(t, "", hook.Webhook.Secret)
+
+	// WHEN deleting webhook
+	_, err = clients.WebhooksClient.Delete(ctx, &services.DeleteWebhookRequest{
+		OrganizationId: orgRes.Id,
+		Id:             webhookRes.Id,
+	})
+	// THEN it should not fail
+	require.NoError(t, err)
+}
@@ -3,6 +3,8 @@ package db
 import (
 	"github.com/bhatti/PlexAuthZ/api/v1/types"
 	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/events"
+	"github.com/bhatti/PlexAuthZ/internal/invalidation"
 	"github.com/bhatti/PlexAuthZ/internal/metrics"
 	"github.com/bhatti/PlexAuthZ/internal/repository"
 )
@@ -16,12 +18,15 @@ type authAdminServiceDB struct {
 	*RoleServiceDB         // implementation for role admin service
 	*GroupServiceDB        // implementation for group admin service
 	*RelationshipServiceDB // implementation for relationships admin service
+	*LeaseServiceDB        // implementation for leases of jobs
 }
 
 // NewAuthAdminServiceDB manages persistence of AuthZ data
 func NewAuthAdminServiceDB(
 	config *domain.Config,
 	metricsRegistry *metrics.Registry,
+	broker *events.Broker,
+	bus invalidation.Bus,
 	orgRepository repository.Repository[types.Organization],
 	principalRepository repository.Repository[types.Principal],
 	groupsRepository repository.Repository[types.Group],
@@ -31,13 +36,15 @@ func NewAuthAdminServiceDB(
 	resourceInstanceRepositoryFactory repository.ResourceInstanceRepositoryFactory,
 	roleRepository repository.Repository[types.Role],
 	hashRepository repository.Repository[domain.HashIndex],
+	leaseRepository repository.Repository[domain.LeaderLease],
 	maxCacheSize int,
 	cacheExpirationMillis int,
 ) *authAdminServiceDB {
-	orgService := NewOrganizationServiceDB(metricsRegistry, orgRepository, maxCacheSize, cacheExpirationMillis)
+	orgService := NewOrganizationServiceDB(metricsRegistry, broker, bus, orgRepository, maxCacheSize, cacheExpirationMillis)
 	principalService := NewPrincipalServiceDB(
 		config,
 		metricsRegistry,
+		broker,
 		orgService,
 		principalRepository,
 		groupsRepository,
@@ -49,30 +56,37 @@ func NewAuthAdminServiceDB(
 		maxCacheSize,
 		cacheExpirationMillis)
 	resourceService := NewResourceServiceDB(
+		config,
 		metricsRegistry,
+		broker,
 		orgService,
 		principalService,
 		resourceRepository,
 		resourceInstanceRepositoryFactory,
-		hashRepository)
+		hashRepository,
+		leaseRepository)
 	permissionService := NewPermissionServiceDB(
 		metricsRegistry,
+		broker,
 		orgService,
 		resourceRepository,
 		permissionRepository,
 		hashRepository)
 	roleService := NewRoleServiceDB(
 		metricsRegistry,
+		broker,
 		orgService,
 		roleRepository,
 		hashRepository)
 	groupService := NewGroupServiceDB(
 		metricsRegistry,
+		broker,
 		orgService,
 		groupsRepository,
 		hashRepository)
 	relationshipService := NewRelationshipServiceDB(
 		metricsRegistry,
+		broker,
 		orgService,
 		relationshipRepository,
 		hashRepository)
@@ -84,10 +98,20 @@ func NewAuthAdminServiceDB(
 		RoleServiceDB:         roleService,
 		GroupServiceDB:        groupService,
 		RelationshipServiceDB: relationshipService,
+		LeaseServiceDB:        NewLeaseServiceDB(metricsRegistry, leaseRepository),
+	}
+}
+
+// changeOperation returns operation of change events for the version before saving entity.
+func changeOperation(version int64) types.ChangeOperation {
+	if version == 0 {
+		return types.ChangeOperation_CREATED
 	}
+	return types.ChangeOperation_UPDATED
 }
 
-// Close no-op
+// Close stops background releasing of expired resource instances and receiving cache invalidations
 func (s *authAdminServiceDB) Close() error {
-	return nil
+	_ = s.OrganizationServiceDB.Close()
+	return s.ResourceServiceDB.Close()
 }
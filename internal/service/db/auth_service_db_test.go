@@ -6,6 +6,9 @@ import (
 	"github.com/bhatti/PlexAuthZ/internal/domain"
 	"github.com/bhatti/PlexAuthZ/internal/metrics"
 	"github.com/bhatti/PlexAuthZ/internal/service"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
 )
 
 func newAuthServiceAndOrg() (service.AuthAdminService, *types.Organization, error) {
@@ -28,3 +31,58 @@ func newAuthServiceAndOrg() (service.AuthAdminService, *types.Organization, erro
 	savedOrg, err := authService.CreateOrganization(ctx, org)
 	return authService, savedOrg, err
 }
+
+func Test_ShouldInvalidateCachedPrincipalsOfOtherReplicas(t *testing.T) {
+	// GIVEN two replicas with Redis invalidation bus
+	ctx := context.TODO()
+	replicas := make([]service.AuthAdminService, 2)
+	for i := range replicas {
+		cfg, err := domain.NewConfig("")
+		require.NoError(t, err)
+		cfg.CacheInvalidation.Bus = domain.RedisCacheInvalidationBus
+		cfg.CacheInvalidation.Channel = "test_invalidations"
+		replicas[i], _, err = CreateDatabaseAuthService(cfg, metrics.New())
+		require.NoError(t, err)
+	}
+	org, err := domain.NewOrganizationBuilder().
+		WithName("test-org").
+		WithNamespaces("finance").Build()
+	require.NoError(t, err)
+	org, err = replicas[0].CreateOrganization(ctx, org)
+	require.NoError(t, err)
+	principal, err := replicas[0].CreatePrincipal(ctx, &types.Principal{
+		OrganizationId: org.Id, Namespaces: org.Namespaces, Username: "alice"})
+	require.NoError(t, err)
+	role, err := replicas[0].CreateRole(ctx, org.Id, &types.Role{Namespace: "finance", Name: "reader"})
+	require.NoError(t, err)
+	require.NoError(t, replicas[0].AddRolesToPrincipal(ctx, org.Id, "finance", principal.Id, role.Id))
+	resource, err := replicas[0].CreateResource(ctx, org.Id, &types.Resource{
+		Namespace: "finance", Name: "report", AllowedActions: []string{"read"}})
+	require.NoError(t, err)
+	perm, err := replicas[0].CreatePermission(ctx, org.Id, &types.Permission{
+		Namespace: "finance", ResourceId: resource.Id, Actions: []string{"read"}, Effect: types.Effect_PERMITTED})
+	require.NoError(t, err)
+
+	// AND principal cached by second replica without permissions
+	xPrincipal, err := replicas[1].GetPrincipalExt(ctx, org.Id, "finance", principal.Id)
+	require.NoError(t, err)
+	require.Len(t, xPrincipal.AllPermissions(), 0)
+
+	// WHEN adding permission to the role with first replica
+	require.NoError(t, replicas[0].AddPermissionsToRole(ctx, org.Id, "finance", role.Id, perm.Id))
+
+	// THEN second replica should evict the principal holding the role
+	require.Eventually(t, func() bool {
+		xPrincipal, err = replicas[1].GetPrincipalExt(ctx, org.Id, "finance", principal.Id)
+		return err == nil && len(xPrincipal.AllPermissions()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// WHEN deleting principal with first replica
+	require.NoError(t, replicas[0].DeletePrincipal(ctx, org.Id, principal.Id))
+
+	// THEN second replica should evict the principal
+	require.Eventually(t, func() bool {
+		_, err = replicas[1].GetPrincipalExt(ctx, org.Id, "finance", principal.Id)
+		return err != nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
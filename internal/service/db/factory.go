@@ -1,13 +1,18 @@
 package db
 
 import (
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/audit"
 	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/events"
+	"github.com/bhatti/PlexAuthZ/internal/invalidation"
 	"github.com/bhatti/PlexAuthZ/internal/metrics"
 	"github.com/bhatti/PlexAuthZ/internal/repository"
+	"github.com/bhatti/PlexAuthZ/internal/repository/bolt"
 	"github.com/bhatti/PlexAuthZ/internal/repository/ddb"
 	"github.com/bhatti/PlexAuthZ/internal/repository/redis"
+	sqlstore "github.com/bhatti/PlexAuthZ/internal/repository/sql"
 	"github.com/bhatti/PlexAuthZ/internal/service"
-	"time"
 )
 
 // CreateDatabaseAuthService factory method
@@ -43,7 +48,8 @@ func CreateDatabaseAuthService(
 	if err != nil {
 		return nil, nil, err
 	}
-	resourceInstanceRepository, err := repository.NewResourceInstanceRepository(store, time.Minute)
+	resourceInstanceRepository, err := repository.NewResourceInstanceRepository(
+		store, cfg.ResourceInstanceExpiration, cfg.ReleasedInstanceExpiration)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -55,9 +61,37 @@ func CreateDatabaseAuthService(
 	if err != nil {
 		return nil, nil, err
 	}
+	leaseRepository, err := repository.NewLeaderLeaseRepository(store)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.Audit.Enabled {
+		auditor, err := audit.NewAuditor(cfg.Audit, store, orgRepository, metricsRegistry)
+		if err != nil {
+			return nil, nil, err
+		}
+		orgRepository = audit.NewAuditedRepository[types.Organization](orgRepository, auditor, "Organization")
+		principalRepository = audit.NewAuditedRepository[types.Principal](principalRepository, auditor, "Principal")
+		groupRepository = audit.NewAuditedRepository[types.Group](groupRepository, auditor, "Group")
+		permissionRepository = audit.NewAuditedRepository[types.Permission](permissionRepository, auditor, "Permission")
+		relationshipRepository = audit.NewAuditedRepository[types.Relationship](
+			relationshipRepository, auditor, "Relationship")
+		resourceRepository = audit.NewAuditedRepository[types.Resource](resourceRepository, auditor, "Resource")
+		roleRepository = audit.NewAuditedRepository[types.Role](roleRepository, auditor, "Role")
+	}
+	broker, err := events.Shared(cfg, metricsRegistry)
+	if err != nil {
+		return nil, nil, err
+	}
+	bus, err := invalidation.Shared(cfg, metricsRegistry)
+	if err != nil {
+		return nil, nil, err
+	}
 	authService := NewAuthAdminServiceDB(
 		cfg,
 		metricsRegistry,
+		broker,
+		bus,
 		orgRepository,
 		principalRepository,
 		groupRepository,
@@ -67,16 +101,34 @@ func CreateDatabaseAuthService(
 		resourceInstanceRepository,
 		roleRepository,
 		hashRepository,
+		leaseRepository,
 		cfg.MaxCacheSize,
 		cfg.CacheExpirationMillis,
 	)
 	return authService, authService, nil
 }
 
+// CreateAuditor factory for recording and querying audit records of administrative changes.
+func CreateAuditor(cfg *domain.Config, metricsRegistry *metrics.Registry) (*audit.Auditor, error) {
+	store, err := CreateDataStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+	orgRepository, err := repository.NewOrganizationRepository(store)
+	if err != nil {
+		return nil, err
+	}
+	return audit.NewAuditor(cfg.Audit, store, orgRepository, metricsRegistry)
+}
+
 // CreateDataStore factory
 func CreateDataStore(cfg *domain.Config) (repository.DataStore, error) {
 	if cfg.PersistenceProvider == domain.DynamoDBPersistenceProvider {
 		return ddb.NewDDBStore(cfg)
+	} else if cfg.PersistenceProvider == domain.SQLPersistenceProvider {
+		return sqlstore.NewSQLStore(cfg)
+	} else if cfg.PersistenceProvider == domain.BoltPersistenceProvider {
+		return bolt.NewBoltStore(cfg)
 	}
 	return redis.NewRedisStore(cfg)
 }
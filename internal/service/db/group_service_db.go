@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"github.com/bhatti/PlexAuthZ/api/v1/types"
 	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/events"
+	"github.com/bhatti/PlexAuthZ/internal/invalidation"
 	"github.com/bhatti/PlexAuthZ/internal/metrics"
 	"github.com/bhatti/PlexAuthZ/internal/repository"
 	"github.com/bhatti/PlexAuthZ/internal/utils"
@@ -16,6 +18,7 @@ import (
 // GroupServiceDB - manages persistence of groups data
 type GroupServiceDB struct {
 	metricsRegistry *metrics.Registry
+	broker          *events.Broker
 	orgService      *OrganizationServiceDB
 	groupRepository repository.Repository[types.Group]
 	hashRepository  repository.Repository[domain.HashIndex]
@@ -24,12 +27,14 @@ type GroupServiceDB struct {
 // NewGroupServiceDB manages persistence of groups data
 func NewGroupServiceDB(
 	metricsRegistry *metrics.Registry,
+	broker *events.Broker,
 	orgService *OrganizationServiceDB,
 	groupsRepository repository.Repository[types.Group],
 	hashRepository repository.Repository[domain.HashIndex],
 ) *GroupServiceDB {
 	return &GroupServiceDB{
 		metricsRegistry: metricsRegistry,
+		broker:          broker,
 		orgService:      orgService,
 		groupRepository: groupsRepository,
 		hashRepository:  hashRepository,
@@ -116,7 +121,12 @@ func (s *GroupServiceDB) DeleteGroup(
 		ctx, organizationID, namespace); err != nil {
 		return err
 	}
-	return s.groupRepository.Delete(ctx, organizationID, namespace, id)
+	if err := s.groupRepository.Delete(ctx, organizationID, namespace, id); err != nil {
+		return err
+	}
+	s.orgService.invalidate(ctx, invalidation.GroupKind, organizationID, id)
+	s.broker.Publish(organizationID, namespace, types.ChangeEntityType_GROUP, id, 0, types.ChangeOperation_DELETED)
+	return nil
 }
 
 // GetGroup - finds group
@@ -246,6 +256,11 @@ func (s *GroupServiceDB) updateGroup(
 	if err != nil {
 		return err
 	}
+	if version > 0 {
+		s.orgService.invalidate(ctx, invalidation.GroupKind, organizationID, xGroup.Delegate.Id)
+	}
+	s.broker.Publish(organizationID, xGroup.Delegate.Namespace, types.ChangeEntityType_GROUP,
+		xGroup.Delegate.Id, xGroup.Delegate.Version, changeOperation(version))
 	hash := xGroup.Hash()
 	return s.hashRepository.Update(
 		ctx,
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/metrics"
+	"github.com/bhatti/PlexAuthZ/internal/repository"
+	"time"
+)
+
+// LeaseServiceDB - manages leases of jobs that run on one server at a time.
+type LeaseServiceDB struct {
+	metricsRegistry *metrics.Registry
+	leaseRepository repository.Repository[domain.LeaderLease]
+}
+
+// NewLeaseServiceDB manages persistence of leases
+func NewLeaseServiceDB(
+	metricsRegistry *metrics.Registry,
+	leaseRepository repository.Repository[domain.LeaderLease],
+) *LeaseServiceDB {
+	return &LeaseServiceDB{
+		metricsRegistry: metricsRegistry,
+		leaseRepository: leaseRepository,
+	}
+}
+
+// AcquireLease acquires or renews lease of the job for the holder.
+func (s *LeaseServiceDB) AcquireLease(
+	ctx context.Context,
+	job string,
+	holder string,
+	expiry time.Duration) (bool, error) {
+	defer s.metricsRegistry.Elapsed("leases_svc_acquire", "job", job)()
+	return acquireLease(ctx, s.leaseRepository, job, holder, expiry)
+}
+
+// CompleteJob marks the job as completed without expiration so that it doesn't run again.
+func (s *LeaseServiceDB) CompleteJob(
+	ctx context.Context,
+	job string,
+	holder string) error {
+	defer s.metricsRegistry.Elapsed("leases_svc_complete", "job", job)()
+	existing, err := s.leaseRepository.GetByID(ctx, "", "", job)
+	if err != nil {
+		return err
+	}
+	if existing.Holder != holder || existing.Completed || existing.Expired(time.Now()) {
+		return domain.NewConflictError(
+			fmt.Sprintf("lease of job %s is not held by %s", job, holder))
+	}
+	lease := domain.NewLeaderLease(job, holder, existing.Version+1, 0)
+	lease.Completed = true
+	lease.ExpiresAt = nil
+	return s.leaseRepository.Update(ctx, "", "", job, existing.Version, lease, time.Duration(0))
+}
+
+// JobCompleted returns true if the job is completed.
+func (s *LeaseServiceDB) JobCompleted(
+	ctx context.Context,
+	job string) (bool, error) {
+	existing, err := s.leaseRepository.GetByID(ctx, "", "", job)
+	if err != nil {
+		var notFoundErr *domain.NotFoundError
+		if errors.As(err, &notFoundErr) {
+			return false, nil
+		}
+		return false, err
+	}
+	return existing.Completed, nil
+}
+
+// acquireLease acquires or renews lease of the job for the holder. Leases are written with their versions
+// so that only one of the servers competing for an expired lease acquires it.
+func acquireLease(
+	ctx context.Context,
+	leaseRepository repository.Repository[domain.LeaderLease],
+	job string,
+	holder string,
+	expiry time.Duration) (bool, error) {
+	existing, err := leaseRepository.GetByID(ctx, "", "", job)
+	if err != nil {
+		var notFoundErr *domain.NotFoundError
+		if !errors.As(err, &notFoundErr) {
+			return false, err
+		}
+		return leaseRepository.Create(ctx, "", "", job,
+			domain.NewLeaderLease(job, holder, 1, expiry), expiry) == nil, nil
+	}
+	if existing.Completed || (existing.Holder != holder && !existing.Expired(time.Now())) {
+		return false, nil
+	}
+	return leaseRepository.Update(ctx, "", "", job, existing.Version,
+		domain.NewLeaderLease(job, holder, existing.Version+1, expiry), expiry) == nil, nil
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"github.com/bhatti/PlexAuthZ/api/v1/types"
 	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/events"
+	"github.com/bhatti/PlexAuthZ/internal/invalidation"
 	"github.com/bhatti/PlexAuthZ/internal/metrics"
 	"github.com/bhatti/PlexAuthZ/internal/repository"
 	"github.com/bhatti/PlexAuthZ/internal/utils"
@@ -12,6 +14,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/twinj/uuid"
 	"google.golang.org/protobuf/types/known/timestamppb"
+	"sync"
 	"time"
 )
 
@@ -21,23 +24,100 @@ type OrganizationServiceDB struct {
 	metricsRegistry *metrics.Registry
 	orgRepository   repository.Repository[types.Organization]
 	orgCache        *expirable.LRU[string, *types.Organization]
+	broker          *events.Broker
+	bus             invalidation.Bus
+	evictors        []invalidation.Handler
+	subscriptions   []int64
+	lock            sync.RWMutex
 }
 
 // NewOrganizationServiceDB manages persistence of organization
 func NewOrganizationServiceDB(
 	metricsRegistry *metrics.Registry,
+	broker *events.Broker,
+	bus invalidation.Bus,
 	orgRepository repository.Repository[types.Organization],
 	maxCacheSize int,
 	cacheExpirationMillis int,
 ) *OrganizationServiceDB {
-	return &OrganizationServiceDB{
+	s := &OrganizationServiceDB{
 		metricsRegistry: metricsRegistry,
+		broker:          broker,
+		bus:             bus,
 		orgRepository:   orgRepository,
 		orgCache: expirable.NewLRU[string, *types.Organization](
 			maxCacheSize,
 			nil,
 			time.Millisecond*time.Duration(cacheExpirationMillis)),
 	}
+	s.subscribe(s.evict)
+	return s
+}
+
+// subscribe adds handler that evicts cached data of the process when data is changed by this or,
+// with the invalidation bus, another replica.
+func (s *OrganizationServiceDB) subscribe(handler invalidation.Handler) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.bus != nil {
+		s.subscriptions = append(s.subscriptions, s.bus.Subscribe(handler))
+	} else {
+		s.evictors = append(s.evictors, handler)
+	}
+}
+
+// Close removes handlers of the service from the invalidation bus, which is shared by the process.
+func (s *OrganizationServiceDB) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, id := range s.subscriptions {
+		s.bus.Unsubscribe(id)
+	}
+	s.subscriptions = nil
+	return nil
+}
+
+// invalidate evicts cached principals and organizations of the process, where the invalidation bus
+// invokes handlers of the process before publishing the invalidation to other replicas. Failures are
+// only logged because the caches expire after cache_expiration_millis.
+func (s *OrganizationServiceDB) invalidate(
+	ctx context.Context,
+	kind invalidation.Kind,
+	organizationID string,
+	id string,
+) {
+	inv := &invalidation.Invalidation{
+		OrganizationId: organizationID,
+		Kind:           kind,
+		Id:             id,
+	}
+	if s.bus == nil {
+		s.lock.RLock()
+		evictors := s.evictors
+		s.lock.RUnlock()
+		for _, evict := range evictors {
+			evict(inv)
+		}
+		return
+	}
+	if err := s.bus.Publish(ctx, inv); err != nil {
+		log.WithFields(log.Fields{
+			"Component":    "OrganizationServiceDB",
+			"Organization": organizationID,
+			"Kind":         kind,
+			"Id":           id,
+			"Error":        err,
+		}).Warnf("failed to publish cache invalidation")
+	}
+}
+
+// evict removes cached organization of the invalidation.
+func (s *OrganizationServiceDB) evict(inv *invalidation.Invalidation) {
+	if inv.Kind == invalidation.AllKind {
+		s.orgCache.Purge()
+	} else if inv.Kind == invalidation.OrganizationKind {
+		_ = s.orgCache.Remove(inv.OrganizationId)
+	}
 }
 
 // GetOrganization finds organization
@@ -91,6 +171,7 @@ func (s *OrganizationServiceDB) CreateOrganization(
 		return nil, err
 	}
 	_ = s.orgCache.Add(org.Id, org)
+	s.broker.Publish(org.Id, "", types.ChangeEntityType_ORGANIZATION, org.Id, org.Version, types.ChangeOperation_CREATED)
 	if log.IsLevelEnabled(log.DebugLevel) {
 		log.WithFields(log.Fields{
 			"Component":    "OrganizationServiceDB",
@@ -127,7 +208,9 @@ func (s *OrganizationServiceDB) UpdateOrganization(
 	if err != nil {
 		return err
 	}
+	s.invalidate(ctx, invalidation.OrganizationKind, org.Id, org.Id)
 	_ = s.orgCache.Add(org.Id, org)
+	s.broker.Publish(org.Id, "", types.ChangeEntityType_ORGANIZATION, org.Id, org.Version, types.ChangeOperation_UPDATED)
 	log.WithFields(log.Fields{
 		"Component":    "OrganizationServiceDB",
 		"Organization": org.Id,
@@ -148,7 +231,8 @@ func (s *OrganizationServiceDB) DeleteOrganization(
 	if err != nil {
 		return err
 	}
-	_ = s.orgCache.Remove(id)
+	s.invalidate(ctx, invalidation.OrganizationKind, id, id)
+	s.broker.Publish(id, "", types.ChangeEntityType_ORGANIZATION, id, 0, types.ChangeOperation_DELETED)
 	log.WithFields(log.Fields{
 		"Component":      "OrganizationServiceDB",
 		"OrganizationId": id,
@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"github.com/bhatti/PlexAuthZ/internal/invalidation"
 	"github.com/stretchr/testify/require"
 	"testing"
 )
@@ -63,3 +64,46 @@ func Test_Should_CRUD_Organizations(t *testing.T) {
 	// THEN it should fail
 	require.Error(t, err)
 }
+
+func Test_ShouldEvictCachedOrganizationWithoutInvalidationBus(t *testing.T) {
+	// GIVEN organization service without invalidation bus and a cached organization
+	ctx := context.TODO()
+	store, org, err := newAuthServiceAndOrg()
+	require.NoError(t, err)
+	shared := store.(*authAdminServiceDB).OrganizationServiceDB
+	orgService := NewOrganizationServiceDB(shared.metricsRegistry, shared.broker, nil, shared.orgRepository, 100, 60000)
+	_, err = orgService.GetOrganization(ctx, org.Id)
+	require.NoError(t, err)
+
+	// WHEN deleting the organization
+	require.NoError(t, orgService.DeleteOrganization(ctx, org.Id))
+
+	// THEN cached organization should be evicted
+	_, err = orgService.GetOrganization(ctx, org.Id)
+	require.Error(t, err)
+}
+
+func Test_ShouldEvictOnceWithInvalidationBusUntilClosed(t *testing.T) {
+	// GIVEN organization service with invalidation bus and a handler of evictions
+	ctx := context.TODO()
+	store, org, err := newAuthServiceAndOrg()
+	require.NoError(t, err)
+	shared := store.(*authAdminServiceDB).OrganizationServiceDB
+	bus := invalidation.NewLocalBus()
+	orgService := NewOrganizationServiceDB(shared.metricsRegistry, shared.broker, bus, shared.orgRepository, 100, 60000)
+	evicted := 0
+	orgService.subscribe(func(*invalidation.Invalidation) {
+		evicted++
+	})
+
+	// WHEN invalidating the organization
+	orgService.invalidate(ctx, invalidation.OrganizationKind, org.Id, org.Id)
+	// THEN each handler should be invoked once
+	require.Equal(t, 1, evicted)
+
+	// WHEN closing the service
+	require.NoError(t, orgService.Close())
+	// THEN its handlers should no longer receive invalidations of the shared bus
+	require.NoError(t, bus.Publish(ctx, &invalidation.Invalidation{Kind: invalidation.AllKind}))
+	require.Equal(t, 1, evicted)
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"github.com/bhatti/PlexAuthZ/api/v1/types"
 	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/events"
+	"github.com/bhatti/PlexAuthZ/internal/invalidation"
 	"github.com/bhatti/PlexAuthZ/internal/metrics"
 	"github.com/bhatti/PlexAuthZ/internal/repository"
 	"github.com/bhatti/PlexAuthZ/internal/utils"
@@ -16,6 +18,7 @@ import (
 // PermissionServiceDB - manages persistence of permission data
 type PermissionServiceDB struct {
 	metricsRegistry      *metrics.Registry
+	broker               *events.Broker
 	orgService           *OrganizationServiceDB
 	groupRepository      repository.Repository[types.Group]
 	resourceRepository   repository.Repository[types.Resource]
@@ -26,6 +29,7 @@ type PermissionServiceDB struct {
 // NewPermissionServiceDB manages persistence of permission data
 func NewPermissionServiceDB(
 	metricsRegistry *metrics.Registry,
+	broker *events.Broker,
 	orgService *OrganizationServiceDB,
 	resourceRepository repository.Repository[types.Resource],
 	permissionRepository repository.Repository[types.Permission],
@@ -33,6 +37,7 @@ func NewPermissionServiceDB(
 ) *PermissionServiceDB {
 	return &PermissionServiceDB{
 		metricsRegistry:      metricsRegistry,
+		broker:               broker,
 		orgService:           orgService,
 		resourceRepository:   resourceRepository,
 		permissionRepository: permissionRepository,
@@ -141,11 +146,16 @@ func (s *PermissionServiceDB) DeletePermission(
 		ctx, organizationID, namespace); err != nil {
 		return err
 	}
-	return s.permissionRepository.Delete(
+	if err := s.permissionRepository.Delete(
 		ctx,
 		organizationID,
 		namespace,
-		id)
+		id); err != nil {
+		return err
+	}
+	s.orgService.invalidate(ctx, invalidation.OrganizationKind, organizationID, id)
+	s.broker.Publish(organizationID, namespace, types.ChangeEntityType_PERMISSION, id, 0, types.ChangeOperation_DELETED)
+	return nil
 }
 
 // GetPermission - finds permission
@@ -232,6 +242,11 @@ func (s *PermissionServiceDB) updatePermission(
 	if err != nil {
 		return err
 	}
+	if version > 0 {
+		s.orgService.invalidate(ctx, invalidation.OrganizationKind, organizationID, xPermission.Delegate.Id)
+	}
+	s.broker.Publish(organizationID, xPermission.Delegate.Namespace, types.ChangeEntityType_PERMISSION,
+		xPermission.Delegate.Id, xPermission.Delegate.Version, changeOperation(version))
 
 	// update mapping between permission-hash and id
 	hash := xPermission.Hash()
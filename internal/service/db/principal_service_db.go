@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"github.com/bhatti/PlexAuthZ/api/v1/types"
 	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/events"
+	"github.com/bhatti/PlexAuthZ/internal/invalidation"
 	"github.com/bhatti/PlexAuthZ/internal/metrics"
 	"github.com/bhatti/PlexAuthZ/internal/repository"
 	"github.com/bhatti/PlexAuthZ/internal/utils"
@@ -19,6 +21,7 @@ import (
 type PrincipalServiceDB struct {
 	config                 *domain.Config
 	metricsRegistry        *metrics.Registry
+	broker                 *events.Broker
 	orgService             *OrganizationServiceDB
 	principalRepository    repository.Repository[types.Principal]
 	groupRepository        repository.Repository[types.Group]
@@ -34,6 +37,7 @@ type PrincipalServiceDB struct {
 func NewPrincipalServiceDB(
 	config *domain.Config,
 	metricsRegistry *metrics.Registry,
+	broker *events.Broker,
 	orgService *OrganizationServiceDB,
 	principalRepository repository.Repository[types.Principal],
 	groupsRepository repository.Repository[types.Group],
@@ -45,9 +49,10 @@ func NewPrincipalServiceDB(
 	maxCacheSize int,
 	cacheExpirationMillis int,
 ) *PrincipalServiceDB {
-	return &PrincipalServiceDB{
+	s := &PrincipalServiceDB{
 		config:                 config,
 		metricsRegistry:        metricsRegistry,
+		broker:                 broker,
 		orgService:             orgService,
 		principalRepository:    principalRepository,
 		groupRepository:        groupsRepository,
@@ -61,6 +66,32 @@ func NewPrincipalServiceDB(
 			nil,
 			time.Millisecond*time.Duration(cacheExpirationMillis)),
 	}
+	orgService.subscribe(s.evict)
+	return s
+}
+
+// evict removes cached principals that are affected by the invalidation.
+func (s *PrincipalServiceDB) evict(inv *invalidation.Invalidation) {
+	switch inv.Kind {
+	case invalidation.AllKind:
+		s.principalCache.Purge()
+		return
+	case invalidation.PrincipalKind:
+		_ = s.principalCache.Remove(toKey(inv.OrganizationId, "", inv.Id))
+		return
+	}
+	for _, key := range s.principalCache.Keys() {
+		// expired entries that are not yet removed are returned without value
+		xPrincipal, ok := s.principalCache.Peek(key)
+		if !ok || xPrincipal == nil || xPrincipal.Delegate.OrganizationId != inv.OrganizationId {
+			continue
+		}
+		if inv.Kind == invalidation.OrganizationKind ||
+			(inv.Kind == invalidation.GroupKind && xPrincipal.HasGroup(inv.Id)) ||
+			(inv.Kind == invalidation.RoleKind && xPrincipal.HasRole(inv.Id)) {
+			_ = s.principalCache.Remove(key)
+		}
+	}
 }
 
 // CreatePrincipal - creates new instance of principal
@@ -171,9 +202,9 @@ func (s *PrincipalServiceDB) DeletePrincipal(
 		return err
 	}
 
-	// clear cache
-	key := toKey(organizationID, "", id)
-	_ = s.principalCache.Remove(key)
+	// clear cache of all replicas
+	s.orgService.invalidate(ctx, invalidation.PrincipalKind, organizationID, id)
+	s.broker.Publish(organizationID, "", types.ChangeEntityType_PRINCIPAL, id, 0, types.ChangeOperation_DELETED)
 
 	for _, namespace := range principal.Namespaces {
 		// ignore errors
@@ -539,7 +570,7 @@ func (s *PrincipalServiceDB) GetPrincipal(
 	// check cache
 	key := toKey(organizationID, "", id) // no namespace for key
 	xPrincipal, _ := s.principalCache.Get(key)
-	if xPrincipal != nil {
+	if xPrincipal != nil && xPrincipal.Revision >= domain.MinRevision(ctx) {
 		return xPrincipal.Delegate, nil
 	}
 
@@ -592,12 +623,13 @@ func (s *PrincipalServiceDB) GetPrincipalExt(
 			fmt.Sprintf("id is not defined"))
 	}
 
-	// check cache
+	// check cache, which is reloaded if it's older than the consistency token of the request
 	key := toKey(organizationID, "", id) // no namespace for key
 	xPrincipal, _ = s.principalCache.Get(key)
-	if xPrincipal != nil {
+	if xPrincipal != nil && xPrincipal.Revision >= domain.MinRevision(ctx) {
 		return xPrincipal, nil
 	}
+	revision := domain.NewRevision()
 
 	// load from database
 	principal, err := s.principalRepository.GetByID(ctx, organizationID, "", id) // no namespace for principal
@@ -609,6 +641,7 @@ func (s *PrincipalServiceDB) GetPrincipalExt(
 	}
 	xPrincipal = domain.NewPrincipalExt(principal)
 	xPrincipal.Organization = org
+	xPrincipal.Revision = revision
 
 	// get cache of all group and role ids
 	groupIDs, roleIDs, updatedGroupRoleIds := s.getPrincipalAllGroupAndRoleIds(
@@ -727,9 +760,10 @@ func (s *PrincipalServiceDB) updatePrincipal(
 		return err
 	}
 
-	// clear cache
-	key := toKey(xPrincipal.Delegate.OrganizationId, "", xPrincipal.Delegate.Id)
-	_ = s.principalCache.Remove(key)
+	// clear cache of all replicas
+	s.orgService.invalidate(ctx, invalidation.PrincipalKind, xPrincipal.Delegate.OrganizationId, xPrincipal.Delegate.Id)
+	s.broker.Publish(xPrincipal.Delegate.OrganizationId, "", types.ChangeEntityType_PRINCIPAL,
+		xPrincipal.Delegate.Id, xPrincipal.Delegate.Version, changeOperation(version))
 
 	principalHash := xPrincipal.Hash()
 	// update mapping between username and principal-id
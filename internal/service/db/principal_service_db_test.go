@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"github.com/bhatti/PlexAuthZ/api/v1/types"
 	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/invalidation"
 	"github.com/bhatti/PlexAuthZ/internal/utils"
 	"github.com/stretchr/testify/require"
 	"github.com/twinj/uuid"
 	"testing"
+	"time"
 )
 
 func Test_Should_CRUD_Principals(t *testing.T) {
@@ -231,3 +233,26 @@ func Test_ShouldCRUDPrincipalWithPermissions(t *testing.T) {
 	}
 
 }
+
+func Test_ShouldEvictPrincipalsWithExpiredCacheEntries(t *testing.T) {
+	// GIVEN principal service with a cached principal that expires
+	store, org, err := newAuthServiceAndOrg()
+	require.NoError(t, err)
+	shared := store.(*authAdminServiceDB).PrincipalServiceDB
+	svc := NewPrincipalServiceDB(shared.config, shared.metricsRegistry, shared.broker, shared.orgService,
+		shared.principalRepository, shared.groupRepository, shared.permissionRepository,
+		shared.relationshipRepository, shared.resourceRepository, shared.roleRepository, shared.hashRepository,
+		100, 50)
+	defer func() {
+		_ = svc.orgService.Close()
+	}()
+	svc.principalCache.Add(toKey(org.Id, "", "p1"), &domain.PrincipalExt{
+		Delegate: &types.Principal{OrganizationId: org.Id, Id: "p1"}})
+
+	// WHEN evicting members of a group while the cached principal expires
+	// THEN expired entries that are not yet removed should be skipped
+	require.Eventually(t, func() bool {
+		svc.evict(&invalidation.Invalidation{OrganizationId: "other", Kind: invalidation.GroupKind, Id: "g1"})
+		return svc.principalCache.Len() == 0
+	}, 5*time.Second, time.Microsecond)
+}
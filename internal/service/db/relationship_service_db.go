@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"github.com/bhatti/PlexAuthZ/api/v1/types"
 	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/events"
+	"github.com/bhatti/PlexAuthZ/internal/invalidation"
 	"github.com/bhatti/PlexAuthZ/internal/metrics"
 	"github.com/bhatti/PlexAuthZ/internal/repository"
 	"github.com/twinj/uuid"
@@ -15,6 +17,7 @@ import (
 // RelationshipServiceDB - manages persistence of relationship data
 type RelationshipServiceDB struct {
 	metricsRegistry        *metrics.Registry
+	broker                 *events.Broker
 	orgService             *OrganizationServiceDB
 	relationshipRepository repository.Repository[types.Relationship]
 	hashRepository         repository.Repository[domain.HashIndex]
@@ -23,12 +26,14 @@ type RelationshipServiceDB struct {
 // NewRelationshipServiceDB manages persistence of relationship data
 func NewRelationshipServiceDB(
 	metricsRegistry *metrics.Registry,
+	broker *events.Broker,
 	orgService *OrganizationServiceDB,
 	relationshipRepository repository.Repository[types.Relationship],
 	hashRepository repository.Repository[domain.HashIndex],
 ) *RelationshipServiceDB {
 	return &RelationshipServiceDB{
 		metricsRegistry:        metricsRegistry,
+		broker:                 broker,
 		orgService:             orgService,
 		relationshipRepository: relationshipRepository,
 		hashRepository:         hashRepository,
@@ -121,7 +126,12 @@ func (s *RelationshipServiceDB) DeleteRelationship(
 		ctx, organizationID, namespace); err != nil {
 		return err
 	}
-	return s.relationshipRepository.Delete(ctx, organizationID, namespace, id)
+	if err := s.relationshipRepository.Delete(ctx, organizationID, namespace, id); err != nil {
+		return err
+	}
+	s.orgService.invalidate(ctx, invalidation.OrganizationKind, organizationID, id)
+	s.broker.Publish(organizationID, namespace, types.ChangeEntityType_RELATIONSHIP, id, 0, types.ChangeOperation_DELETED)
+	return nil
 }
 
 // GetRelationship - finds relationship
@@ -208,6 +218,11 @@ func (s *RelationshipServiceDB) updateRelationship(
 	if err != nil {
 		return err
 	}
+	if version > 0 {
+		s.orgService.invalidate(ctx, invalidation.OrganizationKind, organizationID, xRelationship.Delegate.Id)
+	}
+	s.broker.Publish(organizationID, xRelationship.Delegate.Namespace, types.ChangeEntityType_RELATIONSHIP,
+		xRelationship.Delegate.Id, xRelationship.Delegate.Version, changeOperation(version))
 	hash := xRelationship.Hash()
 	return s.hashRepository.Update(
 		ctx,
@@ -2,45 +2,79 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/bhatti/PlexAuthZ/api/v1/services"
 	"github.com/bhatti/PlexAuthZ/api/v1/types"
 	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/events"
+	"github.com/bhatti/PlexAuthZ/internal/invalidation"
 	"github.com/bhatti/PlexAuthZ/internal/metrics"
 	"github.com/bhatti/PlexAuthZ/internal/repository"
+	log "github.com/sirupsen/logrus"
 	"github.com/twinj/uuid"
+	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 	"strings"
+	"sync"
 	"time"
 )
 
-// ResourceServiceDB - manages persistence of resources and resource instances
+// sweeperJob identifies lease of the server that releases expired resource instances.
+const sweeperJob = "resource-instance-sweeper"
+
+// ResourceServiceDB - manages persistence of resources and resource instances, where instances whose
+// leases are not renewed are released in background.
 type ResourceServiceDB struct {
+	config                            *domain.Config
 	metricsRegistry                   *metrics.Registry
+	broker                            *events.Broker
 	orgService                        *OrganizationServiceDB
 	principalService                  *PrincipalServiceDB
 	resourceRepository                repository.Repository[types.Resource]
 	resourceInstanceRepositoryFactory repository.ResourceInstanceRepositoryFactory
 	hashRepository                    repository.Repository[domain.HashIndex]
+	leaseRepository                   repository.Repository[domain.LeaderLease]
+	holder                            string
+	done                              chan bool
+	wg                                sync.WaitGroup
 }
 
 // NewResourceServiceDB manages persistence of resources
 func NewResourceServiceDB(
+	config *domain.Config,
 	metricsRegistry *metrics.Registry,
+	broker *events.Broker,
 	orgService *OrganizationServiceDB,
 	principalService *PrincipalServiceDB,
 	resourceRepository repository.Repository[types.Resource],
 	resourceInstanceRepositoryFactory repository.ResourceInstanceRepositoryFactory,
 	hashRepository repository.Repository[domain.HashIndex],
+	leaseRepository repository.Repository[domain.LeaderLease],
 ) *ResourceServiceDB {
-	return &ResourceServiceDB{
+	svc := &ResourceServiceDB{
+		config:                            config,
 		metricsRegistry:                   metricsRegistry,
+		broker:                            broker,
 		orgService:                        orgService,
 		principalService:                  principalService,
 		resourceRepository:                resourceRepository,
 		resourceInstanceRepositoryFactory: resourceInstanceRepositoryFactory,
 		hashRepository:                    hashRepository,
+		leaseRepository:                   leaseRepository,
+		holder:                            uuid.NewV4().String(),
+		done:                              make(chan bool),
 	}
+	svc.wg.Add(1)
+	go svc.sweep()
+	return svc
+}
+
+// Close stops releasing expired resource instances
+func (s *ResourceServiceDB) Close() error {
+	close(s.done)
+	s.wg.Wait()
+	return nil
 }
 
 // CreateResource - creates a new instance of resource
@@ -149,6 +183,8 @@ func (s *ResourceServiceDB) DeleteResource(
 	if err != nil {
 		return err
 	}
+	s.orgService.invalidate(ctx, invalidation.OrganizationKind, organizationID, id)
+	s.broker.Publish(organizationID, namespace, types.ChangeEntityType_RESOURCE, id, 0, types.ChangeOperation_DELETED)
 	return s.hashRepository.Delete(
 		ctx,
 		organizationID,
@@ -226,18 +262,18 @@ func (s *ResourceServiceDB) QueryResourceInstances(
 		ctx, organizationID, namespace); err != nil {
 		return nil, "", err
 	}
-	instanceRepository, err := s.resourceInstanceRepositoryFactory.CreateResourceInstanceRepository(resourceID)
+	// released instances are kept separately so that they are not counted against capacity
+	var instanceRepository repository.Repository[types.ResourceInstance]
+	if predicate["state"] == types.ResourceState_RELEASED.String() {
+		predicate = copyPredicate(predicate)
+		delete(predicate, "state")
+		instanceRepository, err = s.resourceInstanceRepositoryFactory.CreateReleasedResourceInstanceRepository(resourceID)
+	} else {
+		instanceRepository, err = s.resourceInstanceRepositoryFactory.CreateResourceInstanceRepository(resourceID)
+	}
 	if err != nil {
 		return res, "", err
 	}
-
-	res, _, err = instanceRepository.Query(
-		ctx,
-		organizationID,
-		namespace,
-		predicate,
-		offset,
-		limit)
 	return instanceRepository.Query(
 		ctx,
 		organizationID,
@@ -247,7 +283,7 @@ func (s *ResourceServiceDB) QueryResourceInstances(
 		limit)
 }
 
-// AllocateResourceInstance - allocates resource-instance
+// AllocateResourceInstance - allocates resource-instance with a lease of expiry and returns deadline of the lease
 func (s *ResourceServiceDB) AllocateResourceInstance(
 	ctx context.Context,
 	organizationID string,
@@ -257,16 +293,16 @@ func (s *ResourceServiceDB) AllocateResourceInstance(
 	constraints string,
 	expiry time.Duration,
 	context map[string]string,
-) error {
+) (time.Time, error) {
 	defer s.metricsRegistry.Elapsed("resources_svc_allocate", "org", organizationID)()
 	if _, err := s.orgService.verifyOrganizationNamespace(
 		ctx, organizationID, namespace); err != nil {
-		return err
+		return time.Time{}, err
 	}
 	if constraints != "" {
 		resource, err := s.GetResource(ctx, organizationID, namespace, resourceID)
 		if err != nil {
-			return err
+			return time.Time{}, err
 		}
 		xPrincipal, err := s.principalService.GetPrincipalExt(
 			ctx,
@@ -274,7 +310,7 @@ func (s *ResourceServiceDB) AllocateResourceInstance(
 			namespace,
 			principalID)
 		if err != nil {
-			return err
+			return time.Time{}, err
 		}
 		matched, _, err := xPrincipal.CheckConstraints(
 			&services.AuthRequest{
@@ -287,36 +323,32 @@ func (s *ResourceServiceDB) AllocateResourceInstance(
 			constraints,
 		)
 		if err != nil {
-			return err
+			return time.Time{}, err
 		}
 		if !matched {
-			return domain.NewAuthError(fmt.Sprintf("constraints '%s' didn't match", constraints))
+			return time.Time{}, domain.NewAuthError(fmt.Sprintf("constraints '%s' didn't match", constraints))
 		}
 	} else {
 		if _, err := s.principalService.GetPrincipal(ctx, organizationID, principalID); err != nil {
-			return err
+			return time.Time{}, err
 		}
 	}
 
 	xInstance := domain.NewResourceInstanceExt(namespace, resourceID, principalID)
 	if err := xInstance.Validate(); err != nil {
-		return err
+		return time.Time{}, err
 	}
-	capacity, allocated, err := s.CountResourceInstances(ctx, organizationID, namespace, resourceID)
+	resource, err := s.resourceRepository.GetByID(ctx, organizationID, namespace, resourceID)
 	if err != nil {
-		return err
+		return time.Time{}, err
 	}
-	if capacity == 0 {
-		return domain.NewValidationError(
+	if resource.Capacity == 0 {
+		return time.Time{}, domain.NewValidationError(
 			fmt.Sprintf("capacity is not defined"))
 	}
-	if allocated >= capacity {
-		return domain.NewValidationError(
-			fmt.Sprintf("usage %d exceeds capacity %d", allocated, capacity))
-	}
 	instanceRepository, err := s.resourceInstanceRepositoryFactory.CreateResourceInstanceRepository(resourceID)
 	if err != nil {
-		return err
+		return time.Time{}, err
 	}
 	existing, _ := instanceRepository.GetByID(ctx, organizationID, namespace, xInstance.Delegate.Id)
 	var version int64 = 0
@@ -325,22 +357,97 @@ func (s *ResourceServiceDB) AllocateResourceInstance(
 		version = existing.Version
 		xInstance.Delegate.Version = existing.Version + 1
 		xInstance.Delegate.Created = existing.Created
-		return instanceRepository.Update(
+	} else {
+		xInstance.Delegate.Version = 1
+	}
+	lease := s.lease(expiry)
+	xInstance.Delegate.Expiry = durationpb.New(lease)
+	xInstance.Delegate.ExpiresAt = timestamppb.New(xInstance.Delegate.Updated.AsTime().Add(lease))
+	// capacity is checked by the data store when the instance is written so that concurrent
+	// allocations cannot exceed it, and existing instance is only changed if its version matches
+	allocate := func() error {
+		return instanceRepository.Allocate(
 			ctx,
 			organizationID,
 			namespace,
 			xInstance.Delegate.Id,
 			version,
+			int64(resource.Capacity),
 			xInstance.Delegate,
-			expiry)
+			s.storeExpiration(lease))
+	}
+	if err = allocate(); err != nil {
+		// leases that expired since the last sweep are released before giving up on capacity
+		var validationErr *domain.ValidationError
+		if !errors.As(err, &validationErr) {
+			return time.Time{}, err
+		}
+		if released, _ := s.releaseExpiredInstances(ctx, organizationID, namespace, resourceID); released == 0 {
+			return time.Time{}, err
+		}
+		if err = allocate(); err != nil {
+			return time.Time{}, err
+		}
+	}
+	s.broker.Publish(organizationID, namespace, types.ChangeEntityType_RESOURCE_INSTANCE,
+		xInstance.Delegate.Id, xInstance.Delegate.Version, changeOperation(version))
+	return xInstance.Delegate.ExpiresAt.AsTime(), nil
+}
+
+// RenewAllocation - extends lease of allocated resource-instance by expiry, which defaults to the
+// expiry of the allocation, and returns deadline of the renewed lease
+func (s *ResourceServiceDB) RenewAllocation(
+	ctx context.Context,
+	organizationID string,
+	namespace string,
+	resourceID string,
+	principalID string,
+	expiry time.Duration,
+) (time.Time, error) {
+	defer s.metricsRegistry.Elapsed("resources_svc_renew", "org", organizationID)()
+	if _, err := s.orgService.verifyOrganizationNamespace(
+		ctx, organizationID, namespace); err != nil {
+		return time.Time{}, err
 	}
-	return instanceRepository.Create(
+	xInstance := domain.NewResourceInstanceExt(namespace, resourceID, principalID)
+	if err := xInstance.Validate(); err != nil {
+		return time.Time{}, err
+	}
+	instanceRepository, err := s.resourceInstanceRepositoryFactory.CreateResourceInstanceRepository(resourceID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	instance, err := instanceRepository.GetByID(ctx, organizationID, namespace, xInstance.Delegate.Id)
+	if err != nil {
+		return time.Time{}, err
+	}
+	now := time.Now()
+	if instance.State == types.ResourceState_RELEASED || leaseExpired(instance, now) {
+		return time.Time{}, domain.NewNotFoundError(
+			fmt.Sprintf("lease of resource-instance %s has expired", instance.Id))
+	}
+	if expiry <= 0 && instance.Expiry != nil {
+		expiry = instance.Expiry.AsDuration()
+	}
+	lease := s.lease(expiry)
+	version := instance.Version
+	instance.Version++
+	instance.Expiry = durationpb.New(lease)
+	instance.ExpiresAt = timestamppb.New(now.Add(lease))
+	instance.Updated = timestamppb.New(now)
+	if err = instanceRepository.Update(
 		ctx,
 		organizationID,
 		namespace,
-		xInstance.Delegate.Id,
-		xInstance.Delegate,
-		expiry)
+		instance.Id,
+		version,
+		instance,
+		s.storeExpiration(lease)); err != nil {
+		return time.Time{}, err
+	}
+	s.broker.Publish(organizationID, namespace, types.ChangeEntityType_RESOURCE_INSTANCE,
+		instance.Id, instance.Version, types.ChangeOperation_UPDATED)
+	return instance.ExpiresAt.AsTime(), nil
 }
 
 // DeallocateResourceInstance - deallocates resource-instance
@@ -364,14 +471,20 @@ func (s *ResourceServiceDB) DeallocateResourceInstance(
 	if err != nil {
 		return err
 	}
-	return instanceRepository.Delete(
+	if err = instanceRepository.Delete(
 		ctx,
 		organizationID,
 		namespace,
-		xInstance.Delegate.Id)
+		xInstance.Delegate.Id); err != nil {
+		return err
+	}
+	s.broker.Publish(organizationID, namespace, types.ChangeEntityType_RESOURCE_INSTANCE,
+		xInstance.Delegate.Id, 0, types.ChangeOperation_DELETED)
+	return nil
 }
 
-// CountResourceInstances - size of total and allocated resource-instances
+// CountResourceInstances - size of total and allocated resource-instances, where instances whose leases
+// expired are not counted even if they haven't been released yet.
 func (s *ResourceServiceDB) CountResourceInstances(
 	ctx context.Context,
 	organizationID string,
@@ -397,12 +510,23 @@ func (s *ResourceServiceDB) CountResourceInstances(
 		return 0, 0, err
 	}
 	capacity = resource.Capacity
-	count, err := instanceRepository.Size(ctx, organizationID, namespace)
-	if err != nil {
-		return 0, 0, err
+	now := time.Now()
+	offset := ""
+	for {
+		instances, nextOffset, err := instanceRepository.Query(ctx, organizationID, namespace, nil, offset, 500)
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, instance := range instances {
+			if instance.State != types.ResourceState_RELEASED && !leaseExpired(instance, now) {
+				allocated++
+			}
+		}
+		if nextOffset == "" {
+			return capacity, allocated, nil
+		}
+		offset = nextOffset
 	}
-	allocated = int32(count)
-	return
 }
 
 // updateResource - save resource
@@ -433,6 +557,11 @@ func (s *ResourceServiceDB) updateResource(
 	if err != nil {
 		return err
 	}
+	if version > 0 {
+		s.orgService.invalidate(ctx, invalidation.OrganizationKind, organizationID, xResource.Delegate.Id)
+	}
+	s.broker.Publish(organizationID, xResource.Delegate.Namespace, types.ChangeEntityType_RESOURCE,
+		xResource.Delegate.Id, xResource.Delegate.Version, changeOperation(version))
 
 	// update mapping between resource-name and id
 	hash := xResource.Hash()
@@ -445,3 +574,183 @@ func (s *ResourceServiceDB) updateResource(
 		domain.NewHashIndex(hash, []string{xResource.Delegate.Id}),
 		time.Duration(0))
 }
+
+// ReleaseExpiredResourceInstances - releases resource-instances of all organizations whose leases expired
+func (s *ResourceServiceDB) ReleaseExpiredResourceInstances(
+	ctx context.Context,
+) (released int64, err error) {
+	defer s.metricsRegistry.Elapsed("resources_svc_release_expired")()
+	orgOffset := ""
+	for {
+		orgs, nextOrgOffset, err := s.orgService.GetOrganizations(ctx, nil, orgOffset, 100)
+		if err != nil {
+			return released, err
+		}
+		for _, org := range orgs {
+			for _, namespace := range org.Namespaces {
+				resourceOffset := ""
+				for {
+					resources, nextResourceOffset, err := s.resourceRepository.Query(
+						ctx, org.Id, namespace, nil, resourceOffset, 100)
+					if err != nil {
+						return released, err
+					}
+					for _, resource := range resources {
+						if resource.Capacity == 0 {
+							// instances cannot be allocated without capacity
+							continue
+						}
+						count, err := s.releaseExpiredInstances(ctx, org.Id, namespace, resource.Id)
+						released += count
+						if err != nil {
+							return released, err
+						}
+					}
+					if nextResourceOffset == "" {
+						break
+					}
+					resourceOffset = nextResourceOffset
+				}
+			}
+		}
+		if nextOrgOffset == "" {
+			return released, nil
+		}
+		orgOffset = nextOrgOffset
+	}
+}
+
+// releaseExpiredInstances moves instances of the resource whose leases expired to the released instances
+// and publishes their expiry, where candidates are found with the expiry index of the data store. The instance is marked as released with its version before it's removed so
+// that a concurrent renewal or another replica releasing the same instance fails with conflict.
+func (s *ResourceServiceDB) releaseExpiredInstances(
+	ctx context.Context,
+	organizationID string,
+	namespace string,
+	resourceID string,
+) (released int64, err error) {
+	instanceRepository, err := s.resourceInstanceRepositoryFactory.CreateResourceInstanceRepository(resourceID)
+	if err != nil {
+		return 0, err
+	}
+	releasedRepository, err := s.resourceInstanceRepositoryFactory.CreateReleasedResourceInstanceRepository(resourceID)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	offset := ""
+	var expired []*types.ResourceInstance
+	for {
+		// instances are kept in the data store for two sweeps after their leases expire
+		instances, nextOffset, err := instanceRepository.Expiring(
+			ctx, organizationID, namespace, now.Add(2*s.config.ResourceLeaseSweepInterval), offset, 500)
+		if err != nil {
+			return 0, err
+		}
+		for _, instance := range instances {
+			if leaseExpired(instance, now) {
+				expired = append(expired, instance)
+			}
+		}
+		if nextOffset == "" {
+			break
+		}
+		offset = nextOffset
+	}
+	for _, instance := range expired {
+		version := instance.Version
+		instance.Version++
+		instance.State = types.ResourceState_RELEASED
+		instance.Updated = timestamppb.New(now)
+		if err = instanceRepository.Update(
+			ctx, organizationID, namespace, instance.Id, version, instance, s.storeExpiration(0)); err != nil {
+			var conflictErr *domain.ConflictError
+			if errors.As(err, &conflictErr) {
+				continue
+			}
+			return released, err
+		}
+		if err = releasedRepository.Update(
+			ctx, organizationID, namespace, instance.Id, -1, instance, time.Duration(0)); err != nil {
+			return released, err
+		}
+		if err = instanceRepository.Delete(ctx, organizationID, namespace, instance.Id); err != nil {
+			return released, err
+		}
+		s.broker.Publish(organizationID, namespace, types.ChangeEntityType_RESOURCE_INSTANCE,
+			instance.Id, instance.Version, types.ChangeOperation_EXPIRED)
+		released++
+	}
+	return released, nil
+}
+
+// sweep releases expired resource instances every resource_lease_sweep_interval on the server that
+// holds the lease of the sweeper so that replicas don't scan all resources at the same time.
+func (s *ResourceServiceDB) sweep() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.config.ResourceLeaseSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if !s.leading(context.Background()) {
+				continue
+			}
+			if released, err := s.ReleaseExpiredResourceInstances(context.Background()); err != nil {
+				log.WithFields(log.Fields{
+					"Component": "ResourceServiceDB",
+					"Error":     err,
+				}).Warnf("failed to release expired resource instances")
+			} else if released > 0 {
+				log.WithFields(log.Fields{
+					"Component": "ResourceServiceDB",
+					"Released":  released,
+				}).Debugf("released expired resource instances")
+			}
+		}
+	}
+}
+
+// leading acquires or renews the lease of the sweeper for two sweeps and returns true if this server
+// holds it. Leases are written with their versions so that only one of the servers competing for an
+// expired lease acquires it.
+func (s *ResourceServiceDB) leading(ctx context.Context) bool {
+	acquired, err := acquireLease(ctx, s.leaseRepository, sweeperJob, s.holder, 2*s.config.ResourceLeaseSweepInterval)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"Component": "ResourceServiceDB",
+			"Error":     err,
+		}).Warnf("failed to get lease of sweeper")
+	}
+	return acquired
+}
+
+// lease returns duration of lease, which defaults to resource_instance_expiration.
+func (s *ResourceServiceDB) lease(expiry time.Duration) time.Duration {
+	if expiry <= 0 {
+		return s.config.ResourceInstanceExpiration
+	}
+	return expiry
+}
+
+// storeExpiration keeps instances in the data store for two sweeps after their leases expire so
+// that the sweeper can release them rather than the data store dropping them silently.
+func (s *ResourceServiceDB) storeExpiration(lease time.Duration) time.Duration {
+	return lease + 2*s.config.ResourceLeaseSweepInterval
+}
+
+// leaseExpired returns true if deadline of the lease has passed.
+func leaseExpired(instance *types.ResourceInstance, now time.Time) bool {
+	return instance.ExpiresAt != nil && !instance.ExpiresAt.AsTime().After(now)
+}
+
+// copyPredicate returns copy of predicate so that callers' predicate is not changed.
+func copyPredicate(predicate map[string]string) map[string]string {
+	res := make(map[string]string, len(predicate))
+	for k, v := range predicate {
+		res[k] = v
+	}
+	return res
+}
@@ -6,6 +6,8 @@ import (
 	"github.com/bhatti/PlexAuthZ/api/v1/types"
 	"github.com/bhatti/PlexAuthZ/internal/domain"
 	"github.com/stretchr/testify/require"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -62,7 +64,7 @@ func Test_Should_CRUD_Resources(t *testing.T) {
 	for _, next := range res {
 		require.Equal(t, resource.Namespace, next.Namespace)
 		require.Equal(t, resource.Name, next.Name)
-		err := store.AllocateResourceInstance(ctx,
+		_, err := store.AllocateResourceInstance(ctx,
 			org.Id,
 			org.Namespaces[0],
 			next.Id,
@@ -105,7 +107,7 @@ func Test_ShouldCreateAndGetAndDeleteResourceInstance(t *testing.T) {
 	require.Equal(t, 1, len(res))
 	for _, next := range res {
 		for i := 0; i < 20; i++ {
-			err = store.AllocateResourceInstance(
+			_, err = store.AllocateResourceInstance(
 				ctx,
 				org.Id,
 				org.Namespaces[0],
@@ -113,7 +115,7 @@ func Test_ShouldCreateAndGetAndDeleteResourceInstance(t *testing.T) {
 				principal.Id, "eq 1 1", time.Hour, nil)
 			require.NoError(t, err)
 		}
-		err = store.AllocateResourceInstance(
+		_, err = store.AllocateResourceInstance(
 			ctx,
 			org.Id,
 			org.Namespaces[0],
@@ -139,3 +141,175 @@ func Test_ShouldCreateAndGetAndDeleteResourceInstance(t *testing.T) {
 		require.NoError(t, err)
 	}
 }
+
+func Test_ShouldNotExceedCapacityWithConcurrentAllocations(t *testing.T) {
+	// GIVEN auth-service with a resource and more principals than its capacity
+	ctx := context.TODO()
+	store, org, err := newAuthServiceAndOrg()
+	require.NoError(t, err)
+	resource, err := domain.NewResourceBuilder().
+		WithName("/licenses/ide").
+		WithNamespace(org.Namespaces[0]).
+		WithCapacity(5).
+		WithAllowedActions("use").Build()
+	require.NoError(t, err)
+	resource, err = store.CreateResource(ctx, org.Id, resource)
+	require.NoError(t, err)
+	principals := make([]*types.Principal, 30)
+	for i := range principals {
+		principals[i], err = store.CreatePrincipal(ctx, &types.Principal{
+			Username:       fmt.Sprintf("user%d", i),
+			OrganizationId: org.Id,
+			Namespaces:     org.Namespaces,
+		})
+		require.NoError(t, err)
+	}
+
+	// WHEN all principals allocate the resource concurrently
+	var allocated int32
+	var wg sync.WaitGroup
+	for _, principal := range principals {
+		wg.Add(1)
+		go func(principalID string) {
+			defer wg.Done()
+			if _, err := store.AllocateResourceInstance(
+				ctx, org.Id, org.Namespaces[0], resource.Id, principalID, "", time.Hour, nil); err == nil {
+				atomic.AddInt32(&allocated, 1)
+			}
+		}(principal.Id)
+	}
+	wg.Wait()
+
+	// THEN only allocations within capacity should succeed
+	require.Equal(t, int32(5), allocated)
+	capacity, count, err := store.CountResourceInstances(ctx, org.Id, org.Namespaces[0], resource.Id)
+	require.NoError(t, err)
+	require.Equal(t, int32(5), capacity)
+	require.Equal(t, int32(5), count)
+}
+
+func Test_ShouldRenewAndReleaseExpiredLeases(t *testing.T) {
+	// GIVEN auth-service with a resource of single capacity and two principals
+	ctx := context.TODO()
+	store, org, err := newAuthServiceAndOrg()
+	require.NoError(t, err)
+	resourceService := store.(*authAdminServiceDB).ResourceServiceDB
+	resource, err := domain.NewResourceBuilder().
+		WithName("/licenses/db").
+		WithNamespace(org.Namespaces[0]).
+		WithCapacity(1).
+		WithAllowedActions("use").Build()
+	require.NoError(t, err)
+	resource, err = store.CreateResource(ctx, org.Id, resource)
+	require.NoError(t, err)
+	alice, err := store.CreatePrincipal(ctx, &types.Principal{
+		Username: "alice", OrganizationId: org.Id, Namespaces: org.Namespaces})
+	require.NoError(t, err)
+	bob, err := store.CreatePrincipal(ctx, &types.Principal{
+		Username: "bob", OrganizationId: org.Id, Namespaces: org.Namespaces})
+	require.NoError(t, err)
+	sub, _, err := resourceService.broker.Subscribe(org.Id, org.Namespaces[0],
+		[]types.ChangeEntityType{types.ChangeEntityType_RESOURCE_INSTANCE}, 0)
+	require.NoError(t, err)
+	defer resourceService.broker.Unsubscribe(sub)
+
+	// WHEN allocating and renewing a lease
+	expiresAt, err := store.AllocateResourceInstance(
+		ctx, org.Id, org.Namespaces[0], resource.Id, alice.Id, "", time.Second, nil)
+	require.NoError(t, err)
+	require.True(t, expiresAt.After(time.Now()))
+	renewedAt, err := store.RenewAllocation(ctx, org.Id, org.Namespaces[0], resource.Id, alice.Id, 0)
+	// THEN deadline of the lease should be extended
+	require.NoError(t, err)
+	require.True(t, renewedAt.After(expiresAt))
+	// AND lease of resource that is not allocated cannot be renewed
+	_, err = store.RenewAllocation(ctx, org.Id, org.Namespaces[0], resource.Id, bob.Id, time.Second)
+	require.Error(t, err)
+
+	// WHEN the lease expires
+	time.Sleep(time.Until(renewedAt) + 100*time.Millisecond)
+	// THEN it cannot be renewed
+	_, err = store.RenewAllocation(ctx, org.Id, org.Namespaces[0], resource.Id, alice.Id, time.Second)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "expired")
+
+	// WHEN releasing expired leases
+	released, err := resourceService.releaseExpiredInstances(ctx, org.Id, org.Namespaces[0], resource.Id)
+	// THEN the instance should be released instead of dropped
+	require.NoError(t, err)
+	require.Equal(t, int64(1), released)
+	_, allocated, err := store.CountResourceInstances(ctx, org.Id, org.Namespaces[0], resource.Id)
+	require.NoError(t, err)
+	require.Equal(t, int32(0), allocated)
+	instances, _, err := store.QueryResourceInstances(ctx, org.Id, org.Namespaces[0], resource.Id,
+		map[string]string{"state": types.ResourceState_RELEASED.String()}, "", 0)
+	require.NoError(t, err)
+	require.Len(t, instances, 1)
+	require.Equal(t, alice.Id, instances[0].PrincipalId)
+	require.Equal(t, types.ResourceState_RELEASED, instances[0].State)
+	// AND expiry of the lease should be published
+	var operations []types.ChangeOperation
+	for len(sub.Events()) > 0 {
+		operations = append(operations, (<-sub.Events()).Operation)
+	}
+	require.Equal(t, []types.ChangeOperation{
+		types.ChangeOperation_CREATED, types.ChangeOperation_UPDATED, types.ChangeOperation_EXPIRED}, operations)
+
+	// WHEN allocating after a lease expired before it was released
+	_, err = store.AllocateResourceInstance(
+		ctx, org.Id, org.Namespaces[0], resource.Id, bob.Id, "", time.Second, nil)
+	require.NoError(t, err)
+	time.Sleep(1100 * time.Millisecond)
+	_, err = store.AllocateResourceInstance(
+		ctx, org.Id, org.Namespaces[0], resource.Id, alice.Id, "", time.Minute, nil)
+	// THEN expired lease should be released so that capacity is not exceeded
+	require.NoError(t, err)
+	_, allocated, err = store.CountResourceInstances(ctx, org.Id, org.Namespaces[0], resource.Id)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), allocated)
+}
+
+func Test_ShouldNotCountLapsedLeasesAndSweepOnLeader(t *testing.T) {
+	// GIVEN auth-service with a resource and a principal
+	ctx := context.TODO()
+	store, org, err := newAuthServiceAndOrg()
+	require.NoError(t, err)
+	resourceService := store.(*authAdminServiceDB).ResourceServiceDB
+	resource, err := domain.NewResourceBuilder().
+		WithName("/licenses/ide").
+		WithNamespace(org.Namespaces[0]).
+		WithCapacity(2).
+		WithAllowedActions("use").Build()
+	require.NoError(t, err)
+	resource, err = store.CreateResource(ctx, org.Id, resource)
+	require.NoError(t, err)
+	alice, err := store.CreatePrincipal(ctx, &types.Principal{
+		Username: "alice", OrganizationId: org.Id, Namespaces: org.Namespaces})
+	require.NoError(t, err)
+
+	// WHEN the lease expires before it's released
+	_, err = store.AllocateResourceInstance(
+		ctx, org.Id, org.Namespaces[0], resource.Id, alice.Id, "", time.Second, nil)
+	require.NoError(t, err)
+	_, allocated, err := store.CountResourceInstances(ctx, org.Id, org.Namespaces[0], resource.Id)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), allocated)
+	time.Sleep(1100 * time.Millisecond)
+	// THEN it should not be counted
+	_, allocated, err = store.CountResourceInstances(ctx, org.Id, org.Namespaces[0], resource.Id)
+	require.NoError(t, err)
+	require.Equal(t, int32(0), allocated)
+
+	// WHEN another server competes for lease of the sweeper
+	other := NewResourceServiceDB(resourceService.config, resourceService.metricsRegistry,
+		resourceService.broker, resourceService.orgService,
+		resourceService.principalService, resourceService.resourceRepository,
+		resourceService.resourceInstanceRepositoryFactory, resourceService.hashRepository,
+		resourceService.leaseRepository)
+	defer func() { _ = other.Close() }()
+	_ = resourceService.leaseRepository.Delete(ctx, "", "", sweeperJob)
+	// THEN only one of them should sweep
+	require.True(t, resourceService.leading(ctx))
+	require.False(t, other.leading(ctx))
+	require.True(t, resourceService.leading(ctx))
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"github.com/bhatti/PlexAuthZ/api/v1/types"
 	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/events"
+	"github.com/bhatti/PlexAuthZ/internal/invalidation"
 	"github.com/bhatti/PlexAuthZ/internal/metrics"
 	"github.com/bhatti/PlexAuthZ/internal/repository"
 	"github.com/bhatti/PlexAuthZ/internal/utils"
@@ -16,6 +18,7 @@ import (
 // RoleServiceDB - manages persistence of roles data
 type RoleServiceDB struct {
 	metricsRegistry *metrics.Registry
+	broker          *events.Broker
 	orgService      *OrganizationServiceDB
 	roleRepository  repository.Repository[types.Role]
 	hashRepository  repository.Repository[domain.HashIndex]
@@ -24,12 +27,14 @@ type RoleServiceDB struct {
 // NewRoleServiceDB manages persistence of roles data
 func NewRoleServiceDB(
 	metricsRegistry *metrics.Registry,
+	broker *events.Broker,
 	orgService *OrganizationServiceDB,
 	roleRepository repository.Repository[types.Role],
 	hashRepository repository.Repository[domain.HashIndex],
 ) *RoleServiceDB {
 	return &RoleServiceDB{
 		metricsRegistry: metricsRegistry,
+		broker:          broker,
 		orgService:      orgService,
 		roleRepository:  roleRepository,
 		hashRepository:  hashRepository,
@@ -120,11 +125,16 @@ func (s *RoleServiceDB) DeleteRole(
 		ctx, organizationID, namespace); err != nil {
 		return err
 	}
-	return s.roleRepository.Delete(
+	if err := s.roleRepository.Delete(
 		ctx,
 		organizationID,
 		namespace,
-		id)
+		id); err != nil {
+		return err
+	}
+	s.orgService.invalidate(ctx, invalidation.RoleKind, organizationID, id)
+	s.broker.Publish(organizationID, namespace, types.ChangeEntityType_ROLE, id, 0, types.ChangeOperation_DELETED)
+	return nil
 }
 
 // GetRole - finds role
@@ -257,6 +267,11 @@ func (s *RoleServiceDB) updateRole(
 	if err != nil {
 		return err
 	}
+	if version > 0 {
+		s.orgService.invalidate(ctx, invalidation.RoleKind, organizationID, xRole.Delegate.Id)
+	}
+	s.broker.Publish(organizationID, xRole.Delegate.Namespace, types.ChangeEntityType_ROLE,
+		xRole.Delegate.Id, xRole.Delegate.Version, changeOperation(version))
 	hash := xRole.Hash()
 	return s.hashRepository.Update(
 		ctx,
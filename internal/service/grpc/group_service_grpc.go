@@ -60,6 +60,7 @@ func (s *GroupServiceGrpc) UpdateGroup(
 		ctx,
 		&services.UpdateGroupRequest{
 			Id:             group.Id,
+			Version:        group.Version,
 			OrganizationId: organizationID,
 			Namespace:      group.Namespace,
 			Name:           group.Name,
@@ -41,14 +41,16 @@ func (s *OrganizationServiceGrpc) GetOrganization(
 		return nil, err
 	}
 	return &types.Organization{
-		Id:         res.Id,
-		Version:    res.Version,
-		Name:       res.Name,
-		Namespaces: res.Namespaces,
-		Url:        res.Url,
-		ParentIds:  res.ParentIds,
-		Created:    res.Created,
-		Updated:    res.Updated,
+		Id:             res.Id,
+		Version:        res.Version,
+		Name:           res.Name,
+		Namespaces:     res.Namespaces,
+		Url:            res.Url,
+		ParentIds:      res.ParentIds,
+		RateLimit:      res.RateLimit,
+		AuditRetention: res.AuditRetention,
+		Created:        res.Created,
+		Updated:        res.Updated,
 	}, nil
 }
 
@@ -74,14 +76,16 @@ func (s *OrganizationServiceGrpc) GetOrganizations(
 			break
 		}
 		org := &types.Organization{
-			Id:         orgRes.Id,
-			Version:    orgRes.Version,
-			Name:       orgRes.Name,
-			Namespaces: orgRes.Namespaces,
-			Url:        orgRes.Url,
-			ParentIds:  orgRes.ParentIds,
-			Created:    orgRes.Created,
-			Updated:    orgRes.Updated,
+			Id:             orgRes.Id,
+			Version:        orgRes.Version,
+			Name:           orgRes.Name,
+			Namespaces:     orgRes.Namespaces,
+			Url:            orgRes.Url,
+			ParentIds:      orgRes.ParentIds,
+			RateLimit:      orgRes.RateLimit,
+			AuditRetention: orgRes.AuditRetention,
+			Created:        orgRes.Created,
+			Updated:        orgRes.Updated,
 		}
 		nextToken = orgRes.NextOffset
 		arr = append(arr, org)
@@ -96,10 +100,12 @@ func (s *OrganizationServiceGrpc) CreateOrganization(
 	res, err := s.clients.OrganizationsClient.Create(
 		ctx,
 		&services.CreateOrganizationRequest{
-			Namespaces: org.Namespaces,
-			Name:       org.Name,
-			Url:        org.Url,
-			ParentIds:  org.ParentIds,
+			Namespaces:     org.Namespaces,
+			Name:           org.Name,
+			Url:            org.Url,
+			ParentIds:      org.ParentIds,
+			RateLimit:      org.RateLimit,
+			AuditRetention: org.AuditRetention,
 		})
 	if err != nil {
 		return nil, err
@@ -115,12 +121,14 @@ func (s *OrganizationServiceGrpc) UpdateOrganization(
 	_, err := s.clients.OrganizationsClient.Update(
 		ctx,
 		&services.UpdateOrganizationRequest{
-			Id:         org.Id,
-			Version:    org.Version,
-			Namespaces: org.Namespaces,
-			Name:       org.Name,
-			Url:        org.Url,
-			ParentIds:  org.ParentIds,
+			Id:             org.Id,
+			Version:        org.Version,
+			Namespaces:     org.Namespaces,
+			Name:           org.Name,
+			Url:            org.Url,
+			ParentIds:      org.ParentIds,
+			RateLimit:      org.RateLimit,
+			AuditRetention: org.AuditRetention,
 		})
 	return err
 }
@@ -63,6 +63,7 @@ func (s *PermissionServiceGrpc) UpdatePermission(
 		ctx,
 		&services.UpdatePermissionRequest{
 			Id:             permission.Id,
+			Version:        permission.Version,
 			OrganizationId: organizationID,
 			Namespace:      permission.Namespace,
 			Scope:          permission.Scope,
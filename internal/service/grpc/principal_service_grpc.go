@@ -53,6 +53,7 @@ func (s *PrincipalServiceGrpc) UpdatePrincipal(
 		ctx,
 		&services.UpdatePrincipalRequest{
 			Id:             principal.Id,
+			Version:        principal.Version,
 			OrganizationId: principal.OrganizationId,
 			Namespaces:     principal.Namespaces,
 			Username:       principal.Username,
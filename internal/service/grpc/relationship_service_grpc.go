@@ -64,6 +64,7 @@ func (s *RelationshipServiceGrpc) UpdateRelationship(
 			OrganizationId: organizationID,
 			Namespace:      relationship.Namespace,
 			Id:             relationship.Id,
+			Version:        relationship.Version,
 			Relation:       relationship.Relation,
 			PrincipalId:    relationship.PrincipalId,
 			ResourceId:     relationship.ResourceId,
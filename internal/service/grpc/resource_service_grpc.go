@@ -64,6 +64,7 @@ func (s *ResourceServiceGrpc) UpdateResource(
 		ctx,
 		&services.UpdateResourceRequest{
 			Id:             resource.Id,
+			Version:        resource.Version,
 			OrganizationId: organizationID,
 			Namespace:      resource.Namespace,
 			Name:           resource.Name,
@@ -224,6 +225,7 @@ func (s *ResourceServiceGrpc) QueryResourceInstances(
 			State:       resourceRes.State,
 			Created:     resourceRes.Created,
 			Updated:     resourceRes.Updated,
+			ExpiresAt:   resourceRes.ExpiresAt,
 		})
 	}
 	return
@@ -261,7 +263,7 @@ func (s *ResourceServiceGrpc) CountResourceInstances(
 	return res.Capacity, res.Allocated, nil
 }
 
-// AllocateResourceInstance - allocates resource-instance.
+// AllocateResourceInstance - allocates resource-instance and returns deadline of its lease.
 func (s *ResourceServiceGrpc) AllocateResourceInstance(
 	ctx context.Context,
 	organizationID string,
@@ -271,24 +273,24 @@ func (s *ResourceServiceGrpc) AllocateResourceInstance(
 	constraints string,
 	expiry time.Duration,
 	context map[string]string,
-) error {
+) (time.Time, error) {
 	if organizationID == "" {
-		return domain.NewValidationError(
+		return time.Time{}, domain.NewValidationError(
 			fmt.Sprintf("organization-id is not defined"))
 	}
 	if namespace == "" {
-		return domain.NewValidationError(
+		return time.Time{}, domain.NewValidationError(
 			fmt.Sprintf("namespace is not defined"))
 	}
 	if resourceID == "" {
-		return domain.NewValidationError(
+		return time.Time{}, domain.NewValidationError(
 			fmt.Sprintf("resource-id is not defined"))
 	}
 	if principalID == "" {
-		return domain.NewValidationError(
+		return time.Time{}, domain.NewValidationError(
 			fmt.Sprintf("principal-id is not defined"))
 	}
-	_, err := s.clients.AuthClient.Allocate(
+	res, err := s.clients.AuthClient.Allocate(
 		ctx,
 		&services.AllocateResourceRequest{
 			OrganizationId: organizationID,
@@ -299,7 +301,51 @@ func (s *ResourceServiceGrpc) AllocateResourceInstance(
 			Expiry:         durationpb.New(expiry),
 			Context:        context,
 		})
-	return err
+	if err != nil {
+		return time.Time{}, err
+	}
+	return res.ExpiresAt.AsTime(), nil
+}
+
+// RenewAllocation - extends lease of allocated resource-instance.
+func (s *ResourceServiceGrpc) RenewAllocation(
+	ctx context.Context,
+	organizationID string,
+	namespace string,
+	resourceID string,
+	principalID string,
+	expiry time.Duration,
+) (time.Time, error) {
+	if organizationID == "" {
+		return time.Time{}, domain.NewValidationError(
+			fmt.Sprintf("organization-id is not defined"))
+	}
+	if namespace == "" {
+		return time.Time{}, domain.NewValidationError(
+			fmt.Sprintf("namespace is not defined"))
+	}
+	if resourceID == "" {
+		return time.Time{}, domain.NewValidationError(
+			fmt.Sprintf("resource-id is not defined"))
+	}
+	if principalID == "" {
+		return time.Time{}, domain.NewValidationError(
+			fmt.Sprintf("principal-id is not defined"))
+	}
+	req := &services.RenewAllocationRequest{
+		OrganizationId: organizationID,
+		Namespace:      namespace,
+		ResourceId:     resourceID,
+		PrincipalId:    principalID,
+	}
+	if expiry > 0 {
+		req.Expiry = durationpb.New(expiry)
+	}
+	res, err := s.clients.AuthClient.RenewAllocation(ctx, req)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return res.ExpiresAt.AsTime(), nil
 }
 
 // DeallocateResourceInstance - deallocates resource-instance.
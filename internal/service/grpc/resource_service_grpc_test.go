@@ -69,13 +69,17 @@ func testCRUDResources(
 		require.Equal(t, resource.Namespace, next.Namespace)
 		require.Equal(t, resource.Name, next.Name)
 		require.Equal(t, 2, len(resource.Attributes))
-		err := authService.AllocateResourceInstance(ctx, org.Id, org.Namespaces[0], next.Id, principal.Id, "", time.Hour, nil)
+		expiresAt, err := authService.AllocateResourceInstance(ctx, org.Id, org.Namespaces[0], next.Id, principal.Id, "", time.Hour, nil)
 		require.NoError(t, err)
+		renewedAt, err := authService.RenewAllocation(ctx, org.Id, org.Namespaces[0], next.Id, principal.Id, 2*time.Hour)
+		require.NoError(t, err)
+		require.True(t, renewedAt.After(expiresAt))
 		capacity, allocated, err := authService.CountResourceInstances(ctx, org.Id, org.Namespaces[0], next.Id)
 		require.Equal(t, int32(2), capacity)
 		require.Equal(t, int32(1), allocated)
 		instances, _, err := authService.QueryResourceInstances(ctx, org.Id, org.Namespaces[0], next.Id, nil, "", 0)
 		require.Equal(t, 1, len(instances))
+		require.Equal(t, renewedAt.Unix(), instances[0].ExpiresAt.AsTime().Unix())
 		err = authService.DeallocateResourceInstance(ctx, org.Id, org.Namespaces[0], next.Id, principal.Id)
 		require.NoError(t, err)
 		capacity, allocated, err = authService.CountResourceInstances(ctx, org.Id, org.Namespaces[0], next.Id)
@@ -118,7 +122,7 @@ func testCRUDResourcesWithInstances(
 	require.Equal(t, 1, len(res))
 	for _, next := range res {
 		for i := 0; i < 20; i++ {
-			err = authService.AllocateResourceInstance(
+			_, err = authService.AllocateResourceInstance(
 				ctx,
 				org.Id,
 				org.Namespaces[0],
@@ -126,7 +130,7 @@ func testCRUDResourcesWithInstances(
 				principal.Id, "eq true true", time.Hour, nil)
 			require.NoError(t, err)
 		}
-		err = authService.AllocateResourceInstance(
+		_, err = authService.AllocateResourceInstance(
 			ctx,
 			org.Id,
 			org.Namespaces[0],
@@ -66,6 +66,7 @@ func (s *RoleServiceGrpc) UpdateRole(
 			OrganizationId: organizationID,
 			Namespace:      role.Namespace,
 			Id:             role.Id,
+			Version:        role.Version,
 			Name:           role.Name,
 			ParentIds:      role.ParentIds,
 		})
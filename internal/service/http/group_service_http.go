@@ -66,6 +66,7 @@ func (h *GroupServiceHTTP) UpdateGroup(
 	}
 	req := &services.UpdateGroupRequest{
 		Id:             group.Id,
+		Version:        group.Version,
 		OrganizationId: organizationID,
 		Namespace:      group.Namespace,
 		Name:           group.Name,
@@ -32,10 +32,12 @@ func (h *OrganizationServiceHTTP) CreateOrganization(
 	ctx context.Context,
 	org *types.Organization) (*types.Organization, error) {
 	req := &services.CreateOrganizationRequest{
-		Namespaces: org.Namespaces,
-		Name:       org.Name,
-		Url:        org.Url,
-		ParentIds:  org.ParentIds,
+		Namespaces:     org.Namespaces,
+		Name:           org.Name,
+		Url:            org.Url,
+		ParentIds:      org.ParentIds,
+		RateLimit:      org.RateLimit,
+		AuditRetention: org.AuditRetention,
 	}
 	res := &services.CreateOrganizationResponse{}
 	_, _, err := h.post(ctx,
@@ -55,12 +57,14 @@ func (h *OrganizationServiceHTTP) UpdateOrganization(
 	ctx context.Context,
 	org *types.Organization) error {
 	req := &services.UpdateOrganizationRequest{
-		Id:         org.Id,
-		Version:    org.Version,
-		Namespaces: org.Namespaces,
-		Name:       org.Name,
-		Url:        org.Url,
-		ParentIds:  org.ParentIds,
+		Id:             org.Id,
+		Version:        org.Version,
+		Namespaces:     org.Namespaces,
+		Name:           org.Name,
+		Url:            org.Url,
+		ParentIds:      org.ParentIds,
+		RateLimit:      org.RateLimit,
+		AuditRetention: org.AuditRetention,
 	}
 	res := &services.UpdateOrganizationRequest{}
 	_, _, err := h.put(ctx,
@@ -104,14 +108,16 @@ func (h *OrganizationServiceHTTP) GetOrganization(
 		return nil, err
 	}
 	return &types.Organization{
-		Id:         res.Id,
-		Version:    res.Version,
-		Name:       res.Name,
-		Namespaces: res.Namespaces,
-		Url:        res.Url,
-		ParentIds:  res.ParentIds,
-		Created:    res.Created,
-		Updated:    res.Updated,
+		Id:             res.Id,
+		Version:        res.Version,
+		Name:           res.Name,
+		Namespaces:     res.Namespaces,
+		Url:            res.Url,
+		ParentIds:      res.ParentIds,
+		RateLimit:      res.RateLimit,
+		AuditRetention: res.AuditRetention,
+		Created:        res.Created,
+		Updated:        res.Updated,
 	}, nil
 }
 
@@ -138,14 +144,16 @@ func (h *OrganizationServiceHTTP) GetOrganizations(
 	}
 	for _, next := range *res {
 		arr = append(arr, &types.Organization{
-			Id:         next.Id,
-			Version:    next.Version,
-			Name:       next.Name,
-			Namespaces: next.Namespaces,
-			Url:        next.Url,
-			ParentIds:  next.ParentIds,
-			Created:    next.Created,
-			Updated:    next.Updated,
+			Id:             next.Id,
+			Version:        next.Version,
+			Name:           next.Name,
+			Namespaces:     next.Namespaces,
+			Url:            next.Url,
+			ParentIds:      next.ParentIds,
+			RateLimit:      next.RateLimit,
+			AuditRetention: next.AuditRetention,
+			Created:        next.Created,
+			Updated:        next.Updated,
 		})
 	}
 	nextOffset = resHeaders[domain.NextOffsetHeader]
@@ -69,6 +69,7 @@ func (h *PermissionServiceHTTP) UpdatePermission(
 	}
 	req := &services.UpdatePermissionRequest{
 		Id:             permission.Id,
+		Version:        permission.Version,
 		OrganizationId: organizationID,
 		Namespace:      permission.Namespace,
 		Scope:          permission.Scope,
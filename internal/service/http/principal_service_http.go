@@ -60,6 +60,7 @@ func (h *PrincipalServiceHTTP) UpdatePrincipal(
 	principal *types.Principal) error {
 	req := &services.UpdatePrincipalRequest{
 		Id:             principal.Id,
+		Version:        principal.Version,
 		OrganizationId: principal.OrganizationId,
 		Namespaces:     principal.Namespaces,
 		Username:       principal.Username,
@@ -70,6 +70,7 @@ func (h *RelationshipServiceHTTP) UpdateRelationship(
 		OrganizationId: organizationID,
 		Namespace:      relationship.Namespace,
 		Id:             relationship.Id,
+		Version:        relationship.Version,
 		Relation:       relationship.Relation,
 		PrincipalId:    relationship.PrincipalId,
 		ResourceId:     relationship.ResourceId,
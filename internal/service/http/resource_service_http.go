@@ -70,6 +70,7 @@ func (h *ResourceServiceHTTP) UpdateResource(
 	}
 	req := &services.UpdateResourceRequest{
 		Id:             resource.Id,
+		Version:        resource.Version,
 		OrganizationId: organizationID,
 		Namespace:      resource.Namespace,
 		Name:           resource.Name,
@@ -229,6 +230,7 @@ func (h *ResourceServiceHTTP) QueryResourceInstances(
 			State:       resourceRes.State,
 			Created:     resourceRes.Created,
 			Updated:     resourceRes.Updated,
+			ExpiresAt:   resourceRes.ExpiresAt,
 		})
 	}
 	nextOffset = resHeaders[domain.NextOffsetHeader]
@@ -267,7 +269,7 @@ func (h *ResourceServiceHTTP) CountResourceInstances(
 	return res.Capacity, res.Allocated, nil
 }
 
-// AllocateResourceInstance - allocates resource-instance
+// AllocateResourceInstance - allocates resource-instance and returns deadline of its lease
 func (h *ResourceServiceHTTP) AllocateResourceInstance(
 	ctx context.Context,
 	organizationID string,
@@ -277,21 +279,21 @@ func (h *ResourceServiceHTTP) AllocateResourceInstance(
 	constraints string,
 	expiry time.Duration,
 	context map[string]string,
-) error {
+) (time.Time, error) {
 	if organizationID == "" {
-		return domain.NewValidationError(
+		return time.Time{}, domain.NewValidationError(
 			fmt.Sprintf("organization-id is not defined"))
 	}
 	if namespace == "" {
-		return domain.NewValidationError(
+		return time.Time{}, domain.NewValidationError(
 			fmt.Sprintf("namespace is not defined"))
 	}
 	if resourceID == "" {
-		return domain.NewValidationError(
+		return time.Time{}, domain.NewValidationError(
 			fmt.Sprintf("resource-id is not defined"))
 	}
 	if principalID == "" {
-		return domain.NewValidationError(
+		return time.Time{}, domain.NewValidationError(
 			fmt.Sprintf("principal-id is not defined for allocating resource"))
 	}
 	req := &services.AllocateResourceRequest{
@@ -304,12 +306,59 @@ func (h *ResourceServiceHTTP) AllocateResourceInstance(
 		Context:        context,
 	}
 	res := &services.AllocateResourceResponse{}
-	_, _, err := h.put(ctx,
+	if _, _, err := h.put(ctx,
 		fmt.Sprintf("/api/v1/%s/%s/resources/%s/allocate/%s", organizationID, namespace, resourceID, principalID),
 		req,
 		res,
-	)
-	return err
+	); err != nil {
+		return time.Time{}, err
+	}
+	return res.ExpiresAt.AsTime(), nil
+}
+
+// RenewAllocation - extends lease of allocated resource-instance
+func (h *ResourceServiceHTTP) RenewAllocation(
+	ctx context.Context,
+	organizationID string,
+	namespace string,
+	resourceID string,
+	principalID string,
+	expiry time.Duration,
+) (time.Time, error) {
+	if organizationID == "" {
+		return time.Time{}, domain.NewValidationError(
+			fmt.Sprintf("organization-id is not defined"))
+	}
+	if namespace == "" {
+		return time.Time{}, domain.NewValidationError(
+			fmt.Sprintf("namespace is not defined"))
+	}
+	if resourceID == "" {
+		return time.Time{}, domain.NewValidationError(
+			fmt.Sprintf("resource-id is not defined"))
+	}
+	if principalID == "" {
+		return time.Time{}, domain.NewValidationError(
+			fmt.Sprintf("principal-id is not defined for renewing resource"))
+	}
+	req := &services.RenewAllocationRequest{
+		ResourceId:     resourceID,
+		OrganizationId: organizationID,
+		Namespace:      namespace,
+		PrincipalId:    principalID,
+	}
+	if expiry > 0 {
+		req.Expiry = durationpb.New(expiry)
+	}
+	res := &services.RenewAllocationResponse{}
+	if _, _, err := h.put(ctx,
+		fmt.Sprintf("/api/v1/%s/%s/resources/%s/renew/%s", organizationID, namespace, resourceID, principalID),
+		req,
+		res,
+	); err != nil {
+		return time.Time{}, err
+	}
+	return res.ExpiresAt.AsTime(), nil
 }
 
 // DeallocateResourceInstance - deallocates resource-instance
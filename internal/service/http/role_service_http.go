@@ -72,6 +72,7 @@ func (h *RoleServiceHTTP) UpdateRole(
 		OrganizationId: organizationID,
 		Namespace:      role.Namespace,
 		Id:             role.Id,
+		Version:        role.Version,
 		Name:           role.Name,
 		ParentIds:      role.ParentIds,
 	}
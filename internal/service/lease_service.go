@@ -0,0 +1,29 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// LeaseService - coordinates jobs that run on one server at a time, e.g., bootstrapping the system organization
+type LeaseService interface {
+	// AcquireLease acquires or renews lease of the job for the holder, which returns false if another
+	// holder has a live lease or the job is completed.
+	AcquireLease(
+		ctx context.Context,
+		job string,
+		holder string,
+		expiry time.Duration) (bool, error)
+
+	// CompleteJob marks the job as completed if the holder has its lease, which is written after the last
+	// step of the job.
+	CompleteJob(
+		ctx context.Context,
+		job string,
+		holder string) error
+
+	// JobCompleted returns true if the job is completed.
+	JobCompleted(
+		ctx context.Context,
+		job string) (bool, error)
+}
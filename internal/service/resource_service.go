@@ -44,7 +44,7 @@ type ResourceService interface {
 		offset string,
 		limit int64) (res []*types.Resource, nextOffset string, err error)
 
-	// AllocateResourceInstance - allocates resource-instance
+	// AllocateResourceInstance - allocates resource-instance with a lease and returns its deadline
 	AllocateResourceInstance(
 		ctx context.Context,
 		organizationID string,
@@ -54,7 +54,17 @@ type ResourceService interface {
 		constraints string,
 		expiry time.Duration,
 		context map[string]string,
-	) error
+	) (expiresAt time.Time, err error)
+
+	// RenewAllocation - extends lease of allocated resource-instance and returns its deadline
+	RenewAllocation(
+		ctx context.Context,
+		organizationID string,
+		namespace string,
+		resourceID string,
+		principalID string,
+		expiry time.Duration,
+	) (expiresAt time.Time, err error)
 
 	// DeallocateResourceInstance - deallocates resource-instance
 	DeallocateResourceInstance(
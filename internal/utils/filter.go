@@ -21,26 +21,7 @@ func MatchPredicate(
 		return false
 	}
 	for k, v := range predicate {
-		op := "=="
-		if strings.HasSuffix(k, ":==") {
-			k = k[0 : len(k)-3]
-			op = "=="
-		} else if strings.HasSuffix(k, ":!=") {
-			k = k[0 : len(k)-3]
-			op = "!="
-		} else if strings.HasSuffix(k, ":>=") {
-			k = k[0 : len(k)-3]
-			op = ">="
-		} else if strings.HasSuffix(k, ":<=") {
-			k = k[0 : len(k)-3]
-			op = "<="
-		} else if strings.HasSuffix(k, ":<") {
-			k = k[0 : len(k)-2]
-			op = "<"
-		} else if strings.HasSuffix(k, ":>") {
-			k = k[0 : len(k)-2]
-			op = ">"
-		}
+		k, op := ParsePredicateKey(k)
 		if m[k] == nil {
 			return false
 		}
@@ -82,3 +63,13 @@ func MatchPredicate(
 	}
 	return true
 }
+
+// ParsePredicateKey splits predicate key into field name and comparison operator, which defaults to ==.
+func ParsePredicateKey(k string) (string, string) {
+	for _, op := range []string{"==", "!=", ">=", "<=", "<", ">"} {
+		if strings.HasSuffix(k, ":"+op) {
+			return k[0 : len(k)-len(op)-1], op
+		}
+	}
+	return k, "=="
+}
@@ -0,0 +1,147 @@
+package web
+
+import (
+	"fmt"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/labstack/echo/v4"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"strings"
+)
+
+// SubjectKey key of the authenticated subject in the context of request.
+const SubjectKey = "subject"
+
+// ScopeKey key of the scope of authenticated subject in the context of request.
+const ScopeKey = "scope"
+
+// Authenticator authenticates HTTP requests.
+type Authenticator interface {
+	// Authenticate returns subject of the caller and its optional scope or empty subject
+	// if the request doesn't have credentials for the authenticator.
+	Authenticate(req *http.Request) (string, *domain.ClientScope, error)
+}
+
+// MTLSAuthenticator uses common name of the verified client certificate as subject.
+type MTLSAuthenticator struct {
+}
+
+// Authenticate returns common name and scope of URI SAN of the verified client certificate.
+func (a MTLSAuthenticator) Authenticate(req *http.Request) (string, *domain.ClientScope, error) {
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 || len(req.TLS.VerifiedChains[0]) == 0 {
+		return "", nil, nil
+	}
+	cert := req.TLS.VerifiedChains[0][0]
+	return cert.Subject.CommonName, domain.ParseClientScopeURIs(cert.URIs), nil
+}
+
+// APIKeyAuthenticator maps static API keys of the header to subjects.
+type APIKeyAuthenticator struct {
+	header  string
+	apiKeys map[string]domain.APIKeyConfig
+}
+
+// NewAPIKeyAuthenticator constructor
+func NewAPIKeyAuthenticator(header string, apiKeys []domain.APIKeyConfig) *APIKeyAuthenticator {
+	keys := make(map[string]domain.APIKeyConfig)
+	for _, apiKey := range apiKeys {
+		keys[apiKey.Key] = apiKey
+	}
+	return &APIKeyAuthenticator{
+		header:  header,
+		apiKeys: keys,
+	}
+}
+
+// Authenticate returns subject and scope of the API key.
+func (a *APIKeyAuthenticator) Authenticate(req *http.Request) (string, *domain.ClientScope, error) {
+	key := req.Header.Get(a.header)
+	if key == "" {
+		return "", nil, nil
+	}
+	apiKey, ok := a.apiKeys[key]
+	if !ok {
+		return "", nil, domain.NewAuthError("invalid api key")
+	}
+	return apiKey.Subject, apiKey.Scope(), nil
+}
+
+// JWTAuthenticator uses subject claim of the bearer token as subject.
+type JWTAuthenticator struct {
+	verifier *domain.JWTVerifier
+}
+
+// NewJWTAuthenticator constructor
+func NewJWTAuthenticator(config domain.JWTAuthConfig) (*JWTAuthenticator, error) {
+	verifier, err := domain.NewJWTVerifier(config)
+	if err != nil {
+		return nil, err
+	}
+	return &JWTAuthenticator{verifier: verifier}, nil
+}
+
+// Authenticate returns subject and scope of the bearer token.
+func (a *JWTAuthenticator) Authenticate(req *http.Request) (string, *domain.ClientScope, error) {
+	authorization := req.Header.Get("Authorization")
+	if !strings.HasPrefix(strings.ToLower(authorization), "bearer ") {
+		return "", nil, nil
+	}
+	claims, err := a.verifier.Verify(authorization)
+	if err != nil {
+		return "", nil, err
+	}
+	subject := a.verifier.Subject(claims)
+	if subject == "" {
+		return "", nil, domain.NewAuthError("subject claim is not defined in jwt token")
+	}
+	return subject, a.verifier.Scope(claims), nil
+}
+
+// NewAuthenticators creates authenticators based on configuration of REST APIs.
+func NewAuthenticators(config *domain.Config) (authenticators []Authenticator, err error) {
+	if config.HttpAuth.MTLS {
+		authenticators = append(authenticators, MTLSAuthenticator{})
+	}
+	if len(config.HttpAuth.APIKeys) > 0 {
+		authenticators = append(authenticators,
+			NewAPIKeyAuthenticator(config.HttpAuth.APIKeyHeader, config.HttpAuth.APIKeys))
+	}
+	if config.HttpAuth.JWT.Enabled() {
+		jwtAuthenticator, err := NewJWTAuthenticator(config.HttpAuth.JWT)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, jwtAuthenticator)
+	}
+	if len(authenticators) == 0 {
+		return nil, domain.NewValidationError("mtls, api keys or jwt is not defined for http auth")
+	}
+	return
+}
+
+// AuthenticationMiddleware stores subject and scope of the first authenticator that finds credentials
+// in the request under SubjectKey and ScopeKey and rejects requests without valid credentials.
+func AuthenticationMiddleware(authenticators ...Authenticator) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			for _, authenticator := range authenticators {
+				subject, scope, err := authenticator.Authenticate(c.Request())
+				if err != nil {
+					log.WithFields(log.Fields{
+						"Component": "AuthenticationMiddleware",
+						"Path":      c.Request().URL.Path,
+						"Error":     err,
+					}).Warnf("failed to authenticate request")
+					return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+				}
+				if subject != "" {
+					c.Set(SubjectKey, subject)
+					c.Set(ScopeKey, scope)
+					return next(c)
+				}
+			}
+			return echo.NewHTTPError(http.StatusUnauthorized,
+				fmt.Sprintf("credentials not found for %s", c.Request().URL.Path))
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package web
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_ShouldAuthenticateWithAuthenticators(t *testing.T) {
+	// GIVEN authenticators for mtls, api keys and jwt tokens
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	cfg.HttpAuth.MTLS = true
+	cfg.HttpAuth.APIKeys = []domain.APIKeyConfig{{Key: "root-key", Subject: "root"}}
+	cfg.HttpAuth.JWT.SigningKey = "secret"
+	authenticators, err := NewAuthenticators(cfg)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(authenticators))
+	e := echo.New()
+	e.Use(AuthenticationMiddleware(authenticators...))
+	e.GET("/subject", func(c echo.Context) error {
+		return c.String(http.StatusOK, c.Get(SubjectKey).(string))
+	})
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "client"}).
+		SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	// WHEN authenticating with client certificate, api key and jwt token
+	// THEN it should use subject of the credentials
+	req := httptest.NewRequest(http.MethodGet, "/subject", nil)
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{
+		{{Subject: pkix.Name{CommonName: "nobody"}}}}}
+	require.Equal(t, "nobody", serveTestRequest(t, e, req, http.StatusOK))
+	req = httptest.NewRequest(http.MethodGet, "/subject", nil)
+	req.Header.Set("X-API-Key", "root-key")
+	require.Equal(t, "root", serveTestRequest(t, e, req, http.StatusOK))
+	req = httptest.NewRequest(http.MethodGet, "/subject", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	require.Equal(t, "client", serveTestRequest(t, e, req, http.StatusOK))
+
+	// WHEN authenticating without or with invalid credentials
+	// THEN it should fail
+	serveTestRequest(t, e, httptest.NewRequest(http.MethodGet, "/subject", nil), http.StatusUnauthorized)
+	req = httptest.NewRequest(http.MethodGet, "/subject", nil)
+	req.Header.Set("X-API-Key", "bad-key")
+	serveTestRequest(t, e, req, http.StatusUnauthorized)
+	req = httptest.NewRequest(http.MethodGet, "/subject", nil)
+	req.Header.Set("Authorization", "Bearer bad-token")
+	serveTestRequest(t, e, req, http.StatusUnauthorized)
+}
+
+func Test_ShouldNotCreateAuthenticatorsWithoutCredentials(t *testing.T) {
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	cfg.HttpAuth = domain.HttpAuthConfig{Enabled: true}
+	_, err = NewAuthenticators(cfg)
+	require.Error(t, err)
+}
+
+func serveTestRequest(t *testing.T, e *echo.Echo, req *http.Request, status int) string {
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, status, rec.Code)
+	return rec.Body.String()
+}
@@ -114,6 +114,13 @@ func (w *DefaultHTTPClient) execute(
 		}
 	}
 
+	if w.config.HttpAuth.ClientAPIKey != "" {
+		req.Header.Set(w.config.HttpAuth.APIKeyHeader, w.config.HttpAuth.ClientAPIKey)
+	}
+	if w.config.HttpAuth.ClientToken != "" {
+		req.Header.Set("Authorization", "Bearer "+w.config.HttpAuth.ClientToken)
+	}
+
 	client := httpClient(w.config)
 	resp, err := client.Do(req)
 
@@ -1,8 +1,11 @@
 package web
 
 import (
+	"crypto/tls"
 	"embed"
+	"errors"
 	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/metrics"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	log "github.com/sirupsen/logrus"
@@ -43,15 +46,17 @@ type Server interface {
 
 // DefaultWebServer defines default web server
 type DefaultWebServer struct {
-	id     string
-	config *domain.Config
-	e      *echo.Echo
-	addr   string
+	id              string
+	config          *domain.Config
+	metricsRegistry *metrics.Registry
+	e               *echo.Echo
+	addr            string
 }
 
-// NewDefaultWebServer creates new instance of web server
-func NewDefaultWebServer(config *domain.Config) Server {
-	ws := &DefaultWebServer{config: config, e: echo.New()}
+// NewDefaultWebServer creates new instance of web server, where metrics of TLS certificates are
+// recorded in the registry.
+func NewDefaultWebServer(config *domain.Config, metricsRegistry *metrics.Registry) Server {
+	ws := &DefaultWebServer{config: config, metricsRegistry: metricsRegistry, e: echo.New()}
 	defaultLoggerConfig := middleware.LoggerConfig{
 		Skipper: middleware.DefaultSkipper,
 		Format: `{"time":"${time_rfc3339_nano}","id":"${id}","remote_ip":"${remote_ip}",` +
@@ -64,6 +69,11 @@ func NewDefaultWebServer(config *domain.Config) Server {
 	ws.e.Use(middleware.Recover())
 
 	ws.e.HTTPErrorHandler = func(err error, c echo.Context) {
+		var conflictErr *domain.ConflictError
+		var duplicateErr *domain.DuplicateError
+		if errors.As(err, &conflictErr) || errors.As(err, &duplicateErr) {
+			err = echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
 		ws.e.DefaultHTTPErrorHandler(err, c)
 	}
 
@@ -171,6 +181,17 @@ func (w *DefaultWebServer) Start(address string) error {
 	w.addr = address
 	log.WithField("HTTPListen", address).
 		Infof("##################### starting HTTP server %s #####################", w.id)
+	if w.config.HttpAuth.Enabled && w.config.HttpAuth.MTLS {
+		tlsConfig, err := w.config.SetupTLSServer(address, w.metricsRegistry)
+		if err != nil {
+			return err
+		}
+		// client certificates are optional as requests can also use api keys or jwt tokens
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		w.e.TLSServer.Addr = address
+		w.e.TLSServer.TLSConfig = tlsConfig
+		return w.e.StartServer(w.e.TLSServer)
+	}
 	return w.e.Start(address)
 }
 
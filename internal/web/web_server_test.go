@@ -0,0 +1,45 @@
+package web
+
+import (
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/metrics"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_ShouldReturnConflictStatusForConflictError(t *testing.T) {
+	// GIVEN a web server with handler that fails with conflict
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	ws := NewDefaultWebServer(cfg, metrics.New()).(*DefaultWebServer)
+	ws.PUT("/conflict", func(c APIContext) error {
+		return domain.NewConflictError("version is stale")
+	})
+
+	// WHEN invoking the handler
+	rec := httptest.NewRecorder()
+	ws.e.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/conflict", nil))
+
+	// THEN it should return conflict status
+	require.Equal(t, http.StatusConflict, rec.Code)
+	require.Contains(t, rec.Body.String(), "version is stale")
+}
+
+func Test_ShouldReturnConflictStatusForDuplicateError(t *testing.T) {
+	// GIVEN a web server with handler that fails with duplicate record
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	ws := NewDefaultWebServer(cfg, metrics.New()).(*DefaultWebServer)
+	ws.POST("/duplicate", func(c APIContext) error {
+		return domain.NewDuplicateError("record already exists")
+	})
+
+	// WHEN invoking the handler
+	rec := httptest.NewRecorder()
+	ws.e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/duplicate", nil))
+
+	// THEN it should return conflict status
+	require.Equal(t, http.StatusConflict, rec.Code)
+}
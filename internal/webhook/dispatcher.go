@@ -0,0 +1,334 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/events"
+	"github.com/bhatti/PlexAuthZ/internal/metrics"
+	log "github.com/sirupsen/logrus"
+	"github.com/twinj/uuid"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// SignatureHeader header with HMAC signature of the body
+	SignatureHeader = "X-PlexAuthZ-Signature"
+	// DeliveryHeader header with id of the delivery, which is same for all attempts
+	DeliveryHeader = "X-PlexAuthZ-Delivery"
+	// EventHeader header with entity type and operation of the event
+	EventHeader = "X-PlexAuthZ-Event"
+)
+
+// Dispatcher delivers change events of the broker to matching webhooks asynchronously, where
+// each delivery is recorded in the data store and failed attempts are retried with exponential
+// backoff until MaxAttempts after which the delivery is kept as a dead letter. Pending deliveries
+// whose claims expired, e.g., when their server was stopped, are resumed by one of the dispatchers.
+type Dispatcher struct {
+	config          domain.WebhookConfig
+	manager         *Manager
+	broker          *events.Broker
+	metricsRegistry *metrics.Registry
+	client          *http.Client
+	queue           chan *attempt
+	done            chan struct{}
+	wg              sync.WaitGroup
+	lock            sync.Mutex
+	sub             *events.Subscription
+	closed          bool
+}
+
+type attempt struct {
+	delivery *types.WebhookDelivery
+	claim    *domain.LeaderLease
+	secret   string
+}
+
+// NewDispatcher constructor, which starts workers for delivering events.
+func NewDispatcher(
+	config domain.WebhookConfig,
+	manager *Manager,
+	broker *events.Broker,
+	metricsRegistry *metrics.Registry,
+) (*Dispatcher, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	sub, _, err := broker.SubscribeAll(0, config.QueueSize)
+	if err != nil {
+		return nil, err
+	}
+	d := &Dispatcher{
+		config:          config,
+		manager:         manager,
+		broker:          broker,
+		metricsRegistry: metricsRegistry,
+		client:          newHTTPClient(config),
+		queue:           make(chan *attempt, config.QueueSize),
+		done:            make(chan struct{}),
+		sub:             sub,
+	}
+	d.wg.Add(config.Workers + 2)
+	go d.resume()
+	go d.dispatch()
+	for i := 0; i < config.Workers; i++ {
+		go d.work()
+	}
+	return d, nil
+}
+
+// Close stops dispatching of events, where pending retries are dropped but remain
+// recorded as pending deliveries.
+func (d *Dispatcher) Close() {
+	d.lock.Lock()
+	if d.closed {
+		d.lock.Unlock()
+		return
+	}
+	d.closed = true
+	d.broker.Unsubscribe(d.sub)
+	close(d.done)
+	d.lock.Unlock()
+	d.wg.Wait()
+}
+
+// resume claims and enqueues pending deliveries whose claims expired when the dispatcher is started and
+// then periodically, where receivers can use the delivery header to ignore duplicates if a server
+// resumed a delivery that was still being attempted by a slow server.
+func (d *Dispatcher) resume() {
+	defer d.wg.Done()
+	ticker := time.NewTicker(d.manager.claimExpiry() / 2)
+	defer ticker.Stop()
+	for {
+		d.resumeClaimed(context.Background())
+		select {
+		case <-d.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Dispatcher) resumeClaimed(ctx context.Context) {
+	err := d.manager.claimPendingDeliveries(ctx, func(delivery *types.WebhookDelivery, claim *domain.LeaderLease) {
+		webhook, err := d.manager.webhookRepository.GetByID(ctx, delivery.OrganizationId, "", delivery.WebhookId)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"Component": "WebhookDispatcher",
+				"Delivery":  delivery.Id,
+				"Webhook":   delivery.WebhookId,
+				"Error":     err,
+			}).Warnf("failed to find webhook of pending delivery")
+			return
+		}
+		d.enqueue(&attempt{delivery: delivery, claim: claim, secret: webhook.Secret})
+	})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"Component": "WebhookDispatcher",
+			"Error":     err,
+		}).Warnf("failed to resume pending webhook deliveries")
+	}
+}
+
+func (d *Dispatcher) dispatch() {
+	defer d.wg.Done()
+	var cursor int64
+	sub := d.sub
+	for {
+		select {
+		case <-d.done:
+			return
+		case event, ok := <-sub.Events():
+			if !ok {
+				var replay []*types.ChangeEvent
+				if sub, replay = d.resubscribe(cursor); sub == nil {
+					return
+				}
+				for _, event := range replay {
+					cursor = event.Cursor
+					d.fanout(event)
+				}
+				continue
+			}
+			cursor = event.Cursor
+			d.fanout(event)
+		}
+	}
+}
+
+// resubscribe resumes from the cursor when the subscription fell behind changes, which skips
+// missed events if they are no longer in the history of the broker.
+func (d *Dispatcher) resubscribe(cursor int64) (*events.Subscription, []*types.ChangeEvent) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.closed {
+		return nil, nil
+	}
+	sub, replay, err := d.broker.SubscribeAll(cursor, d.config.QueueSize)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"Component": "WebhookDispatcher",
+			"Cursor":    cursor,
+			"Error":     err,
+		}).Warnf("failed to resume webhook deliveries, skipping missed events")
+		if sub, replay, err = d.broker.SubscribeAll(0, d.config.QueueSize); err != nil {
+			return nil, nil
+		}
+	}
+	d.sub = sub
+	return sub, replay
+}
+
+func (d *Dispatcher) fanout(event *types.ChangeEvent) {
+	ctx := context.Background()
+	webhooks, err := d.manager.matchingWebhooks(ctx, event)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"Component":    "WebhookDispatcher",
+			"Organization": event.OrganizationId,
+			"Error":        err,
+		}).Warnf("failed to find webhooks")
+		return
+	}
+	for _, webhook := range webhooks {
+		now := timestamppb.Now()
+		delivery := &types.WebhookDelivery{
+			Id:             uuid.NewV4().String(),
+			WebhookId:      webhook.Id,
+			OrganizationId: webhook.OrganizationId,
+			Url:            webhook.Url,
+			Event:          event,
+			Status:         types.WebhookDeliveryStatus_PENDING,
+			Created:        now,
+			Updated:        now,
+		}
+		claim, err := d.manager.createDelivery(ctx, delivery)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"Component": "WebhookDispatcher",
+				"Webhook":   webhook.Id,
+				"Error":     err,
+			}).Warnf("failed to record webhook delivery")
+			continue
+		}
+		d.enqueue(&attempt{delivery: delivery, claim: claim, secret: webhook.Secret})
+	}
+}
+
+// enqueue adds attempt to the queue without blocking, where the attempt is dropped if the queue is
+// full but its delivery remains pending in the data store so that it's resumed after its claim expires.
+func (d *Dispatcher) enqueue(a *attempt) {
+	select {
+	case <-d.done:
+		return
+	default:
+	}
+	select {
+	case d.queue <- a:
+	default:
+		d.metricsRegistry.Incr("webhook_dropped", "org", a.delivery.OrganizationId)
+		log.WithFields(log.Fields{
+			"Component": "WebhookDispatcher",
+			"Delivery":  a.delivery.Id,
+			"QueueSize": d.config.QueueSize,
+		}).Warnf("webhook queue is full, dropping delivery attempt")
+	}
+}
+
+func (d *Dispatcher) work() {
+	defer d.wg.Done()
+	for {
+		select {
+		case <-d.done:
+			return
+		case a := <-d.queue:
+			d.deliver(a)
+		}
+	}
+}
+
+func (d *Dispatcher) deliver(a *attempt) {
+	delivery := a.delivery
+	delivery.Attempts++
+	code, err := d.post(a)
+	delivery.ResponseCode = int32(code)
+	if err == nil {
+		delivery.Status = types.WebhookDeliveryStatus_DELIVERED
+		delivery.LastError = ""
+		d.metricsRegistry.Incr("webhook_delivered", "org", delivery.OrganizationId)
+	} else {
+		delivery.LastError = err.Error()
+		if int(delivery.Attempts) >= d.config.MaxAttempts {
+			delivery.Status = types.WebhookDeliveryStatus_DEAD_LETTER
+			d.metricsRegistry.Incr("webhook_dead_letter", "org", delivery.OrganizationId)
+		} else {
+			d.metricsRegistry.Incr("webhook_retried", "org", delivery.OrganizationId)
+		}
+	}
+	if saveErr := d.manager.saveDelivery(context.Background(), delivery, a.claim); saveErr != nil {
+		var conflictErr *domain.ConflictError
+		if errors.As(saveErr, &conflictErr) {
+			// another server claimed the delivery after claim of this server expired
+			return
+		}
+		log.WithFields(log.Fields{
+			"Component": "WebhookDispatcher",
+			"Delivery":  delivery.Id,
+			"Error":     saveErr,
+		}).Warnf("failed to update webhook delivery")
+	}
+	if delivery.Status == types.WebhookDeliveryStatus_PENDING {
+		time.AfterFunc(d.backoff(delivery.Attempts), func() {
+			d.enqueue(a)
+		})
+	}
+}
+
+func (d *Dispatcher) post(a *attempt) (int, error) {
+	event := a.delivery.Event
+	body, err := json.Marshal(event)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequest(http.MethodPost, a.delivery.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(a.secret, body))
+	req.Header.Set(DeliveryHeader, a.delivery.Id)
+	req.Header.Set(EventHeader, fmt.Sprintf("%s.%s", event.EntityType, event.Operation))
+	res, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, res.Body)
+		_ = res.Body.Close()
+	}()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, fmt.Errorf("webhook responded with status %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+// backoff doubles initial backoff after each attempt up to the max backoff.
+func (d *Dispatcher) backoff(attempts int32) time.Duration {
+	backoff := d.config.InitialBackoff
+	for i := int32(1); i < attempts && backoff < d.config.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > d.config.MaxBackoff {
+		backoff = d.config.MaxBackoff
+	}
+	return backoff
+}
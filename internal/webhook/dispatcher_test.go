@@ -0,0 +1,274 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/events"
+	"github.com/bhatti/PlexAuthZ/internal/metrics"
+	"github.com/bhatti/PlexAuthZ/internal/repository/redis"
+	"github.com/stretchr/testify/require"
+	"github.com/twinj/uuid"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_ShouldDeliverSignedEventsToMatchingWebhooks(t *testing.T) {
+	// GIVEN receiver that verifies signatures and webhook for role changes
+	ctx := context.TODO()
+	received := make(chan *types.ChangeEvent, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !Verify("secret", body, r.Header.Get(SignatureHeader)) || r.Header.Get(DeliveryHeader) == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		event := &types.ChangeEvent{}
+		_ = json.Unmarshal(body, event)
+		received <- event
+	}))
+	defer receiver.Close()
+	manager, broker, dispatcher := newTestDispatcher(t, domain.WebhookConfig{AllowPrivateNetworks: true})
+	defer dispatcher.Close()
+	org := uuid.NewV4().String()
+	webhook, err := manager.CreateWebhook(ctx, &types.Webhook{
+		OrganizationId: org,
+		Url:            receiver.URL,
+		EntityTypes:    []types.ChangeEntityType{types.ChangeEntityType_ROLE},
+		Secret:         "secret",
+	})
+	require.NoError(t, err)
+
+	// WHEN publishing changes of a principal and a role
+	broker.Publish(org, "ns", types.ChangeEntityType_PRINCIPAL, "p1", 1, types.ChangeOperation_CREATED)
+	broker.Publish(org, "ns", types.ChangeEntityType_ROLE, "r1", 1, types.ChangeOperation_CREATED)
+
+	// THEN only role change should be delivered and recorded
+	select {
+	case event := <-received:
+		require.Equal(t, "r1", event.EntityId)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for delivery")
+	}
+	require.Eventually(t, func() bool {
+		res, _, err := manager.QueryDeliveries(ctx, org, webhook.Id, "DELIVERED", "", 0)
+		return err == nil && len(res) == 1 && res[0].Attempts == 1 && res[0].ResponseCode == 200
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Len(t, received, 0)
+}
+
+func Test_ShouldRecordDeadLetterAfterRetries(t *testing.T) {
+	// GIVEN receiver that always fails and webhook for all changes
+	ctx := context.TODO()
+	attempts := make(chan bool, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts <- true
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+	manager, broker, dispatcher := newTestDispatcher(t, domain.WebhookConfig{
+		MaxAttempts:          3,
+		InitialBackoff:       time.Millisecond,
+		MaxBackoff:           5 * time.Millisecond,
+		AllowPrivateNetworks: true,
+	})
+	defer dispatcher.Close()
+	org := uuid.NewV4().String()
+	webhook, err := manager.CreateWebhook(ctx, &types.Webhook{
+		OrganizationId: org,
+		Url:            receiver.URL,
+		Secret:         "secret",
+	})
+	require.NoError(t, err)
+
+	// WHEN publishing change of a relationship
+	broker.Publish(org, "ns", types.ChangeEntityType_RELATIONSHIP, "rel1", 1, types.ChangeOperation_DELETED)
+
+	// THEN delivery should be recorded as dead letter after max attempts
+	require.Eventually(t, func() bool {
+		res, _, err := manager.QueryDeliveries(ctx, org, webhook.Id, "DEAD_LETTER", "", 0)
+		return err == nil && len(res) == 1 && res[0].Attempts == 3 &&
+			res[0].ResponseCode == 500 && res[0].LastError != ""
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Len(t, attempts, 3)
+}
+
+func Test_ShouldValidateWebhooks(t *testing.T) {
+	// GIVEN manager of webhooks
+	ctx := context.TODO()
+	manager, _, dispatcher := newTestDispatcher(t, domain.WebhookConfig{AllowPrivateNetworks: true})
+	defer dispatcher.Close()
+	org := uuid.NewV4().String()
+
+	// WHEN creating webhook with invalid url or without secret
+	_, err := manager.CreateWebhook(ctx, &types.Webhook{OrganizationId: org, Url: "ftp://host", Secret: "s"})
+	// THEN it should fail
+	require.Error(t, err)
+	_, err = manager.CreateWebhook(ctx, &types.Webhook{OrganizationId: org, Url: "http://host"})
+	require.Error(t, err)
+
+	// WHEN updating webhook without secret
+	webhook, err := manager.CreateWebhook(ctx, &types.Webhook{OrganizationId: org, Url: "http://host", Secret: "s"})
+	require.NoError(t, err)
+	err = manager.UpdateWebhook(ctx, &types.Webhook{Id: webhook.Id, OrganizationId: org, Url: "https://host"})
+	require.NoError(t, err)
+
+	// THEN existing secret should be kept but not returned by queries
+	matched, err := manager.matchingWebhooks(ctx, &types.ChangeEvent{OrganizationId: org})
+	require.NoError(t, err)
+	require.Len(t, matched, 1)
+	require.Equal(t, "s", matched[0].Secret)
+	require.Equal(t, "https://host", matched[0].Url)
+	res, _, err := manager.QueryWebhooks(ctx, org, nil, "", 0)
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	require.Equal(t, "", res[0].Secret)
+	require.Equal(t, int64(2), res[0].Version)
+
+	// WHEN deleting webhook
+	require.NoError(t, manager.DeleteWebhook(ctx, org, webhook.Id))
+	// THEN cached webhooks should be invalidated
+	matched, err = manager.matchingWebhooks(ctx, &types.ChangeEvent{OrganizationId: org})
+	require.NoError(t, err)
+	require.Len(t, matched, 0)
+}
+
+func Test_ShouldRejectWebhooksOfInternalAddresses(t *testing.T) {
+	// GIVEN manager of webhooks that doesn't permit private networks
+	ctx := context.TODO()
+	manager, _, dispatcher := newTestDispatcher(t, domain.WebhookConfig{})
+	defer dispatcher.Close()
+	org := uuid.NewV4().String()
+
+	// WHEN creating webhooks of loopback, metadata, private or unspecified addresses
+	for _, u := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://10.1.2.3/hook",
+		"https://192.168.1.1/hook",
+		"http://[::1]/hook",
+		"http://0.0.0.0/hook",
+	} {
+		_, err := manager.CreateWebhook(ctx, &types.Webhook{OrganizationId: org, Url: u, Secret: "s"})
+		// THEN it should fail
+		require.Error(t, err, u)
+	}
+
+	// WHEN creating webhook of public address
+	_, err := manager.CreateWebhook(ctx, &types.Webhook{
+		OrganizationId: org, Url: "https://203.0.113.10/hook", Secret: "s"})
+	// THEN it should succeed
+	require.NoError(t, err)
+
+	// AND connections to internal addresses should be rejected when dialing
+	client := newHTTPClient(manager.config)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer receiver.Close()
+	_, err = client.Get(receiver.URL)
+	require.Error(t, err)
+}
+
+func Test_ShouldResumePendingDeliveriesOnce(t *testing.T) {
+	// GIVEN receiver, webhook, a delivery whose server was stopped and a delivery of a running server
+	ctx := context.TODO()
+	received := make(chan string, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get(DeliveryHeader)
+	}))
+	defer receiver.Close()
+	config := domain.WebhookConfig{AllowPrivateNetworks: true}
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	store, err := redis.NewRedisStore(cfg)
+	require.NoError(t, err)
+	newManager := func() *Manager {
+		manager, err := NewManager(config, store)
+		require.NoError(t, err)
+		return manager
+	}
+	manager := newManager()
+	org := &types.Organization{Id: uuid.NewV4().String(), Name: "webhook-org", Namespaces: []string{"ns"}}
+	require.NoError(t, manager.orgRepository.Create(ctx, "", "", org.Id, org, time.Duration(0)))
+	defer func() {
+		_ = manager.orgRepository.Delete(ctx, "", "", org.Id)
+	}()
+	webhook, err := manager.CreateWebhook(ctx, &types.Webhook{
+		OrganizationId: org.Id,
+		Url:            receiver.URL,
+		Secret:         "secret",
+	})
+	require.NoError(t, err)
+	newDelivery := func() *types.WebhookDelivery {
+		return &types.WebhookDelivery{
+			Id:             uuid.NewV4().String(),
+			WebhookId:      webhook.Id,
+			OrganizationId: org.Id,
+			Url:            webhook.Url,
+			Event:          &types.ChangeEvent{OrganizationId: org.Id, EntityType: types.ChangeEntityType_ROLE},
+			Status:         types.WebhookDeliveryStatus_PENDING,
+			Created:        timestamppb.New(time.Now().Add(-time.Minute)),
+		}
+	}
+	stopped := newManager()
+	abandoned := newDelivery()
+	claim, err := stopped.createDelivery(ctx, abandoned)
+	require.NoError(t, err)
+	expired := domain.NewLeaderLease(abandoned.Id, claim.Holder, claim.Version+1, -time.Second)
+	require.NoError(t, stopped.claimRepository.Update(
+		ctx, org.Id, "", abandoned.Id, claim.Version, expired, time.Duration(0)))
+	running := newManager()
+	_, err = running.createDelivery(ctx, newDelivery())
+	require.NoError(t, err)
+	res, _, err := manager.QueryDeliveries(ctx, org.Id, webhook.Id, "PENDING", "", 0)
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+
+	// WHEN starting dispatchers of two servers
+	for i := 0; i < 2; i++ {
+		broker, err := events.NewBroker(domain.WatchConfig{}, metrics.New())
+		require.NoError(t, err)
+		dispatcher, err := NewDispatcher(config, newManager(), broker, metrics.New())
+		require.NoError(t, err)
+		defer dispatcher.Close()
+	}
+
+	// THEN delivery with expired claim should be delivered once
+	select {
+	case id := <-received:
+		require.Equal(t, abandoned.Id, id)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for delivery")
+	}
+	require.Eventually(t, func() bool {
+		res, _, err := manager.QueryDeliveries(ctx, org.Id, webhook.Id, "DELIVERED", "", 0)
+		return err == nil && len(res) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(200 * time.Millisecond)
+	require.Len(t, received, 0)
+
+	// AND delivery of the running server should remain pending
+	res, _, err = manager.QueryDeliveries(ctx, org.Id, webhook.Id, "PENDING", "", 0)
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	require.NotEqual(t, abandoned.Id, res[0].Id)
+}
+
+func newTestDispatcher(t *testing.T, config domain.WebhookConfig) (*Manager, *events.Broker, *Dispatcher) {
+	cfg, err := domain.NewConfig("")
+	require.NoError(t, err)
+	store, err := redis.NewRedisStore(cfg)
+	require.NoError(t, err)
+	manager, err := NewManager(config, store)
+	require.NoError(t, err)
+	broker, err := events.NewBroker(domain.WatchConfig{}, metrics.New())
+	require.NoError(t, err)
+	dispatcher, err := NewDispatcher(config, manager, broker, metrics.New())
+	require.NoError(t, err)
+	return manager, broker, dispatcher
+}
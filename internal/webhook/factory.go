@@ -0,0 +1,45 @@
+package webhook
+
+import (
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/events"
+	"github.com/bhatti/PlexAuthZ/internal/metrics"
+	"github.com/bhatti/PlexAuthZ/internal/service/db"
+	"sync"
+)
+
+var shared = struct {
+	managers map[*domain.Config]*Manager
+	lock     sync.Mutex
+}{managers: make(map[*domain.Config]*Manager)}
+
+// Shared returns webhook manager of the config, which is created once with a dispatcher of the
+// change events so that events are delivered once when both gRPC servers and REST controllers
+// are started in the process. Metrics of the dispatcher are recorded in the registry of the process.
+func Shared(config *domain.Config, metricsRegistry *metrics.Registry) (*Manager, error) {
+	shared.lock.Lock()
+	defer shared.lock.Unlock()
+	if manager := shared.managers[config]; manager != nil {
+		return manager, nil
+	}
+	if !config.Webhook.Enabled {
+		return nil, domain.NewValidationError("webhooks are not enabled")
+	}
+	store, err := db.CreateDataStore(config)
+	if err != nil {
+		return nil, err
+	}
+	manager, err := NewManager(config.Webhook, store)
+	if err != nil {
+		return nil, err
+	}
+	broker, err := events.Shared(config, metricsRegistry)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = NewDispatcher(config.Webhook, manager, broker, metricsRegistry); err != nil {
+		return nil, err
+	}
+	shared.managers[config] = manager
+	return manager, nil
+}
@@ -0,0 +1,425 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"github.com/bhatti/PlexAuthZ/api/v1/types"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"github.com/bhatti/PlexAuthZ/internal/repository"
+	"github.com/twinj/uuid"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+const defaultQueryLimit = 100
+
+// Manager manages webhooks of organizations and records of their deliveries, where webhooks of
+// each organization are cached for matching change events. Each pending delivery has a claim of the
+// server delivering it, which is renewed after each attempt so that other servers only resume the
+// delivery after the claim expires.
+type Manager struct {
+	config             domain.WebhookConfig
+	holder             string
+	orgRepository      repository.Repository[types.Organization]
+	webhookRepository  repository.Repository[types.Webhook]
+	deliveryRepository repository.Repository[types.WebhookDelivery]
+	claimRepository    repository.Repository[domain.LeaderLease]
+	cache              map[string]*cachedWebhooks
+	lock               sync.RWMutex
+}
+
+type cachedWebhooks struct {
+	webhooks []*types.Webhook
+	loaded   time.Time
+}
+
+// NewManager constructor
+func NewManager(
+	config domain.WebhookConfig,
+	store repository.DataStore,
+) (*Manager, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	orgRepository, err := repository.NewOrganizationRepository(store)
+	if err != nil {
+		return nil, err
+	}
+	webhookRepository, err := repository.NewWebhookRepository(store)
+	if err != nil {
+		return nil, err
+	}
+	deliveryRepository, err := repository.NewWebhookDeliveryRepository(store, config.DeliveryRetention)
+	if err != nil {
+		return nil, err
+	}
+	claimRepository, err := repository.NewWebhookClaimRepository(store, config.DeliveryRetention)
+	if err != nil {
+		return nil, err
+	}
+	return &Manager{
+		config:             config,
+		holder:             uuid.NewV4().String(),
+		orgRepository:      orgRepository,
+		webhookRepository:  webhookRepository,
+		deliveryRepository: deliveryRepository,
+		claimRepository:    claimRepository,
+		cache:              make(map[string]*cachedWebhooks),
+	}, nil
+}
+
+// CreateWebhook - creates new webhook
+func (m *Manager) CreateWebhook(
+	ctx context.Context,
+	webhook *types.Webhook,
+) (*types.Webhook, error) {
+	if err := m.validate(ctx, webhook); err != nil {
+		return nil, err
+	}
+	if webhook.Secret == "" {
+		return nil, domain.NewValidationError("secret is not defined")
+	}
+	now := timestamppb.Now()
+	webhook.Id = uuid.NewV4().String()
+	webhook.Version = 1
+	webhook.Created = now
+	webhook.Updated = now
+	if err := m.webhookRepository.Create(
+		ctx,
+		webhook.OrganizationId,
+		"",
+		webhook.Id,
+		webhook,
+		time.Duration(0)); err != nil {
+		return nil, err
+	}
+	m.invalidate(webhook.OrganizationId)
+	return webhook, nil
+}
+
+// UpdateWebhook - updates url, event types and secret of webhook, where existing secret
+// is kept when it's not defined.
+func (m *Manager) UpdateWebhook(
+	ctx context.Context,
+	webhook *types.Webhook,
+) error {
+	if err := m.validate(ctx, webhook); err != nil {
+		return err
+	}
+	existing, err := m.webhookRepository.GetByID(ctx, webhook.OrganizationId, "", webhook.Id)
+	if err != nil {
+		return err
+	}
+	version := webhook.Version
+	if version == 0 {
+		version = existing.Version
+	}
+	if webhook.Secret == "" {
+		webhook.Secret = existing.Secret
+	}
+	webhook.Version = existing.Version + 1
+	webhook.Created = existing.Created
+	webhook.Updated = timestamppb.Now()
+	defer m.invalidate(webhook.OrganizationId)
+	return m.webhookRepository.Update(
+		ctx,
+		webhook.OrganizationId,
+		"",
+		webhook.Id,
+		version,
+		webhook,
+		time.Duration(0))
+}
+
+// DeleteWebhook - removes webhook
+func (m *Manager) DeleteWebhook(
+	ctx context.Context,
+	organizationId string,
+	id string,
+) error {
+	if id == "" {
+		return domain.NewValidationError("id is not defined")
+	}
+	defer m.invalidate(organizationId)
+	return m.webhookRepository.Delete(ctx, organizationId, "", id)
+}
+
+// QueryWebhooks - queries webhooks of organization without their secrets.
+func (m *Manager) QueryWebhooks(
+	ctx context.Context,
+	organizationId string,
+	predicates map[string]string,
+	offset string,
+	limit int64,
+) ([]*types.Webhook, string, error) {
+	res, nextOffset, err := m.webhookRepository.Query(ctx, organizationId, "", predicates, offset, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, webhook := range res {
+		webhook.Secret = ""
+	}
+	return res, nextOffset, nil
+}
+
+// QueryDeliveries - queries a page of deliveries of webhook with optional status such as DEAD_LETTER,
+// where deliveries of the page are sorted by created date in descending order.
+func (m *Manager) QueryDeliveries(
+	ctx context.Context,
+	organizationId string,
+	webhookId string,
+	status string,
+	offset string,
+	limit int64,
+) (res []*types.WebhookDelivery, nextOffset string, err error) {
+	value, ok := types.WebhookDeliveryStatus_value[status]
+	if status != "" && !ok {
+		return nil, "", domain.NewValidationError(fmt.Sprintf("invalid status %s", status))
+	}
+	if limit <= 0 {
+		limit = defaultQueryLimit
+	}
+	if status == types.WebhookDeliveryStatus_PENDING.String() {
+		// PENDING is the default value that isn't serialized for predicates so pending deliveries
+		// are found from their claims instead
+		res, nextOffset, err = m.pendingDeliveries(ctx, organizationId, webhookId, offset, limit)
+	} else {
+		predicates := map[string]string{"webhook_id": webhookId}
+		if status != "" {
+			predicates["status"] = fmt.Sprintf("%d", value)
+		}
+		res, nextOffset, err = m.deliveryRepository.Query(ctx, organizationId, "", predicates, offset, limit)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Created.AsTime().After(res[j].Created.AsTime())
+	})
+	return res, nextOffset, nil
+}
+
+// matchingWebhooks returns webhooks of organization of the event that subscribed to its entity type.
+func (m *Manager) matchingWebhooks(
+	ctx context.Context,
+	event *types.ChangeEvent,
+) (res []*types.Webhook, err error) {
+	webhooks, err := m.webhooks(ctx, event.OrganizationId)
+	if err != nil {
+		return nil, err
+	}
+	for _, webhook := range webhooks {
+		if len(webhook.EntityTypes) == 0 {
+			res = append(res, webhook)
+			continue
+		}
+		for _, entityType := range webhook.EntityTypes {
+			if entityType == event.EntityType {
+				res = append(res, webhook)
+				break
+			}
+		}
+	}
+	return
+}
+
+// webhooks returns cached webhooks of organization, which are loaded again after CacheTTL so that
+// changes on other servers are also picked up.
+func (m *Manager) webhooks(
+	ctx context.Context,
+	organizationId string,
+) ([]*types.Webhook, error) {
+	m.lock.RLock()
+	cached := m.cache[organizationId]
+	m.lock.RUnlock()
+	if cached != nil && time.Since(cached.loaded) < m.config.CacheTTL {
+		return cached.webhooks, nil
+	}
+	loaded := time.Now()
+	webhooks, _, err := m.webhookRepository.Query(ctx, organizationId, "", nil, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	m.lock.Lock()
+	m.cache[organizationId] = &cachedWebhooks{webhooks: webhooks, loaded: loaded}
+	m.lock.Unlock()
+	return webhooks, nil
+}
+
+func (m *Manager) invalidate(organizationId string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.cache, organizationId)
+}
+
+// pendingDeliveries returns a page of pending deliveries of webhook from claims of the organization,
+// which may have fewer deliveries than the limit as claims of other webhooks are skipped.
+func (m *Manager) pendingDeliveries(
+	ctx context.Context,
+	organizationId string,
+	webhookId string,
+	offset string,
+	limit int64,
+) (res []*types.WebhookDelivery, nextOffset string, err error) {
+	claims, nextOffset, err := m.claimRepository.Query(ctx, organizationId, "", nil, offset, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, claim := range claims {
+		delivery, err := m.deliveryRepository.GetByID(ctx, organizationId, "", claim.Job)
+		if err != nil {
+			continue // delivery expired before its claim was removed
+		}
+		if delivery.WebhookId == webhookId && delivery.Status == types.WebhookDeliveryStatus_PENDING {
+			res = append(res, delivery)
+		}
+	}
+	return res, nextOffset, nil
+}
+
+// createDelivery records new pending delivery with a claim of this server.
+func (m *Manager) createDelivery(ctx context.Context, delivery *types.WebhookDelivery) (*domain.LeaderLease, error) {
+	if err := m.deliveryRepository.Create(
+		ctx,
+		delivery.OrganizationId,
+		"",
+		delivery.Id,
+		delivery,
+		time.Duration(0)); err != nil {
+		return nil, err
+	}
+	claim := domain.NewLeaderLease(delivery.Id, m.holder, 1, m.claimExpiry())
+	if err := m.claimRepository.Create(
+		ctx,
+		delivery.OrganizationId,
+		"",
+		delivery.Id,
+		claim,
+		time.Duration(0)); err != nil {
+		return nil, err
+	}
+	return claim, nil
+}
+
+// saveDelivery records the attempt of delivery, where claim of a pending delivery is renewed first so
+// that it fails with conflict if another server claimed the delivery in the meantime, and claim of a
+// delivered or dead lettered delivery is removed.
+func (m *Manager) saveDelivery(
+	ctx context.Context,
+	delivery *types.WebhookDelivery,
+	claim *domain.LeaderLease,
+) error {
+	pending := delivery.Status == types.WebhookDeliveryStatus_PENDING
+	if pending {
+		renewed := domain.NewLeaderLease(claim.Job, m.holder, claim.Version+1, m.claimExpiry())
+		if err := m.claimRepository.Update(
+			ctx,
+			delivery.OrganizationId,
+			"",
+			delivery.Id,
+			claim.Version,
+			renewed,
+			time.Duration(0)); err != nil {
+			return err
+		}
+		*claim = *renewed
+	}
+	delivery.Updated = timestamppb.Now()
+	if err := m.deliveryRepository.Update(
+		ctx,
+		delivery.OrganizationId,
+		"",
+		delivery.Id,
+		-1, // no version
+		delivery,
+		time.Duration(0)); err != nil {
+		return err
+	}
+	if pending {
+		return nil
+	}
+	return m.claimRepository.Delete(ctx, delivery.OrganizationId, "", delivery.Id)
+}
+
+// claimPendingDeliveries claims pending deliveries of all organizations whose claims expired, e.g.,
+// when the server delivering them was stopped. Claims are updated with their version so that only
+// one server resumes each delivery.
+func (m *Manager) claimPendingDeliveries(
+	ctx context.Context,
+	claimed func(delivery *types.WebhookDelivery, claim *domain.LeaderLease),
+) error {
+	offset := ""
+	for {
+		orgs, nextOffset, err := m.orgRepository.Query(ctx, "", "", nil, offset, defaultQueryLimit)
+		if err != nil {
+			return err
+		}
+		for _, org := range orgs {
+			if err = m.claimExpiredDeliveries(ctx, org.Id, claimed); err != nil {
+				return err
+			}
+		}
+		if nextOffset == "" {
+			return nil
+		}
+		offset = nextOffset
+	}
+}
+
+func (m *Manager) claimExpiredDeliveries(
+	ctx context.Context,
+	organizationId string,
+	claimed func(delivery *types.WebhookDelivery, claim *domain.LeaderLease),
+) error {
+	offset := ""
+	for {
+		claims, nextOffset, err := m.claimRepository.Query(ctx, organizationId, "", nil, offset, defaultQueryLimit)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, claim := range claims {
+			if !claim.Expired(now) {
+				continue
+			}
+			renewed := domain.NewLeaderLease(claim.Job, m.holder, claim.Version+1, m.claimExpiry())
+			if m.claimRepository.Update(ctx, organizationId, "", claim.Job, claim.Version,
+				renewed, time.Duration(0)) != nil {
+				continue // claimed by another server
+			}
+			delivery, err := m.deliveryRepository.GetByID(ctx, organizationId, "", claim.Job)
+			if err != nil || delivery.Status != types.WebhookDeliveryStatus_PENDING {
+				// delivery expired or completed before its claim was removed
+				_ = m.claimRepository.Delete(ctx, organizationId, "", claim.Job)
+				continue
+			}
+			claimed(delivery, renewed)
+		}
+		if nextOffset == "" {
+			return nil
+		}
+		offset = nextOffset
+	}
+}
+
+// claimExpiry is the duration of claims, which covers the backoff and timeout of the next attempt.
+func (m *Manager) claimExpiry() time.Duration {
+	return 2 * (m.config.MaxBackoff + m.config.Timeout)
+}
+
+func (m *Manager) validate(ctx context.Context, webhook *types.Webhook) error {
+	if webhook.OrganizationId == "" {
+		return domain.NewValidationError("organization_id is not defined")
+	}
+	u, err := url.Parse(webhook.Url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return domain.NewValidationError(fmt.Sprintf("invalid url %s", webhook.Url))
+	}
+	if m.config.AllowPrivateNetworks {
+		return nil
+	}
+	return checkURL(ctx, u)
+}
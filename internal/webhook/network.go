@@ -0,0 +1,68 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"github.com/bhatti/PlexAuthZ/internal/domain"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// sharedAddressSpace of carrier-grade NAT, which is not reported as private by net.IP.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// checkAddress rejects loopback, link-local such as 169.254.169.254 of cloud metadata, private and
+// unspecified addresses so that webhooks cannot be used to reach internal services.
+func checkAddress(ip net.IP) error {
+	if ip == nil {
+		return domain.NewValidationError("invalid address")
+	}
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsMulticast() || sharedAddressSpace.Contains(ip) {
+		return domain.NewValidationError(fmt.Sprintf("address %s is not permitted for webhooks", ip))
+	}
+	return nil
+}
+
+// checkURL resolves host of the url and verifies all of its addresses.
+func checkURL(ctx context.Context, u *url.URL) error {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return domain.NewValidationError(fmt.Sprintf("failed to resolve host of url %s", u))
+	}
+	for _, addr := range addrs {
+		if err = checkAddress(addr.IP); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newHTTPClient returns client that verifies the resolved address of each connection, including
+// redirects, so that hosts cannot be re-bound to internal addresses after they are registered.
+func newHTTPClient(config domain.WebhookConfig) *http.Client {
+	dialer := &net.Dialer{Timeout: config.Timeout, KeepAlive: 30 * time.Second}
+	if !config.AllowPrivateNetworks {
+		dialer.Control = func(_ string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			return checkAddress(net.ParseIP(host))
+		}
+	}
+	return &http.Client{
+		Timeout: config.Timeout,
+		Transport: &http.Transport{
+			// proxies are not used so that the address of webhook is verified
+			DialContext:         dialer.DialContext,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+	}
+}